@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// accessListChange is an entry of the access list journal, slot is nil when the
+// address itself was added.
+type accessListChange struct {
+	address common.Address
+	slot    *common.Hash
+}
+
+// accessList holds the accounts and storage slots accessed by the transaction
+// (EIP-2929). The StateDB of this tree predates it, the list is kept by the EVM
+// and journaled along the state snapshots so that the accesses of a reverted
+// frame are cold again.
+type accessList struct {
+	addresses map[common.Address]map[common.Hash]struct{}
+	journal   []accessListChange
+	// journal length at each state snapshot
+	revisions map[int]int
+	// value of the slots at their first access, the EIP-2200 original value
+	original map[common.Address]map[common.Hash]common.Hash
+}
+
+func newAccessList() *accessList {
+	return &accessList{
+		addresses: make(map[common.Address]map[common.Hash]struct{}),
+		journal:   make([]accessListChange, 0),
+		revisions: make(map[int]int),
+		original:  make(map[common.Address]map[common.Hash]common.Hash),
+	}
+}
+
+// reset empties the access list for the next transaction, keeping its maps.
+func (al *accessList) reset() {
+	for address := range al.addresses {
+		delete(al.addresses, address)
+	}
+	for id := range al.revisions {
+		delete(al.revisions, id)
+	}
+	for address := range al.original {
+		delete(al.original, address)
+	}
+	al.journal = al.journal[:0]
+}
+
+// containsAddress returns whether the address is in the access list.
+func (al *accessList) containsAddress(address common.Address) bool {
+	_, ok := al.addresses[address]
+	return ok
+}
+
+// contains returns whether the address and the slot are in the access list.
+func (al *accessList) contains(address common.Address, slot common.Hash) (addressOk bool, slotOk bool) {
+	slots, ok := al.addresses[address]
+	if !ok {
+		return false, false
+	}
+	_, slotOk = slots[slot]
+	return true, slotOk
+}
+
+// addAddress adds an address to the access list and returns whether it was
+// cold, i.e. not present yet.
+func (al *accessList) addAddress(address common.Address) bool {
+	if al.containsAddress(address) {
+		return false
+	}
+	al.addresses[address] = make(map[common.Hash]struct{})
+	al.journal = append(al.journal, accessListChange{address: address})
+	return true
+}
+
+// addSlot adds the address and the slot to the access list and returns whether
+// each of them was cold.
+func (al *accessList) addSlot(address common.Address, slot common.Hash) (addressCold bool, slotCold bool) {
+	addressCold = al.addAddress(address)
+	if _, ok := al.addresses[address][slot]; ok {
+		return addressCold, false
+	}
+	al.addresses[address][slot] = struct{}{}
+	al.journal = append(al.journal, accessListChange{address: address, slot: &slot})
+	return addressCold, true
+}
+
+// originalState returns the value of the slot when the transaction first accessed
+// it, current is recorded as such on the first call. Every write of a slot is
+// preceded by an access, the first one sees the value of the slot before the
+// transaction.
+func (al *accessList) originalState(address common.Address, slot, current common.Hash) common.Hash {
+	slots, ok := al.original[address]
+	if !ok {
+		slots = make(map[common.Hash]common.Hash)
+		al.original[address] = slots
+	}
+	original, ok := slots[slot]
+	if !ok {
+		slots[slot] = current
+		return current
+	}
+	return original
+}
+
+// snapshot records the journal length of the state snapshot id.
+func (al *accessList) snapshot(id int) {
+	al.revisions[id] = len(al.journal)
+}
+
+// revertToSnapshot removes the entries added since the state snapshot id.
+func (al *accessList) revertToSnapshot(id int) {
+	length, ok := al.revisions[id]
+	if !ok {
+		return
+	}
+	for i := len(al.journal) - 1; i >= length; i-- {
+		change := al.journal[i]
+		if change.slot == nil {
+			delete(al.addresses, change.address)
+		} else {
+			delete(al.addresses[change.address], *change.slot)
+		}
+	}
+	al.journal = al.journal[:length]
+}
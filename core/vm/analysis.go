@@ -38,7 +38,7 @@ func (d destinations) has(codehash common.Hash, code []byte, dest *big.Int) bool
 
 	m, analysed := d[codehash]
 	if !analysed {
-		m = jumpdests(code)
+		m = analysisCache.get(codehash, code).jumpdests
 		d[codehash] = m
 	}
 	return (m[udest/8] & (1 << (udest % 8))) != 0
@@ -1 +1,69 @@
 package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// blake2FInput is the compression of the block "abc" with the initial state of
+// BLAKE2b-512 (EIP-152 test vector 5), rounds and final flag as given.
+func blake2FInput(rounds string, final string) []byte {
+	return common.Hex2Bytes(rounds + "48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b" +
+		"6162630000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000" +
+		"0300000000000000" + "0000000000000000" + final)
+}
+
+func TestPrecompiledBlake2F(t *testing.T) {
+	p := PrecompiledContractsIstanbul[common.BytesToAddress([]byte{9})]
+	tests := []struct {
+		input    []byte
+		gas      uint64
+		expected string
+		err      error
+	}{
+		// BLAKE2b-512("abc")
+		{blake2FInput("0000000c", "01"), 12, "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923", nil},
+		// no round: the state is the initialization vector with the counter and the flag
+		{blake2FInput("00000000", "01"), 0, "08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b", nil},
+		{blake2FInput("0000000c", "02"), 12, "", errBlake2FInvalidFinalFlag},
+		{blake2FInput("0000000c", "0100"), 0, "", errBlake2FInvalidInputLength},
+	}
+	for i, test := range tests {
+		if gas := p.RequiredGas(test.input); gas != test.gas {
+			t.Errorf("test %d: gas %d, want %d", i, gas, test.gas)
+		}
+		contract := NewContract(AccountRef(common.HexToAddress("1337")), nil, new(big.Int), test.gas)
+		res, err := RunPrecompiledContract(p, test.input, contract)
+		if err != test.err || common.Bytes2Hex(res) != test.expected {
+			t.Errorf("test %d: got %x, %v, want %s, %v", i, res, err, test.expected, test.err)
+		}
+	}
+}
+
+func TestPrecompiledIstanbulGas(t *testing.T) {
+	pairing := make([]byte, 2*192)
+	tests := []struct {
+		addr      byte
+		input     []byte
+		byzantium uint64
+		istanbul  uint64
+	}{
+		{6, nil, 500, Bn256AddGasIstanbul},
+		{7, nil, 40000, Bn256ScalarMulGasIstanbul},
+		{8, pairing, 100000 + 2*80000, 45000 + 2*34000},
+	}
+	for _, test := range tests {
+		addr := common.BytesToAddress([]byte{test.addr})
+		if gas := PrecompiledContractsByzantium[addr].RequiredGas(test.input); gas != test.byzantium {
+			t.Errorf("precompile %d: %d gas in Byzantium, want %d", test.addr, gas, test.byzantium)
+		}
+		if gas := PrecompiledContractsIstanbul[addr].RequiredGas(test.input); gas != test.istanbul {
+			t.Errorf("precompile %d: %d gas in Istanbul, want %d", test.addr, gas, test.istanbul)
+		}
+	}
+	if _, ok := PrecompiledContractsByzantium[common.BytesToAddress([]byte{9})]; ok {
+		t.Error("blake2f in Byzantium")
+	}
+}
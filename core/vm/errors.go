@@ -16,12 +16,63 @@
 
 package vm
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
 
 var (
-	ErrOutOfGas            = errors.New("out of gas")
-	ErrCodeStoreOutOfGas   = errors.New("contract creation code storage out of gas")
-	ErrDepth               = errors.New("max call depth exceeded")
-	ErrTraceLimitReached   = errors.New("the number of logs reached the specified limit")
-	ErrInsufficientBalance = errors.New("insufficient balance for transfer")
+	ErrOutOfGas              = errors.New("out of gas")
+	ErrCodeStoreOutOfGas     = errors.New("contract creation code storage out of gas")
+	ErrDepth                 = errors.New("max call depth exceeded")
+	ErrTraceLimitReached     = errors.New("the number of logs reached the specified limit")
+	ErrInsufficientBalance   = errors.New("insufficient balance for transfer")
+	ErrWriteProtection       = errors.New("evm: write protection")
+	ErrReturnDataOutOfBounds = errors.New("evm: return data out of bounds")
+	ErrExecutionReverted     = errors.New("evm: execution reverted")
+	ErrMaxCodeSizeExceeded   = errors.New("evm: max code size exceeded")
+	ErrFeeCapTooLow          = errors.New("max fee per gas less than block base fee")
+	ErrTipAboveFeeCap        = errors.New("max priority fee per gas higher than max fee per gas")
+	ErrExecutionTimeout      = errors.New("execution timeout")
+	ErrInstrumentationPanic  = errors.New("evm: instrumentation panic")
 )
+
+// ErrStackUnderflow is returned when an operation needs more items than the stack holds.
+type ErrStackUnderflow struct {
+	stackLen int
+	required int
+}
+
+func (e *ErrStackUnderflow) Error() string {
+	return fmt.Sprintf("stack underflow (%d <=> %d)", e.stackLen, e.required)
+}
+
+// ErrStackOverflow is returned when an operation would push the stack over its limit.
+type ErrStackOverflow struct {
+	stackLen int
+	limit    uint64
+}
+
+func (e *ErrStackOverflow) Error() string {
+	return fmt.Sprintf("stack limit reached %d (%d)", e.stackLen, e.limit)
+}
+
+// ErrInvalidOpCode is returned when the interpreter meets an undefined opcode.
+type ErrInvalidOpCode struct {
+	opcode OpCode
+}
+
+func (e *ErrInvalidOpCode) Error() string {
+	return fmt.Sprintf("invalid opcode 0x%x", int(e.opcode))
+}
+
+// ErrInvalidJump is returned when a jump does not land on a JUMPDEST.
+type ErrInvalidJump struct {
+	op   OpCode
+	dest *big.Int
+}
+
+func (e *ErrInvalidJump) Error() string {
+	return fmt.Sprintf("invalid jump destination (%v) %v", e.op, e.dest)
+}
@@ -26,13 +26,9 @@
 package vm
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"math/big"
-	"net/http"
-	"runtime"
 	"sync"
 	"sync/atomic"
 
@@ -56,8 +52,10 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, snapshot int, contract *Contract, input []byte) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiledContracts := PrecompiledContracts
-		if p := precompiledContracts[*contract.CodeAddr]; p != nil {
+		if p := evm.precompile(*contract.CodeAddr); p != nil {
+			if HooksEnabled() {
+				return hacker_run_precompile(evm, p, input, contract)
+			}
 			return RunPrecompiledContract(p, input, contract)
 		}
 	}
@@ -87,6 +85,7 @@ type Context struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	BaseFee     *big.Int       // Provides information for BASEFEE, nil before London
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -97,7 +96,7 @@ type Context struct {
 // specific errors should ever be performed. The interpreter makes
 // sure that any errors generated are to be considered faulty code.
 //
-// The EVM should never be reused and is not thread safe.
+// The EVM is not thread safe. It is reused for another transaction after Reset.
 type EVM struct {
 	// Context provides auxiliary blockchain related information
 	Context
@@ -110,6 +109,9 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// forkRules are the rules of the forks after Byzantium opted in to
+	// (hacker_fork.go)
+	forkRules hackerRules
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -119,10 +121,25 @@ type EVM struct {
 	// abort is used to abort the EVM calling operations
 	// NOTE: must be set atomically
 	abort int32
+	// accessList holds the accounts and slots accessed by the transaction (EIP-2929)
+	accessList *accessList
+	// chainID is reported by CHAINID instead of the one of the chain config when set
+	chainID *big.Int
+	// steps counts the operations of the transaction, it is cancelled past stepLimit
+	// when set
+	steps     uint64
+	stepLimit uint64
+	// validation checks the operations of a validation phase when set
+	// (hacker_validation.go)
+	validation *hackerValidationGuard
+	// callTree is the call tree being recorded (hacker_contractcall.go)
+	callTree *hackerCallTree
+	// seed pins the block environment of a harness execution (hacker_prng.go)
+	seed *common.Hash
 }
 
 // NewEVM retutrns a new EVM evmironment. The returned EVM is not thread safe
-// and should only ever be used *once*.
+// and should only ever be used *once*, or reset between transactions.
 func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
 	evm := &EVM{
 		Context:     ctx,
@@ -130,18 +147,42 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		vmConfig:    vmConfig,
 		chainConfig: chainConfig,
 		chainRules:  chainConfig.Rules(ctx.BlockNumber),
+		accessList:  newAccessList(),
 	}
+	evm.forkRules = hackerForkRules(evm.chainRules)
 
 	evm.interpreter = NewInterpreter(evm, vmConfig)
 	return evm
 }
 
+// Reset prepares the EVM for another transaction on statedb in the block context
+// ctx, the fuzz harness reuses one EVM and its interpreter across the executions of
+// a campaign instead of allocating them per message. The chain id override is kept.
+func (evm *EVM) Reset(ctx Context, statedb StateDB) {
+	evm.Context = ctx
+	evm.StateDB = statedb
+	evm.depth = 0
+	evm.chainRules = evm.chainConfig.Rules(ctx.BlockNumber)
+	evm.forkRules = hackerForkRules(evm.chainRules)
+	atomic.StoreInt32(&evm.abort, 0)
+	evm.steps = 0
+	evm.callTree = nil
+	evm.seed = nil
+	evm.accessList.reset()
+	evm.interpreter.Reset()
+}
+
 // Cancel cancels any running EVM operation. This may be called concurrently and
 // it's safe to be called multiple times.
 func (evm *EVM) Cancel() {
 	atomic.StoreInt32(&evm.abort, 1)
 }
 
+// Cancelled returns whether Cancel was called since the EVM was created or reset.
+func (evm *EVM) Cancelled() bool {
+	return atomic.LoadInt32(&evm.abort) == 1
+}
+
 // Call executes the contract associated with the addr with the given input as parameters. It also handles any
 // necessary value transfer required and takes the necessary steps to create accounts and reverses the state in
 // case of an execution error or failed value transfer.
@@ -149,18 +190,11 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if caller == nil {
 		fmt.Println("caller is nil")
 	}
-	defer func() { // 必须要先声明defer，否则不能捕获到panic异常
-		if err := recover(); err != nil {
-			Println("error happened in Evm.Call")
-			Printf("%v", err) // 这里的err其实就是panic传入的内容
-			for i := 0; i < 10; i++ {
-				funcName, file, line, ok := runtime.Caller(i)
-				if ok {
-					Printf("frame %v:[func:%v,file:%v,line:%v]\n", i, runtime.FuncForPC(funcName).Name(), file, line)
-				}
-			}
-		}
-	}()
+	hooks := HooksEnabled()
+	snapshot := -1
+	if hooks {
+		defer hackerRecoverFrame(CALL, evm, nil, &snapshot, &ret, &leftOverGas, &err)
+	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
@@ -176,14 +210,21 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		return nil, gas, ErrInsufficientBalance
 	}
 
-	var (
-		to       = AccountRef(addr)
-		snapshot = evm.StateDB.Snapshot()
-	)
+	if hooks && evm.depth == 0 {
+		hacker_execution_start(evm)
+		defer hacker_execution_end(evm)
+		defer checkBalanceWatchpoints(evm, caller.Address(), addr, input, watchedBalances(evm.StateDB))
+	}
+	evm.prepareAccessList(addr)
+	to := AccountRef(addr)
+	snapshot = evm.snapshot()
 	if !evm.StateDB.Exist(addr) {
-		if PrecompiledContracts[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		if evm.precompile(addr) == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 			return nil, gas, nil
 		}
+		if hooks {
+			hacker_account_created(evm, addr)
+		}
 		evm.StateDB.CreateAccount(addr)
 	}
 	evm.Transfer(evm.StateDB, caller.Address(), to.Address(), value)
@@ -194,7 +235,9 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	contract := NewContract(caller, to, value, gas)
 	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
 
-	log.Printf("Call to Contract/Account@%s", addr.Hex())
+	if hooks {
+		log.Printf("Call to Contract/Account@%s", addr.Hex())
+	}
 	// if caller != nil&&!isRelOracle(contract.Address()) {
 	// 	/***
 	// 	*record Call action.And create HackerContractCall object
@@ -226,14 +269,20 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// when we're in homestead this also counts for code storage gas errors.
 	// nextRevisionId := evm.StateDB.GetNextRevisionId()
 	if err != nil {
-		if true == GetGlobalWatchDog().TurnOn() {
-			GetGlobalWatchDog().ThrowError()
+		if hooks {
+			for _, dog := range hackerDogs(evm) {
+				if dog.watches(evm) && dog.TurnOn() {
+					dog.ThrowError()
+				}
+			}
+			if evm.depth == 0 {
+				hacker_root_failed(evm)
+			}
 		}
-		if true == GetGlobalTracerWatchDog().TurnOn() {
-			GetGlobalTracerWatchDog().ThrowError()
+		evm.revertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
 		}
-		contract.UseGas(contract.Gas)
-		evm.StateDB.RevertToSnapshot(snapshot)
 		// nextRevisionId = snapshot
 	}
 
@@ -271,12 +320,12 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 //
 // CallCode differs from Call in the sense that it executes the given address' code with the caller as context.
 func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
-	defer func() { // 必须要先声明defer，否则不能捕获到panic异常
-		if err := recover(); err != nil {
-			Println("CallCode")
-			Println(err) // 这里的err其实就是panic传入的内容，55
-		}
-	}()
+	hooks := HooksEnabled()
+	snapshot := -1
+	var call *HackerContractCall
+	if hooks {
+		defer hackerRecoverFrame(CALLCODE, evm, &call, &snapshot, &ret, &leftOverGas, &err)
+	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
@@ -285,14 +334,20 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
+		if hooks {
+			hacker_refused_call(evm, CALLCODE, caller, addr, value, gas, input, ErrDepth)
+		}
 		return nil, gas, ErrDepth
 	}
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
+		if hooks {
+			hacker_refused_call(evm, CALLCODE, caller, addr, value, gas, input, ErrInsufficientBalance)
+		}
 		return nil, gas, ErrInsufficientBalance
 	}
 
+	snapshot = evm.snapshot()
 	var (
-		snapshot       = evm.StateDB.Snapshot()
 		to             = AccountRef(caller.Address())
 		nextRevisionId = snapshot
 	)
@@ -302,62 +357,20 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	contract := NewContract(caller, to, value, gas)
 	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
 
-	if caller != nil && !isRelOracle(contract.Address()) {
-		/***
-		*record CallCode action.And create HackerContractCall object
-		*then push the object to the stack.
-		**/
-		hacker_init(evm, contract, input)
-		if hacker_call_stack == nil {
-			Println("call stack is nil")
-			return
-		}
-		call := hacker_call_stack.peek()
-		if call == nil {
-			Println("call is nil")
-			return
-		}
-		nextCall := call.OnCallCode(caller, contract.Address(), *value, *new(big.Int).SetUint64(gas), input)
-		nextCall.snapshotId = snapshot
-		//nextCall.nextRevisionId = evm.StateDB.GetNextRevisonId()
-		if nextCall == nil {
-			Println("nextcall is nil")
-			return
-		}
-		hacker_call_stack.push(nextCall)
-		Printf("\npush call@%p into stack", nextCall)
-		/***/
+	if hooks && caller != nil && !isRelOracle(contract.Address()) {
+		// record CallCode action: push a HackerContractCall on the hacker call stack
+		call = hackerOpenCall(CALLCODE, evm, caller, contract, value, gas, input, snapshot)
 	}
 
 	ret, err = run(evm, snapshot, contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
-		evm.StateDB.RevertToSnapshot(snapshot)
-	}
-	if caller != nil && !isRelOracle(contract.Address()) {
-		Println("\nclose call...")
-		// subcriber.Close()
-		// subcriber.Write()
-		/**
-		*CallCode action finish. So pop the object on top of the stack.
-		*and set object to "close" state, also record final related state.
-		**/
-		if hacker_call_stack != nil {
-			call := hacker_call_stack.pop()
-			call.nextRevisionId = nextRevisionId
-			if err != nil {
-				call.throwException = true
-			}
-			if call == nil {
-				Println("call is nil")
-				return
-			}
-			call.OnCloseCall(*new(big.Int).SetUint64(contract.Gas))
-			if hacker_call_stack.len() == 1 {
-				hacker_close()
-			}
+		evm.revertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
 		}
 	}
+	// the action finished: pop the HackerContractCall and record its final state
+	hackerCloseCall(evm, call, nextRevisionId, err, contract.Gas)
 	return ret, contract.Gas, err
 }
 
@@ -367,12 +380,12 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 // DelegateCall differs from CallCode in the sense that it executes the given address' code with the caller as context
 // and the caller is set to the caller of the caller.
 func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
-	defer func() { // 必须要先声明defer，否则不能捕获到panic异常
-		if err := recover(); err != nil {
-			Println("DelegateCall")
-			Println(err) // 这里的err其实就是panic传入的内容，55
-		}
-	}()
+	hooks := HooksEnabled()
+	snapshot := -1
+	var call *HackerContractCall
+	if hooks {
+		defer hackerRecoverFrame(DELEGATECALL, evm, &call, &snapshot, &ret, &leftOverGas, &err)
+	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
@@ -381,11 +394,14 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
+		if hooks {
+			hacker_refused_call(evm, DELEGATECALL, caller, addr, nil, gas, input, ErrDepth)
+		}
 		return nil, gas, ErrDepth
 	}
 
+	snapshot = evm.snapshot()
 	var (
-		snapshot       = evm.StateDB.Snapshot()
 		to             = AccountRef(caller.Address())
 		nextRevisionId = snapshot
 	)
@@ -394,66 +410,109 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	contract := NewContract(caller, to, nil, gas).AsDelegate()
 	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
 
-	if caller != nil && !isRelOracle(contract.Address()) {
-		/***
-		*record DelegateCall action.And create HackerContractCall object
-		*then push the object to the stack.
-		**/
-		hacker_init(evm, contract, input)
-		if hacker_call_stack == nil {
-			Println("call stack is nil")
-			return
-		}
-		call := hacker_call_stack.peek()
-		if call == nil {
-			Println("call is nil")
-			return
+	if hooks && caller != nil && !isRelOracle(contract.Address()) {
+		// record DelegateCall action: push a HackerContractCall on the hacker call stack
+		call = hackerOpenCall(DELEGATECALL, evm, caller, contract, nil, gas, input, snapshot)
+	}
+	ret, err = run(evm, snapshot, contract, input)
+	if err != nil {
+		evm.revertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
 		}
-		nextCall := call.OnDelegateCall(caller, contract.Address(), *new(big.Int).SetUint64(gas), input)
-		nextCall.snapshotId = snapshot
-		//nextCall.nextRevisionId = evm.StateDB.GetNextRevisonId()
-		if nextCall == nil {
-			Println("nextcall is nil")
-			return
+	}
+	// the action finished: pop the HackerContractCall and record its final state
+	hackerCloseCall(evm, call, nextRevisionId, err, contract.Gas)
+
+	return ret, contract.Gas, err
+}
+
+// StaticCall executes the contract associated with the addr with the given input as
+// parameters while disallowing any modifications to the state during the call.
+// Opcodes that attempt to perform such modifications will result in exceptions
+// instead of performing the modifications.
+func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
+	hooks := HooksEnabled()
+	snapshot := -1
+	var call *HackerContractCall
+	if hooks {
+		defer hackerRecoverFrame(STATICCALL, evm, &call, &snapshot, &ret, &leftOverGas, &err)
+	}
+
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+	// Fail if we're trying to execute above the call depth limit
+	if evm.depth > int(params.CallCreateDepth) {
+		if hooks {
+			hacker_refused_call(evm, STATICCALL, caller, addr, nil, gas, input, ErrDepth)
 		}
-		hacker_call_stack.push(nextCall)
-		Printf("\npush call@%p into stack", nextCall)
+		return nil, gas, ErrDepth
+	}
+	// Make sure the readonly is only set if we aren't in readonly yet
+	// this makes also sure that the readonly flag isn't removed for
+	// child calls.
+	if !evm.interpreter.readOnly {
+		evm.interpreter.readOnly = true
+		defer func() { evm.interpreter.readOnly = false }()
+	}
+
+	snapshot = evm.snapshot()
+	var (
+		to             = AccountRef(addr)
+		nextRevisionId = snapshot
+	)
+	// Initialise a new contract and set the code that is to be used by the
+	// EVM. The contract is a scoped environment for this execution context
+	// only.
+	contract := NewContract(caller, to, new(big.Int), gas)
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
+
+	if hooks && caller != nil && !isRelOracle(contract.Address()) {
+		// record StaticCall action: push a HackerContractCall on the hacker call stack
+		call = hackerOpenCall(STATICCALL, evm, caller, contract, nil, gas, input, snapshot)
 	}
+	// When an error was returned by the EVM or when setting the creation code
+	// above we revert to the snapshot and consume any gas remaining. Additionally
+	// when we're in Homestead this also counts for code storage gas errors.
 	ret, err = run(evm, snapshot, contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
-		evm.StateDB.RevertToSnapshot(snapshot)
-	}
-	if caller != nil && !isRelOracle(contract.Address()) {
-		Println("\nclose call...")
-		// subcriber.Close()
-		// subcriber.Write()
-		/**
-		*DeletegateCall action finish. So pop the object on top of the stack.
-		*and set object to "close" state, also record final related state.
-		**/
-		if hacker_call_stack != nil {
-			call := hacker_call_stack.pop()
-			call.nextRevisionId = nextRevisionId
-			if err != nil {
-				call.throwException = true
-			}
-			if call == nil {
-				Println("call is nil")
-				return
-			}
-			call.OnCloseCall(*new(big.Int).SetUint64(contract.Gas))
-			if hacker_call_stack.len() == 1 {
-				hacker_close()
+		if hooks {
+			for _, dog := range hackerDogs(evm) {
+				if dog.watches(evm) && dog.TurnOn() {
+					dog.ThrowError()
+				}
 			}
 		}
+		evm.revertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 	}
-
+	// the action finished: pop the HackerContractCall and record its final state
+	hackerCloseCall(evm, call, nextRevisionId, err, contract.Gas)
 	return ret, contract.Gas, err
 }
 
 // Create creates a new contract using code as deployment code.
 func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	nonce := evm.StateDB.GetNonce(caller.Address())
+	return evm.create(caller, code, gas, value, crypto.CreateAddress(caller.Address(), nonce))
+}
+
+// Create2 creates a new contract using code as deployment code, at an address derived
+// from the caller, salt and the hash of code instead of the caller's nonce (EIP-1014).
+func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, value *big.Int, salt *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	return evm.create(caller, code, gas, value, create2Address(caller.Address(), common.BigToHash(salt), code))
+}
+
+// create2Address returns keccak256(0xff ++ sender ++ salt ++ keccak256(code))[12:].
+func create2Address(sender common.Address, salt common.Hash, code []byte) common.Address {
+	return common.BytesToAddress(crypto.Keccak256([]byte{0xff}, sender.Bytes(), salt.Bytes(), crypto.Keccak256(code))[12:])
+}
+
+// create runs the deployment code at contractAddr.
+func (evm *EVM) create(caller ContractRef, code []byte, gas uint64, value *big.Int, contractAddr common.Address) ([]byte, common.Address, uint64, error) {
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, common.Address{}, gas, nil
 	}
@@ -468,11 +527,23 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	}
 
 	// Create a new account on the state
+	hooks := HooksEnabled()
+	if hooks {
+		hacker_nonce_bump(evm, caller.Address())
+	}
 	nonce := evm.StateDB.GetNonce(caller.Address())
 	evm.StateDB.SetNonce(caller.Address(), nonce+1)
 
-	snapshot := evm.StateDB.Snapshot()
-	contractAddr = crypto.CreateAddress(caller.Address(), nonce)
+	if hooks && evm.depth == 0 {
+		defer checkBalanceWatchpoints(evm, caller.Address(), contractAddr, nil, watchedBalances(evm.StateDB))
+	}
+	// the created account stays warm when the creation fails (EIP-2929)
+	evm.prepareAccessList(contractAddr)
+	evm.accessList.addAddress(contractAddr)
+	snapshot := evm.snapshot()
+	if hooks {
+		hacker_account_created(evm, contractAddr)
+	}
 	evm.StateDB.CreateAccount(contractAddr)
 	if evm.ChainConfig().IsEIP158(evm.BlockNumber) {
 		evm.StateDB.SetNonce(contractAddr, 1)
@@ -485,7 +556,7 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	contract := NewContract(caller, AccountRef(contractAddr), value, gas)
 	contract.SetCallCode(&contractAddr, crypto.Keccak256Hash(code), code)
 
-	ret, err = run(evm, snapshot, contract, nil)
+	ret, err := run(evm, snapshot, contract, nil)
 	// check whether the max code size has been exceeded
 	maxCodeSizeExceeded := len(ret) > params.MaxCodeSize
 	// if the contract creation ran successfully and no errors were returned
@@ -504,21 +575,80 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	// When an error was returned by the EVM or when setting the creation code
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in homestead this also counts for code storage gas errors.
+	// A REVERT keeps the gas left and its return data.
 	if maxCodeSizeExceeded ||
 		(err != nil && (evm.ChainConfig().IsHomestead(evm.BlockNumber) || err != ErrCodeStoreOutOfGas)) {
-		contract.UseGas(contract.Gas)
-		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.revertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	if maxCodeSizeExceeded && err == nil {
+		err = ErrMaxCodeSizeExceeded
 	}
 	// If the vm returned with an error the return value should be set to nil.
 	// This isn't consensus critical but merely to for behaviour reasons such as
 	// tests, RPC calls, etc.
-	if err != nil {
+	if err != nil && err != ErrExecutionReverted {
 		ret = nil
 	}
 
 	return ret, contractAddr, contract.Gas, err
 }
 
+// snapshot takes a snapshot of the state and of the access list.
+func (evm *EVM) snapshot() int {
+	id := evm.StateDB.Snapshot()
+	evm.accessList.snapshot(id)
+	return id
+}
+
+// revertToSnapshot reverts the state and the access list to the snapshot id.
+func (evm *EVM) revertToSnapshot(id int) {
+	evm.StateDB.RevertToSnapshot(id)
+	evm.accessList.revertToSnapshot(id)
+}
+
+// prepareAccessList warms the accounts every transaction accesses (EIP-2929): the
+// origin, the destination of the message call or the created contract, and the
+// precompiled contracts.
+func (evm *EVM) prepareAccessList(dst common.Address) {
+	if evm.depth > 0 {
+		return
+	}
+	evm.accessList.addAddress(evm.Origin)
+	evm.accessList.addAddress(dst)
+	for addr := range evm.precompiles() {
+		evm.accessList.addAddress(addr)
+	}
+}
+
+// precompiles returns the precompiled contracts under the rules of the current block.
+func (evm *EVM) precompiles() map[common.Address]PrecompiledContract {
+	switch {
+	case evm.forkRules.IsIstanbul:
+		return PrecompiledContractsIstanbul
+	case evm.chainRules.IsByzantium:
+		return PrecompiledContractsByzantium
+	default:
+		return PrecompiledContracts
+	}
+}
+
+// precompile returns the precompiled contract at addr under the rules of the current
+// block, nil if there is none.
+func (evm *EVM) precompile(addr common.Address) PrecompiledContract {
+	return evm.precompiles()[addr]
+}
+
+// ChainID returns the chain id reported by CHAINID.
+func (evm *EVM) ChainID() *big.Int {
+	if evm.chainID != nil {
+		return evm.chainID
+	}
+	return evm.chainConfig.ChainId
+}
+
 // ChainConfig returns the evmironment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
 
@@ -527,23 +657,124 @@ func (evm *EVM) Interpreter() *Interpreter { return evm.interpreter }
 
 var handleSet map[string]bool = make(map[string]bool)
 
+// ForgetTransactions lets the WatchDogs watch the transactions already seen again, for
+// executions replayed from a fresh state.
+func ForgetTransactions() {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+	handleSet = make(map[string]bool)
+}
+
 type WatchDog struct {
-	trace       []string
+	trace  *hackerTraceRing
+	writes *hackerStorageRing
+	logs   []HackerLogRecord
+	// console holds the console.log calls (hacker_console.go)
+	console []HackerConsoleLog
+	// accounts is the account diff of the watched transaction
+	accounts    *hackerAccounts
 	storage_new map[common.Hash]common.Hash
 	storage_old map[common.Hash]common.Hash
 	balance_old big.Int
 	balance_new big.Int
 	env         *EVM
 	tx          *types.Transaction
-	turnOn      bool
-	hasThrow    bool
+	// turnOn is 1 while a transaction is watched, it is read and written atomically
+	turnOn   int32
+	hasThrow bool
+	// storageLoaded is set once the initial slots of the watched contract are read
+	storageLoaded bool
+	findings      []HackerFinding
+	// confirmEnv runs on a copy of the state before the watched transaction, the
+	// findings are confirmed on it at the end (hacker_confirm.go)
+	confirmEnv *EVM
+	taint      *HackerTaint
+	competitor *common.Address
+	frontrun   *HackerFrontRunReport
+	// pluginView is the call tree checked by the oracle plugins (hacker_plugin.go)
+	pluginView *hackerPluginView
+	// differential compares the versions of the code of the target (hacker_differential.go)
+	differential *HackerDifferentialReport
+	// griefing replays the calls of the target to gas burning callees (hacker_griefing.go)
+	griefing *HackerGriefingReport
+	// revertBomb replays them to reverting callees (hacker_revertbomb.go)
+	revertBomb *HackerGriefingReport
+	// returnPoisoning replays them to callees returning malformed data (hacker_returndata.go)
+	returnPoisoning *HackerGriefingReport
+	// timeSkew replays the transaction at later timestamps (hacker_timeskew.go)
+	timeSkew *HackerTimeSkewReport
+	// feeSkew replays it at other gas prices and base fees (hacker_feeskew.go)
+	feeSkew *HackerFeeSkewReport
+	// proxy is set when the watched transaction goes to a proxy
+	proxy *HackerProxy
+	// typed is set when the watched transaction is a typed one (WatchTyped)
+	typed *HackerTypedTx
+	// stepBudget caps the operations of the watched transactions when set
+	stepBudget uint64
+	// progressEvery is the number of operations between two progress reports when set,
+	// executed counts the operations of the watched transaction
+	progressEvery uint64
+	executed      uint64
+	// block is the block between OnBlockStart and OnBlockEnd
+	block *hackerBlock
+	// selector is the selector of the watched transaction with its statistics
+	selector *HackerSelectorStat
+	// edges are the branch edges covered by the watched transaction, coverage their
+	// count once merged into the campaign
+	edges    map[hackerCoverageEdge]struct{}
+	coverage *HackerCoverageStat
+	// annotated are the annotations of the codes run by the watched transaction, at the
+	// instruction running (hacker_disasm.go)
+	annotated map[common.Hash]*hackerCodeAnnotations
+	at        hackerPcKey
+	// detectors are the detectors scheduled for the watched transaction (hacker_schedule.go)
+	detectors *hackerDetectorRun
+	// sampler samples the trace by class of operation, nil when it is kept whole
+	// (hacker_tracesampling.go)
+	sampler *hackerTraceSampler
+	// reduced holds the targets instrumented calls-only when the transaction was watched
+	reduced map[common.Address]bool
+	// blocked summarises the frames of the blocked contracts
+	blocked map[common.Address]*HackerBlockedContract
+	// callsOnly is set when the policy (hacker_policy.go) decided to record the calls only
+	callsOnly bool
+	// hits are the snapshots taken at the breakpoints (hacker_debugger.go)
+	hits []HackerMachineState
+	// senderOld and senderNew are the balances of the sender around the message call
+	// (hacker_profit.go)
+	senderOld *big.Int
+	senderNew *big.Int
+	tokensOld map[common.Address]*big.Int
+	tokensNew map[common.Address]*big.Int
+	// started is set once the message call of the watched transaction began
+	started bool
+	// parent is the WatchDog this one was forked from, forks the forks of this one by
+	// the hash of their transaction (hacker_multiwatch.go)
+	parent *WatchDog
+	forks  map[common.Hash]*WatchDog
+	// call is set when the transaction wraps an instrumented harness call
+	// (hacker_callreport.go)
+	call bool
+	// bundle has a step per message of the bundle watched (hacker_bundle.go)
+	bundle []HackerBundleStep
+	// revert records the state changed for the revert-state inspection (hacker_revert.go)
+	revert *hackerRevertCapture
+	// constraint checks the must-hold constraints (hacker_constraint.go)
+	constraint *hackerConstraintCheck
+	// properties failed after the watched transaction (hacker_property.go)
+	properties []hackerPropertyFailure
+	// lock guards what is read by other goroutines than the one executing the watched
+	// transaction (see hacker_trace.go)
+	lock sync.Mutex
+	// armed is the EVM of the watched transaction, nil when none is watched
+	armed atomic.Value
 }
 
 var wdog *WatchDog = nil
 var tracerdog *WatchDog = nil
 
 func newWatchDog() *WatchDog {
-	return &WatchDog{trace: nil, storage_new: nil, storage_old: nil, env: nil, tx: nil, turnOn: false, balance_new: *new(big.Int), balance_old: *new(big.Int), hasThrow: false}
+	return &WatchDog{trace: nil, storage_new: nil, storage_old: nil, env: nil, tx: nil, balance_new: *new(big.Int), balance_old: *new(big.Int), hasThrow: false}
 }
 func GetGlobalWatchDog() *WatchDog {
 	if wdog == nil {
@@ -558,128 +789,299 @@ func GetGlobalTracerWatchDog() *WatchDog {
 	return tracerdog
 }
 func (dog *WatchDog) Watch(env *EVM, tx *types.Transaction) {
-	if tx != nil && tx.To() != nil {
-		if _, ok := handleSet[tx.Hash().Hex()]; !ok {
-			handleSet[tx.Hash().Hex()] = true
+	if tx != nil && tx.To() != nil && HooksEnabled() {
+		decision := GetGlobalCampaign().decide(env.Origin, tx)
+		if decision == HackerPolicySkip {
+			return
+		}
+		if dog.busyWith(env) {
+			dog.watchAside(env, tx)
+			return
+		}
+		if dog.watching() && dog.tx != tx {
+			// the previous transaction of env never ended
+			dog.setTurnOn(false)
+			dog.Start()
+		}
+		if markWatched(tx.Hash()) {
+			if budget := atomic.LoadUint64(&dog.stepBudget); budget != 0 {
+				env.SetStepLimit(budget)
+			}
+			// the probes run on a copy of the state, never on the state of the block,
+			// copied only when one of them is armed
+			var probe *EVM
+			if dog.probing(*tx.To()) {
+				probe = hackerProbeEnv(env)
+			}
+			dog.frontRun(probe, tx)
+			dog.runDifferential(probe, tx)
+			dog.probeGriefing(probe, tx)
+			dog.probeRevertBomb(probe, tx)
+			dog.probeReturnPoisoning(probe, tx)
+			dog.skewTime(probe, tx)
+			dog.skewFees(probe, tx)
+			dog.confirmEnv = hackerProbeEnv(env)
+			if dog.confirmEnv != nil {
+				dog.proxy = ResolveProxy(dog.confirmEnv, *tx.To())
+			}
+			if dog.block != nil {
+				dog.block.tod.add(env, tx)
+			}
+			GetGlobalCampaign().RegisterTarget(*tx.To(), env.StateDB.GetCode(*tx.To()))
+			dog.lock.Lock()
 			dog.env = env
 			dog.tx = tx
-			dog.turnOn = true
+			atomic.StoreInt32(&dog.turnOn, 1)
+			dog.started = false
+			dog.callsOnly = decision == HackerPolicyCalls
+			dog.lock.Unlock()
+			dog.arm(env)
+			dog.watchAccounts()
+			dog.reduced = GetGlobalCampaign().reducedTargets()
+			dog.blocked = GetGlobalCampaign().blockedContracts()
+			dog.detectors = GetGlobalCampaign().scheduleDetectors()
+			dog.sampler = GetGlobalCampaign().traceSampler()
+			dog.executed = 0
+			dog.senderOld, dog.senderNew = nil, nil
+			dog.tokensOld, dog.tokensNew = nil, nil
+			// taken again when the execution starts
 			dog.balance_old = *(env.StateDB.GetBalance(*(dog.tx.To())))
-			log.Printf("balance before tx : %s", dog.balance_old.Text(10))
 		}
 	}
 }
 func (dog *WatchDog) TurnOn() bool {
-	return dog.turnOn
+	return dog.watching()
 }
 func (dog *WatchDog) ThrowError() {
-	if true == dog.turnOn {
+	if dog.watching() {
+		dog.lock.Lock()
 		dog.hasThrow = true
+		dog.lock.Unlock()
 	}
 }
 func (dog *WatchDog) GetEnv() *EVM {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
 	return dog.env
 }
 func (dog *WatchDog) GetTx() *types.Transaction {
 	return dog.tx
 }
 func (dog *WatchDog) Start() {
+	if dog.watchedEnv() != nil {
+		// the transaction being watched goes on, the next one is watched by a fork
+		return
+	}
+	dog.lock.Lock()
 	dog.hasThrow = false
-	dog.turnOn = false
-	dog.trace = make([]string, 0, 0)
-	dog.storage_old = make(map[common.Hash]common.Hash)
-	dog.storage_new = make(map[common.Hash]common.Hash)
+	atomic.StoreInt32(&dog.turnOn, 0)
+	dog.findings = make([]HackerFinding, 0)
+	dog.lock.Unlock()
+	dog.arm(nil)
+	dog.resetRecording()
+	dog.taint = newHackerTaint()
 }
-func (dog *WatchDog) Write2Trace(code_desc string) {
-	if dog.turnOn == true {
-		dog.trace = append(dog.trace, code_desc)
+func (dog *WatchDog) Write2Trace(pc uint64, op OpCode, frame int) {
+	if dog.watching() {
+		dog.lock.Lock()
+		if dog.sampler != nil && !dog.sampler.keep(op) {
+			dog.trace.skipped++
+		} else {
+			dog.trace.push(pc, op, frame)
+		}
+		dog.lock.Unlock()
 	}
 }
 
-var mutex = &sync.Mutex{}
-
-func (dog *WatchDog) Write2Storage(location, value common.Hash) {
-	if dog.turnOn == true {
-		mutex.Lock()
-		if _, ok := dog.storage_old[location]; !ok {
-			dog.storage_old[location] = value
-		} else if dog.storage_old[location] != value {
-			dog.storage_new[location] = value
-		}
-		mutex.Unlock()
+func (dog *WatchDog) Write2Storage(frame int, location, value common.Hash) {
+	if dog.watching() {
+		dog.lock.Lock()
+		dog.writes.push(frame, location, value)
+		dog.lock.Unlock()
 	}
 }
 
 func (dog *WatchDog) End(receipt *types.Receipt) {
-	if dog.turnOn == true {
-		dog.balance_new = *(dog.env.StateDB.GetBalance(*(dog.tx.To())))
-		log.Printf("balance after tx : %s", dog.balance_new.Text(10))
-		if len(dog.trace) != 0 {
-			json_map := make(map[string]interface{})
-			json_map["trace"] = dog.trace
-			json_map["hash"] = receipt.TxHash.String()
-			log.Printf("WatchDog report execution trace and storage context to fuzzer for tx@%s", json_map["hash"])
-			json_map["storage_new"] = dog.storage_new
-			json_map["storage_old"] = dog.storage_old
-			json_map["balance_new"] = dog.balance_new.Text(10)
-			json_map["balance_old"] = dog.balance_old.Text(10)
-			json_map["receipt"] = *receipt
-			json_map["hasThrow"] = dog.hasThrow
-			json_str, err := json.Marshal(json_map)
-			if err != nil {
-				fmt.Println("json error!")
-				return
-			}
+	dog.finish(receipt, nil)
+}
 
-			req, err := http.Post("http://localhost:3000/fuzz",
-				"application/json",
-				bytes.NewBuffer(json_str))
+// EndTracer ends the watched transaction like End, its report carries the result of
+// the tracer.
+func (dog *WatchDog) EndTracer(receipt *types.Receipt, tracer_result interface{}) {
+	dog.finish(receipt, map[string]interface{}{"tracer": tracer_result})
+}
 
-			if err != nil {
-				fmt.Print("Post Error!\n")
-				// panic(err)
-			} else {
-				// fmt.Print("successfully!\n")
-				defer req.Body.Close()
-			}
-		}
+// finish runs the oracles of the watched transaction of receipt, sends its report
+// with the extra fields and resets the WatchDog for the next transaction.
+func (dog *WatchDog) finish(receipt *types.Receipt, extra map[string]interface{}) {
+	if fork := dog.forkOf(receipt.TxHash); fork != nil {
+		fork.finish(receipt, extra)
+		dog.unfork(fork)
+		return
 	}
-	dog.turnOn = false
-}
-func (dog *WatchDog) EndTracer(receipt *types.Receipt, tracer_result interface{}) {
-	if dog.turnOn == true {
+	if dog.watching() {
 		dog.balance_new = *(dog.env.StateDB.GetBalance(*(dog.tx.To())))
 		log.Printf("balance after tx : %s", dog.balance_new.Text(10))
-		if len(dog.trace) != 0 {
+		if receipt.GasUsed != nil {
+			dog.recordGas(receipt.GasUsed.Uint64())
+		}
+		dog.checkSignatureReplay()
+		dog.checkTokens()
+		dog.checkProfit(receipt.GasUsed)
+		dog.checkViolations()
+		dog.checkPropertyFailures()
+		dog.checkGriefing()
+		dog.checkRevertBomb()
+		dog.checkReturnPoisoning()
+		dog.checkRefundAbuse()
+		dog.checkPlugins()
+		dog.recordCallGraph()
+		dog.recordSelector()
+		dog.recordCoverage()
+		dog.recordAnnotations()
+		dog.recordDetectors()
+		dog.confirmFindings()
+		dog.labelProxy()
+		reported := dog.reportable()
+		if reported {
 			json_map := make(map[string]interface{})
-			json_map["trace"] = dog.trace
+			json_map["trace"] = dog.trace.strings()
+			json_map["traceFrames"] = dog.trace.frameIDs()
+			if dog.trace.dropped != 0 {
+				json_map["traceDropped"] = dog.trace.dropped
+			}
+			if skipped := dog.traceSkipped(); skipped != nil {
+				json_map["traceSkipped"] = skipped
+			}
 			json_map["hash"] = receipt.TxHash.String()
 			log.Printf("WatchDog report execution trace and storage context to fuzzer for tx@%s", json_map["hash"])
-			json_map["storage_new"] = dog.storage_new
-			json_map["storage_old"] = dog.storage_old
+			json_map["storageWrites"] = dog.storageWrites()
+			json_map["storage_old"], json_map["storage_new"] = dog.storage()
+			json_map["logs"] = dog.logRecords()
+			if console := dog.consoleLogs(); len(console) != 0 {
+				json_map["console"] = console
+			}
 			json_map["balance_new"] = dog.balance_new.Text(10)
 			json_map["balance_old"] = dog.balance_old.Text(10)
-			json_map["tracer"] = tracer_result
+			json_map["accounts"] = dog.accountDiff()
 			json_map["receipt"] = *receipt
 			json_map["hasThrow"] = dog.hasThrow
-			json_str, err := json.Marshal(json_map)
-			if err != nil {
-				fmt.Println("json error!")
-				return
+			json_map["findings"] = dog.findings
+			if dog.env.seed != nil {
+				json_map["seed"] = dog.env.seed.Hex()
 			}
-
-			req, err := http.Post("http://localhost:3000/fuzz",
-				"application/json",
-				bytes.NewBuffer(json_str))
-
-			if err != nil {
-				fmt.Print("Post Error!\n")
-				// panic(err)
-			} else {
-				// fmt.Print("successfully!\n")
-				defer req.Body.Close()
+			json_map["env"] = dog.reportEnv()
+			if dog.frontrun != nil {
+				json_map["frontRunning"] = dog.frontrun
+			}
+			if dog.differential != nil {
+				json_map["differential"] = dog.differential
+			}
+			if dog.griefing != nil {
+				json_map["griefing"] = dog.griefing
+			}
+			if dog.revertBomb != nil {
+				json_map["revertBomb"] = dog.revertBomb
+			}
+			if dog.returnPoisoning != nil {
+				json_map["returnPoisoning"] = dog.returnPoisoning
+			}
+			if dog.timeSkew != nil {
+				json_map["timeSkew"] = dog.timeSkew
 			}
+			if dog.feeSkew != nil {
+				json_map["feeSkew"] = dog.feeSkew
+			}
+			if detectors := dog.activeDetectors(); detectors != nil {
+				json_map["detectors"] = detectors
+			}
+			if dog.proxy != nil {
+				json_map["proxy"] = dog.proxy
+			}
+			if fees := dog.fees(); fees != nil {
+				json_map["fees"] = fees
+			}
+			if typed := dog.typedTx(); typed != nil {
+				json_map["tx"] = typed
+			}
+			if steps := dog.steps(); steps != nil {
+				json_map["steps"] = steps
+			}
+			if dog.selector != nil {
+				json_map["selector"] = dog.selector
+			}
+			if dog.coverage != nil {
+				json_map["coverage"] = dog.coverage
+			}
+			if blocked := dog.blockedSummary(); blocked != nil {
+				json_map["blocked"] = blocked
+			}
+			if dog.hits != nil {
+				json_map["breakpoints"] = dog.hits
+			}
+			if dog.taint != nil {
+				json_map["frames"] = dog.taint.journal.report()
+				if chains := dog.taint.journal.forwardChains(); len(chains) > 0 {
+					json_map["forwarding"] = chains
+				}
+			}
+			json_map["gas"] = dog.gasBreakdown()
+			if refunds := dog.refundReport(); refunds != nil {
+				json_map["refunds"] = refunds
+			}
+			if dog.bundle != nil {
+				json_map["bundle"] = dog.bundle
+			}
+			if revert := dog.revertState(); revert != nil {
+				json_map["revertState"] = revert
+			}
+			for key, value := range extra {
+				json_map[key] = value
+			}
+			if labels := reportLabels(json_map); labels != nil {
+				json_map["labels"] = labels
+			}
+			dog.sendReport(json_map)
 		}
+		GetGlobalCampaign().addFindings(dog.tx, dog.findings)
+		dog.countBlock(reported)
+		stopStepping(dog.env)
 	}
-	dog.turnOn = false
+	dog.resetTransaction()
+}
+
+// resetTransaction clears the state of the watched transaction once its report is
+// sent. Its trace and findings stay readable until the next Start.
+func (dog *WatchDog) resetTransaction() {
+	dog.lock.Lock()
+	atomic.StoreInt32(&dog.turnOn, 0)
+	dog.started = false
+	dog.callsOnly = false
+	dog.lock.Unlock()
+	dog.arm(nil)
+	dog.proxy = nil
+	dog.typed = nil
+	dog.selector = nil
+	dog.accounts = nil
+	dog.coverage = nil
+	dog.reduced = nil
+	dog.blocked = nil
+	dog.revert = nil
+	dog.constraint = nil
+	dog.properties = nil
+	dog.hits = nil
+	dog.bundle = nil
+	dog.frontrun = nil
+	dog.pluginView = nil
+	dog.differential = nil
+	dog.griefing = nil
+	dog.revertBomb = nil
+	dog.returnPoisoning = nil
+	dog.timeSkew = nil
+	dog.feeSkew = nil
+	dog.detectors = nil
+	dog.sampler = nil
+	dog.executed = 0
+	dog.senderOld, dog.senderNew = nil, nil
+	dog.tokensOld, dog.tokensNew = nil, nil
 }
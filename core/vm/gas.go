@@ -33,6 +33,42 @@ const (
 	GasReturn       uint64 = 0
 	GasStop         uint64 = 0
 	GasContractByte uint64 = 200
+
+	// GasExtcodeHash is the price of EXTCODEHASH (EIP-1052), the gas tables of the
+	// chain config predate it.
+	GasExtcodeHash uint64 = 400
+
+	// Access costs of EIP-2929 and the net gas metering of EIP-2200, Berlin and
+	// Istanbul are not in the gas tables either.
+	ColdAccountAccessCostEIP2929      uint64 = 2600
+	ColdSloadCostEIP2929              uint64 = 2100
+	WarmStorageReadCostEIP2929        uint64 = 100
+	SstoreSentryGasEIP2200            uint64 = 2300
+	SstoreClearsScheduleRefundEIP2200 uint64 = 15000
+	// SstoreClearsScheduleRefundEIP3529 is the refund for clearing a slot in London:
+	// SSTORE_RESET_GAS - COLD_SLOAD_COST + ACCESS_LIST_STORAGE_KEY_COST.
+	SstoreClearsScheduleRefundEIP3529 uint64 = 5000 - 2100 + 1900
+
+	// Intrinsic gas of the non-zero calldata bytes after Istanbul (EIP-2028) and of the
+	// access lists (EIP-2930), the refund caps of the transactions before and after
+	// London (EIP-3529).
+	TxDataNonZeroGasEIP2028   uint64 = 16
+	TxAccessListAddressGas    uint64 = 2400
+	TxAccessListStorageKeyGas uint64 = 1900
+	RefundQuotient            uint64 = 2
+	RefundQuotientEIP3529     uint64 = 5
+
+	// Prices of Istanbul: the storage and account reads repriced by EIP-1884, the
+	// SSTORE of a slot left as is (EIP-2200) and the bn256 precompiled contracts
+	// (EIP-1108).
+	SloadGasEIP1884                 uint64 = 800
+	BalanceGasEIP1884               uint64 = 700
+	ExtcodeHashGasEIP1884           uint64 = 700
+	SloadGasEIP2200                 uint64 = 800
+	Bn256AddGasIstanbul             uint64 = 150
+	Bn256ScalarMulGasIstanbul       uint64 = 6000
+	Bn256PairingBaseGasIstanbul     uint64 = 45000
+	Bn256PairingPerPointGasIstanbul uint64 = 34000
 )
 
 // calcGas returns the actual gas cost of the call.
@@ -0,0 +1,160 @@
+/**
+* @hacker_abi.go
+* ABI registry of the fuzzing targets.
+* The fuzzer registers the function signatures of a target once (e.g. "transfer(address,uint256)"),
+* oracles then look up the selector of a frame to know the function name and the
+* minimal calldata size its arguments need.
+ */
+package vm
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// HackerABIMethod is one registered function of a target.
+type HackerABIMethod struct {
+	Signature string   `json:"signature"`
+	Selector  [4]byte  `json:"selector"`
+	Inputs    []string `json:"inputs"`
+	// ArgSize is the minimal number of calldata bytes after the selector.
+	ArgSize int `json:"argSize"`
+}
+
+// HackerABIRegistry maps target addresses to their registered methods.
+// Methods registered for the zero address apply to every target.
+type HackerABIRegistry struct {
+	lock    sync.RWMutex
+	methods map[common.Address]map[[4]byte]*HackerABIMethod
+}
+
+var _abiRegistry *HackerABIRegistry = nil
+
+func GetABIRegistry() *HackerABIRegistry {
+	if _abiRegistry == nil {
+		_abiRegistry = &HackerABIRegistry{methods: make(map[common.Address]map[[4]byte]*HackerABIMethod)}
+	}
+	return _abiRegistry
+}
+
+// Register parses a canonical function signature and adds it for addr.
+func (registry *HackerABIRegistry) Register(addr common.Address, signature string) *HackerABIMethod {
+	signature = strings.Replace(signature, " ", "", -1)
+	method := &HackerABIMethod{Signature: signature, Inputs: splitABIArguments(signature)}
+	copy(method.Selector[:], crypto.Keccak256([]byte(signature))[:4])
+	for _, input := range method.Inputs {
+		method.ArgSize += abiHeadSize(input)
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.methods[addr]; !ok {
+		registry.methods[addr] = make(map[[4]byte]*HackerABIMethod)
+	}
+	registry.methods[addr][method.Selector] = method
+	return method
+}
+
+// Lookup returns the method of addr matching the selector at the head of input, looking
+// at the implementation of addr too when it is a resolved proxy.
+func (registry *HackerABIRegistry) Lookup(addr common.Address, input []byte) (*HackerABIMethod, bool) {
+	if len(input) < 4 {
+		return nil, false
+	}
+	var selector [4]byte
+	copy(selector[:], input[:4])
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	if method, ok := registry.methods[addr][selector]; ok {
+		return method, true
+	}
+	if implementation, ok := implementationOf(addr); ok {
+		if method, ok := registry.methods[implementation][selector]; ok {
+			return method, true
+		}
+	}
+	method, ok := registry.methods[common.Address{}][selector]
+	return method, ok
+}
+
+// Methods returns all methods registered for addr.
+func (registry *HackerABIRegistry) Methods(addr common.Address) []*HackerABIMethod {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	methods := make([]*HackerABIMethod, 0, len(registry.methods[addr]))
+	for _, method := range registry.methods[addr] {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// splitABIArguments returns the top level argument types of a signature.
+func splitABIArguments(signature string) []string {
+	open := strings.Index(signature, "(")
+	if open < 0 || !strings.HasSuffix(signature, ")") {
+		return nil
+	}
+	body := signature[open+1 : len(signature)-1]
+	args := make([]string, 0)
+	if body == "" {
+		return args
+	}
+	depth, start := 0, 0
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, body[start:])
+}
+
+// abiIsDynamic reports whether an argument of the given type is encoded out of line.
+func abiIsDynamic(typ string) bool {
+	if typ == "bytes" || typ == "string" || strings.HasSuffix(typ, "[]") {
+		return true
+	}
+	if end := strings.LastIndex(typ, "["); end > 0 && strings.HasSuffix(typ, "]") {
+		return abiIsDynamic(typ[:end])
+	}
+	if strings.HasPrefix(typ, "(") {
+		for _, elem := range splitABIArguments("tuple" + typ) {
+			if abiIsDynamic(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// abiHeadSize returns the number of head bytes an argument of the given type takes.
+func abiHeadSize(typ string) int {
+	if abiIsDynamic(typ) {
+		return 32
+	}
+	if end := strings.LastIndex(typ, "["); end > 0 && strings.HasSuffix(typ, "]") {
+		n, err := strconv.Atoi(typ[end+1 : len(typ)-1])
+		if err != nil {
+			return 32
+		}
+		return n * abiHeadSize(typ[:end])
+	}
+	if strings.HasPrefix(typ, "(") {
+		size := 0
+		for _, elem := range splitABIArguments("tuple" + typ) {
+			size += abiHeadSize(elem)
+		}
+		return size
+	}
+	return 32
+}
@@ -0,0 +1,27 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestABIArgSize(t *testing.T) {
+	tests := []struct {
+		signature string
+		size      int
+	}{
+		{"totalSupply()", 0},
+		{"transfer(address,uint256)", 64},
+		{"setName(string)", 32},
+		{"fill(uint256[3],bytes32)", 128},
+		{"batch((address,uint256)[2],bool)", 160},
+		{"submit((address,bytes),uint8)", 64},
+	}
+	registry := &HackerABIRegistry{methods: make(map[common.Address]map[[4]byte]*HackerABIMethod)}
+	for _, test := range tests {
+		if method := registry.Register(common.Address{}, test.signature); method.ArgSize != test.size {
+			t.Errorf("%s: arg size mismatch, have %d want %d", test.signature, method.ArgSize, test.size)
+		}
+	}
+}
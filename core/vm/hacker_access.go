@@ -0,0 +1,68 @@
+/**
+* @hacker_access.go
+* Oracle: cold access griefing.
+* Under the Berlin gas schedule (EIP-2929) the first access of an account or a storage
+* slot in a transaction is cold and costs 2100 to 2600 gas, the next ones are warm and
+* cost 100. The gas functions report every access to the frame of the journal running
+* it, the counts are sent with the frames.
+* A live frame making hackerColdAccessThreshold cold accesses or more is reported as a
+* "cold_access_griefing" finding: the gas of such a function grows with data that the
+* callers may control (holders, queued entries), a gas-griefing candidate.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"strconv"
+)
+
+// hackerColdAccessThreshold is the number of cold accesses of a frame reported.
+const hackerColdAccessThreshold = 32
+
+// hacker_access records an access of contract, cold when it is the first one of the
+// transaction.
+func hacker_access(evm *EVM, contract *Contract, cold bool) {
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() && dog.taint != nil {
+			dog.taint.journal.access(contract, cold)
+		}
+	}
+}
+
+// access counts an access of the current frame. The gas of an operation is charged
+// before it is recorded, but an access is never the first operation of a frame: the
+// current frame is the one of contract.
+func (journal *hackerFrameJournal) access(contract *Contract, cold bool) {
+	top := journal.top()
+	if top < 0 || journal.frames[top].contract != contract {
+		return
+	}
+	if cold {
+		journal.frames[top].cold++
+	} else {
+		journal.frames[top].warm++
+	}
+}
+
+// checkColdAccess reports the live frames with hackerColdAccessThreshold cold accesses
+// or more.
+func (taint *HackerTaint) checkColdAccess() []*HackerFinding {
+	live := taint.journal.live()
+	findings := make([]*HackerFinding, 0)
+	for i := range taint.journal.frames {
+		frame := &taint.journal.frames[i]
+		if !live[i] || frame.cold < hackerColdAccessThreshold {
+			continue
+		}
+		finding := newHackerFinding("cold_access_griefing", "HackerColdAccess")
+		finding.Detail["contract"] = frame.Address().Hex()
+		finding.Detail["frame"] = strconv.Itoa(i)
+		if selector := hackerSelector(frame.Input()); len(frame.Input()) >= 4 {
+			finding.Detail["selector"] = "0x" + hex.EncodeToString(selector[:])
+		}
+		finding.Detail["cold"] = strconv.Itoa(frame.cold)
+		finding.Detail["warm"] = strconv.Itoa(frame.warm)
+		findings = append(findings, finding)
+	}
+	return findings
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAccessListRevert(t *testing.T) {
+	var (
+		list = newAccessList()
+		a, b = common.HexToAddress("0x0a"), common.HexToAddress("0x0b")
+		slot = common.HexToHash("0x01")
+	)
+	if !list.addAddress(a) || list.addAddress(a) {
+		t.Fatal("first access of a not cold or second one not warm")
+	}
+	list.snapshot(1)
+	if addressCold, slotCold := list.addSlot(b, slot); !addressCold || !slotCold {
+		t.Fatal("slot of b not cold")
+	}
+	if addressCold, slotCold := list.addSlot(a, slot); addressCold || !slotCold {
+		t.Fatal("slot of a not cold")
+	}
+	list.revertToSnapshot(1)
+	if list.containsAddress(b) {
+		t.Error("b accessed in a reverted frame is still warm")
+	}
+	if addressOk, slotOk := list.contains(a, slot); !addressOk || slotOk {
+		t.Errorf("a warm %v, slot warm %v after the revert, want true, false", addressOk, slotOk)
+	}
+	original := list.originalState(a, slot, common.HexToHash("0x02"))
+	if got := list.originalState(a, slot, common.HexToHash("0x03")); got != original {
+		t.Errorf("original value %x changed to %x", original, got)
+	}
+}
+
+func TestColdAccessOracle(t *testing.T) {
+	taint := newHackerTaint()
+	frame := enterTestFrame(taint, common.HexToAddress("0x0a"), common.HexToAddress("0x0b"), []byte{0x12, 0x34, 0x56, 0x78})
+	contract := taint.journal.frames[frame].contract
+	for i := 0; i < hackerColdAccessThreshold-1; i++ {
+		taint.journal.access(contract, true)
+	}
+	taint.journal.access(contract, false)
+	if findings := taint.checkColdAccess(); len(findings) != 0 {
+		t.Fatalf("reported below the threshold: %v", findings)
+	}
+	taint.journal.access(contract, true)
+	findings := taint.checkColdAccess()
+	if len(findings) != 1 || findings[0].Detail["selector"] != "0x12345678" || findings[0].Detail["cold"] != "32" || findings[0].Detail["warm"] != "1" {
+		t.Fatalf("unexpected findings %+v", findings)
+	}
+}
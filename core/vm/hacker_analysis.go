@@ -0,0 +1,100 @@
+/**
+* @hacker_analysis.go
+* Code analysis shared across transactions.
+* The interpreter analyses JUMPDESTs once per contract object, so every call of a fuzzed
+* target paid for it again. The analysis (jump destinations and the branch table used to
+* number coverage edges) is kept per code hash in a process wide LRU instead.
+ */
+package vm
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerAnalysisCacheSize is the number of codes whose analysis is kept.
+const hackerAnalysisCacheSize = 1024
+
+// hackerCodeAnalysis is the analysis of one code.
+type hackerCodeAnalysis struct {
+	jumpdests []byte
+	// branches numbers the JUMP and JUMPI instructions of the code by pc,
+	// the coverage edges of a branch are 2*index (fall through) and 2*index+1 (taken).
+	branches map[uint64]uint32
+}
+
+func analyseCode(code []byte) *hackerCodeAnalysis {
+	analysis := &hackerCodeAnalysis{jumpdests: jumpdests(code), branches: make(map[uint64]uint32)}
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		if op == JUMP || op == JUMPI {
+			analysis.branches[pc] = uint32(len(analysis.branches))
+		} else if op >= PUSH1 && op <= PUSH32 {
+			pc += uint64(op) - uint64(PUSH1) + 1
+		}
+	}
+	return analysis
+}
+
+type hackerAnalysisEntry struct {
+	codehash common.Hash
+	analysis *hackerCodeAnalysis
+}
+
+// hackerAnalysisCache is an LRU of code analyses keyed by code hash.
+type hackerAnalysisCache struct {
+	lock    sync.Mutex
+	size    int
+	order   *list.List
+	entries map[common.Hash]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+func newHackerAnalysisCache(size int) *hackerAnalysisCache {
+	return &hackerAnalysisCache{size: size, order: list.New(), entries: make(map[common.Hash]*list.Element)}
+}
+
+var analysisCache = newHackerAnalysisCache(hackerAnalysisCacheSize)
+
+// get returns the analysis of code, computing it on a miss. Codes without a hash
+// (empty hash) are analysed but never cached.
+func (cache *hackerAnalysisCache) get(codehash common.Hash, code []byte) *hackerCodeAnalysis {
+	if codehash == (common.Hash{}) {
+		return analyseCode(code)
+	}
+	cache.lock.Lock()
+	if elem, ok := cache.entries[codehash]; ok {
+		cache.order.MoveToFront(elem)
+		cache.hits++
+		cache.lock.Unlock()
+		return elem.Value.(*hackerAnalysisEntry).analysis
+	}
+	cache.misses++
+	cache.lock.Unlock()
+
+	// analyse outside the lock, a concurrent miss on the same code only costs a duplicate analysis
+	analysis := analyseCode(code)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if elem, ok := cache.entries[codehash]; ok {
+		return elem.Value.(*hackerAnalysisEntry).analysis
+	}
+	cache.entries[codehash] = cache.order.PushFront(&hackerAnalysisEntry{codehash: codehash, analysis: analysis})
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*hackerAnalysisEntry).codehash)
+	}
+	return analysis
+}
+
+// stats returns the hits and misses of the cache.
+func (cache *hackerAnalysisCache) stats() (uint64, uint64) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return cache.hits, cache.misses
+}
@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAnalysisCache(t *testing.T) {
+	var (
+		cache = newHackerAnalysisCache(2)
+		// PUSH1 0x5b JUMPDEST PUSH1 2 JUMP
+		code = []byte{byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST), byte(PUSH1), 2, byte(JUMP)}
+	)
+	analysis := cache.get(common.HexToHash("0x01"), code)
+	if !bytes.Equal(analysis.jumpdests, jumpdests(code)) {
+		t.Fatalf("jumpdests mismatch: %x", analysis.jumpdests)
+	}
+	if index, ok := analysis.branches[5]; !ok || index != 0 || len(analysis.branches) != 1 {
+		t.Fatalf("unexpected branch table %v", analysis.branches)
+	}
+	if cache.get(common.HexToHash("0x01"), code) != analysis {
+		t.Fatal("analysis not reused")
+	}
+	cache.get(common.HexToHash("0x02"), code)
+	cache.get(common.HexToHash("0x03"), code)
+	if _, ok := cache.entries[common.HexToHash("0x01")]; ok {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if hits, misses := cache.stats(); hits != 1 || misses != 3 {
+		t.Fatalf("hits %d misses %d", hits, misses)
+	}
+}
+
+func BenchmarkJumpdestCached(b *testing.B) {
+	code := bytes.Repeat([]byte{byte(PUSH1), 0, byte(JUMPDEST), byte(JUMPI)}, 4096)
+	codehash := common.HexToHash("0x01")
+	pos := new(big.Int).SetUint64(2)
+	for i := 0; i < b.N; i++ {
+		// a fresh destinations per call, as for every new Contract
+		destinations{}.has(codehash, code, pos)
+	}
+}
@@ -0,0 +1,170 @@
+/**
+* @hacker_attacker.go
+* Attacker contract templates used to confirm what the oracles suspect.
+* Every template is runtime bytecode (no constructor) so the harness can place it at any
+* address through a state override:
+*   reentrancy          : fallback calling back into the target with a fixed payload, Depth times
+*   gas_burner          : fallback looping until it runs out of gas
+*   revert_always       : fallback throwing on every call (INVALID, no REVERT before byzantium)
+*   selfdestruct_funder : fallback self destructing to a beneficiary, forcing ether into it
+ */
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	AttackerReentrancy         = "reentrancy"
+	AttackerGasBurner          = "gas_burner"
+	AttackerRevertAlways       = "revert_always"
+	AttackerSelfdestructFunder = "selfdestruct_funder"
+)
+
+// hackerSuggestedAttackers maps oracle names (prefixes of Oracle.String()) and finding types
+// to the templates able to confirm them.
+var hackerSuggestedAttackers = map[string][]string{
+	"HackerReentrancy":              {AttackerReentrancy},
+	"HackerGaslessSend":             {AttackerGasBurner},
+	"HackerEtherTransferFailed":     {AttackerRevertAlways, AttackerGasBurner},
+	"HackerCallEtherTransferFailed": {AttackerRevertAlways, AttackerGasBurner},
+	"HackerExceptionDisorder":       {AttackerRevertAlways},
+	"HackerBalanceGtZero":           {AttackerSelfdestructFunder},
+	"unbounded_loop":                {AttackerGasBurner},
+}
+
+// SuggestAttackers returns the templates worth deploying for the oracle or finding name.
+func SuggestAttackers(name string) []string {
+	for prefix, kinds := range hackerSuggestedAttackers {
+		if strings.HasPrefix(name, prefix) {
+			return kinds
+		}
+	}
+	return nil
+}
+
+// HackerAttackerParams parameterizes the templates, each template reads only what it needs.
+type HackerAttackerParams struct {
+	Target      common.Address // reentrancy: contract called back
+	Payload     []byte         // reentrancy: calldata of the call back
+	Value       *big.Int       // reentrancy: value of the call back
+	Depth       uint64         // reentrancy: number of call backs, 1 if zero
+	Beneficiary common.Address // selfdestruct_funder: receiver of the balance
+}
+
+// HackerAttacker is an instantiated template.
+type HackerAttacker struct {
+	Kind string
+	Code []byte
+}
+
+// NewHackerAttacker assembles the template kind with params.
+func NewHackerAttacker(kind string, params HackerAttackerParams) (*HackerAttacker, error) {
+	asm := newHackerAssembler()
+	switch kind {
+	case AttackerReentrancy:
+		depth := params.Depth
+		if depth == 0 {
+			depth = 1
+		}
+		value := params.Value
+		if value == nil {
+			value = new(big.Int)
+		}
+		// if sload(0) < depth
+		asm.pushUint(0).op(SLOAD)
+		asm.pushUint(depth).op(DUP2, LT, ISZERO).pushLabel("stop").op(JUMPI)
+		// sstore(0, sload(0)+1)
+		asm.pushUint(1).op(ADD).pushUint(0).op(SSTORE)
+		// codecopy(0, payload, len(payload))
+		asm.pushUint(uint64(len(params.Payload))).pushLabel("payload").pushUint(0).op(CODECOPY)
+		// call(gas, target, value, 0, len(payload), 0, 0)
+		asm.pushUint(0).pushUint(0).pushUint(uint64(len(params.Payload))).pushUint(0)
+		asm.push(value.Bytes()).push(params.Target.Bytes()).op(GAS, CALL, POP)
+		asm.label("stop").op(STOP)
+		asm.data("payload", params.Payload)
+	case AttackerGasBurner:
+		asm.label("loop").pushLabel("loop").op(JUMP)
+	case AttackerRevertAlways:
+		asm.code = append(asm.code, 0xfe)
+	case AttackerSelfdestructFunder:
+		asm.push(params.Beneficiary.Bytes()).op(SELFDESTRUCT)
+	default:
+		return nil, fmt.Errorf("unknown attacker template %q", kind)
+	}
+	return &HackerAttacker{Kind: kind, Code: asm.bytes()}, nil
+}
+
+// Override returns the state override deploying the attacker at addr with balance.
+func (attacker *HackerAttacker) Override(addr common.Address, balance *big.Int) HackerStateOverride {
+	return HackerStateOverride{addr: &HackerAccountOverride{Code: attacker.Code, Balance: balance, State: map[common.Hash]common.Hash{}}}
+}
+
+// hackerAssembler writes bytecode with forward references to labels (always PUSH2).
+type hackerAssembler struct {
+	code   []byte
+	labels map[string]uint64
+	fixups map[int]string
+}
+
+func newHackerAssembler() *hackerAssembler {
+	return &hackerAssembler{labels: make(map[string]uint64), fixups: make(map[int]string)}
+}
+
+func (asm *hackerAssembler) op(ops ...OpCode) *hackerAssembler {
+	for _, op := range ops {
+		asm.code = append(asm.code, byte(op))
+	}
+	return asm
+}
+
+// push emits the shortest PUSHn for data, PUSH1 0 when data is empty.
+func (asm *hackerAssembler) push(data []byte) *hackerAssembler {
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	asm.code = append(asm.code, byte(PUSH1)+byte(len(data)-1))
+	asm.code = append(asm.code, data...)
+	return asm
+}
+
+func (asm *hackerAssembler) pushUint(v uint64) *hackerAssembler {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return asm.push(buf[:])
+}
+
+func (asm *hackerAssembler) pushLabel(name string) *hackerAssembler {
+	asm.code = append(asm.code, byte(PUSH2))
+	asm.fixups[len(asm.code)] = name
+	asm.code = append(asm.code, 0, 0)
+	return asm
+}
+
+// label marks a jump destination.
+func (asm *hackerAssembler) label(name string) *hackerAssembler {
+	asm.labels[name] = uint64(len(asm.code))
+	return asm.op(JUMPDEST)
+}
+
+// data appends raw bytes addressable through name.
+func (asm *hackerAssembler) data(name string, data []byte) *hackerAssembler {
+	asm.labels[name] = uint64(len(asm.code))
+	asm.code = append(asm.code, data...)
+	return asm
+}
+
+func (asm *hackerAssembler) bytes() []byte {
+	for pos, name := range asm.fixups {
+		binary.BigEndian.PutUint16(asm.code[pos:], uint16(asm.labels[name]))
+	}
+	return asm.code
+}
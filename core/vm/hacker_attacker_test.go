@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAttackerTemplates(t *testing.T) {
+	payload := []byte{0xde, 0xad, 0xbe, 0xef}
+	attacker, err := NewHackerAttacker(AttackerReentrancy, HackerAttackerParams{Target: common.HexToAddress("0x01"), Payload: payload, Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := attacker.Code
+	if !bytes.HasSuffix(code, payload) {
+		t.Fatalf("payload not appended: %x", code)
+	}
+	// every PUSH2 is a label and must point at a JUMPDEST or at the payload
+	for pc := 0; pc < len(code)-len(payload); pc++ {
+		op := OpCode(code[pc])
+		if op == PUSH2 {
+			dest := int(binary.BigEndian.Uint16(code[pc+1:]))
+			if OpCode(code[dest]) != JUMPDEST && dest != len(code)-len(payload) {
+				t.Fatalf("label at pc %d points at %x", pc, code[dest])
+			}
+		}
+		if op.IsPush() {
+			pc += int(op - PUSH1 + 1)
+		}
+	}
+	burner, _ := NewHackerAttacker(AttackerGasBurner, HackerAttackerParams{})
+	if want := []byte{byte(JUMPDEST), byte(PUSH2), 0, 0, byte(JUMP)}; !bytes.Equal(burner.Code, want) {
+		t.Fatalf("gas burner: got %x, want %x", burner.Code, want)
+	}
+	if _, err := NewHackerAttacker("unknown", HackerAttackerParams{}); err == nil {
+		t.Fatal("expected an error for an unknown template")
+	}
+	if kinds := SuggestAttackers("HackerReentrancyle"); len(kinds) != 1 || kinds[0] != AttackerReentrancy {
+		t.Fatalf("unexpected suggestion %v", kinds)
+	}
+}
@@ -0,0 +1,49 @@
+/**
+* @hacker_audit.go
+* Audit log of the control operations of the campaign: targets registered, oracles
+* and watchpoints set, instrumentation, policy, breakpoints, state overrides applied
+* by the harness calls.
+* 1 an entry is the RPC method with its positional parameters as JSON and its error,
+*   replaying the entries in order on a fresh node sets the campaign up again.
+* 2 the log is append-only, it is only cleared by Reset, and is part of the session
+*   summary (hacker_shutdown.go).
+ */
+package vm
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HackerAuditEntry is a control operation of the campaign.
+type HackerAuditEntry struct {
+	Seq    uint64 `json:"seq"`
+	Method string `json:"method"`
+	// Params are the parameters of the method, as sent to the RPC
+	Params json.RawMessage `json:"params"`
+	Error  string          `json:"error,omitempty"`
+	// Time is when the operation ran, in seconds since the epoch
+	Time int64 `json:"time"`
+}
+
+// Audit appends the operation method with params, failed with err, to the audit log.
+func (c *HackerCampaign) Audit(method string, params interface{}, err error) {
+	data, marshalErr := json.Marshal(params)
+	if marshalErr != nil {
+		data, _ = json.Marshal(marshalErr.Error())
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := HackerAuditEntry{Seq: uint64(len(c.audit)), Method: method, Params: data, Time: time.Now().Unix()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.audit = append(c.audit, entry)
+}
+
+// AuditLog returns the audit log in the order of the operations.
+func (c *HackerCampaign) AuditLog() []HackerAuditEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]HackerAuditEntry{}, c.audit...)
+}
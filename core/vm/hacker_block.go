@@ -0,0 +1,143 @@
+/**
+* @hacker_block.go
+* Block boundaries of the WatchDog.
+* 1 the node calls OnBlockStart before the first transaction of a block and OnBlockEnd
+*   after the last one, with the receipts of the block. Transactions run outside of a
+*   block (harness, RPC calls) are not counted.
+* 2 between them the WatchDog counts the transactions it watched, reported and the
+*   findings raised; OnBlockEnd adds the counts to the session of the campaign.
+* 3 the campaign-global state (coverage, batching, session accounting) is flushed at
+*   block end by the listeners registered with RegisterBlockListener, instead of after
+*   every transaction.
+* 4 the transactions watched in the block are replayed in permuted orders at block end
+*   (hacker_tod.go), a divergence is a "tod" finding of the campaign.
+ */
+package vm
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HackerBlockListener is told about the block boundaries seen by the WatchDog.
+type HackerBlockListener interface {
+	OnBlockStart(header *types.Header)
+	OnBlockEnd(header *types.Header, receipts types.Receipts)
+}
+
+var (
+	blockListenersLock sync.Mutex
+	blockListeners     []HackerBlockListener
+)
+
+// RegisterBlockListener adds a listener of the block boundaries of the node WatchDog.
+func RegisterBlockListener(listener HackerBlockListener) {
+	blockListenersLock.Lock()
+	defer blockListenersLock.Unlock()
+	blockListeners = append(blockListeners, listener)
+}
+
+// UnregisterBlockListener removes a listener added with RegisterBlockListener.
+func UnregisterBlockListener(listener HackerBlockListener) {
+	blockListenersLock.Lock()
+	defer blockListenersLock.Unlock()
+	for i := range blockListeners {
+		if blockListeners[i] == listener {
+			blockListeners = append(blockListeners[:i], blockListeners[i+1:]...)
+			return
+		}
+	}
+}
+
+func listenersOfBlocks() []HackerBlockListener {
+	blockListenersLock.Lock()
+	defer blockListenersLock.Unlock()
+	return append([]HackerBlockListener{}, blockListeners...)
+}
+
+// hackerBlock counts the watched transactions of the current block.
+type hackerBlock struct {
+	header   *types.Header
+	watched  int
+	reported int
+	findings int
+	// tod collects the watched transactions
+	tod hackerBlockTOD
+}
+
+// HackerSession is the accounting of a campaign over the blocks it saw.
+type HackerSession struct {
+	Blocks       uint64 `json:"blocks"`
+	Transactions uint64 `json:"transactions"`
+	Watched      uint64 `json:"watched"`
+	Reported     uint64 `json:"reported"`
+	Findings     uint64 `json:"findings"`
+	// LastBlock is the number of the last block ended
+	LastBlock uint64 `json:"lastBlock"`
+}
+
+// OnBlockStart opens the block of header, the transactions watched until OnBlockEnd
+// are counted in it.
+func (dog *WatchDog) OnBlockStart(header *types.Header) {
+	dog.block = &hackerBlock{header: header}
+	for _, listener := range listenersOfBlocks() {
+		listener.OnBlockStart(header)
+	}
+}
+
+// OnBlockEnd closes the block of header, adds its counts to the session of the
+// campaign and lets the listeners flush. The transactions still watched are abandoned.
+// A TOD finding of the block is recorded against its first watched transaction.
+func (dog *WatchDog) OnBlockEnd(header *types.Header, receipts types.Receipts) {
+	// the transactions watched are over with the block
+	dog.abandon()
+	block := dog.block
+	dog.block = nil
+	if block == nil {
+		block = &hackerBlock{header: header}
+	}
+	if finding := block.tod.finding(); finding != nil {
+		block.findings++
+		GetGlobalCampaign().addFindings(block.tod.txs[0], []HackerFinding{*finding})
+	}
+	GetGlobalCampaign().endBlock(header, len(receipts), block)
+	for _, listener := range listenersOfBlocks() {
+		listener.OnBlockEnd(header, receipts)
+	}
+}
+
+// countBlock counts the transaction ending in the current block, reported when its
+// report was sent.
+func (dog *WatchDog) countBlock(reported bool) {
+	if dog.block == nil || !dog.watching() {
+		return
+	}
+	dog.block.watched++
+	dog.block.findings += len(dog.findings)
+	if reported {
+		dog.block.reported++
+	}
+}
+
+// endBlock adds the counts of block to the session.
+func (c *HackerCampaign) endBlock(header *types.Header, transactions int, block *hackerBlock) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.session.Blocks++
+	c.session.Transactions += uint64(transactions)
+	c.session.Watched += uint64(block.watched)
+	c.session.Reported += uint64(block.reported)
+	c.session.Findings += uint64(block.findings)
+	if header != nil && header.Number != nil {
+		c.session.LastBlock = header.Number.Uint64()
+	}
+	c.sampleCoverage(header)
+}
+
+// Session returns the accounting of the campaign.
+func (c *HackerCampaign) Session() HackerSession {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.session
+}
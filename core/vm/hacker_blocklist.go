@@ -0,0 +1,106 @@
+/**
+* @hacker_blocklist.go
+* Contracts left out of the trace, e.g. the exchanges and routers of a mainnet fork.
+* 1 the blocklist of the campaign holds the addresses of known infrastructure with a
+*   label. Nothing of what runs in their frames is recorded: trace, taint, storage
+*   writes, logs, coverage, oracles. The frames themselves are still in "frames" and the
+*   contracts they call are traced as usual.
+* 2 the report summarises, per blocked contract that ran, its frames and operations in
+*   the "blocked" section.
+* The blocklist applies from the next watched transaction on.
+ */
+package vm
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerBlockedContract is a contract of the blocklist, with what it ran in the watched
+// transaction.
+type HackerBlockedContract struct {
+	Address common.Address `json:"address"`
+	Label   string         `json:"label"`
+	Frames  int            `json:"frames"`
+	Steps   uint64         `json:"steps"`
+}
+
+// Block adds addr to the blocklist of the campaign.
+func (c *HackerCampaign) Block(addr common.Address, label string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.blocklist[addr] = label
+}
+
+// Unblock removes addr from the blocklist of the campaign.
+func (c *HackerCampaign) Unblock(addr common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.blocklist, addr)
+}
+
+// Blocklist returns the contracts of the blocklist, sorted by address.
+func (c *HackerCampaign) Blocklist() []HackerBlockedContract {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	blocked := make([]HackerBlockedContract, 0, len(c.blocklist))
+	for addr, label := range c.blocklist {
+		blocked = append(blocked, HackerBlockedContract{Address: addr, Label: label})
+	}
+	sortBlocked(blocked)
+	return blocked
+}
+
+func sortBlocked(blocked []HackerBlockedContract) {
+	sort.Slice(blocked, func(i, j int) bool {
+		return bytes.Compare(blocked[i].Address[:], blocked[j].Address[:]) < 0
+	})
+}
+
+// blockedContracts returns the summaries of the blocked contracts for a watched
+// transaction, nil when the blocklist is empty.
+func (c *HackerCampaign) blockedContracts() map[common.Address]*HackerBlockedContract {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.blocklist) == 0 {
+		return nil
+	}
+	blocked := make(map[common.Address]*HackerBlockedContract, len(c.blocklist))
+	for addr, label := range c.blocklist {
+		blocked[addr] = &HackerBlockedContract{Address: addr, Label: label}
+	}
+	return blocked
+}
+
+// isBlocked tells whether contract is blocked, counting the operation it runs.
+func (dog *WatchDog) isBlocked(contract *Contract) bool {
+	if dog.blocked == nil {
+		return false
+	}
+	summary, ok := dog.blocked[contract.Address()]
+	if ok {
+		summary.Steps++
+	}
+	return ok
+}
+
+// countBlockedFrame counts the frame of contract ending.
+func (dog *WatchDog) countBlockedFrame(contract *Contract) {
+	if summary, ok := dog.blocked[contract.Address()]; ok {
+		summary.Frames++
+	}
+}
+
+// blockedSummary returns the "blocked" section, nil when no blocked contract ran.
+func (dog *WatchDog) blockedSummary() []HackerBlockedContract {
+	var blocked []HackerBlockedContract
+	for _, summary := range dog.blocked {
+		if summary.Frames != 0 || summary.Steps != 0 {
+			blocked = append(blocked, *summary)
+		}
+	}
+	sortBlocked(blocked)
+	return blocked
+}
@@ -0,0 +1,111 @@
+/**
+* @hacker_budget.go
+* Size budget of the WatchDog reports.
+* 1 the budget is a maximum size in bytes of the JSON encoding of the report, checked
+*   after the redaction (hacker_redact.go): the sections are measured as they would be
+*   encoded, without holding the encoding.
+* 2 over budget, sections are dropped in the order of the priority of the budget until
+*   the report fits: by default the machine state snapshots first, then the trace,
+*   the storage and the frames, the probes last.
+* 3 the verdicts and the coverage ("findings", "coverage", "hasThrow", "hash",
+*   "receipt") are never dropped, a priority naming one is refused.
+* 4 the sections dropped are listed in "elided", in the order they were dropped, so the
+*   consumers tell an elided section from an empty one.
+ */
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// HackerReportBudget is the size budget of the reports.
+type HackerReportBudget struct {
+	MaxBytes int `json:"maxBytes"`
+	// Priority lists the sections in the order they are dropped, empty for the default
+	Priority []string `json:"priority"`
+}
+
+// hackerBudgetPriority is the default order in which the sections are dropped.
+var hackerBudgetPriority = []string{
+	"breakpoints", "trace", "traceFrames", "storageWrites", "storage_old", "storage_new",
+	"frames", "bundle", "console", "logs", "accounts", "revertState", "blocked", "steps",
+	"differential", "griefing", "revertBomb", "returnPoisoning", "timeSkew", "feeSkew",
+	"frontRunning",
+}
+
+// hackerBudgetKept are the sections never dropped.
+var hackerBudgetKept = map[string]bool{"findings": true, "coverage": true, "hasThrow": true, "hash": true, "receipt": true, "elided": true}
+
+var errBudgetKept = errors.New("the verdicts and the coverage of the reports cannot be elided")
+
+// SetReportBudget sets the size budget of the reports, nil sends them whole.
+func (c *HackerCampaign) SetReportBudget(budget *HackerReportBudget) error {
+	if budget != nil {
+		for _, section := range budget.Priority {
+			if hackerBudgetKept[section] {
+				return errBudgetKept
+			}
+		}
+		copied := &HackerReportBudget{MaxBytes: budget.MaxBytes, Priority: append([]string{}, budget.Priority...)}
+		if len(copied.Priority) == 0 {
+			copied.Priority = append(copied.Priority, hackerBudgetPriority...)
+		}
+		budget = copied
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.budget = budget
+	return nil
+}
+
+// ReportBudget returns the size budget of the reports, nil when there is none.
+func (c *HackerCampaign) ReportBudget() *HackerReportBudget {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.budget
+}
+
+// hackerByteCounter counts the bytes written to it.
+type hackerByteCounter int
+
+func (counter *hackerByteCounter) Write(p []byte) (int, error) {
+	*counter += hackerByteCounter(len(p))
+	return len(p), nil
+}
+
+// encodedSize returns the size of the JSON encoding of v, 0 when it has none.
+func encodedSize(v interface{}) int {
+	var counter hackerByteCounter
+	if err := json.NewEncoder(&counter).Encode(v); err != nil {
+		return 0
+	}
+	return int(counter)
+}
+
+// fitBudget drops the sections of report in the order of the budget of the campaign
+// until it fits, and lists them in "elided".
+func (dog *WatchDog) fitBudget(report map[string]interface{}) {
+	budget := GetGlobalCampaign().ReportBudget()
+	if budget == nil {
+		return
+	}
+	size := encodedSize(report)
+	elided := make([]string, 0)
+	for _, section := range budget.Priority {
+		if size <= budget.MaxBytes {
+			break
+		}
+		value, ok := report[section]
+		if !ok {
+			continue
+		}
+		// the key, its quotes, the colon and the comma
+		size -= encodedSize(value) - 1 + len(section) + 4
+		delete(report, section)
+		elided = append(elided, section)
+	}
+	if len(elided) != 0 {
+		report["elided"] = elided
+	}
+}
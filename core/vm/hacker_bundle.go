@@ -0,0 +1,109 @@
+/**
+* @hacker_bundle.go
+* Bundle simulation: an ordered list of messages executed on one fork of the state,
+* like a MEV bundle, each message seeing the effects of the previous ones.
+* 1 the state is reverted once the last message ran, whether a message failed or
+*   not: the failure of a message is reported in its step, the next ones still run.
+* 2 an instrumented harness watches the whole bundle with a single fork of the
+*   WatchDog (hacker_callreport.go): one trace, one set of oracles and one report,
+*   whose "bundle" section has a step per message. The operations and findings of a
+*   step are given by their positions, counted from the start of the bundle.
+* 3 the balances compared by the profit oracles are taken before the first message
+*   and after the last one.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerBundleStep is the section of a message of a bundle in its report.
+type HackerBundleStep struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	// Trace are the positions of the first operation of the step and of the one after
+	// its last, traceDropped of the first ones are not in the trace
+	Trace [2]uint64 `json:"trace"`
+	// Findings are the positions of the first finding of the step and of the one after
+	// its last
+	Findings [2]int `json:"findings"`
+	GasUsed  uint64 `json:"gasUsed"`
+	Failed   bool   `json:"failed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HackerBundleOutcome is the result of a bundle.
+type HackerBundleOutcome struct {
+	Steps []*HackerOutcome
+	// Report is the report of the bundle of an instrumented harness
+	Report *HackerCallReport
+}
+
+// hackerBundleWatch is the watch of a bundle executed by an instrumented harness.
+type hackerBundleWatch struct {
+	dog     *WatchDog
+	gasUsed uint64
+	failed  bool
+}
+
+// traced returns the number of operations recorded so far.
+func (dog *WatchDog) traced() uint64 {
+	return dog.trace.steps()
+}
+
+// watch watches the message msg executed by evm, nil when it is not watched.
+func (harness *HackerHarness) watch(evm *EVM, msg *HackerMessage, value *big.Int) *WatchDog {
+	bundle := harness.bundle
+	if bundle == nil {
+		return watchCall(evm, msg, value)
+	}
+	if bundle.dog == nil {
+		if bundle.dog = watchCall(evm, msg, value); bundle.dog == nil {
+			return nil
+		}
+	}
+	dog := bundle.dog
+	dog.bundle = append(dog.bundle, HackerBundleStep{From: msg.From, To: *msg.To, Trace: [2]uint64{dog.traced(), 0}, Findings: [2]int{len(dog.findings), 0}})
+	return dog
+}
+
+// unwatch ends the watch of the message of outcome, the report of the bundle is sent
+// once all its messages ran.
+func (harness *HackerHarness) unwatch(dog *WatchDog, outcome *HackerOutcome) *HackerCallReport {
+	bundle := harness.bundle
+	if bundle == nil {
+		return endCall(dog, outcome.Failed(), outcome.GasUsed)
+	}
+	step := &dog.bundle[len(dog.bundle)-1]
+	step.Trace[1], step.Findings[1] = dog.traced(), len(dog.findings)
+	step.GasUsed, step.Failed = outcome.GasUsed, outcome.Failed()
+	if outcome.Err != nil {
+		step.Error = outcome.Err.Error()
+	}
+	bundle.gasUsed += outcome.GasUsed
+	bundle.failed = bundle.failed || outcome.Failed()
+	return nil
+}
+
+// ExecuteBundle runs msgs in order on one fork of the state with override applied
+// first and returns their outcomes, with the report of the bundle when the harness is
+// instrumented.
+func (harness *HackerHarness) ExecuteBundle(msgs []*HackerMessage, override HackerStateOverride) *HackerBundleOutcome {
+	outcome := &HackerBundleOutcome{Steps: make([]*HackerOutcome, 0, len(msgs))}
+	harness.Fork(func() {
+		override.Apply(harness.statedb)
+		if harness.instrumented {
+			harness.bundle = new(hackerBundleWatch)
+			defer func() { harness.bundle = nil }()
+		}
+		for _, msg := range msgs {
+			outcome.Steps = append(outcome.Steps, harness.Apply(msg))
+		}
+		if bundle := harness.bundle; bundle != nil && bundle.dog != nil {
+			outcome.Report = endCall(bundle.dog, bundle.failed, bundle.gasUsed)
+		}
+	})
+	return outcome
+}
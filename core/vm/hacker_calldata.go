@@ -0,0 +1,86 @@
+/**
+* @hacker_calldata.go
+* Oracle: short calldata (short address attack)
+* A frame that reads past the end of its calldata (CALLDATALOAD/CALLDATACOPY) without
+* ever comparing CALLDATASIZE with the size it expects gets the missing bytes silently
+* zero padded.
+* The expected size comes from the ABI registry when the selector is registered: the
+* selector and the arguments. Otherwise a check has to go beyond the selector, the
+* "calldatasize < 4" of a dispatcher validates no argument.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// calldataEnd returns offset+size, saturating for offsets no calldata can reach.
+func calldataEnd(offset, size *big.Int) uint64 {
+	end := new(big.Int).Add(offset, size)
+	if end.BitLen() > 63 {
+		return 1<<63 - 1
+	}
+	return end.Uint64()
+}
+
+// calldataExpected returns the calldata size a length check of the frame of contract
+// has to ensure.
+func calldataExpected(contract *Contract) uint64 {
+	if method, ok := GetABIRegistry().Lookup(contract.Address(), contract.Input); ok {
+		return 4 + uint64(method.ArgSize)
+	}
+	return 5
+}
+
+// calldataBound returns the calldata size the comparison of step ensures, nil when
+// it does not compare CALLDATASIZE with an untainted value. The ABI decoders compare
+// sub(calldatasize, 4): the operand derived from CALLDATASIZE is brought back to it.
+func calldataBound(step *hackerTaintStep, size uint64) *big.Int {
+	for i, other := range [2]int{1, 0} {
+		if step.input(i)&taintCalldataSize != 0 && step.input(other)&taintCalldataSize == 0 {
+			bound := new(big.Int).Sub(step.arg(other), step.arg(i))
+			return bound.Add(bound, new(big.Int).SetUint64(size))
+		}
+	}
+	return nil
+}
+
+func (taint *HackerTaint) checkCalldataLength(dog *WatchDog, step *hackerTaintStep, op OpCode, pc uint64, contract *Contract) {
+	var end uint64
+	switch op {
+	case LT, GT, SLT, SGT, EQ:
+		bound := calldataBound(step, uint64(len(contract.Input)))
+		if bound != nil && bound.Cmp(new(big.Int).SetUint64(calldataExpected(contract))) >= 0 {
+			taint.lengthChecked[contract] = true
+		}
+		return
+	case CALLDATALOAD:
+		end = calldataEnd(step.arg(0), big.NewInt(32))
+	case CALLDATACOPY:
+		if step.arg(2).Sign() == 0 {
+			return
+		}
+		end = calldataEnd(step.arg(1), step.arg(2))
+	default:
+		return
+	}
+	size := uint64(len(contract.Input))
+	if end <= size || taint.lengthChecked[contract] || !taint.once(contract, pc, "calldata") {
+		return
+	}
+	finding := newHackerFinding("short_calldata", "HackerShortCalldata")
+	finding.Detail["contract"] = contract.Address().Hex()
+	finding.Detail["pc"] = fmt.Sprintf("%d", pc)
+	finding.Detail["calldataSize"] = fmt.Sprintf("%d", size)
+	finding.Detail["readEnd"] = fmt.Sprintf("%d", end)
+	if size >= 4 {
+		finding.Detail["selector"] = hex.EncodeToString(contract.Input[:4])
+	}
+	if method, ok := GetABIRegistry().Lookup(contract.Address(), contract.Input); ok {
+		finding.Detail["function"] = method.Signature
+		finding.Detail["expectedSize"] = fmt.Sprintf("%d", 4+method.ArgSize)
+	}
+	dog.EmitFinding(finding)
+}
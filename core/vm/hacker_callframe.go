@@ -0,0 +1,86 @@
+/**
+* @hacker_callframe.go
+* Recording of the CALLCODE, DELEGATECALL and STATICCALL frames on the hacker call stack,
+* without changing what the frames do.
+* 1 a frame that cannot be recorded (no call stack, an empty one) runs uninstrumented:
+*   it used to return before run, with no gas left and no error, as a successful call
+*   doing nothing.
+* 2 a panic of the instrumentation in a frame (Call, CallCode, DelegateCall, StaticCall)
+*   fails the frame with ErrInstrumentationPanic: its state changes are reverted, its
+*   gas consumed and its recorded call closed with the error, where the frame used to
+*   return no gas left and no error.
+ */
+package vm
+
+import (
+	"math/big"
+	"runtime"
+)
+
+// hackerOpenCall records the frame of contract, run by op (CALLCODE, DELEGATECALL or
+// STATICCALL), on the hacker call stack and returns it, nil when it cannot be recorded.
+func hackerOpenCall(op OpCode, evm *EVM, caller ContractRef, contract *Contract, value *big.Int, gas uint64, input []byte, snapshot int) *HackerContractCall {
+	hacker_init(evm, contract, input)
+	tree := evm.callTree
+	if tree == nil || tree.stack.len() == 0 {
+		Printf("%v frame of %s not recorded: no call stack\n", op, contract.Address().Hex())
+		return nil
+	}
+	parent := tree.stack.peek()
+	var call *HackerContractCall
+	switch op {
+	case DELEGATECALL:
+		call = parent.OnDelegateCall(caller, contract.Address(), *new(big.Int).SetUint64(gas), input)
+	case STATICCALL:
+		call = parent.OnStaticCall(caller, contract.Address(), *new(big.Int).SetUint64(gas), input)
+	default:
+		call = parent.OnCallCode(caller, contract.Address(), *value, *new(big.Int).SetUint64(gas), input)
+	}
+	call.snapshotId = snapshot
+	tree.stack.push(call)
+	return call
+}
+
+// hackerCloseCall closes call, opened by hackerOpenCall in evm, with the error and the
+// gas left of its frame. The recording ends (hacker_close) when only the initial call is
+// left.
+func hackerCloseCall(evm *EVM, call *HackerContractCall, nextRevisionId int, err error, gas uint64) {
+	tree := evm.callTree
+	if call == nil || tree == nil || tree.stack.len() == 0 {
+		return
+	}
+	tree.stack.pop()
+	call.nextRevisionId = nextRevisionId
+	call.setError(err)
+	call.OnCloseCall(*new(big.Int).SetUint64(gas))
+	if tree.stack.len() == 1 {
+		hacker_close(tree)
+	}
+}
+
+// hackerRecoverFrame, deferred by the frames of op, recovers a panic of the
+// instrumentation: the frame fails with ErrInstrumentationPanic, the state is reverted
+// to snapshot (none before it is taken, -1), the gas is consumed and the call opened
+// for the frame, if any, is closed with the error.
+func hackerRecoverFrame(op OpCode, evm *EVM, call **HackerContractCall, snapshot *int, ret *[]byte, leftOverGas *uint64, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	Printf("panic in %v frame: %v\n", op, r)
+	for i := 2; i < 12; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		Printf("frame %v:[func:%v,file:%v,line:%v]\n", i, runtime.FuncForPC(pc).Name(), file, line)
+	}
+	if *snapshot >= 0 {
+		evm.revertToSnapshot(*snapshot)
+	}
+	// the call is still open unless the panic comes from closing it
+	if tree := evm.callTree; call != nil && *call != nil && tree != nil && tree.stack.len() > 0 && tree.stack.peek() == *call {
+		hackerCloseCall(evm, *call, *snapshot, ErrInstrumentationPanic, 0)
+	}
+	*ret, *leftOverGas, *err = nil, 0, ErrInstrumentationPanic
+}
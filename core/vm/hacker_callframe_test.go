@@ -0,0 +1,167 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// frameStateDB serves code at every address and records the reverts.
+type frameStateDB struct {
+	NoopStateDB
+	code     []byte
+	reverted []int
+}
+
+func (db *frameStateDB) GetCode(common.Address) []byte          { return db.code }
+func (db *frameStateDB) GetCodeHash(common.Address) common.Hash { return common.BytesToHash(db.code) }
+func (db *frameStateDB) GetCodeSize(common.Address) int         { return len(db.code) }
+func (db *frameStateDB) GetBalance(common.Address) *big.Int     { return new(big.Int) }
+func (db *frameStateDB) RevertToSnapshot(id int)                { db.reverted = append(db.reverted, id) }
+
+func TestFrameWithoutCallStack(t *testing.T) {
+	// PUSH1 1 PUSH1 1 ADD STOP costs 9 gas
+	db := &frameStateDB{code: common.FromHex("0x600160010100")}
+	context := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+	}
+	env := NewEVM(context, db, params.TestChainConfig, Config{})
+	caller := AccountRef(common.HexToAddress("0x01"))
+	target := common.HexToAddress("0x0a")
+
+	frames := map[string]func() ([]byte, uint64, error){
+		"CALLCODE": func() ([]byte, uint64, error) {
+			return env.CallCode(caller, target, nil, 1000, new(big.Int))
+		},
+		"DELEGATECALL": func() ([]byte, uint64, error) {
+			contract := NewContract(caller, caller, new(big.Int), 1000)
+			return env.DelegateCall(contract, target, nil, 1000)
+		},
+	}
+	for name, frame := range frames {
+		// a recording whose call stack lost its initial call
+		env.callTree = &hackerCallTree{env: env, stack: newHackerContractCallStack()}
+		_, gas, err := frame()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if gas != 1000-9 {
+			t.Errorf("%s: %d gas left, want %d", name, gas, 1000-9)
+		}
+		if env.callTree.stack.len() != 0 {
+			t.Errorf("%s: frame recorded on an empty call stack", name)
+		}
+	}
+}
+
+func TestCloseCallWithoutFrame(t *testing.T) {
+	env := NewEVM(Context{}, new(frameStateDB), params.TestChainConfig, Config{})
+	tree := newHackerCallTree(env)
+	defer tree.release()
+	env.callTree = tree
+	initCall := newHackerContractCall(tree, "STARTRECORD", common.Address{}, common.Address{}, *new(big.Int), *new(big.Int), nil)
+	tree.stack.push(initCall)
+	hackerCloseCall(env, nil, 0, nil, 0)
+	if tree.stack.len() != 1 || tree.stack.peek() != initCall {
+		t.Fatal("closing an unrecorded frame popped the call stack")
+	}
+	env.callTree = nil
+	hackerCloseCall(env, initCall, 0, nil, 0)
+}
+
+func TestRecoverFrame(t *testing.T) {
+	db := new(frameStateDB)
+	env := NewEVM(Context{}, db, params.TestChainConfig, Config{})
+	frame := func(snapshot int) (ret []byte, leftOverGas uint64, err error) {
+		defer hackerRecoverFrame(CALL, env, nil, &snapshot, &ret, &leftOverGas, &err)
+		ret, leftOverGas = []byte{1}, 1000
+		panic("instrumentation bug")
+	}
+	ret, gas, err := frame(3)
+	if err != ErrInstrumentationPanic || gas != 0 || ret != nil {
+		t.Fatalf("got (%x, %d, %v), want (nil, 0, %v)", ret, gas, err, ErrInstrumentationPanic)
+	}
+	if len(db.reverted) != 1 || db.reverted[0] != 3 {
+		t.Errorf("reverted to %v, want [3]", db.reverted)
+	}
+	// a panic before the snapshot reverts nothing
+	db.reverted = nil
+	if _, _, err := frame(-1); err != ErrInstrumentationPanic || len(db.reverted) != 0 {
+		t.Errorf("got %v and reverts %v", err, db.reverted)
+	}
+}
+
+func TestRecoverFrameClosesCall(t *testing.T) {
+	env := NewEVM(Context{}, new(frameStateDB), params.TestChainConfig, Config{})
+	tree := newHackerCallTree(env)
+	defer tree.release()
+	env.callTree = tree
+	initCall := newHackerContractCall(tree, "STARTRECORD", common.Address{}, common.Address{}, *new(big.Int), *new(big.Int), nil)
+	tree.stack.push(initCall)
+	outer := initCall.OnCall(AccountRef(common.HexToAddress("0x01")), common.HexToAddress("0x0a"), *new(big.Int), *big.NewInt(100000), nil)
+	tree.stack.push(outer)
+
+	var call *HackerContractCall
+	frame := func(snapshot int) (ret []byte, leftOverGas uint64, err error) {
+		defer hackerRecoverFrame(STATICCALL, env, &call, &snapshot, &ret, &leftOverGas, &err)
+		call = outer.OnStaticCall(AccountRef(common.HexToAddress("0x0a")), common.HexToAddress("0x0b"), *big.NewInt(50000), nil)
+		tree.stack.push(call)
+		panic("instrumentation bug")
+	}
+	if _, _, err := frame(2); err != ErrInstrumentationPanic {
+		t.Fatalf("error %v, want %v", err, ErrInstrumentationPanic)
+	}
+	if tree.stack.len() != 2 || tree.stack.peek() != outer {
+		t.Fatalf("call stack of %d frames, want the frame of the panic popped", tree.stack.len())
+	}
+	if !call.throwException {
+		t.Error("frame of the panic not closed with the error")
+	}
+}
+
+func TestStaticCallFrames(t *testing.T) {
+	// without calldata: STATICCALL 0x0b with one byte, with one byte: DELEGATECALL 0x0c
+	// with two bytes, with two bytes: STOP
+	db := &frameStateDB{code: common.FromHex("0x368015600d5760011460" + "1b57005b600060006001600060" + "0b5afa005b600060006002600060" + "0c5af400")}
+	env := NewEVM(Context{BlockNumber: new(big.Int)}, db, params.TestChainConfig, Config{})
+	caller := AccountRef(common.HexToAddress("0x01"))
+	tree := newHackerCallTree(env)
+	defer tree.release()
+	env.callTree = tree
+	initCall := newHackerContractCall(tree, "STARTRECORD", common.Address{}, common.Address{}, *new(big.Int), *new(big.Int), nil)
+	tree.stack.push(initCall)
+	// a frame of the transaction keeps the recording open
+	tree.stack.push(initCall.OnCall(caller, common.HexToAddress("0x0a"), *new(big.Int), *big.NewInt(100000), nil))
+
+	if _, _, err := env.StaticCall(caller, common.HexToAddress("0x0a"), nil, 100000); err != nil {
+		t.Fatal(err)
+	}
+	// the same DELEGATECALL out of a static context
+	contract := NewContract(caller, caller, new(big.Int), 100000)
+	if _, _, err := env.DelegateCall(contract, common.HexToAddress("0x0c"), []byte{0, 0}, 100000); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		operation string
+		depth     int
+		readOnly  bool
+	}{
+		{"CALL", 0, false},
+		{"STATICCALL", 0, true},
+		{"STATICCALL", 1, true},
+		{"DELEGATECALL", 2, true},
+		{"DELEGATECALL", 0, false},
+	}
+	frames := hackerFrameViews(tree.calls)
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d: %+v", len(frames), len(want), frames)
+	}
+	for i, frame := range frames {
+		if frame.Operation != want[i].operation || frame.Depth != want[i].depth || frame.ReadOnly != want[i].readOnly {
+			t.Errorf("frame %d: %s at depth %d read only %v, want %+v", i, frame.Operation, frame.Depth, frame.ReadOnly, want[i])
+		}
+	}
+}
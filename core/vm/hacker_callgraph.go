@@ -0,0 +1,98 @@
+/**
+* @hacker_callgraph.go
+* Campaign call graph: which contract called which one, with which selector and how often.
+* 1 at the end of every watched transaction, each frame entered by a contract adds an
+*   edge from the account of its parent frame to the code it runs (the code address of a
+*   DELEGATECALL or CALLCODE, the new account of a CREATE). The frame of the transaction
+*   itself is an entry point and not an edge.
+* 2 the edges are accumulated by the campaign across all watched executions, the fuzzer
+*   reads them (fuzz_callGraph) to select contracts to fuzz together.
+ */
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerCallEdge is a key of the call graph.
+type hackerCallEdge struct {
+	from, to common.Address
+	selector string
+	kind     OpCode
+}
+
+// HackerCallEdge is an edge of the campaign call graph.
+type HackerCallEdge struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	// Selector is empty for the calls with less than 4 bytes of input
+	Selector string `json:"selector"`
+	Kind     string `json:"kind"`
+	Count    uint64 `json:"count"`
+}
+
+// callEdges returns the edges of the frames of the journal.
+func (journal *hackerFrameJournal) callEdges() []hackerCallEdge {
+	edges := make([]hackerCallEdge, 0, len(journal.frames))
+	for i := range journal.frames {
+		frame := &journal.frames[i]
+		if frame.parent < 0 {
+			continue
+		}
+		edge := hackerCallEdge{from: journal.frames[frame.parent].Address(), to: frame.Address(), kind: frame.kind}
+		if frame.contract.CodeAddr != nil {
+			edge.to = *frame.contract.CodeAddr
+		}
+		if input := frame.Input(); len(input) >= 4 && frame.kind != CREATE && frame.kind != CREATE2 {
+			edge.selector = "0x" + hex.EncodeToString(input[:4])
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// recordCallGraph adds the calls between contracts of the watched transaction to the
+// call graph of the campaign.
+func (dog *WatchDog) recordCallGraph() {
+	if dog.taint == nil {
+		return
+	}
+	GetGlobalCampaign().addCallEdges(dog.taint.journal.callEdges())
+}
+
+func (c *HackerCampaign) addCallEdges(edges []hackerCallEdge) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, edge := range edges {
+		c.callGraph[edge]++
+	}
+}
+
+// CallGraph returns the edges of the call graph, sorted by caller, callee, selector
+// and kind.
+func (c *HackerCampaign) CallGraph() []HackerCallEdge {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	edges := make([]HackerCallEdge, 0, len(c.callGraph))
+	for edge, count := range c.callGraph {
+		edges = append(edges, HackerCallEdge{From: edge.from, To: edge.to, Selector: edge.selector, Kind: edge.kind.String(), Count: count})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if c := bytes.Compare(a.From[:], b.From[:]); c != 0 {
+			return c < 0
+		}
+		if c := bytes.Compare(a.To[:], b.To[:]); c != 0 {
+			return c < 0
+		}
+		if a.Selector != b.Selector {
+			return a.Selector < b.Selector
+		}
+		return a.Kind < b.Kind
+	})
+	return edges
+}
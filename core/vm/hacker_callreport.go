@@ -0,0 +1,68 @@
+/**
+* @hacker_callreport.go
+* Instrumented harness calls: "what if this call had been made at block N".
+* 1 a message of an instrumented harness is wrapped in a transaction of its own, its
+*   nonce taken from a counter so that every call is watched, and watched by a fork of
+*   the WatchDog on the EVM of the harness (hacker_multiwatch.go): trace, oracles and
+*   report as for a transaction of the node.
+* 2 the harness runs on the state and block context it was given, e.g. a historical
+*   block of an archive node. The env section of the report (hacker_env.go) notes the
+*   call: it ran on the state of its block, not of the parent.
+* 3 the calls are left out of the session accounting of the blocks; their findings
+*   are kept with the campaign like the others.
+* Contract creations are not instrumented.
+ */
+package vm
+
+import (
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// hackerCallNonce numbers the transactions wrapping the instrumented calls.
+var hackerCallNonce uint64
+
+// HackerCallReport is the report of an instrumented call.
+type HackerCallReport struct {
+	// Hash is the hash of the report sent to the sink
+	Hash common.Hash `json:"hash"`
+	// Sent is unset when the call executed no code and had no report, or violated no
+	// constraint in negative mode (hacker_constraint.go)
+	Sent     bool            `json:"sent"`
+	Findings []HackerFinding `json:"findings"`
+}
+
+// SetInstrumented makes the harness watch the messages it executes, each one is
+// reported to the sink.
+func (harness *HackerHarness) SetInstrumented(on bool) {
+	harness.instrumented = on
+}
+
+// watchCall watches msg executed by evm with a fork of the WatchDog, nil when the
+// policy skips it.
+func watchCall(evm *EVM, msg *HackerMessage, value *big.Int) *WatchDog {
+	nonce := atomic.AddUint64(&hackerCallNonce, 1)
+	tx := types.NewTransaction(nonce, *msg.To, value, new(big.Int).SetUint64(msg.Gas), evm.GasPrice, msg.Data)
+	fork := GetGlobalWatchDog().watchAside(evm, tx)
+	if fork == nil {
+		return nil
+	}
+	fork.block = nil
+	fork.call = true
+	return fork
+}
+
+// endCall ends the watch of fork on a call which used gasUsed, failed when set, and
+// sends its report.
+func endCall(fork *WatchDog, failed bool, gasUsed uint64) *HackerCallReport {
+	used := new(big.Int).SetUint64(gasUsed)
+	receipt := &types.Receipt{Failed: failed, CumulativeGasUsed: used, TxHash: fork.tx.Hash(), GasUsed: used}
+	report := &HackerCallReport{Hash: receipt.TxHash}
+	GetGlobalWatchDog().End(receipt)
+	report.Sent = fork.reportable()
+	report.Findings = append([]HackerFinding{}, fork.findings...)
+	return report
+}
@@ -0,0 +1,207 @@
+/**
+* @hacker_campaign.go
+* State kept across all the transactions of a fuzzing campaign, as opposed to the
+* WatchDog which only knows about the transaction being watched.
+ */
+package vm
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Default bounds of the harness executions of a campaign.
+const (
+	hackerDefaultTimeout  = 5 * time.Second
+	hackerDefaultMaxSteps = 50000000
+)
+
+// HackerCampaign accumulates campaign wide statistics.
+type HackerCampaign struct {
+	lock sync.Mutex
+	gas  *hackerGasTracker
+	// chainID is the chain id the harness executions report, nil for the real one
+	chainID *big.Int
+	// timeout and maxSteps bound every harness execution, zero for unbounded
+	timeout  time.Duration
+	maxSteps uint64
+	// session is the accounting of the blocks seen
+	session HackerSession
+	// callGraph counts the calls between contracts of the watched transactions
+	callGraph map[hackerCallEdge]uint64
+	// selectors counts the executions per target and selector
+	selectors map[common.Address]map[string]uint64
+	// dictionaries holds the constants of the code of the targets
+	dictionaries map[common.Address]*hackerDictionary
+	// coverage holds the branch coverage bitmaps by code hash
+	coverage map[common.Hash][]byte
+	// saturation is the number of executions without new coverage after which a target
+	// is instrumented calls-only (reduced), stale counts them per target
+	saturation uint64
+	stale      map[common.Address]uint64
+	reduced    map[common.Address]bool
+	// corpus holds the inputs that covered new edges (corpusKeys by target and input),
+	// the first corpusSent were sent to the coordinator, synced holds the digests of the
+	// bitmaps the coordinator has
+	corpus     []HackerCorpusEntry
+	corpusKeys map[common.Hash]bool
+	corpusSent int
+	synced     map[common.Hash]common.Hash
+	// blocklist labels the contracts left out of the trace
+	blocklist map[common.Address]string
+	// policy decides how the transactions are watched, nil for all fully
+	policy HackerPolicy
+	// profitTargets are the contracts checked by the profit oracle, for a net profit of
+	// the sender above profitThreshold or for profitTokens got for nothing
+	profitTargets   map[common.Address]bool
+	profitThreshold *big.Int
+	profitTokens    []common.Address
+	// labels name addresses in the reports
+	labels map[common.Address]string
+	// redaction is applied to the reports before they are sent, then budget
+	// (hacker_budget.go)
+	redaction *HackerRedaction
+	budget    *HackerReportBudget
+	// coverage growth, recent findings and reports sent, for the status
+	growth  []HackerCoverageSample
+	recent  []HackerRecentFinding
+	reports HackerReportStats
+	// findingTypes counts the findings by type and latency the time the sink takes, for
+	// the metrics
+	findingTypes map[string]uint64
+	latency      HackerHistogram
+	// audit logs the control operations
+	audit []HackerAuditEntry
+	// revertCapture turns the revert-state inspection on (hacker_revert.go)
+	revertCapture bool
+	// constraints must hold in the watched transactions by id, negative sends only the
+	// reports of their violations (hacker_constraint.go)
+	constraints   map[uint64]*HackerConstraint
+	constraintSeq uint64
+	negative      bool
+	// properties are checked after the watched transactions, by harness
+	// (hacker_property.go)
+	properties map[common.Address][]*HackerProperty
+	// fixtures are installed on the forks of the fuzz API (hacker_fixture.go)
+	fixtures map[common.Address]*HackerContractFixture
+	// differentials holds the versions of the code of the targets executed with both
+	// (hacker_differential.go)
+	differentials map[common.Address]*hackerDifferential
+	// griefing, revertBomb and returnPoisoning turn the probes of the callees of the
+	// targets on (hacker_griefing.go, hacker_revertbomb.go, hacker_returndata.go)
+	griefing        bool
+	revertBomb      bool
+	returnPoisoning bool
+	// timeSkews are the timestamp offsets of the replays of the watched transactions,
+	// nil when off (hacker_timeskew.go)
+	timeSkews []uint64
+	// feeVariants are the gas pricing of the replays of the watched transactions, nil
+	// when off (hacker_feeskew.go)
+	feeVariants []HackerFeeVariant
+	// annotations of the codes run by the watched transactions by code hash
+	// (hacker_disasm.go)
+	annotations map[common.Hash]*hackerCodeAnnotations
+	// detectors schedules the detectors by their cost (hacker_schedule.go)
+	detectors hackerDetectorSchedule
+	// traceSampling samples the trace by class of operation, nil when it is kept whole
+	// (hacker_tracesampling.go)
+	traceSampling map[string]uint64
+	// credits are credited on the forks of the fuzz API, faucet tops the senders up
+	// (hacker_faucet.go)
+	credits map[common.Address]*big.Int
+	faucet  *HackerFaucet
+	// nonces allocates the nonces of the senders of the fuzzer (hacker_nonce.go)
+	nonces map[common.Address]*hackerSenderNonces
+	// refundThreshold is the percentage of the gas of a transaction its refunds are
+	// reported at, 0 reports none (hacker_refund.go)
+	refundThreshold uint64
+	// fork is the fork after Byzantium opted in to (hacker_fork.go)
+	fork HackerFork
+}
+
+var campaign *HackerCampaign = nil
+
+func newHackerCampaign() *HackerCampaign {
+	return &HackerCampaign{gas: newHackerGasTracker(), timeout: hackerDefaultTimeout, maxSteps: hackerDefaultMaxSteps, callGraph: make(map[hackerCallEdge]uint64), selectors: make(map[common.Address]map[string]uint64), dictionaries: make(map[common.Address]*hackerDictionary), coverage: make(map[common.Hash][]byte), saturation: hackerDefaultSaturation, stale: make(map[common.Address]uint64), reduced: make(map[common.Address]bool), corpusKeys: make(map[common.Hash]bool), synced: make(map[common.Hash]common.Hash), blocklist: make(map[common.Address]string), profitThreshold: new(big.Int), labels: make(map[common.Address]string), findingTypes: make(map[string]uint64), latency: newHackerHistogram(hackerLatencyBuckets), constraints: make(map[uint64]*HackerConstraint), properties: make(map[common.Address][]*HackerProperty), fixtures: make(map[common.Address]*HackerContractFixture), differentials: make(map[common.Address]*hackerDifferential), annotations: make(map[common.Hash]*hackerCodeAnnotations), credits: make(map[common.Address]*big.Int), nonces: make(map[common.Address]*hackerSenderNonces), refundThreshold: hackerDefaultRefundThreshold}
+}
+
+func GetGlobalCampaign() *HackerCampaign {
+	if campaign == nil {
+		campaign = newHackerCampaign()
+	}
+	return campaign
+}
+
+// Reset forgets everything recorded so far, e.g. when a new campaign starts.
+func (c *HackerCampaign) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.gas = newHackerGasTracker()
+	c.chainID = nil
+	c.timeout, c.maxSteps = hackerDefaultTimeout, hackerDefaultMaxSteps
+	c.session = HackerSession{}
+	c.callGraph = make(map[hackerCallEdge]uint64)
+	c.selectors = make(map[common.Address]map[string]uint64)
+	c.dictionaries = make(map[common.Address]*hackerDictionary)
+	c.coverage = make(map[common.Hash][]byte)
+	c.saturation = hackerDefaultSaturation
+	c.stale = make(map[common.Address]uint64)
+	c.reduced = make(map[common.Address]bool)
+	c.corpus, c.corpusKeys, c.corpusSent = nil, make(map[common.Hash]bool), 0
+	c.synced = make(map[common.Hash]common.Hash)
+	c.blocklist = make(map[common.Address]string)
+	c.labels = make(map[common.Address]string)
+	c.redaction, c.budget = nil, nil
+	c.growth, c.recent, c.reports = nil, nil, HackerReportStats{}
+	c.findingTypes, c.latency = make(map[string]uint64), newHackerHistogram(hackerLatencyBuckets)
+	c.audit = nil
+	c.revertCapture = false
+	c.constraints, c.constraintSeq, c.negative = make(map[uint64]*HackerConstraint), 0, false
+	c.properties = make(map[common.Address][]*HackerProperty)
+	c.fixtures = make(map[common.Address]*HackerContractFixture)
+	c.differentials = make(map[common.Address]*hackerDifferential)
+	c.griefing, c.revertBomb, c.returnPoisoning = false, false, false
+	c.timeSkews, c.feeVariants = nil, nil
+	c.annotations = make(map[common.Hash]*hackerCodeAnnotations)
+	c.detectors = hackerDetectorSchedule{}
+	c.traceSampling = nil
+	c.credits, c.faucet = make(map[common.Address]*big.Int), nil
+	c.nonces = make(map[common.Address]*hackerSenderNonces)
+	c.refundThreshold = hackerDefaultRefundThreshold
+	c.fork = ForkByzantium
+	c.policy = nil
+	c.profitTargets, c.profitThreshold, c.profitTokens = nil, new(big.Int), nil
+}
+
+// SetChainID overrides the chain id reported to the harness executions of the
+// campaign, nil restores the chain id of the chain config.
+func (c *HackerCampaign) SetChainID(id *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.chainID = id
+}
+
+// ChainID returns the chain id override of the campaign, nil if there is none.
+func (c *HackerCampaign) ChainID() *big.Int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.chainID
+}
+
+// SetLimits bounds the wall-clock time and the number of operations of every harness
+// execution of the campaign, zero leaves them unbounded.
+func (c *HackerCampaign) SetLimits(timeout time.Duration, maxSteps uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timeout, c.maxSteps = timeout, maxSteps
+}
+
+// Limits returns the bounds of the harness executions of the campaign.
+func (c *HackerCampaign) Limits() (time.Duration, uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.timeout, c.maxSteps
+}
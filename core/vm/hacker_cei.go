@@ -0,0 +1,76 @@
+/**
+* @hacker_cei.go
+* Oracle: checks-effects-interactions violation.
+* A frame writing storage after a call sending value to another account updates its
+* state once the callee had control: if the callee can call back, it sees the state
+* before the effects, the pattern of the reentrancy exploits. It is flagged whether a
+* reentrancy happened or not, as a "cei_violation" finding per SSTORE, checked in line.
+* The finding is ranked by how the callee of the call was chosen, from its taint:
+*   "high"    derived from calldata, the sender picks the callee.
+*   "medium"  derived from storage, e.g. a registered withdrawal address.
+*   "low"     a constant or anything else.
+* When a frame made several such calls the most controllable callee is reported.
+ */
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerCEICall is the call with value of a frame reported by the SSTOREs after it.
+type hackerCEICall struct {
+	pc     uint64
+	callee string
+	mask   uint
+}
+
+// ceiRank returns the rank of a callee derived from the taint sources of mask.
+func ceiRank(mask uint) int {
+	switch {
+	case mask&taintCalldata != 0:
+		return 2
+	case mask&taintStorage != 0:
+		return 1
+	}
+	return 0
+}
+
+var ceiRankNames = []string{"low", "medium", "high"}
+
+// checkCEI remembers the calls with value of frame and reports its SSTOREs after one.
+func (taint *HackerTaint) checkCEI(dog *WatchDog, step *hackerTaintStep, op OpCode, pc uint64, frame int, contract *Contract) {
+	if frame < 0 {
+		return
+	}
+	switch op {
+	case CALL, CALLCODE:
+		callee := common.BigToAddress(step.arg(1))
+		if step.arg(2).Sign() == 0 || callee == contract.Address() {
+			return
+		}
+		mask := step.input(1) & (taintCalldata | taintStorage)
+		if call, ok := taint.ceiCalls[frame]; ok && ceiRank(call.mask) >= ceiRank(mask) {
+			return
+		}
+		taint.ceiCalls[frame] = &hackerCEICall{pc: pc, callee: callee.Hex(), mask: mask}
+	case SSTORE:
+		call, ok := taint.ceiCalls[frame]
+		if !ok || !taint.once(contract, pc, "cei") {
+			return
+		}
+		finding := newHackerFinding("cei_violation", "HackerCEI")
+		finding.Detail["contract"] = contract.Address().Hex()
+		finding.Detail["frame"] = fmt.Sprintf("%d", frame)
+		finding.Detail["callPc"] = fmt.Sprintf("%d", call.pc)
+		finding.Detail["storePc"] = fmt.Sprintf("%d", pc)
+		finding.Detail["callee"] = call.callee
+		finding.Detail["rank"] = ceiRankNames[ceiRank(call.mask)]
+		if call.mask != 0 {
+			finding.Detail["sources"] = strings.Join(taintSourceNames(call.mask), ",")
+		}
+		dog.EmitFinding(finding)
+	}
+}
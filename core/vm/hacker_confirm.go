@@ -0,0 +1,127 @@
+/**
+* @hacker_confirm.go
+* Two phase findings: detect, then try to exploit.
+* 1 oracles raise findings heuristically during the watched execution.
+* 2 at End(), every finding with a suggested attacker template (hacker_attacker.go) is
+*   replayed on a fork of the state taken when the transaction was watched, before it
+*   ran: the template is deployed at the agent address and the watched
+*   message is sent again from it, once with the template and once with an empty account.
+* 3 the finding is "confirmed" when the template changes the outcome the way the bug
+*   predicts (ether extracted, failure swallowed, forced balance observed), and
+*   "heuristic-only" otherwise.
+ */
+package vm
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	FindingConfirmed     = "confirmed"
+	FindingHeuristicOnly = "heuristic-only"
+)
+
+// hackerReentrancyDepth is the number of call backs made by the reentrancy template.
+const hackerReentrancyDepth = 3
+
+var (
+	// hackerAgent is the default agent contract address, see hacker_close().
+	hackerAgent      = common.HexToAddress("0xe930e50b62af818dbc955f345f9a3a3108f7a70d")
+	hackerAgentFunds = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+)
+
+// Confirm tries the templates suggested for finding against msg and returns the status
+// of the finding with the evidence of the confirmation.
+func (harness *HackerHarness) Confirm(finding *HackerFinding, msg *HackerMessage) (string, string) {
+	if msg.To == nil {
+		return FindingHeuristicOnly, ""
+	}
+	for _, kind := range SuggestAttackers(finding.Type) {
+		if confirmed, evidence := harness.confirmWith(kind, msg); confirmed {
+			return FindingConfirmed, kind + ":" + evidence
+		}
+	}
+	return FindingHeuristicOnly, ""
+}
+
+func (harness *HackerHarness) confirmWith(kind string, msg *HackerMessage) (bool, string) {
+	if kind == AttackerSelfdestructFunder {
+		return harness.confirmForcedEther(msg)
+	}
+	attacker, err := NewHackerAttacker(kind, HackerAttackerParams{Target: *msg.To, Payload: msg.Data, Depth: hackerReentrancyDepth})
+	if err != nil {
+		return false, ""
+	}
+	attack := *msg
+	attack.From = hackerAgent
+	benign := HackerStateOverride{hackerAgent: &HackerAccountOverride{Code: []byte{}, Balance: hackerAgentFunds}}
+	baseline := harness.ExecuteWithOverride(&attack, benign)
+	attacked := harness.ExecuteWithOverride(&attack, attacker.Override(hackerAgent, hackerAgentFunds))
+
+	switch kind {
+	case AttackerReentrancy:
+		// more ether back to the agent than a single call gives
+		gained := new(big.Int).Sub(attacked.Balances[hackerAgent], baseline.Balances[hackerAgent])
+		return gained.Sign() > 0, gained.String()
+	default:
+		// the target completed although its call into the agent failed
+		if attacked.Failed() {
+			return false, ""
+		}
+		reasons := baseline.Diff(attacked)
+		return len(reasons) > 0, strings.Join(reasons, ",")
+	}
+}
+
+// confirmForcedEther checks whether msg behaves differently once ether was forced into
+// its target by a self destructing contract.
+func (harness *HackerHarness) confirmForcedEther(msg *HackerMessage) (bool, string) {
+	attacker, err := NewHackerAttacker(AttackerSelfdestructFunder, HackerAttackerParams{Beneficiary: *msg.To})
+	if err != nil {
+		return false, ""
+	}
+	baseline := harness.Execute(msg)
+	var forced *HackerOutcome
+	harness.Fork(func() {
+		attacker.Override(hackerAgent, hackerAgentFunds).Apply(harness.statedb)
+		agent := hackerAgent
+		harness.Apply(&HackerMessage{From: msg.From, To: &agent, Gas: msg.Gas})
+		forced = harness.Apply(msg)
+	})
+	reasons := baseline.Diff(forced)
+	return len(reasons) > 0, strings.Join(reasons, ",")
+}
+
+// confirmFindings sets the status of every finding of the watched transaction.
+// The replays run on the state before the transaction, on an EVM no WatchDog
+// watches, so they leave no trace.
+func (dog *WatchDog) confirmFindings() {
+	env := dog.confirmEnv
+	dog.confirmEnv = nil
+	findings := dog.Findings()
+	if len(findings) == 0 || env == nil || dog.tx == nil {
+		return
+	}
+	harness := newHackerHarnessFrom(env)
+	msg := hackerTxMessage(env, dog.tx)
+
+	statuses := make([]string, len(findings))
+	evidences := make([]string, len(findings))
+	for i := range findings {
+		statuses[i], evidences[i] = harness.Confirm(&findings[i], msg)
+	}
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	for i := range findings {
+		dog.findings[i].Status = statuses[i]
+		if evidences[i] != "" {
+			if dog.findings[i].Detail == nil {
+				dog.findings[i].Detail = make(map[string]string)
+			}
+			dog.findings[i].Detail["confirmation"] = evidences[i]
+		}
+	}
+}
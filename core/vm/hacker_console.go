@@ -0,0 +1,186 @@
+/**
+* @hacker_console.go
+* console.log of Hardhat and forge-std in the watched executions.
+* 1 the contracts compiled with console.sol or forge-std console2 log by view calls to
+*   the pseudo-precompile 0x000000000000000000636F6e736F6c652e6c6f67, which has no
+*   code: the calls succeed and would be silently ignored.
+* 2 the calls to it are decoded when recorded, by the selectors of the log functions of
+*   both libraries (uint and uint256 spellings), and sent in the "console" section of
+*   the report. Step is the index of the call operation in "trace", counting the
+*   entries dropped ("traceDropped").
+* 3 the message is the arguments formatted like console.log, separated by a space. A
+*   call which cannot be decoded keeps its input instead.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// hackerConsoleAddress is the address console.log calls.
+var hackerConsoleAddress = common.HexToAddress("0x000000000000000000636F6e736F6c652e6c6f67")
+
+var (
+	hackerConsoleOnce sync.Once
+	// hackerConsoleSignatures holds the argument types of the log functions by selector,
+	// computed on the first decoded call.
+	hackerConsoleSignatures map[[4]byte][]string
+)
+
+func consoleSignatures() map[[4]byte][]string {
+	signatures := make(map[[4]byte][]string)
+	add := func(name string, types []string) {
+		canonical := make([]string, len(types))
+		for i, typ := range types {
+			canonical[i] = typ
+			if typ == "uint" || typ == "int" {
+				canonical[i] = typ + "256"
+			}
+		}
+		var selector [4]byte
+		copy(selector[:], crypto.Keccak256([]byte(name + "(" + strings.Join(types, ",") + ")"))[:4])
+		signatures[selector] = canonical
+	}
+	add("log", nil)
+	for _, typ := range []string{"int", "int256", "uint", "uint256", "string", "bool", "address", "bytes", "bytes32"} {
+		add("log", []string{typ})
+	}
+	for _, fn := range [][2]string{{"Int", "int"}, {"Int", "int256"}, {"Uint", "uint"}, {"Uint", "uint256"}, {"String", "string"}, {"Bool", "bool"}, {"Address", "address"}, {"Bytes", "bytes"}} {
+		add("log"+fn[0], []string{fn[1]})
+	}
+	for n := 1; n <= 32; n++ {
+		add("logBytes"+strconv.Itoa(n), []string{"bytes" + strconv.Itoa(n)})
+	}
+	// log(p0, ..., p3) of every combination of 2 to 4 of these
+	for _, integer := range []string{"uint", "uint256"} {
+		types := []string{integer, "string", "bool", "address"}
+		var combine func(prefix []string)
+		combine = func(prefix []string) {
+			if len(prefix) >= 2 {
+				add("log", prefix)
+			}
+			if len(prefix) == 4 {
+				return
+			}
+			for _, typ := range types {
+				combine(append(append([]string{}, prefix...), typ))
+			}
+		}
+		combine(nil)
+	}
+	return signatures
+}
+
+// HackerConsoleLog is a console.log call of the watched transaction.
+type HackerConsoleLog struct {
+	Step    uint64         `json:"step"`
+	Frame   int            `json:"frame"`
+	Address common.Address `json:"address"`
+	Message string         `json:"message"`
+	// Input is the calldata of a call which could not be decoded
+	Input string `json:"input,omitempty"`
+}
+
+// decodeConsoleLog returns the message logged by input, false when it is not a call to
+// a known log function.
+func decodeConsoleLog(input []byte) (string, bool) {
+	if len(input) < 4 {
+		return "", false
+	}
+	var selector [4]byte
+	copy(selector[:], input[:4])
+	hackerConsoleOnce.Do(func() { hackerConsoleSignatures = consoleSignatures() })
+	types, ok := hackerConsoleSignatures[selector]
+	if !ok {
+		return "", false
+	}
+	data := input[4:]
+	word := func(offset uint64) ([]byte, bool) {
+		if offset+32 < offset || offset+32 > uint64(len(data)) {
+			return nil, false
+		}
+		return data[offset : offset+32], true
+	}
+	args := make([]string, len(types))
+	for i, typ := range types {
+		head, ok := word(uint64(32 * i))
+		if !ok {
+			return "", false
+		}
+		switch {
+		case typ == "uint256":
+			args[i] = new(big.Int).SetBytes(head).Text(10)
+		case typ == "int256":
+			value := new(big.Int).SetBytes(head)
+			if head[0]&0x80 != 0 {
+				value.Sub(value, new(big.Int).Lsh(common.Big1, 256))
+			}
+			args[i] = value.Text(10)
+		case typ == "bool":
+			args[i] = strconv.FormatBool(new(big.Int).SetBytes(head).Sign() != 0)
+		case typ == "address":
+			args[i] = common.BytesToAddress(head).Hex()
+		case typ == "string" || typ == "bytes":
+			offset := new(big.Int).SetBytes(head)
+			size, ok := word(offset.Uint64())
+			if !ok || !offset.IsUint64() {
+				return "", false
+			}
+			length := new(big.Int).SetBytes(size)
+			start := offset.Uint64() + 32
+			if !length.IsUint64() || start+length.Uint64() < start || start+length.Uint64() > uint64(len(data)) {
+				return "", false
+			}
+			content := data[start : start+length.Uint64()]
+			if typ == "string" {
+				args[i] = string(content)
+			} else {
+				args[i] = "0x" + hex.EncodeToString(content)
+			}
+		default:
+			// bytesN, left aligned
+			n, _ := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+			args[i] = "0x" + hex.EncodeToString(head[:n])
+		}
+	}
+	return strings.Join(args, " "), true
+}
+
+// recordConsole records the console.log call op of contract in frame, if it is one.
+func (dog *WatchDog) recordConsole(op OpCode, frame int, contract *Contract, memory *Memory, stack *Stack) {
+	// the arguments of the input after gas and address (and value for CALL)
+	in := 2
+	if op == CALL {
+		in = 3
+	}
+	if stack.len() < in+2 || common.BigToAddress(stack.Back(1)) != hackerConsoleAddress {
+		return
+	}
+	var input []byte
+	if offset, size := stack.Back(in), stack.Back(in+1); size.Sign() != 0 && offset.BitLen() <= 63 && size.BitLen() <= 63 && offset.Int64()+size.Int64() <= int64(memory.Len()) {
+		input = common.CopyBytes(memory.GetPtr(offset.Int64(), size.Int64()))
+	}
+	record := HackerConsoleLog{Step: dog.trace.steps() - 1, Frame: frame, Address: contract.Address()}
+	if message, ok := decodeConsoleLog(input); ok {
+		record.Message = message
+	} else {
+		record.Input = hex.EncodeToString(input)
+	}
+	dog.lock.Lock()
+	dog.console = append(dog.console, record)
+	dog.lock.Unlock()
+}
+
+// consoleLogs returns the console.log calls of the watched transaction.
+func (dog *WatchDog) consoleLogs() []HackerConsoleLog {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	return append(make([]HackerConsoleLog, 0, len(dog.console)), dog.console...)
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeConsoleLog(t *testing.T) {
+	word := func(v int64) []byte {
+		return common.LeftPadBytes(new(big.Int).SetInt64(v).Bytes(), 32)
+	}
+	text := common.RightPadBytes([]byte("balance"), 32)
+	minusOne := common.FromHex("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	tests := []struct {
+		input   []byte
+		message string
+		ok      bool
+	}{
+		// log(string) and log(uint) of console.sol
+		{append(append(common.FromHex("0x41304fac"), word(32)...), append(word(7), text...)...), "balance", true},
+		{append(common.FromHex("0xf5b1bba9"), word(42)...), "42", true},
+		{append(selectorBytes("log(int256)"), minusOne...), "-1", true},
+		{append(append(selectorBytes("log(string,uint256)"), word(64)...), append(word(5), append(word(7), text...)...)...), "balance 5", true},
+		{append(append(selectorBytes("log(bool,address)"), word(1)...), word(0xa1)...), "true " + common.BigToAddress(big.NewInt(0xa1)).Hex(), true},
+		{append(selectorBytes("logBytes2(bytes2)"), common.RightPadBytes([]byte{0xbe, 0xef}, 32)...), "0xbeef", true},
+		{selectorBytes("log()"), "", true},
+		// the string is out of the input
+		{append(append(common.FromHex("0x41304fac"), word(32)...), word(64)...), "", false},
+		{append(selectorBytes("transfer(address,uint256)"), word(1)...), "", false},
+		{[]byte{0x41}, "", false},
+	}
+	for i, test := range tests {
+		if message, ok := decodeConsoleLog(test.input); ok != test.ok || message != test.message {
+			t.Errorf("test %d: decoded %q (%v), want %q (%v)", i, message, ok, test.message, test.ok)
+		}
+	}
+}
+
+func selectorBytes(signature string) []byte {
+	selector := selectorOf(signature)
+	return selector[:]
+}
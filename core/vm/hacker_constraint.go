@@ -0,0 +1,174 @@
+/**
+* @hacker_constraint.go
+* Must-hold constraints: property-based testing over the live bytecode.
+* 1 a constraint names state which must never change: a storage slot of a contract, or
+*   the return data of a view call to it (e.g. owner()).
+* 2 the constrained values are read when the message call of the watched transaction
+*   starts and when it returns, the view calls like the token probes of the profit
+*   oracle (hacker_profit.go). A value which changed is a "constraint_violation"
+*   finding, counted per constraint by the campaign.
+* 3 in negative mode the violations are the output of the campaign: only the reports of
+*   the transactions violating a constraint are sent.
+ */
+package vm
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var errConstraintKind = errors.New("constraint needs either a slot or a call")
+
+// HackerConstraint is state which must not change.
+type HackerConstraint struct {
+	ID      uint64         `json:"id"`
+	Name    string         `json:"name,omitempty"`
+	Address common.Address `json:"address"`
+	// Slot is the storage slot of Address which must not change
+	Slot *common.Hash `json:"slot,omitempty"`
+	// Call is the input of a view call to Address whose return data must not change
+	Call hexutil.Bytes `json:"call,omitempty"`
+	// Violations counts the watched transactions which changed the state
+	Violations uint64 `json:"violations"`
+}
+
+// AddConstraint registers constraint and returns its id.
+func (c *HackerCampaign) AddConstraint(constraint HackerConstraint) (uint64, error) {
+	if (constraint.Slot == nil) == (len(constraint.Call) == 0) {
+		return 0, errConstraintKind
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.constraintSeq++
+	constraint.ID, constraint.Violations = c.constraintSeq, 0
+	c.constraints[constraint.ID] = &constraint
+	return constraint.ID, nil
+}
+
+// RemoveConstraint drops the constraint id.
+func (c *HackerCampaign) RemoveConstraint(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.constraints, id)
+}
+
+// Constraints returns the constraints by id with their violation counts.
+func (c *HackerCampaign) Constraints() []HackerConstraint {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	constraints := make([]HackerConstraint, 0, len(c.constraints))
+	for _, constraint := range c.constraints {
+		constraints = append(constraints, *constraint)
+	}
+	sort.Slice(constraints, func(i, j int) bool { return constraints[i].ID < constraints[j].ID })
+	return constraints
+}
+
+// SetNegativeMode turns the negative mode on or off: when on, only the reports of the
+// transactions violating a constraint are sent.
+func (c *HackerCampaign) SetNegativeMode(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.negative = on
+}
+
+func (c *HackerCampaign) negativeMode() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.negative
+}
+
+// countViolation counts a violation of the constraint id, if still registered.
+func (c *HackerCampaign) countViolation(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if constraint, ok := c.constraints[id]; ok {
+		constraint.Violations++
+	}
+}
+
+// hackerConstraintCheck holds the constrained values around the watched message call.
+type hackerConstraintCheck struct {
+	constraints []HackerConstraint
+	old, new    []string
+}
+
+// checkConstraints returns the check of the constraints of the campaign with the values
+// in the state of evm, nil when there is none.
+func (c *HackerCampaign) checkConstraints(evm *EVM) *hackerConstraintCheck {
+	constraints := c.Constraints()
+	if len(constraints) == 0 {
+		return nil
+	}
+	return &hackerConstraintCheck{constraints: constraints, old: probeConstraints(evm, constraints)}
+}
+
+// probeConstraints returns the values of constraints in the state of evm, which is
+// left as is.
+func probeConstraints(evm *EVM, constraints []HackerConstraint) []string {
+	defer pauseWatching(evm)()
+	values := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		if constraint.Slot != nil {
+			values[i] = evm.StateDB.GetState(constraint.Address, *constraint.Slot).Hex()
+			continue
+		}
+		snapshot := evm.snapshot()
+		ret, _, err := evm.StaticCall(AccountRef(evm.Origin), constraint.Address, constraint.Call, hackerProbeGas)
+		evm.revertToSnapshot(snapshot)
+		if err != nil {
+			values[i] = "error: " + err.Error()
+		} else {
+			values[i] = hexutil.Bytes(ret).String()
+		}
+	}
+	return values
+}
+
+// checkViolations raises a finding per constraint the watched transaction violated.
+func (dog *WatchDog) checkViolations() {
+	check := dog.constraint
+	if check == nil || check.new == nil {
+		return
+	}
+	for i, constraint := range check.constraints {
+		if check.old[i] == check.new[i] {
+			continue
+		}
+		finding := newHackerFinding("constraint_violation", "HackerConstraint")
+		finding.Detail["id"] = strconv.FormatUint(constraint.ID, 10)
+		if constraint.Name != "" {
+			finding.Detail["name"] = constraint.Name
+		}
+		finding.Detail["address"] = constraint.Address.Hex()
+		if constraint.Slot != nil {
+			finding.Detail["slot"] = constraint.Slot.Hex()
+		} else {
+			finding.Detail["call"] = constraint.Call.String()
+		}
+		finding.Detail["old"], finding.Detail["new"] = check.old[i], check.new[i]
+		dog.EmitFinding(finding)
+		GetGlobalCampaign().countViolation(constraint.ID)
+	}
+}
+
+// reportable tells whether the report of the watched transaction is sent: in negative
+// mode, only when it violated a constraint.
+func (dog *WatchDog) reportable() bool {
+	if dog.trace.len() == 0 {
+		return false
+	}
+	if !GetGlobalCampaign().negativeMode() {
+		return true
+	}
+	for _, finding := range dog.findings {
+		if finding.Type == "constraint_violation" {
+			return true
+		}
+	}
+	return false
+}
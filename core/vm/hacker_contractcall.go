@@ -4,6 +4,8 @@
 * 2 while all contract calls triggered by one transaction finish,check oracle status.
 * 3 Write corresponding  info to 0x***-UTime.log in detail
 *    and append this info profile to Oracle.log
+* 4 every EVM records the call tree of its own transaction (hackerCallTree), the
+*    findings of the oracles go to the WatchDogs watching that EVM.
 */
 
 package vm
@@ -17,16 +19,45 @@ import (
 	"runtime"
 	"log"
 	"encoding/json"
-	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
-var hacker_env *EVM
-var hacker_call_stack *HackerContractCallStack
-var hacker_call_hashs []common.Hash
-var hacker_calls []*HackerContractCall
+// hackerCallTree is the call tree recorded for the transaction run by an EVM, from
+// hacker_init to hacker_close. Every EVM records its own.
+type hackerCallTree struct {
+	env   *EVM
+	stack *HackerContractCallStack
+	// hashs and calls list the frames in the order they are made
+	hashs []common.Hash
+	calls []*HackerContractCall
+	// precompiles lists the precompile frames (hacker_precompile.go)
+	precompiles []*HackerContractCall
+	// frames lists the frames taken from the pool for the tree (hacker_pool.go)
+	frames []*HackerContractCall
+}
+
+func newHackerCallTree(evm *EVM) *hackerCallTree {
+	return &hackerCallTree{
+		env:         evm,
+		stack:       newHackerContractCallStack(),
+		hashs:       make([]common.Hash, 0, 0),
+		calls:       make([]*HackerContractCall, 0, 0),
+		precompiles: make([]*HackerContractCall, 0, 0),
+	}
+}
+
+// state snapshots the accounts addrs in the state of the tree.
+func (tree *hackerCallTree) state(addrs ...common.Address) *HackerState {
+	return newHackerState(tree.env.StateDB, addrs...)
+}
+
+// record lists the frame call, made in the tree.
+func (tree *hackerCallTree) record(call *HackerContractCall) {
+	var util HackerUtils
+	tree.hashs = append(tree.hashs, util.Hash(call))
+	tree.calls = append(tree.calls, call)
+}
 
 type HackerContractCall struct {
 	isInitCall     bool
@@ -42,8 +73,17 @@ type HackerContractCall struct {
 	throwException  bool
 	errOutGas       bool
 	errOutBalance   bool
+	errKind         HackerFrameError
+	isPrecompile    bool
+	output          []byte
+	// depth is the call depth of the frame, 0 for the frame of the transaction
+	depth           int
+	// readOnly is set for frames running in a static context
+	readOnly        bool
 	snapshotId      int
 	nextRevisionId  int
+	// tree is the call tree the frame belongs to
+	tree            *hackerCallTree
 }
 func CallsPointerToString(calls []*HackerContractCall) string{
 	if len(calls)== 0{
@@ -130,20 +170,12 @@ func (call *HackerContractCall) Write(writer io.Writer) {
 			call.StateStack)
 	writer.Write([]byte(Data))
 }
-func newHackerContractCall(operation string, caller, callee common.Address,
+func newHackerContractCall(tree *hackerCallTree, operation string, caller, callee common.Address,
 	value, gas big.Int, _input []byte) *HackerContractCall {
-	_operationStack := newHackerOperationStack()
-	_operationStack.push(operation)
-
-	_stateStack := newHackerStateStack()
-	initState := newHackerState(caller, callee)
-	_stateStack.push(initState)
-	nextcalls := make([]*HackerContractCall, 0)
-	input := make([]byte, len(_input))
-	copy(input, _input)
-
-	return &HackerContractCall{isInitCall:false,caller: caller, callee: callee, value: value, gas: gas, input: input,
-		OperationStack: _operationStack, StateStack: _stateStack, nextcalls: nextcalls,throwException:false,errOutGas:false,errOutBalance:false}
+	call := tree.acquire()
+	call.tree = tree
+	call.init(operation, caller, callee, value, gas, _input)
+	return call
 }
 
 func (call *HackerContractCall) isAncestor(callA *HackerContractCall) (bool){
@@ -161,8 +193,8 @@ func (call *HackerContractCall) isAncestor(callA *HackerContractCall) (bool){
 }
 func (call *HackerContractCall) findFather(index int) *HackerContractCall{
 	for i:= index-1;i>=0;i--{
-		if hacker_calls[i].isAncestor(call) {
-			return hacker_calls[i]
+		if call.tree.calls[i].isAncestor(call) {
+			return call.tree.calls[i]
 		}
 	}
 	return nil
@@ -178,144 +210,138 @@ func (call *HackerContractCall) isBrother(callindex int ,callA *HackerContractCa
 func (call *HackerContractCall) OnCall(_caller ContractRef, _callee common.Address, _value, _gas big.Int,
 	_input []byte) *HackerContractCall {
 	call.OperationStack.push(opCodeToString[CALL])
-	call.StateStack.push(newHackerState(_caller.Address(), _callee))
-	nextcall := newHackerContractCall(opCodeToString[CALL], _caller.Address(), _callee, _value, _gas, _input)
+	call.StateStack.push(call.tree.state(_caller.Address(), _callee))
+	nextcall := newHackerContractCall(call.tree, opCodeToString[CALL], _caller.Address(), _callee, _value, _gas, _input)
 	call.nextcalls = append(call.nextcalls, nextcall)
-	
-	var util HackerUtils
-	hash := util.Hash(nextcall)
-	hacker_call_hashs= append(hacker_call_hashs,hash)
-	hacker_calls = append(hacker_calls,nextcall)
-	
+	call.tree.record(nextcall)
 	return nextcall
 }
 func (call *HackerContractCall) OnDelegateCall(_caller ContractRef, _callee common.Address, _gas big.Int,
 	_input []byte) *HackerContractCall {
 	call.OperationStack.push(opCodeToString[DELEGATECALL])
-	call.StateStack.push(newHackerState(_caller.Address(), _callee))
-	nextcall := newHackerContractCall(opCodeToString[DELEGATECALL], _caller.Address(), _callee, *new(big.Int).SetUint64(0), _gas, _input)
+	call.StateStack.push(call.tree.state(_caller.Address(), _callee))
+	nextcall := newHackerContractCall(call.tree, opCodeToString[DELEGATECALL], _caller.Address(), _callee, *new(big.Int).SetUint64(0), _gas, _input)
 	call.nextcalls = append(call.nextcalls, nextcall)
-	
-	var util HackerUtils
-	hash := util.Hash(nextcall)
-	hacker_call_hashs= append(hacker_call_hashs,hash)
-	hacker_calls = append(hacker_calls,nextcall)
-	
+	call.tree.record(nextcall)
 	return nextcall
 }
 func (call *HackerContractCall) OnCallCode(_caller ContractRef, _callee common.Address,  _value,_gas big.Int,
 	_input []byte) *HackerContractCall {
 	call.OperationStack.push(opCodeToString[CALLCODE])
-	call.StateStack.push(newHackerState(_caller.Address(), _callee))
-	nextcall := newHackerContractCall(opCodeToString[CALLCODE], _caller.Address(), _callee, _value, _gas, _input)
+	call.StateStack.push(call.tree.state(_caller.Address(), _callee))
+	nextcall := newHackerContractCall(call.tree, opCodeToString[CALLCODE], _caller.Address(), _callee, _value, _gas, _input)
 	call.nextcalls = append(call.nextcalls, nextcall)
-	
-	var util HackerUtils
-	hash := util.Hash(nextcall)
-	hacker_call_hashs= append(hacker_call_hashs,hash)
-	hacker_calls = append(hacker_calls,nextcall)
-	
+	call.tree.record(nextcall)
+	return nextcall
+}
+func (call *HackerContractCall) OnStaticCall(_caller ContractRef, _callee common.Address, _gas big.Int,
+	_input []byte) *HackerContractCall {
+	call.OperationStack.push(opCodeToString[STATICCALL])
+	call.StateStack.push(call.tree.state(_caller.Address(), _callee))
+	nextcall := newHackerContractCall(call.tree, opCodeToString[STATICCALL], _caller.Address(), _callee, *new(big.Int).SetUint64(0), _gas, _input)
+	call.nextcalls = append(call.nextcalls, nextcall)
+	call.tree.record(nextcall)
 	return nextcall
 }
 func (call *HackerContractCall) OnCloseCall(finalgas big.Int) {
 	call.finalgas = finalgas
 	//fmt.Println("CloseCall..")
 	call.OperationStack.push(opCodeToString[RETURN])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 	fmt.Printf("\ncall@%pClosed",call)
 	
 	//call.Write(hacker_writer)
 }
 func (call *HackerContractCall) OnBlockHash() {
 	call.OperationStack.push(opCodeToString[BLOCKHASH])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnGas() {
 	call.OperationStack.push(opCodeToString[BLOCKHASH])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnTimestamp() {
 	call.OperationStack.push(opCodeToString[TIMESTAMP])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnRelationOp(relation OpCode) {
 	call.OperationStack.push(opCodeToString[relation])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnSha3() {
 	call.OperationStack.push(opCodeToString[SHA3])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnCreate() {
 	call.OperationStack.push(opCodeToString[CREATE])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnAddress() {
 	call.OperationStack.push(opCodeToString[ADDRESS])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnOrigin() {
 	call.OperationStack.push(opCodeToString[ADDRESS])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnCaller() {
 	call.OperationStack.push(opCodeToString[CALLER])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnDiv() {
 	call.OperationStack.push(opCodeToString[DIV])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnBalance() {
 	call.OperationStack.push(opCodeToString[BALANCE])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnCallValue() {
 	call.OperationStack.push(opCodeToString[CALLVALUE])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnCalldataLoad() {
 	call.OperationStack.push(opCodeToString[CALLDATALOAD])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 //Memory,Storage operation
 func (call *HackerContractCall) OnMload() {
 	call.OperationStack.push(opCodeToString[MLOAD])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnMstore() {
 	call.OperationStack.push(opCodeToString[MSTORE])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnSload() {
 	call.OperationStack.push(opCodeToString[SLOAD])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnSstore() {
 	call.OperationStack.push(opCodeToString[SSTORE])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 //Jump statement, Jump to existing function position, or Jump to the invalid to invoke a error throw.
 func (call *HackerContractCall) OnJumpi() {
 	call.OperationStack.push(opCodeToString[JUMPI])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnJump() {
 	call.OperationStack.push(opCodeToString[JUMP])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnSuicide() {
 	call.OperationStack.push(opCodeToString[SELFDESTRUCT])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 
 func (call *HackerContractCall) OnNumber() {
 	call.OperationStack.push(opCodeToString[NUMBER])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 func (call *HackerContractCall) OnReturn() {
 	call.OperationStack.push(opCodeToString[RETURN])
-	call.StateStack.push(newHackerState(call.caller, call.callee))
+	call.StateStack.push(call.tree.state(call.caller, call.callee))
 }
 type HackerContractCallStack struct {
 	data []*HackerContractCall
@@ -388,36 +414,39 @@ func hacker_init(evm *EVM, contract *Contract, input []byte) {
 			Println(err) // 这里的err其实就是panic传入的内容，55
 		}
 	}()
-	if hacker_env == nil || hacker_call_stack == nil {
-		hacker_env = evm
-		hacker_call_stack = newHackerContractCallStack()
-		hacker_call_hashs = make([]common.Hash,0,0)
-		hacker_calls = make([]*HackerContractCall,0,0)
-		initCall := newHackerContractCall("STARTRECORD", contract.Caller(), contract.Address(), *contract.Value(), *new(big.Int).SetUint64(contract.Gas), contract.Input)
+	if evm.callTree == nil {
+		tree := newHackerCallTree(evm)
+		initCall := newHackerContractCall(tree, "STARTRECORD", contract.Caller(), contract.Address(), *contract.Value(), *new(big.Int).SetUint64(contract.Gas), contract.Input)
 		initCall.isInitCall = true
-		hacker_call_stack.push(initCall)
+		tree.stack.push(initCall)
+		evm.callTree = tree
 	}
 
 }
-const (
-    MaxIdleConnections int = 50
-    RequestTimeout     int = 5
-)
+// hackerOracleURL is the endpoint of the FuzzerReporter the oracles and the profile of
+// each top-level call are sent to, empty disables it.
+var hackerOracleURL = "http://localhost:8888/hack"
 
-var Client = &http.Client{
-	Transport: &http.Transport{
-		MaxIdleConnsPerHost: MaxIdleConnections,
-	},
-	Timeout: time.Duration(RequestTimeout) * time.Second,
+// SetOracleURL sets the endpoint of the FuzzerReporter, empty stops sending to it.
+func SetOracleURL(url string) {
+	hackerOracleURL = url
 }
 
-// var Client = http.Client{Transport:&transport}
-func hacker_close() {
+// hackerInformational tells the oracles describing the transaction rather than a
+// vulnerability, they are reported as features and never as findings.
+func hackerInformational(oracle Oracle) bool {
+	switch oracle.(type) {
+	case *HackerCallOpInfo, *HackerSendOpInfo, *HackerStorageChanged, *HackerEtherTransfer,
+		*HackerTimestampOp, *HackerNumberOp, *HackerBalanceGtZero:
+		return true
+	}
+	return false
+}
+
+func hacker_close(tree *hackerCallTree) {
 	defer func() { // 必须要先声明defer，否则不能捕获到panic异常
-	    hacker_env = nil
-		hacker_call_stack = nil
-		hacker_call_hashs = nil
-		hacker_calls = nil
+		tree.env.callTree = nil
+		tree.release()
 		Println("hacker_closed!")
 		if err := recover(); err != nil {
 			Println(err) // 这里的err其实就是panic传入的内容，55
@@ -430,25 +459,25 @@ func hacker_close() {
 		}
 	
 	}()
-	if hacker_env != nil || hacker_call_stack != nil {
+	if tree.stack != nil {
 		Println("hacker_close...")
 
-		for ;hacker_call_stack.len()>0;{
-			call:= hacker_call_stack.pop()
+		for ;tree.stack.len()>0;{
+			call:= tree.stack.pop()
 			//contract = call.callee
 			call.OnCloseCall(*new(big.Int).SetUint64(0))
 		}
 		//The default Agent Contract's Address:"0xe930e50b62af818dbc955f345f9a3a3108f7a70d" 
 		//the contract could help us to exploit the underlying bugs such as reentrancy, or exception disorder check bug.
-		if strings.EqualFold(strings.TrimSpace(strings.ToLower(hacker_calls[0].callee.Hex())),strings.TrimSpace("0xe930e50b62af818dbc955f345f9a3a3108f7a70d")){
+		if strings.EqualFold(strings.TrimSpace(strings.ToLower(tree.calls[0].callee.Hex())),strings.TrimSpace("0xe930e50b62af818dbc955f345f9a3a3108f7a70d")){
 		    var root int
-			for root = 1;root<len(hacker_calls);root++{
-				if IsAccountAddress(hacker_calls[root].callee){
+			for root = 1;root<len(tree.calls);root++{
+				if IsAccountAddress(tree.calls[root].callee){
 					break
 				}
 			}
-		   hacker_call_hashs  = hacker_call_hashs[root:]
-		   hacker_calls = hacker_calls[root:]
+		   tree.hashs = tree.hashs[root:]
+		   tree.calls = tree.calls[root:]
 		}
 		//Set check oracles 
 		//with hacker_call_hashs,and hacker_calls as input,different test oracles are checked
@@ -473,24 +502,37 @@ func hacker_close() {
         oracles = append(oracles,NewHackerBalanceGtZero())
         features := make([]string,0,0)
  		for _,oracle := range  oracles{
-			oracle.InitOracle(hacker_call_hashs,hacker_calls)
+			oracle.InitOracle(tree.hashs,tree.calls)
 			if true == oracle.TestOracle(){
 				features = append(features,oracle.String())
+				if hackerInformational(oracle) {
+					continue
+				}
+				for _, dog := range hackerDogs(tree.env) {
+					if dog.watches(tree.env) {
+						dog.EmitFinding(newHackerFinding(oracle.String(),"hacker_close"))
+					}
+				}
 			}
 		}
 		
+		// the oracle plugins check the tree when the transaction ends (hacker_plugin.go)
+		if len(hacker_plugins) > 0 {
+			view := &hackerPluginView{frames: hackerFrameViews(tree.calls), precompiles: hackerPrecompileViews(tree.precompiles)}
+			for _, dog := range hackerDogs(tree.env) {
+				if dog.watches(tree.env) {
+					dog.pluginView = view
+				}
+			}
+		}
+
 		// Send the oracle and profile reports from one transaction or one contract call more precisely.
 		// to FuzzerReporter outside, whose listening port is on "http://localhost:8888/hack"
 		features_str,_:= json.Marshal(features)
-		values := url.Values{"oracles":{string(features_str)},"profile":{GetReportor().Profile(hacker_call_hashs,hacker_calls)}}
-		url := "http://localhost:8888/hack?"+values.Encode()
-		if req, err := http.NewRequest("GET", url,nil);err!= nil {
-       	 	log.Println("Error Occured. %+v", err)
-		}else{
-			if response,err := Client.Do(req);err!=nil{
+		values := url.Values{"oracles":{string(features_str)},"profile":{new(HackerCallInfoReportor).Profile(tree.hashs,tree.calls)}}
+		if hackerOracleURL != "" && currentTransport() != nil {
+			if err := hackerGet(hackerOracleURL+"?"+values.Encode()); err != nil {
 				log.Println("Error sending request to API endpoint. %+v", err)
-			}else{
-				defer response.Body.Close()
 			}
 		}
 	}
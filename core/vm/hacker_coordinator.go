@@ -0,0 +1,265 @@
+/**
+* @hacker_coordinator.go
+* Campaigns spread over several nodes, synchronised through a coordinator.
+* 1 the corpus of the campaign holds the inputs of the watched transactions that covered
+*   new edges (hacker_coverage.go), once per target and input.
+* 2 every few blocks the node posts to the coordinator (POST <url>/sync) the digests of
+*   all its coverage bitmaps, the bitmaps changed since the last synchronisation and the
+*   corpus entries it found since. The coordinator answers with the bitmaps whose merged
+*   digest differs from the one of the node and the corpus entries of the other nodes.
+* 3 the bitmaps received are merged into the campaign, the edges covered by other nodes
+*   are not new any more; the corpus entries received are added with the node they come
+*   from, the fuzzer reads them with Corpus instead of rediscovering them.
+* A synchronisation runs in the background at block end, one at a time; a failed one is
+* logged and retried with the same content at the next.
+ */
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// hackerCoordinatorTimeout bounds a synchronisation with the coordinator.
+const hackerCoordinatorTimeout = 10 * time.Second
+
+// HackerCorpusEntry is an input that covered new edges of its target.
+type HackerCorpusEntry struct {
+	Target common.Address `json:"target"`
+	Input  hexutil.Bytes  `json:"input"`
+	// Edges is the number of edges that were new when the input ran
+	Edges int `json:"edges"`
+	// Node is the node that found the input, empty for this one
+	Node string `json:"node,omitempty"`
+}
+
+func (entry *HackerCorpusEntry) key() common.Hash {
+	return crypto.Keccak256Hash(entry.Target[:], entry.Input)
+}
+
+// HackerCoverageDigest identifies the coverage bitmap of a code.
+type HackerCoverageDigest struct {
+	CodeHash common.Hash `json:"codeHash"`
+	Digest   common.Hash `json:"digest"`
+}
+
+// HackerCoordinatorSync is what a node posts to the coordinator.
+type HackerCoordinatorSync struct {
+	Node     string                 `json:"node"`
+	Digests  []HackerCoverageDigest `json:"digests"`
+	Coverage []HackerCoverageMap    `json:"coverage"`
+	Corpus   []HackerCorpusEntry    `json:"corpus"`
+}
+
+// HackerCoordinatorUpdate is the answer of the coordinator.
+type HackerCoordinatorUpdate struct {
+	Coverage []HackerCoverageMap `json:"coverage"`
+	Corpus   []HackerCorpusEntry `json:"corpus"`
+}
+
+// HackerSyncResult tells what a synchronisation exchanged.
+type HackerSyncResult struct {
+	SentMaps   int `json:"sentMaps"`
+	SentCorpus int `json:"sentCorpus"`
+	// Edges is the number of edges covered by other nodes only
+	Edges  int `json:"edges"`
+	Corpus int `json:"corpus"`
+}
+
+func coverageDigest(bitmap []byte) common.Hash {
+	return crypto.Keccak256Hash(bitmap)
+}
+
+// addCorpus adds entry unless its input is already in the corpus, c.lock is held.
+func (c *HackerCampaign) addCorpus(entry HackerCorpusEntry) bool {
+	key := entry.key()
+	if c.corpusKeys[key] {
+		return false
+	}
+	c.corpusKeys[key] = true
+	c.corpus = append(c.corpus, entry)
+	return true
+}
+
+// recordCorpus keeps the input of the watched transaction when it covered new edges.
+func (dog *WatchDog) recordCorpus() {
+	if dog.coverage.New == 0 {
+		return
+	}
+	c := GetGlobalCampaign()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.addCorpus(HackerCorpusEntry{Target: *dog.tx.To(), Input: common.CopyBytes(dog.tx.Data()), Edges: dog.coverage.New})
+}
+
+// Corpus returns the corpus entries of target, all of them for the zero address.
+func (c *HackerCampaign) Corpus(target common.Address) []HackerCorpusEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entries := make([]HackerCorpusEntry, 0)
+	for _, entry := range c.corpus {
+		if target == (common.Address{}) || entry.Target == target {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// pendingSync returns what node has to send to the coordinator, with the length of the
+// corpus it covers.
+func (c *HackerCampaign) pendingSync(node string) (*HackerCoordinatorSync, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	request := &HackerCoordinatorSync{Node: node, Digests: make([]HackerCoverageDigest, 0), Coverage: make([]HackerCoverageMap, 0), Corpus: make([]HackerCorpusEntry, 0)}
+	for hash, bitmap := range c.coverage {
+		digest := coverageDigest(bitmap)
+		request.Digests = append(request.Digests, HackerCoverageDigest{CodeHash: hash, Digest: digest})
+		if c.synced[hash] != digest {
+			request.Coverage = append(request.Coverage, HackerCoverageMap{CodeHash: hash, Bitmap: common.CopyBytes(bitmap)})
+		}
+	}
+	for _, entry := range c.corpus[c.corpusSent:] {
+		// the entries of the other nodes came from the coordinator
+		if entry.Node == "" {
+			entry.Node = node
+			request.Corpus = append(request.Corpus, entry)
+		}
+	}
+	return request, len(c.corpus)
+}
+
+// applySync records that request was sent, the corpus up to sent, and merges update.
+func (c *HackerCampaign) applySync(request *HackerCoordinatorSync, sent int, update *HackerCoordinatorUpdate) *HackerSyncResult {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := &HackerSyncResult{SentMaps: len(request.Coverage), SentCorpus: len(request.Corpus)}
+	for _, coverage := range request.Coverage {
+		c.synced[coverage.CodeHash] = coverageDigest(coverage.Bitmap)
+	}
+	c.corpusSent = sent
+	for _, coverage := range update.Coverage {
+		before := hackerBitCount(c.coverage[coverage.CodeHash])
+		c.restoreCoverage([]HackerCoverageMap{coverage})
+		result.Edges += hackerBitCount(c.coverage[coverage.CodeHash]) - before
+		// the coordinator holds this bitmap, edges covered since are still sent
+		c.synced[coverage.CodeHash] = coverageDigest(coverage.Bitmap)
+	}
+	for _, entry := range update.Corpus {
+		if entry.Node != request.Node && c.addCorpus(entry) {
+			result.Corpus++
+		}
+	}
+	return result
+}
+
+func hackerBitCount(bitmap []byte) int {
+	count := 0
+	for _, b := range bitmap {
+		for ; b != 0; b &= b - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// HackerCoordinator synchronises the campaign with the coordinator of a distributed
+// campaign every few blocks.
+type HackerCoordinator struct {
+	url    string
+	node   string
+	blocks uint64
+	// seen counts the blocks ended, syncing is set while a synchronisation runs
+	seen    uint64
+	syncing int32
+	pending sync.WaitGroup
+}
+
+var (
+	coordinatorLock sync.Mutex
+	coordinator     *HackerCoordinator
+)
+
+// StartCoordinator synchronises the campaign as node with the coordinator at url every
+// blocks blocks, replacing the coordinator started before. An empty url stops it.
+func StartCoordinator(url string, node string, blocks uint64) *HackerCoordinator {
+	coordinatorLock.Lock()
+	defer coordinatorLock.Unlock()
+	if coordinator != nil {
+		coordinator.stop()
+		coordinator = nil
+	}
+	if url == "" {
+		return nil
+	}
+	if blocks == 0 {
+		blocks = 1
+	}
+	coordinator = &HackerCoordinator{url: url, node: node, blocks: blocks}
+	RegisterBlockListener(coordinator)
+	return coordinator
+}
+
+// GetCoordinator returns the coordinator started, nil if there is none.
+func GetCoordinator() *HackerCoordinator {
+	coordinatorLock.Lock()
+	defer coordinatorLock.Unlock()
+	return coordinator
+}
+
+// stop unregisters the coordinator and waits for the synchronisation running.
+func (coord *HackerCoordinator) stop() {
+	UnregisterBlockListener(coord)
+	coord.pending.Wait()
+}
+
+func (coord *HackerCoordinator) OnBlockStart(header *types.Header) {}
+
+// OnBlockEnd starts a synchronisation every coord.blocks blocks, unless one is running.
+func (coord *HackerCoordinator) OnBlockEnd(header *types.Header, receipts types.Receipts) {
+	if atomic.AddUint64(&coord.seen, 1)%coord.blocks != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&coord.syncing, 0, 1) {
+		return
+	}
+	coord.pending.Add(1)
+	go func() {
+		defer coord.pending.Done()
+		defer atomic.StoreInt32(&coord.syncing, 0)
+		if _, err := coord.Sync(); err != nil {
+			log.Printf("coordinator sync: %v", err)
+		}
+	}()
+}
+
+// Sync synchronises the campaign with the coordinator now.
+func (coord *HackerCoordinator) Sync() (*HackerSyncResult, error) {
+	c := GetGlobalCampaign()
+	request, sent := c.pendingSync(coord.node)
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := hackerPost(coord.url+"/sync", "application/json", bytes.NewReader(data), hackerCoordinatorTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if status != hackerStatusOK {
+		return nil, fmt.Errorf("coordinator: status %d", status)
+	}
+	update := new(HackerCoordinatorUpdate)
+	if err := json.Unmarshal(body, update); err != nil {
+		return nil, fmt.Errorf("coordinator: %v", err)
+	}
+	return c.applySync(request, sent, update), nil
+}
@@ -0,0 +1,151 @@
+/**
+* @hacker_coverage.go
+* Branch coverage of the campaign, kept across node restarts.
+* 1 the branches of a code are numbered by the analysis (hacker_analysis.go), a JUMPI
+*   covers the edge 2*index when it falls through and 2*index+1 when it jumps, a JUMP
+*   always covers 2*index+1. The edges of the watched transaction are recorded per code
+*   hash as it runs.
+* 2 at the end of the transaction its edges are merged into the bitmaps of the campaign,
+*   the report tells how many edges it covered and how many of them were new
+*   ("coverage"), the fuzzer keeps the inputs with new edges.
+* 3 the bitmaps and the rest of the campaign (hacker_shutdown.go) are stored in the
+*   database of the node (SaveTo) and read back when it starts again (LoadFrom): the
+*   edges covered before the restart are not new again.
+ */
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// hackerCampaignKey is the database key of the campaign.
+var hackerCampaignKey = []byte("hacker-campaign")
+
+// HackerDatabase is the part of the database of the node the campaign is stored in.
+type HackerDatabase interface {
+	Put(key []byte, value []byte) error
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+}
+
+type hackerCoverageEdge struct {
+	codeHash common.Hash
+	edge     uint32
+}
+
+// HackerCoverageStat is the "coverage" section of the WatchDog report.
+type HackerCoverageStat struct {
+	Edges int `json:"edges"`
+	New   int `json:"new"`
+}
+
+// HackerCoverageMap is the coverage bitmap of a code, bit i set when the edge i was
+// covered.
+type HackerCoverageMap struct {
+	CodeHash common.Hash   `json:"codeHash"`
+	Bitmap   hexutil.Bytes `json:"bitmap"`
+	// Edges is the number of bits set
+	Edges int `json:"edges"`
+}
+
+// recordBranch records the edge op covers in contract, before it executes.
+func (dog *WatchDog) recordBranch(pc uint64, op OpCode, contract *Contract, stack *Stack) {
+	if contract.CodeHash == (common.Hash{}) || dog.edges == nil {
+		return
+	}
+	index, ok := analysisCache.get(contract.CodeHash, contract.Code).branches[pc]
+	if !ok {
+		return
+	}
+	edge := 2*index + 1
+	if op == JUMPI && stack.Back(1).Sign() == 0 {
+		edge = 2 * index
+	}
+	dog.edges[hackerCoverageEdge{contract.CodeHash, edge}] = struct{}{}
+}
+
+// addCoverage merges edges into the bitmaps and returns how many were new.
+func (c *HackerCampaign) addCoverage(edges map[hackerCoverageEdge]struct{}) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	added := 0
+	for edge := range edges {
+		bitmap := c.coverage[edge.codeHash]
+		if need := int(edge.edge/8) + 1; len(bitmap) < need {
+			bitmap = append(bitmap, make([]byte, need-len(bitmap))...)
+			c.coverage[edge.codeHash] = bitmap
+		}
+		if bit := byte(1) << (edge.edge % 8); bitmap[edge.edge/8]&bit == 0 {
+			bitmap[edge.edge/8] |= bit
+			added++
+		}
+	}
+	return added
+}
+
+// Coverage returns the coverage bitmaps of the campaign, sorted by code hash.
+func (c *HackerCampaign) Coverage() []HackerCoverageMap {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	maps := make([]HackerCoverageMap, 0, len(c.coverage))
+	for hash, bitmap := range c.coverage {
+		maps = append(maps, HackerCoverageMap{CodeHash: hash, Bitmap: common.CopyBytes(bitmap), Edges: hackerBitCount(bitmap)})
+	}
+	sort.Slice(maps, func(i, j int) bool {
+		return bytes.Compare(maps[i].CodeHash[:], maps[j].CodeHash[:]) < 0
+	})
+	return maps
+}
+
+// restoreCoverage adds the bitmaps of maps to the campaign.
+func (c *HackerCampaign) restoreCoverage(maps []HackerCoverageMap) {
+	for _, coverage := range maps {
+		bitmap := c.coverage[coverage.CodeHash]
+		if len(bitmap) < len(coverage.Bitmap) {
+			bitmap = append(bitmap, make([]byte, len(coverage.Bitmap)-len(bitmap))...)
+		}
+		for i, b := range coverage.Bitmap {
+			bitmap[i] |= b
+		}
+		c.coverage[coverage.CodeHash] = bitmap
+	}
+}
+
+// recordCoverage merges the edges of the watched transaction, for the report.
+func (dog *WatchDog) recordCoverage() {
+	dog.coverage = &HackerCoverageStat{Edges: len(dog.edges), New: GetGlobalCampaign().addCoverage(dog.edges)}
+	dog.recordCorpus()
+	dog.checkSaturation()
+}
+
+// SaveTo stores the campaign in the database of the node.
+func (c *HackerCampaign) SaveTo(db HackerDatabase) error {
+	data, err := json.Marshal(c.State())
+	if err != nil {
+		return err
+	}
+	return db.Put(hackerCampaignKey, data)
+}
+
+// LoadFrom adds the campaign stored in the database of the node, if there is one.
+func (c *HackerCampaign) LoadFrom(db HackerDatabase) error {
+	if ok, err := db.Has(hackerCampaignKey); err != nil || !ok {
+		return err
+	}
+	data, err := db.Get(hackerCampaignKey)
+	if err != nil {
+		return err
+	}
+	state := new(HackerCampaignState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("stored campaign: %v", err)
+	}
+	c.Restore(state)
+	return nil
+}
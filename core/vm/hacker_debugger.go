@@ -0,0 +1,269 @@
+/**
+* @hacker_debugger.go
+* Breakpoints and interactive debugging of the watched executions.
+* 1 breakpoints are set on a pc of a code, by code hash (any code for the zero hash), or
+*   on an opcode. A watched execution reaching one takes a snapshot of the machine state
+*   before the operation executes (code, pc, operation, gas, stack, memory and the
+*   storage visible to the contract) into its report ("breakpoints"), at most
+*   hackerBreakpointHits of them.
+* 2 in interactive mode it also pauses: the interpreter blocks in Hacker_record until
+*   it is resumed. The paused executions are listed with their snapshot by Paused and
+*   the storage of their contract is read with PausedStorage; nothing else runs on their
+*   state in the meantime.
+* 3 Resume continues to the next breakpoint, or pauses again at the next operation when
+*   stepping. ResumeAll releases every paused execution, e.g. at shutdown or when the
+*   interactive mode is turned off; clearing the breakpoints does not.
+* The executions not watched never break.
+ */
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var errNotPaused = errors.New("no execution paused with this id")
+
+// hackerBreakpointHits is the number of snapshots kept per watched transaction.
+const hackerBreakpointHits = 64
+
+type hackerBreakpoint struct {
+	codeHash common.Hash
+	pc       uint64
+}
+
+// HackerMachineState is a snapshot of a frame before an operation.
+type HackerMachineState struct {
+	CodeHash common.Hash    `json:"codeHash"`
+	Address  common.Address `json:"address"`
+	Pc       uint64         `json:"pc"`
+	Op       string         `json:"op"`
+	Depth    int            `json:"depth"`
+	Gas      uint64         `json:"gas"`
+	// Stack is the stack of the frame, its top last
+	Stack  []*hexutil.Big `json:"stack"`
+	Memory hexutil.Bytes  `json:"memory"`
+	// Storage holds the slots of the contract stored or written by the transaction
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// HackerPausedState is a watched execution paused before an operation.
+type HackerPausedState struct {
+	ID   uint64      `json:"id"`
+	Hash common.Hash `json:"hash"`
+	HackerMachineState
+}
+
+type hackerPaused struct {
+	state  HackerPausedState
+	evm    *EVM
+	resume chan bool
+}
+
+var (
+	debuggerLock sync.Mutex
+	breakpoints  = make(map[hackerBreakpoint]struct{})
+	opBreaks     = make(map[OpCode]struct{})
+	interactive  bool
+	paused       = make(map[uint64]*hackerPaused)
+	// stepping holds the EVMs to pause at their next operation
+	stepping = make(map[*EVM]bool)
+	pauseID  uint64
+	// breakCount is the number of breakpoints and EVMs stepping, read on every operation
+	breakCount int32
+)
+
+func countBreaks() {
+	atomic.StoreInt32(&breakCount, int32(len(breakpoints)+len(opBreaks)+len(stepping)))
+}
+
+// SetBreakpoint breaks the watched executions before the operation at pc of the code
+// codeHash, of any code for the zero hash.
+func SetBreakpoint(codeHash common.Hash, pc uint64) {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	breakpoints[hackerBreakpoint{codeHash, pc}] = struct{}{}
+	countBreaks()
+}
+
+// SetInteractive turns the interactive mode on or off, the executions paused are
+// resumed when it is turned off.
+func SetInteractive(on bool) {
+	debuggerLock.Lock()
+	interactive = on
+	debuggerLock.Unlock()
+	if !on {
+		ResumeAll()
+	}
+}
+
+// SetOpBreakpoint breaks the watched executions before every operation op.
+func SetOpBreakpoint(op string) error {
+	code := StringToOp(op)
+	if code.String() != op {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	opBreaks[code] = struct{}{}
+	countBreaks()
+	return nil
+}
+
+// ClearBreakpoints removes the breakpoints, the paused executions stay paused.
+func ClearBreakpoints() {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	breakpoints = make(map[hackerBreakpoint]struct{})
+	opBreaks = make(map[OpCode]struct{})
+	countBreaks()
+}
+
+// Paused returns the executions paused, by id.
+func Paused() []HackerPausedState {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	states := make([]HackerPausedState, 0, len(paused))
+	for _, execution := range paused {
+		states = append(states, execution.state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states
+}
+
+// PausedStorage returns the slot of the contract of the execution paused as id.
+func PausedStorage(id uint64, slot common.Hash) (common.Hash, error) {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	execution, ok := paused[id]
+	if !ok {
+		return common.Hash{}, errNotPaused
+	}
+	return execution.evm.StateDB.GetState(execution.state.Address, slot), nil
+}
+
+// Resume continues the execution paused as id, up to its next operation when step is
+// set.
+func Resume(id uint64, step bool) error {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	execution, ok := paused[id]
+	if !ok {
+		return errNotPaused
+	}
+	delete(paused, id)
+	execution.resume <- step
+	return nil
+}
+
+// ResumeAll continues every paused execution.
+func ResumeAll() {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	for id, execution := range paused {
+		delete(paused, id)
+		execution.resume <- false
+	}
+}
+
+// stopStepping forgets that evm was stepping, once its watched transaction ended.
+func stopStepping(evm *EVM) {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	if stepping[evm] {
+		delete(stepping, evm)
+		countBreaks()
+	}
+}
+
+// snapshot returns the machine state of contract before the operation op at pc.
+func (dog *WatchDog) snapshot(evm *EVM, pc uint64, op OpCode, contract *Contract, memory *Memory, stack *Stack) HackerMachineState {
+	state := HackerMachineState{
+		CodeHash: contract.CodeHash, Address: contract.Address(), Pc: pc, Op: op.String(), Depth: evm.depth, Gas: contract.Gas,
+		Stack: make([]*hexutil.Big, 0, stack.len()), Memory: common.CopyBytes(memory.Data()), Storage: make(map[common.Hash]common.Hash),
+	}
+	for _, item := range stack.Data() {
+		state.Stack = append(state.Stack, (*hexutil.Big)(new(big.Int).Set(item)))
+	}
+	evm.StateDB.ForEachStorage(state.Address, func(key, value common.Hash) bool {
+		state.Storage[key] = common.Hash{}
+		return true
+	})
+	if state.Address == *dog.tx.To() {
+		dog.lock.Lock()
+		dog.writes.each(func(write *hackerStorageWrite) {
+			state.Storage[write.slot] = common.Hash{}
+		})
+		dog.lock.Unlock()
+	}
+	for key := range state.Storage {
+		state.Storage[key] = evm.StateDB.GetState(state.Address, key)
+	}
+	return state
+}
+
+// checkBreakpoint takes a snapshot of the watched execution of evm when the operation
+// op at pc of contract has a breakpoint and, in interactive mode, pauses it until it is
+// resumed.
+func checkBreakpoint(evm *EVM, pc uint64, op OpCode, contract *Contract, memory *Memory, stack *Stack) {
+	if atomic.LoadInt32(&breakCount) == 0 {
+		return
+	}
+	var dogs []*WatchDog
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() {
+			dogs = append(dogs, dog)
+		}
+	}
+	if len(dogs) == 0 {
+		return
+	}
+	debuggerLock.Lock()
+	_, hit := breakpoints[hackerBreakpoint{contract.CodeHash, pc}]
+	if _, wildcard := breakpoints[hackerBreakpoint{common.Hash{}, pc}]; wildcard {
+		hit = true
+	}
+	if _, ok := opBreaks[op]; ok {
+		hit = true
+	}
+	stepped := stepping[evm]
+	pause := interactive
+	debuggerLock.Unlock()
+	if !hit && !stepped {
+		return
+	}
+	state := dogs[0].snapshot(evm, pc, op, contract, memory, stack)
+	if hit {
+		for _, dog := range dogs {
+			if len(dog.hits) < hackerBreakpointHits {
+				dog.hits = append(dog.hits, state)
+			}
+		}
+	}
+	if !pause {
+		stopStepping(evm)
+		return
+	}
+
+	debuggerLock.Lock()
+	delete(stepping, evm)
+	pauseID++
+	execution := &hackerPaused{evm: evm, resume: make(chan bool, 1), state: HackerPausedState{ID: pauseID, Hash: dogs[0].tx.Hash(), HackerMachineState: state}}
+	paused[execution.state.ID] = execution
+	countBreaks()
+	debuggerLock.Unlock()
+
+	if step := <-execution.resume; step {
+		debuggerLock.Lock()
+		stepping[evm] = true
+		countBreaks()
+		debuggerLock.Unlock()
+	}
+}
@@ -0,0 +1,64 @@
+/**
+* @hacker_depth.go
+* Oracle: call depth attack.
+* A call operation run at the call depth limit (params.CallCreateDepth frames) fails
+* without running its callee, whatever the callee and the gas: an attacker calling a
+* contract from deep enough makes every call of the contract fail. The journal keeps
+* the frames whose call operation failed this way.
+* Such a failure in a live frame whose path from the frame of the transaction wrote
+* storage that was kept is reported as a "call_depth" finding, with the path: the
+* contract went on and committed state although a child call failed, it relies on the
+* success of its child calls (e.g. an unchecked send). Since EIP-150 the depth limit is
+* out of reach of the gas limits of the main chain, not of every chain.
+ */
+package vm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// hackerDepthFailure is a call operation of a frame failed at the call depth limit.
+type hackerDepthFailure struct {
+	frame int
+	op    OpCode
+}
+
+// checkCallDepth reports the live frames with a call failed at the call depth limit
+// under a frame that committed a storage write.
+func (taint *HackerTaint) checkCallDepth() []*HackerFinding {
+	journal := taint.journal
+	if len(journal.depthFailures) == 0 {
+		return nil
+	}
+	live := journal.live()
+	written := make(map[int]bool)
+	for _, write := range taint.erc20.writes {
+		written[write.frame] = true
+	}
+	findings := make([]*HackerFinding, 0)
+	reported := make(map[int]bool)
+	for _, failure := range journal.depthFailures {
+		if !live[failure.frame] || reported[failure.frame] {
+			continue
+		}
+		var path []string
+		committed := false
+		for i := failure.frame; i >= 0; i = journal.frames[i].parent {
+			path = append([]string{strconv.Itoa(i)}, path...)
+			committed = committed || written[i]
+		}
+		if !committed {
+			continue
+		}
+		reported[failure.frame] = true
+		frame := &journal.frames[failure.frame]
+		finding := newHackerFinding("call_depth", "HackerCallDepth")
+		finding.Detail["contract"] = frame.Address().Hex()
+		finding.Detail["frame"] = strconv.Itoa(failure.frame)
+		finding.Detail["op"] = failure.op.String()
+		finding.Detail["path"] = strings.Join(path, "/")
+		findings = append(findings, finding)
+	}
+	return findings
+}
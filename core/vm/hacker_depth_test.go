@@ -0,0 +1,48 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCallDepthOracle(t *testing.T) {
+	taint := newHackerTaint()
+	stack := newstack()
+	// a contract calling itself down to the depth limit
+	var (
+		self   = common.HexToAddress("0x0a")
+		caller = ContractRef(AccountRef(common.HexToAddress("0x01")))
+		frame  int
+	)
+	for depth := 1; depth <= int(params.CallCreateDepth)+1; depth++ {
+		contract := NewContract(caller, AccountRef(self), new(big.Int), 0)
+		contract.SetCallCode(&self, common.Hash{}, nil)
+		taint.journal.enter(PUSH1, contract, depth)
+		frame = taint.journal.enter(CALL, contract, depth)
+		caller = contract
+	}
+	stack.push(new(big.Int))
+	taint.journal.leave(CALL, int(params.CallCreateDepth)+1, stack)
+	if findings := taint.checkCallDepth(); len(findings) != 0 {
+		t.Fatalf("reported without storage write: %+v", findings)
+	}
+
+	// the deepest frame goes on and writes
+	key := hackerTaintStorageKey{self, common.Hash{}}
+	taint.erc20.writes = append(taint.erc20.writes, hackerERC20Write{frame, key, common.HexToHash("0x01")})
+	findings := taint.checkCallDepth()
+	if len(findings) != 1 || findings[0].Detail["frame"] != "1024" || findings[0].Detail["op"] != "CALL" {
+		t.Fatalf("unexpected findings %+v", findings)
+	}
+	if path := findings[0].Detail["path"]; path[:6] != "0/1/2/" || path[len(path)-10:] != "/1023/1024" {
+		t.Errorf("path %s, want the frames from 0 to 1024", path)
+	}
+	// a failure in a reverted frame is not reported
+	taint.journal.frames[frame].failed = true
+	if findings := taint.checkCallDepth(); len(findings) != 0 {
+		t.Errorf("reported in a failed frame: %+v", findings)
+	}
+}
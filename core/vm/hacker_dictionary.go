@@ -0,0 +1,95 @@
+/**
+* @hacker_dictionary.go
+* Mutation dictionary extracted from the bytecode of the targets.
+* 1 when a target is registered, its code is scanned for the constants pushed by PUSH4
+*   (selectors), PUSH20 (addresses) and PUSH32 (magic values), the push data of the
+*   other pushes is skipped. The all-zero and all-one values (masks) are left out.
+* 2 the destination of a watched transaction is registered on its first watch, and again
+*   when its code changed; the fuzzer registers the other targets (fuzz_registerTarget)
+*   and reads the entries with fuzz_dictionary, next to the comparisons seen at runtime.
+ */
+package vm
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// HackerDictionaryEntry is a constant of the code of a target.
+type HackerDictionaryEntry struct {
+	// Kind is "selector", "address" or "magic"
+	Kind  string        `json:"kind"`
+	Value hexutil.Bytes `json:"value"`
+}
+
+// hackerDictionary is the dictionary of the code of a target.
+type hackerDictionary struct {
+	codeHash common.Hash
+	entries  []HackerDictionaryEntry
+}
+
+var hackerDictionaryKinds = map[OpCode]string{
+	PUSH4:  "selector",
+	PUSH20: "address",
+	PUSH32: "magic",
+}
+
+// extractDictionary returns the constants of code, in the order they first appear.
+func extractDictionary(code []byte) []HackerDictionaryEntry {
+	entries := make([]HackerDictionaryEntry, 0)
+	seen := make(map[string]bool)
+	for pc := 0; pc < len(code); pc++ {
+		op := OpCode(code[pc])
+		if op < PUSH1 || op > PUSH32 {
+			continue
+		}
+		size := int(op - PUSH1 + 1)
+		start := pc + 1
+		pc += size
+		kind, ok := hackerDictionaryKinds[op]
+		if !ok || start+size > len(code) {
+			continue
+		}
+		value := code[start : start+size]
+		if bytes.Count(value, []byte{0}) == size || bytes.Count(value, []byte{0xff}) == size {
+			continue
+		}
+		key := kind + string(value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, HackerDictionaryEntry{Kind: kind, Value: common.CopyBytes(value)})
+	}
+	return entries
+}
+
+// RegisterTarget extracts the dictionary of the code of target, unless it is known for
+// this code already.
+func (c *HackerCampaign) RegisterTarget(target common.Address, code []byte) {
+	hash := crypto.Keccak256Hash(code)
+	c.lock.Lock()
+	if dictionary, ok := c.dictionaries[target]; ok && dictionary.codeHash == hash {
+		c.lock.Unlock()
+		return
+	}
+	c.lock.Unlock()
+	dictionary := &hackerDictionary{codeHash: hash, entries: extractDictionary(code)}
+	c.lock.Lock()
+	c.dictionaries[target] = dictionary
+	c.lock.Unlock()
+}
+
+// Dictionary returns the constants of the code of target, nil if it is not registered.
+func (c *HackerCampaign) Dictionary(target common.Address) []HackerDictionaryEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	dictionary, ok := c.dictionaries[target]
+	if !ok {
+		return nil
+	}
+	return append([]HackerDictionaryEntry{}, dictionary.entries...)
+}
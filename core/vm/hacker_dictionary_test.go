@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExtractDictionary(t *testing.T) {
+	code := common.FromHex("0x" +
+		"63a9059cbb" + // PUSH4 transfer
+		"63ffffffff" + // PUSH4 mask, left out
+		"7300000000000000000000000000000000000000c1" + // PUSH20
+		"62a9059cbb" + // PUSH3 with a selector as data, skipped
+		"7f" + "deadbeef00000000000000000000000000000000000000000000000000000000" + // PUSH32
+		"63a9059cbb" + // duplicate
+		"6312") // truncated PUSH4
+	want := []HackerDictionaryEntry{
+		{Kind: "selector", Value: common.FromHex("0xa9059cbb")},
+		{Kind: "address", Value: common.FromHex("0x00000000000000000000000000000000000000c1")},
+		{Kind: "magic", Value: common.FromHex("0xdeadbeef00000000000000000000000000000000000000000000000000000000")},
+	}
+	entries := extractDictionary(code)
+	if len(entries) != len(want) {
+		t.Fatalf("entries %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i].Kind != want[i].Kind || !bytes.Equal(entries[i].Value, want[i].Value) {
+			t.Errorf("entry %d %v, want %v", i, entries[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/**
+* @hacker_differential.go
+* Differential fuzzing of two versions of the code of a contract, e.g. before and after
+* a patch.
+* 1 both versions are registered for the target (SetDifferential), an empty version
+*   stands for the code the target has in the state.
+* 2 every message to the target executed by the fuzz API, and every watched transaction
+*   to it, is executed again on two forks of the same prestate, one per version.
+* 3 the outcomes are compared: status, return data, post state, and the logs and storage
+*   writes kept (those of the reverted frames are dropped), in order. The divergences
+*   are the "differential" section of the report and of the result of fuzz_call.
+ */
+package vm
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// hackerDifferential holds the versions of the code of a target.
+type hackerDifferential struct {
+	codes [2][]byte
+}
+
+// SetDifferential executes the messages to target with both versions of its code,
+// an empty version stands for the code of target in the state.
+func (c *HackerCampaign) SetDifferential(target common.Address, first, second []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.differentials[target] = &hackerDifferential{codes: [2][]byte{common.CopyBytes(first), common.CopyBytes(second)}}
+}
+
+// ClearDifferential stops the differential executions of target.
+func (c *HackerCampaign) ClearDifferential(target common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.differentials, target)
+}
+
+func (c *HackerCampaign) differential(target common.Address) *hackerDifferential {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.differentials[target]
+}
+
+// HackerDifferentialReport compares the executions of a message with both versions.
+type HackerDifferentialReport struct {
+	Target   common.Address `json:"target"`
+	Diverged bool           `json:"diverged"`
+	Reasons  []string       `json:"reasons"`
+	Failed   [2]bool        `json:"failed"`
+	GasUsed  [2]uint64      `json:"gasUsed"`
+}
+
+// hackerStateWrite is a storage write kept by the recording state.
+type hackerStateWrite struct {
+	addr  common.Address
+	slot  common.Hash
+	value common.Hash
+}
+
+// hackerRecords are the logs and storage writes of an execution.
+type hackerRecords struct {
+	logs   []*types.Log
+	writes []hackerStateWrite
+}
+
+// hackerRecordingState records the logs and storage writes of an execution, the ones
+// reverted by a snapshot are dropped.
+type hackerRecordingState struct {
+	StateDB
+	hackerRecords
+	on    bool
+	marks map[int][2]int
+}
+
+func (state *hackerRecordingState) AddLog(log *types.Log) {
+	if state.on {
+		state.logs = append(state.logs, log)
+	}
+	state.StateDB.AddLog(log)
+}
+
+func (state *hackerRecordingState) SetState(addr common.Address, slot, value common.Hash) {
+	if state.on {
+		state.writes = append(state.writes, hackerStateWrite{addr, slot, value})
+	}
+	state.StateDB.SetState(addr, slot, value)
+}
+
+func (state *hackerRecordingState) Snapshot() int {
+	id := state.StateDB.Snapshot()
+	state.marks[id] = [2]int{len(state.logs), len(state.writes)}
+	return id
+}
+
+func (state *hackerRecordingState) RevertToSnapshot(id int) {
+	if mark, ok := state.marks[id]; ok {
+		state.logs, state.writes = state.logs[:mark[0]], state.writes[:mark[1]]
+	}
+	state.StateDB.RevertToSnapshot(id)
+}
+
+// Differential executes msg, after override, with both versions of the code of its
+// target, nil when no differential is registered for it.
+func (harness *HackerHarness) Differential(msg *HackerMessage, override HackerStateOverride) *HackerDifferentialReport {
+	if msg.To == nil {
+		return nil
+	}
+	differential := GetGlobalCampaign().differential(*msg.To)
+	if differential == nil {
+		return nil
+	}
+	var (
+		outcomes [2]*HackerOutcome
+		records  [2]hackerRecords
+	)
+	for i, code := range differential.codes {
+		state := &hackerRecordingState{StateDB: harness.statedb, marks: make(map[int][2]int)}
+		variant := harness.derive(harness.ctx, state, harness.vmConfig)
+		variant.Fork(func() {
+			override.Apply(state)
+			if len(code) != 0 {
+				state.SetCode(*msg.To, code)
+			}
+			state.on = true
+			outcomes[i] = variant.Apply(msg)
+			state.on = false
+			// kept before the fork is reverted
+			records[i] = hackerRecords{append([]*types.Log{}, state.logs...), append([]hackerStateWrite{}, state.writes...)}
+		})
+	}
+	report := &HackerDifferentialReport{Target: *msg.To, Reasons: outcomes[0].Diff(outcomes[1])}
+	report.Reasons = append(report.Reasons, diffRecords(&records[0], &records[1])...)
+	for i, outcome := range outcomes {
+		report.Failed[i], report.GasUsed[i] = outcome.Failed(), outcome.GasUsed
+	}
+	report.Diverged = len(report.Reasons) > 0
+	return report
+}
+
+// diffRecords returns the first log and the first storage write in which the records
+// of two executions differ.
+func diffRecords(first, second *hackerRecords) []string {
+	reasons := make([]string, 0)
+	for i := 0; i < len(first.logs) || i < len(second.logs); i++ {
+		if i >= len(first.logs) || i >= len(second.logs) || !equalLogs(first.logs[i], second.logs[i]) {
+			reasons = append(reasons, "log:"+strconv.Itoa(i))
+			break
+		}
+	}
+	for i := 0; i < len(first.writes) || i < len(second.writes); i++ {
+		if i >= len(first.writes) || i >= len(second.writes) || first.writes[i] != second.writes[i] {
+			reasons = append(reasons, "write:"+strconv.Itoa(i))
+			break
+		}
+	}
+	return reasons
+}
+
+func equalLogs(a, b *types.Log) bool {
+	if a.Address != b.Address || len(a.Topics) != len(b.Topics) || !bytes.Equal(a.Data, b.Data) {
+		return false
+	}
+	for i := range a.Topics {
+		if a.Topics[i] != b.Topics[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (dog *WatchDog) runDifferential(env *EVM, tx *types.Transaction) {
+	dog.differential = nil
+	if env == nil {
+		return
+	}
+	dog.differential = newHackerHarnessFrom(env).Differential(hackerTxMessage(env, tx), nil)
+}
@@ -0,0 +1,211 @@
+/**
+* @hacker_disasm.go
+* Annotated disassembly of the codes run by the watched transactions, the triage view
+* of the fuzzer (fuzz_disassemble).
+* 1 while a watched transaction runs, every code it executes is annotated by pc:
+*   - the branch hits, how many times a JUMPI fell through and how many times a JUMP
+*     or a JUMPI jumped (the counts behind the coverage bitmaps, hacker_coverage.go),
+*   - the operands of the comparisons (EQ, LT, GT, SLT, SGT), at most
+*     hackerAnnotationOperands distinct ones per pc,
+*   - the findings raised while the pc executed, counted by type.
+* 2 at the end of the transaction its annotations are merged into the campaign,
+*   Disassemble decodes the code of a hash with the annotations of each instruction.
+* The annotations live as long as the campaign, they are not stored with the coverage.
+ */
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// hackerAnnotationOperands bounds the distinct comparison operands kept per pc.
+const hackerAnnotationOperands = 8
+
+var errUnknownCode = errors.New("code not run by a watched transaction")
+
+// hackerPcKey is an instruction of a code.
+type hackerPcKey struct {
+	codeHash common.Hash
+	pc       uint64
+}
+
+// hackerCodeAnnotations are the annotations of a code by pc.
+type hackerCodeAnnotations struct {
+	code []byte
+	// branches counts the fall throughs and the jumps
+	branches map[uint64]*[2]uint64
+	operands map[uint64][]common.Hash
+	findings map[uint64]map[string]uint64
+}
+
+func newHackerCodeAnnotations(code []byte) *hackerCodeAnnotations {
+	return &hackerCodeAnnotations{code: code, branches: make(map[uint64]*[2]uint64), operands: make(map[uint64][]common.Hash), findings: make(map[uint64]map[string]uint64)}
+}
+
+func (a *hackerCodeAnnotations) addOperand(pc uint64, value common.Hash) {
+	operands := a.operands[pc]
+	if len(operands) == hackerAnnotationOperands {
+		return
+	}
+	for _, operand := range operands {
+		if operand == value {
+			return
+		}
+	}
+	a.operands[pc] = append(operands, value)
+}
+
+func (a *hackerCodeAnnotations) addFinding(pc uint64, kind string, count uint64) {
+	if a.findings[pc] == nil {
+		a.findings[pc] = make(map[string]uint64)
+	}
+	a.findings[pc][kind] += count
+}
+
+// merge adds the annotations of other.
+func (a *hackerCodeAnnotations) merge(other *hackerCodeAnnotations) {
+	for pc, hits := range other.branches {
+		if a.branches[pc] == nil {
+			a.branches[pc] = new([2]uint64)
+		}
+		a.branches[pc][0] += hits[0]
+		a.branches[pc][1] += hits[1]
+	}
+	for pc, operands := range other.operands {
+		for _, operand := range operands {
+			a.addOperand(pc, operand)
+		}
+	}
+	for pc, kinds := range other.findings {
+		for kind, count := range kinds {
+			a.addFinding(pc, kind, count)
+		}
+	}
+}
+
+// annotations returns the annotations of the code of contract for the watched
+// transaction.
+func (dog *WatchDog) annotations(contract *Contract) *hackerCodeAnnotations {
+	annotations := dog.annotated[contract.CodeHash]
+	if annotations == nil {
+		annotations = newHackerCodeAnnotations(contract.Code)
+		dog.annotated[contract.CodeHash] = annotations
+	}
+	return annotations
+}
+
+// annotate records the annotations of op about to execute at pc in contract.
+func (dog *WatchDog) annotate(pc uint64, op OpCode, contract *Contract, stack *Stack) {
+	if contract.CodeHash == (common.Hash{}) || dog.annotated == nil {
+		return
+	}
+	switch op {
+	case JUMP, JUMPI:
+		annotations := dog.annotations(contract)
+		hits := annotations.branches[pc]
+		if hits == nil {
+			hits = new([2]uint64)
+			annotations.branches[pc] = hits
+		}
+		if op == JUMPI && stack.Back(1).Sign() == 0 {
+			hits[0]++
+		} else {
+			hits[1]++
+		}
+	case EQ, LT, GT, SLT, SGT:
+		annotations := dog.annotations(contract)
+		annotations.addOperand(pc, common.BigToHash(stack.Back(0)))
+		annotations.addOperand(pc, common.BigToHash(stack.Back(1)))
+	}
+}
+
+// annotateFinding marks the instruction running when the finding kind was raised,
+// none once the execution ended.
+func (dog *WatchDog) annotateFinding(kind string) {
+	if dog.annotated == nil || dog.at.codeHash == (common.Hash{}) {
+		return
+	}
+	if annotations := dog.annotated[dog.at.codeHash]; annotations != nil {
+		annotations.addFinding(dog.at.pc, kind, 1)
+	}
+}
+
+// recordAnnotations merges the annotations of the watched transaction into the
+// campaign.
+func (dog *WatchDog) recordAnnotations() {
+	GetGlobalCampaign().addAnnotations(dog.annotated)
+}
+
+func (c *HackerCampaign) addAnnotations(annotated map[common.Hash]*hackerCodeAnnotations) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for hash, annotations := range annotated {
+		if c.annotations[hash] == nil {
+			c.annotations[hash] = newHackerCodeAnnotations(common.CopyBytes(annotations.code))
+		}
+		c.annotations[hash].merge(annotations)
+	}
+}
+
+// HackerInstruction is an instruction of the annotated disassembly.
+type HackerInstruction struct {
+	Pc  uint64        `json:"pc"`
+	Op  string        `json:"op"`
+	Arg hexutil.Bytes `json:"arg,omitempty"`
+	// FallThrough and Taken count the branch hits, JUMP and JUMPI only
+	FallThrough uint64            `json:"fallThrough,omitempty"`
+	Taken       uint64            `json:"taken,omitempty"`
+	Operands    []common.Hash     `json:"operands,omitempty"`
+	Findings    map[string]uint64 `json:"findings,omitempty"`
+}
+
+// HackerDisassembly is the annotated disassembly of a code.
+type HackerDisassembly struct {
+	CodeHash     common.Hash         `json:"codeHash"`
+	Instructions []HackerInstruction `json:"instructions"`
+}
+
+// Disassemble returns the annotated disassembly of the code of codeHash.
+func (c *HackerCampaign) Disassemble(codeHash common.Hash) (*HackerDisassembly, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	annotations := c.annotations[codeHash]
+	if annotations == nil {
+		return nil, errUnknownCode
+	}
+	disassembly := &HackerDisassembly{CodeHash: codeHash, Instructions: make([]HackerInstruction, 0)}
+	code := annotations.code
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		instruction := HackerInstruction{Pc: pc, Op: opCodeToString[op]}
+		if instruction.Op == "" {
+			instruction.Op = fmt.Sprintf("INVALID(0x%02x)", byte(op))
+		}
+		if op >= PUSH1 && op <= PUSH32 {
+			end := pc + uint64(op-PUSH1) + 2
+			if end > uint64(len(code)) {
+				end = uint64(len(code))
+			}
+			instruction.Arg = common.CopyBytes(code[pc+1 : end])
+		}
+		if hits := annotations.branches[pc]; hits != nil {
+			instruction.FallThrough, instruction.Taken = hits[0], hits[1]
+		}
+		if operands := annotations.operands[pc]; len(operands) != 0 {
+			instruction.Operands = append([]common.Hash{}, operands...)
+		}
+		if kinds := annotations.findings[pc]; len(kinds) != 0 {
+			instruction.Findings = make(map[string]uint64, len(kinds))
+			for kind, count := range kinds {
+				instruction.Findings[kind] = count
+			}
+		}
+		disassembly.Instructions = append(disassembly.Instructions, instruction)
+		pc += uint64(len(instruction.Arg))
+	}
+	return disassembly, nil
+}
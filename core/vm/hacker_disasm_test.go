@@ -0,0 +1,46 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDisassembleAnnotations(t *testing.T) {
+	// push1 1 jumpi invalid push2 0xab (cut)
+	code := common.FromHex("0x600157fe61ab")
+	hash := common.HexToHash("0x01")
+	dog := &WatchDog{annotated: make(map[common.Hash]*hackerCodeAnnotations)}
+	dog.setTurnOn(true)
+	dog.annotated[hash] = newHackerCodeAnnotations(code)
+	dog.at = hackerPcKey{hash, 2}
+	dog.EmitFinding(newHackerFinding("weak_randomness", "test"))
+	dog.at = hackerPcKey{}
+	dog.EmitFinding(newHackerFinding("profit", "test"))
+	dog.annotated[hash].branches[2] = &[2]uint64{1, 3}
+
+	c := newHackerCampaign()
+	if _, err := c.Disassemble(hash); err != errUnknownCode {
+		t.Fatalf("error %v, want errUnknownCode", err)
+	}
+	c.addAnnotations(dog.annotated)
+	c.addAnnotations(dog.annotated)
+	disassembly, err := c.Disassemble(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := make([]string, 0)
+	for _, instruction := range disassembly.Instructions {
+		ops = append(ops, instruction.Op)
+	}
+	if len(ops) != 4 || ops[0] != "PUSH1" || ops[1] != "JUMPI" || ops[2] != "INVALID(0xfe)" || ops[3] != "PUSH2" {
+		t.Fatalf("ops %v, want PUSH1 JUMPI INVALID(0xfe) PUSH2", ops)
+	}
+	if arg := disassembly.Instructions[3].Arg; len(arg) != 1 || arg[0] != 0xab {
+		t.Errorf("argument %x, want the push data cut at the end of the code", arg)
+	}
+	jumpi := disassembly.Instructions[1]
+	if jumpi.Pc != 2 || jumpi.FallThrough != 2 || jumpi.Taken != 6 || len(jumpi.Findings) != 1 || jumpi.Findings["weak_randomness"] != 2 {
+		t.Errorf("instruction %+v, want the merged hits and the finding raised at it", jumpi)
+	}
+}
@@ -0,0 +1,141 @@
+/**
+* @hacker_env.go
+* Execution environment of a watched transaction, the "env" section of its report, for
+* a finding to be reproduced byte for byte later.
+* 1 the block environment as the execution saw it: with a seed (hacker_prng.go) the
+*   coinbase and difficulty are the seeded ones, the chain id is the override of the
+*   harness when there is one.
+* 2 the block forked: the transaction ran on the state of the parent of its block, an
+*   instrumented harness call (hacker_callreport.go) on the state of its block.
+* 3 the version of the node.
+* 4 the hash of the instrumentation configuration of the execution: policy decision,
+*   targets downgraded and blocked, step budget, progress reports, breakpoints,
+*   saturation, oracles and redaction. Two reports with the same hash were recorded
+*   the same way.
+ */
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// HackerReportEnv is the environment of a watched transaction.
+type HackerReportEnv struct {
+	Number     string         `json:"number"`
+	Time       string         `json:"time"`
+	Coinbase   common.Address `json:"coinbase"`
+	Difficulty string         `json:"difficulty"`
+	GasLimit   string         `json:"gasLimit"`
+	GasPrice   string         `json:"gasPrice"`
+	BaseFee    string         `json:"baseFee,omitempty"`
+	ChainID    string         `json:"chainId"`
+	// ChainIDOverride is set when the chain id is not the one of the chain config
+	ChainIDOverride bool         `json:"chainIdOverride,omitempty"`
+	Seed            *common.Hash `json:"seed,omitempty"`
+	// ForkBlock is the block whose state the transaction ran on
+	ForkBlock uint64 `json:"forkBlock"`
+	// Call is set when the transaction wraps a harness call
+	Call bool   `json:"call,omitempty"`
+	Node string `json:"node"`
+	// Instrumentation is the hash of the instrumentation configuration
+	Instrumentation common.Hash `json:"instrumentation"`
+}
+
+// hackerInstrumentation is the configuration the hash of the env is taken of.
+type hackerInstrumentation struct {
+	CallsOnly       bool             `json:"callsOnly"`
+	Reduced         []common.Address `json:"reduced"`
+	Blocked         []common.Address `json:"blocked"`
+	StepBudget      uint64           `json:"stepBudget"`
+	ProgressEvery   uint64           `json:"progressEvery"`
+	Breakpoints     []string         `json:"breakpoints"`
+	Saturation      uint64           `json:"saturation"`
+	ProfitTargets   []common.Address `json:"profitTargets"`
+	ProfitThreshold string           `json:"profitThreshold"`
+	ProfitTokens    []common.Address `json:"profitTokens"`
+	Redaction       *HackerRedaction `json:"redaction"`
+	// TraceSampling is left out when the trace is kept whole
+	TraceSampling map[string]uint64 `json:"traceSampling,omitempty"`
+}
+
+func hackerSortedAddresses(set map[common.Address]bool) []common.Address {
+	addrs := make([]common.Address, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	return addrs
+}
+
+// breakpointList returns the breakpoints set, sorted.
+func breakpointList() []string {
+	debuggerLock.Lock()
+	defer debuggerLock.Unlock()
+	list := make([]string, 0, len(breakpoints)+len(opBreaks))
+	for bp := range breakpoints {
+		list = append(list, fmt.Sprintf("%s:%d", bp.codeHash.Hex(), bp.pc))
+	}
+	for op := range opBreaks {
+		list = append(list, op.String())
+	}
+	sort.Strings(list)
+	return list
+}
+
+// instrumentationHash returns the hash of the instrumentation configuration of the
+// watched transaction.
+func (dog *WatchDog) instrumentationHash() common.Hash {
+	config := hackerInstrumentation{CallsOnly: dog.callsOnly, Reduced: hackerSortedAddresses(dog.reduced), StepBudget: atomic.LoadUint64(&dog.stepBudget), ProgressEvery: atomic.LoadUint64(&dog.progressEvery), Breakpoints: breakpointList(), TraceSampling: dog.traceSampling()}
+	blocked := make(map[common.Address]bool, len(dog.blocked))
+	for addr := range dog.blocked {
+		blocked[addr] = true
+	}
+	config.Blocked = hackerSortedAddresses(blocked)
+	c := GetGlobalCampaign()
+	targets, threshold := c.profitOracle()
+	config.ProfitTargets, config.ProfitThreshold, config.ProfitTokens = hackerSortedAddresses(targets), threshold.Text(10), c.profitTokenList()
+	c.lock.Lock()
+	config.Saturation = c.saturation
+	c.lock.Unlock()
+	config.Redaction = c.Redaction()
+	data, _ := json.Marshal(config)
+	return crypto.Keccak256Hash(data)
+}
+
+// reportEnv returns the "env" section of the report of the watched transaction.
+func (dog *WatchDog) reportEnv() *HackerReportEnv {
+	evm := dog.env
+	text := func(n *big.Int) string {
+		if n == nil {
+			return "0"
+		}
+		return n.Text(10)
+	}
+	env := &HackerReportEnv{Number: text(evm.BlockNumber), Time: text(evm.Time), Coinbase: evm.Coinbase, Difficulty: text(evm.Difficulty), GasLimit: text(evm.GasLimit), GasPrice: text(evm.GasPrice), ChainID: text(evm.ChainID()), ChainIDOverride: evm.chainID != nil, Node: params.Version, Instrumentation: dog.instrumentationHash()}
+	if evm.BaseFee != nil {
+		env.BaseFee = evm.BaseFee.Text(10)
+	}
+	if evm.seed != nil {
+		seed := *evm.seed
+		env.Seed, env.Coinbase, env.Difficulty = &seed, seededCoinbase(&seed), seededDifficulty(&seed).Text(10)
+	}
+	if evm.BlockNumber != nil && evm.BlockNumber.Sign() > 0 {
+		env.ForkBlock = evm.BlockNumber.Uint64() - 1
+	}
+	if dog.call {
+		env.Call = true
+		if evm.BlockNumber != nil {
+			env.ForkBlock = evm.BlockNumber.Uint64()
+		}
+	}
+	return env
+}
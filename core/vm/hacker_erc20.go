@@ -0,0 +1,326 @@
+/**
+* @hacker_erc20.go
+* ERC-20 oracle pack, for the targets whose ABI registered transfer(address,uint256).
+* 1 during the execution the Transfer/Approval events (the ERC-721/1155 ones too, see
+*   hacker_nft.go) and the storage writes are recorded with the frame of the journal
+*   (hacker_journal.go) they happened in. Frames that failed, and their children,
+*   are left out when checking, their effects were reverted.
+* 2 at the end of the transaction every successful CALL of the token is checked:
+*   "erc20_missing_transfer_event"    transfer/transferFrom without its Transfer event.
+*   "erc20_transferfrom_no_allowance" transferFrom on behalf of another holder that never
+*                                     hashed the (owner, spender) pair of an allowance slot.
+*   "erc20_approve_race"              approve changing a non zero allowance to another non
+*                                     zero value, the spender can use both.
+*   "erc20_balance_sum"               the balances of a mapping keyed by the holders of the
+*                                     Transfer events do not add up to minted - burned.
+*   Mapping slots are recognised through the SHA3 preimages of hacker_signature.go.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	erc20TransferEvent = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	erc20ApprovalEvent = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+
+	erc20Transfer     = selectorOf("transfer(address,uint256)")
+	erc20TransferFrom = selectorOf("transferFrom(address,address,uint256)")
+	erc20Approve      = selectorOf("approve(address,uint256)")
+)
+
+func selectorOf(signature string) (selector [4]byte) {
+	copy(selector[:], crypto.Keccak256([]byte(signature))[:4])
+	return selector
+}
+
+type hackerERC20Event struct {
+	frame    int
+	token    common.Address
+	topic    common.Hash
+	from, to common.Hash
+	value    *big.Int
+	// id is the token id of the ERC-721/1155 events, nil for ERC-20
+	id *big.Int
+}
+
+type hackerERC20Write struct {
+	frame int
+	key   hackerTaintStorageKey
+	value common.Hash
+}
+
+// hackerERC20 records the token events and storage writes of one transaction, for the
+// ERC-20, NFT and proxy upgrade oracles.
+type hackerERC20 struct {
+	events []hackerERC20Event
+	writes []hackerERC20Write
+	// first value of every written slot
+	old map[hackerTaintStorageKey]common.Hash
+}
+
+func newHackerERC20() *hackerERC20 {
+	return &hackerERC20{old: make(map[hackerTaintStorageKey]common.Hash)}
+}
+
+// recordERC20 runs before op executes in the frame of the journal.
+func (taint *HackerTaint) recordERC20(frame int, op OpCode, contract *Contract, memory *Memory, stack *Stack, statedb StateDB) {
+	erc20 := taint.erc20
+	switch {
+	case op == SSTORE && stack.len() >= 2:
+		key := hackerTaintStorageKey{contract.Address(), common.BigToHash(stack.Back(0))}
+		if _, ok := erc20.old[key]; !ok {
+			erc20.old[key] = statedb.GetState(key.addr, key.slot)
+		}
+		erc20.writes = append(erc20.writes, hackerERC20Write{frame, key, common.BigToHash(stack.Back(1))})
+	case (op == LOG3 || op == LOG4) && stack.len() >= int(op-LOG0)+2:
+		topic := common.BigToHash(stack.Back(2))
+		if topic != erc20TransferEvent && topic != erc20ApprovalEvent && topic != erc1155TransferSingleEvent {
+			return
+		}
+		data := make([]byte, 64)
+		if stack.Back(0).BitLen() <= 63 && stack.Back(1).BitLen() <= 63 && stack.Back(0).Int64()+stack.Back(1).Int64() <= int64(memory.Len()) {
+			copy(data, memory.GetPtr(stack.Back(0).Int64(), stack.Back(1).Int64()))
+		}
+		event := hackerERC20Event{frame: frame, token: contract.Address(), topic: topic}
+		switch {
+		case topic == erc1155TransferSingleEvent && op == LOG4:
+			// TransferSingle(operator, from, to) id, value
+			event.from, event.to = common.BigToHash(stack.Back(4)), common.BigToHash(stack.Back(5))
+			event.id, event.value = new(big.Int).SetBytes(data[:32]), new(big.Int).SetBytes(data[32:64])
+		case op == LOG4:
+			// ERC-721 Transfer/Approval, the token id is indexed
+			event.from, event.to = common.BigToHash(stack.Back(3)), common.BigToHash(stack.Back(4))
+			event.id, event.value = new(big.Int).Set(stack.Back(5)), big.NewInt(1)
+		case topic != erc1155TransferSingleEvent:
+			event.from, event.to = common.BigToHash(stack.Back(3)), common.BigToHash(stack.Back(4))
+			event.value = new(big.Int).SetBytes(data[:32])
+		default:
+			return
+		}
+		erc20.events = append(erc20.events, event)
+	}
+}
+
+// kept returns the events and the final value of the slots written by the live frames.
+func (erc20 *hackerERC20) kept(live []bool) ([]*hackerERC20Event, map[hackerTaintStorageKey]common.Hash) {
+	events := make([]*hackerERC20Event, 0, len(erc20.events))
+	for i := range erc20.events {
+		if live[erc20.events[i].frame] {
+			events = append(events, &erc20.events[i])
+		}
+	}
+	final := make(map[hackerTaintStorageKey]common.Hash)
+	for _, write := range erc20.writes {
+		if live[write.frame] {
+			final[write.key] = write.value
+		}
+	}
+	return events, final
+}
+
+// abiWord returns the n-th argument word of input.
+func abiWord(input []byte, n int) common.Hash {
+	start := 4 + 32*n
+	if len(input) < start+32 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(input[start : start+32])
+}
+
+// hashes tells whether a preimage was hashed with both words, directly or nested,
+// the way allowance[owner][spender] is addressed.
+func (sigs *hackerSignatures) hashes(a, b common.Hash) bool {
+	for _, preimage := range sigs.preimages {
+		var hasA, hasB bool
+		for _, word := range preimage.words {
+			hasA = hasA || word == a
+			hasB = hasB || word == b
+			if nested, ok := sigs.preimages[word]; ok {
+				for _, inner := range nested.words {
+					hasA = hasA || inner == a
+					hasB = hasB || inner == b
+				}
+			}
+		}
+		if hasA && hasB {
+			return true
+		}
+	}
+	return false
+}
+
+// checkERC20 runs the ERC-20 oracles over the frames of the transaction and returns
+// their findings.
+func (taint *HackerTaint) checkERC20() []*HackerFinding {
+	erc20 := taint.erc20
+	if len(erc20.events)+len(erc20.writes) == 0 {
+		return nil
+	}
+	live := taint.journal.live()
+	events, final := erc20.kept(live)
+	findings := make([]*HackerFinding, 0)
+	consumed := make(map[*hackerERC20Event]bool)
+	// expectTransfer consumes the first matching Transfer event of token
+	expectTransfer := func(token common.Address, from, to common.Hash, value *big.Int) bool {
+		for _, event := range events {
+			if !consumed[event] && event.topic == erc20TransferEvent && event.id == nil && event.token == token &&
+				event.from == from && event.to == to && event.value.Cmp(value) == 0 {
+				consumed[event] = true
+				return true
+			}
+		}
+		return false
+	}
+	tokens := make(map[common.Address]bool)
+	for i := range taint.journal.frames {
+		call := &taint.journal.frames[i]
+		if !live[i] || call.kind != CALL {
+			continue
+		}
+		method, ok := GetABIRegistry().Lookup(call.Address(), call.Input())
+		if !ok {
+			continue
+		}
+		if _, erc := GetABIRegistry().Lookup(call.Address(), erc20Transfer[:]); !erc {
+			continue
+		}
+		tokens[call.Address()] = true
+		caller := common.BytesToHash(call.Caller().Bytes())
+		var finding *HackerFinding
+		switch method.Selector {
+		case erc20Transfer:
+			to, value := abiWord(call.Input(), 0), abiWord(call.Input(), 1).Big()
+			if !expectTransfer(call.Address(), caller, to, value) {
+				finding = newHackerFinding("erc20_missing_transfer_event", "HackerERC20")
+				finding.Detail["from"] = call.Caller().Hex()
+				finding.Detail["to"] = common.BytesToAddress(to.Bytes()).Hex()
+				finding.Detail["value"] = value.Text(10)
+			}
+		case erc20TransferFrom:
+			from, to, value := abiWord(call.Input(), 0), abiWord(call.Input(), 1), abiWord(call.Input(), 2).Big()
+			if !expectTransfer(call.Address(), from, to, value) {
+				finding = newHackerFinding("erc20_missing_transfer_event", "HackerERC20")
+				finding.Detail["from"] = common.BytesToAddress(from.Bytes()).Hex()
+				finding.Detail["to"] = common.BytesToAddress(to.Bytes()).Hex()
+				finding.Detail["value"] = value.Text(10)
+			} else if from != caller && !taint.signatures.hashes(from, caller) {
+				finding = newHackerFinding("erc20_transferfrom_no_allowance", "HackerERC20")
+				finding.Detail["from"] = common.BytesToAddress(from.Bytes()).Hex()
+				finding.Detail["spender"] = call.Caller().Hex()
+				finding.Detail["value"] = value.Text(10)
+			}
+		case erc20Approve:
+			spender, value := abiWord(call.Input(), 0), abiWord(call.Input(), 1)
+			for key, current := range final {
+				if key.addr != call.Address() || current != value {
+					continue
+				}
+				preimage, ok := taint.signatures.preimages[key.slot]
+				if !ok || !taint.signatures.hashes(caller, spender) || !containsWord(preimage.words, spender) {
+					continue
+				}
+				if old := erc20.old[key]; old != (common.Hash{}) && old != value && value != (common.Hash{}) {
+					finding = newHackerFinding("erc20_approve_race", "HackerERC20")
+					finding.Detail["owner"] = call.Caller().Hex()
+					finding.Detail["spender"] = common.BytesToAddress(spender.Bytes()).Hex()
+					finding.Detail["old"] = old.Big().Text(10)
+					finding.Detail["new"] = value.Big().Text(10)
+					break
+				}
+			}
+		}
+		if finding != nil {
+			finding.Detail["token"] = call.Address().Hex()
+			findings = append(findings, finding)
+		}
+	}
+	for token := range tokens {
+		findings = append(findings, taint.checkBalanceSum(token, events, final)...)
+	}
+	return findings
+}
+
+// checkTokens runs the token and proxy upgrade oracles once the transaction is done.
+func (dog *WatchDog) checkTokens() {
+	if dog.taint == nil || dog.env == nil {
+		return
+	}
+	findings := dog.taint.checkERC20()
+	findings = append(findings, dog.taint.checkNFT(dog.env.StateDB)...)
+	findings = append(findings, dog.taint.checkUpgrade(dog.env.StateDB)...)
+	findings = append(findings, dog.taint.checkColdAccess()...)
+	findings = append(findings, dog.taint.checkCallDepth()...)
+	for _, finding := range findings {
+		dog.EmitFinding(finding)
+	}
+}
+
+func containsWord(words []common.Hash, word common.Hash) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBalanceSum compares, per mapping keyed by the holders of the Transfer events of
+// token, the sum of the balance changes with the minted minus burned amount.
+func (taint *HackerTaint) checkBalanceSum(token common.Address, events []*hackerERC20Event, final map[hackerTaintStorageKey]common.Hash) []*HackerFinding {
+	holders := make(map[common.Hash]bool)
+	expected := new(big.Int)
+	for _, event := range events {
+		if event.token != token || event.topic != erc20TransferEvent || event.id != nil {
+			continue
+		}
+		if event.from == (common.Hash{}) {
+			expected.Add(expected, event.value)
+		} else {
+			holders[event.from] = true
+		}
+		if event.to == (common.Hash{}) {
+			expected.Sub(expected, event.value)
+		} else {
+			holders[event.to] = true
+		}
+	}
+	if len(holders) == 0 {
+		return nil
+	}
+	sums := make(map[common.Hash]*big.Int)
+	for key, current := range final {
+		if key.addr != token {
+			continue
+		}
+		preimage, ok := taint.signatures.preimages[key.slot]
+		if !ok || len(preimage.words) != 2 || !holders[preimage.words[0]] {
+			continue
+		}
+		index := preimage.words[1]
+		if _, nested := taint.signatures.preimages[index]; nested {
+			continue
+		}
+		if sums[index] == nil {
+			sums[index] = new(big.Int)
+		}
+		sums[index].Add(sums[index], current.Big())
+		sums[index].Sub(sums[index], taint.erc20.old[key].Big())
+	}
+	findings := make([]*HackerFinding, 0)
+	for index, sum := range sums {
+		if sum.Cmp(expected) != 0 {
+			finding := newHackerFinding("erc20_balance_sum", "HackerERC20")
+			finding.Detail["token"] = token.Hex()
+			finding.Detail["mapping"] = index.Big().Text(10)
+			finding.Detail["delta"] = sum.Text(10)
+			finding.Detail["expected"] = expected.Text(10)
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestERC20Oracles(t *testing.T) {
+	var (
+		holder    = common.HexToAddress("0x01")
+		token     = common.HexToAddress("0x02")
+		recipient = common.HexToAddress("0x03")
+		taint     = newHackerTaint()
+	)
+	GetABIRegistry().Register(token, "transfer(address,uint256)")
+	input := append(erc20Transfer[:], common.BytesToHash(recipient.Bytes()).Bytes()...)
+	input = append(input, common.BigToHash(big.NewInt(5)).Bytes()...)
+	call := enterTestFrame(taint, holder, token, input)
+
+	findings := taint.checkERC20()
+	if len(findings) != 0 {
+		t.Fatalf("findings without any event or write: %v", findings)
+	}
+	taint.erc20.writes = append(taint.erc20.writes, hackerERC20Write{frame: call})
+	findings = taint.checkERC20()
+	if len(findings) != 1 || findings[0].Type != "erc20_missing_transfer_event" {
+		t.Fatalf("missing Transfer event not reported: %v", findings)
+	}
+
+	from, to := common.BytesToHash(holder.Bytes()), common.BytesToHash(recipient.Bytes())
+	taint.erc20 = newHackerERC20()
+	taint.erc20.events = append(taint.erc20.events, hackerERC20Event{frame: call, token: token, topic: erc20TransferEvent, from: from, to: to, value: big.NewInt(5)})
+	fromSlot, toSlot := common.HexToHash("0xaa"), common.HexToHash("0xbb")
+	taint.signatures.preimages[fromSlot] = &hackerPreimage{words: []common.Hash{from, {}}}
+	taint.signatures.preimages[toSlot] = &hackerPreimage{words: []common.Hash{to, {}}}
+	taint.erc20.old[hackerTaintStorageKey{token, fromSlot}] = common.BigToHash(big.NewInt(10))
+	taint.erc20.writes = append(taint.erc20.writes,
+		hackerERC20Write{call, hackerTaintStorageKey{token, fromSlot}, common.BigToHash(big.NewInt(5))},
+		hackerERC20Write{call, hackerTaintStorageKey{token, toSlot}, common.BigToHash(big.NewInt(4))})
+	findings = taint.checkERC20()
+	if len(findings) != 1 || findings[0].Type != "erc20_balance_sum" || findings[0].Detail["delta"] != "-1" {
+		t.Fatalf("balance sum violation not reported: %v", findings)
+	}
+	taint.journal.frames[call].failed = true
+	if findings = taint.checkERC20(); len(findings) != 0 {
+		t.Fatalf("failed frame checked: %v", findings)
+	}
+}
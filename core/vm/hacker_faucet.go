@@ -0,0 +1,121 @@
+/**
+* @hacker_faucet.go
+* Faucet of the campaign: balances credited on the forks of the fuzz API, so that the
+* fuzzer never runs out of ether, without touching the canonical state.
+* 1 Fund credits an amount to an account on the fork of every execution of the
+*   harnesses (SetFunding), after the fixtures: the credits of an account add up, its
+*   fixture sets the balance they are added to.
+* 2 the faucet (SetFaucet) tops the sender accounts of the fuzzer up: a message of a
+*   sender whose balance is below the threshold on the fork is executed with the amount
+*   credited first, every message of a bundle included.
+* 3 the watched transactions of the chain are never funded.
+ */
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var errFaucetAmount = errors.New("faucet without amount")
+
+// HackerFaucet tops the sender accounts of the fuzzer up when their balance drops
+// below Threshold, below Amount without threshold.
+type HackerFaucet struct {
+	Senders   []common.Address `json:"senders"`
+	Threshold *hexutil.Big     `json:"threshold"`
+	Amount    *hexutil.Big     `json:"amount"`
+}
+
+// sends tells whether addr is a sender topped up by the faucet.
+func (faucet *HackerFaucet) sends(addr common.Address) bool {
+	for _, sender := range faucet.Senders {
+		if sender == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Fund credits amount to addr on the forks of the fuzz API, on top of its previous
+// credits.
+func (c *HackerCampaign) Fund(addr common.Address, amount *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	credit := c.credits[addr]
+	if credit == nil {
+		credit = new(big.Int)
+		c.credits[addr] = credit
+	}
+	credit.Add(credit, amount)
+}
+
+// Credits returns the amounts credited by account.
+func (c *HackerCampaign) Credits() map[common.Address]*big.Int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	credits := make(map[common.Address]*big.Int, len(c.credits))
+	for addr, credit := range c.credits {
+		credits[addr] = new(big.Int).Set(credit)
+	}
+	return credits
+}
+
+// SetFaucet tops the senders of faucet up on the forks of the fuzz API, nil turns the
+// faucet off.
+func (c *HackerCampaign) SetFaucet(faucet *HackerFaucet) error {
+	if faucet != nil && (faucet.Amount == nil || faucet.Amount.ToInt().Sign() <= 0) {
+		return errFaucetAmount
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.faucet = faucet
+	return nil
+}
+
+// Faucet returns the faucet of the senders, nil when it is off.
+func (c *HackerCampaign) Faucet() *HackerFaucet {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.faucet
+}
+
+// SetFunding credits credits on the fork of every execution of the harness and tops
+// the senders of faucet up, nil leaves them alone.
+func (harness *HackerHarness) SetFunding(credits map[common.Address]*big.Int, faucet *HackerFaucet) {
+	harness.credits = credits
+	harness.faucet = faucet
+}
+
+// fund credits the credits of the harness on its fork.
+func (harness *HackerHarness) fund() {
+	for addr, credit := range harness.credits {
+		if !harness.statedb.Exist(addr) {
+			harness.statedb.CreateAccount(addr)
+		}
+		harness.statedb.AddBalance(addr, credit)
+	}
+}
+
+// topUp credits the amount of the faucet to sender when its balance is below the
+// threshold.
+func (harness *HackerHarness) topUp(sender common.Address) {
+	faucet := harness.faucet
+	if faucet == nil || !faucet.sends(sender) {
+		return
+	}
+	threshold := faucet.Amount.ToInt()
+	if faucet.Threshold != nil {
+		threshold = faucet.Threshold.ToInt()
+	}
+	if balance := harness.statedb.GetBalance(sender); balance != nil && balance.Cmp(threshold) >= 0 {
+		return
+	}
+	if !harness.statedb.Exist(sender) {
+		harness.statedb.CreateAccount(sender)
+	}
+	harness.statedb.AddBalance(sender, faucet.Amount.ToInt())
+}
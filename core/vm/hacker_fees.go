@@ -0,0 +1,70 @@
+/**
+* @hacker_fees.go
+* London fee market (EIP-1559) for the instrumented execution path.
+* 1 the block base fee is Context.BaseFee (BASEFEE), nil for the blocks before London.
+* 2 a dynamic fee message pays min(fee cap, base fee + tip cap) per gas, this effective
+*   price is the GASPRICE of its context. A message whose fee cap is below the base fee
+*   is refused before it executes.
+* 3 the report of a transaction watched in a London context carries the fees it paid
+*   ("fees"), campaigns targeting contracts that read BASEFEE compare them.
+ */
+package vm
+
+import (
+	"math/big"
+)
+
+// EffectiveGasPrice returns the price per gas msg pays in a block of base fee baseFee,
+// gasPrice for a legacy message.
+func (msg *HackerMessage) EffectiveGasPrice(baseFee, gasPrice *big.Int) (*big.Int, error) {
+	if msg.GasFeeCap == nil {
+		return gasPrice, nil
+	}
+	return EffectiveGasPrice(baseFee, msg.GasFeeCap, msg.GasTipCap)
+}
+
+// EffectiveGasPrice returns the price per gas of a dynamic fee transaction with the
+// given fee and tip caps in a block of base fee baseFee, nil before London.
+func EffectiveGasPrice(baseFee, feeCap, tipCap *big.Int) (*big.Int, error) {
+	if tipCap == nil {
+		tipCap = new(big.Int)
+	}
+	if tipCap.Cmp(feeCap) > 0 {
+		return nil, ErrTipAboveFeeCap
+	}
+	if baseFee == nil {
+		return new(big.Int).Set(feeCap), nil
+	}
+	if feeCap.Cmp(baseFee) < 0 {
+		return nil, ErrFeeCapTooLow
+	}
+	price := new(big.Int).Add(baseFee, tipCap)
+	if price.Cmp(feeCap) > 0 {
+		price.Set(feeCap)
+	}
+	return price, nil
+}
+
+// HackerFees is the "fees" section of the WatchDog report.
+type HackerFees struct {
+	BaseFee  string `json:"baseFee"`
+	GasPrice string `json:"gasPrice"`
+	// Tip is the part of the gas price above the base fee, paid to the coinbase.
+	Tip string `json:"tip"`
+}
+
+// fees returns the fees of the watched transaction, nil outside of a London context.
+func (dog *WatchDog) fees() *HackerFees {
+	if dog.env == nil || dog.env.BaseFee == nil {
+		return nil
+	}
+	price := dog.env.GasPrice
+	if price == nil {
+		price = new(big.Int)
+	}
+	tip := new(big.Int).Sub(price, dog.env.BaseFee)
+	if tip.Sign() < 0 {
+		tip.SetUint64(0)
+	}
+	return &HackerFees{BaseFee: dog.env.BaseFee.Text(10), GasPrice: price.Text(10), Tip: tip.Text(10)}
+}
@@ -0,0 +1,117 @@
+/**
+* @hacker_feeskew.go
+* Gas price and base fee sensitivity of watched transactions.
+* 1 when enabled (SetFeeSkew), Watch() replays the transaction on a fork of the
+*   pre-state as is, then once per fee variant with the gas price seen by GASPRICE
+*   and the base fee seen by BASEFEE replaced by the ones of the variant (a variant
+*   leaves the value it has none of as is). By default, each is set to 0 then to
+*   1000 gwei.
+* 2 the price paid by a dynamic fee message follows the base fee of the variant, a base
+*   fee above its fee cap makes the replay fail.
+* 3 every replay is compared with the original one, the divergences are the "feeSkew"
+*   section of the report with the share of the variants changing the outcome: the
+*   refunds or the accesses decided on the gas pricing.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HackerFeeVariant replaces the gas price and the base fee of a replay, nil keeps the
+// one of the context.
+type HackerFeeVariant struct {
+	GasPrice *hexutil.Big `json:"gasPrice,omitempty"`
+	BaseFee  *hexutil.Big `json:"baseFee,omitempty"`
+}
+
+// hackerFeeHigh is the high price of the default variants, 1000 gwei.
+var hackerFeeHigh = new(big.Int).Exp(big.NewInt(10), big.NewInt(12), nil)
+
+// hackerDefaultFeeVariants are the fee variants replayed by default.
+var hackerDefaultFeeVariants = []HackerFeeVariant{
+	{GasPrice: (*hexutil.Big)(new(big.Int))},
+	{GasPrice: (*hexutil.Big)(hackerFeeHigh)},
+	{BaseFee: (*hexutil.Big)(new(big.Int))},
+	{BaseFee: (*hexutil.Big)(hackerFeeHigh)},
+}
+
+// HackerFeeSkew compares the replay of a message with a fee variant.
+type HackerFeeSkew struct {
+	HackerFeeVariant
+	Changed bool     `json:"changed"`
+	Reasons []string `json:"reasons"`
+	Failed  bool     `json:"failed"`
+}
+
+// HackerFeeSkewReport is the "feeSkew" section of the WatchDog report.
+type HackerFeeSkewReport struct {
+	Target         common.Address  `json:"target"`
+	OriginalFailed bool            `json:"originalFailed"`
+	Variants       []HackerFeeSkew `json:"variants"`
+	// Sensitivity is the share of the variants changing the outcome, from 0 to 1
+	Sensitivity float64 `json:"sensitivity"`
+}
+
+// SetFeeSkew turns the fee variant replays of the watched transactions on or off,
+// with variants or the default ones when empty.
+func (c *HackerCampaign) SetFeeSkew(on bool, variants []HackerFeeVariant) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.feeVariants = nil
+	if on {
+		c.feeVariants = append([]HackerFeeVariant{}, hackerDefaultFeeVariants...)
+		if len(variants) != 0 {
+			c.feeVariants = append([]HackerFeeVariant{}, variants...)
+		}
+	}
+}
+
+// FeeVariants returns the fee variants replayed, nil when the replays are off.
+func (c *HackerCampaign) FeeVariants() []HackerFeeVariant {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]HackerFeeVariant(nil), c.feeVariants...)
+}
+
+// FeeSkew executes msg as is, then once per fee variant, and compares the outcomes.
+func (harness *HackerHarness) FeeSkew(msg *HackerMessage, variants []HackerFeeVariant) *HackerFeeSkewReport {
+	if msg.To == nil {
+		return nil
+	}
+	original := harness.Execute(msg)
+	report := &HackerFeeSkewReport{Target: *msg.To, OriginalFailed: original.Failed(), Variants: make([]HackerFeeSkew, 0, len(variants))}
+	changed := 0
+	for _, variant := range variants {
+		ctx := harness.ctx
+		if variant.GasPrice != nil {
+			ctx.GasPrice = new(big.Int).Set(variant.GasPrice.ToInt())
+		}
+		if variant.BaseFee != nil {
+			ctx.BaseFee = new(big.Int).Set(variant.BaseFee.ToInt())
+		}
+		skewed := harness.derive(ctx, harness.statedb, harness.vmConfig).Execute(msg)
+		skew := HackerFeeSkew{HackerFeeVariant: variant, Reasons: original.Diff(skewed), Failed: skewed.Failed()}
+		if skew.Changed = len(skew.Reasons) > 0; skew.Changed {
+			changed++
+		}
+		report.Variants = append(report.Variants, skew)
+	}
+	if len(variants) != 0 {
+		report.Sensitivity = float64(changed) / float64(len(variants))
+	}
+	return report
+}
+
+func (dog *WatchDog) skewFees(env *EVM, tx *types.Transaction) {
+	dog.feeSkew = nil
+	variants := GetGlobalCampaign().FeeVariants()
+	if variants == nil || env == nil {
+		return
+	}
+	dog.feeSkew = newHackerHarnessFrom(env).FeeSkew(hackerTxMessage(env, tx), variants)
+}
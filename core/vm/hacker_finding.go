@@ -0,0 +1,37 @@
+/**
+* @hacker_finding.go
+* 1 a finding is a typed verdict raised by an oracle during one watched execution.
+* 2 findings are collected by the WatchDog and sent with its report under "findings".
+* 3 before the report is sent, findings are replayed against attacker templates and
+*   their status tells whether the exploit was confirmed (see hacker_confirm.go).
+ */
+package vm
+
+// HackerFinding is one typed oracle verdict attached to the WatchDog report.
+type HackerFinding struct {
+	Type   string            `json:"type"`
+	Source string            `json:"source"`
+	Detail map[string]string `json:"detail,omitempty"`
+	Status string            `json:"status,omitempty"`
+}
+
+func newHackerFinding(kind, source string) *HackerFinding {
+	return &HackerFinding{Type: kind, Source: source, Detail: make(map[string]string)}
+}
+
+// EmitFinding records a finding for the transaction currently being watched.
+func (dog *WatchDog) EmitFinding(finding *HackerFinding) {
+	if dog.watching() && finding != nil {
+		dog.lock.Lock()
+		dog.findings = append(dog.findings, *finding)
+		dog.lock.Unlock()
+		dog.annotateFinding(finding.Type)
+	}
+}
+
+// Findings returns a copy of the findings recorded so far for the watched transaction.
+func (dog *WatchDog) Findings() []HackerFinding {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	return append([]HackerFinding(nil), dog.findings...)
+}
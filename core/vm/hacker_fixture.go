@@ -0,0 +1,106 @@
+/**
+* @hacker_fixture.go
+* Contract fixtures: the full state of a contract, to set a campaign up from files or
+* share the state of a target between nodes.
+* 1 a fixture is dumped from a state: code, balance, nonce and every slot of the
+*   storage (trie iteration).
+* 2 the fixtures loaded into the campaign are installed, as a state override replacing
+*   the whole account, on the fork of every execution of the harnesses of the fuzz API
+*   (SetFixtures), before the overrides of the call. The canonical state is never
+*   touched.
+ */
+package vm
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// HackerContractFixture is the full state of a contract.
+type HackerContractFixture struct {
+	Address common.Address              `json:"address"`
+	Code    hexutil.Bytes               `json:"code"`
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   hexutil.Uint64              `json:"nonce"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// DumpContract returns the fixture of addr in statedb.
+func DumpContract(statedb StateDB, addr common.Address) *HackerContractFixture {
+	fixture := &HackerContractFixture{Address: addr, Code: common.CopyBytes(statedb.GetCode(addr)), Balance: (*hexutil.Big)(new(big.Int).Set(statedb.GetBalance(addr))), Nonce: hexutil.Uint64(statedb.GetNonce(addr)), Storage: make(map[common.Hash]common.Hash)}
+	statedb.ForEachStorage(addr, func(key, value common.Hash) bool {
+		if value != (common.Hash{}) {
+			fixture.Storage[key] = value
+		}
+		return true
+	})
+	return fixture
+}
+
+// override returns the override installing the fixture.
+func (fixture *HackerContractFixture) override() *HackerAccountOverride {
+	nonce := uint64(fixture.Nonce)
+	override := &HackerAccountOverride{Nonce: &nonce, Code: []byte(fixture.Code), State: fixture.Storage}
+	if override.Code == nil {
+		override.Code = []byte{}
+	}
+	if override.State == nil {
+		override.State = make(map[common.Hash]common.Hash)
+	}
+	if fixture.Balance != nil {
+		override.Balance = fixture.Balance.ToInt()
+	}
+	return override
+}
+
+// LoadFixture installs fixture on the forks of the fuzz API, replacing the fixture of
+// its address.
+func (c *HackerCampaign) LoadFixture(fixture *HackerContractFixture) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fixtures[fixture.Address] = fixture
+}
+
+// UnloadFixture drops the fixture of addr.
+func (c *HackerCampaign) UnloadFixture(addr common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.fixtures, addr)
+}
+
+// Fixtures returns the fixtures loaded, by address.
+func (c *HackerCampaign) Fixtures() []*HackerContractFixture {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	fixtures := make([]*HackerContractFixture, 0, len(c.fixtures))
+	for _, fixture := range c.fixtures {
+		fixtures = append(fixtures, fixture)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Address.Hex() < fixtures[j].Address.Hex() })
+	return fixtures
+}
+
+// FixtureOverride returns the override installing the fixtures loaded.
+func (c *HackerCampaign) FixtureOverride() HackerStateOverride {
+	override := make(HackerStateOverride)
+	for _, fixture := range c.Fixtures() {
+		override[fixture.Address] = fixture.override()
+	}
+	return override
+}
+
+// SetFixtures installs override on the fork of every execution of the harness.
+func (harness *HackerHarness) SetFixtures(override HackerStateOverride) {
+	harness.fixtures = override
+}
+
+// DumpContract returns the fixture of addr as the executions of the harness see it.
+func (harness *HackerHarness) DumpContract(addr common.Address) (fixture *HackerContractFixture) {
+	harness.Fork(func() {
+		fixture = DumpContract(harness.statedb, addr)
+	})
+	return fixture
+}
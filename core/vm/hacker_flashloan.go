@@ -0,0 +1,124 @@
+/**
+* @hacker_flashloan.go
+* Flash-loan scenarios: a candidate message executed with borrowed capital.
+* 1 before the message the sender is lent ether and ERC-20 tokens by a state override
+*   (hacker_override.go): its ether balance and the balance slots of the tokens are
+*   raised by the amounts. A token balance is read and written at keccak256(holder,
+*   slot) of the balances mapping at slot, the Solidity layout.
+* 2 after the message the loan is repaid: every amount is taken back from the balances
+*   of the sender. What is left over the balances before the loan is its profit per
+*   asset, the loan is repaid when no profit is negative. A real lender reverts the
+*   transaction otherwise.
+* 3 the exploits needing more capital than the sender holds are found by mutating the
+*   message, e.g. a price manipulation through a pool, and keeping the repaid ones with
+*   a positive profit.
+* Gas is not bought by the harness, it is left out of the profit.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// HackerTokenLoan is an amount of an ERC-20 token lent.
+type HackerTokenLoan struct {
+	Token common.Address
+	// Slot is the storage slot of the balances mapping of the token
+	Slot   common.Hash
+	Amount *big.Int
+}
+
+// HackerFlashLoan is what the sender of a message borrows for its execution.
+type HackerFlashLoan struct {
+	Ether  *big.Int
+	Tokens []HackerTokenLoan
+}
+
+// HackerLoanResult is the settlement of a flash loan.
+type HackerLoanResult struct {
+	Repaid bool
+	// Profits is the balance of the sender once the loan is repaid minus its balance
+	// before the loan, per token and for ether under the zero address
+	Profits map[common.Address]*big.Int
+}
+
+// hackerBalanceSlot returns the slot of the balance of holder in the mapping at slot.
+func hackerBalanceSlot(holder common.Address, slot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(common.LeftPadBytes(holder.Bytes(), 32), slot.Bytes())
+}
+
+// balances returns the balances of holder in the assets of the loan, ether under the
+// zero address.
+func (loan *HackerFlashLoan) balances(statedb StateDB, holder common.Address) map[common.Address]*big.Int {
+	balances := map[common.Address]*big.Int{common.Address{}: new(big.Int).Set(statedb.GetBalance(holder))}
+	for _, token := range loan.Tokens {
+		balances[token.Token] = statedb.GetState(token.Token, hackerBalanceSlot(holder, token.Slot)).Big()
+	}
+	return balances
+}
+
+// amounts returns the amounts lent per asset, ether under the zero address.
+func (loan *HackerFlashLoan) amounts() map[common.Address]*big.Int {
+	amounts := map[common.Address]*big.Int{common.Address{}: new(big.Int)}
+	if loan.Ether != nil {
+		amounts[common.Address{}].Set(loan.Ether)
+	}
+	for _, token := range loan.Tokens {
+		if amounts[token.Token] == nil {
+			amounts[token.Token] = new(big.Int)
+		}
+		if token.Amount != nil {
+			amounts[token.Token].Add(amounts[token.Token], token.Amount)
+		}
+	}
+	return amounts
+}
+
+// override returns the state override lending the loan to holder, whose balances are
+// before.
+func (loan *HackerFlashLoan) override(holder common.Address, before map[common.Address]*big.Int) HackerStateOverride {
+	amounts := loan.amounts()
+	account := &HackerAccountOverride{Balance: new(big.Int).Add(before[common.Address{}], amounts[common.Address{}])}
+	override := HackerStateOverride{holder: account}
+	for _, token := range loan.Tokens {
+		lent := new(big.Int).Add(before[token.Token], amounts[token.Token])
+		slot := hackerBalanceSlot(holder, token.Slot)
+		if existing, ok := override[token.Token]; ok {
+			existing.StateDiff[slot] = common.BigToHash(lent)
+			continue
+		}
+		override[token.Token] = &HackerAccountOverride{StateDiff: map[common.Hash]common.Hash{slot: common.BigToHash(lent)}}
+	}
+	return override
+}
+
+// settle repays the loan out of the balances after and returns the profits over the
+// balances before.
+func (loan *HackerFlashLoan) settle(before, after map[common.Address]*big.Int) *HackerLoanResult {
+	result := &HackerLoanResult{Repaid: true, Profits: make(map[common.Address]*big.Int)}
+	for asset, amount := range loan.amounts() {
+		profit := new(big.Int).Sub(after[asset], amount)
+		profit.Sub(profit, before[asset])
+		if profit.Sign() < 0 {
+			result.Repaid = false
+		}
+		result.Profits[asset] = profit
+	}
+	return result
+}
+
+// ExecuteFlashLoan runs msg on a fork of the state with override applied first and the
+// loan lent to the sender, and settles the loan in the outcome.
+func (harness *HackerHarness) ExecuteFlashLoan(msg *HackerMessage, loan *HackerFlashLoan, override HackerStateOverride) (outcome *HackerOutcome) {
+	harness.Fork(func() {
+		override.Apply(harness.statedb)
+		before := loan.balances(harness.statedb, msg.From)
+		loan.override(msg.From, before).Apply(harness.statedb)
+		outcome = harness.Apply(msg)
+		outcome.Loan = loan.settle(before, loan.balances(harness.statedb, msg.From))
+	})
+	return outcome
+}
@@ -0,0 +1,89 @@
+/**
+* @hacker_fork.go
+* Rules of the forks after Byzantium. The chain config of this tree ends at Byzantium,
+* the later forks are an opt-in of the campaign instead of being assumed:
+* 1 a block of a Byzantium chain runs the Byzantium instructions unless the campaign
+*   opted in to a later fork (HackerCampaign.SetFork, --fuzz.fork), whose instruction
+*   set, precompiled contracts and gas rules then apply to every block from Byzantium
+*   on. The forks are Byzantium, Constantinople, Istanbul, Berlin, London and Shanghai,
+*   each one with the rules of the ones before it.
+* 2 the rules do not depend on the hooks, the frames run the same with them on and off
+*   (hacker_hooks.go). --fuzz.off applies no other flag: the node runs the rules of its
+*   chain config.
+* 3 the rules are fixed when the EVM is created or reset, an EVM running keeps them.
+ */
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// HackerFork is a fork after Byzantium the campaign can opt in to.
+type HackerFork int
+
+const (
+	ForkByzantium HackerFork = iota
+	ForkConstantinople
+	ForkIstanbul
+	ForkBerlin
+	ForkLondon
+	ForkShanghai
+)
+
+var hackerForkNames = []string{"byzantium", "constantinople", "istanbul", "berlin", "london", "shanghai"}
+
+func (fork HackerFork) String() string {
+	if fork < 0 || int(fork) >= len(hackerForkNames) {
+		return fmt.Sprintf("fork(%d)", int(fork))
+	}
+	return hackerForkNames[fork]
+}
+
+// ParseFork returns the fork named name, case insensitive.
+func ParseFork(name string) (HackerFork, error) {
+	for fork, known := range hackerForkNames {
+		if strings.EqualFold(name, known) {
+			return HackerFork(fork), nil
+		}
+	}
+	return ForkByzantium, fmt.Errorf("unknown fork %q, want one of %s", name, strings.Join(hackerForkNames, ", "))
+}
+
+// hackerRules are the rules of the forks after Byzantium an EVM runs with.
+type hackerRules struct {
+	IsConstantinople, IsIstanbul, IsBerlin, IsLondon, IsShanghai bool
+}
+
+// hackerForkRules returns the rules after Byzantium of a block under chainRules: those
+// of the fork of the campaign when the block is past Byzantium.
+func hackerForkRules(chainRules params.Rules) hackerRules {
+	if !chainRules.IsByzantium {
+		return hackerRules{}
+	}
+	fork := GetGlobalCampaign().Fork()
+	return hackerRules{
+		IsConstantinople: fork >= ForkConstantinople,
+		IsIstanbul:       fork >= ForkIstanbul,
+		IsBerlin:         fork >= ForkBerlin,
+		IsLondon:         fork >= ForkLondon,
+		IsShanghai:       fork >= ForkShanghai,
+	}
+}
+
+// SetFork opts the campaign in to the rules of fork for the blocks from Byzantium on,
+// ForkByzantium keeps the rules of the chain config.
+func (c *HackerCampaign) SetFork(fork HackerFork) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fork = fork
+}
+
+// Fork returns the fork the campaign opted in to.
+func (c *HackerCampaign) Fork() HackerFork {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.fork
+}
@@ -0,0 +1,107 @@
+/**
+* @hacker_forward.go
+* Calldata forwarding: a proxy, a router or a wallet copying its whole calldata
+* (CALLDATACOPY from offset 0 over all of it) into the input of a call forwards the
+* function called to its callee, the selector is the one of the callee.
+* 1 a frame entered with the input of its parent, after the parent copied its whole
+*   calldata, is forwarded by the parent: the report gives the parent of every
+*   forwarded frame ("forwardedFrom" of the frames) and the chains of forwarding, from
+*   the first forwarder to the frame running the function ("forwarding"), with its
+*   code address: the implementation behind a DELEGATECALL.
+* 2 the selector of a watched transaction whose calldata is forwarded by its target is
+*   counted under the implementation too, the statistics of the selector in the
+*   report are those of the implementation: the fuzzer schedules the functions of the
+*   code that runs them, whatever proxy they were sent to.
+ */
+package vm
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerForwardChain is a chain of frames forwarding the same calldata.
+type HackerForwardChain struct {
+	Selector string `json:"selector"`
+	// Frames are the indexes of the frames, the first forwarder first
+	Frames []int `json:"frames"`
+	// Implementation is the code address of the last frame, which runs the function
+	Implementation common.Address `json:"implementation"`
+}
+
+// copyCalldata runs before op executes in frame: a copy of the whole calldata to
+// memory makes its calls forwarding candidates.
+func (journal *hackerFrameJournal) copyCalldata(op OpCode, frame int, stack *Stack) {
+	if op != CALLDATACOPY || frame < 0 || stack.len() < 3 {
+		return
+	}
+	input := journal.frames[frame].Input()
+	if stack.Back(1).Sign() == 0 && len(input) > 0 && stack.Back(2).Cmp(new(big.Int).SetUint64(uint64(len(input)))) >= 0 {
+		journal.frames[frame].copied = true
+	}
+}
+
+// forwarded tells whether the frame about to be entered with parent forwards the
+// calldata of its parent.
+func (journal *hackerFrameJournal) forwarded(parent int, contract *Contract) bool {
+	if parent < 0 || !journal.frames[parent].copied {
+		return false
+	}
+	input := journal.frames[parent].Input()
+	return len(input) > 0 && bytes.Equal(contract.Input, input)
+}
+
+// codeAddress returns the address of the code the frame runs.
+func (frame *hackerFrame) codeAddress() common.Address {
+	if frame.contract.CodeAddr != nil {
+		return *frame.contract.CodeAddr
+	}
+	return frame.Address()
+}
+
+// forwardChains returns the chains of forwarding, from the frames forwarding their
+// calldata without being forwarded themselves.
+func (journal *hackerFrameJournal) forwardChains() []HackerForwardChain {
+	chains := make([]HackerForwardChain, 0)
+	for i := range journal.frames {
+		if journal.frames[i].forwarder >= 0 {
+			continue
+		}
+		chain := []int{i}
+		for last := i; ; {
+			next := -1
+			for j := last + 1; j < len(journal.frames); j++ {
+				if journal.frames[j].forwarder == last {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				break
+			}
+			chain, last = append(chain, next), next
+		}
+		if len(chain) > 1 {
+			last := &journal.frames[chain[len(chain)-1]]
+			chains = append(chains, HackerForwardChain{Selector: hackerSelectorKey(last.Input()), Frames: chain, Implementation: last.codeAddress()})
+		}
+	}
+	return chains
+}
+
+// forwardedTo returns the implementation the calldata of the watched transaction is
+// forwarded to, false when its target runs it.
+func (dog *WatchDog) forwardedTo() (common.Address, bool) {
+	if dog.taint == nil {
+		return common.Address{}, false
+	}
+	journal := dog.taint.journal
+	for _, chain := range journal.forwardChains() {
+		if journal.frames[chain.Frames[0]].parent < 0 {
+			return chain.Implementation, true
+		}
+	}
+	return common.Address{}, false
+}
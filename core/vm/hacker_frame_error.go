@@ -0,0 +1,161 @@
+/**
+* @hacker_frame_error.go
+* Why a frame failed, as an enum instead of comparing error strings.
+* The kind is stored on every HackerContractCall when it closes and is reported with
+* its text name ("out_of_gas", "invalid_opcode", ...). The reason of a revert is decoded
+* from its data, Error(string) and Panic(uint256) as raised by Solidity.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerFrameError classifies the error a frame ended with.
+type HackerFrameError uint8
+
+const (
+	FrameOK HackerFrameError = iota
+	FrameOutOfGas
+	FrameInvalidOpcode
+	FrameInvalidJump
+	FrameStackUnderflow
+	FrameStackOverflow
+	FrameRevert
+	FrameDepth
+	FrameInsufficientBalance
+	FrameWriteProtection
+	FrameOther
+)
+
+var frameErrorNames = map[HackerFrameError]string{
+	FrameOK:                  "ok",
+	FrameOutOfGas:            "out_of_gas",
+	FrameInvalidOpcode:       "invalid_opcode",
+	FrameInvalidJump:         "invalid_jump",
+	FrameStackUnderflow:      "stack_underflow",
+	FrameStackOverflow:       "stack_overflow",
+	FrameRevert:              "revert",
+	FrameDepth:               "depth",
+	FrameInsufficientBalance: "insufficient_balance",
+	FrameWriteProtection:     "write_protection",
+	FrameOther:               "other",
+}
+
+func (kind HackerFrameError) String() string {
+	if name, ok := frameErrorNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("HackerFrameError(%d)", uint8(kind))
+}
+
+// MarshalText reports the kind by name.
+func (kind HackerFrameError) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+// classifyFrameError returns the kind of err, FrameOK for nil.
+func classifyFrameError(err error) HackerFrameError {
+	switch err.(type) {
+	case nil:
+		return FrameOK
+	case *ErrInvalidOpCode:
+		return FrameInvalidOpcode
+	case *ErrInvalidJump:
+		return FrameInvalidJump
+	case *ErrStackUnderflow:
+		return FrameStackUnderflow
+	case *ErrStackOverflow:
+		return FrameStackOverflow
+	}
+	switch err {
+	case ErrOutOfGas, ErrCodeStoreOutOfGas, errGasUintOverflow:
+		return FrameOutOfGas
+	case ErrDepth:
+		return FrameDepth
+	case ErrInsufficientBalance:
+		return FrameInsufficientBalance
+	case ErrWriteProtection:
+		return FrameWriteProtection
+	case ErrExecutionReverted:
+		return FrameRevert
+	}
+	return FrameOther
+}
+
+var (
+	revertErrorSelector = [4]byte{0x08, 0xc3, 0x79, 0xa0}
+	revertPanicSelector = [4]byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// revertReason returns the reason encoded in the data of a revert, empty when there is
+// none or it cannot be decoded.
+func revertReason(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	args := data[4:]
+	switch hackerSelector(data) {
+	case revertErrorSelector:
+		if len(args) < 64 {
+			return ""
+		}
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(args))-32 {
+			return ""
+		}
+		start := offset.Uint64() + 32
+		size := new(big.Int).SetBytes(args[offset.Uint64():start])
+		if !size.IsUint64() || size.Uint64() > uint64(len(args))-start {
+			return ""
+		}
+		return string(args[start : start+size.Uint64()])
+	case revertPanicSelector:
+		if len(args) < 32 {
+			return ""
+		}
+		return "panic: 0x" + new(big.Int).SetBytes(args[:32]).Text(16)
+	}
+	return ""
+}
+
+// setError records the error the frame closed with.
+func (call *HackerContractCall) setError(err error) {
+	call.errKind = classifyFrameError(err)
+	call.throwException = call.errKind != FrameOK
+	call.errOutGas = call.errKind == FrameOutOfGas
+	call.errOutBalance = call.errKind == FrameInsufficientBalance
+}
+
+// ErrorKind returns why the frame failed, FrameOK if it did not.
+func (call *HackerContractCall) ErrorKind() HackerFrameError {
+	return call.errKind
+}
+
+// hacker_refused_call records a call of evm refused before it started (depth, balance)
+// as a failed leaf frame of the call being recorded, these never reach hacker_init.
+func hacker_refused_call(evm *EVM, op OpCode, caller ContractRef, callee common.Address, value *big.Int, gas uint64, input []byte, err error) {
+	tree := evm.callTree
+	if tree == nil || tree.stack.len() == 0 {
+		return
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	var call *HackerContractCall
+	switch op {
+	case DELEGATECALL:
+		call = tree.stack.peek().OnDelegateCall(caller, callee, *new(big.Int).SetUint64(gas), input)
+	case STATICCALL:
+		call = tree.stack.peek().OnStaticCall(caller, callee, *new(big.Int).SetUint64(gas), input)
+	case CALLCODE:
+		call = tree.stack.peek().OnCallCode(caller, callee, *value, *new(big.Int).SetUint64(gas), input)
+	default:
+		call = tree.stack.peek().OnCall(caller, callee, *value, *new(big.Int).SetUint64(gas), input)
+	}
+	call.setError(err)
+	call.OnCloseCall(*new(big.Int).SetUint64(gas))
+}
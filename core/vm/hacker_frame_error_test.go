@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestClassifyFrameError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want HackerFrameError
+	}{
+		{nil, FrameOK},
+		{ErrOutOfGas, FrameOutOfGas},
+		{ErrCodeStoreOutOfGas, FrameOutOfGas},
+		{&ErrInvalidOpCode{opcode: 0xfe}, FrameInvalidOpcode},
+		{newstack().require(1), FrameStackUnderflow},
+		{ErrDepth, FrameDepth},
+		{ErrInsufficientBalance, FrameInsufficientBalance},
+		{ErrWriteProtection, FrameWriteProtection},
+		{ErrExecutionReverted, FrameRevert},
+		{errors.New("out of gas"), FrameOther},
+	}
+	for i, test := range tests {
+		if kind := classifyFrameError(test.err); kind != test.want {
+			t.Errorf("test %d: got %v, want %v", i, kind, test.want)
+		}
+	}
+	if msg := (&ErrInvalidOpCode{opcode: 0xfe}).Error(); msg != "invalid opcode 0xfe" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestRevertReason(t *testing.T) {
+	reason := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"6e6f000000000000000000000000000000000000000000000000000000000000")
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{nil, ""},
+		{reason, "no"},
+		{reason[:68], ""},
+		{common.FromHex("0x4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011"), "panic: 0x11"},
+		{common.FromHex("0x4e487b71"), ""},
+		{common.FromHex("0x12345678" + "0000000000000000000000000000000000000000000000000000000000000020"), ""},
+	}
+	for i, test := range tests {
+		if got := revertReason(test.data); got != test.want {
+			t.Errorf("test %d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
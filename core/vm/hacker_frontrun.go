@@ -0,0 +1,78 @@
+/**
+* @hacker_frontrun.go
+* Front running sensitivity of watched transactions.
+* When enabled, Watch() replays the transaction on a fork of the pre-state twice:
+*   1 as is,
+*   2 after a competitor transaction with the same calldata from another sender,
+* and the WatchDog report tells whether the outcome of the original changed.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HackerFrontRunReport is the "frontRunning" section of the WatchDog report.
+type HackerFrontRunReport struct {
+	Competitor       common.Address `json:"competitor"`
+	Changed          bool           `json:"changed"`
+	Reasons          []string       `json:"reasons"`
+	OriginalFailed   bool           `json:"originalFailed"`
+	FrontRunFailed   bool           `json:"frontRunFailed"`
+	CompetitorFailed bool           `json:"competitorFailed"`
+}
+
+// FrontRun executes msg alone and after a copy of it sent by competitor, and compares both outcomes.
+func (harness *HackerHarness) FrontRun(msg *HackerMessage, competitor common.Address) *HackerFrontRunReport {
+	original := harness.Execute(msg)
+
+	var competing, frontrun *HackerOutcome
+	harness.Fork(func() {
+		rival := *msg
+		rival.From = competitor
+		competing = harness.Apply(&rival)
+		frontrun = harness.Apply(msg)
+	})
+	reasons := original.Diff(frontrun)
+	return &HackerFrontRunReport{
+		Competitor:       competitor,
+		Changed:          len(reasons) > 0,
+		Reasons:          reasons,
+		OriginalFailed:   original.Failed(),
+		FrontRunFailed:   frontrun.Failed(),
+		CompetitorFailed: competing.Failed(),
+	}
+}
+
+// EnableFrontRunning turns on the front running replay of watched transactions,
+// the competitor transactions are sent from competitor.
+func (dog *WatchDog) EnableFrontRunning(competitor common.Address) {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	dog.competitor = &competitor
+}
+
+// DisableFrontRunning turns the front running replay off.
+func (dog *WatchDog) DisableFrontRunning() {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	dog.competitor = nil
+}
+
+// frontRunner returns the sender of the competitor transactions, nil when the front
+// running replay is off.
+func (dog *WatchDog) frontRunner() *common.Address {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	return dog.competitor
+}
+
+func (dog *WatchDog) frontRun(env *EVM, tx *types.Transaction) {
+	dog.frontrun = nil
+	competitor := dog.frontRunner()
+	if competitor == nil || env == nil {
+		return
+	}
+	dog.frontrun = newHackerHarnessFrom(env).FrontRun(hackerTxMessage(env, tx), *competitor)
+}
@@ -0,0 +1,172 @@
+/**
+* @hacker_gas.go
+* Gas regression tracking per (code hash, selector) over a campaign.
+* 1 the gas used by every watched transaction is accumulated (count, mean, variance)
+*   under the code hash of its target and the selector of its calldata.
+* 2 when a target is redeployed with a different code, the previous code hash becomes
+*   its baseline; every selector is then compared with Welch's t test and a change
+*   beyond hackerGasSignificance is reported once as a "gas_regression" finding.
+ */
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// hackerGasMinSamples is the number of samples needed on both sides of a comparison.
+	hackerGasMinSamples = 5
+	// hackerGasSignificance is the |t| above which a change is reported.
+	hackerGasSignificance = 3.0
+)
+
+type hackerGasKey struct {
+	code     common.Hash
+	selector [4]byte
+}
+
+// hackerGasStats is a running mean and variance (Welford).
+type hackerGasStats struct {
+	n    uint64
+	mean float64
+	m2   float64
+}
+
+func (stats *hackerGasStats) add(x float64) {
+	stats.n++
+	delta := x - stats.mean
+	stats.mean += delta / float64(stats.n)
+	stats.m2 += delta * (x - stats.mean)
+}
+
+func (stats *hackerGasStats) variance() float64 {
+	if stats.n < 2 {
+		return 0
+	}
+	return stats.m2 / float64(stats.n-1)
+}
+
+// welchT returns Welch's t statistic of b against a.
+func welchT(a, b *hackerGasStats) float64 {
+	se := math.Sqrt(a.variance()/float64(a.n) + b.variance()/float64(b.n))
+	if se == 0 {
+		if a.mean == b.mean {
+			return 0
+		}
+		// constant samples on both sides, any change is significant (no Inf, it has to marshal)
+		return math.Copysign(math.MaxFloat64, b.mean-a.mean)
+	}
+	return (b.mean - a.mean) / se
+}
+
+// HackerGasRegression is a significant gas change of one selector between two deployments.
+type HackerGasRegression struct {
+	Address     common.Address `json:"address"`
+	Selector    string         `json:"selector"`
+	OldCodeHash common.Hash    `json:"oldCodeHash"`
+	NewCodeHash common.Hash    `json:"newCodeHash"`
+	OldMean     float64        `json:"oldMean"`
+	NewMean     float64        `json:"newMean"`
+	T           float64        `json:"t"`
+}
+
+type hackerGasTracker struct {
+	stats       map[hackerGasKey]*hackerGasStats
+	code        map[common.Address]common.Hash
+	baseline    map[common.Address]common.Hash
+	reported    map[string]bool
+	regressions []HackerGasRegression
+}
+
+func newHackerGasTracker() *hackerGasTracker {
+	return &hackerGasTracker{
+		stats:       make(map[hackerGasKey]*hackerGasStats),
+		code:        make(map[common.Address]common.Hash),
+		baseline:    make(map[common.Address]common.Hash),
+		reported:    make(map[string]bool),
+		regressions: make([]HackerGasRegression, 0),
+	}
+}
+
+func hackerSelector(input []byte) [4]byte {
+	var selector [4]byte
+	copy(selector[:], input)
+	return selector
+}
+
+// record adds a sample and returns the regression it reveals, if any.
+func (tracker *hackerGasTracker) record(addr common.Address, code common.Hash, input []byte, gas uint64) *HackerGasRegression {
+	if current, ok := tracker.code[addr]; ok && current != code {
+		tracker.baseline[addr] = current
+	}
+	tracker.code[addr] = code
+
+	key := hackerGasKey{code: code, selector: hackerSelector(input)}
+	stats, ok := tracker.stats[key]
+	if !ok {
+		stats = new(hackerGasStats)
+		tracker.stats[key] = stats
+	}
+	stats.add(float64(gas))
+
+	old, ok := tracker.baseline[addr]
+	if !ok {
+		return nil
+	}
+	before := tracker.stats[hackerGasKey{code: old, selector: key.selector}]
+	if before == nil || before.n < hackerGasMinSamples || stats.n < hackerGasMinSamples {
+		return nil
+	}
+	id := fmt.Sprintf("%x:%x:%x", old, code, key.selector)
+	t := welchT(before, stats)
+	if tracker.reported[id] || math.Abs(t) < hackerGasSignificance {
+		return nil
+	}
+	tracker.reported[id] = true
+	regression := HackerGasRegression{
+		Address:     addr,
+		Selector:    fmt.Sprintf("0x%x", key.selector),
+		OldCodeHash: old,
+		NewCodeHash: code,
+		OldMean:     before.mean,
+		NewMean:     stats.mean,
+		T:           t,
+	}
+	tracker.regressions = append(tracker.regressions, regression)
+	return &regression
+}
+
+// RecordGas adds the gas used by a call of input to addr running the code with hash code.
+func (c *HackerCampaign) RecordGas(addr common.Address, code common.Hash, input []byte, gas uint64) *HackerGasRegression {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.gas.record(addr, code, input, gas)
+}
+
+// GasRegressions returns the regressions reported since the campaign started.
+func (c *HackerCampaign) GasRegressions() []HackerGasRegression {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]HackerGasRegression{}, c.gas.regressions...)
+}
+
+// recordGas feeds the campaign with the gas used by the watched transaction.
+func (dog *WatchDog) recordGas(gasUsed uint64) {
+	to := *dog.tx.To()
+	regression := GetGlobalCampaign().RecordGas(to, dog.env.StateDB.GetCodeHash(to), dog.tx.Data(), gasUsed)
+	if regression == nil {
+		return
+	}
+	finding := newHackerFinding("gas_regression", "HackerGasRegression")
+	finding.Detail["contract"] = to.Hex()
+	finding.Detail["selector"] = regression.Selector
+	finding.Detail["oldCodeHash"] = regression.OldCodeHash.Hex()
+	finding.Detail["newCodeHash"] = regression.NewCodeHash.Hex()
+	finding.Detail["oldMean"] = fmt.Sprintf("%.0f", regression.OldMean)
+	finding.Detail["newMean"] = fmt.Sprintf("%.0f", regression.NewMean)
+	finding.Detail["t"] = fmt.Sprintf("%.2f", regression.T)
+	dog.EmitFinding(finding)
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGasRegression(t *testing.T) {
+	var (
+		tracker = newHackerGasTracker()
+		addr    = common.HexToAddress("0x01")
+		oldCode = common.HexToHash("0x0a")
+		newCode = common.HexToHash("0x0b")
+		input   = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	)
+	for i := uint64(0); i < hackerGasMinSamples; i++ {
+		if tracker.record(addr, oldCode, input, 21000+i) != nil {
+			t.Fatal("regression reported without a redeployment")
+		}
+	}
+	var regression *HackerGasRegression
+	for i := uint64(0); i < hackerGasMinSamples; i++ {
+		if r := tracker.record(addr, newCode, input, 30000+i); r != nil {
+			regression = r
+		}
+	}
+	if regression == nil || regression.OldCodeHash != oldCode || regression.NewMean <= regression.OldMean {
+		t.Fatalf("regression not reported: %+v", regression)
+	}
+	if tracker.record(addr, newCode, input, 30000) != nil {
+		t.Fatal("regression reported twice")
+	}
+	// same gas on another selector is not a regression
+	other := []byte{0x70, 0xa0, 0x82, 0x31}
+	for i := uint64(0); i < hackerGasMinSamples; i++ {
+		tracker.record(addr, oldCode, other, 5000+i)
+	}
+	for i := uint64(0); i < hackerGasMinSamples; i++ {
+		if tracker.record(addr, newCode, other, 5000+i) != nil {
+			t.Fatal("unchanged selector reported")
+		}
+	}
+}
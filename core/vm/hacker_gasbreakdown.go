@@ -0,0 +1,112 @@
+/**
+* @hacker_gasbreakdown.go
+* Breakdown of the gas of the watched transaction ("gas" in the report): the receipt
+* only tells the total, the gas oracles need to know where it went.
+* 1 the intrinsic gas is charged for the transaction, its calldata (EIP-2028 in the
+*   forks opted in to from Istanbul on, hacker_fork.go) and its access list (EIP-2930),
+*   before the message call runs.
+* 2 the execution gas is the gas used by the message call, the frame of the transaction
+*   in the journal (hacker_journal.go): none when the target has no code.
+* 3 the refund applied is the refund counter of the state at the end of the
+*   transaction, capped at a fifth of the gas used (EIP-3529, half before London).
+*   Used = Intrinsic + Execution - Refund is the gas used of the receipt of a node.
+* 4 the frames called by the transaction itself are listed with the gas each used,
+*   their children included, Own is the gas the code of the target used itself.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// HackerGasBreakdown is the breakdown of the gas of a watched transaction.
+type HackerGasBreakdown struct {
+	Limit     uint64 `json:"limit"`
+	Intrinsic uint64 `json:"intrinsic"`
+	Execution uint64 `json:"execution"`
+	// Refund is the refund applied, RefundCounter the refunds accumulated before the
+	// cap
+	Refund        uint64 `json:"refund"`
+	RefundCounter uint64 `json:"refundCounter"`
+	Used          uint64 `json:"used"`
+	// Frames are the frames called by the transaction, Own the gas used by the frame
+	// of the transaction outside of them
+	Frames []HackerFrameGas `json:"frames"`
+	Own    uint64           `json:"own"`
+}
+
+// HackerFrameGas is the gas of a frame called by the transaction.
+type HackerFrameGas struct {
+	// Index is the index of the frame in the "frames" of the report
+	Index   int    `json:"index"`
+	Kind    string `json:"kind"`
+	Address string `json:"address"`
+	Gas     uint64 `json:"gas"`
+	GasUsed uint64 `json:"gasUsed"`
+}
+
+// hackerIntrinsicGas returns the intrinsic gas of a message call with data and list.
+func hackerIntrinsicGas(data []byte, list HackerAccessList, eip2028 bool) uint64 {
+	gas, nonZero := params.TxGas, params.TxDataNonZeroGas
+	if eip2028 {
+		nonZero = TxDataNonZeroGasEIP2028
+	}
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += nonZero
+		}
+	}
+	for _, tuple := range list {
+		gas += TxAccessListAddressGas + uint64(len(tuple.StorageKeys))*TxAccessListStorageKeyGas
+	}
+	return gas
+}
+
+// gasBreakdown returns the breakdown of the gas of the watched transaction.
+func (dog *WatchDog) gasBreakdown() *HackerGasBreakdown {
+	rules := dog.env.forkRules
+	var list HackerAccessList
+	if dog.typed != nil {
+		list = dog.typed.AccessList
+	}
+	gas := &HackerGasBreakdown{Intrinsic: hackerIntrinsicGas(dog.tx.Data(), list, rules.IsIstanbul), Frames: make([]HackerFrameGas, 0)}
+	if limit := dog.tx.Gas(); limit != nil {
+		gas.Limit = limit.Uint64()
+	}
+	if dog.taint != nil {
+		journal := dog.taint.journal
+		root := -1
+		for i := range journal.frames {
+			frame := &journal.frames[i]
+			switch {
+			case frame.parent < 0 && root < 0:
+				root = i
+				gas.Execution = frame.gasUsed
+			case frame.parent == root && root >= 0:
+				gas.Frames = append(gas.Frames, HackerFrameGas{Index: i, Kind: frame.kind.String(), Address: frame.Address().Hex(), Gas: frame.gas, GasUsed: frame.gasUsed})
+			}
+		}
+		var called uint64
+		for _, frame := range gas.Frames {
+			called += frame.GasUsed
+		}
+		if called <= gas.Execution {
+			gas.Own = gas.Execution - called
+		}
+	}
+	if refund := dog.env.StateDB.GetRefund(); refund != nil && refund.IsUint64() {
+		gas.RefundCounter = refund.Uint64()
+	}
+	quotient := RefundQuotient
+	if rules.IsLondon {
+		quotient = RefundQuotientEIP3529
+	}
+	gas.Refund = gas.RefundCounter
+	if max := (gas.Intrinsic + gas.Execution) / quotient; gas.Refund > max {
+		gas.Refund = max
+	}
+	gas.Used = gas.Intrinsic + gas.Execution - gas.Refund
+	return gas
+}
@@ -0,0 +1,172 @@
+/**
+* @hacker_griefing.go
+* Gas griefing probe of the external calls of watched transactions.
+* 1 when enabled (SetGriefing), Watch() replays the transaction on a fork of the
+*   pre-state with a tracer listing the accounts the target calls, precompiles aside.
+* 2 the transaction is replayed again once per callee, the code of the callee replaced
+*   by a stub burning all the gas it is given (the designated invalid opcode).
+* 3 each replay is compared with the original: the transaction "reverted" when the
+*   callee can make it fail, the failure was "ignored" when the post state is the one
+*   of the original (unchecked call), and "handled" otherwise. The replays are the
+*   "griefing" section of the report, the reverted and ignored ones "gas_griefing"
+*   findings.
+ */
+package vm
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	GriefingReverted = "reverted"
+	GriefingIgnored  = "ignored"
+	GriefingHandled  = "handled"
+)
+
+// hackerGriefingMaxCallees bounds the replays of a transaction.
+const hackerGriefingMaxCallees = 16
+
+// hackerGasBurner is the stub installed at the callees: INVALID consumes all the gas
+// forwarded to the call.
+var hackerGasBurner = []byte{0xfe}
+
+// hackerCalleeStub is a code installed at the callees, name tells the stubs of a probe
+// apart.
+type hackerCalleeStub struct {
+	name string
+	code []byte
+}
+
+// HackerGriefingCall is the replay of a transaction with one callee replaced by a stub.
+type HackerGriefingCall struct {
+	Callee  common.Address `json:"callee"`
+	Op      string         `json:"op"`
+	Stub    string         `json:"stub,omitempty"`
+	Outcome string         `json:"outcome"`
+	Reasons []string       `json:"reasons"`
+	GasUsed uint64         `json:"gasUsed"`
+}
+
+// HackerGriefingReport is the "griefing" section of the WatchDog report.
+type HackerGriefingReport struct {
+	Target         common.Address       `json:"target"`
+	OriginalFailed bool                 `json:"originalFailed"`
+	Calls          []HackerGriefingCall `json:"calls"`
+}
+
+// SetGriefing turns the gas griefing probe of the watched transactions on or off.
+func (c *HackerCampaign) SetGriefing(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.griefing = on
+}
+
+func (c *HackerCampaign) griefingProbe() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.griefing
+}
+
+// hackerCalleeTracer lists the accounts called by target, in the order of their
+// first call.
+type hackerCalleeTracer struct {
+	target  common.Address
+	callees []common.Address
+	ops     map[common.Address]OpCode
+}
+
+func (tracer *hackerCalleeTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if err != nil || contract.Address() != tracer.target {
+		return nil
+	}
+	switch op {
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL:
+	default:
+		return nil
+	}
+	callee := common.BigToAddress(stack.Back(1))
+	if _, seen := tracer.ops[callee]; seen || callee == tracer.target || env.precompile(callee) != nil {
+		return nil
+	}
+	tracer.ops[callee] = op
+	tracer.callees = append(tracer.callees, callee)
+	return nil
+}
+
+func (tracer *hackerCalleeTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration) error {
+	return nil
+}
+
+// Griefing executes msg as is, then once per account its target calls with the code
+// of that account replaced by a gas burning stub, and compares the outcomes.
+func (harness *HackerHarness) Griefing(msg *HackerMessage) *HackerGriefingReport {
+	return harness.replaceCallees(msg, hackerCalleeStub{code: hackerGasBurner})
+}
+
+// replaceCallees executes msg as is, then once per account its target calls and stub
+// with the code of that account replaced by the stub.
+func (harness *HackerHarness) replaceCallees(msg *HackerMessage, stubs ...hackerCalleeStub) *HackerGriefingReport {
+	if msg.To == nil {
+		return nil
+	}
+	report := &HackerGriefingReport{Target: *msg.To, Calls: make([]HackerGriefingCall, 0)}
+	tracer := &hackerCalleeTracer{target: *msg.To, ops: make(map[common.Address]OpCode)}
+	config := harness.vmConfig
+	config.Debug, config.Tracer = true, tracer
+	traced := harness.derive(harness.ctx, harness.statedb, config)
+	original := traced.Execute(msg)
+	if report.OriginalFailed = original.Failed(); report.OriginalFailed {
+		return report
+	}
+	for i, callee := range tracer.callees {
+		if i == hackerGriefingMaxCallees {
+			break
+		}
+		for _, stub := range stubs {
+			replayed := harness.ExecuteWithOverride(msg, HackerStateOverride{callee: &HackerAccountOverride{Code: stub.code}})
+			call := HackerGriefingCall{Callee: callee, Op: tracer.ops[callee].String(), Stub: stub.name, Reasons: original.Diff(replayed), GasUsed: replayed.GasUsed}
+			switch {
+			case replayed.Failed():
+				call.Outcome = GriefingReverted
+			case len(call.Reasons) == 0:
+				call.Outcome = GriefingIgnored
+			default:
+				call.Outcome = GriefingHandled
+			}
+			report.Calls = append(report.Calls, call)
+		}
+	}
+	return report
+}
+
+func (dog *WatchDog) probeGriefing(env *EVM, tx *types.Transaction) {
+	dog.griefing = nil
+	if !GetGlobalCampaign().griefingProbe() || env == nil {
+		return
+	}
+	dog.griefing = newHackerHarnessFrom(env).Griefing(hackerTxMessage(env, tx))
+}
+
+// checkGriefing raises a finding per callee able to make the watched transaction fail
+// or whose failure it ignored.
+func (dog *WatchDog) checkGriefing() {
+	if dog.griefing == nil {
+		return
+	}
+	for _, call := range dog.griefing.Calls {
+		if call.Outcome == GriefingHandled {
+			continue
+		}
+		finding := newHackerFinding("gas_griefing", "HackerGriefing")
+		finding.Detail["target"] = dog.griefing.Target.Hex()
+		finding.Detail["callee"] = call.Callee.Hex()
+		finding.Detail["op"] = call.Op
+		finding.Detail["outcome"] = call.Outcome
+		finding.Detail["gasUsed"] = strconv.FormatUint(call.GasUsed, 10)
+		dog.EmitFinding(finding)
+	}
+}
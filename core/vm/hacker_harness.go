@@ -0,0 +1,304 @@
+/**
+* @hacker_harness.go
+* Fuzz harness: execute candidate messages against a state without committing them.
+* 1 every execution runs inside a StateDB snapshot which is reverted afterwards,
+*   so the canonical state seen by the node is never mutated. The probes replaying a
+*   watched transaction run on a copy of the state of the block (hackerProbeEnv): a
+*   revert in the middle of a block would still leave its journal touched.
+* 2 the outcome keeps what the comparison modes (front running, ...) need:
+*   return data, error, gas and the post state of the touched accounts.
+* 3 the chain id reported by CHAINID can be overridden, a campaign replays the
+*   messages signed for one chain as if they were sent on another one.
+* 4 the messages are executed by one EVM reset between them (EVM.Reset), a message
+*   applied while another one is running gets its own EVM.
+* 5 an execution is cancelled past a wall-clock timeout or a number of operations,
+*   its outcome is marked Timeout: mutated calldata looping forever, with a large gas
+*   limit or without gas metering, cannot hang a fuzz worker.
+* 6 an instrumented harness watches the messages it executes and reports them to the
+*   sink (hacker_callreport.go), a bundle of messages in one report
+*   (hacker_bundle.go).
+* 7 the contract fixtures loaded into the campaign can be installed on every fork
+*   (hacker_fixture.go), the balances credited by the faucet too (hacker_faucet.go).
+* 8 a message can be run as the validation of an account abstraction operation,
+*   checked against the rules of the validation phase (hacker_validation.go).
+ */
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// HackerMessage is a call executed by the harness.
+type HackerMessage struct {
+	From  common.Address
+	To    *common.Address
+	Value *big.Int
+	Gas   uint64
+	Data  []byte
+	// GasFeeCap and GasTipCap are the fees of a dynamic fee message (EIP-1559), a
+	// legacy message leaves them nil and pays the gas price of the context.
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+	// AccessList is warm from the start of the message (EIP-2930)
+	AccessList HackerAccessList
+	// Seed pins the block environment of the execution when set (hacker_prng.go)
+	Seed *common.Hash
+}
+
+// hackerTxMessage returns the message of tx as executed by env.
+func hackerTxMessage(env *EVM, tx *types.Transaction) *HackerMessage {
+	return &HackerMessage{From: env.Origin, To: tx.To(), Value: tx.Value(), Gas: tx.Gas().Uint64(), Data: tx.Data()}
+}
+
+// HackerOutcome is the result of one harness execution.
+type HackerOutcome struct {
+	Ret     []byte
+	GasUsed uint64
+	Err     error
+	// Timeout is set when the execution was cancelled by the limits of the harness
+	Timeout bool
+	// post state of the callee and the sender
+	Storage  map[common.Hash]common.Hash
+	Balances map[common.Address]*big.Int
+	// Loan is the settlement of the flash loan of the execution, if any
+	// (hacker_flashloan.go)
+	Loan *HackerLoanResult
+	// Report is the report of the execution of an instrumented harness
+	Report *HackerCallReport
+}
+
+// Failed reports whether the execution ended with an error.
+func (outcome *HackerOutcome) Failed() bool {
+	return outcome.Err != nil
+}
+
+// Diff returns the reasons why other differs from outcome, empty if equivalent.
+func (outcome *HackerOutcome) Diff(other *HackerOutcome) []string {
+	reasons := make([]string, 0)
+	if outcome.Failed() != other.Failed() {
+		reasons = append(reasons, "status")
+	}
+	if !bytes.Equal(outcome.Ret, other.Ret) {
+		reasons = append(reasons, "return")
+	}
+	for addr, balance := range outcome.Balances {
+		if other.Balances[addr] == nil || balance.Cmp(other.Balances[addr]) != 0 {
+			reasons = append(reasons, "balance:"+addr.Hex())
+		}
+	}
+	for slot, value := range outcome.Storage {
+		if other.Storage[slot] != value {
+			reasons = append(reasons, "storage:"+slot.Hex())
+		}
+	}
+	for slot, value := range other.Storage {
+		if _, ok := outcome.Storage[slot]; !ok && value != (common.Hash{}) {
+			reasons = append(reasons, "storage:"+slot.Hex())
+		}
+	}
+	return reasons
+}
+
+// HackerHarness runs messages on top of a state and block context.
+type HackerHarness struct {
+	ctx         Context
+	statedb     StateDB
+	chainConfig *params.ChainConfig
+	vmConfig    Config
+	// chainID overrides the chain id of chainConfig when set
+	chainID *big.Int
+	// evm is reused across the messages, busy while one is running
+	evm  *EVM
+	busy bool
+	// timeout and maxSteps bound each execution when set
+	timeout  time.Duration
+	maxSteps uint64
+	// instrumented is set when the messages are watched, bundle while a bundle of
+	// them runs (hacker_bundle.go)
+	instrumented bool
+	bundle       *hackerBundleWatch
+	// fixtures are installed on every fork (hacker_fixture.go)
+	fixtures HackerStateOverride
+	// credits are credited on every fork, faucet tops the senders up
+	// (hacker_faucet.go)
+	credits map[common.Address]*big.Int
+	faucet  *HackerFaucet
+	// validation checks the message running in a validation phase
+	// (hacker_validation.go)
+	validation *hackerValidationGuard
+}
+
+func NewHackerHarness(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *HackerHarness {
+	return &HackerHarness{ctx: ctx, statedb: statedb, chainConfig: chainConfig, vmConfig: vmConfig}
+}
+
+// newHackerHarnessFrom returns a harness sharing the state and context of evm.
+func newHackerHarnessFrom(evm *EVM) *HackerHarness {
+	harness := NewHackerHarness(evm.Context, evm.StateDB, evm.chainConfig, evm.vmConfig)
+	harness.SetChainID(evm.chainID)
+	harness.SetLimits(0, evm.stepLimit)
+	return harness
+}
+
+// hackerProbeEnv returns an EVM sharing the context of env on a copy of its state, for
+// the probes replaying a watched transaction before it runs; nil when the state cannot
+// be copied, the probes are skipped then rather than run on the state of the block.
+func hackerProbeEnv(env *EVM) *EVM {
+	statedb, ok := hackerCopyState(env.StateDB)
+	if !ok {
+		return nil
+	}
+	probe := NewEVM(env.Context, statedb, env.chainConfig, env.vmConfig)
+	probe.chainID = env.chainID
+	probe.SetStepLimit(env.stepLimit)
+	return probe
+}
+
+// HackerStateCopier is a StateDB which can be copied, the probes of the WatchDog run
+// on a copy of the state of the block.
+type HackerStateCopier interface {
+	Copy() StateDB
+}
+
+// hackerCopyState returns a copy of statedb, false when it cannot be copied.
+func hackerCopyState(statedb StateDB) (StateDB, bool) {
+	copier, ok := statedb.(HackerStateCopier)
+	if !ok {
+		return nil, false
+	}
+	copied := copier.Copy()
+	return copied, copied != nil
+}
+
+// probing reports whether a probe replays the watched transactions to target.
+func (dog *WatchDog) probing(target common.Address) bool {
+	campaign := GetGlobalCampaign()
+	return dog.frontRunner() != nil || campaign.differential(target) != nil || campaign.griefingProbe() || campaign.revertBombProbe() ||
+		campaign.returnPoisoningProbe() || len(campaign.TimeSkews()) > 0 || len(campaign.FeeVariants()) > 0
+}
+
+// derive returns a harness with the settings of this one running on ctx, statedb and
+// vmConfig.
+func (harness *HackerHarness) derive(ctx Context, statedb StateDB, vmConfig Config) *HackerHarness {
+	derived := NewHackerHarness(ctx, statedb, harness.chainConfig, vmConfig)
+	derived.SetChainID(harness.chainID)
+	derived.SetLimits(harness.timeout, harness.maxSteps)
+	derived.SetFixtures(harness.fixtures)
+	derived.SetFunding(harness.credits, harness.faucet)
+	return derived
+}
+
+// SetChainID makes CHAINID report id in the messages executed by the harness, nil
+// reports the chain id of the chain config again.
+func (harness *HackerHarness) SetChainID(id *big.Int) {
+	harness.chainID = id
+}
+
+// SetLimits cancels the executions of the harness running longer than timeout or
+// more than maxSteps operations, zero leaves them unbounded.
+func (harness *HackerHarness) SetLimits(timeout time.Duration, maxSteps uint64) {
+	harness.timeout = timeout
+	harness.maxSteps = maxSteps
+}
+
+// Fork runs fn on a snapshot of the state, with the fixtures of the harness installed
+// and its credits funded, and reverts everything fn did.
+func (harness *HackerHarness) Fork(fn func()) {
+	snapshot := harness.statedb.Snapshot()
+	defer harness.statedb.RevertToSnapshot(snapshot)
+	harness.fixtures.Apply(harness.statedb)
+	harness.fund()
+	fn()
+}
+
+// Apply executes msg on the current state and keeps its effects; use it inside Fork.
+func (harness *HackerHarness) Apply(msg *HackerMessage) *HackerOutcome {
+	outcome := &HackerOutcome{Storage: make(map[common.Hash]common.Hash), Balances: make(map[common.Address]*big.Int)}
+	ctx := harness.ctx
+	ctx.Origin = msg.From
+	if ctx.GasPrice, outcome.Err = msg.EffectiveGasPrice(ctx.BaseFee, ctx.GasPrice); outcome.Err != nil {
+		return outcome
+	}
+	evm := harness.acquire(ctx)
+	defer harness.release(evm)
+	evm.chainID = harness.chainID
+	evm.validation = harness.validation
+	evm.seed = msg.Seed
+	evm.SetStepLimit(harness.maxSteps)
+	evm.WarmAccessList(msg.AccessList)
+	if harness.timeout > 0 {
+		timer := time.AfterFunc(harness.timeout, evm.Cancel)
+		defer timer.Stop()
+	}
+
+	harness.topUp(msg.From)
+	value := msg.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	var (
+		to      common.Address
+		gasLeft uint64
+		watch   *WatchDog
+	)
+	if harness.instrumented && msg.To != nil {
+		watch = harness.watch(evm, msg, value)
+	}
+	if msg.To == nil {
+		outcome.Ret, to, gasLeft, outcome.Err = evm.Create(AccountRef(msg.From), msg.Data, msg.Gas, value)
+	} else {
+		to = *msg.To
+		outcome.Ret, gasLeft, outcome.Err = evm.Call(AccountRef(msg.From), to, msg.Data, msg.Gas, value)
+	}
+	outcome.GasUsed = msg.Gas - gasLeft
+	if evm.Cancelled() {
+		outcome.Err, outcome.Timeout = ErrExecutionTimeout, true
+	}
+	if watch != nil {
+		outcome.Report = harness.unwatch(watch, outcome)
+	}
+
+	harness.statedb.ForEachStorage(to, func(key, value common.Hash) bool {
+		outcome.Storage[key] = value
+		return true
+	})
+	for _, addr := range []common.Address{msg.From, to} {
+		outcome.Balances[addr] = new(big.Int).Set(harness.statedb.GetBalance(addr))
+	}
+	return outcome
+}
+
+// acquire returns the EVM of the harness reset for ctx, or a new one when it is busy.
+func (harness *HackerHarness) acquire(ctx Context) *EVM {
+	if harness.busy {
+		return NewEVM(ctx, harness.statedb, harness.chainConfig, harness.vmConfig)
+	}
+	if harness.evm == nil {
+		harness.evm = NewEVM(ctx, harness.statedb, harness.chainConfig, harness.vmConfig)
+	} else {
+		harness.evm.Reset(ctx, harness.statedb)
+	}
+	harness.busy = true
+	return harness.evm
+}
+
+// release marks the EVM of the harness free again once evm is done.
+func (harness *HackerHarness) release(evm *EVM) {
+	if evm == harness.evm {
+		harness.busy = false
+	}
+}
+
+// Execute runs msg on a fork of the state and returns its outcome.
+func (harness *HackerHarness) Execute(msg *HackerMessage) (outcome *HackerOutcome) {
+	harness.Fork(func() {
+		outcome = harness.Apply(msg)
+	})
+	return outcome
+}
@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// copyStateDB is a frameStateDB with storage which can be copied.
+type copyStateDB struct {
+	frameStateDB
+	storage   map[common.Hash]common.Hash
+	snapshots int
+}
+
+func (db *copyStateDB) GetState(_ common.Address, key common.Hash) common.Hash {
+	return db.storage[key]
+}
+func (db *copyStateDB) SetState(_ common.Address, key, value common.Hash) { db.storage[key] = value }
+func (db *copyStateDB) Snapshot() int                                     { db.snapshots++; return 0 }
+func (db *copyStateDB) Exist(common.Address) bool                         { return true }
+
+func (db *copyStateDB) Copy() StateDB {
+	copied := &copyStateDB{frameStateDB: frameStateDB{code: db.code}, storage: make(map[common.Hash]common.Hash)}
+	for key, value := range db.storage {
+		copied.storage[key] = value
+	}
+	return copied
+}
+
+func TestProbeEnvCopiesState(t *testing.T) {
+	SetHooks(false)
+	defer SetHooks(true)
+	// PUSH1 1 PUSH1 0 SSTORE STOP
+	live := &copyStateDB{frameStateDB: frameStateDB{code: common.FromHex("0x600160005500")}, storage: make(map[common.Hash]common.Hash)}
+	context := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+	}
+	env := NewEVM(context, live, params.TestChainConfig, Config{})
+	probe := hackerProbeEnv(env)
+	if probe == nil || probe.StateDB == StateDB(live) {
+		t.Fatal("the probe runs on the state of the block")
+	}
+	target := common.HexToAddress("0x0a")
+	outcome := newHackerHarnessFrom(probe).Apply(&HackerMessage{From: common.HexToAddress("0x01"), To: &target, Gas: 100000})
+	if outcome.Err != nil {
+		t.Fatal(outcome.Err)
+	}
+	if copied := probe.StateDB.(*copyStateDB); copied.storage[common.Hash{}] != common.BigToHash(big.NewInt(1)) || copied.snapshots == 0 {
+		t.Errorf("the probe did not run on the copy: %v, %d snapshots", copied.storage, copied.snapshots)
+	}
+	if len(live.storage) != 0 || live.snapshots != 0 {
+		t.Errorf("the probe touched the state of the block: %v, %d snapshots", live.storage, live.snapshots)
+	}
+	// a state without Copy skips the probes
+	if hackerProbeEnv(NewEVM(context, new(frameStateDB), params.TestChainConfig, Config{})) != nil {
+		t.Error("a probe runs on a state which cannot be copied")
+	}
+}
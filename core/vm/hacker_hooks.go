@@ -0,0 +1,38 @@
+/**
+* @hacker_hooks.go
+* Switches of the hooks of the instrumentation in the interpreter (evm.go,
+* interpreter.go, instructions.go, operations_acl.go), so that the fork doubles as a
+* normal node.
+* 1 built with the nofuzz tag (hacker_hooks_nofuzz.go) the hooks are compiled out: the
+*   interpreter runs as the vanilla EVM, Watch arms no WatchDog, nothing is recorded or
+*   reported.
+* 2 built without it, SetHooks(false) turns them off at run time (--fuzz.off, or the
+*   GETH_NOFUZZ environment variable, fuzz.Config). The switch is read once per frame:
+*   turn it between transactions, a transaction running keeps its hooks.
+* 3 with the hooks off the frames do the same state changes, use the same gas and
+*   return the same results as with them on, without the seeded block values of the
+*   fuzz API forks (hacker_prng.go).
+ */
+package vm
+
+import (
+	"sync/atomic"
+)
+
+// hackerHooksOff is set when the hooks are turned off at run time.
+var hackerHooksOff int32
+
+// SetHooks turns the hooks of the instrumentation on or off, it has no effect when they
+// are compiled out.
+func SetHooks(on bool) {
+	off := int32(1)
+	if on {
+		off = 0
+	}
+	atomic.StoreInt32(&hackerHooksOff, off)
+}
+
+// HooksEnabled tells whether the hooks of the instrumentation run.
+func HooksEnabled() bool {
+	return hackerHooksCompiled && atomic.LoadInt32(&hackerHooksOff) == 0
+}
@@ -0,0 +1,8 @@
+//go:build nofuzz
+// +build nofuzz
+
+package vm
+
+// hackerHooksCompiled is unset with the nofuzz tag: the hooks of the instrumentation
+// are compiled out (hacker_hooks.go).
+const hackerHooksCompiled = false
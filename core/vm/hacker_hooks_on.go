@@ -0,0 +1,7 @@
+//go:build !nofuzz
+// +build !nofuzz
+
+package vm
+
+// hackerHooksCompiled is set when the hooks of the instrumentation are built in.
+const hackerHooksCompiled = true
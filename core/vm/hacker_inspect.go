@@ -0,0 +1,56 @@
+/**
+* @hacker_inspect.go
+* Live inspection of a WatchDog (debug_fuzzWatchdog), to diagnose instrumentation
+* stuck on a transaction or never armed during a campaign. The state is read as it
+* is, without stopping the transaction being watched: the counts are a snapshot.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerWatchDogState is the state of a WatchDog.
+type HackerWatchDogState struct {
+	// Tx is the transaction watched or last watched, To its destination
+	Tx        *common.Hash    `json:"tx"`
+	To        *common.Address `json:"to"`
+	TurnOn    bool            `json:"turnOn"`
+	HasThrow  bool            `json:"hasThrow"`
+	Started   bool            `json:"started"`
+	CallsOnly bool            `json:"callsOnly"`
+	// TraceLength is the number of operations in the trace ring, TraceDropped those
+	// overwritten and TraceSkipped those left out by the sampling
+	TraceLength  int    `json:"traceLength"`
+	TraceDropped uint64 `json:"traceDropped"`
+	TraceSkipped uint64 `json:"traceSkipped"`
+	// StorageWrites is the number of writes not merged into StorageNew yet
+	StorageWrites int `json:"storageWrites"`
+	StorageOld    int `json:"storageOld"`
+	StorageNew    int `json:"storageNew"`
+	Logs          int `json:"logs"`
+	Findings      int `json:"findings"`
+	Forks         int `json:"forks"`
+}
+
+// State returns the state of the WatchDog.
+func (dog *WatchDog) State() *HackerWatchDogState {
+	watchesLock.RLock()
+	forks := len(dog.forks)
+	watchesLock.RUnlock()
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	state := &HackerWatchDogState{TurnOn: dog.watching(), HasThrow: dog.hasThrow, Started: dog.started, CallsOnly: dog.callsOnly, Findings: len(dog.findings), Forks: forks}
+	if tx := dog.tx; tx != nil {
+		hash := tx.Hash()
+		state.Tx, state.To = &hash, tx.To()
+	}
+	if dog.trace != nil {
+		state.TraceLength, state.TraceDropped, state.TraceSkipped = dog.trace.len(), dog.trace.dropped, dog.trace.skipped
+	}
+	if dog.writes != nil {
+		state.StorageWrites = dog.writes.count
+	}
+	state.StorageOld, state.StorageNew, state.Logs = len(dog.storage_old), len(dog.storage_new), len(dog.logs)
+	return state
+}
@@ -1,28 +1,56 @@
 package vm
 
-import (
-	"strconv"
-
-	"github.com/ethereum/go-ethereum/common"
-)
-
 type opFunc func(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error)
 
 func Hacker_record(op OpCode, fun opFunc, pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	var code_desc string = strconv.FormatUint(*pc, 10) + opCodeToString[op]
-	if GetGlobalWatchDog().TurnOn() == true {
-		GetGlobalWatchDog().Write2Trace(code_desc)
-		GetGlobalWatchDog().GetEnv().StateDB.ForEachStorage(*(GetGlobalWatchDog().GetTx().To()), func(key, value common.Hash) bool {
-			GetGlobalWatchDog().Write2Storage(key, value)
-			return true
-		})
+	checkBreakpoint(evm, *pc, op, contract, memory, stack)
+	dogs := hackerDogs(evm)
+	frames := [2]int{-1, -1}
+	// only the WatchDogs watching evm record its operations, the frames of a saturated
+	// target are tracked, only its calls are recorded
+	var watched, instrumented [2]bool
+	for i, dog := range dogs {
+		if watched[i] = dog.watches(evm) && dog.TurnOn(); watched[i] {
+			if dog.taint != nil {
+				frames[i] = dog.taint.journal.enter(op, contract, evm.depth)
+				dog.taint.refunds.before(op, frames[i], contract, stack, evm.StateDB)
+				dog.taint.journal.copyCalldata(op, frames[i], stack)
+			}
+			dog.countProgress(*pc)
+			if instrumented[i] = dog.instrumented(op, contract); instrumented[i] {
+				dog.record(*pc, op, frames[i], contract, memory, stack)
+			}
+		}
+	}
+	var steps [2]*hackerTaintStep
+	for i, dog := range dogs {
+		if watched[i] && instrumented[i] && dog.taint != nil {
+			steps[i] = dog.taint.before(op, *pc, contract, memory, stack)
+			// the detectors are scheduled by their cost (hacker_schedule.go)
+			dog.tickDetectors()
+			taint, step, frame := dog.taint, steps[i], frames[i]
+			dog.detect(hackerDetectorWeakRandomness, func() { taint.checkWeakRandomness(dog, step, op, *pc, contract) })
+			dog.detect(hackerDetectorCalldataLength, func() { taint.checkCalldataLength(dog, step, op, *pc, contract) })
+			dog.detect(hackerDetectorCEI, func() { taint.checkCEI(dog, step, op, *pc, frame, contract) })
+			dog.detect(hackerDetectorERC20, func() { taint.recordERC20(frame, op, contract, memory, stack, evm.StateDB) })
+		}
+	}
+	checkWatchpoints(op, evm, contract, stack)
+	checkValidation(op, *pc, evm, contract, stack)
+	res, err := fun(pc, evm, contract, memory, stack)
+	for i, dog := range dogs {
+		if watched[i] && frames[i] >= 0 && dog.TurnOn() == true {
+			dog.taint.refunds.after(op, evm.StateDB)
+		}
 	}
-	if GetGlobalTracerWatchDog().TurnOn() == true {
-		GetGlobalTracerWatchDog().Write2Trace(code_desc)
-		GetGlobalTracerWatchDog().GetEnv().StateDB.ForEachStorage(*(GetGlobalTracerWatchDog().GetTx().To()), func(key, value common.Hash) bool {
-			GetGlobalTracerWatchDog().Write2Storage(key, value)
-			return true
-		})
+	for i, dog := range dogs {
+		if watched[i] && err == nil && steps[i] != nil && dog.TurnOn() == true {
+			dog.taint.after(steps[i], op, *pc, contract, memory, stack)
+			taint, step := dog.taint, steps[i]
+			dog.detect(hackerDetectorUnboundedLoop, func() { taint.checkUnboundedLoop(dog, step, op, *pc, contract, stack) })
+			dog.detect(hackerDetectorSignature, func() { taint.recordSignatureInputs(step, op, contract, memory, stack) })
+			dog.taint.journal.leave(op, evm.depth, stack)
+		}
 	}
-	return fun(pc, evm, contract, memory, stack)
+	return res, err
 }
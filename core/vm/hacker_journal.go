@@ -0,0 +1,310 @@
+/**
+* @hacker_journal.go
+* Frames of the watched transaction as seen by the interpreter.
+* The HackerContractCall tree is only recorded below CALLCODE/DELEGATECALL, the oracles
+* that need every frame (token events, storage writes) use this journal instead:
+* 1 a frame is opened when an operation runs in a contract other than the current one,
+*   the interpreter depth tells whether it is a child, a sibling or a parent resumed.
+* 2 its kind is the call operation the parent was executing (CALL, CALLCODE,
+*   DELEGATECALL, STATICCALL, CREATE, CREATE2), the first frame is the transaction itself.
+* 3 a frame failed when its call operation pushed 0, the first frame when the message
+*   call of the transaction returned an error. A frame is live when neither it nor one of
+*   its parents failed: its effects were kept.
+* 4 the frames are sent in the report ("frames") with their depth, kind, static
+*   context flag and access counts, in the order they were entered.
+* 5 when the interpreter leaves a frame, the frame keeps the data it returned (the
+*   first hackerReturnDataCap bytes), the kind of error it ended with and the reason of
+*   a revert: the report tells which internal call failed and why.
+* 6 it keeps the gas it was given and used too, its children included: all of it when
+*   it failed with an error other than a revert (hacker_gasbreakdown.go).
+* 7 a frame entered with the calldata its parent copied whole is forwarded by the
+*   parent (hacker_forward.go).
+ */
+package vm
+
+import (
+	"encoding/hex"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type hackerFrame struct {
+	parent   int
+	depth    int
+	kind     OpCode
+	contract *Contract
+	failed   bool
+	readOnly bool
+	// accounts and slots accessed by the frame, cold ones first accessed (EIP-2929)
+	cold, warm int
+	// call operation in progress, and the index its frame got
+	pending      OpCode
+	pendingChild int
+	// returned data (capped) and its size, error kind and revert reason, set when the
+	// frame ends
+	ret     []byte
+	retSize int
+	errKind HackerFrameError
+	reason  string
+	// gas given to the frame and used by it, set when the frame ends
+	gas, gasUsed uint64
+	// copied is set once the frame copied its whole calldata, forwarder is the frame
+	// whose calldata it was entered with, -1 for none (hacker_forward.go)
+	copied    bool
+	forwarder int
+}
+
+// Caller returns msg.sender of the frame.
+func (frame *hackerFrame) Caller() common.Address {
+	return frame.contract.Caller()
+}
+
+// Address returns the account whose storage the frame uses.
+func (frame *hackerFrame) Address() common.Address {
+	return frame.contract.Address()
+}
+
+// Input returns the calldata of the frame.
+func (frame *hackerFrame) Input() []byte {
+	return frame.contract.Input
+}
+
+type hackerFrameJournal struct {
+	frames []hackerFrame
+	// indexes of the frames from the first one to the current one
+	open []int
+	// call operations failed at the call depth limit (hacker_depth.go)
+	depthFailures []hackerDepthFailure
+}
+
+func newHackerFrameJournal() *hackerFrameJournal {
+	return &hackerFrameJournal{frames: make([]hackerFrame, 0), open: make([]int, 0)}
+}
+
+func isCallOp(op OpCode) bool {
+	return op == CALL || op == CALLCODE || op == DELEGATECALL || op == STATICCALL || op == CREATE || op == CREATE2
+}
+
+// top returns the index of the current frame, -1 before the first operation.
+func (journal *hackerFrameJournal) top() int {
+	if len(journal.open) == 0 {
+		return -1
+	}
+	return journal.open[len(journal.open)-1]
+}
+
+// popAbove closes the frames deeper than depth.
+func (journal *hackerFrameJournal) popAbove(depth int) {
+	for len(journal.open) > 0 && journal.frames[journal.top()].depth > depth {
+		journal.open = journal.open[:len(journal.open)-1]
+	}
+}
+
+// enter runs before op executes in contract at the given interpreter depth and
+// returns the index of the frame op runs in.
+func (journal *hackerFrameJournal) enter(op OpCode, contract *Contract, depth int) int {
+	journal.popAbove(depth)
+	if top := journal.top(); top < 0 || journal.frames[top].contract != contract {
+		// a frame of the same depth is a sibling, its predecessor has returned
+		journal.popAbove(depth - 1)
+		frame := hackerFrame{parent: journal.top(), depth: depth, contract: contract, kind: CALL, forwarder: -1}
+		if journal.forwarded(frame.parent, contract) {
+			frame.forwarder = frame.parent
+		}
+		if frame.parent >= 0 {
+			frame.kind = journal.frames[frame.parent].pending
+			frame.readOnly = frame.kind == STATICCALL || journal.frames[frame.parent].readOnly
+		} else if contract.CodeAddr == nil {
+			frame.kind = CREATE
+		}
+		journal.frames = append(journal.frames, frame)
+		journal.open = append(journal.open, len(journal.frames)-1)
+	}
+	top := journal.top()
+	if isCallOp(op) {
+		journal.frames[top].pending = op
+		journal.frames[top].pendingChild = len(journal.frames)
+	}
+	return top
+}
+
+// leave runs after the call operation op of the frame at depth returned successfully,
+// the child frame failed when op pushed 0.
+func (journal *hackerFrameJournal) leave(op OpCode, depth int, stack *Stack) {
+	if !isCallOp(op) {
+		return
+	}
+	journal.popAbove(depth)
+	top := journal.top()
+	if top < 0 {
+		return
+	}
+	child := journal.frames[top].pendingChild
+	if stack.len() > 0 && stack.peek().Sign() == 0 && child < len(journal.frames) && journal.frames[child].parent == top {
+		journal.frames[child].failed = true
+	}
+	// the callee of a call failed at the depth limit never ran
+	if stack.len() > 0 && stack.peek().Sign() == 0 && child == len(journal.frames) && journal.frames[top].depth > int(params.CallCreateDepth) {
+		journal.depthFailures = append(journal.depthFailures, hackerDepthFailure{top, op})
+	}
+}
+
+// hackerReturnDataCap is the number of bytes of return data kept per frame.
+const hackerReturnDataCap = 256
+
+// end runs when the interpreter leaves contract, given gas, with ret and err.
+func (journal *hackerFrameJournal) end(contract *Contract, gas uint64, ret []byte, err error) {
+	top := journal.top()
+	if top < 0 || journal.frames[top].contract != contract {
+		return
+	}
+	frame := &journal.frames[top]
+	frame.retSize = len(ret)
+	frame.errKind = classifyFrameError(err)
+	if frame.errKind == FrameRevert {
+		frame.reason = revertReason(ret)
+	}
+	if len(ret) > hackerReturnDataCap {
+		ret = ret[:hackerReturnDataCap]
+	}
+	frame.ret = common.CopyBytes(ret)
+	if err != nil {
+		frame.failed = true
+	}
+	frame.gas, frame.gasUsed = gas, gas
+	if err == nil || err == ErrExecutionReverted {
+		frame.gasUsed -= contract.Gas
+	}
+}
+
+// hacker_frame_end is called when the interpreter of evm leaves a frame given gas.
+func hacker_frame_end(evm *EVM, contract *Contract, gas uint64, ret []byte, err error) {
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() {
+			dog.countBlockedFrame(contract)
+		}
+		if dog.watches(evm) && dog.TurnOn() && dog.taint != nil {
+			dog.taint.journal.end(contract, gas, ret, err)
+			if err != nil && dog.revert != nil {
+				dog.revert.fail(evm.StateDB, dog.taint.journal, contract)
+			}
+		}
+	}
+}
+
+// fail marks the frame of the transaction as failed.
+func (journal *hackerFrameJournal) fail() {
+	for i := range journal.frames {
+		if journal.frames[i].parent < 0 {
+			journal.frames[i].failed = true
+		}
+	}
+}
+
+// live returns, per frame, whether its effects were kept.
+func (journal *hackerFrameJournal) live() []bool {
+	live := make([]bool, len(journal.frames))
+	for i, frame := range journal.frames {
+		// parents come before their children
+		live[i] = !frame.failed && (frame.parent < 0 || live[frame.parent])
+	}
+	return live
+}
+
+// descends tells whether frame i runs under frame ancestor.
+func (journal *hackerFrameJournal) descends(i, ancestor int) bool {
+	for i = journal.frames[i].parent; i >= 0; i = journal.frames[i].parent {
+		if i == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// HackerReportFrame is a frame of the journal as sent in the WatchDog report.
+type HackerReportFrame struct {
+	Index  int `json:"index"`
+	Parent int `json:"parent"`
+	// Depth is the call depth, 0 for the frame of the transaction.
+	Depth int `json:"depth"`
+	// Kind is the operation that created the frame: CALL, CALLCODE, DELEGATECALL,
+	// STATICCALL, CREATE or CREATE2.
+	Kind string `json:"kind"`
+	// ReadOnly is set for frames entered with STATICCALL and their children.
+	ReadOnly    bool   `json:"readOnly"`
+	Caller      string `json:"caller"`
+	Address     string `json:"address"`
+	CodeAddress string `json:"codeAddress,omitempty"`
+	Input       string `json:"input"`
+	// InputSize is the size of the input when the report redaction cut it
+	// (hacker_redact.go).
+	InputSize int  `json:"inputSize,omitempty"`
+	Failed    bool `json:"failed"`
+	// ColdAccesses and WarmAccesses count the accounts and slots the frame accessed,
+	// cold ones were not accessed before in the transaction (EIP-2929).
+	ColdAccesses int `json:"coldAccesses,omitempty"`
+	WarmAccesses int `json:"warmAccesses,omitempty"`
+	// ReturnData is the data returned or reverted with, cut at hackerReturnDataCap
+	// bytes, ReturnDataSize its full size.
+	ReturnData     string `json:"returnData,omitempty"`
+	ReturnDataSize int    `json:"returnDataSize,omitempty"`
+	// Error is the kind of error the frame ended with, empty when it returned.
+	Error        string `json:"error,omitempty"`
+	RevertReason string `json:"revertReason,omitempty"`
+	// Gas is the gas given to the frame, GasUsed the gas it used, its children
+	// included.
+	Gas     uint64 `json:"gas"`
+	GasUsed uint64 `json:"gasUsed"`
+	// ForwardedFrom is the frame whose calldata the frame was entered with
+	// (hacker_forward.go).
+	ForwardedFrom *int `json:"forwardedFrom,omitempty"`
+}
+
+// report returns the frames in the order they were entered.
+func (journal *hackerFrameJournal) report() []HackerReportFrame {
+	frames := make([]HackerReportFrame, 0, len(journal.frames))
+	for i := range journal.frames {
+		frame := &journal.frames[i]
+		view := HackerReportFrame{
+			Index:        i,
+			Parent:       frame.parent,
+			Depth:        frame.depth - 1,
+			Kind:         frame.kind.String(),
+			ReadOnly:     frame.readOnly,
+			Caller:       frame.Caller().Hex(),
+			Address:      frame.Address().Hex(),
+			Input:        hex.EncodeToString(frame.Input()),
+			Failed:       frame.failed,
+			ColdAccesses: frame.cold,
+			WarmAccesses: frame.warm,
+			Gas:          frame.gas,
+			GasUsed:      frame.gasUsed,
+		}
+		if frame.contract.CodeAddr != nil {
+			view.CodeAddress = frame.contract.CodeAddr.Hex()
+		}
+		if frame.forwarder >= 0 {
+			forwarder := frame.forwarder
+			view.ForwardedFrom = &forwarder
+		}
+		if frame.retSize != 0 {
+			view.ReturnData, view.ReturnDataSize = hex.EncodeToString(frame.ret), frame.retSize
+		}
+		if frame.errKind != FrameOK {
+			view.Error, view.RevertReason = frame.errKind.String(), frame.reason
+		}
+		frames = append(frames, view)
+	}
+	return frames
+}
+
+// hacker_root_failed is called when the message call of a transaction of evm returns
+// an error.
+func hacker_root_failed(evm *EVM) {
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() && dog.taint != nil {
+			dog.taint.journal.fail()
+		}
+	}
+}
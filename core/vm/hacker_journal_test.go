@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// enterTestFrame opens a frame of the transaction calling address from caller.
+func enterTestFrame(taint *HackerTaint, caller, address common.Address, input []byte) int {
+	contract := NewContract(AccountRef(caller), AccountRef(address), new(big.Int), 0)
+	contract.SetCallCode(&address, common.Hash{}, nil)
+	contract.Input = input
+	return taint.journal.enter(STOP, contract, 1)
+}
+
+func TestFrameJournal(t *testing.T) {
+	var (
+		journal = newHackerFrameJournal()
+		a, b    = common.HexToAddress("0x0a"), common.HexToAddress("0x0b")
+		root    = NewContract(AccountRef(a), AccountRef(a), new(big.Int), 0)
+		first   = NewContract(root, AccountRef(b), new(big.Int), 0)
+		second  = NewContract(root, AccountRef(b), new(big.Int), 0)
+		stack   = newstack()
+	)
+	root.CodeAddr = &a
+	journal.enter(PUSH1, root, 1)
+	journal.enter(DELEGATECALL, root, 1)
+	journal.enter(PUSH1, first, 2)
+	stack.push(new(big.Int))
+	journal.leave(DELEGATECALL, 1, stack)
+	journal.enter(CALL, root, 1)
+	journal.enter(PUSH1, second, 2)
+	stack.push(big.NewInt(1))
+	journal.leave(CALL, 1, stack)
+
+	if len(journal.frames) != 3 || journal.frames[1].parent != 0 || journal.frames[2].parent != 0 {
+		t.Fatalf("unexpected frames %+v", journal.frames)
+	}
+	if journal.frames[0].kind != CALL || journal.frames[1].kind != DELEGATECALL || journal.frames[2].kind != CALL {
+		t.Fatalf("unexpected kinds %v %v %v", journal.frames[0].kind, journal.frames[1].kind, journal.frames[2].kind)
+	}
+	if live := journal.live(); !live[0] || live[1] || !live[2] {
+		t.Fatalf("unexpected live frames %v", live)
+	}
+	report := journal.report()
+	if report[1].Depth != 1 || report[1].Kind != "DELEGATECALL" || !report[1].Failed || report[0].Depth != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	journal.fail()
+	if live := journal.live(); live[0] || live[2] {
+		t.Fatal("frames of a failed transaction kept")
+	}
+}
@@ -0,0 +1,71 @@
+/**
+* @hacker_labels.go
+* Labels of the addresses of a campaign, for readable reports.
+* 1 the fuzzer names the addresses it knows ("Victim", "Router", the attacker
+*   accounts...) one by one or in bulk, an empty label removes one.
+* 2 every WatchDog report gets a "labels" section mapping the labelled addresses
+*   appearing anywhere in the report (trace, frames, storage, findings...) to their
+*   label, the sections keep the raw addresses. Without any label there is no section.
+ */
+package vm
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SetLabel names addr in the reports, an empty label removes its label.
+func (c *HackerCampaign) SetLabel(addr common.Address, label string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if label == "" {
+		delete(c.labels, addr)
+		return
+	}
+	c.labels[addr] = label
+}
+
+// ImportLabels names every address of labels, keeping the other labels.
+func (c *HackerCampaign) ImportLabels(labels map[common.Address]string) {
+	for addr, label := range labels {
+		c.SetLabel(addr, label)
+	}
+}
+
+// Labels returns the labels of the campaign.
+func (c *HackerCampaign) Labels() map[common.Address]string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	labels := make(map[common.Address]string, len(c.labels))
+	for addr, label := range c.labels {
+		labels[addr] = label
+	}
+	return labels
+}
+
+// reportLabels returns the labels of the addresses appearing in the report, nil when
+// there is none.
+func reportLabels(report map[string]interface{}) map[string]string {
+	labels := GetGlobalCampaign().Labels()
+	if len(labels) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil
+	}
+	text := strings.ToLower(string(data))
+	var found map[string]string
+	for addr, label := range labels {
+		// with or without 0x, in any case
+		if strings.Contains(text, strings.ToLower(addr.Hex()[2:])) {
+			if found == nil {
+				found = make(map[string]string)
+			}
+			found[addr.Hex()] = label
+		}
+	}
+	return found
+}
@@ -0,0 +1,110 @@
+/**
+* @hacker_loop.go
+* Oracle: unbounded loop (gas DoS)
+* 1 per frame, count the opcodes executed and the back edges (jumps to a lower pc) of every loop.
+* 2 remember which storage slot / calldata offset a loaded value came from, and which
+*   JUMPI conditions compare against such a value (the loop bound).
+* 3 a loop whose guard is bounded by storage or calldata and that keeps iterating is
+*   reported with the controlling slot/offset: its gas cost grows with a value
+*   someone else can make large.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerLoopIterations is the number of back edges after which a bounded loop is reported.
+const hackerLoopIterations = 16
+
+type hackerLoopGuard struct {
+	bound  *big.Int
+	origin string
+}
+
+type hackerLoop struct {
+	backEdges uint64
+	reported  bool
+}
+
+type hackerLoopFrame struct {
+	steps   uint64
+	origins map[common.Hash]string
+	compare *hackerLoopGuard
+	guards  map[uint64]*hackerLoopGuard
+	loops   map[uint64]*hackerLoop
+}
+
+func (taint *HackerTaint) loopFrame(contract *Contract) *hackerLoopFrame {
+	frame, ok := taint.loopFrames[contract]
+	if !ok {
+		frame = &hackerLoopFrame{
+			origins: make(map[common.Hash]string),
+			guards:  make(map[uint64]*hackerLoopGuard),
+			loops:   make(map[uint64]*hackerLoop),
+		}
+		taint.loopFrames[contract] = frame
+	}
+	return frame
+}
+
+// checkUnboundedLoop runs after op executed, pc is the program counter left by op.
+func (taint *HackerTaint) checkUnboundedLoop(dog *WatchDog, step *hackerTaintStep, op OpCode, pc uint64, contract *Contract, stack *Stack) {
+	const bounded = taintStorage | taintCalldata
+
+	frame := taint.loopFrame(contract)
+	frame.steps++
+	switch op {
+	case SLOAD:
+		frame.origins[common.BigToHash(stack.peek())] = "storage:" + common.BigToHash(step.arg(0)).Hex()
+	case CALLDATALOAD:
+		frame.origins[common.BigToHash(stack.peek())] = fmt.Sprintf("calldata:%d", step.arg(0).Uint64())
+	case LT, GT, SLT, SGT, EQ:
+		for i := 0; i < 2; i++ {
+			if step.input(i)&bounded == 0 {
+				continue
+			}
+			guard := &hackerLoopGuard{bound: step.arg(i), origin: "derived"}
+			if origin, ok := frame.origins[common.BigToHash(step.arg(i))]; ok {
+				guard.origin = origin
+			}
+			frame.compare = guard
+		}
+	case JUMPI:
+		if step.input(1)&bounded != 0 && frame.compare != nil {
+			frame.guards[step.pc] = frame.compare
+		}
+	}
+	if (op != JUMP && op != JUMPI) || pc >= step.pc {
+		return
+	}
+	// back edge taken from step.pc to pc
+	loop, ok := frame.loops[pc]
+	if !ok {
+		loop = new(hackerLoop)
+		frame.loops[pc] = loop
+	}
+	loop.backEdges++
+	if loop.reported || loop.backEdges < hackerLoopIterations {
+		return
+	}
+	for guardPc, guard := range frame.guards {
+		if guardPc < pc || guardPc > step.pc {
+			continue
+		}
+		loop.reported = true
+		finding := newHackerFinding("unbounded_loop", "HackerUnboundedLoop")
+		finding.Detail["contract"] = contract.Address().Hex()
+		finding.Detail["loopHead"] = fmt.Sprintf("%d", pc)
+		finding.Detail["guard"] = fmt.Sprintf("%d", guardPc)
+		finding.Detail["iterations"] = fmt.Sprintf("%d", loop.backEdges)
+		finding.Detail["bound"] = guard.bound.String()
+		finding.Detail["controller"] = guard.origin
+		finding.Detail["frameSteps"] = fmt.Sprintf("%d", frame.steps)
+		dog.EmitFinding(finding)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+/**
+* @hacker_metrics.go
+* Metrics of a long-running campaign for the monitoring of the node (fuzz/metrics.go
+* exports them to Prometheus).
+* 1 the findings are counted by type since the start of the campaign, the status only
+*   keeps the last hackerRecentFindings of them.
+* 2 the time the sink takes for a report, sent or dropped, is kept in a histogram over
+*   hackerLatencyBuckets: a slow sink holds the transactions watched.
+* 3 the rest is read from the session and the status: edges, targets, corpus and the
+*   reports sent and dropped.
+ */
+package vm
+
+import (
+	"time"
+)
+
+// hackerLatencyBuckets are the upper bounds of the latency histogram, in seconds.
+var hackerLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HackerHistogram is a cumulative histogram: Counts[i] is the number of observations
+// up to Buckets[i].
+type HackerHistogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+func newHackerHistogram(buckets []float64) HackerHistogram {
+	return HackerHistogram{Buckets: buckets, Counts: make([]uint64, len(buckets))}
+}
+
+func (h *HackerHistogram) observe(value float64) {
+	for i, bound := range h.Buckets {
+		if value <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += value
+}
+
+// HackerMetrics are the metrics of the campaign.
+type HackerMetrics struct {
+	Session HackerSession
+	Edges   int
+	Targets int
+	Corpus  int
+	// Findings counts the findings by type
+	Findings map[string]uint64
+	Reports  HackerReportStats
+	// Latency is the time the sink took per report, in seconds
+	Latency HackerHistogram
+}
+
+// observeLatency adds the time the sink took for a report, the lock of c is held.
+func (c *HackerCampaign) observeLatency(elapsed time.Duration) {
+	c.latency.observe(elapsed.Seconds())
+}
+
+// Metrics returns the metrics of the campaign.
+func (c *HackerCampaign) Metrics() *HackerMetrics {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	metrics := &HackerMetrics{Session: c.session, Targets: len(c.selectors), Corpus: len(c.corpus), Findings: make(map[string]uint64), Reports: c.reports, Latency: c.latency}
+	metrics.Latency.Counts = append([]uint64{}, c.latency.Counts...)
+	for _, bitmap := range c.coverage {
+		metrics.Edges += hackerBitCount(bitmap)
+	}
+	for kind, count := range c.findingTypes {
+		metrics.Findings[kind] = count
+	}
+	return metrics
+}
@@ -0,0 +1,321 @@
+/**
+* @hacker_msgpack.go
+* MessagePack encoding of reports, the binary alternative to JSON.
+* Values are mapped the way encoding/json maps them, so a sink decodes the same document
+* in both formats: struct fields use their json names (omitempty and "-" honoured),
+* TextMarshalers (hashes, addresses, big integers) become strings, map keys are sorted,
+* and types with only a MarshalJSON go through their JSON form. Byte slices are sent
+* as bin instead of base64 strings.
+ */
+package vm
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	stringSliceType   = reflect.TypeOf([]string(nil))
+)
+
+const (
+	noMarshaler = iota
+	textMarshaler
+	textMarshalerAddr // only the pointer implements it
+	jsonMarshaler
+	jsonMarshalerAddr
+)
+
+// marshalers caches marshalerOf, the Implements checks dominate the encoding time otherwise.
+var marshalers sync.Map
+
+func marshalerOf(t reflect.Type) int {
+	if kind, ok := marshalers.Load(t); ok {
+		return kind.(int)
+	}
+	kind := noMarshaler
+	switch {
+	case t.Implements(textMarshalerType):
+		kind = textMarshaler
+	case reflect.PtrTo(t).Implements(textMarshalerType):
+		kind = textMarshalerAddr
+	case t.Implements(jsonMarshalerType):
+		kind = jsonMarshaler
+	case reflect.PtrTo(t).Implements(jsonMarshalerType):
+		kind = jsonMarshalerAddr
+	}
+	marshalers.Store(t, kind)
+	return kind
+}
+
+type msgpackEncoder struct {
+	w   *bufio.Writer
+	buf [9]byte
+}
+
+// encodeMsgpack writes v to w in MessagePack.
+func encodeMsgpack(w io.Writer, v interface{}) error {
+	enc := &msgpackEncoder{w: bufio.NewWriter(w)}
+	if err := enc.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return enc.w.Flush()
+}
+
+// head writes a type byte followed by n as a big endian integer of size bytes.
+func (enc *msgpackEncoder) head(code byte, n uint64, size int) {
+	enc.buf[0] = code
+	switch size {
+	case 1:
+		enc.buf[1] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(enc.buf[1:], uint16(n))
+	case 4:
+		binary.BigEndian.PutUint32(enc.buf[1:], uint32(n))
+	case 8:
+		binary.BigEndian.PutUint64(enc.buf[1:], n)
+	}
+	enc.w.Write(enc.buf[:1+size])
+}
+
+// length writes the header of a str, bin, array or map of n elements.
+func (enc *msgpackEncoder) length(fix byte, fixMax uint64, code8, code16, code32 byte, n int) {
+	switch {
+	case fix != 0 && uint64(n) <= fixMax:
+		enc.w.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		enc.head(code8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		enc.head(code16, uint64(n), 2)
+	default:
+		enc.head(code32, uint64(n), 4)
+	}
+}
+
+func (enc *msgpackEncoder) uint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		enc.w.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		enc.head(0xcc, n, 1)
+	case n <= math.MaxUint16:
+		enc.head(0xcd, n, 2)
+	case n <= math.MaxUint32:
+		enc.head(0xce, n, 4)
+	default:
+		enc.head(0xcf, n, 8)
+	}
+}
+
+func (enc *msgpackEncoder) int(n int64) {
+	switch {
+	case n >= 0:
+		enc.uint(uint64(n))
+	case n >= -32:
+		enc.w.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		enc.head(0xd0, uint64(n), 1)
+	case n >= math.MinInt16:
+		enc.head(0xd1, uint64(n), 2)
+	case n >= math.MinInt32:
+		enc.head(0xd2, uint64(n), 4)
+	default:
+		enc.head(0xd3, uint64(n), 8)
+	}
+}
+
+func (enc *msgpackEncoder) string(s string) {
+	enc.length(0xa0, 31, 0xd9, 0xda, 0xdb, len(s))
+	enc.w.WriteString(s)
+}
+
+func (enc *msgpackEncoder) bytes(b []byte) {
+	enc.length(0, 0, 0xc4, 0xc5, 0xc6, len(b))
+	enc.w.Write(b)
+}
+
+func (enc *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		return enc.w.WriteByte(0xc0)
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		return enc.w.WriteByte(0xc0)
+	}
+	marshaler := marshalerOf(v.Type())
+	if marshaler == textMarshaler || (marshaler == textMarshalerAddr && v.CanAddr()) {
+		if v.Kind() != reflect.Ptr && v.CanAddr() {
+			v = v.Addr()
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		enc.string(string(text))
+		return nil
+	}
+	if marshaler == jsonMarshaler || (marshaler == jsonMarshalerAddr && v.CanAddr()) {
+		if v.Kind() != reflect.Ptr && v.CanAddr() {
+			v = v.Addr()
+		}
+		raw, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return err
+		}
+		return enc.encode(reflect.ValueOf(generic))
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return enc.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return enc.w.WriteByte(0xc3)
+		}
+		return enc.w.WriteByte(0xc2)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		enc.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		enc.uint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		enc.head(0xcb, math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		enc.string(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			enc.bytes(b)
+			return nil
+		}
+		enc.length(0x90, 15, 0, 0xdc, 0xdd, v.Len())
+		if v.Type() == stringSliceType {
+			// traces, the bulk of a report
+			for _, s := range v.Interface().([]string) {
+				enc.string(s)
+			}
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return enc.encodeMap(v)
+	case reflect.Struct:
+		return enc.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (enc *msgpackEncoder) encodeMap(v reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		var name string
+		switch {
+		case key.Type().Implements(textMarshalerType):
+			text, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return err
+			}
+			name = string(text)
+		case key.Kind() == reflect.String:
+			name = key.String()
+		case key.Kind() >= reflect.Int && key.Kind() <= reflect.Int64:
+			name = strconv.FormatInt(key.Int(), 10)
+		case key.Kind() >= reflect.Uint && key.Kind() <= reflect.Uintptr:
+			name = strconv.FormatUint(key.Uint(), 10)
+		default:
+			return fmt.Errorf("msgpack: unsupported map key type %s", key.Type())
+		}
+		entries = append(entries, entry{name, v.MapIndex(key)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	enc.length(0x80, 15, 0, 0xde, 0xdf, len(entries))
+	for _, e := range entries {
+		enc.string(e.key)
+		if err := enc.encode(e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (enc *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	type field struct {
+		name  string
+		value reflect.Value
+	}
+	fields := make([]field, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name, opts := sf.Name, ""
+		if tag := sf.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(v.Field(i)) {
+			continue
+		}
+		fields = append(fields, field{name, v.Field(i)})
+	}
+	enc.length(0x80, 15, 0, 0xde, 0xdf, len(fields))
+	for _, f := range fields {
+		enc.string(f.name)
+		if err := enc.encode(f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyValue follows the omitempty rule of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMsgpackEncoding(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{5, []byte{0x05}},
+		{-1, []byte{0xff}},
+		{300, []byte{0xcd, 0x01, 0x2c}},
+		{"ab", []byte{0xa2, 'a', 'b'}},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 1, 2}},
+		{[]int{1, 2}, []byte{0x92, 1, 2}},
+		{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 1, 0xa1, 'b', 2}},
+		{HackerFinding{Type: "x", Source: "y"}, []byte{0x82, 0xa4, 't', 'y', 'p', 'e', 0xa1, 'x', 0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xa1, 'y'}},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := encodeMsgpack(&buf, test.value); err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("test %d: got %x, want %x", i, buf.Bytes(), test.want)
+		}
+	}
+	// hashes are encoded as their text form, like in JSON
+	var buf bytes.Buffer
+	encodeMsgpack(&buf, map[common.Hash]common.Hash{{}: {}})
+	if want := "0x" + string(bytes.Repeat([]byte("0"), 64)); !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("hash key not encoded as text: %x", buf.Bytes())
+	}
+}
+
+func BenchmarkReportJSON(b *testing.B) {
+	report := benchmarkReport()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		json.NewEncoder(&bytes.Buffer{}).Encode(report)
+	}
+}
+
+func BenchmarkReportMsgpack(b *testing.B) {
+	report := benchmarkReport()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encodeMsgpack(&bytes.Buffer{}, report)
+	}
+}
+
+func benchmarkReport() map[string]interface{} {
+	ring := newHackerTraceRing(1 << 14)
+	for pc := uint64(0); pc < 1<<14; pc++ {
+		ring.push(pc, ADD, 0)
+	}
+	return map[string]interface{}{"trace": ring.strings(), "hasThrow": false}
+}
@@ -0,0 +1,171 @@
+/**
+* @hacker_multiwatch.go
+* Several transactions watched at the same time.
+* 1 a WatchDog watches one transaction (env, tx) at a time. When Watch is called for a
+*   transaction of another EVM while it is still watching one, the new transaction is
+*   watched by a fork of the WatchDog: a WatchDog of its own, with its balance snapshot,
+*   recording and report, and the settings of its parent (step budget, progress
+*   threshold, competitor).
+* 2 the hooks of the interpreter find the WatchDog of an execution through its EVM
+*   (hackerDogs): the fork watching a transaction of the EVM, the global WatchDogs
+*   otherwise.
+* 3 End and EndTracer of the parent end the fork watching the transaction of the
+*   receipt. Start does not reset a WatchDog that is watching, the transaction it is
+*   watching is abandoned at the end of the block, with the forks left.
+* The call tree (hacker_contractcall.go) is recorded per EVM as well.
+ */
+package vm
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	watchesLock sync.RWMutex
+	// watches holds the forks by the EVM of their transaction
+	watches = make(map[*EVM]*WatchDog)
+	// watchCount is the number of forks, read on every operation
+	watchCount int32
+)
+
+// hackerDogs returns the WatchDogs recording the executions of evm: the WatchDog and
+// the tracer WatchDog, or their forks watching a transaction of evm.
+func hackerDogs(evm *EVM) [2]*WatchDog {
+	dogs := [2]*WatchDog{GetGlobalWatchDog(), GetGlobalTracerWatchDog()}
+	if atomic.LoadInt32(&watchCount) == 0 {
+		return dogs
+	}
+	watchesLock.RLock()
+	defer watchesLock.RUnlock()
+	if fork, ok := watches[evm]; ok {
+		if fork.parent == dogs[1] {
+			dogs[1] = fork
+		} else {
+			dogs[0] = fork
+		}
+	}
+	return dogs
+}
+
+// markWatched tells whether tx was not watched before, marking it.
+func markWatched(tx common.Hash) bool {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+	if _, ok := handleSet[tx.Hex()]; ok {
+		return false
+	}
+	handleSet[tx.Hex()] = true
+	return true
+}
+
+// busyWith tells whether the WatchDog is watching a transaction of another EVM than env.
+func (dog *WatchDog) busyWith(env *EVM) bool {
+	watched := dog.watchedEnv()
+	return watched != nil && watched != env
+}
+
+// watchedEnv returns the EVM of the watched transaction, nil when none is watched.
+func (dog *WatchDog) watchedEnv() *EVM {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	if !dog.watching() {
+		return nil
+	}
+	return dog.env
+}
+
+// watchAside watches the transaction tx of env with a fork of the WatchDog.
+func (dog *WatchDog) watchAside(env *EVM, tx *types.Transaction) *WatchDog {
+	fork := newWatchDog()
+	fork.parent = dog
+	fork.stepBudget, fork.progressEvery = atomic.LoadUint64(&dog.stepBudget), atomic.LoadUint64(&dog.progressEvery)
+	fork.competitor, fork.block = dog.frontRunner(), dog.block
+	fork.Start()
+	fork.Watch(env, tx)
+	if !fork.watching() {
+		return nil
+	}
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+	if dog.forks == nil {
+		dog.forks = make(map[common.Hash]*WatchDog)
+	}
+	dog.forks[tx.Hash()] = fork
+	if _, ok := watches[env]; !ok {
+		atomic.AddInt32(&watchCount, 1)
+	}
+	watches[env] = fork
+	return fork
+}
+
+// forkOf returns the fork watching the transaction hash, nil if there is none.
+func (dog *WatchDog) forkOf(hash common.Hash) *WatchDog {
+	watchesLock.RLock()
+	defer watchesLock.RUnlock()
+	return dog.forks[hash]
+}
+
+// alias lets the fork watching a transaction be found by another hash as well.
+func (dog *WatchDog) alias(fork *WatchDog, hash common.Hash) {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+	dog.forks[hash] = fork
+}
+
+// unfork forgets fork once its transaction ended.
+func (dog *WatchDog) unfork(fork *WatchDog) {
+	watchesLock.Lock()
+	defer watchesLock.Unlock()
+	for hash, other := range dog.forks {
+		if other == fork {
+			delete(dog.forks, hash)
+		}
+	}
+	for env, other := range watches {
+		if other == fork {
+			delete(watches, env)
+			atomic.AddInt32(&watchCount, -1)
+		}
+	}
+}
+
+// abandon stops watching the transaction left without End at the end of a block,
+// with the forks left.
+func (dog *WatchDog) abandon() {
+	dog.setTurnOn(false)
+	dog.arm(nil)
+	dog.proxy, dog.typed, dog.selector, dog.accounts, dog.coverage, dog.reduced, dog.blocked = nil, nil, nil, nil, nil, nil, nil
+	dog.revert, dog.constraint, dog.properties, dog.confirmEnv, dog.pluginView = nil, nil, nil, nil, nil
+	watchesLock.RLock()
+	forks := make([]*WatchDog, 0, len(dog.forks))
+	for _, fork := range dog.forks {
+		forks = append(forks, fork)
+	}
+	watchesLock.RUnlock()
+	for _, fork := range forks {
+		fork.abandon()
+		dog.unfork(fork)
+	}
+}
+
+// Watching returns the number of transactions the WatchDog and its forks are watching.
+func (dog *WatchDog) Watching() int {
+	watching := 0
+	if dog.watching() {
+		watching++
+	}
+	watchesLock.RLock()
+	defer watchesLock.RUnlock()
+	seen := make(map[*WatchDog]bool)
+	for _, fork := range dog.forks {
+		if !seen[fork] && fork.watching() {
+			seen[fork] = true
+			watching++
+		}
+	}
+	return watching
+}
@@ -0,0 +1,198 @@
+/**
+* @hacker_nft.go
+* ERC-721/1155 oracle pack, for the targets whose ABI registered ownerOf(uint256)
+* (ERC-721) or the five argument safeTransferFrom (ERC-1155).
+* It works on the events and storage writes recorded by hacker_erc20.go:
+*   "nft_missing_transfer_event" an owner slot (mapping keyed by a token id holding an
+*                                address) moved from one owner to another without the
+*                                matching Transfer event, or an ERC-1155 safeTransferFrom
+*                                without its TransferSingle.
+*   "nft_unsafe_receiver"        a safe transfer to a contract that succeeded although the
+*                                receiver hook was never called, or the receiver code does
+*                                not contain the hook selector.
+*   "nft_duplicate_mint"         a Transfer from the zero address for a token id that already
+*                                had an owner, or that was minted twice in the transaction.
+ */
+package vm
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	erc1155TransferSingleEvent = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+
+	erc721OwnerOf          = selectorOf("ownerOf(uint256)")
+	erc721TransferFrom     = selectorOf("transferFrom(address,address,uint256)")
+	erc721SafeTransfer     = selectorOf("safeTransferFrom(address,address,uint256)")
+	erc721SafeTransferData = selectorOf("safeTransferFrom(address,address,uint256,bytes)")
+	erc1155SafeTransfer    = selectorOf("safeTransferFrom(address,address,uint256,uint256,bytes)")
+
+	erc721Received  = selectorOf("onERC721Received(address,address,uint256,bytes)")
+	erc1155Received = selectorOf("onERC1155Received(address,address,uint256,uint256,bytes)")
+)
+
+// isOwnerWord tells whether word may hold an owner: empty or an address too large to be
+// a counter.
+func isOwnerWord(word common.Hash) bool {
+	n := word.Big()
+	return n.Sign() == 0 || n.BitLen() > 64 && n.BitLen() <= 160
+}
+
+// calledHook tells whether a frame under frame i invoked selector on receiver, and
+// whether that frame succeeded.
+func (journal *hackerFrameJournal) calledHook(i int, receiver common.Address, selector [4]byte) (called, succeeded bool) {
+	for j := i + 1; j < len(journal.frames); j++ {
+		next := &journal.frames[j]
+		if next.kind == CALL && next.Address() == receiver && len(next.Input()) >= 4 &&
+			bytes.Equal(next.Input()[:4], selector[:]) && journal.descends(j, i) {
+			return true, !next.failed
+		}
+	}
+	return false, false
+}
+
+// checkNFT runs the ERC-721/1155 oracles over the frames of the transaction and returns
+// their findings.
+func (taint *HackerTaint) checkNFT(statedb StateDB) []*HackerFinding {
+	nft := taint.erc20
+	if len(nft.events)+len(nft.writes) == 0 {
+		return nil
+	}
+	live := taint.journal.live()
+	events, final := nft.kept(live)
+	registry := GetABIRegistry()
+	isERC721 := func(token common.Address) bool {
+		_, ok := registry.Lookup(token, erc721OwnerOf[:])
+		return ok
+	}
+	findings := make([]*HackerFinding, 0)
+	for i := range taint.journal.frames {
+		call := &taint.journal.frames[i]
+		if !live[i] || call.kind != CALL {
+			continue
+		}
+		method, ok := registry.Lookup(call.Address(), call.Input())
+		if !ok {
+			continue
+		}
+		from, to, id := abiWord(call.Input(), 0), abiWord(call.Input(), 1), abiWord(call.Input(), 2).Big()
+		receiver := common.BytesToAddress(to.Bytes())
+		var hook [4]byte
+		switch method.Selector {
+		case erc721SafeTransfer, erc721SafeTransferData:
+			hook = erc721Received
+		case erc1155SafeTransfer:
+			hook = erc1155Received
+			value, found := abiWord(call.Input(), 3).Big(), false
+			for _, event := range events {
+				if event.topic == erc1155TransferSingleEvent && event.token == call.Address() && event.from == from &&
+					event.to == to && event.id.Cmp(id) == 0 && event.value.Cmp(value) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				finding := newHackerFinding("nft_missing_transfer_event", "HackerNFT")
+				finding.Detail["token"] = call.Address().Hex()
+				finding.Detail["from"] = common.BytesToAddress(from.Bytes()).Hex()
+				finding.Detail["to"] = receiver.Hex()
+				finding.Detail["id"] = id.Text(10)
+				finding.Detail["value"] = value.Text(10)
+				findings = append(findings, finding)
+			}
+		default:
+			continue
+		}
+		if statedb == nil || statedb.GetCodeSize(receiver) == 0 {
+			continue
+		}
+		reason := ""
+		if called, succeeded := taint.journal.calledHook(i, receiver, hook); !called {
+			reason = "hook_not_called"
+		} else if !succeeded {
+			reason = "hook_failed"
+		} else if !bytes.Contains(statedb.GetCode(receiver), hook[:]) {
+			reason = "hook_not_implemented"
+		}
+		if reason != "" {
+			finding := newHackerFinding("nft_unsafe_receiver", "HackerNFT")
+			finding.Detail["token"] = call.Address().Hex()
+			finding.Detail["receiver"] = receiver.Hex()
+			finding.Detail["id"] = id.Text(10)
+			finding.Detail["reason"] = reason
+			findings = append(findings, finding)
+		}
+	}
+
+	// owner slots of the ERC-721 targets, by token and id
+	type ownerKey struct {
+		token common.Address
+		id    common.Hash
+	}
+	owners := make(map[ownerKey]hackerTaintStorageKey)
+	for key, current := range final {
+		preimage, ok := taint.signatures.preimages[key.slot]
+		if !ok || len(preimage.words) != 2 || !isERC721(key.addr) {
+			continue
+		}
+		if _, nested := taint.signatures.preimages[preimage.words[1]]; nested {
+			continue
+		}
+		old := nft.old[key]
+		if old == current || !isOwnerWord(old) || !isOwnerWord(current) {
+			continue
+		}
+		owners[ownerKey{key.addr, preimage.words[0]}] = key
+		found := false
+		for _, event := range events {
+			if event.topic == erc20TransferEvent && event.id != nil && event.token == key.addr &&
+				common.BigToHash(event.id) == preimage.words[0] && event.from == old && event.to == current {
+				found = true
+				break
+			}
+		}
+		if !found {
+			finding := newHackerFinding("nft_missing_transfer_event", "HackerNFT")
+			finding.Detail["token"] = key.addr.Hex()
+			finding.Detail["from"] = common.BytesToAddress(old.Bytes()).Hex()
+			finding.Detail["to"] = common.BytesToAddress(current.Bytes()).Hex()
+			finding.Detail["id"] = preimage.words[0].Big().Text(10)
+			finding.Detail["slot"] = key.slot.Hex()
+			findings = append(findings, finding)
+		}
+	}
+
+	minted := make(map[ownerKey]bool)
+	for _, event := range events {
+		if event.topic != erc20TransferEvent || event.id == nil || !isERC721(event.token) {
+			continue
+		}
+		key := ownerKey{event.token, common.BigToHash(event.id)}
+		if event.to == (common.Hash{}) {
+			delete(minted, key)
+			continue
+		}
+		if event.from != (common.Hash{}) {
+			continue
+		}
+		previous := ""
+		if minted[key] {
+			previous = "minted"
+		} else if slot, ok := owners[key]; ok && nft.old[slot] != (common.Hash{}) {
+			previous = common.BytesToAddress(nft.old[slot].Bytes()).Hex()
+		}
+		minted[key] = true
+		if previous != "" {
+			finding := newHackerFinding("nft_duplicate_mint", "HackerNFT")
+			finding.Detail["token"] = event.token.Hex()
+			finding.Detail["id"] = event.id.Text(10)
+			finding.Detail["previous"] = previous
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNFTOracles(t *testing.T) {
+	var (
+		owner = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		buyer = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		token = common.HexToAddress("0x03")
+		id    = common.BigToHash(big.NewInt(7))
+		slot  = common.HexToHash("0xaa")
+		taint = newHackerTaint()
+	)
+	GetABIRegistry().Register(token, "ownerOf(uint256)")
+	GetABIRegistry().Register(token, "transferFrom(address,address,uint256)")
+	input := append(erc721TransferFrom[:], common.BytesToHash(owner.Bytes()).Bytes()...)
+	input = append(input, common.BytesToHash(buyer.Bytes()).Bytes()...)
+	input = append(input, id.Bytes()...)
+	call := enterTestFrame(taint, owner, token, input)
+
+	taint.signatures.preimages[slot] = &hackerPreimage{words: []common.Hash{id, common.BigToHash(big.NewInt(2))}}
+	key := hackerTaintStorageKey{token, slot}
+	taint.erc20.old[key] = common.BytesToHash(owner.Bytes())
+	taint.erc20.writes = append(taint.erc20.writes, hackerERC20Write{call, key, common.BytesToHash(buyer.Bytes())})
+	findings := taint.checkNFT(poolStateDB{})
+	if len(findings) != 1 || findings[0].Type != "nft_missing_transfer_event" {
+		t.Fatalf("ownership change without event not reported: %v", findings)
+	}
+
+	// the owner slot was already set when the id is minted
+	taint.erc20.events = append(taint.erc20.events, hackerERC20Event{
+		frame: call, token: token, topic: erc20TransferEvent,
+		from: common.Hash{}, to: common.BytesToHash(buyer.Bytes()), id: id.Big(), value: big.NewInt(1),
+	})
+	findings = taint.checkNFT(poolStateDB{})
+	if len(findings) != 2 || findings[1].Type != "nft_duplicate_mint" || findings[1].Detail["previous"] != owner.Hex() {
+		t.Fatalf("duplicate mint not reported: %v", findings)
+	}
+}
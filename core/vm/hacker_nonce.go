@@ -0,0 +1,139 @@
+/**
+* @hacker_nonce.go
+* Nonce allocation of the fuzzer senders, so that parallel workers submitting
+* transactions of the same sender neither reuse a nonce nor leave a gap stalling the
+* ones after it.
+* 1 AllocateNonce hands out the lowest nonce free: a released nonce first, the next
+*   one never handed out otherwise. The allocation of a sender starts at its nonce in
+*   the state and follows it: the nonces below the state nonce are mined, they are
+*   dropped from the released and the allocated ones.
+* 2 ReleaseNonce gives back the nonce of a candidate dropped before it was sent, the
+*   next allocation of the sender reuses it. Releasing the last nonce handed out moves
+*   the allocation back instead.
+* 3 the allocations are kept per sender until the campaign is reset (ResetNonces drops
+*   those of one sender).
+ */
+package vm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerNonceState is the nonce allocation of a sender.
+type HackerNonceState struct {
+	Sender common.Address `json:"sender"`
+	// Next is the lowest nonce never handed out
+	Next uint64 `json:"next"`
+	// Released are handed out again before Next, Allocated are in use
+	Released  []uint64 `json:"released"`
+	Allocated []uint64 `json:"allocated"`
+}
+
+// hackerSenderNonces allocates the nonces of a sender.
+type hackerSenderNonces struct {
+	next      uint64
+	released  []uint64
+	allocated map[uint64]bool
+}
+
+// sync drops the nonces below the nonce of the sender in the state, mined.
+func (nonces *hackerSenderNonces) sync(stateNonce uint64) {
+	if stateNonce > nonces.next {
+		nonces.next = stateNonce
+	}
+	released := nonces.released[:0]
+	for _, nonce := range nonces.released {
+		if nonce >= stateNonce {
+			released = append(released, nonce)
+		}
+	}
+	nonces.released = released
+	for nonce := range nonces.allocated {
+		if nonce < stateNonce {
+			delete(nonces.allocated, nonce)
+		}
+	}
+}
+
+// allocate hands out the lowest nonce free.
+func (nonces *hackerSenderNonces) allocate() uint64 {
+	nonce := nonces.next
+	if len(nonces.released) > 0 {
+		nonce, nonces.released = nonces.released[0], nonces.released[1:]
+	} else {
+		nonces.next++
+	}
+	nonces.allocated[nonce] = true
+	return nonce
+}
+
+// release gives nonce back.
+func (nonces *hackerSenderNonces) release(nonce uint64) bool {
+	if !nonces.allocated[nonce] {
+		return false
+	}
+	delete(nonces.allocated, nonce)
+	if nonce+1 != nonces.next {
+		i := sort.Search(len(nonces.released), func(i int) bool { return nonces.released[i] > nonce })
+		nonces.released = append(nonces.released, 0)
+		copy(nonces.released[i+1:], nonces.released[i:])
+		nonces.released[i] = nonce
+		return true
+	}
+	// the last nonce handed out, with the released ones just below it
+	nonces.next--
+	for n := len(nonces.released); n > 0 && nonces.released[n-1]+1 == nonces.next; n-- {
+		nonces.released = nonces.released[:n-1]
+		nonces.next--
+	}
+	return true
+}
+
+// AllocateNonce hands out a nonce of sender, whose nonce in the state is stateNonce.
+func (c *HackerCampaign) AllocateNonce(sender common.Address, stateNonce uint64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	nonces := c.nonces[sender]
+	if nonces == nil {
+		nonces = &hackerSenderNonces{allocated: make(map[uint64]bool)}
+		c.nonces[sender] = nonces
+	}
+	nonces.sync(stateNonce)
+	return nonces.allocate()
+}
+
+// ReleaseNonce gives back nonce of sender, handed out by AllocateNonce and never sent.
+func (c *HackerCampaign) ReleaseNonce(sender common.Address, nonce uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if nonces := c.nonces[sender]; nonces == nil || !nonces.release(nonce) {
+		return fmt.Errorf("nonce %d of %s not allocated", nonce, sender.Hex())
+	}
+	return nil
+}
+
+// ResetNonces drops the nonce allocation of sender.
+func (c *HackerCampaign) ResetNonces(sender common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.nonces, sender)
+}
+
+// Nonces returns the nonce allocation of sender, nil when none was handed out.
+func (c *HackerCampaign) Nonces(sender common.Address) *HackerNonceState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	nonces := c.nonces[sender]
+	if nonces == nil {
+		return nil
+	}
+	state := &HackerNonceState{Sender: sender, Next: nonces.next, Released: append([]uint64{}, nonces.released...), Allocated: make([]uint64, 0, len(nonces.allocated))}
+	for nonce := range nonces.allocated {
+		state.Allocated = append(state.Allocated, nonce)
+	}
+	sort.Slice(state.Allocated, func(i, j int) bool { return state.Allocated[i] < state.Allocated[j] })
+	return state
+}
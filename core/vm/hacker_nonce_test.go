@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAllocateNonceParallel(t *testing.T) {
+	c := newHackerCampaign()
+	sender := common.HexToAddress("0x5e")
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		handed = make(map[uint64]int)
+	)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				nonce := c.AllocateNonce(sender, 7)
+				lock.Lock()
+				handed[nonce]++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for nonce := uint64(7); nonce < 807; nonce++ {
+		if handed[nonce] != 1 {
+			t.Fatalf("nonce %d handed out %d times", nonce, handed[nonce])
+		}
+	}
+	if state := c.Nonces(sender); state.Next != 807 || len(state.Allocated) != 800 {
+		t.Errorf("next %d, %d allocated, want 807, 800", state.Next, len(state.Allocated))
+	}
+}
+
+func TestReleaseNonce(t *testing.T) {
+	c := newHackerCampaign()
+	sender := common.HexToAddress("0x5e")
+	for i := 0; i < 5; i++ {
+		c.AllocateNonce(sender, 0)
+	}
+	// the dropped candidates of 1 and 2 are reused first, lowest first
+	for _, nonce := range []uint64{2, 1} {
+		if err := c.ReleaseNonce(sender, nonce); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.ReleaseNonce(sender, 2); err == nil {
+		t.Error("nonce released twice")
+	}
+	if nonce := c.AllocateNonce(sender, 0); nonce != 1 {
+		t.Errorf("nonce %d, want the released 1", nonce)
+	}
+	// releasing the last nonce moves the allocation back over the released ones
+	c.ReleaseNonce(sender, 3)
+	c.ReleaseNonce(sender, 4)
+	if state := c.Nonces(sender); state.Next != 2 || len(state.Released) != 0 || !reflect.DeepEqual(state.Allocated, []uint64{0, 1}) {
+		t.Errorf("state %+v, want 0 and 1 allocated, 2 next", state)
+	}
+	// the nonces mined in the state are dropped
+	if nonce := c.AllocateNonce(sender, 5); nonce != 5 {
+		t.Errorf("nonce %d, want the state nonce 5", nonce)
+	}
+	if state := c.Nonces(sender); !reflect.DeepEqual(state.Allocated, []uint64{5}) {
+		t.Errorf("allocated %v, want 5", state.Allocated)
+	}
+	c.ResetNonces(sender)
+	if state := c.Nonces(sender); state != nil {
+		t.Errorf("state %+v after the reset", state)
+	}
+}
@@ -465,7 +465,7 @@ func (oracle *HackerExceptionDisorder) TestOracle() bool{
 	exception := false
 	nextcalls := oracle.hacker_calls[0].nextcalls
 	for _,call := range nextcalls {
-		if oracle.TriggerExceptionCall(hacker_calls[0],call) {
+		if oracle.TriggerExceptionCall(oracle.hacker_calls[0],call) {
 			oracle.hacker_exception_calls = append(oracle.hacker_exception_calls, call)
 			exception = true
 		}
@@ -757,7 +757,7 @@ func (oracle *HackerTimestampOp) InitOracle(hacker_call_hashs []common.Hash,hack
 	oracle.hacker_exception_calls = make([]*HackerContractCall,0,10)
 }
 func (oracle * HackerTimestampOp) TestOracle() bool{
-	var rootCall = hacker_calls[0]
+	var rootCall = oracle.hacker_calls[0]
 	return strings.Contains(rootCall.OperationStack.String(),opCodeToString[TIMESTAMP])
 }
 func (oracle *HackerTimestampOp) Write(writer io.Writer){
@@ -797,7 +797,7 @@ func (oracle *HackerNumberOp) InitOracle(hacker_call_hashs []common.Hash,hacker_
 	oracle.hacker_exception_calls = make([]*HackerContractCall,0,10)
 }
 func (oracle * HackerNumberOp) TestOracle() bool{
-	var rootCall = hacker_calls[0]
+	var rootCall = oracle.hacker_calls[0]
 	return strings.Contains(rootCall.OperationStack.String(),opCodeToString[NUMBER])
 }
 func (oracle *HackerNumberOp) Write(writer io.Writer){
@@ -835,7 +835,7 @@ func (oracle *HackerBlockHashOp) InitOracle(hacker_call_hashs []common.Hash,hack
 	oracle.hacker_exception_calls = make([]*HackerContractCall,0,10)
 }
 func (oracle * HackerBlockHashOp) TestOracle() bool{
-	var rootCall = hacker_calls[0]
+	var rootCall = oracle.hacker_calls[0]
 	return strings.Contains(rootCall.OperationStack.String(),opCodeToString[BLOCKHASH])
 }
 func (oracle *HackerBlockHashOp) Write(writer io.Writer){
@@ -872,7 +872,7 @@ func (oracle *HackerBalanceGtZero) InitOracle(hacker_call_hashs []common.Hash,ha
 	oracle.hacker_call_hashs = hacker_call_hashs
 }
 func (oracle * HackerBalanceGtZero) TestOracle() bool{
-	var rootCall = hacker_calls[0]
+	var rootCall = oracle.hacker_calls[0]
 	return rootCall.StateStack.Data()[0].contracts[1].balance.Uint64()>0
 }
 func (oracle *HackerBalanceGtZero) Write(writer io.Writer){
@@ -0,0 +1,73 @@
+/**
+* @hacker_override.go
+* State overrides for harness executions (the eth_call "stateOverrides" of the fuzz API).
+* Overrides are written into the state like any other change, so applying them inside
+* HackerHarness.Fork keeps the canonical state untouched.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerAccountOverride replaces parts of one account, nil fields are left as they are.
+// State replaces the whole storage while StateDiff only patches the given slots.
+type HackerAccountOverride struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// HackerStateOverride maps accounts to their overrides.
+type HackerStateOverride map[common.Address]*HackerAccountOverride
+
+// Apply writes the overrides into statedb.
+func (override HackerStateOverride) Apply(statedb StateDB) {
+	for addr, account := range override {
+		if account == nil {
+			continue
+		}
+		if !statedb.Exist(addr) {
+			statedb.CreateAccount(addr)
+		}
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, *account.Nonce)
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SubBalance(addr, statedb.GetBalance(addr))
+			statedb.AddBalance(addr, account.Balance)
+		}
+		if account.State != nil {
+			cleared := make([]common.Hash, 0)
+			statedb.ForEachStorage(addr, func(key, value common.Hash) bool {
+				cleared = append(cleared, key)
+				return true
+			})
+			for _, key := range cleared {
+				statedb.SetState(addr, key, common.Hash{})
+			}
+			for key, value := range account.State {
+				statedb.SetState(addr, key, value)
+			}
+		}
+		for key, value := range account.StateDiff {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
+// ExecuteWithOverride runs msg on a fork of the state with override applied first.
+func (harness *HackerHarness) ExecuteWithOverride(msg *HackerMessage, override HackerStateOverride) (outcome *HackerOutcome) {
+	harness.Fork(func() {
+		override.Apply(harness.statedb)
+		outcome = harness.Apply(msg)
+	})
+	return outcome
+}
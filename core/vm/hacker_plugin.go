@@ -0,0 +1,246 @@
+/**
+* @hacker_plugin.go
+* Host side of the oracle plugin interface, for detectors too hot to run as scripts.
+* 1 a HackerPluginRuntime (the WASM engine of hacker_wasmvm.go unless the node registers
+*   another one) compiles every *.wasm module found in the plugin directory at startup.
+* 2 hacker_close hands the call tree of the watched transaction to its WatchDog, which
+*   runs every loaded module against it when the transaction ends, next to the other
+*   checks of the report.
+* 3 a module only sees the stable host API below (frames, storage journal,
+*   EmitFinding); it never gets a pointer into the interpreter, so the engine's
+*   sandbox is the only thing it can touch.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerPluginAPIVersion is bumped whenever the host API seen by plugins changes.
+const HackerPluginAPIVersion = 1
+
+var errNoPluginRuntime = errors.New("no oracle plugin runtime registered")
+
+// HackerPluginRuntime compiles plugin modules into runnable instances.
+type HackerPluginRuntime interface {
+	// Name returns the runtime name, e.g. "wasm".
+	Name() string
+	// Load compiles one module. The runtime is responsible for sandboxing
+	// (memory limits, no host imports besides HackerPluginHost).
+	Load(name string, module []byte) (HackerPluginInstance, error)
+}
+
+// HackerPluginInstance is a compiled oracle module.
+type HackerPluginInstance interface {
+	// Check runs the detector against the host view of the finished execution
+	// and reports whether the oracle fired.
+	Check(host *HackerPluginHost) (bool, error)
+}
+
+// HackerFrameView is the read-only frame record handed to plugins. Operation is the
+// call kind that created the frame (CALL, CALLCODE, DELEGATECALL, STATICCALL, PRECOMPILE).
+type HackerFrameView struct {
+	Index     int    `json:"index"`
+	Parent    int    `json:"parent"`
+	Operation string `json:"operation"`
+	Caller    string `json:"caller"`
+	Callee    string `json:"callee"`
+	Value     string `json:"value"`
+	Gas       string `json:"gas"`
+	Input     string `json:"input"`
+	Throw     bool   `json:"throw"`
+	OutOfGas  bool   `json:"outOfGas"`
+	Error     string `json:"error"`
+	// Depth is the call depth, 0 for the frame of the transaction.
+	Depth int `json:"depth"`
+	// ReadOnly is set for frames running in a static context: STATICCALL frames and
+	// the frames they call.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// HackerStorageEntry is one slot of the WatchDog storage journal.
+type HackerStorageEntry struct {
+	Slot common.Hash `json:"slot"`
+	Old  common.Hash `json:"old"`
+	New  common.Hash `json:"new"`
+}
+
+// HackerPluginHost is the host API exposed to a plugin instance.
+type HackerPluginHost struct {
+	name        string
+	dog         *WatchDog
+	frames      []HackerFrameView
+	precompiles []HackerPrecompileView
+}
+
+// hackerPluginView is the call tree of a watched transaction as the plugins see it,
+// taken when the tree closes.
+type hackerPluginView struct {
+	frames      []HackerFrameView
+	precompiles []HackerPrecompileView
+}
+
+// Version returns the host API version.
+func (host *HackerPluginHost) Version() int {
+	return HackerPluginAPIVersion
+}
+
+// Frames returns the recorded call frames in creation order.
+func (host *HackerPluginHost) Frames() []HackerFrameView {
+	if host.frames == nil {
+		return []HackerFrameView{}
+	}
+	return host.frames
+}
+
+// hackerFrameViews returns the frames of calls in creation order.
+func hackerFrameViews(calls []*HackerContractCall) []HackerFrameView {
+	frames := make([]HackerFrameView, 0, len(calls))
+	for i, call := range calls {
+		parent := -1
+		for j := i - 1; j >= 0; j-- {
+			if calls[j].isAncestor(call) {
+				parent = j
+				break
+			}
+		}
+		kind := call.OperationStack.Data()[0]
+		frames = append(frames, HackerFrameView{
+			Index:     i,
+			Parent:    parent,
+			Operation: kind,
+			Caller:    call.caller.Hex(),
+			Callee:    call.callee.Hex(),
+			Value:     call.value.Text(10),
+			Gas:       call.gas.Text(10),
+			Input:     hex.EncodeToString(call.input),
+			Throw:     call.throwException,
+			OutOfGas:  call.errOutGas,
+			Error:     call.errKind.String(),
+			Depth:     call.depth,
+			ReadOnly:  call.readOnly,
+		})
+	}
+	return frames
+}
+
+// StorageJournal returns the storage slots touched by the watched transaction.
+func (host *HackerPluginHost) StorageJournal() []HackerStorageEntry {
+	entries := make([]HackerStorageEntry, 0)
+	if host.dog == nil {
+		return entries
+	}
+	storageOld, storageNew := host.dog.storage()
+	for slot, old := range storageOld {
+		entry := HackerStorageEntry{Slot: slot, Old: old, New: old}
+		if value, ok := storageNew[slot]; ok {
+			entry.New = value
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Slot.Big().Cmp(entries[j].Slot.Big()) < 0
+	})
+	return entries
+}
+
+// EmitFinding attaches a finding raised by the plugin to the WatchDog report.
+func (host *HackerPluginHost) EmitFinding(kind string, detail map[string]string) {
+	if host.dog == nil {
+		return
+	}
+	finding := newHackerFinding(kind, "plugin:"+host.name)
+	for key, value := range detail {
+		finding.Detail[key] = value
+	}
+	host.dog.EmitFinding(finding)
+}
+
+var hacker_plugin_runtime HackerPluginRuntime
+var hacker_plugins []*HackerPluginOracle
+
+// RegisterPluginRuntime installs the engine used by LoadOraclePlugins.
+func RegisterPluginRuntime(runtime HackerPluginRuntime) {
+	hacker_plugin_runtime = runtime
+}
+
+// LoadOraclePlugins compiles every *.wasm module in dir and adds it to the
+// oracles checked at the end of each watched transaction. It is meant to be
+// called once at node startup.
+func LoadOraclePlugins(dir string) error {
+	if hacker_plugin_runtime == nil {
+		return errNoPluginRuntime
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".wasm") {
+			continue
+		}
+		module, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(file.Name(), ".wasm")
+		instance, err := hacker_plugin_runtime.Load(name, module)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", name, err)
+		}
+		hacker_plugins = append(hacker_plugins, &HackerPluginOracle{name: name, instance: instance})
+		Printf("loaded %s oracle plugin %s", hacker_plugin_runtime.Name(), name)
+	}
+	return nil
+}
+
+// HackerPluginOracle is a loaded plugin module.
+type HackerPluginOracle struct {
+	name     string
+	instance HackerPluginInstance
+}
+
+// check runs the plugin against host and reports whether it fired, a plugin failing
+// or panicking does not.
+func (oracle *HackerPluginOracle) check(host *HackerPluginHost) (fired bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			Printf("oracle plugin %s panicked: %v", oracle.name, err)
+			fired = false
+		}
+	}()
+	fired, err := oracle.instance.Check(host)
+	if err != nil {
+		Printf("oracle plugin %s failed: %v", oracle.name, err)
+		return false
+	}
+	return fired
+}
+
+func (oracle *HackerPluginOracle) String() string {
+	return "HackerPlugin:" + oracle.name
+}
+
+// checkPlugins runs the oracle plugins against the call tree of the watched
+// transaction, a plugin firing is a finding named after it.
+func (dog *WatchDog) checkPlugins() {
+	view := dog.pluginView
+	dog.pluginView = nil
+	if view == nil {
+		return
+	}
+	for _, plugin := range hacker_plugins {
+		host := &HackerPluginHost{name: plugin.name, dog: dog, frames: view.frames, precompiles: view.precompiles}
+		if plugin.check(host) {
+			dog.EmitFinding(newHackerFinding(plugin.String(), "plugin:"+plugin.name))
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package vm
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func wasmLEB(value uint32) []byte {
+	var out []byte
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmI32(value int32) []byte {
+	var out []byte
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 && b&0x40 == 0 || value == -1 && b&0x40 != 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmVec(items ...[]byte) []byte {
+	out := wasmLEB(uint32(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func wasmName(name string) []byte {
+	return append(wasmLEB(uint32(len(name))), name...)
+}
+
+func wasmSection(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, wasmLEB(uint32(len(payload)))...), payload...)
+}
+
+func wasmConcat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// wasmPlugin assembles a module importing frames and emit, with pages of memory
+// holding data at 0 and exporting check with body.
+func wasmPlugin(pages uint32, data []byte, body ...[]byte) []byte {
+	return wasmPluginLocals(0, pages, data, body...)
+}
+
+// wasmPluginLocals assembles a plugin whose check has locals i32 locals.
+func wasmPluginLocals(locals byte, pages uint32, data []byte, body ...[]byte) []byte {
+	declared := []byte{0x00}
+	if locals > 0 {
+		declared = []byte{0x01, locals, 0x7f}
+	}
+	code := wasmConcat(append([][]byte{declared}, body...)...)
+	return wasmConcat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		wasmSection(1, wasmVec(
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f},
+			[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x00},
+			[]byte{0x60, 0x00, 0x01, 0x7f},
+		)),
+		wasmSection(2, wasmVec(
+			wasmConcat(wasmName("hacker"), wasmName("frames"), []byte{0x00, 0x00}),
+			wasmConcat(wasmName("hacker"), wasmName("emit"), []byte{0x00, 0x01}),
+		)),
+		wasmSection(3, wasmVec([]byte{0x02})),
+		wasmSection(5, wasmVec(append([]byte{0x00}, wasmLEB(pages)...))),
+		wasmSection(7, wasmVec(
+			wasmConcat(wasmName("check"), []byte{0x00, 0x02}),
+			wasmConcat(wasmName("memory"), []byte{0x02, 0x00}),
+		)),
+		wasmSection(10, wasmVec(append(wasmLEB(uint32(len(code))), code...))),
+		wasmSection(11, wasmVec(wasmConcat([]byte{0x00, 0x41, 0x00, 0x0b}, wasmLEB(uint32(len(data))), data))),
+	)
+}
+
+func TestWasmPluginOracle(t *testing.T) {
+	plugins := hacker_plugins
+	defer func() { hacker_plugins = plugins }()
+	hacker_plugins = nil
+
+	const kind, detail = "plugin_hit", `{"reason":"frames"}`
+	// emit kind when the JSON of the frames is longer than "[]"
+	hit := wasmPlugin(1, []byte(kind+detail),
+		wasmI32Const(256), wasmI32Const(4096), []byte{0x10, 0x00}, wasmI32Const(2), []byte{0x4b},
+		[]byte{0x04, 0x40},
+		wasmI32Const(0), wasmI32Const(int32(len(kind))), wasmI32Const(int32(len(kind))), wasmI32Const(int32(len(detail))), []byte{0x10, 0x01},
+		wasmI32Const(1), []byte{0x0f},
+		[]byte{0x0b},
+		wasmI32Const(0), []byte{0x0b},
+	)
+	// loop forever
+	spin := wasmPlugin(1, nil, []byte{0x03, 0x40, 0x0c, 0x00, 0x0b}, wasmI32Const(0), []byte{0x0b})
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, module := range map[string][]byte{"hit": hit, "spin": spin, "notes": []byte("not a module")} {
+		file := name + ".wasm"
+		if name == "notes" {
+			file = name + ".txt"
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), module, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadOraclePlugins(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(hacker_plugins) != 2 {
+		t.Fatalf("loaded %d plugins, want 2", len(hacker_plugins))
+	}
+
+	env := NewEVM(Context{}, new(frameStateDB), params.TestChainConfig, Config{})
+	dog := GetGlobalWatchDog()
+	turnOn, findings := dog.watching(), dog.findings
+	armed, _ := dog.armed.Load().(*EVM)
+	defer func() { dog.setTurnOn(turnOn); dog.arm(armed); dog.findings = findings }()
+	dog.setTurnOn(true)
+	dog.arm(env)
+	// the plugins check the tree closed when the transaction ends
+	dog.findings = nil
+	tree := newHackerCallTree(env)
+	tree.record(newHackerContractCall(tree, "CALL", common.HexToAddress("0x0a"), common.HexToAddress("0x0b"), *new(big.Int), *big.NewInt(21000), []byte{0x12, 0x34, 0x56, 0x78}))
+	hacker_close(tree)
+	if len(dog.findings) != 0 {
+		t.Fatalf("plugins checked before the end of the transaction: %+v", dog.findings)
+	}
+	dog.checkPlugins()
+	found := make([]HackerFinding, 0)
+	for _, finding := range dog.findings {
+		if strings.HasPrefix(finding.Source, "plugin:") {
+			found = append(found, finding)
+		}
+	}
+	if len(found) != 2 {
+		t.Fatalf("unexpected findings %+v", found)
+	}
+	if found[0].Type != kind || found[0].Source != "plugin:hit" || found[0].Detail["reason"] != "frames" {
+		t.Errorf("finding %+v, want the one emitted by hit", found[0])
+	}
+	if found[1].Type != "HackerPlugin:hit" || found[1].Source != "plugin:hit" {
+		t.Errorf("finding %+v, want hit fired", found[1])
+	}
+	// a tree without frames fires nothing, neither does a transaction without a tree
+	dog.findings = nil
+	dog.pluginView = &hackerPluginView{}
+	dog.checkPlugins()
+	if len(dog.findings) != 0 {
+		t.Errorf("plugin fired without frames: %+v", dog.findings)
+	}
+	dog.checkPlugins()
+	if len(dog.findings) != 0 {
+		t.Errorf("plugin fired without a tree: %+v", dog.findings)
+	}
+}
+
+func wasmI32Const(value int32) []byte {
+	return append([]byte{0x41}, wasmI32(value)...)
+}
+
+func TestWasmPluginLimits(t *testing.T) {
+	runtime := hackerWasmRuntime{}
+	if _, err := runtime.Load("big", wasmPlugin(hackerWasmMaxPages+1, nil, wasmI32Const(0), []byte{0x0b})); err == nil {
+		t.Error("loaded a module over the memory limit")
+	}
+	// memory.grow over the limit returns -1
+	grow := wasmPlugin(1, nil, wasmI32Const(hackerWasmMaxPages), []byte{0x40, 0x00}, wasmI32Const(-1), []byte{0x46, 0x0b})
+	instance, err := runtime.Load("grow", grow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fired, err := instance.Check(&HackerPluginHost{name: "grow"}); !fired || err != nil {
+		t.Errorf("memory grown over the limit: %v, %v", fired, err)
+	}
+	// out of bounds store traps
+	store := wasmPlugin(1, nil, wasmI32Const(hackerWasmPageSize-2), wasmI32Const(1), []byte{0x36, 0x02, 0x00}, wasmI32Const(1), []byte{0x0b})
+	if instance, err = runtime.Load("store", store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instance.Check(&HackerPluginHost{name: "store"}); err != errWasmMemory {
+		t.Errorf("got %v, want %v", err, errWasmMemory)
+	}
+	spin := wasmPlugin(1, nil, []byte{0x03, 0x40, 0x0c, 0x00, 0x0b}, wasmI32Const(0), []byte{0x0b})
+	if instance, err = runtime.Load("spin", spin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instance.Check(&HackerPluginHost{name: "spin"}); err != errWasmFuel {
+		t.Errorf("got %v, want %v", err, errWasmFuel)
+	}
+	if _, err := runtime.Load("float", wasmPlugin(1, nil, []byte{0x43, 0, 0, 0, 0, 0x1a}, wasmI32Const(0), []byte{0x0b})); err == nil {
+		t.Error("loaded a module using floats")
+	}
+}
+
+func TestWasmPluginValidation(t *testing.T) {
+	invalid := map[string][]byte{
+		// check returns an i64
+		"type": wasmPlugin(1, nil, []byte{0x42, 0x00, 0x0b}),
+		// check returns nothing
+		"empty":  wasmPlugin(1, nil, []byte{0x0b}),
+		"local":  wasmPlugin(1, nil, []byte{0x20, 0x00, 0x0b}),
+		"branch": wasmPlugin(1, nil, []byte{0x0c, 0x01}, wasmI32Const(0), []byte{0x0b}),
+		"else":   wasmPlugin(1, nil, []byte{0x05}, wasmI32Const(0), []byte{0x0b}),
+		// emit takes four operands
+		"call": wasmPlugin(1, nil, wasmI32Const(0), []byte{0x10, 0x01}, wasmI32Const(0), []byte{0x0b}),
+		"data": wasmPlugin(1, make([]byte, hackerWasmPageSize+1), wasmI32Const(0), []byte{0x0b}),
+	}
+	for name, module := range invalid {
+		if _, err := (hackerWasmRuntime{}).Load(name, module); err == nil {
+			t.Errorf("%s: loaded an invalid module", name)
+		}
+	}
+	if _, err := (hackerWasmRuntime{}).Load("valid", wasmPlugin(1, nil, wasmI32Const(0), []byte{0x0b})); err != nil {
+		t.Errorf("refused a valid module: %v", err)
+	}
+}
+
+func TestWasmPluginLoop(t *testing.T) {
+	// sum 1..10 in local 1, counting in local 0, then compare it to 55
+	sum := wasmPluginLocals(2, 1, nil,
+		[]byte{0x03, 0x40},
+		[]byte{0x20, 0x01, 0x20, 0x00, 0x6a, 0x21, 0x01},
+		[]byte{0x20, 0x00}, wasmI32Const(1), []byte{0x6a, 0x22, 0x00},
+		wasmI32Const(10), []byte{0x4c, 0x0d, 0x00},
+		[]byte{0x0b},
+		[]byte{0x20, 0x01}, wasmI32Const(55), []byte{0x46, 0x0b},
+	)
+	instance, err := hackerWasmRuntime{}.Load("sum", sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fired, err := instance.Check(&HackerPluginHost{name: "sum"}); !fired || err != nil {
+		t.Errorf("got %v, %v, want the sum of 1..10 to be 55", fired, err)
+	}
+}
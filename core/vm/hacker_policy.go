@@ -0,0 +1,303 @@
+/**
+* @hacker_policy.go
+* Policy deciding, before a transaction is watched, whether and how deeply it is watched.
+* 1 the policy of the campaign is called by Watch with the sender, the target, the
+*   selector and the value of the transaction and decides: "watch" (full
+*   instrumentation), "calls" (only the call operations are recorded, as for a
+*   saturated target, hacker_saturation.go) or "skip" (not watched at all).
+* 2 the policy is a Go function (SetPolicy) or a list of rules (CompileHackerPolicy),
+*   one per line or separated by ';', the first rule whose condition holds decides:
+*     to == 0x7a25...488d && selector == 0x38ed1739 -> calls
+*     value >= 1000000000000000000 -> watch
+*     from != 0x00...a1 -> skip
+*     watch
+*   A condition compares from, to, selector and value with numbers (decimal or 0x
+*   hexadecimal) with == != < <= > >=, combined with && || ! and parentheses; a rule
+*   without condition always holds. '#' starts a comment. Without matching rule the
+*   transaction is watched.
+* The selector of a transaction with less than 4 bytes of data equals no number.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HackerPolicyTx is what the policy knows of a transaction.
+type HackerPolicyTx struct {
+	From common.Address
+	To   common.Address
+	// Selector is nil when the data is shorter than a selector
+	Selector []byte
+	Value    *big.Int
+}
+
+// HackerPolicyDecision is how a transaction is watched.
+type HackerPolicyDecision int
+
+const (
+	HackerPolicyWatch HackerPolicyDecision = iota
+	HackerPolicyCalls
+	HackerPolicySkip
+)
+
+var hackerPolicyNames = map[HackerPolicyDecision]string{
+	HackerPolicyWatch: "watch",
+	HackerPolicyCalls: "calls",
+	HackerPolicySkip:  "skip",
+}
+
+func (decision HackerPolicyDecision) String() string {
+	return hackerPolicyNames[decision]
+}
+
+// HackerPolicy decides how the transaction tx is watched.
+type HackerPolicy func(tx *HackerPolicyTx) HackerPolicyDecision
+
+// SetPolicy sets the policy of the campaign, nil watches every transaction.
+func (c *HackerCampaign) SetPolicy(policy HackerPolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.policy = policy
+}
+
+// decide returns how the transaction tx sent by from is watched.
+func (c *HackerCampaign) decide(from common.Address, tx *types.Transaction) HackerPolicyDecision {
+	c.lock.Lock()
+	policy := c.policy
+	c.lock.Unlock()
+	if policy == nil {
+		return HackerPolicyWatch
+	}
+	info := &HackerPolicyTx{From: from, To: *tx.To(), Value: tx.Value()}
+	if data := tx.Data(); len(data) >= 4 {
+		info.Selector = common.CopyBytes(data[:4])
+	}
+	return policy(info)
+}
+
+// hackerPolicyRule is a rule of a compiled policy, cond is nil when it always holds.
+type hackerPolicyRule struct {
+	cond     hackerPolicyCond
+	decision HackerPolicyDecision
+}
+
+type hackerPolicyCond func(tx *HackerPolicyTx) bool
+
+// CompileHackerPolicy compiles the rules of src into a policy.
+func CompileHackerPolicy(src string) (HackerPolicy, error) {
+	var rules []hackerPolicyRule
+	for i, line := range strings.Split(src, "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		for _, text := range strings.Split(line, ";") {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			rule, err := compilePolicyRule(text)
+			if err != nil {
+				return nil, fmt.Errorf("policy line %d: %v", i+1, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return func(tx *HackerPolicyTx) HackerPolicyDecision {
+		for _, rule := range rules {
+			if rule.cond == nil || rule.cond(tx) {
+				return rule.decision
+			}
+		}
+		return HackerPolicyWatch
+	}, nil
+}
+
+func compilePolicyRule(text string) (hackerPolicyRule, error) {
+	rule := hackerPolicyRule{}
+	action := text
+	if arrow := strings.LastIndex(text, "->"); arrow >= 0 {
+		parser := &hackerPolicyParser{tokens: tokenizePolicy(text[:arrow])}
+		cond, err := parser.or()
+		if err != nil {
+			return rule, err
+		}
+		if parser.pos != len(parser.tokens) {
+			return rule, fmt.Errorf("unexpected %q", parser.tokens[parser.pos])
+		}
+		rule.cond, action = cond, text[arrow+2:]
+	}
+	action = strings.TrimSpace(action)
+	for decision, name := range hackerPolicyNames {
+		if name == action {
+			rule.decision = decision
+			return rule, nil
+		}
+	}
+	return rule, fmt.Errorf("unknown decision %q", action)
+}
+
+// tokenizePolicy splits a condition into operators, parentheses and words.
+func tokenizePolicy(text string) []string {
+	var tokens []string
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.ContainsRune("()", rune(c)):
+			tokens = append(tokens, text[i:i+1])
+			i++
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			j := i + 1
+			if j < len(text) && strings.ContainsRune("=&|", rune(text[j])) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(text) && !strings.ContainsRune(" \t\r()=!<>&|", rune(text[j])) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+type hackerPolicyParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *hackerPolicyParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *hackerPolicyParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *hackerPolicyParser) or() (hackerPolicyCond, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right hackerPolicyCond
+		if right, err = p.and(); err == nil {
+			a, b := left, right
+			left = func(tx *HackerPolicyTx) bool { return a(tx) || b(tx) }
+		}
+	}
+	return left, err
+}
+
+func (p *hackerPolicyParser) and() (hackerPolicyCond, error) {
+	left, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right hackerPolicyCond
+		if right, err = p.unary(); err == nil {
+			a, b := left, right
+			left = func(tx *HackerPolicyTx) bool { return a(tx) && b(tx) }
+		}
+	}
+	return left, err
+}
+
+func (p *hackerPolicyParser) unary() (hackerPolicyCond, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		cond, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(tx *HackerPolicyTx) bool { return !cond(tx) }, nil
+	case "(":
+		p.next()
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if token := p.next(); token != ")" {
+			return nil, fmt.Errorf("expected ) instead of %q", token)
+		}
+		return cond, nil
+	}
+	return p.comparison()
+}
+
+// hackerPolicyOperand returns the value of an operand for tx, nil when it has none.
+type hackerPolicyOperand func(tx *HackerPolicyTx) *big.Int
+
+var hackerPolicyFields = map[string]hackerPolicyOperand{
+	"from":  func(tx *HackerPolicyTx) *big.Int { return tx.From.Big() },
+	"to":    func(tx *HackerPolicyTx) *big.Int { return tx.To.Big() },
+	"value": func(tx *HackerPolicyTx) *big.Int { return tx.Value },
+	"selector": func(tx *HackerPolicyTx) *big.Int {
+		if tx.Selector == nil {
+			return nil
+		}
+		return new(big.Int).SetBytes(tx.Selector)
+	},
+}
+
+var hackerPolicyComparisons = map[string]func(cmp int) bool{
+	"==": func(cmp int) bool { return cmp == 0 },
+	"!=": func(cmp int) bool { return cmp != 0 },
+	"<":  func(cmp int) bool { return cmp < 0 },
+	"<=": func(cmp int) bool { return cmp <= 0 },
+	">":  func(cmp int) bool { return cmp > 0 },
+	">=": func(cmp int) bool { return cmp >= 0 },
+}
+
+func (p *hackerPolicyParser) operand() (hackerPolicyOperand, error) {
+	token := p.next()
+	if field, ok := hackerPolicyFields[token]; ok {
+		return field, nil
+	}
+	number, ok := new(big.Int), false
+	if strings.HasPrefix(token, "0x") || strings.HasPrefix(token, "0X") {
+		number, ok = number.SetString(token[2:], 16)
+	} else {
+		number, ok = number.SetString(token, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	return func(tx *HackerPolicyTx) *big.Int { return number }, nil
+}
+
+func (p *hackerPolicyParser) comparison() (hackerPolicyCond, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	compare, ok := hackerPolicyComparisons[op]
+	if !ok {
+		return nil, fmt.Errorf("expected a comparison instead of %q", op)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return func(tx *HackerPolicyTx) bool {
+		a, b := left(tx), right(tx)
+		if a == nil || b == nil {
+			// a missing selector is different from any number
+			return op == "!="
+		}
+		return compare(a.Cmp(b))
+	}, nil
+}
@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCompilePolicy(t *testing.T) {
+	policy, err := CompileHackerPolicy(`
+# the router is watched calls-only
+to == 0x00000000000000000000000000000000000000c1 && selector == 0xa9059cbb -> calls
+value >= 1000000000000000000 -> watch; !(from == 0xa1 || from == 0xa2) -> skip
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, other := common.HexToAddress("0xc1"), common.HexToAddress("0xc2")
+	transfer := common.FromHex("0xa9059cbb")
+	for i, test := range []struct {
+		tx   HackerPolicyTx
+		want HackerPolicyDecision
+	}{
+		{HackerPolicyTx{From: common.HexToAddress("0xa1"), To: router, Selector: transfer, Value: new(big.Int)}, HackerPolicyCalls},
+		{HackerPolicyTx{From: common.HexToAddress("0xa1"), To: router, Value: new(big.Int)}, HackerPolicyWatch},
+		{HackerPolicyTx{From: common.HexToAddress("0xb1"), To: other, Value: big.NewInt(1e18)}, HackerPolicyWatch},
+		{HackerPolicyTx{From: common.HexToAddress("0xb1"), To: other, Value: new(big.Int)}, HackerPolicySkip},
+		{HackerPolicyTx{From: common.HexToAddress("0xa2"), To: other, Selector: transfer, Value: new(big.Int)}, HackerPolicyWatch},
+	} {
+		if got := policy(&test.tx); got != test.want {
+			t.Errorf("tx %d: %v, want %v", i, got, test.want)
+		}
+	}
+
+	for _, src := range []string{"to == -> skip", "value > 1 -> ignore", "(to == 1 -> skip", "to = 1 -> skip", "sender == 1 -> skip"} {
+		if _, err := CompileHackerPolicy(src); err == nil {
+			t.Errorf("policy %q compiled", src)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/**
+* @hacker_pool.go
+* Reuse of HackerContractCall frames.
+* 1 frames come from a sync.Pool, their slices (input, next calls, operation and state
+*   stacks) keep their capacity from one transaction to the next.
+* 2 every frame handed out since hacker_init() is listed in the frames of its call tree,
+*   hacker_close() releases that tree alone once the oracles and the report are done
+*   with it, the trees recorded by the other EVMs are left alone.
+* Nothing may keep a frame after hacker_close(), copy what is needed instead.
+ */
+package vm
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var hackerCallPool = sync.Pool{
+	New: func() interface{} {
+		return &HackerContractCall{
+			OperationStack: newHackerOperationStack(),
+			StateStack:     newHackerStateStack(),
+			nextcalls:      make([]*HackerContractCall, 0),
+		}
+	},
+}
+
+// acquire returns a frame from the pool, listed in the frames of the tree.
+func (tree *hackerCallTree) acquire() *HackerContractCall {
+	call := hackerCallPool.Get().(*HackerContractCall)
+	tree.frames = append(tree.frames, call)
+	return call
+}
+
+// reset clears call for reuse, keeping the capacity of its slices.
+func (call *HackerContractCall) reset() {
+	for i := range call.nextcalls {
+		call.nextcalls[i] = nil
+	}
+	for i := range call.StateStack.data {
+		call.StateStack.data[i] = nil
+	}
+	*call = HackerContractCall{
+		input:          call.input[:0],
+		output:         call.output[:0],
+		nextcalls:      call.nextcalls[:0],
+		OperationStack: call.OperationStack,
+		StateStack:     call.StateStack,
+	}
+	call.OperationStack.data = call.OperationStack.data[:0]
+	call.StateStack.data = call.StateStack.data[:0]
+}
+
+// release returns every frame of the tree to the pool.
+func (tree *hackerCallTree) release() {
+	for i, call := range tree.frames {
+		call.reset()
+		hackerCallPool.Put(call)
+		tree.frames[i] = nil
+	}
+	tree.frames = tree.frames[:0]
+}
+
+// init sets up a frame acquired from the pool, see newHackerContractCall.
+func (call *HackerContractCall) init(operation string, caller, callee common.Address, value, gas big.Int, input []byte) {
+	call.caller = caller
+	call.callee = callee
+	call.value.Set(&value)
+	call.gas.Set(&gas)
+	call.input = append(call.input[:0], input...)
+	// frames are created before the interpreter of the callee increments the depth
+	env := call.tree.env
+	call.depth = env.depth
+	call.readOnly = env.interpreter != nil && env.interpreter.readOnly
+	call.OperationStack.push(operation)
+	call.StateStack.push(call.tree.state(caller, callee))
+}
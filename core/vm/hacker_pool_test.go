@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// poolStateDB is a NoopStateDB with balances, frames read them when created.
+type poolStateDB struct{ NoopStateDB }
+
+func (poolStateDB) GetBalance(common.Address) *big.Int { return new(big.Int) }
+
+func TestContractCallPool(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0x01")
+		callee = common.HexToAddress("0x02")
+	)
+	tree := newHackerCallTree(NewEVM(Context{}, poolStateDB{}, params.TestChainConfig, Config{}))
+	root := newHackerContractCall(tree, "STARTRECORD", caller, callee, *big.NewInt(1), *big.NewInt(2), []byte{1, 2, 3})
+	child := root.OnCall(AccountRef(callee), caller, *big.NewInt(3), *big.NewInt(4), []byte{4})
+	if len(tree.frames) != 2 || len(root.nextcalls) != 1 || root.nextcalls[0] != child {
+		t.Fatalf("frames %d, next calls %d", len(tree.frames), len(root.nextcalls))
+	}
+	tree.release()
+	if len(tree.frames) != 0 {
+		t.Fatal("frames not released")
+	}
+	if len(root.input) != 0 || len(root.nextcalls) != 0 || root.OperationStack.len() != 0 || root.value.Sign() != 0 {
+		t.Fatalf("released frame not reset: %+v", root)
+	}
+	call := newHackerContractCall(tree, "CALL", callee, caller, *big.NewInt(5), *big.NewInt(6), []byte{7})
+	if call.callee != caller || call.value.Int64() != 5 || len(call.input) != 1 || call.OperationStack.len() != 1 {
+		t.Fatalf("unexpected frame %+v", call)
+	}
+	tree.release()
+}
+
+func TestContractCallPoolPerTree(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0x01")
+		callee = common.HexToAddress("0x02")
+	)
+	env := NewEVM(Context{}, poolStateDB{}, params.TestChainConfig, Config{})
+	first, second := newHackerCallTree(env), newHackerCallTree(env)
+	root := newHackerContractCall(first, "STARTRECORD", caller, callee, *new(big.Int), *new(big.Int), []byte{1})
+	other := newHackerContractCall(second, "STARTRECORD", callee, caller, *new(big.Int), *new(big.Int), []byte{2})
+	child := other.OnCall(AccountRef(caller), callee, *new(big.Int), *new(big.Int), []byte{3})
+	// releasing the first tree leaves the frames of the second one alone
+	first.release()
+	if len(first.frames) != 0 || len(root.input) != 0 {
+		t.Fatalf("first tree not released: %d frames", len(first.frames))
+	}
+	if len(second.frames) != 2 || other.callee != caller || len(other.nextcalls) != 1 || other.nextcalls[0] != child || child.input[0] != 3 {
+		t.Fatalf("frames of the second tree released: %+v", other)
+	}
+	second.release()
+}
@@ -0,0 +1,101 @@
+/**
+* @hacker_precompile.go
+* Precompiled contracts in the call tree.
+* run() hands precompiles to RunPrecompiledContract without entering the interpreter,
+* so they never reached hacker_init/Hacker_record. Each invocation is now recorded as a
+* leaf frame ("PRECOMPILE") under the frame that called it, with its input, output and
+* gas. The leaves are kept out of the calls of the tree, the oracles written against
+* them see the same calls as before, and are listed in its precompiles instead.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// OnPrecompile records a call of the precompile at addr made by the frame.
+func (call *HackerContractCall) OnPrecompile(caller ContractRef, addr common.Address, value *big.Int, gas uint64, input []byte) *HackerContractCall {
+	precompile := newHackerContractCall(call.tree, "PRECOMPILE", caller.Address(), addr, *value, *new(big.Int).SetUint64(gas), input)
+	precompile.isPrecompile = true
+	call.nextcalls = append(call.nextcalls, precompile)
+	call.tree.precompiles = append(call.tree.precompiles, precompile)
+	return precompile
+}
+
+// OnClosePrecompile records the result of the precompile.
+func (call *HackerContractCall) OnClosePrecompile(output []byte, gasLeft uint64, err error) {
+	call.output = append(call.output[:0], output...)
+	call.finalgas.SetUint64(gasLeft)
+	call.setError(err)
+}
+
+// IsPrecompile tells whether the frame is a precompile invocation.
+func (call *HackerContractCall) IsPrecompile() bool {
+	return call.isPrecompile
+}
+
+// Output returns what the precompile returned.
+func (call *HackerContractCall) Output() []byte {
+	return call.output
+}
+
+// hacker_run_precompile runs p like RunPrecompiledContract and records it when a call
+// tree is being recorded. ecrecover calls are handed to the signature replay oracle
+// whether or not a tree is recorded.
+func hacker_run_precompile(evm *EVM, p PrecompiledContract, input []byte, contract *Contract) ([]byte, error) {
+	var frame *HackerContractCall
+	if tree := evm.callTree; tree != nil && tree.stack.len() > 0 {
+		frame = tree.stack.peek().OnPrecompile(contract.caller, *contract.CodeAddr, contract.Value(), contract.Gas, input)
+	}
+	ret, err := RunPrecompiledContract(p, input, contract)
+	if frame != nil {
+		frame.OnClosePrecompile(ret, contract.Gas, err)
+	}
+	if err == nil && *contract.CodeAddr == ecrecoverAddress {
+		for _, dog := range hackerDogs(evm) {
+			if dog.watches(evm) && dog.TurnOn() && dog.taint != nil {
+				dog.taint.onEcrecover(contract.caller.Address(), input, ret)
+			}
+		}
+	}
+	return ret, err
+}
+
+// HackerPrecompileView is a precompile invocation as seen by a plugin.
+type HackerPrecompileView struct {
+	Caller  string `json:"caller"`
+	Address string `json:"address"`
+	Input   string `json:"input"`
+	Output  string `json:"output"`
+	Gas     string `json:"gas"`
+	GasLeft string `json:"gasLeft"`
+	Error   string `json:"error"`
+}
+
+// Precompiles returns the precompile invocations of the watched transaction.
+func (host *HackerPluginHost) Precompiles() []HackerPrecompileView {
+	if host.precompiles == nil {
+		return []HackerPrecompileView{}
+	}
+	return host.precompiles
+}
+
+// hackerPrecompileViews returns the views of the precompile invocations.
+func hackerPrecompileViews(precompiles []*HackerContractCall) []HackerPrecompileView {
+	views := make([]HackerPrecompileView, 0, len(precompiles))
+	for _, call := range precompiles {
+		views = append(views, HackerPrecompileView{
+			Caller:  call.caller.Hex(),
+			Address: call.callee.Hex(),
+			Input:   hex.EncodeToString(call.input),
+			Output:  hex.EncodeToString(call.output),
+			Gas:     call.gas.Text(10),
+			GasLeft: call.finalgas.Text(10),
+			Error:   call.errKind.String(),
+		})
+	}
+	return views
+}
@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestPrecompileFrames(t *testing.T) {
+	if !hackerHooksCompiled {
+		t.Skip("hooks compiled out")
+	}
+	env := NewEVM(Context{}, poolStateDB{}, params.TestChainConfig, Config{})
+	tree := newHackerCallTree(env)
+	env.callTree = tree
+	defer tree.release()
+	caller := common.HexToAddress("0xca11")
+	root := newHackerContractCall(tree, "STARTRECORD", caller, caller, *new(big.Int), *new(big.Int), nil)
+	tree.stack.push(root)
+
+	identity := common.BytesToAddress([]byte{4})
+	contract := NewContract(AccountRef(caller), AccountRef(identity), new(big.Int), 1000)
+	contract.SetCallCode(&identity, common.Hash{}, nil)
+	input := []byte("hello")
+	ret, err := run(env, 0, contract, input)
+	if err != nil || !bytes.Equal(ret, input) {
+		t.Fatalf("identity failed: %x %v", ret, err)
+	}
+	if len(tree.precompiles) != 1 || len(root.nextcalls) != 1 {
+		t.Fatalf("precompile not recorded: %d frames", len(tree.precompiles))
+	}
+	frame := tree.precompiles[0]
+	if !frame.IsPrecompile() || frame.callee != identity || !bytes.Equal(frame.Output(), input) || frame.ErrorKind() != FrameOK {
+		t.Fatalf("unexpected frame %+v", frame)
+	}
+	if frame.finalgas.Uint64() != contract.Gas || frame.gas.Uint64() != 1000 {
+		t.Fatalf("gas not recorded: %v -> %v", frame.gas.Uint64(), frame.finalgas.Uint64())
+	}
+	if len(tree.calls) != 0 {
+		t.Fatal("precompile frames must stay out of the calls of the tree")
+	}
+}
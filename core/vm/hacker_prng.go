@@ -0,0 +1,45 @@
+/**
+* @hacker_prng.go
+* Seeded block environment for fuzz executions.
+* When a message executed by the harness carries a seed (fuzz_call takes one per
+* call), BLOCKHASH, DIFFICULTY and COINBASE no longer read the chain but are derived
+* from keccak256(seed, opcode, args), so one input can be replayed under a "lucky"
+* and an "unlucky" environment at will. The transactions of the node are never seeded.
+ */
+package vm
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// hackerSeedFor returns the seed pinning the block environment of evm, nil when it
+// is not pinned or the hooks are off.
+func hackerSeedFor(evm *EVM) *common.Hash {
+	if !HooksEnabled() {
+		return nil
+	}
+	return evm.seed
+}
+
+func seededWord(seed *common.Hash, op OpCode, arg uint64) []byte {
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], arg)
+	return crypto.Keccak256(seed.Bytes(), []byte(opCodeToString[op]), num[:])
+}
+
+func seededBlockHash(seed *common.Hash, number uint64) common.Hash {
+	return common.BytesToHash(seededWord(seed, BLOCKHASH, number))
+}
+
+func seededDifficulty(seed *common.Hash) *big.Int {
+	// keep it non-zero, a zero difficulty is never seen on chain
+	return new(big.Int).Add(new(big.Int).SetBytes(seededWord(seed, DIFFICULTY, 0)), common.Big1)
+}
+
+func seededCoinbase(seed *common.Hash) common.Address {
+	return common.BytesToAddress(seededWord(seed, COINBASE, 0))
+}
@@ -0,0 +1,209 @@
+/**
+* @hacker_profit.go
+* Profit oracle: can anyone make free money out of the targets?
+* 1 the balance of the sender is taken when the message call of the watched transaction
+*   starts and when it returns, the difference is what the execution moved to or from
+*   the sender, before gas.
+* 2 the gas used by the transaction at its effective price is subtracted: what is left is
+*   the net profit of the sender. A net profit above the threshold of a transaction that
+*   ran code of one of the profit targets (in any frame) is a "profit" finding.
+* 3 with registered tokens, the ERC-20 balances of the sender are probed around the
+*   message call too, by balanceOf view calls at depth 0 reverted afterwards (the
+*   watching WatchDogs are paused meanwhile, the probes are not part of the trace).
+*   A token balance growing while neither the ether before gas nor another registered
+*   token balance of the sender fell is a "profit_token" finding: the sender got tokens
+*   for nothing but gas. Swapping ether or a registered token for them is not.
+* The profit is counterfactual: the transaction might not have been mined as is, the
+* finding is confirmed like the others (hacker_confirm.go).
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerProbeGas is the gas of a balanceOf probe.
+const hackerProbeGas = 100000
+
+var erc20BalanceOf = selectorOf("balanceOf(address)")
+
+// SetProfitOracle checks the watched transactions running code of targets for a net
+// profit of their sender above threshold wei. No target turns the oracle off.
+func (c *HackerCampaign) SetProfitOracle(targets []common.Address, threshold *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.profitTargets = make(map[common.Address]bool, len(targets))
+	for _, target := range targets {
+		c.profitTargets[target] = true
+	}
+	c.profitThreshold = new(big.Int)
+	if threshold != nil {
+		c.profitThreshold.Set(threshold)
+	}
+}
+
+// SetProfitTokens registers the ERC-20 tokens whose balances of the sender the profit
+// oracle checks, replacing the previous ones.
+func (c *HackerCampaign) SetProfitTokens(tokens []common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.profitTokens = make([]common.Address, len(tokens))
+	copy(c.profitTokens, tokens)
+}
+
+// profitOracle returns the profit targets and threshold, no target when the oracle is
+// off.
+func (c *HackerCampaign) profitOracle() (map[common.Address]bool, *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.profitTargets, c.profitThreshold
+}
+
+// profitTokenList returns the registered tokens, none when the oracle is off.
+func (c *HackerCampaign) profitTokenList() []common.Address {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.profitTargets) == 0 {
+		return nil
+	}
+	return c.profitTokens
+}
+
+// pauseWatching pauses the WatchDogs watching evm, the probes it runs are not part of
+// their trace, and returns the function resuming them.
+func pauseWatching(evm *EVM) func() {
+	var paused []*WatchDog
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.watching() {
+			dog.setTurnOn(false)
+			paused = append(paused, dog)
+		}
+	}
+	return func() {
+		for _, dog := range paused {
+			dog.setTurnOn(true)
+		}
+	}
+}
+
+// probeTokens returns the balances of holder of the tokens answering balanceOf in the
+// state of evm, which is left as is. The WatchDogs watching evm are paused during the
+// probes.
+func probeTokens(evm *EVM, holder common.Address, tokens []common.Address) map[common.Address]*big.Int {
+	if len(tokens) == 0 {
+		return nil
+	}
+	defer pauseWatching(evm)()
+	input := append(erc20BalanceOf[:], common.LeftPadBytes(holder.Bytes(), 32)...)
+	balances := make(map[common.Address]*big.Int, len(tokens))
+	for _, token := range tokens {
+		snapshot := evm.snapshot()
+		ret, _, err := evm.StaticCall(AccountRef(holder), token, input, hackerProbeGas)
+		evm.revertToSnapshot(snapshot)
+		if err == nil && len(ret) >= 32 {
+			balances[token] = new(big.Int).SetBytes(ret[:32])
+		}
+	}
+	return balances
+}
+
+// hacker_execution_end is called when a message call of evm at depth 0 returns.
+func hacker_execution_end(evm *EVM) {
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() && dog.started && dog.senderNew == nil {
+			dog.senderNew = new(big.Int).Set(evm.StateDB.GetBalance(evm.Origin))
+			dog.tokensNew = probeTokens(evm, evm.Origin, GetGlobalCampaign().profitTokenList())
+		}
+		if dog.watches(evm) && dog.TurnOn() && dog.constraint != nil && dog.constraint.new == nil {
+			dog.constraint.new = probeConstraints(evm, dog.constraint.constraints)
+		}
+		if dog.watches(evm) && dog.TurnOn() && dog.started && dog.properties == nil {
+			dog.properties = checkProperties(evm)
+		}
+		if dog.watches(evm) && dog.TurnOn() {
+			// the findings raised from now on are not at an instruction (hacker_disasm.go)
+			dog.at = hackerPcKey{}
+		}
+	}
+}
+
+// profitTarget returns the first profit target whose code the watched transaction ran.
+func (dog *WatchDog) profitTarget(targets map[common.Address]bool) (common.Address, bool) {
+	if targets[*dog.tx.To()] {
+		return *dog.tx.To(), true
+	}
+	if dog.taint != nil {
+		for _, frame := range dog.taint.journal.frames {
+			if targets[frame.Address()] {
+				return frame.Address(), true
+			}
+		}
+	}
+	return common.Address{}, false
+}
+
+// checkProfit raises a finding when the sender of the watched transaction, which used
+// gasUsed gas, made a net profit out of a profit target.
+func (dog *WatchDog) checkProfit(gasUsed *big.Int) {
+	targets, threshold := GetGlobalCampaign().profitOracle()
+	if len(targets) == 0 || dog.senderOld == nil || dog.senderNew == nil {
+		return
+	}
+	target, ok := dog.profitTarget(targets)
+	if !ok {
+		return
+	}
+	gross := new(big.Int).Sub(dog.senderNew, dog.senderOld)
+	gasCost := new(big.Int)
+	if gasUsed != nil && dog.env.GasPrice != nil {
+		gasCost.Mul(gasUsed, dog.env.GasPrice)
+	}
+	profit := new(big.Int).Sub(gross, gasCost)
+	dog.checkTokenProfit(target, gross)
+	if profit.Cmp(threshold) <= 0 {
+		return
+	}
+	finding := newHackerFinding("profit", "HackerProfit")
+	finding.Detail["sender"] = dog.env.Origin.Hex()
+	finding.Detail["target"] = target.Hex()
+	finding.Detail["profit"] = profit.Text(10)
+	finding.Detail["gross"] = gross.Text(10)
+	finding.Detail["gasCost"] = gasCost.Text(10)
+	dog.EmitFinding(finding)
+}
+
+// checkTokenProfit raises a finding when the sender of the watched transaction, whose
+// ether balance changed by gross before gas, got registered tokens for nothing.
+func (dog *WatchDog) checkTokenProfit(target common.Address, gross *big.Int) {
+	if gross.Sign() < 0 {
+		return
+	}
+	deltas := make(map[common.Address]*big.Int)
+	gained := false
+	for token, old := range dog.tokensOld {
+		current, ok := dog.tokensNew[token]
+		if !ok {
+			continue
+		}
+		delta := new(big.Int).Sub(current, old)
+		if delta.Sign() < 0 {
+			return
+		}
+		if delta.Sign() > 0 {
+			deltas[token], gained = delta, true
+		}
+	}
+	if !gained {
+		return
+	}
+	finding := newHackerFinding("profit_token", "HackerProfit")
+	finding.Detail["sender"] = dog.env.Origin.Hex()
+	finding.Detail["target"] = target.Hex()
+	finding.Detail["gross"] = gross.Text(10)
+	for token, delta := range deltas {
+		finding.Detail["token:"+token.Hex()] = delta.Text(10)
+	}
+	dog.EmitFinding(finding)
+}
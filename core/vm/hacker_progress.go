@@ -0,0 +1,83 @@
+/**
+* @hacker_progress.go
+* Progress of the watched transactions running long.
+* 1 with a progress threshold set, a WatchDog counts the operations of the transaction
+*   it watches and, every threshold operations, posts a partial report to the progress
+*   URL: the operations executed, the gas used so far and the stack of the frames
+*   running, innermost last.
+* 2 the gas used is the gas of the transaction minus the gas left to the frames running:
+*   a caller keeps what it did not pass to its callee.
+* 3 the reports are posted in the background like the watchpoint alerts, WaitAlerts
+*   waits for them too. The fuzzer gives up on a pathological candidate from them
+*   instead of waiting for its final report, e.g. by lowering its step budget.
+ */
+package vm
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerProgressURL is where the fuzzer listens for the progress reports.
+var hackerProgressURL = "http://localhost:3000/progress"
+
+// SetProgressURL changes where the progress reports are sent.
+func SetProgressURL(url string) {
+	hackerProgressURL = url
+}
+
+// HackerProgressFrame is a frame running when a progress report was taken.
+type HackerProgressFrame struct {
+	Frame   int            `json:"frame"`
+	Depth   int            `json:"depth"`
+	Kind    string         `json:"kind"`
+	Address common.Address `json:"address"`
+	// Gas is the gas left to the frame
+	Gas uint64 `json:"gas"`
+}
+
+// HackerProgress is a partial report of a watched transaction.
+type HackerProgress struct {
+	Hash    common.Hash           `json:"hash"`
+	Steps   uint64                `json:"steps"`
+	GasUsed uint64                `json:"gasUsed"`
+	Pc      uint64                `json:"pc"`
+	Frames  []HackerProgressFrame `json:"frames"`
+}
+
+// SetProgressThreshold sends a progress report of the transactions watched from now on
+// every steps operations, zero sends none.
+func (dog *WatchDog) SetProgressThreshold(steps uint64) {
+	atomic.StoreUint64(&dog.progressEvery, steps)
+}
+
+// countProgress counts the operation at pc of the watched transaction, reporting the
+// progress every threshold operations.
+func (dog *WatchDog) countProgress(pc uint64) {
+	dog.executed++
+	every := atomic.LoadUint64(&dog.progressEvery)
+	if every == 0 || dog.executed%every != 0 || dog.taint == nil {
+		return
+	}
+	progress := &HackerProgress{Hash: dog.tx.Hash(), Steps: dog.executed, Pc: pc, Frames: make([]HackerProgressFrame, 0)}
+	left := uint64(0)
+	journal := dog.taint.journal
+	for _, index := range journal.open {
+		frame := &journal.frames[index]
+		progress.Frames = append(progress.Frames, HackerProgressFrame{Frame: index, Depth: frame.depth, Kind: frame.kind.String(), Address: frame.Address(), Gas: frame.contract.Gas})
+		left += frame.contract.Gas
+	}
+	if limit := dog.tx.Gas().Uint64(); left < limit {
+		progress.GasUsed = limit - left
+	}
+	url := hackerProgressURL
+	alertsPending.Add(1)
+	go func() {
+		defer alertsPending.Done()
+		if err := postReport(url, progress); err != nil {
+			log.Printf("Post Error! %v", err)
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+/**
+* @hacker_property.go
+* Property suites: the invariant contracts of Echidna and Foundry run against the node.
+* 1 a harness contract is registered with the signatures of its functions (ABI
+*   registry). The functions without argument named echidna_* (returning bool) or
+*   invariant_* (reverting on failure) are its properties.
+* 2 once the message call of a watched transaction returns, every property is called
+*   by a view call from the sender, the WatchDogs watching paused meanwhile like the
+*   token probes (hacker_profit.go).
+* 3 a property returning false or failing is a "property_violation" finding, the
+*   campaign counts the failures per property and keeps the first transaction after
+*   which it failed.
+ */
+package vm
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerPropertyGas is the gas of a property call.
+const hackerPropertyGas = 10000000
+
+var errNoProperty = errors.New("no echidna_ or invariant_ function without argument")
+
+// HackerProperty is a property function of a harness contract.
+type HackerProperty struct {
+	Harness   common.Address `json:"harness"`
+	Signature string         `json:"signature"`
+	Failures  uint64         `json:"failures"`
+	// FirstFailure is the first watched transaction after which the property failed
+	FirstFailure *common.Hash `json:"firstFailure,omitempty"`
+	selector     [4]byte
+}
+
+// isPropertyName tells whether signature is a property by the Echidna or Foundry
+// naming conventions.
+func isPropertyName(signature string) bool {
+	return (strings.HasPrefix(signature, "echidna_") || strings.HasPrefix(signature, "invariant_")) && strings.HasSuffix(signature, "()")
+}
+
+// RegisterProperties registers the signatures of the functions of harness and checks
+// its properties after the watched transactions, replacing the properties it had.
+func (c *HackerCampaign) RegisterProperties(harness common.Address, signatures []string) ([]HackerProperty, error) {
+	for _, signature := range signatures {
+		GetABIRegistry().Register(harness, signature)
+	}
+	properties := make([]*HackerProperty, 0)
+	for _, method := range GetABIRegistry().Methods(harness) {
+		if isPropertyName(method.Signature) {
+			properties = append(properties, &HackerProperty{Harness: harness, Signature: method.Signature, selector: method.Selector})
+		}
+	}
+	if len(properties) == 0 {
+		return nil, errNoProperty
+	}
+	sort.Slice(properties, func(i, j int) bool { return properties[i].Signature < properties[j].Signature })
+	c.lock.Lock()
+	c.properties[harness] = properties
+	c.lock.Unlock()
+	return c.harnessProperties(harness), nil
+}
+
+// UnregisterProperties stops checking the properties of harness.
+func (c *HackerCampaign) UnregisterProperties(harness common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.properties, harness)
+}
+
+// Properties returns the properties checked, by harness and signature.
+func (c *HackerCampaign) Properties() []HackerProperty {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	properties := make([]HackerProperty, 0)
+	for _, harness := range c.properties {
+		for _, property := range harness {
+			properties = append(properties, *property)
+		}
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		if properties[i].Harness != properties[j].Harness {
+			return properties[i].Harness.Hex() < properties[j].Harness.Hex()
+		}
+		return properties[i].Signature < properties[j].Signature
+	})
+	return properties
+}
+
+func (c *HackerCampaign) harnessProperties(harness common.Address) []HackerProperty {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	properties := make([]HackerProperty, 0, len(c.properties[harness]))
+	for _, property := range c.properties[harness] {
+		properties = append(properties, *property)
+	}
+	return properties
+}
+
+// countPropertyFailure counts a failure of the property of harness after tx.
+func (c *HackerCampaign) countPropertyFailure(harness common.Address, signature string, tx common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, property := range c.properties[harness] {
+		if property.Signature == signature {
+			property.Failures++
+			if property.FirstFailure == nil {
+				property.FirstFailure = &tx
+			}
+		}
+	}
+}
+
+// hackerPropertyFailure is a property which failed after the watched transaction.
+type hackerPropertyFailure struct {
+	property HackerProperty
+	// reason is "false" or the error of the call
+	reason string
+}
+
+// checkProperties calls the properties of the campaign in the state of evm, which is
+// left as is, and returns the failed ones.
+func checkProperties(evm *EVM) []hackerPropertyFailure {
+	properties := GetGlobalCampaign().Properties()
+	if len(properties) == 0 {
+		return nil
+	}
+	defer pauseWatching(evm)()
+	failures := make([]hackerPropertyFailure, 0)
+	for _, property := range properties {
+		snapshot := evm.snapshot()
+		ret, _, err := evm.StaticCall(AccountRef(evm.Origin), property.Harness, property.selector[:], hackerPropertyGas)
+		evm.revertToSnapshot(snapshot)
+		switch {
+		case err != nil:
+			failures = append(failures, hackerPropertyFailure{property, err.Error()})
+		case len(ret) >= 32 && common.BytesToHash(ret[:32]) == (common.Hash{}):
+			failures = append(failures, hackerPropertyFailure{property, "false"})
+		}
+	}
+	return failures
+}
+
+// checkPropertyFailures raises a finding per property failed after the watched
+// transaction.
+func (dog *WatchDog) checkPropertyFailures() {
+	for _, failure := range dog.properties {
+		finding := newHackerFinding("property_violation", "HackerProperty")
+		finding.Detail["harness"] = failure.property.Harness.Hex()
+		finding.Detail["property"] = failure.property.Signature
+		finding.Detail["reason"] = failure.reason
+		dog.EmitFinding(finding)
+		GetGlobalCampaign().countPropertyFailure(failure.property.Harness, failure.property.Signature, dog.tx.Hash())
+	}
+}
@@ -0,0 +1,106 @@
+/**
+* @hacker_proxy.go
+* Upgradeable targets.
+* A proxy holds no logic, its code only forwards to an implementation with DELEGATECALL.
+* When a watched transaction goes to a proxy the implementation is resolved from the
+* standard slots:
+*   EIP-1967 implementation slot, EIP-1967 beacon slot (the beacon's implementation()
+*   is called on a fork), EIP-1822 PROXIABLE slot.
+*   It is resolved on the copy of the state the findings are confirmed on, not resolved
+*   when the state cannot be copied.
+* The ABI registry then falls back to the methods registered for the implementation,
+* the report carries the proxy ("proxy") and every finding is labelled with the proxy
+* and the implementation code hash, which is what coverage is keyed by.
+ */
+package vm
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	ProxyEIP1967       = "eip1967"
+	ProxyEIP1967Beacon = "eip1967-beacon"
+	ProxyEIP1822       = "eip1822"
+)
+
+var (
+	// keccak256("eip1967.proxy.implementation") - 1
+	eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	// keccak256("eip1967.proxy.beacon") - 1
+	eip1967BeaconSlot = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+	// keccak256("PROXIABLE")
+	eip1822ProxiableSlot = common.HexToHash("0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7")
+
+	beaconImplementation = selectorOf("implementation()")
+)
+
+// hackerBeaconGas is the gas given to the implementation() call of a beacon.
+const hackerBeaconGas = 100000
+
+// HackerProxy is a proxy resolved to its implementation.
+type HackerProxy struct {
+	Address        common.Address `json:"address"`
+	Standard       string         `json:"standard"`
+	Implementation common.Address `json:"implementation"`
+	CodeHash       common.Hash    `json:"codeHash"`
+}
+
+var (
+	proxiesLock sync.RWMutex
+	// proxies maps the proxies resolved so far to their implementation
+	proxies = make(map[common.Address]common.Address)
+)
+
+// ResolveProxy returns the implementation behind addr, nil if addr is not a proxy.
+func ResolveProxy(env *EVM, addr common.Address) *HackerProxy {
+	statedb := env.StateDB
+	proxy := &HackerProxy{Address: addr}
+	if slot := statedb.GetState(addr, eip1967ImplementationSlot); slot != (common.Hash{}) {
+		proxy.Standard, proxy.Implementation = ProxyEIP1967, common.BytesToAddress(slot.Bytes())
+	} else if slot := statedb.GetState(addr, eip1967BeaconSlot); slot != (common.Hash{}) {
+		beacon := common.BytesToAddress(slot.Bytes())
+		outcome := newHackerHarnessFrom(env).Execute(&HackerMessage{From: addr, To: &beacon, Gas: hackerBeaconGas, Data: beaconImplementation[:]})
+		if outcome.Failed() || len(outcome.Ret) < 32 {
+			return nil
+		}
+		proxy.Standard, proxy.Implementation = ProxyEIP1967Beacon, common.BytesToAddress(outcome.Ret[:32])
+	} else if slot := statedb.GetState(addr, eip1822ProxiableSlot); slot != (common.Hash{}) {
+		proxy.Standard, proxy.Implementation = ProxyEIP1822, common.BytesToAddress(slot.Bytes())
+	} else {
+		return nil
+	}
+	if statedb.GetCodeSize(proxy.Implementation) == 0 {
+		return nil
+	}
+	proxy.CodeHash = statedb.GetCodeHash(proxy.Implementation)
+	proxiesLock.Lock()
+	proxies[addr] = proxy.Implementation
+	proxiesLock.Unlock()
+	return proxy
+}
+
+// implementationOf returns the implementation last resolved for the proxy addr.
+func implementationOf(addr common.Address) (common.Address, bool) {
+	proxiesLock.RLock()
+	defer proxiesLock.RUnlock()
+	implementation, ok := proxies[addr]
+	return implementation, ok
+}
+
+// labelProxy attributes the findings of a transaction sent to a proxy to its implementation.
+func (dog *WatchDog) labelProxy() {
+	if dog.proxy == nil {
+		return
+	}
+	for i := range dog.findings {
+		if dog.findings[i].Detail == nil {
+			dog.findings[i].Detail = make(map[string]string)
+		}
+		dog.findings[i].Detail["proxy"] = dog.proxy.Address.Hex()
+		dog.findings[i].Detail["implementation"] = dog.proxy.Implementation.Hex()
+		dog.findings[i].Detail["codeHash"] = dog.proxy.CodeHash.Hex()
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// proxyStateDB holds one EIP-1967 proxy slot and gives code to every account.
+type proxyStateDB struct {
+	poolStateDB
+	proxy, implementation common.Address
+}
+
+func (db proxyStateDB) GetState(addr common.Address, slot common.Hash) common.Hash {
+	if addr == db.proxy && slot == eip1967ImplementationSlot {
+		return common.BytesToHash(db.implementation.Bytes())
+	}
+	return common.Hash{}
+}
+
+func (proxyStateDB) GetCodeSize(common.Address) int { return 1 }
+
+func (proxyStateDB) GetCodeHash(addr common.Address) common.Hash {
+	return common.BytesToHash(addr.Bytes())
+}
+
+func TestResolveProxy(t *testing.T) {
+	var (
+		proxy          = common.HexToAddress("0x0a")
+		implementation = common.HexToAddress("0x0b")
+		db             = proxyStateDB{proxy: proxy, implementation: implementation}
+		env            = NewEVM(Context{}, db, params.TestChainConfig, Config{})
+	)
+	if ResolveProxy(env, implementation) != nil {
+		t.Fatal("plain contract resolved as a proxy")
+	}
+	resolved := ResolveProxy(env, proxy)
+	if resolved == nil || resolved.Standard != ProxyEIP1967 || resolved.Implementation != implementation {
+		t.Fatalf("proxy not resolved: %+v", resolved)
+	}
+	method := GetABIRegistry().Register(implementation, "upgradeTo(address)")
+	if found, ok := GetABIRegistry().Lookup(proxy, method.Selector[:]); !ok || found != method {
+		t.Fatal("implementation methods not found through the proxy")
+	}
+}
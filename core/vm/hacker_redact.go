@@ -0,0 +1,78 @@
+/**
+* @hacker_redact.go
+* Redaction of the WatchDog reports before they leave the node, for campaigns against
+* contracts holding sensitive test data.
+* 1 DropMemory removes the memory of the machine state snapshots ("breakpoints").
+* 2 MaxInput cuts the calldata of the frames ("frames") to as many bytes, InputSize
+*   keeps the size of the calldata cut.
+* 3 the storage of the contracts of ExcludeStorage is left out: the storage sections
+*   ("storageWrites", "storage_old", "storage_new") of a transaction sent to one of
+*   them are empty, the snapshots taken in one of them carry no storage.
+* The rules apply from the next report on, the reports of the alerts and of the
+* progress carry none of these.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerRedaction holds the redaction rules of the reports.
+type HackerRedaction struct {
+	DropMemory bool `json:"dropMemory"`
+	// MaxInput is the number of calldata bytes kept per frame, zero keeps them all
+	MaxInput       int              `json:"maxInput"`
+	ExcludeStorage []common.Address `json:"excludeStorage"`
+}
+
+// SetRedaction sets the redaction rules of the reports, nil redacts nothing.
+func (c *HackerCampaign) SetRedaction(rules *HackerRedaction) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.redaction = rules
+}
+
+// Redaction returns the redaction rules of the reports, nil when there is none.
+func (c *HackerCampaign) Redaction() *HackerRedaction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.redaction
+}
+
+// redact applies the redaction rules of the campaign to the report of the watched
+// transaction.
+func (dog *WatchDog) redact(report map[string]interface{}) {
+	rules := GetGlobalCampaign().Redaction()
+	if rules == nil {
+		return
+	}
+	excluded := make(map[common.Address]bool, len(rules.ExcludeStorage))
+	for _, addr := range rules.ExcludeStorage {
+		excluded[addr] = true
+	}
+	if dog.tx != nil && dog.tx.To() != nil && excluded[*dog.tx.To()] {
+		report["storageWrites"] = []HackerStorageWrite{}
+		report["storage_old"] = map[common.Hash]common.Hash{}
+		report["storage_new"] = map[common.Hash]common.Hash{}
+	}
+	if hits, ok := report["breakpoints"].([]HackerMachineState); ok {
+		redacted := make([]HackerMachineState, len(hits))
+		for i, state := range hits {
+			if rules.DropMemory {
+				state.Memory = nil
+			}
+			if excluded[state.Address] {
+				state.Storage = nil
+			}
+			redacted[i] = state
+		}
+		report["breakpoints"] = redacted
+	}
+	if frames, ok := report["frames"].([]HackerReportFrame); ok && rules.MaxInput > 0 {
+		for i := range frames {
+			if size := len(frames[i].Input) / 2; size > rules.MaxInput {
+				frames[i].Input, frames[i].InputSize = frames[i].Input[:2*rules.MaxInput], size
+			}
+		}
+	}
+}
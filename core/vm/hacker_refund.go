@@ -0,0 +1,172 @@
+/**
+* @hacker_refund.go
+* Gas refunds of the watched transaction and the refund abuse oracle.
+* 1 the refunds are tracked per frame of the journal: the change of the refund counter
+*   of the state made by each SSTORE and SELFDESTRUCT, net of the refunds taken back
+*   (EIP-2200), and the slots cleared (non-zero to zero) with the refund of the clear.
+*   The refunds of a reverted frame are reverted with it, they stay in its entry.
+* 2 the report carries them ("refunds") when the transaction generated any, with the
+*   refund counter at the end and its fraction of the gas used before the refund.
+* 3 a transaction whose refund counter reaches the refund threshold of the campaign
+*   (SetRefundThreshold, a percentage of the gas used before the refund) is reported
+*   as a "refund_abuse" finding with the slots it cleared: the gas token pattern of
+*   storage written to be cleared for its refund.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// hackerDefaultRefundThreshold is the percentage of the gas reported by default,
+	// the cap of the refunds after London (EIP-3529)
+	hackerDefaultRefundThreshold = 20
+	// hackerRefundSlotsCap is the number of cleared slots listed in a finding
+	hackerRefundSlotsCap = 16
+)
+
+// HackerClearedSlot is a slot cleared by the watched transaction.
+type HackerClearedSlot struct {
+	// Frame is the index of the frame in the "frames" of the report
+	Frame   int            `json:"frame"`
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Refund  int64          `json:"refund"`
+}
+
+// HackerFrameRefund is the net refund generated by a frame.
+type HackerFrameRefund struct {
+	Index  int   `json:"index"`
+	Refund int64 `json:"refund"`
+}
+
+// HackerRefundReport is the "refunds" section of a report.
+type HackerRefundReport struct {
+	Counter uint64 `json:"counter"`
+	// Fraction is the fraction of the gas used before the refund the counter stands for
+	Fraction float64             `json:"fraction"`
+	Frames   []HackerFrameRefund `json:"frames"`
+	Cleared  []HackerClearedSlot `json:"cleared"`
+}
+
+// hackerRefunds tracks the refunds of a watched transaction.
+type hackerRefunds struct {
+	// seen is the refund counter when last read, started once it was read
+	seen    *big.Int
+	started bool
+	frames  map[int]int64
+	cleared []HackerClearedSlot
+}
+
+func newHackerRefunds() *hackerRefunds {
+	return &hackerRefunds{seen: new(big.Int), frames: make(map[int]int64)}
+}
+
+// hackerRefund returns the refund counter of statedb.
+func hackerRefund(statedb StateDB) *big.Int {
+	if refund := statedb.GetRefund(); refund != nil {
+		return refund
+	}
+	return new(big.Int)
+}
+
+// before runs before op executes in frame: the refunds of SSTORE and SELFDESTRUCT are
+// added to the counter by their gas, before they execute.
+func (refunds *hackerRefunds) before(op OpCode, frame int, contract *Contract, stack *Stack, statedb StateDB) {
+	switch {
+	case !refunds.started:
+		refunds.started = true
+		refunds.seen = new(big.Int).Set(hackerRefund(statedb))
+	case op == SSTORE || op == SELFDESTRUCT:
+		refund := hackerRefund(statedb)
+		delta := new(big.Int).Sub(refund, refunds.seen).Int64()
+		refunds.seen = new(big.Int).Set(refund)
+		if delta != 0 {
+			refunds.frames[frame] += delta
+		}
+		if op == SSTORE && stack.len() >= 2 && stack.Back(1).Sign() == 0 {
+			slot := common.BigToHash(stack.Back(0))
+			if statedb.GetState(contract.Address(), slot) != (common.Hash{}) {
+				refunds.cleared = append(refunds.cleared, HackerClearedSlot{Frame: frame, Address: contract.Address(), Slot: slot, Refund: delta})
+			}
+		}
+	}
+}
+
+// after runs after the call operation op returned: the refunds of a reverted callee
+// were taken back.
+func (refunds *hackerRefunds) after(op OpCode, statedb StateDB) {
+	if isCallOp(op) {
+		refunds.seen = new(big.Int).Set(hackerRefund(statedb))
+	}
+}
+
+// SetRefundThreshold reports the transactions whose refund counter reaches percent of
+// the gas they used before the refund, 0 reports none.
+func (c *HackerCampaign) SetRefundThreshold(percent uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.refundThreshold = percent
+}
+
+// RefundThreshold returns the refund threshold, a percentage of the gas.
+func (c *HackerCampaign) RefundThreshold() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.refundThreshold
+}
+
+// refundReport returns the refunds of the watched transaction, nil when it generated
+// none.
+func (dog *WatchDog) refundReport() *HackerRefundReport {
+	if dog.taint == nil {
+		return nil
+	}
+	refunds := dog.taint.refunds
+	if len(refunds.frames) == 0 && len(refunds.cleared) == 0 {
+		return nil
+	}
+	gas := dog.gasBreakdown()
+	report := &HackerRefundReport{Counter: gas.RefundCounter, Frames: make([]HackerFrameRefund, 0, len(refunds.frames)), Cleared: refunds.cleared}
+	if used := gas.Intrinsic + gas.Execution; used != 0 {
+		report.Fraction = float64(gas.RefundCounter) / float64(used)
+	}
+	for index, refund := range refunds.frames {
+		report.Frames = append(report.Frames, HackerFrameRefund{Index: index, Refund: refund})
+	}
+	sort.Slice(report.Frames, func(i, j int) bool { return report.Frames[i].Index < report.Frames[j].Index })
+	if report.Cleared == nil {
+		report.Cleared = []HackerClearedSlot{}
+	}
+	return report
+}
+
+// checkRefundAbuse reports the watched transaction when its refund counter reaches the
+// refund threshold.
+func (dog *WatchDog) checkRefundAbuse() {
+	threshold := GetGlobalCampaign().RefundThreshold()
+	report := dog.refundReport()
+	if threshold == 0 || report == nil || report.Counter == 0 || report.Fraction*100 < float64(threshold) {
+		return
+	}
+	finding := newHackerFinding("refund_abuse", "HackerRefund")
+	finding.Detail["refund"] = fmt.Sprintf("%d", report.Counter)
+	finding.Detail["fraction"] = fmt.Sprintf("%.2f", report.Fraction)
+	finding.Detail["threshold"] = fmt.Sprintf("%d", threshold)
+	finding.Detail["cleared"] = fmt.Sprintf("%d", len(report.Cleared))
+	slots := make([]string, 0, hackerRefundSlotsCap)
+	for _, cleared := range report.Cleared {
+		if len(slots) == hackerRefundSlotsCap {
+			break
+		}
+		slots = append(slots, cleared.Address.Hex()+":"+cleared.Slot.Hex())
+	}
+	finding.Detail["slots"] = strings.Join(slots, ",")
+	dog.EmitFinding(finding)
+}
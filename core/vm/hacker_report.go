@@ -0,0 +1,105 @@
+/**
+* @hacker_report.go
+* Sending WatchDog reports to the fuzzer.
+* 1 the report is encoded straight into the request body through a pipe, so a report of
+*   several hundred MB (trace, storage maps) is never held twice in memory. The body has
+*   no length and goes out with chunked transfer encoding.
+* 2 the format is chosen per sink: JSON, or MessagePack when the sink lists it in the
+*   Accept-Post header of its OPTIONS response (or when set with SetReportFormat).
+* 3 the report is redacted (hacker_redact.go), then cut to the size budget of the
+*   campaign (hacker_budget.go).
+* 4 the requests go through the transport installed with SetTransport
+*   (hacker_transport.go).
+ */
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hackerReportURL is where the fuzzer listens for WatchDog reports.
+var hackerReportURL = "http://localhost:3000/fuzz"
+
+const (
+	ReportJSON    = "application/json"
+	ReportMsgpack = "application/msgpack"
+)
+
+var (
+	reportFormatsLock sync.Mutex
+	reportFormats     = make(map[string]string)
+)
+
+// SetReportFormat fixes the format of the reports sent to url, skipping the negotiation.
+func SetReportFormat(url, format string) error {
+	if format != ReportJSON && format != ReportMsgpack {
+		return fmt.Errorf("unknown report format %q", format)
+	}
+	reportFormatsLock.Lock()
+	defer reportFormatsLock.Unlock()
+	reportFormats[url] = format
+	return nil
+}
+
+// SetReportURL changes where the WatchDogs send their reports, e.g. to a test sink.
+func SetReportURL(url string) {
+	hackerReportURL = url
+}
+
+// reportFormat returns the format of url, negotiating it on first use.
+func reportFormat(url string) string {
+	reportFormatsLock.Lock()
+	format, ok := reportFormats[url]
+	reportFormatsLock.Unlock()
+	if ok {
+		return format
+	}
+	format = ReportJSON
+	accepted, err := hackerAcceptPost(url)
+	if err != nil {
+		// unreachable sink, negotiate again next time
+		return format
+	}
+	if strings.Contains(accepted, ReportMsgpack) {
+		format = ReportMsgpack
+	}
+	reportFormatsLock.Lock()
+	reportFormats[url] = format
+	reportFormatsLock.Unlock()
+	return format
+}
+
+// postReport streams report to url in the format of the sink.
+func postReport(url string, report interface{}) error {
+	format := reportFormat(url)
+	reader, writer := io.Pipe()
+	go func() {
+		if format == ReportMsgpack {
+			writer.CloseWithError(encodeMsgpack(writer, report))
+		} else {
+			writer.CloseWithError(json.NewEncoder(writer).Encode(report))
+		}
+	}()
+	_, _, err := hackerPost(url, format, reader, 0)
+	// unblocks the encoder if the request failed before reading the whole body
+	reader.Close()
+	return err
+}
+
+// sendReport posts the report of the watched transaction to the fuzzer.
+func (dog *WatchDog) sendReport(report map[string]interface{}) {
+	dog.redact(report)
+	dog.fitBudget(report)
+	start := time.Now()
+	err := postReport(hackerReportURL, report)
+	GetGlobalCampaign().countReport(err, time.Since(start))
+	if err != nil {
+		log.Printf("Post Error! %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/fuzz/report"
+)
+
+func TestPostReportStreams(t *testing.T) {
+	var (
+		received map[string]interface{}
+		chunked  bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		// the request with an encoding error arrives truncated
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+	SetTransport(report.NewHTTP())
+	defer SetTransport(nil)
+
+	trace := strings.Repeat("0PUSH1", 1<<16)
+	if err := postReport(server.URL, map[string]interface{}{"trace": trace}); err != nil {
+		t.Fatal(err)
+	}
+	if !chunked {
+		t.Error("report not sent with chunked transfer encoding")
+	}
+	if received["trace"] != trace {
+		t.Fatal("report corrupted")
+	}
+	if err := postReport(server.URL, map[string]interface{}{"bad": make(chan int)}); err == nil {
+		t.Fatal("expected the encoding error")
+	}
+}
+
+func TestPostReportWithoutTransport(t *testing.T) {
+	SetTransport(nil)
+	if err := postReport("http://localhost:1", map[string]interface{}{}); err != errNoTransport {
+		t.Fatalf("got %v, want %v", err, errNoTransport)
+	}
+}
@@ -0,0 +1,80 @@
+/**
+* @hacker_returndata.go
+* Return data poisoning probe of the external calls of watched transactions.
+* 1 when enabled (SetReturnPoisoning), Watch() replays the transaction once per account
+*   its target calls and stub, with the code of that account replaced by the stub, the
+*   same way as the gas griefing probe (hacker_griefing.go). The stubs return:
+*   - "empty": nothing, as a token without return value,
+*   - "short": a single byte,
+*   - "dirty": a word with all its bits set, out of range of any narrower ABI type,
+*   - "oversized": 64KiB, as a return bomb copied in full by the caller.
+* 2 a target failing, or ending in another state than the original, trusted the length
+*   or the content of the return data: the replays are the "returnPoisoning" section of
+*   the report, those not ignored by the target "returndata_poisoning" findings.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var hackerPoisonStubs = []hackerCalleeStub{
+	// stop
+	{name: "empty", code: common.FromHex("0x00")},
+	// mstore8(0, 1) return(0, 1)
+	{name: "short", code: common.FromHex("0x600160005360016000f3")},
+	// mstore(0, not(0)) return(0, 32)
+	{name: "dirty", code: common.FromHex("0x60001960005260206000f3")},
+	// return(0, 0x10000)
+	{name: "oversized", code: common.FromHex("0x620100006000f3")},
+}
+
+// SetReturnPoisoning turns the return data poisoning probe of the watched transactions
+// on or off.
+func (c *HackerCampaign) SetReturnPoisoning(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.returnPoisoning = on
+}
+
+func (c *HackerCampaign) returnPoisoningProbe() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.returnPoisoning
+}
+
+// ReturnPoisoning executes msg as is, then once per account its target calls and
+// poisoning stub with the code of that account replaced by the stub, and compares the
+// outcomes.
+func (harness *HackerHarness) ReturnPoisoning(msg *HackerMessage) *HackerGriefingReport {
+	return harness.replaceCallees(msg, hackerPoisonStubs...)
+}
+
+func (dog *WatchDog) probeReturnPoisoning(env *EVM, tx *types.Transaction) {
+	dog.returnPoisoning = nil
+	if !GetGlobalCampaign().returnPoisoningProbe() || env == nil {
+		return
+	}
+	dog.returnPoisoning = newHackerHarnessFrom(env).ReturnPoisoning(hackerTxMessage(env, tx))
+}
+
+// checkReturnPoisoning raises a finding per callee and stub whose return data changed
+// the outcome of the watched transaction.
+func (dog *WatchDog) checkReturnPoisoning() {
+	if dog.returnPoisoning == nil {
+		return
+	}
+	for _, call := range dog.returnPoisoning.Calls {
+		if call.Outcome == GriefingIgnored {
+			continue
+		}
+		finding := newHackerFinding("returndata_poisoning", "HackerReturnPoisoning")
+		finding.Detail["target"] = dog.returnPoisoning.Target.Hex()
+		finding.Detail["callee"] = call.Callee.Hex()
+		finding.Detail["op"] = call.Op
+		finding.Detail["stub"] = call.Stub
+		finding.Detail["outcome"] = call.Outcome
+		dog.EmitFinding(finding)
+	}
+}
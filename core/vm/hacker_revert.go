@@ -0,0 +1,152 @@
+/**
+* @hacker_revert.go
+* Revert-state inspection: how close a reverted transaction came to changing the state.
+* 1 when the capture is on (SetRevertCapture), the watched transaction records the
+*   storage slots written by any contract and the balances moved by value calls and
+*   self-destructs, with their value before the transaction.
+* 2 when a frame fails, before the EVM rolls its changes back, the state of those slots
+*   and balances is compared with the values before: the capture of the deepest failed
+*   frame is kept, the last one of that depth.
+* 3 the transaction reverted in the end: its report gets the capture as "revertState",
+*   the diff that would have been applied had nothing reverted up to that frame.
+* The balances of the accounts created with value are not followed.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerRevertSlot is a storage slot changed before the rollback.
+type HackerRevertSlot struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Old     common.Hash    `json:"old"`
+	New     common.Hash    `json:"new"`
+}
+
+// HackerRevertBalance is a balance changed before the rollback.
+type HackerRevertBalance struct {
+	Address common.Address `json:"address"`
+	Old     string         `json:"old"`
+	New     string         `json:"new"`
+}
+
+// HackerRevertState is the "revertState" section of the report: the state diff as of
+// the end of the deepest failed frame, before its rollback.
+type HackerRevertState struct {
+	Frame    int                   `json:"frame"`
+	Depth    int                   `json:"depth"`
+	Address  common.Address        `json:"address"`
+	Error    string                `json:"error"`
+	Reason   string                `json:"reason,omitempty"`
+	Storage  []HackerRevertSlot    `json:"storage"`
+	Balances []HackerRevertBalance `json:"balances"`
+}
+
+// hackerRevertCapture records the values before the transaction of what it changes.
+type hackerRevertCapture struct {
+	slots        map[hackerSlotKey]common.Hash
+	slotOrder    []hackerSlotKey
+	balances     map[common.Address]*big.Int
+	balanceOrder []common.Address
+	state        *HackerRevertState
+}
+
+// SetRevertCapture turns the revert-state inspection of the watched transactions on or
+// off.
+func (c *HackerCampaign) SetRevertCapture(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.revertCapture = on
+}
+
+// newRevertCapture returns the capture of a transaction of origin to target, nil when
+// the inspection is off.
+func (c *HackerCampaign) newRevertCapture(statedb StateDB, origin, target common.Address) *hackerRevertCapture {
+	c.lock.Lock()
+	on := c.revertCapture
+	c.lock.Unlock()
+	if !on {
+		return nil
+	}
+	capture := &hackerRevertCapture{slots: make(map[hackerSlotKey]common.Hash), balances: make(map[common.Address]*big.Int)}
+	capture.touchBalance(statedb, origin)
+	capture.touchBalance(statedb, target)
+	return capture
+}
+
+func (capture *hackerRevertCapture) touchSlot(statedb StateDB, addr common.Address, slot common.Hash) {
+	key := hackerSlotKey{addr, slot}
+	if _, ok := capture.slots[key]; !ok {
+		capture.slots[key] = statedb.GetState(addr, slot)
+		capture.slotOrder = append(capture.slotOrder, key)
+	}
+}
+
+func (capture *hackerRevertCapture) touchBalance(statedb StateDB, addr common.Address) {
+	if _, ok := capture.balances[addr]; !ok {
+		capture.balances[addr] = new(big.Int).Set(statedb.GetBalance(addr))
+		capture.balanceOrder = append(capture.balanceOrder, addr)
+	}
+}
+
+// record runs before op executes in contract.
+func (capture *hackerRevertCapture) record(statedb StateDB, op OpCode, contract *Contract, stack *Stack) {
+	switch {
+	case op == SSTORE && stack.len() >= 1:
+		capture.touchSlot(statedb, contract.Address(), common.BigToHash(stack.Back(0)))
+	case (op == CALL || op == CALLCODE) && stack.len() >= 3 && stack.Back(2).Sign() != 0:
+		capture.touchBalance(statedb, contract.Address())
+		capture.touchBalance(statedb, common.BigToAddress(stack.Back(1)))
+	case op == SELFDESTRUCT && stack.len() >= 1:
+		capture.touchBalance(statedb, contract.Address())
+		capture.touchBalance(statedb, common.BigToAddress(stack.Back(0)))
+	}
+}
+
+// fail takes the state at the end of the failed frame of contract, before its
+// rollback, when it is at least as deep as the deepest one so far.
+func (capture *hackerRevertCapture) fail(statedb StateDB, journal *hackerFrameJournal, contract *Contract) {
+	top := journal.top()
+	if top < 0 || journal.frames[top].contract != contract {
+		return
+	}
+	frame := &journal.frames[top]
+	if capture.state != nil && frame.depth < capture.state.Depth {
+		return
+	}
+	state := &HackerRevertState{Frame: top, Depth: frame.depth, Address: frame.Address(), Error: frame.errKind.String(), Reason: frame.reason, Storage: make([]HackerRevertSlot, 0), Balances: make([]HackerRevertBalance, 0)}
+	for _, key := range capture.slotOrder {
+		if value := statedb.GetState(key.address, key.slot); value != capture.slots[key] {
+			state.Storage = append(state.Storage, HackerRevertSlot{Address: key.address, Slot: key.slot, Old: capture.slots[key], New: value})
+		}
+	}
+	for _, addr := range capture.balanceOrder {
+		if balance := statedb.GetBalance(addr); balance.Cmp(capture.balances[addr]) != 0 {
+			state.Balances = append(state.Balances, HackerRevertBalance{Address: addr, Old: capture.balances[addr].Text(10), New: balance.Text(10)})
+		}
+	}
+	capture.state = state
+}
+
+// reverted tells whether the frame of the transaction failed.
+func (journal *hackerFrameJournal) reverted() bool {
+	for _, frame := range journal.frames {
+		if frame.parent < 0 && frame.failed {
+			return true
+		}
+	}
+	return false
+}
+
+// revertState returns the "revertState" section of the watched transaction, nil when
+// it did not revert or the inspection is off.
+func (dog *WatchDog) revertState() *HackerRevertState {
+	if dog.revert == nil || dog.revert.state == nil || dog.taint == nil || !dog.taint.journal.reverted() {
+		return nil
+	}
+	return dog.revert.state
+}
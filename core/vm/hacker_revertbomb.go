@@ -0,0 +1,64 @@
+/**
+* @hacker_revertbomb.go
+* Revert bomb probe of the external calls of watched transactions.
+* 1 when enabled (SetRevertBomb), Watch() replays the transaction once per account its
+*   target calls, with the code of that account replaced by a stub always reverting,
+*   the same way as the gas griefing probe (hacker_griefing.go).
+* 2 a callee whose revert makes the whole transaction fail blocks the target, e.g. a
+*   push payment to a recipient refusing it: the replays are the "revertBomb" section
+*   of the report, the blocking callees "revert_bomb" findings.
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// hackerRevertStub is the stub installed at the callees: revert(0, 0).
+var hackerRevertStub = common.FromHex("0x60006000fd")
+
+// SetRevertBomb turns the revert bomb probe of the watched transactions on or off.
+func (c *HackerCampaign) SetRevertBomb(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.revertBomb = on
+}
+
+func (c *HackerCampaign) revertBombProbe() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.revertBomb
+}
+
+// RevertBomb executes msg as is, then once per account its target calls with the code
+// of that account replaced by a stub always reverting, and compares the outcomes.
+func (harness *HackerHarness) RevertBomb(msg *HackerMessage) *HackerGriefingReport {
+	return harness.replaceCallees(msg, hackerCalleeStub{code: hackerRevertStub})
+}
+
+func (dog *WatchDog) probeRevertBomb(env *EVM, tx *types.Transaction) {
+	dog.revertBomb = nil
+	if !GetGlobalCampaign().revertBombProbe() || env == nil {
+		return
+	}
+	dog.revertBomb = newHackerHarnessFrom(env).RevertBomb(hackerTxMessage(env, tx))
+}
+
+// checkRevertBomb raises a finding per callee blocking the watched transaction when it
+// reverts.
+func (dog *WatchDog) checkRevertBomb() {
+	if dog.revertBomb == nil {
+		return
+	}
+	for _, call := range dog.revertBomb.Calls {
+		if call.Outcome != GriefingReverted {
+			continue
+		}
+		finding := newHackerFinding("revert_bomb", "HackerRevertBomb")
+		finding.Detail["target"] = dog.revertBomb.Target.Hex()
+		finding.Detail["callee"] = call.Callee.Hex()
+		finding.Detail["op"] = call.Op
+		dog.EmitFinding(finding)
+	}
+}
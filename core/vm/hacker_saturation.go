@@ -0,0 +1,141 @@
+/**
+* @hacker_saturation.go
+* Compare-and-prune: the targets whose coverage stopped growing are instrumented less.
+* 1 after every watched transaction the campaign compares the new coverage edges
+*   (hacker_coverage.go) with zero; a target whose transactions found no new edge for
+*   SetSaturation executions in a row is saturated.
+* 2 a saturated target is downgraded to calls-only instrumentation: in its frames only
+*   the call operations are recorded (trace, taint, oracles), the frames themselves are
+*   still tracked. The overhead goes to the targets still being explored.
+* 3 the fuzzer is told with an alert of type "saturated" (Old and New are the levels)
+*   and restores the full instrumentation with SetInstrumentation, e.g. after a
+*   mutation strategy change.
+* The levels apply from the next watched transaction on.
+ */
+package vm
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerDefaultSaturation is the number of executions without new coverage after which
+// a target is saturated.
+const hackerDefaultSaturation = 1000
+
+// HackerInstrumentation is the instrumentation level of a target.
+type HackerInstrumentation int
+
+const (
+	HackerInstrumentFull HackerInstrumentation = iota
+	HackerInstrumentCalls
+)
+
+var hackerInstrumentationNames = map[HackerInstrumentation]string{
+	HackerInstrumentFull:  "full",
+	HackerInstrumentCalls: "calls",
+}
+
+func (level HackerInstrumentation) String() string {
+	return hackerInstrumentationNames[level]
+}
+
+// ParseHackerInstrumentation returns the level named name.
+func ParseHackerInstrumentation(name string) (HackerInstrumentation, error) {
+	for level, levelName := range hackerInstrumentationNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return HackerInstrumentFull, fmt.Errorf("unknown instrumentation level %q", name)
+}
+
+// SetSaturation sets the number of executions without new coverage after which a
+// target is downgraded, zero never downgrades.
+func (c *HackerCampaign) SetSaturation(executions uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.saturation = executions
+}
+
+// SetInstrumentation sets the instrumentation level of target, its count of executions
+// without new coverage starts over.
+func (c *HackerCampaign) SetInstrumentation(target common.Address, level HackerInstrumentation) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.stale, target)
+	if level == HackerInstrumentFull {
+		delete(c.reduced, target)
+	} else {
+		c.reduced[target] = true
+	}
+}
+
+// Instrumentation returns the instrumentation level of target.
+func (c *HackerCampaign) Instrumentation(target common.Address) HackerInstrumentation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.reduced[target] {
+		return HackerInstrumentCalls
+	}
+	return HackerInstrumentFull
+}
+
+// reducedTargets returns the targets instrumented calls-only, nil when there is none.
+func (c *HackerCampaign) reducedTargets() map[common.Address]bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.reduced) == 0 {
+		return nil
+	}
+	reduced := make(map[common.Address]bool, len(c.reduced))
+	for target := range c.reduced {
+		reduced[target] = true
+	}
+	return reduced
+}
+
+// observeCoverage counts an execution of target that found added new edges and tells
+// whether it saturated the target.
+func (c *HackerCampaign) observeCoverage(target common.Address, added int) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if added != 0 || c.reduced[target] {
+		delete(c.stale, target)
+		return false
+	}
+	c.stale[target]++
+	if c.saturation == 0 || c.stale[target] < c.saturation {
+		return false
+	}
+	delete(c.stale, target)
+	c.reduced[target] = true
+	return true
+}
+
+// instrumented tells whether op running in contract is recorded, the operations of the
+// blocked contracts (hacker_blocklist.go) are counted instead. The calls of the
+// transactions the policy (hacker_policy.go) watches calls-only are recorded.
+func (dog *WatchDog) instrumented(op OpCode, contract *Contract) bool {
+	if dog.isBlocked(contract) {
+		return false
+	}
+	if isCallOp(op) {
+		return true
+	}
+	return !dog.callsOnly && (dog.reduced == nil || !dog.reduced[contract.Address()])
+}
+
+// checkSaturation downgrades the target of the watched transaction when it is
+// saturated, and tells the fuzzer.
+func (dog *WatchDog) checkSaturation() {
+	target := *dog.tx.To()
+	if !GetGlobalCampaign().observeCoverage(target, dog.coverage.New) {
+		return
+	}
+	alert := newHackerAlert("saturated", dog.env, HackerAlertFrame{Caller: dog.env.Origin, Address: target, Selector: hackerSelectorKey(dog.tx.Data())})
+	alert.Address = target
+	alert.Old, alert.New = HackerInstrumentFull.String(), HackerInstrumentCalls.String()
+	sendAlert(alert)
+}
@@ -0,0 +1,227 @@
+/**
+* @hacker_schedule.go
+* Adaptive scheduling of the detectors run at every operation of the watched
+* transactions (weak randomness, calldata length, CEI, ERC20 events, unbounded loops,
+* signature inputs).
+* 1 the cost of each detector is measured on one operation of hackerDetectorSampleEvery
+*   and extrapolated to all of them, its average per transaction is kept by the campaign
+*   with the latency of the watched transactions (from Watch to End), as moving
+*   averages.
+* 2 with a latency budget set (SetDetectorBudget), every hackerScheduleEvery watched
+*   transactions: over budget, the most expensive detector still running is degraded,
+*   from active to sampled (run by one transaction of hackerDetectorSampling) and from
+*   sampled to disabled; under half the budget, the cheapest degraded one is restored
+*   a step.
+* 3 the reports carry the detectors active for their transaction ("detectors") while a
+*   budget is set, the findings of a transaction stay interpretable.
+ */
+package vm
+
+import (
+	"time"
+)
+
+const (
+	hackerDetectorWeakRandomness = iota
+	hackerDetectorCalldataLength
+	hackerDetectorCEI
+	hackerDetectorERC20
+	hackerDetectorUnboundedLoop
+	hackerDetectorSignature
+	hackerDetectorCount
+)
+
+var hackerDetectorNames = [hackerDetectorCount]string{"weak_randomness", "calldata_length", "cei", "erc20", "unbounded_loop", "signature"}
+
+const (
+	DetectorActive   = "active"
+	DetectorSampled  = "sampled"
+	DetectorDisabled = "disabled"
+)
+
+var hackerDetectorStates = []string{DetectorActive, DetectorSampled, DetectorDisabled}
+
+const (
+	// hackerDetectorSampleEvery is the number of operations per timed operation
+	hackerDetectorSampleEvery = 64
+	// hackerDetectorSampling is the number of transactions per run of a sampled detector
+	hackerDetectorSampling = 4
+	// hackerScheduleEvery is the number of watched transactions between two adaptations
+	hackerScheduleEvery = 8
+	// hackerScheduleWeight is the weight of the last transaction in the moving averages
+	hackerScheduleWeight = 0.2
+)
+
+// hackerDetectorSchedule is the schedule of the detectors of the campaign.
+type hackerDetectorSchedule struct {
+	budget  time.Duration
+	states  [hackerDetectorCount]int
+	costs   [hackerDetectorCount]float64
+	runs    [hackerDetectorCount]uint64
+	latency float64
+	watched uint64
+}
+
+// hackerDetectorRun is the schedule of the detectors for a watched transaction.
+type hackerDetectorRun struct {
+	active   [hackerDetectorCount]bool
+	adaptive bool
+	start    time.Time
+	// ops counts the operations, sampled the timed ones
+	ops     uint64
+	sampled uint64
+	timed   bool
+	costs   [hackerDetectorCount]time.Duration
+}
+
+// HackerDetectorStat is the cost and the state of a detector.
+type HackerDetectorStat struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Cost is the average cost per transaction, in nanoseconds
+	Cost uint64 `json:"cost"`
+	Runs uint64 `json:"runs"`
+}
+
+// SetDetectorBudget sets the latency budget of the watched transactions the detectors
+// are scheduled for, zero runs them all. The schedule starts over with all of them
+// active, the costs measured so far are kept.
+func (c *HackerCampaign) SetDetectorBudget(budget time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.detectors.budget = budget
+	c.detectors.states, c.detectors.watched = [hackerDetectorCount]int{}, 0
+}
+
+// Detectors returns the cost and the state of the detectors.
+func (c *HackerCampaign) Detectors() []HackerDetectorStat {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := make([]HackerDetectorStat, hackerDetectorCount)
+	for i := range stats {
+		stats[i] = HackerDetectorStat{Name: hackerDetectorNames[i], State: hackerDetectorStates[c.detectors.states[i]], Cost: uint64(c.detectors.costs[i]), Runs: c.detectors.runs[i]}
+	}
+	return stats
+}
+
+// scheduleDetectors returns the detectors run by the next watched transaction.
+func (c *HackerCampaign) scheduleDetectors() *hackerDetectorRun {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schedule := &c.detectors
+	run := &hackerDetectorRun{adaptive: schedule.budget != 0, start: time.Now()}
+	for i, state := range schedule.states {
+		run.active[i] = state == 0 || (state == 1 && schedule.watched%hackerDetectorSampling == 0)
+	}
+	schedule.watched++
+	return run
+}
+
+// endDetectors records the costs of run and adapts the schedule to the latency.
+func (c *HackerCampaign) endDetectors(run *hackerDetectorRun) {
+	latency := float64(time.Since(run.start))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schedule := &c.detectors
+	for i, active := range run.active {
+		if !active {
+			continue
+		}
+		cost := 0.0
+		if run.sampled != 0 {
+			cost = float64(run.costs[i]) * float64(run.ops) / float64(run.sampled)
+		}
+		if schedule.runs[i] == 0 {
+			schedule.costs[i] = cost
+		} else {
+			schedule.costs[i] += hackerScheduleWeight * (cost - schedule.costs[i])
+		}
+		schedule.runs[i]++
+	}
+	if schedule.latency == 0 {
+		schedule.latency = latency
+	} else {
+		schedule.latency += hackerScheduleWeight * (latency - schedule.latency)
+	}
+	if schedule.budget != 0 && schedule.watched%hackerScheduleEvery == 0 {
+		schedule.adapt()
+	}
+}
+
+// adapt degrades the most expensive detector over budget, restores the cheapest
+// degraded one under half of it.
+func (schedule *hackerDetectorSchedule) adapt() {
+	budget := float64(schedule.budget)
+	pick := -1
+	switch {
+	case schedule.latency > budget:
+		for i, state := range schedule.states {
+			if state < 2 && (pick < 0 || schedule.costs[i] > schedule.costs[pick]) {
+				pick = i
+			}
+		}
+		if pick >= 0 {
+			schedule.states[pick]++
+		}
+	case schedule.latency < budget/2:
+		for i, state := range schedule.states {
+			if state > 0 && (pick < 0 || schedule.costs[i] < schedule.costs[pick]) {
+				pick = i
+			}
+		}
+		if pick >= 0 {
+			schedule.states[pick]--
+		}
+	}
+}
+
+// detect runs check, the detector of the watched transaction, when it is scheduled,
+// timing it on the sampled operations.
+func (dog *WatchDog) detect(detector int, check func()) {
+	run := dog.detectors
+	if run == nil {
+		check()
+		return
+	}
+	if !run.active[detector] {
+		return
+	}
+	if !run.timed {
+		check()
+		return
+	}
+	start := time.Now()
+	check()
+	run.costs[detector] += time.Since(start)
+}
+
+// tickDetectors counts an operation of the watched transaction.
+func (dog *WatchDog) tickDetectors() {
+	if run := dog.detectors; run != nil {
+		if run.timed = run.ops%hackerDetectorSampleEvery == 0; run.timed {
+			run.sampled++
+		}
+		run.ops++
+	}
+}
+
+// activeDetectors returns the names of the detectors run by the watched transaction,
+// nil when they are not scheduled.
+func (dog *WatchDog) activeDetectors() []string {
+	if dog.detectors == nil || !dog.detectors.adaptive {
+		return nil
+	}
+	names := make([]string, 0, hackerDetectorCount)
+	for i, active := range dog.detectors.active {
+		if active {
+			names = append(names, hackerDetectorNames[i])
+		}
+	}
+	return names
+}
+
+func (dog *WatchDog) recordDetectors() {
+	if dog.detectors != nil {
+		GetGlobalCampaign().endDetectors(dog.detectors)
+	}
+}
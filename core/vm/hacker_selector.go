@@ -0,0 +1,83 @@
+/**
+* @hacker_selector.go
+* Selector statistics for mutation scheduling.
+* 1 the campaign counts the executions of every selector of every target (the
+*   destination of the watched transaction), the calls with less than 4 bytes of input
+*   are counted under the empty selector.
+* 2 the rarity of a selector is 1/count: 1 the first time it runs, lower the more it
+*   runs. The report carries the selector executed with its count and rarity
+*   ("selector"), fuzz_selectorStats lists the selectors of a target rarest first so
+*   that the fuzzer biases its mutations toward the functions seldom exercised.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HackerSelectorStat is the execution count of a selector of a target.
+type HackerSelectorStat struct {
+	Selector string  `json:"selector"`
+	Count    uint64  `json:"count"`
+	Rarity   float64 `json:"rarity"`
+	// Implementation is the code the selector of the watched transaction is forwarded
+	// to, whose statistics these are (hacker_forward.go)
+	Implementation *common.Address `json:"implementation,omitempty"`
+}
+
+func newHackerSelectorStat(selector string, count uint64) HackerSelectorStat {
+	return HackerSelectorStat{Selector: selector, Count: count, Rarity: 1 / float64(count)}
+}
+
+// hackerSelectorKey returns the selector of input as counted, empty without one.
+func hackerSelectorKey(input []byte) string {
+	if len(input) < 4 {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(input[:4])
+}
+
+// recordSelector counts an execution of input by target and returns its statistics.
+func (c *HackerCampaign) recordSelector(target common.Address, input []byte) HackerSelectorStat {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	counts, ok := c.selectors[target]
+	if !ok {
+		counts = make(map[string]uint64)
+		c.selectors[target] = counts
+	}
+	selector := hackerSelectorKey(input)
+	counts[selector]++
+	return newHackerSelectorStat(selector, counts[selector])
+}
+
+// SelectorStats returns the selectors executed on target, rarest first.
+func (c *HackerCampaign) SelectorStats(target common.Address) []HackerSelectorStat {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := make([]HackerSelectorStat, 0, len(c.selectors[target]))
+	for selector, count := range c.selectors[target] {
+		stats = append(stats, newHackerSelectorStat(selector, count))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count < stats[j].Count
+		}
+		return stats[i].Selector < stats[j].Selector
+	})
+	return stats
+}
+
+// recordSelector counts the selector of the watched transaction, for the report, and
+// under the implementation its calldata is forwarded to.
+func (dog *WatchDog) recordSelector() {
+	stat := GetGlobalCampaign().recordSelector(*dog.tx.To(), dog.tx.Data())
+	if implementation, ok := dog.forwardedTo(); ok && implementation != *dog.tx.To() {
+		stat = GetGlobalCampaign().recordSelector(implementation, dog.tx.Data())
+		stat.Implementation = &implementation
+	}
+	dog.selector = &stat
+}
@@ -0,0 +1,222 @@
+/**
+* @hacker_shutdown.go
+* Flushing the campaign when the node stops.
+* 1 Shutdown resumes the executions paused by the debugger (hacker_debugger.go),
+*   abandons the transactions still watched, waits (up to a timeout) for the
+*   watchpoint alerts still in flight and saves the campaign to a file.
+* 2 the campaign file holds the session accounting, the call graph, the coverage bitmaps
+*   (hacker_coverage.go), the corpus (hacker_coordinator.go) and, per target, the code
+*   hash and dictionary (the corpus index) with the executions of its selectors. Load
+*   adds a saved campaign to the current one, so that a campaign resumes where the
+*   previous process left it.
+* 3 the campaign is synchronised a last time with the coordinator, if one was started.
+* 4 a summary of the session, with the audit log of the campaign (hacker_audit.go), is
+*   logged and sent to the summary URL.
+ */
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerSummaryURL is where the fuzzer listens for the session summary.
+var hackerSummaryURL = "http://localhost:3000/summary"
+
+// SetSummaryURL changes where the session summary is sent.
+func SetSummaryURL(url string) {
+	hackerSummaryURL = url
+}
+
+// HackerCampaignTarget is a target of a saved campaign.
+type HackerCampaignTarget struct {
+	Address    common.Address          `json:"address"`
+	CodeHash   common.Hash             `json:"codeHash"`
+	Dictionary []HackerDictionaryEntry `json:"dictionary"`
+	// Selectors counts the executions of the target per selector
+	Selectors map[string]uint64 `json:"selectors"`
+}
+
+// HackerCampaignState is a campaign as saved to a file.
+type HackerCampaignState struct {
+	Session   HackerSession          `json:"session"`
+	CallGraph []HackerCallEdge       `json:"callGraph"`
+	Targets   []HackerCampaignTarget `json:"targets"`
+	Coverage  []HackerCoverageMap    `json:"coverage"`
+	Corpus    []HackerCorpusEntry    `json:"corpus"`
+}
+
+// State returns what the campaign recorded, the targets sorted by address.
+func (c *HackerCampaign) State() *HackerCampaignState {
+	state := &HackerCampaignState{Session: c.Session(), CallGraph: c.CallGraph(), Targets: make([]HackerCampaignTarget, 0), Coverage: c.Coverage(), Corpus: c.Corpus(common.Address{})}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	targets := make(map[common.Address]*HackerCampaignTarget)
+	target := func(addr common.Address) *HackerCampaignTarget {
+		if _, ok := targets[addr]; !ok {
+			targets[addr] = &HackerCampaignTarget{Address: addr, Dictionary: make([]HackerDictionaryEntry, 0), Selectors: make(map[string]uint64)}
+		}
+		return targets[addr]
+	}
+	for addr, dictionary := range c.dictionaries {
+		target(addr).CodeHash = dictionary.codeHash
+		target(addr).Dictionary = append(target(addr).Dictionary, dictionary.entries...)
+	}
+	for addr, counts := range c.selectors {
+		for selector, count := range counts {
+			target(addr).Selectors[selector] = count
+		}
+	}
+	for _, target := range targets {
+		state.Targets = append(state.Targets, *target)
+	}
+	sort.Slice(state.Targets, func(i, j int) bool {
+		return bytes.Compare(state.Targets[i].Address[:], state.Targets[j].Address[:]) < 0
+	})
+	return state
+}
+
+// Restore adds the counts of state to the campaign. The dictionaries of state replace
+// the ones of the same targets.
+func (c *HackerCampaign) Restore(state *HackerCampaignState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.session.Blocks += state.Session.Blocks
+	c.session.Transactions += state.Session.Transactions
+	c.session.Watched += state.Session.Watched
+	c.session.Reported += state.Session.Reported
+	c.session.Findings += state.Session.Findings
+	if state.Session.LastBlock > c.session.LastBlock {
+		c.session.LastBlock = state.Session.LastBlock
+	}
+	c.restoreCoverage(state.Coverage)
+	for _, entry := range state.Corpus {
+		c.addCorpus(entry)
+	}
+	for _, edge := range state.CallGraph {
+		c.callGraph[hackerCallEdge{from: edge.From, to: edge.To, selector: edge.Selector, kind: StringToOp(edge.Kind)}] += edge.Count
+	}
+	for _, target := range state.Targets {
+		if target.CodeHash != (common.Hash{}) {
+			c.dictionaries[target.Address] = &hackerDictionary{codeHash: target.CodeHash, entries: append([]HackerDictionaryEntry{}, target.Dictionary...)}
+		}
+		if len(target.Selectors) == 0 {
+			continue
+		}
+		counts, ok := c.selectors[target.Address]
+		if !ok {
+			counts = make(map[string]uint64)
+			c.selectors[target.Address] = counts
+		}
+		for selector, count := range target.Selectors {
+			counts[selector] += count
+		}
+	}
+}
+
+// Save writes the campaign to path, replacing the file once it is complete.
+func (c *HackerCampaign) Save(path string) error {
+	data, err := json.MarshalIndent(c.State(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Load adds the campaign saved to path, a missing file is an empty campaign.
+func (c *HackerCampaign) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := new(HackerCampaignState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("campaign %s: %v", path, err)
+	}
+	c.Restore(state)
+	return nil
+}
+
+// HackerShutdownSummary is the summary of the session sent at shutdown.
+type HackerShutdownSummary struct {
+	Session HackerSession `json:"session"`
+	Targets int           `json:"targets"`
+	// Edges is the number of edges of the call graph
+	Edges     int `json:"edges"`
+	Selectors int `json:"selectors"`
+	// Coverage is the number of edges covered
+	Coverage int `json:"coverage"`
+	// Abandoned counts the transactions still watched, they have no report
+	Abandoned int `json:"abandoned"`
+	// AlertsFlushed is unset when alerts were still in flight at the timeout
+	AlertsFlushed bool `json:"alertsFlushed"`
+	// Saved is the file the campaign was saved to, empty when it was not
+	Saved string `json:"saved,omitempty"`
+	// Audit is the audit log of the control operations
+	Audit []HackerAuditEntry `json:"audit"`
+}
+
+// Shutdown flushes the campaign when the node stops: the alerts in flight are given
+// timeout to be sent and the campaign is saved to path, unless it is empty. The
+// summary is returned with the error of the save.
+func Shutdown(path string, timeout time.Duration) (*HackerShutdownSummary, error) {
+	summary := new(HackerShutdownSummary)
+	ResumeAll()
+	for _, dog := range [2]*WatchDog{GetGlobalWatchDog(), GetGlobalTracerWatchDog()} {
+		summary.Abandoned += dog.Watching()
+		dog.abandon()
+	}
+	flushed := make(chan struct{})
+	go func() {
+		WaitAlerts()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		summary.AlertsFlushed = true
+	case <-time.After(timeout):
+	}
+
+	if coord := GetCoordinator(); coord != nil {
+		coord.stop()
+		if _, err := coord.Sync(); err != nil {
+			log.Printf("coordinator sync: %v", err)
+		}
+	}
+	c := GetGlobalCampaign()
+	state := c.State()
+	summary.Session, summary.Targets, summary.Edges = state.Session, len(state.Targets), len(state.CallGraph)
+	summary.Audit = c.AuditLog()
+	for _, target := range state.Targets {
+		summary.Selectors += len(target.Selectors)
+	}
+	for _, coverage := range state.Coverage {
+		summary.Coverage += coverage.Edges
+	}
+	var err error
+	if path != "" {
+		if err = c.Save(path); err == nil {
+			summary.Saved = path
+		}
+	}
+	log.Printf("campaign session: %d blocks, %d transactions watched, %d reported, %d findings, %d targets, %d abandoned",
+		summary.Session.Blocks, summary.Session.Watched, summary.Session.Reported, summary.Session.Findings, summary.Targets, summary.Abandoned)
+	if postErr := postReport(hackerSummaryURL, summary); postErr != nil {
+		log.Printf("Post Error! %v", postErr)
+	}
+	return summary, err
+}
@@ -0,0 +1,172 @@
+/**
+* @hacker_signature.go
+* Oracle: signature replay around ecrecover.
+* 1 every SHA3 of the watched execution is kept with its preimage (32 byte words and the
+*   taint of the hashed memory), every SLOAD with the slot the value came from, and every
+*   SSTORE.
+* 2 every successful call of the ecrecover precompile records the caller, the message
+*   hash and the recovered signer.
+* 3 at the end of the transaction the message hash is unfolded through the recorded
+*   preimages (nested hashes such as the "\x19Ethereum Signed Message" prefix included).
+*   The signature is bound to a nonce when one of the words was loaded from a slot that
+*   the transaction then increased, or to a chain when a word is the chain id. A
+*   signature bound to neither can be submitted again: "signature_replay" finding.
+ */
+package vm
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// hackerSignaturePreimages bounds the number of SHA3 preimages kept per transaction.
+	hackerSignaturePreimages = 4096
+	// hackerSignatureDepth bounds the unfolding of nested hashes.
+	hackerSignatureDepth = 4
+)
+
+var ecrecoverAddress = common.BytesToAddress([]byte{1})
+
+type hackerPreimage struct {
+	words []common.Hash
+	mask  uint
+}
+
+type hackerEcrecover struct {
+	caller common.Address
+	hash   common.Hash
+	signer common.Address
+}
+
+// hackerSignatures collects what the signature replay oracle needs during one transaction.
+type hackerSignatures struct {
+	preimages  map[common.Hash]*hackerPreimage
+	loaded     map[common.Hash][]hackerTaintStorageKey
+	stored     map[hackerTaintStorageKey][]common.Hash
+	ecrecovers []hackerEcrecover
+}
+
+func newHackerSignatures() *hackerSignatures {
+	return &hackerSignatures{
+		preimages: make(map[common.Hash]*hackerPreimage),
+		loaded:    make(map[common.Hash][]hackerTaintStorageKey),
+		stored:    make(map[hackerTaintStorageKey][]common.Hash),
+	}
+}
+
+// recordSignatureInputs runs after op executed successfully.
+func (taint *HackerTaint) recordSignatureInputs(step *hackerTaintStep, op OpCode, contract *Contract, memory *Memory, stack *Stack) {
+	sigs := taint.signatures
+	switch op {
+	case SHA3:
+		if len(sigs.preimages) >= hackerSignaturePreimages {
+			return
+		}
+		offset, size := step.arg(0).Int64(), step.arg(1).Int64()
+		data := memory.Get(offset, size)
+		preimage := &hackerPreimage{mask: taint.memory(memory).get(uint64(offset), uint64(size))}
+		for i := 0; i < len(data); i += 32 {
+			end := i + 32
+			if end > len(data) {
+				end = len(data)
+			}
+			preimage.words = append(preimage.words, common.BytesToHash(data[i:end]))
+		}
+		sigs.preimages[common.BigToHash(stack.peek())] = preimage
+	case SLOAD:
+		key := hackerTaintStorageKey{contract.Address(), common.BigToHash(step.arg(0))}
+		value := common.BigToHash(stack.peek())
+		sigs.loaded[value] = append(sigs.loaded[value], key)
+	case SSTORE:
+		key := hackerTaintStorageKey{contract.Address(), common.BigToHash(step.arg(0))}
+		sigs.stored[key] = append(sigs.stored[key], common.BigToHash(step.arg(1)))
+	}
+}
+
+// onEcrecover records a call of the ecrecover precompile by caller.
+func (taint *HackerTaint) onEcrecover(caller common.Address, input, output []byte) {
+	if len(output) != 32 {
+		return
+	}
+	signer := common.BytesToAddress(output)
+	if signer == (common.Address{}) {
+		return
+	}
+	padded := make([]byte, 32)
+	copy(padded, input)
+	taint.signatures.ecrecovers = append(taint.signatures.ecrecovers, hackerEcrecover{caller: caller, hash: common.BytesToHash(padded), signer: signer})
+}
+
+// increased tells whether word was loaded from a slot the transaction then raised.
+func (sigs *hackerSignatures) increased(word common.Hash) (hackerTaintStorageKey, bool) {
+	for _, key := range sigs.loaded[word] {
+		for _, value := range sigs.stored[key] {
+			if value.Big().Cmp(word.Big()) > 0 {
+				return key, true
+			}
+		}
+	}
+	return hackerTaintStorageKey{}, false
+}
+
+// components unfolds hash and returns the description of its leaves and whether
+// it is bound to a nonce or to a chain id.
+func (sigs *hackerSignatures) components(hash common.Hash, chainId *big.Int, depth int) (parts []string, bound bool) {
+	preimage, ok := sigs.preimages[hash]
+	if !ok || depth >= hackerSignatureDepth {
+		return []string{"opaque"}, false
+	}
+	for _, word := range preimage.words {
+		if _, nested := sigs.preimages[word]; nested {
+			nestedParts, nestedBound := sigs.components(word, chainId, depth+1)
+			parts = append(parts, nestedParts...)
+			bound = bound || nestedBound
+			continue
+		}
+		if key, ok := sigs.increased(word); ok {
+			parts = append(parts, "nonce:"+key.addr.Hex()+":"+key.slot.Hex())
+			bound = true
+			continue
+		}
+		if chainId != nil && chainId.Sign() > 0 && word.Big().Cmp(chainId) == 0 {
+			parts = append(parts, "chainId")
+			bound = true
+			continue
+		}
+		parts = append(parts, word.Hex())
+	}
+	if sources := taintSourceNames(preimage.mask); len(sources) > 0 {
+		parts = append(parts, "sources:"+strings.Join(sources, "|"))
+	}
+	return parts, bound
+}
+
+// checkSignatureReplay reports the ecrecover calls of the watched transaction whose
+// message hash is bound to neither a nonce nor a chain id.
+func (dog *WatchDog) checkSignatureReplay() {
+	if dog.taint == nil || dog.env == nil {
+		return
+	}
+	var chainId *big.Int
+	if dog.env.chainConfig != nil {
+		chainId = dog.env.chainConfig.ChainId
+	}
+	sigs := dog.taint.signatures
+	for _, recovered := range sigs.ecrecovers {
+		parts, bound := sigs.components(recovered.hash, chainId, 0)
+		key := "signature_replay:" + recovered.caller.Hex() + ":" + recovered.hash.Hex()
+		if bound || dog.taint.reported[key] {
+			continue
+		}
+		dog.taint.reported[key] = true
+		finding := newHackerFinding("signature_replay", "HackerSignatureReplay")
+		finding.Detail["contract"] = recovered.caller.Hex()
+		finding.Detail["signer"] = recovered.signer.Hex()
+		finding.Detail["messageHash"] = recovered.hash.Hex()
+		finding.Detail["components"] = strings.Join(parts, ",")
+		dog.EmitFinding(finding)
+	}
+}
@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSignatureComponents(t *testing.T) {
+	var (
+		sigs    = newHackerSignatures()
+		addr    = common.HexToAddress("0x0a")
+		slot    = common.HexToHash("0x03")
+		nonce   = common.BigToHash(big.NewInt(7))
+		inner   = common.HexToHash("0x1111")
+		outer   = common.HexToHash("0x2222")
+		chainId = big.NewInt(1)
+	)
+	sigs.preimages[inner] = &hackerPreimage{words: []common.Hash{common.HexToHash("0xbeef"), nonce}}
+	sigs.preimages[outer] = &hackerPreimage{words: []common.Hash{common.HexToHash("0x19"), inner}}
+	sigs.loaded[nonce] = []hackerTaintStorageKey{{addr, slot}}
+
+	if _, bound := sigs.components(outer, chainId, 0); bound {
+		t.Fatal("nonce not increased, signature reported as bound")
+	}
+	sigs.stored[hackerTaintStorageKey{addr, slot}] = []common.Hash{common.BigToHash(big.NewInt(8))}
+	if parts, bound := sigs.components(outer, chainId, 0); !bound {
+		t.Fatalf("increased nonce not found: %v", parts)
+	}
+	if _, bound := sigs.components(common.HexToHash("0x3333"), chainId, 0); bound {
+		t.Fatal("hash without preimage reported as bound")
+	}
+	sigs.preimages[inner].words[1] = common.BigToHash(chainId)
+	if _, bound := sigs.components(outer, chainId, 0); !bound {
+		t.Fatal("chain id not found")
+	}
+}
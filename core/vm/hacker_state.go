@@ -19,16 +19,16 @@ func StorageToString(storage map[common.Hash]common.Hash) string {
 	Data = Data + "\n}\n"
 	return Data
 }
-func getStorage(_addr common.Address) map[common.Hash]common.Hash {
+func getStorage(statedb StateDB, _addr common.Address) map[common.Hash]common.Hash {
 	storage := make(map[common.Hash]common.Hash)
-	hacker_env.StateDB.ForEachStorage(_addr, func(key, value common.Hash) bool {
+	statedb.ForEachStorage(_addr, func(key, value common.Hash) bool {
 		storage[key] = value
 		return true
 	})
 	return storage
 }
-func getBalance(_addr common.Address) big.Int {
-	balance := hacker_env.StateDB.GetBalance(_addr)
+func getBalance(statedb StateDB, _addr common.Address) big.Int {
+	balance := statedb.GetBalance(_addr)
 	return *balance
 }
 type Hacker_ContractState struct {
@@ -37,9 +37,9 @@ type Hacker_ContractState struct {
 	balance big.Int
 }
 
-func newHacker_ContractState(_addr common.Address) *Hacker_ContractState {
-	_storage := getStorage(_addr)
-	_balance := getBalance(_addr)
+func newHacker_ContractState(statedb StateDB, _addr common.Address) *Hacker_ContractState {
+	_storage := getStorage(statedb, _addr)
+	_balance := getBalance(statedb, _addr)
 	return &Hacker_ContractState{addr: _addr, storage: _storage, balance: _balance}
 }
 func (state *Hacker_ContractState) String() string {
@@ -91,11 +91,11 @@ func (state *HackerState) String() string {
 	Data = Data + fmt.Sprintf("#############\n")
 	return Data
 }
-func newHackerState(addrs ...common.Address) *HackerState {
+func newHackerState(statedb StateDB, addrs ...common.Address) *HackerState {
 	size := len(addrs)
 	_contracts := make([]*Hacker_ContractState, 0,size)
 	for _, addr := range addrs {
-		_contracts = append(_contracts, newHacker_ContractState(addr))
+		_contracts = append(_contracts, newHacker_ContractState(statedb, addr))
 	}
 	return &HackerState{contracts: _contracts}
 }
@@ -0,0 +1,155 @@
+/**
+* @hacker_statediff.go
+* Account diff of the watched transaction, next to the storage and balance diff of the
+* watched contract (storage_old/storage_new, balance_old/balance_new).
+* 1 the nonce of the origin, of the creators and of the created accounts is kept as it
+*   was before the transaction touched it; the report lists the ones that changed. The
+*   nonce of the origin before the transaction is the nonce of the transaction.
+* 2 an account is created by CREATE/CREATE2 or by a message call with value to an account
+*   that did not exist. A created account is reported when it still exists at the end of
+*   the transaction, with code, a new nonce or when it did not exist before: the
+*   creations of reverted frames are left out.
+* 3 an account is deleted when it executed SELFDESTRUCT in a frame that was kept.
+* The section is sent in the report as "accounts".
+* 4 balance_old and the accounts are taken when the message call of the watched
+*   transaction starts, not when the transaction is watched: the transactions run in
+*   between (the ones before it in the block) do not count in the diff.
+ */
+package vm
+
+import (
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type hackerAccount struct {
+	// nonce and existence when the transaction first touched the account
+	nonce   uint64
+	existed bool
+	created bool
+	deleted bool
+}
+
+// hackerAccounts records the accounts whose nonce or existence the watched transaction
+// may change, in the order they were touched.
+type hackerAccounts struct {
+	accounts map[common.Address]*hackerAccount
+	order    []common.Address
+}
+
+func newHackerAccounts() *hackerAccounts {
+	return &hackerAccounts{accounts: make(map[common.Address]*hackerAccount)}
+}
+
+// touch returns the record of addr, reading its nonce and existence the first time.
+func (accounts *hackerAccounts) touch(statedb StateDB, addr common.Address) *hackerAccount {
+	account, ok := accounts.accounts[addr]
+	if !ok {
+		account = &hackerAccount{nonce: statedb.GetNonce(addr), existed: statedb.Exist(addr)}
+		accounts.accounts[addr] = account
+		accounts.order = append(accounts.order, addr)
+	}
+	return account
+}
+
+// HackerNonceChange is the nonce of an account before and after the transaction.
+type HackerNonceChange struct {
+	Address common.Address `json:"address"`
+	Old     uint64         `json:"old"`
+	New     uint64         `json:"new"`
+}
+
+// HackerAccountDiff is the "accounts" section of the WatchDog report.
+type HackerAccountDiff struct {
+	Nonces  []HackerNonceChange `json:"nonces"`
+	Created []common.Address    `json:"created"`
+	Deleted []common.Address    `json:"deleted"`
+}
+
+// diff returns the changes of the accounts in statedb at the end of the transaction.
+func (accounts *hackerAccounts) diff(statedb StateDB) *HackerAccountDiff {
+	diff := &HackerAccountDiff{Nonces: make([]HackerNonceChange, 0), Created: make([]common.Address, 0), Deleted: make([]common.Address, 0)}
+	for _, addr := range accounts.order {
+		account := accounts.accounts[addr]
+		nonce := statedb.GetNonce(addr)
+		if nonce != account.nonce {
+			diff.Nonces = append(diff.Nonces, HackerNonceChange{Address: addr, Old: account.nonce, New: nonce})
+		}
+		if account.created && statedb.Exist(addr) && (!account.existed || nonce != account.nonce || statedb.GetCodeSize(addr) != 0) {
+			diff.Created = append(diff.Created, addr)
+		}
+		// the self-destructs of reverted frames were reverted with them
+		if account.deleted && statedb.HasSuicided(addr) {
+			diff.Deleted = append(diff.Deleted, addr)
+		}
+	}
+	return diff
+}
+
+// watchAccounts starts the account diff of the watched transaction.
+func (dog *WatchDog) watchAccounts() {
+	dog.accounts = newHackerAccounts()
+	dog.accounts.touch(dog.env.StateDB, dog.env.Origin).nonce = dog.tx.Nonce()
+	dog.accounts.touch(dog.env.StateDB, *dog.tx.To())
+}
+
+// accountDiff returns the "accounts" section of the watched transaction.
+func (dog *WatchDog) accountDiff() *HackerAccountDiff {
+	if dog.accounts == nil {
+		return newHackerAccounts().diff(dog.env.StateDB)
+	}
+	return dog.accounts.diff(dog.env.StateDB)
+}
+
+// dogsOf returns the WatchDogs watching a transaction executed by evm.
+func dogsOf(evm *EVM) []*WatchDog {
+	dogs := make([]*WatchDog, 0, 2)
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() && dog.accounts != nil {
+			dogs = append(dogs, dog)
+		}
+	}
+	return dogs
+}
+
+// hacker_nonce_bump is called before evm increments the nonce of addr.
+func hacker_nonce_bump(evm *EVM, addr common.Address) {
+	for _, dog := range dogsOf(evm) {
+		dog.accounts.touch(evm.StateDB, addr)
+	}
+}
+
+// hacker_account_created is called before evm creates the account addr.
+func hacker_account_created(evm *EVM, addr common.Address) {
+	for _, dog := range dogsOf(evm) {
+		dog.accounts.touch(evm.StateDB, addr).created = true
+	}
+}
+
+// recordSelfDestruct runs before SELFDESTRUCT executes in contract.
+func (dog *WatchDog) recordSelfDestruct(contract *Contract) {
+	if dog.accounts != nil {
+		dog.accounts.touch(dog.env.StateDB, contract.Address()).deleted = true
+	}
+}
+
+// hacker_execution_start is called when a message call of evm starts at depth 0, before
+// the value is transferred.
+func hacker_execution_start(evm *EVM) {
+	for _, dog := range hackerDogs(evm) {
+		if dog.watches(evm) && dog.TurnOn() && !dog.started {
+			dog.lock.Lock()
+			dog.started = true
+			dog.lock.Unlock()
+			dog.watchAccounts()
+			dog.balance_old = *(evm.StateDB.GetBalance(*(dog.tx.To())))
+			dog.senderOld = new(big.Int).Set(evm.StateDB.GetBalance(evm.Origin))
+			dog.tokensOld = probeTokens(evm, evm.Origin, GetGlobalCampaign().profitTokenList())
+			dog.revert = GetGlobalCampaign().newRevertCapture(evm.StateDB, evm.Origin, *dog.tx.To())
+			dog.constraint = GetGlobalCampaign().checkConstraints(evm)
+			log.Printf("balance before tx : %s", dog.balance_old.Text(10))
+		}
+	}
+}
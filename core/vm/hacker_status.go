@@ -0,0 +1,173 @@
+/**
+* @hacker_status.go
+* Live status of the campaign, for the fuzz_status API and the dashboard of the node.
+* 1 the total of the coverage edges is sampled at every block end, the last
+*   hackerCoverageSamples samples show how the coverage grows.
+* 2 the findings of the transactions ending are kept with their transaction and
+*   target, the last hackerRecentFindings of them.
+* 3 every report sent or failed to be sent is counted, with the last error: a sink
+*   down or slow shows up before the fuzzer starves.
+* 4 the targets are the ones executed (selector counts), downgraded or in the corpus,
+*   with their label, executions, selectors seen, instrumentation level and corpus size.
+ */
+package vm
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// hackerCoverageSamples is the number of coverage samples kept
+	hackerCoverageSamples = 256
+	// hackerRecentFindings is the number of findings kept
+	hackerRecentFindings = 100
+)
+
+// HackerCoverageSample is the total of the coverage edges at a block end.
+type HackerCoverageSample struct {
+	Block uint64 `json:"block"`
+	Edges int    `json:"edges"`
+}
+
+// HackerRecentFinding is a finding of a transaction watched.
+type HackerRecentFinding struct {
+	Hash   common.Hash    `json:"hash"`
+	Target common.Address `json:"target"`
+	// Time is when the transaction ended, in seconds since the epoch
+	Time int64 `json:"time"`
+	HackerFinding
+}
+
+// HackerReportStats counts the reports sent to the fuzzer.
+type HackerReportStats struct {
+	Sent      uint64 `json:"sent"`
+	Failed    uint64 `json:"failed"`
+	LastError string `json:"lastError,omitempty"`
+	// LastSent is when the last report was sent, in seconds since the epoch
+	LastSent int64 `json:"lastSent,omitempty"`
+}
+
+// HackerTargetStatus is the status of a target of the campaign.
+type HackerTargetStatus struct {
+	Address         common.Address `json:"address"`
+	Label           string         `json:"label,omitempty"`
+	Executions      uint64         `json:"executions"`
+	Selectors       int            `json:"selectors"`
+	Instrumentation string         `json:"instrumentation"`
+	Corpus          int            `json:"corpus"`
+}
+
+// HackerStatus is the live status of the campaign.
+type HackerStatus struct {
+	Session  HackerSession          `json:"session"`
+	Edges    int                    `json:"edges"`
+	Growth   []HackerCoverageSample `json:"growth"`
+	Targets  []HackerTargetStatus   `json:"targets"`
+	Reports  HackerReportStats      `json:"reports"`
+	Findings int                    `json:"findings"`
+}
+
+// sampleCoverage adds a coverage sample at the end of the block of header, the lock
+// of c is held.
+func (c *HackerCampaign) sampleCoverage(header *types.Header) {
+	sample := HackerCoverageSample{}
+	if header != nil && header.Number != nil {
+		sample.Block = header.Number.Uint64()
+	}
+	for _, bitmap := range c.coverage {
+		sample.Edges += hackerBitCount(bitmap)
+	}
+	if len(c.growth) >= hackerCoverageSamples {
+		c.growth = c.growth[1:]
+	}
+	c.growth = append(c.growth, sample)
+}
+
+// addFindings keeps the findings of the transaction tx.
+func (c *HackerCampaign) addFindings(tx *types.Transaction, findings []HackerFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now().Unix()
+	for _, finding := range findings {
+		if len(c.recent) >= hackerRecentFindings {
+			c.recent = c.recent[1:]
+		}
+		c.recent = append(c.recent, HackerRecentFinding{Hash: tx.Hash(), Target: *tx.To(), Time: now, HackerFinding: finding})
+		c.findingTypes[finding.Type]++
+	}
+}
+
+// countReport counts a report sent in elapsed, failed with err.
+func (c *HackerCampaign) countReport(err error, elapsed time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.observeLatency(elapsed)
+	if err != nil {
+		c.reports.Failed++
+		c.reports.LastError = err.Error()
+		return
+	}
+	c.reports.Sent++
+	c.reports.LastSent = time.Now().Unix()
+}
+
+// Findings returns the last findings, at most limit of them and all of them for zero,
+// the most recent first.
+func (c *HackerCampaign) Findings(limit int) []HackerRecentFinding {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if limit == 0 || limit > len(c.recent) {
+		limit = len(c.recent)
+	}
+	findings := make([]HackerRecentFinding, 0, limit)
+	for i := len(c.recent) - 1; i >= len(c.recent)-limit; i-- {
+		findings = append(findings, c.recent[i])
+	}
+	return findings
+}
+
+// Status returns the live status of the campaign.
+func (c *HackerCampaign) Status() *HackerStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	status := &HackerStatus{Session: c.session, Growth: append([]HackerCoverageSample{}, c.growth...), Reports: c.reports, Findings: len(c.recent)}
+	for _, bitmap := range c.coverage {
+		status.Edges += hackerBitCount(bitmap)
+	}
+	targets := make(map[common.Address]*HackerTargetStatus)
+	target := func(addr common.Address) *HackerTargetStatus {
+		if targets[addr] == nil {
+			targets[addr] = &HackerTargetStatus{Address: addr, Label: c.labels[addr], Instrumentation: HackerInstrumentFull.String()}
+		}
+		return targets[addr]
+	}
+	for addr, selectors := range c.selectors {
+		status := target(addr)
+		status.Selectors = len(selectors)
+		for _, count := range selectors {
+			status.Executions += count
+		}
+	}
+	for addr := range c.reduced {
+		target(addr).Instrumentation = HackerInstrumentCalls.String()
+	}
+	for _, entry := range c.corpus {
+		target(entry.Target).Corpus++
+	}
+	status.Targets = make([]HackerTargetStatus, 0, len(targets))
+	for _, target := range targets {
+		status.Targets = append(status.Targets, *target)
+	}
+	sort.Slice(status.Targets, func(i, j int) bool {
+		return bytes.Compare(status.Targets[i].Address[:], status.Targets[j].Address[:]) < 0
+	})
+	return status
+}
@@ -0,0 +1,115 @@
+/**
+* @hacker_storagerange.go
+* Storage iteration of the watched contracts, which the standard RPC lacks.
+* 1 the slots of a contract are enumerated in the order of their keys, a page at a
+*   time: a page starts at a key and holds at most limit slots, Next is the start of
+*   the following page, nil on the last one. A state which can seek its storage
+*   (HackerStorageSeeker) is iterated from the start key and left after limit+1 slots;
+*   ForEachStorage follows no order (the trie is keyed by the hashes of the keys), so
+*   over it only the limit+1 lowest keys from start on are kept while it runs.
+* 2 only the targets of the campaign (the contracts of the watched transactions and the
+*   ones registered, hacker_dictionary.go) and the contracts of the loaded fixtures can
+*   be iterated.
+ */
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// hackerStorageRangeLimit is the size of a page when none is given, and the largest
+	// one
+	hackerStorageRangeLimit = 1024
+	hackerStorageRangeMax   = 65536
+)
+
+var errNotTarget = errors.New("not a watched contract")
+
+// HackerStorageSlot is a slot of a storage range.
+type HackerStorageSlot struct {
+	Key   common.Hash `json:"key"`
+	Value common.Hash `json:"value"`
+}
+
+// HackerStorageRange is a page of the storage of a contract.
+type HackerStorageRange struct {
+	Storage []HackerStorageSlot `json:"storage"`
+	Next    *common.Hash        `json:"next"`
+}
+
+// HackerStorageSeeker is a StateDB which iterates the storage of an account in the
+// order of the keys from start on, until cb returns false.
+type HackerStorageSeeker interface {
+	ForEachStorageFrom(addr common.Address, start common.Hash, cb func(key, value common.Hash) bool)
+}
+
+// StorageRange returns the page of the non-empty slots of addr in statedb starting at
+// the key start, of at most limit slots.
+func StorageRange(statedb StateDB, addr common.Address, start common.Hash, limit int) *HackerStorageRange {
+	if limit <= 0 {
+		limit = hackerStorageRangeLimit
+	}
+	if limit > hackerStorageRangeMax {
+		limit = hackerStorageRangeMax
+	}
+	// one slot over the page tells where the next one starts
+	entries := make([]HackerStorageSlot, 0)
+	if seeker, ok := statedb.(HackerStorageSeeker); ok {
+		seeker.ForEachStorageFrom(addr, start, func(key, value common.Hash) bool {
+			if value != (common.Hash{}) {
+				entries = append(entries, HackerStorageSlot{key, value})
+			}
+			return len(entries) <= limit
+		})
+	} else {
+		statedb.ForEachStorage(addr, func(key, value common.Hash) bool {
+			if value == (common.Hash{}) || bytes.Compare(key[:], start[:]) < 0 {
+				return true
+			}
+			i := sort.Search(len(entries), func(i int) bool { return bytes.Compare(entries[i].Key[:], key[:]) > 0 })
+			if i > limit {
+				return true
+			}
+			entries = append(entries, HackerStorageSlot{})
+			copy(entries[i+1:], entries[i:])
+			entries[i] = HackerStorageSlot{key, value}
+			if len(entries) > limit+1 {
+				entries = entries[:limit+1]
+			}
+			return true
+		})
+	}
+	page := &HackerStorageRange{Storage: entries}
+	if len(entries) > limit {
+		next := entries[limit].Key
+		page.Storage, page.Next = entries[:limit], &next
+	}
+	return page
+}
+
+// IsTarget tells whether the storage of addr can be iterated: addr is a target of the
+// campaign or has a fixture loaded.
+func (c *HackerCampaign) IsTarget(addr common.Address) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, target := c.dictionaries[addr]
+	_, fixture := c.fixtures[addr]
+	return target || fixture
+}
+
+// StorageRange returns the page of the storage of addr starting at start, as the
+// executions of the harness see it, if addr is a target of the campaign.
+func (harness *HackerHarness) StorageRange(addr common.Address, start common.Hash, limit int) (page *HackerStorageRange, err error) {
+	if !GetGlobalCampaign().IsTarget(addr) {
+		return nil, errNotTarget
+	}
+	harness.Fork(func() {
+		page = StorageRange(harness.statedb, addr, start, limit)
+	})
+	return page, nil
+}
@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// storageStateDB iterates its storage in no order, as the trie keyed by the hashes of
+// the keys does.
+type storageStateDB struct {
+	NoopStateDB
+	storage map[common.Hash]common.Hash
+}
+
+func (db *storageStateDB) ForEachStorage(_ common.Address, cb func(key, value common.Hash) bool) {
+	for key, value := range db.storage {
+		if !cb(key, value) {
+			return
+		}
+	}
+}
+
+// seekStateDB iterates its storage from a key on and counts the slots visited.
+type seekStateDB struct {
+	storageStateDB
+	visited int
+}
+
+func (db *seekStateDB) ForEachStorageFrom(_ common.Address, start common.Hash, cb func(key, value common.Hash) bool) {
+	keys := make([]common.Hash, 0)
+	for key := range db.storage {
+		if bytes.Compare(key[:], start[:]) >= 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	for _, key := range keys {
+		db.visited++
+		if !cb(key, db.storage[key]) {
+			return
+		}
+	}
+}
+
+func TestStorageRangePages(t *testing.T) {
+	storage := make(map[common.Hash]common.Hash)
+	for i := int64(1); i <= 20; i++ {
+		storage[common.BigToHash(big.NewInt(i))] = common.BigToHash(big.NewInt(i * i))
+	}
+	// an emptied slot is skipped
+	storage[common.BigToHash(big.NewInt(21))] = common.Hash{}
+
+	seeker := &seekStateDB{storageStateDB: storageStateDB{storage: storage}}
+	for name, statedb := range map[string]StateDB{"unordered": &storageStateDB{storage: storage}, "seek": seeker} {
+		var (
+			slots []HackerStorageSlot
+			start = common.BigToHash(big.NewInt(3))
+			pages int
+		)
+		for {
+			page := StorageRange(statedb, common.Address{}, start, 7)
+			slots, pages = append(slots, page.Storage...), pages+1
+			if page.Next == nil {
+				break
+			}
+			start = *page.Next
+		}
+		if len(slots) != 18 || pages != 3 {
+			t.Fatalf("%s: %d slots in %d pages, want 18 in 3", name, len(slots), pages)
+		}
+		for i, slot := range slots {
+			if key := common.BigToHash(big.NewInt(int64(i + 3))); slot.Key != key || slot.Value != storage[key] {
+				t.Errorf("%s: slot %d %+v, want the slots in order from 3", name, i, slot)
+			}
+		}
+	}
+	// the pages stop the iteration one slot over the limit, the last one runs to the
+	// emptied slot
+	if want := 8 + 8 + 5; seeker.visited != want {
+		t.Errorf("visited %d slots, want %d", seeker.visited, want)
+	}
+}
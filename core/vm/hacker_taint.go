@@ -0,0 +1,363 @@
+/**
+* @hacker_taint.go
+* Lightweight taint tracking for watched executions.
+* 1 every interpreter stack and memory gets a shadow holding a bit mask of the
+*   taint sources (environment opcodes, ...) each word was derived from.
+* 2 Hacker_record calls before()/after() around each operation, the shadow is
+*   kept in step with the real stack by the pop counts of hackerStackPops.
+* 3 sinks (CALL value/recipient, branches) are checked by the oracles built on
+*   top of it, e.g. the weak randomness oracle below.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// taint sources
+const (
+	taintBlockHash uint = 1 << iota
+	taintTimestamp
+	taintNumber
+	taintDifficulty
+	taintCoinbase
+	taintCalldataSize
+	taintStorage
+	taintCalldata
+	taintBaseFee
+)
+
+// taintEnvironment covers every block environment source.
+const taintEnvironment = taintBlockHash | taintTimestamp | taintNumber | taintDifficulty | taintCoinbase | taintBaseFee
+
+var taintSourceOps = map[OpCode]uint{
+	BLOCKHASH:  taintBlockHash,
+	TIMESTAMP:  taintTimestamp,
+	NUMBER:     taintNumber,
+	DIFFICULTY: taintDifficulty,
+	COINBASE:   taintCoinbase,
+	BASEFEE:    taintBaseFee,
+
+	CALLDATASIZE: taintCalldataSize,
+	SLOAD:        taintStorage,
+	CALLDATALOAD: taintCalldata,
+}
+
+// taintSourceNames lists the opcodes a mask was derived from.
+func taintSourceNames(mask uint) []string {
+	names := make([]string, 0)
+	for _, op := range []OpCode{BLOCKHASH, TIMESTAMP, NUMBER, DIFFICULTY, COINBASE, BASEFEE, CALLDATASIZE, SLOAD, CALLDATALOAD} {
+		if mask&taintSourceOps[op] != 0 {
+			names = append(names, opCodeToString[op])
+		}
+	}
+	return names
+}
+
+// hackerStackPops returns the number of stack items consumed by op.
+func hackerStackPops(op OpCode) int {
+	switch {
+	case op >= PUSH1 && op <= PUSH32:
+		return 0
+	case op >= DUP1 && op <= DUP16:
+		return int(op-DUP1) + 1
+	case op >= SWAP1 && op <= SWAP16:
+		return int(op-SWAP1) + 2
+	case op >= LOG0 && op <= LOG4:
+		return int(op-LOG0) + 2
+	}
+	switch op {
+	case ISZERO, NOT, BALANCE, CALLDATALOAD, EXTCODESIZE, EXTCODEHASH, BLOCKHASH, POP, MLOAD, SLOAD, JUMP, SELFDESTRUCT:
+		return 1
+	case ADD, MUL, SUB, DIV, SDIV, MOD, SMOD, EXP, SIGNEXTEND, LT, GT, SLT, SGT, EQ, AND, OR, XOR, BYTE,
+		SHL, SHR, SAR, SHA3, MSTORE, MSTORE8, SSTORE, JUMPI, RETURN, REVERT:
+		return 2
+	case ADDMOD, MULMOD, CALLDATACOPY, CODECOPY, RETURNDATACOPY, CREATE:
+		return 3
+	case EXTCODECOPY, CREATE2:
+		return 4
+	case DELEGATECALL, STATICCALL:
+		return 6
+	case CALL, CALLCODE:
+		return 7
+	}
+	return 0
+}
+
+// hackerTaintPropagates reports whether the result of op is computed from its inputs.
+func hackerTaintPropagates(op OpCode) bool {
+	switch op {
+	case ADD, MUL, SUB, DIV, SDIV, MOD, SMOD, EXP, SIGNEXTEND, LT, GT, SLT, SGT, EQ, ISZERO,
+		AND, OR, XOR, NOT, BYTE, SHL, SHR, SAR, ADDMOD, MULMOD:
+		return true
+	}
+	return false
+}
+
+type taintRange struct {
+	start, end uint64
+	mask       uint
+}
+
+// maxTaintRanges bounds the memory shadow of a single frame.
+const maxTaintRanges = 1024
+
+type hackerTaintMemory struct {
+	ranges []taintRange
+}
+
+func (mem *hackerTaintMemory) set(offset, size uint64, mask uint) {
+	if size == 0 {
+		return
+	}
+	end := offset + size
+	kept := mem.ranges[:0]
+	for _, r := range mem.ranges {
+		if r.start >= offset && r.end <= end {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	mem.ranges = kept
+	if mask != 0 {
+		if len(mem.ranges) >= maxTaintRanges {
+			mem.ranges = mem.ranges[1:]
+		}
+		mem.ranges = append(mem.ranges, taintRange{start: offset, end: end, mask: mask})
+	}
+}
+
+func (mem *hackerTaintMemory) get(offset, size uint64) uint {
+	var mask uint
+	end := offset + size
+	for _, r := range mem.ranges {
+		if r.start < end && offset < r.end {
+			mask |= r.mask
+		}
+	}
+	return mask
+}
+
+type hackerTaintStorageKey struct {
+	addr common.Address
+	slot common.Hash
+}
+
+// HackerTaint holds the shadow state of one watched execution.
+type HackerTaint struct {
+	stacks   map[*Stack][]uint
+	memories map[*Memory]*hackerTaintMemory
+	storage  map[hackerTaintStorageKey]uint
+	// branch collects the taint of JUMPI conditions taken so far, per contract
+	branch map[common.Address]uint
+	// frames that compared CALLDATASIZE against anything
+	lengthChecked map[*Contract]bool
+	// loop back edges and bounds, per frame
+	loopFrames map[*Contract]*hackerLoopFrame
+	// hash preimages, loads, stores and ecrecover calls, for the signature replay oracle
+	signatures *hackerSignatures
+	// frames of the transaction, and the token events and storage writes made in them
+	journal *hackerFrameJournal
+	erc20   *hackerERC20
+	// refunds generated per frame and the slots cleared (hacker_refund.go)
+	refunds *hackerRefunds
+	// sinks already reported, keyed by contract, pc and sink kind
+	reported map[string]bool
+	// calls with value per frame, for the checks-effects-interactions oracle
+	ceiCalls map[int]*hackerCEICall
+}
+
+func newHackerTaint() *HackerTaint {
+	return &HackerTaint{
+		stacks:   make(map[*Stack][]uint),
+		memories: make(map[*Memory]*hackerTaintMemory),
+		storage:  make(map[hackerTaintStorageKey]uint),
+		branch:   make(map[common.Address]uint),
+		reported: make(map[string]bool),
+		ceiCalls: make(map[int]*hackerCEICall),
+
+		lengthChecked: make(map[*Contract]bool),
+		loopFrames:    make(map[*Contract]*hackerLoopFrame),
+		signatures:    newHackerSignatures(),
+		journal:       newHackerFrameJournal(),
+		erc20:         newHackerERC20(),
+		refunds:       newHackerRefunds(),
+	}
+}
+
+func (taint *HackerTaint) shadow(stack *Stack) []uint {
+	shadow := taint.stacks[stack]
+	// keep the shadow the same height as the real stack
+	for len(shadow) < stack.len() {
+		shadow = append(shadow, 0)
+	}
+	if len(shadow) > stack.len() {
+		shadow = shadow[:stack.len()]
+	}
+	return shadow
+}
+
+func (taint *HackerTaint) memory(memory *Memory) *hackerTaintMemory {
+	mem, ok := taint.memories[memory]
+	if !ok {
+		mem = new(hackerTaintMemory)
+		taint.memories[memory] = mem
+	}
+	return mem
+}
+
+// hackerTaintStep holds the operands of one operation. It is kept apart from
+// HackerTaint because CALL-like operations run nested frames in between.
+type hackerTaintStep struct {
+	pc     uint64
+	inputs []uint
+	args   []*big.Int
+}
+
+func (step *hackerTaintStep) input(n int) uint {
+	if n < len(step.inputs) {
+		return step.inputs[n]
+	}
+	return 0
+}
+
+func (step *hackerTaintStep) arg(n int) *big.Int {
+	if n < len(step.args) {
+		return step.args[n]
+	}
+	return new(big.Int)
+}
+
+// before snapshots the taint and values of the operands of op.
+func (taint *HackerTaint) before(op OpCode, pc uint64, contract *Contract, memory *Memory, stack *Stack) *hackerTaintStep {
+	pops := hackerStackPops(op)
+	if op >= DUP1 && op <= DUP16 || op >= SWAP1 && op <= SWAP16 {
+		pops = 0
+	}
+	shadow := taint.shadow(stack)
+	step := &hackerTaintStep{pc: pc, inputs: make([]uint, 0, pops), args: make([]*big.Int, 0, pops)}
+	for i := 0; i < pops && i < stack.len(); i++ {
+		step.inputs = append(step.inputs, shadow[len(shadow)-1-i])
+		step.args = append(step.args, new(big.Int).Set(stack.Back(i)))
+	}
+	taint.stacks[stack] = shadow
+	return step
+}
+
+// after updates the shadow once op has executed successfully, pc is the
+// program counter as left by the operation.
+func (taint *HackerTaint) after(step *hackerTaintStep, op OpCode, pc uint64, contract *Contract, memory *Memory, stack *Stack) {
+	shadow := taint.stacks[stack]
+	switch {
+	case op >= DUP1 && op <= DUP16:
+		n := int(op-DUP1) + 1
+		if len(shadow) >= n {
+			shadow = append(shadow, shadow[len(shadow)-n])
+		}
+		taint.stacks[stack] = taint.resize(shadow, stack)
+		return
+	case op >= SWAP1 && op <= SWAP16:
+		n := int(op-SWAP1) + 2
+		if len(shadow) >= n {
+			shadow[len(shadow)-n], shadow[len(shadow)-1] = shadow[len(shadow)-1], shadow[len(shadow)-n]
+		}
+		taint.stacks[stack] = taint.resize(shadow, stack)
+		return
+	}
+	var mask uint
+	if source, ok := taintSourceOps[op]; ok {
+		mask = source
+	} else if hackerTaintPropagates(op) {
+		for _, in := range step.inputs {
+			mask |= in
+		}
+	}
+	switch op {
+	case SHA3:
+		mask = taint.memory(memory).get(step.arg(0).Uint64(), step.arg(1).Uint64())
+	case MLOAD:
+		mask = taint.memory(memory).get(step.arg(0).Uint64(), 32)
+	case MSTORE:
+		taint.memory(memory).set(step.arg(0).Uint64(), 32, step.input(1))
+	case MSTORE8:
+		taint.memory(memory).set(step.arg(0).Uint64(), 1, step.input(1))
+	case CALLDATACOPY:
+		taint.memory(memory).set(step.arg(0).Uint64(), step.arg(2).Uint64(), taintCalldata)
+	case CODECOPY, RETURNDATACOPY:
+		taint.memory(memory).set(step.arg(0).Uint64(), step.arg(2).Uint64(), 0)
+	case EXTCODECOPY:
+		taint.memory(memory).set(step.arg(1).Uint64(), step.arg(3).Uint64(), 0)
+	case SLOAD:
+		mask |= taint.storage[hackerTaintStorageKey{contract.Address(), common.BigToHash(step.arg(0))}]
+	case SSTORE:
+		key := hackerTaintStorageKey{contract.Address(), common.BigToHash(step.arg(0))}
+		if step.input(1) != 0 {
+			taint.storage[key] = step.input(1)
+		} else {
+			delete(taint.storage, key)
+		}
+	case JUMPI:
+		taint.branch[contract.Address()] |= step.input(1)
+	}
+	pops := len(step.inputs)
+	if pops > len(shadow) {
+		pops = len(shadow)
+	}
+	shadow = shadow[:len(shadow)-pops]
+	for len(shadow) < stack.len() {
+		shadow = append(shadow, mask)
+	}
+	taint.stacks[stack] = taint.resize(shadow, stack)
+}
+
+func (taint *HackerTaint) resize(shadow []uint, stack *Stack) []uint {
+	for len(shadow) < stack.len() {
+		shadow = append(shadow, 0)
+	}
+	return shadow[:stack.len()]
+}
+
+// once reports whether the sink was not reported before, and marks it as reported.
+func (taint *HackerTaint) once(contract *Contract, pc uint64, sink string) bool {
+	key := fmt.Sprintf("%s:%d:%s", contract.Address().Hex(), pc, sink)
+	if taint.reported[key] {
+		return false
+	}
+	taint.reported[key] = true
+	return true
+}
+
+/**
+* Oracle: weak randomness
+* an environment derived value (BLOCKHASH, TIMESTAMP, ...) decides the value or
+* recipient of a CALL, or a branch leading to a value transfer.
+* Checked in line on every CALL/CALLCODE, before the call executes.
+ */
+func (taint *HackerTaint) checkWeakRandomness(dog *WatchDog, step *hackerTaintStep, op OpCode, pc uint64, contract *Contract) {
+	if op != CALL && op != CALLCODE {
+		return
+	}
+	sinks := map[string]uint{
+		"recipient": step.input(1) & taintEnvironment,
+		"value":     step.input(2) & taintEnvironment,
+	}
+	if step.arg(2).Sign() > 0 {
+		sinks["branch"] = taint.branch[contract.Address()] & taintEnvironment
+	}
+	for _, sink := range []string{"recipient", "value", "branch"} {
+		mask := sinks[sink]
+		if mask == 0 || !taint.once(contract, pc, sink) {
+			continue
+		}
+		finding := newHackerFinding("weak_randomness", "HackerWeakRandomness")
+		finding.Detail["contract"] = contract.Address().Hex()
+		finding.Detail["pc"] = fmt.Sprintf("%d", pc)
+		finding.Detail["sink"] = opCodeToString[op] + " " + sink
+		finding.Detail["sources"] = strings.Join(taintSourceNames(mask), ",")
+		dog.EmitFinding(finding)
+	}
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestTaintPropagation(t *testing.T) {
+	var (
+		taint    = newHackerTaint()
+		stack    = newstack()
+		mem      = NewMemory()
+		contract = NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 0)
+	)
+	// TIMESTAMP
+	step := taint.before(TIMESTAMP, 0, contract, mem, stack)
+	stack.push(big.NewInt(1500000000))
+	taint.after(step, TIMESTAMP, 0, contract, mem, stack)
+	// PUSH1 2
+	step = taint.before(PUSH1, 0, contract, mem, stack)
+	stack.push(big.NewInt(2))
+	taint.after(step, PUSH1, 0, contract, mem, stack)
+	if shadow := taint.stacks[stack]; shadow[0] != taintTimestamp || shadow[1] != 0 {
+		t.Fatalf("unexpected shadow after push: %v", shadow)
+	}
+	// SWAP1
+	step = taint.before(SWAP1, 0, contract, mem, stack)
+	stack.swap(2)
+	taint.after(step, SWAP1, 0, contract, mem, stack)
+	if shadow := taint.stacks[stack]; shadow[0] != 0 || shadow[1] != taintTimestamp {
+		t.Fatalf("unexpected shadow after swap: %v", shadow)
+	}
+	// MOD
+	step = taint.before(MOD, 0, contract, mem, stack)
+	x, y := stack.pop(), stack.pop()
+	stack.push(new(big.Int).Mod(x, y))
+	taint.after(step, MOD, 0, contract, mem, stack)
+	if shadow := taint.stacks[stack]; len(shadow) != 1 || shadow[0] != taintTimestamp {
+		t.Fatalf("taint not propagated through MOD: %v", shadow)
+	}
+	// MSTORE at 0x40, then MLOAD back from an overlapping offset
+	stack.push(big.NewInt(0x40))
+	taint.stacks[stack] = append(taint.stacks[stack], 0)
+	step = taint.before(MSTORE, 0, contract, mem, stack)
+	stack.pop()
+	stack.pop()
+	taint.after(step, MSTORE, 0, contract, mem, stack)
+	if mask := taint.memory(mem).get(0x50, 32); mask != taintTimestamp {
+		t.Fatalf("memory taint lost, got %x", mask)
+	}
+	if mask := taint.memory(mem).get(0x00, 32); mask != 0 {
+		t.Fatalf("unrelated memory tainted, got %x", mask)
+	}
+}
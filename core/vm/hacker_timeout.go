@@ -0,0 +1,61 @@
+/**
+* @hacker_timeout.go
+* Execution bounds of the fuzz harness.
+* 1 the operations of a transaction are counted across its frames when the EVM has a
+*   step limit, the transaction is cancelled (EVM.Cancel) once it is exceeded and the
+*   frame running fails with ErrExecutionTimeout.
+* 2 the wall-clock timeout of the harness calls EVM.Cancel from a timer, the frames
+*   stop at their next operation.
+* 3 a WatchDog with a step budget gives it as step limit to the transactions it
+*   watches, independently of their gas: the instrumentation cost of a candidate is
+*   bounded. The budget and the operations counted are reported ("steps").
+ */
+package vm
+
+import (
+	"sync/atomic"
+)
+
+// SetStepLimit cancels the transaction after limit operations, zero for no limit.
+func (evm *EVM) SetStepLimit(limit uint64) {
+	evm.stepLimit = limit
+}
+
+// step counts an operation and reports whether the transaction went past its step
+// limit, cancelling it.
+func (evm *EVM) step() bool {
+	evm.steps++
+	if evm.steps > evm.stepLimit {
+		evm.Cancel()
+		return true
+	}
+	return false
+}
+
+// SetStepBudget caps the operations of the transactions watched from now on, zero
+// removes the cap.
+func (dog *WatchDog) SetStepBudget(budget uint64) {
+	atomic.StoreUint64(&dog.stepBudget, budget)
+}
+
+// HackerSteps is the "steps" section of the WatchDog report.
+type HackerSteps struct {
+	Budget   uint64 `json:"budget"`
+	Count    uint64 `json:"count"`
+	Exceeded bool   `json:"exceeded"`
+}
+
+// steps returns the operations counted for the watched transaction, nil without a
+// step limit.
+func (dog *WatchDog) steps() *HackerSteps {
+	if dog.env == nil || dog.env.stepLimit == 0 {
+		return nil
+	}
+	count := dog.env.steps
+	exceeded := count > dog.env.stepLimit
+	if exceeded {
+		// the operation going past the limit is not executed
+		count = dog.env.stepLimit
+	}
+	return &HackerSteps{Budget: dog.env.stepLimit, Count: count, Exceeded: exceeded}
+}
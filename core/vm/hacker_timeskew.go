@@ -0,0 +1,93 @@
+/**
+* @hacker_timeskew.go
+* Timestamp skew exploration of watched transactions.
+* 1 when enabled (SetTimeSkew), Watch() replays the transaction on a fork of the
+*   pre-state as is, then once per offset with the timestamp of the block moved forward
+*   by the offset (+1s, +15s and +1 day by default).
+* 2 every replay is compared with the original one, the divergences are the "timeSkew"
+*   section of the report with the share of the offsets changing the outcome, the
+*   timestamp sensitivity of the target.
+ */
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// hackerDefaultTimeSkews are the offsets, in seconds, replayed by default.
+var hackerDefaultTimeSkews = []uint64{1, 15, 24 * 60 * 60}
+
+// HackerTimeSkew compares the replay of a message at a timestamp offset.
+type HackerTimeSkew struct {
+	Offset  uint64   `json:"offset"`
+	Changed bool     `json:"changed"`
+	Reasons []string `json:"reasons"`
+	Failed  bool     `json:"failed"`
+}
+
+// HackerTimeSkewReport is the "timeSkew" section of the WatchDog report.
+type HackerTimeSkewReport struct {
+	Target         common.Address   `json:"target"`
+	OriginalFailed bool             `json:"originalFailed"`
+	Skews          []HackerTimeSkew `json:"skews"`
+	// Sensitivity is the share of the offsets changing the outcome, from 0 to 1
+	Sensitivity float64 `json:"sensitivity"`
+}
+
+// SetTimeSkew turns the timestamp skew replays of the watched transactions on or off,
+// at offsets seconds or the default ones when empty.
+func (c *HackerCampaign) SetTimeSkew(on bool, offsets []uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timeSkews = nil
+	if on {
+		c.timeSkews = append([]uint64{}, hackerDefaultTimeSkews...)
+		if len(offsets) != 0 {
+			c.timeSkews = append([]uint64{}, offsets...)
+		}
+	}
+}
+
+// TimeSkews returns the offsets replayed, nil when the replays are off.
+func (c *HackerCampaign) TimeSkews() []uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]uint64(nil), c.timeSkews...)
+}
+
+// TimeSkew executes msg as is, then once per offset with the timestamp moved forward
+// by it, and compares the outcomes.
+func (harness *HackerHarness) TimeSkew(msg *HackerMessage, offsets []uint64) *HackerTimeSkewReport {
+	if msg.To == nil {
+		return nil
+	}
+	original := harness.Execute(msg)
+	report := &HackerTimeSkewReport{Target: *msg.To, OriginalFailed: original.Failed(), Skews: make([]HackerTimeSkew, 0, len(offsets))}
+	changed := 0
+	for _, offset := range offsets {
+		ctx := harness.ctx
+		ctx.Time = new(big.Int).Add(harness.ctx.Time, new(big.Int).SetUint64(offset))
+		skewed := harness.derive(ctx, harness.statedb, harness.vmConfig).Execute(msg)
+		skew := HackerTimeSkew{Offset: offset, Reasons: original.Diff(skewed), Failed: skewed.Failed()}
+		if skew.Changed = len(skew.Reasons) > 0; skew.Changed {
+			changed++
+		}
+		report.Skews = append(report.Skews, skew)
+	}
+	if len(offsets) != 0 {
+		report.Sensitivity = float64(changed) / float64(len(offsets))
+	}
+	return report
+}
+
+func (dog *WatchDog) skewTime(env *EVM, tx *types.Transaction) {
+	dog.timeSkew = nil
+	offsets := GetGlobalCampaign().TimeSkews()
+	if offsets == nil || env == nil {
+		return
+	}
+	dog.timeSkew = newHackerHarnessFrom(env).TimeSkew(hackerTxMessage(env, tx), offsets)
+}
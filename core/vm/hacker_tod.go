@@ -0,0 +1,225 @@
+/**
+* @hacker_tod.go
+* Oracle: transaction order dependence (TOD)
+* The transactions of a block that touch a target are replayed on a fork of the
+* pre-block state in their original order and in permuted orders. Any permutation
+* leaving the targets with different storage or balances is a TOD finding listing
+* the conflicting slots.
+* The WatchDog collects the watched transactions of a block and OnBlockEnd replays them
+* on a copy of the state taken before the first one.
+ */
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// hackerTODExhaustive is the largest transaction count for which every order is tried.
+	hackerTODExhaustive = 4
+	// hackerTODTransactions is the largest transaction count collected in a block.
+	hackerTODTransactions = 16
+)
+
+// HackerTODReport is the result of replaying a block in permuted orders.
+type HackerTODReport struct {
+	Targets   []common.Address `json:"targets"`
+	Divergent bool             `json:"divergent"`
+	// Orders lists the permutations (indexes into the original order) that diverged.
+	Orders   [][]int          `json:"orders"`
+	Slots    []string         `json:"slots"`
+	Balances []common.Address `json:"balances"`
+}
+
+type hackerAccountState struct {
+	storage map[common.Hash]common.Hash
+	balance *big.Int
+}
+
+func (harness *HackerHarness) accountStates(addrs []common.Address) map[common.Address]*hackerAccountState {
+	states := make(map[common.Address]*hackerAccountState)
+	for _, addr := range addrs {
+		state := &hackerAccountState{storage: make(map[common.Hash]common.Hash), balance: new(big.Int).Set(harness.statedb.GetBalance(addr))}
+		harness.statedb.ForEachStorage(addr, func(key, value common.Hash) bool {
+			state.storage[key] = value
+			return true
+		})
+		states[addr] = state
+	}
+	return states
+}
+
+// hackerPermutations returns the orders to try for n transactions, without the identity.
+func hackerPermutations(n int) [][]int {
+	identity := make([]int, n)
+	for i := range identity {
+		identity[i] = i
+	}
+	orders := make([][]int, 0)
+	if n <= hackerTODExhaustive {
+		var permute func(prefix []int, rest []int)
+		permute = func(prefix []int, rest []int) {
+			if len(rest) == 0 {
+				orders = append(orders, append([]int{}, prefix...))
+				return
+			}
+			for i := range rest {
+				next := append(append([]int{}, rest[:i]...), rest[i+1:]...)
+				permute(append(prefix, rest[i]), next)
+			}
+		}
+		permute(nil, identity)
+		return orders[1:]
+	}
+	for i := 0; i+1 < n; i++ {
+		order := append([]int{}, identity...)
+		order[i], order[i+1] = order[i+1], order[i]
+		orders = append(orders, order)
+	}
+	reversed := make([]int, n)
+	for i := range reversed {
+		reversed[i] = n - 1 - i
+	}
+	return append(orders, reversed)
+}
+
+// TOD replays msgs in permuted orders and compares the final state of targets.
+func (harness *HackerHarness) TOD(msgs []*HackerMessage, targets []common.Address) *HackerTODReport {
+	report := &HackerTODReport{Targets: targets, Orders: make([][]int, 0), Slots: make([]string, 0), Balances: make([]common.Address, 0)}
+	if len(msgs) < 2 {
+		return report
+	}
+	run := func(order []int) (states map[common.Address]*hackerAccountState) {
+		harness.Fork(func() {
+			for _, i := range order {
+				msg := *msgs[i]
+				harness.Apply(&msg)
+			}
+			states = harness.accountStates(targets)
+		})
+		return states
+	}
+	identity := make([]int, len(msgs))
+	for i := range identity {
+		identity[i] = i
+	}
+	expected := run(identity)
+
+	slots := make(map[string]bool)
+	balances := make(map[common.Address]bool)
+	for _, order := range hackerPermutations(len(msgs)) {
+		diverged := false
+		for addr, state := range run(order) {
+			want := expected[addr]
+			if state.balance.Cmp(want.balance) != 0 {
+				balances[addr] = true
+				diverged = true
+			}
+			for _, slot := range diffStorageSlots(want.storage, state.storage) {
+				slots[addr.Hex()+":"+slot.Hex()] = true
+				diverged = true
+			}
+		}
+		if diverged {
+			report.Orders = append(report.Orders, order)
+		}
+	}
+	for slot := range slots {
+		report.Slots = append(report.Slots, slot)
+	}
+	sort.Strings(report.Slots)
+	for addr := range balances {
+		report.Balances = append(report.Balances, addr)
+	}
+	report.Divergent = len(report.Orders) > 0
+	return report
+}
+
+// Finding converts a divergent report into a "tod" finding.
+func (report *HackerTODReport) Finding() *HackerFinding {
+	if !report.Divergent {
+		return nil
+	}
+	finding := newHackerFinding("tod", "HackerTOD")
+	targets := make([]string, 0, len(report.Targets))
+	for _, target := range report.Targets {
+		targets = append(targets, target.Hex())
+	}
+	finding.Detail["targets"] = strings.Join(targets, ",")
+	finding.Detail["orders"] = fmt.Sprintf("%v", report.Orders)
+	finding.Detail["slots"] = strings.Join(report.Slots, ",")
+	balances := make([]string, 0, len(report.Balances))
+	for _, addr := range report.Balances {
+		balances = append(balances, addr.Hex())
+	}
+	finding.Detail["balances"] = strings.Join(balances, ",")
+	return finding
+}
+
+// hackerBlockTOD collects the watched transactions of a block for the TOD oracle.
+type hackerBlockTOD struct {
+	lock sync.Mutex
+	// harness runs on a copy of the state before the first transaction collected
+	harness *HackerHarness
+	txs     []*types.Transaction
+	msgs    []*HackerMessage
+	targets []common.Address
+}
+
+// add collects the transaction tx of env, before it runs.
+func (tod *hackerBlockTOD) add(env *EVM, tx *types.Transaction) {
+	tod.lock.Lock()
+	defer tod.lock.Unlock()
+	if len(tod.msgs) >= hackerTODTransactions {
+		return
+	}
+	if tod.harness == nil {
+		probe := hackerProbeEnv(env)
+		if probe == nil {
+			return
+		}
+		tod.harness = newHackerHarnessFrom(probe)
+	}
+	tod.txs = append(tod.txs, tx)
+	tod.msgs = append(tod.msgs, hackerTxMessage(env, tx))
+	for _, target := range tod.targets {
+		if target == *tx.To() {
+			return
+		}
+	}
+	tod.targets = append(tod.targets, *tx.To())
+}
+
+// finding replays the transactions collected in permuted orders, nil when there are
+// less than two of them or their order does not matter.
+func (tod *hackerBlockTOD) finding() *HackerFinding {
+	tod.lock.Lock()
+	defer tod.lock.Unlock()
+	if len(tod.msgs) < 2 {
+		return nil
+	}
+	return tod.harness.TOD(tod.msgs, tod.targets).Finding()
+}
+
+// diffStorageSlots returns the slots whose value differs between a and b.
+func diffStorageSlots(a, b map[common.Hash]common.Hash) []common.Hash {
+	slots := make([]common.Hash, 0)
+	for slot, value := range a {
+		if b[slot] != value {
+			slots = append(slots, slot)
+		}
+	}
+	for slot, value := range b {
+		if _, ok := a[slot]; !ok && value != (common.Hash{}) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
@@ -0,0 +1,51 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPermutations(t *testing.T) {
+	tests := []struct {
+		n      int
+		orders int
+	}{
+		{1, 0},
+		{2, 1},
+		{3, 5},
+		{4, 23},
+		// adjacent swaps and the reversed order
+		{5, 5},
+		{8, 8},
+	}
+	for _, test := range tests {
+		orders := hackerPermutations(test.n)
+		if len(orders) != test.orders {
+			t.Errorf("%d transactions: %d orders, want %d", test.n, len(orders), test.orders)
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, order := range orders {
+			used := make([]bool, test.n)
+			identity := true
+			for i, index := range order {
+				if index < 0 || index >= test.n || used[index] {
+					t.Fatalf("%d transactions: %v is not a permutation", test.n, order)
+				}
+				used[index] = true
+				identity = identity && index == i
+			}
+			if identity {
+				t.Errorf("%d transactions: the identity is tried", test.n)
+			}
+			if key := fmt.Sprint(order); seen[key] {
+				t.Errorf("%d transactions: %v tried twice", test.n, order)
+			} else {
+				seen[key] = true
+			}
+		}
+	}
+	if orders := hackerPermutations(5); fmt.Sprint(orders[4]) != "[4 3 2 1 0]" {
+		t.Errorf("last order %v, want the reversed one", orders[4])
+	}
+}
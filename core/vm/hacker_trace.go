@@ -0,0 +1,314 @@
+/**
+* @hacker_trace.go
+* Recording of the opcode trace and the storage of the watched contract.
+* 1 the trace is a preallocated ring of integers (pc<<8 | opcode), the strings sent to
+*   the fuzzer ("<pc><OPNAME>") are only formatted when the report is built.
+* 2 the storage is not rescanned before every opcode anymore: the slots of the watched
+*   contract are read once when recording starts and every SSTORE into it is appended
+*   to a second ring. storage_old/storage_new are rebuilt from both when needed.
+* 3 nothing here allocates once the rings exist, the rings are reused across transactions.
+* When a ring is full the oldest entries are overwritten and counted as dropped.
+* 4 every trace entry, storage write and log is recorded with the identifier of its
+*   frame, the index of the frame in the journal (hacker_journal.go): identifiers grow
+*   in the order the frames are entered and are -1 when the journal is off. The report
+*   carries them ("traceFrames", "storageWrites", "logs") so that its sections can be
+*   joined with "frames".
+* 5 the trace can be sampled by class of operation (hacker_tracesampling.go), the
+*   entries left out are counted as skipped.
+* 6 a WatchDog is written by the goroutine executing its watched transaction alone:
+*   the hooks of the other EVMs tell it is not theirs from its armed EVM (watches),
+*   an atomic. What the other goroutines read (State, Findings, Watching and the
+*   report of a fork) is written under the lock of the WatchDog: the state, the trace,
+*   the storage and the findings. The step budget, the progress threshold, set from
+*   the API, and the switch of the recording (turnOn), read by every hook, are atomics.
+ */
+package vm
+
+import (
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// hackerTraceCapacity is the number of opcodes kept per WatchDog (8 bytes each).
+	hackerTraceCapacity = 1 << 20
+	// hackerStorageCapacity is the number of storage writes kept per WatchDog.
+	hackerStorageCapacity = 1 << 16
+)
+
+// hackerTraceRing is a ring of opcodes encoded as pc<<8 | op, with their frames.
+type hackerTraceRing struct {
+	entries []uint64
+	frames  []int32
+	next    int
+	count   int
+	dropped uint64
+	// skipped counts the entries left out by the sampling (hacker_tracesampling.go)
+	skipped uint64
+}
+
+func newHackerTraceRing(capacity int) *hackerTraceRing {
+	return &hackerTraceRing{entries: make([]uint64, capacity), frames: make([]int32, capacity)}
+}
+
+func (ring *hackerTraceRing) reset() {
+	ring.next, ring.count, ring.dropped, ring.skipped = 0, 0, 0, 0
+}
+
+func (ring *hackerTraceRing) push(pc uint64, op OpCode, frame int) {
+	ring.entries[ring.next] = pc<<8 | uint64(op)
+	ring.frames[ring.next] = int32(frame)
+	ring.next++
+	if ring.next == len(ring.entries) {
+		ring.next = 0
+	}
+	if ring.count < len(ring.entries) {
+		ring.count++
+	} else {
+		ring.dropped++
+	}
+}
+
+func (ring *hackerTraceRing) len() int {
+	return ring.count
+}
+
+// steps returns the number of entries pushed or skipped since the reset.
+func (ring *hackerTraceRing) steps() uint64 {
+	return uint64(ring.count) + ring.dropped + ring.skipped
+}
+
+// each calls fn on the entries from the oldest to the newest.
+func (ring *hackerTraceRing) each(fn func(pc uint64, op OpCode, frame int)) {
+	start := ring.next - ring.count
+	if start < 0 {
+		start += len(ring.entries)
+	}
+	for i := 0; i < ring.count; i++ {
+		index := (start + i) % len(ring.entries)
+		entry := ring.entries[index]
+		fn(entry>>8, OpCode(entry&0xff), int(ring.frames[index]))
+	}
+}
+
+// strings formats the trace the way the fuzzer expects it.
+func (ring *hackerTraceRing) strings() []string {
+	trace := make([]string, 0, ring.count)
+	ring.each(func(pc uint64, op OpCode, frame int) {
+		trace = append(trace, strconv.FormatUint(pc, 10)+opCodeToString[op])
+	})
+	return trace
+}
+
+// frameIDs returns the frame of every entry of strings.
+func (ring *hackerTraceRing) frameIDs() []int {
+	frames := make([]int, 0, ring.count)
+	ring.each(func(pc uint64, op OpCode, frame int) {
+		frames = append(frames, frame)
+	})
+	return frames
+}
+
+type hackerStorageWrite struct {
+	frame int
+	slot  common.Hash
+	value common.Hash
+}
+
+// hackerStorageRing is a ring of storage writes.
+type hackerStorageRing struct {
+	writes  []hackerStorageWrite
+	next    int
+	count   int
+	dropped uint64
+}
+
+func newHackerStorageRing(capacity int) *hackerStorageRing {
+	return &hackerStorageRing{writes: make([]hackerStorageWrite, capacity)}
+}
+
+func (ring *hackerStorageRing) reset() {
+	ring.next, ring.count, ring.dropped = 0, 0, 0
+}
+
+func (ring *hackerStorageRing) push(frame int, slot, value common.Hash) {
+	ring.writes[ring.next] = hackerStorageWrite{frame: frame, slot: slot, value: value}
+	ring.next++
+	if ring.next == len(ring.writes) {
+		ring.next = 0
+	}
+	if ring.count < len(ring.writes) {
+		ring.count++
+	} else {
+		ring.dropped++
+	}
+}
+
+func (ring *hackerStorageRing) each(fn func(write *hackerStorageWrite)) {
+	start := ring.next - ring.count
+	if start < 0 {
+		start += len(ring.writes)
+	}
+	for i := 0; i < ring.count; i++ {
+		fn(&ring.writes[(start+i)%len(ring.writes)])
+	}
+}
+
+// setTurnOn turns the recording on or off.
+func (dog *WatchDog) setTurnOn(on bool) {
+	var turnOn int32
+	if on {
+		turnOn = 1
+	}
+	atomic.StoreInt32(&dog.turnOn, turnOn)
+}
+
+// watching tells whether the recording is on, from any goroutine.
+func (dog *WatchDog) watching() bool {
+	return atomic.LoadInt32(&dog.turnOn) == 1
+}
+
+// arm records env as the EVM of the watched transaction, nil when none is watched.
+func (dog *WatchDog) arm(env *EVM) {
+	dog.armed.Store(env)
+}
+
+// watches tells whether the WatchDog watches a transaction executed by evm, from any
+// goroutine.
+func (dog *WatchDog) watches(evm *EVM) bool {
+	env, _ := dog.armed.Load().(*EVM)
+	return env != nil && env == evm
+}
+
+// resetRecording prepares the rings for a new transaction, allocating them the first time.
+func (dog *WatchDog) resetRecording() {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	if dog.trace == nil {
+		dog.trace = newHackerTraceRing(hackerTraceCapacity)
+		dog.writes = newHackerStorageRing(hackerStorageCapacity)
+	}
+	dog.trace.reset()
+	dog.writes.reset()
+	dog.logs = dog.logs[:0]
+	dog.console = nil
+	dog.edges = make(map[hackerCoverageEdge]struct{})
+	dog.annotated, dog.at = make(map[common.Hash]*hackerCodeAnnotations), hackerPcKey{}
+	dog.hits = nil
+	dog.storageLoaded = false
+	dog.storage_old = make(map[common.Hash]common.Hash)
+	dog.storage_new = make(map[common.Hash]common.Hash)
+}
+
+// record is called before every opcode of a watched execution, running in frame.
+func (dog *WatchDog) record(pc uint64, op OpCode, frame int, contract *Contract, memory *Memory, stack *Stack) {
+	if !dog.storageLoaded {
+		dog.lock.Lock()
+		dog.storageLoaded = true
+		dog.env.StateDB.ForEachStorage(*dog.tx.To(), func(key, value common.Hash) bool {
+			dog.storage_old[key] = value
+			return true
+		})
+		dog.lock.Unlock()
+	}
+	dog.Write2Trace(pc, op, frame)
+	dog.at = hackerPcKey{contract.CodeHash, pc}
+	if dog.revert != nil {
+		dog.revert.record(dog.env.StateDB, op, contract, stack)
+	}
+	switch {
+	case op == SSTORE && stack.len() >= 2 && contract.Address() == *dog.tx.To():
+		dog.Write2Storage(frame, common.BigToHash(stack.Back(0)), common.BigToHash(stack.Back(1)))
+	case op >= LOG0 && op <= LOG4 && stack.len() >= int(op-LOG0)+2:
+		record := HackerLogRecord{Frame: frame, Address: contract.Address(), Topics: make([]common.Hash, 0, int(op-LOG0))}
+		for i := 0; i < int(op-LOG0); i++ {
+			record.Topics = append(record.Topics, common.BigToHash(stack.Back(2+i)))
+		}
+		// the memory was expanded to the data before the operation
+		if offset, size := stack.Back(0), stack.Back(1); size.Sign() != 0 && offset.BitLen() <= 63 && size.BitLen() <= 63 && offset.Int64()+size.Int64() <= int64(memory.Len()) {
+			record.Data = hex.EncodeToString(memory.GetPtr(offset.Int64(), size.Int64()))
+		}
+		dog.lock.Lock()
+		dog.logs = append(dog.logs, record)
+		dog.lock.Unlock()
+	case op == SELFDESTRUCT:
+		dog.recordSelfDestruct(contract)
+	case op == STATICCALL || op == CALL:
+		dog.recordConsole(op, frame, contract, memory, stack)
+	case op == JUMP || op == JUMPI:
+		dog.recordBranch(pc, op, contract, stack)
+		dog.annotate(pc, op, contract, stack)
+	case op == EQ || op == LT || op == GT || op == SLT || op == SGT:
+		dog.annotate(pc, op, contract, stack)
+	}
+}
+
+// HackerStorageWrite is an SSTORE into the watched contract, in the order executed.
+type HackerStorageWrite struct {
+	Frame int         `json:"frame"`
+	Slot  common.Hash `json:"slot"`
+	Value common.Hash `json:"value"`
+}
+
+// HackerLogRecord is a log emitted during the watched transaction, the logs of failed
+// frames included.
+type HackerLogRecord struct {
+	Frame   int            `json:"frame"`
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    string         `json:"data"`
+}
+
+// storageWrites returns the writes recorded since the last call to storage.
+func (dog *WatchDog) storageWrites() []HackerStorageWrite {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	writes := make([]HackerStorageWrite, 0)
+	if dog.writes == nil {
+		return writes
+	}
+	dog.writes.each(func(write *hackerStorageWrite) {
+		writes = append(writes, HackerStorageWrite{Frame: write.frame, Slot: write.slot, Value: write.value})
+	})
+	return writes
+}
+
+// logRecords returns the logs recorded during the watched transaction.
+func (dog *WatchDog) logRecords() []HackerLogRecord {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	return append(make([]HackerLogRecord, 0, len(dog.logs)), dog.logs...)
+}
+
+// storage rebuilds storage_old (first value seen per slot) and storage_new (last value
+// differing from it) from the initial slots and the writes.
+func (dog *WatchDog) storage() (map[common.Hash]common.Hash, map[common.Hash]common.Hash) {
+	dog.lock.Lock()
+	defer dog.lock.Unlock()
+	if dog.writes == nil {
+		return dog.storage_old, dog.storage_new
+	}
+	dog.writes.each(func(write *hackerStorageWrite) {
+		if old, ok := dog.storage_old[write.slot]; !ok {
+			dog.storage_old[write.slot] = write.value
+		} else if old != write.value {
+			dog.storage_new[write.slot] = write.value
+		}
+	})
+	dog.writes.reset()
+	// writes of reverted frames were recorded too, keep what the state ended with
+	if dog.env != nil && dog.tx != nil {
+		for slot := range dog.storage_new {
+			value := dog.env.StateDB.GetState(*dog.tx.To(), slot)
+			if value == dog.storage_old[slot] {
+				delete(dog.storage_new, slot)
+			} else {
+				dog.storage_new[slot] = value
+			}
+		}
+	}
+	return dog.storage_old, dog.storage_new
+}
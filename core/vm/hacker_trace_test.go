@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTraceRing(t *testing.T) {
+	ring := newHackerTraceRing(3)
+	for pc := uint64(0); pc < 5; pc++ {
+		ring.push(pc, ADD, int(pc))
+	}
+	if ring.len() != 3 || ring.dropped != 2 {
+		t.Fatalf("len %d dropped %d", ring.len(), ring.dropped)
+	}
+	trace := ring.strings()
+	if trace[0] != "2ADD" || trace[2] != "4ADD" {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+	if frames := ring.frameIDs(); len(frames) != 3 || frames[0] != 2 || frames[2] != 4 {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+}
+
+func TestStorageReplay(t *testing.T) {
+	dog := newWatchDog()
+	dog.resetRecording()
+	slot := common.HexToHash("0x01")
+	dog.storage_old[slot] = common.HexToHash("0x0a")
+	dog.writes.push(0, slot, common.HexToHash("0x0b"))
+	dog.writes.push(1, common.HexToHash("0x02"), common.HexToHash("0x0c"))
+	old, changed := dog.storage()
+	if changed[slot] != common.HexToHash("0x0b") || old[slot] != common.HexToHash("0x0a") {
+		t.Fatalf("slot 1: old %x new %x", old[slot], changed[slot])
+	}
+	if _, ok := changed[common.HexToHash("0x02")]; ok || old[common.HexToHash("0x02")] != common.HexToHash("0x0c") {
+		t.Fatal("first write of a slot must be its first observed value")
+	}
+}
+
+// benchmarkSlots is the number of slots of the watched contract in the benchmarks.
+const benchmarkSlots = 16
+
+// BenchmarkTraceLegacy records like the former hot path: a formatted string per opcode
+// and every slot merged into the storage maps under the global mutex.
+func BenchmarkTraceLegacy(b *testing.B) {
+	var (
+		lock    sync.Mutex
+		trace   = make([]string, 0)
+		storage = make(map[common.Hash]common.Hash)
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		trace = append(trace, strconv.FormatUint(uint64(i), 10)+opCodeToString[ADD])
+		for slot := 0; slot < benchmarkSlots; slot++ {
+			lock.Lock()
+			storage[common.BigToHash(new(big.Int).SetInt64(int64(slot)))] = common.Hash{}
+			lock.Unlock()
+		}
+	}
+}
+
+func BenchmarkTraceRing(b *testing.B) {
+	ring := newHackerTraceRing(hackerTraceCapacity)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.push(uint64(i), ADD, 0)
+	}
+}
+
+// TestParallelWatchDogs records into several WatchDogs from parallel goroutines while
+// their storage is read, run it with -race.
+func TestParallelWatchDogs(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		dog := newWatchDog()
+		dog.resetRecording()
+		dog.setTurnOn(true)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for slot := int64(0); slot < 1000; slot++ {
+				dog.Write2Storage(0, common.BigToHash(big.NewInt(slot%10)), common.BigToHash(big.NewInt(slot)))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				dog.storage()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,175 @@
+/**
+* @hacker_tracesampling.go
+* Subsampling of the opcode trace by class of operation, so that the trace of a
+* loop-heavy contract stays bounded (SetTraceSampling).
+* 1 the operations are grouped in classes (hackerTraceClassNames), a class sampled
+*   every N keeps one operation of N of that class in the trace, e.g. one arithmetic
+*   operation of 16 but all the calls and storage accesses. The classes not sampled
+*   are kept whole.
+* 2 only the trace is sampled: the storage writes, logs, coverage, annotations and
+*   detectors still see every operation, and the steps (console logs, bundles) count
+*   the skipped ones.
+* 3 the report of a sampled transaction carries the operations skipped by class
+*   ("traceSkipped"), the sampling is part of the instrumentation of its env.
+ */
+package vm
+
+import (
+	"fmt"
+)
+
+const (
+	hackerTraceArithmetic = iota
+	hackerTraceComparison
+	hackerTraceBitwise
+	hackerTraceSha3
+	hackerTraceEnvironment
+	hackerTraceStack
+	hackerTraceMemory
+	hackerTraceStorage
+	hackerTraceFlow
+	hackerTraceLog
+	hackerTraceSystem
+	hackerTraceClassCount
+)
+
+var hackerTraceClassNames = [hackerTraceClassCount]string{"arithmetic", "comparison", "bitwise", "sha3", "environment", "stack", "memory", "storage", "flow", "log", "system"}
+
+// hackerTraceClass returns the class of op.
+func hackerTraceClass(op OpCode) int {
+	switch {
+	case op >= ADD && op <= SIGNEXTEND:
+		return hackerTraceArithmetic
+	case op >= LT && op <= ISZERO:
+		return hackerTraceComparison
+	case op >= AND && op <= 0x1d:
+		return hackerTraceBitwise
+	case op == SHA3:
+		return hackerTraceSha3
+	case op >= 0x30 && op <= 0x4f:
+		return hackerTraceEnvironment
+	case op == POP || op >= 0x5f && op <= 0x9f:
+		return hackerTraceStack
+	case op == MLOAD || op == MSTORE || op == MSTORE8 || op == MSIZE || op == 0x5e:
+		return hackerTraceMemory
+	case op == SLOAD || op == SSTORE || op == 0x5c || op == 0x5d:
+		return hackerTraceStorage
+	case op >= JUMP && op <= JUMPDEST:
+		return hackerTraceFlow
+	case op >= LOG0 && op <= LOG4:
+		return hackerTraceLog
+	}
+	// STOP, the calls, creations, returns and the undefined operations
+	return hackerTraceSystem
+}
+
+// hackerTraceSampler samples the trace of a watched transaction.
+type hackerTraceSampler struct {
+	every   [hackerTraceClassCount]uint64
+	seen    [hackerTraceClassCount]uint64
+	skipped [hackerTraceClassCount]uint64
+}
+
+// keep tells whether op goes into the trace, counting it.
+func (sampler *hackerTraceSampler) keep(op OpCode) bool {
+	class := hackerTraceClass(op)
+	every := sampler.every[class]
+	if every <= 1 {
+		return true
+	}
+	seen := sampler.seen[class]
+	sampler.seen[class]++
+	if seen%every == 0 {
+		return true
+	}
+	sampler.skipped[class]++
+	return false
+}
+
+// SetTraceSampling samples the trace of the watched transactions: a class of
+// operations mapped to N keeps one operation of N of that class, nil keeps them all.
+func (c *HackerCampaign) SetTraceSampling(sampling map[string]uint64) error {
+	var every map[string]uint64
+	for class, n := range sampling {
+		known := false
+		for _, name := range hackerTraceClassNames {
+			known = known || name == class
+		}
+		if !known {
+			return fmt.Errorf("unknown operation class %q", class)
+		}
+		if n > 1 {
+			if every == nil {
+				every = make(map[string]uint64)
+			}
+			every[class] = n
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.traceSampling = every
+	return nil
+}
+
+// TraceSampling returns the sampling of the trace by class, nil when the trace is kept
+// whole.
+func (c *HackerCampaign) TraceSampling() map[string]uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.traceSampling == nil {
+		return nil
+	}
+	sampling := make(map[string]uint64, len(c.traceSampling))
+	for class, n := range c.traceSampling {
+		sampling[class] = n
+	}
+	return sampling
+}
+
+// traceSampler returns the sampler of the next watched transaction, nil when the trace
+// is kept whole.
+func (c *HackerCampaign) traceSampler() *hackerTraceSampler {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.traceSampling == nil {
+		return nil
+	}
+	sampler := new(hackerTraceSampler)
+	for i, name := range hackerTraceClassNames {
+		sampler.every[i] = c.traceSampling[name]
+	}
+	return sampler
+}
+
+// traceSkipped returns the operations left out of the trace of the watched transaction
+// by class, nil when none was.
+func (dog *WatchDog) traceSkipped() map[string]uint64 {
+	if dog.sampler == nil {
+		return nil
+	}
+	var skipped map[string]uint64
+	for i, n := range dog.sampler.skipped {
+		if n != 0 {
+			if skipped == nil {
+				skipped = make(map[string]uint64)
+			}
+			skipped[hackerTraceClassNames[i]] = n
+		}
+	}
+	return skipped
+}
+
+// traceSampling returns the sampling of the watched transaction by class, nil when
+// its trace is kept whole.
+func (dog *WatchDog) traceSampling() map[string]uint64 {
+	if dog.sampler == nil {
+		return nil
+	}
+	sampling := make(map[string]uint64)
+	for i, n := range dog.sampler.every {
+		if n > 1 {
+			sampling[hackerTraceClassNames[i]] = n
+		}
+	}
+	return sampling
+}
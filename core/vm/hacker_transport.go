@@ -0,0 +1,76 @@
+/**
+* @hacker_transport.go
+* Transport of the instrumentation. core/vm only produces the reports, alerts, progress,
+* summaries, oracle features (hacker_close) and coordinator synchronisations, the
+* requests carrying them go through the HackerTransport installed with SetTransport.
+* 1 no transport is installed when the package is imported: nothing leaves the process,
+*   every request fails with errNoTransport, and the interpreter with its
+*   instrumentation embeds in tools without a node, e.g. transaction simulators.
+* 2 the node installs the HTTP transport of fuzz/report (fuzz.Config.Apply).
+ */
+package vm
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// hackerStatusOK is the status code of a successful response.
+const hackerStatusOK = 200
+
+var errNoTransport = errors.New("no transport installed")
+
+// HackerTransport carries the requests of the instrumentation.
+type HackerTransport interface {
+	// Get sends a GET request to url, its response is discarded.
+	Get(url string) error
+	// AcceptPost returns the Accept-Post header of the OPTIONS response of url.
+	AcceptPost(url string) (string, error)
+	// Post posts body to url and returns the status code and the body of the
+	// response, zero timeout waits for it as long as it takes.
+	Post(url, contentType string, body io.Reader, timeout time.Duration) (int, []byte, error)
+}
+
+var (
+	hackerTransportLock sync.RWMutex
+	hackerTransport     HackerTransport
+)
+
+// SetTransport installs the transport of the instrumentation, nil drops every request.
+func SetTransport(transport HackerTransport) {
+	hackerTransportLock.Lock()
+	defer hackerTransportLock.Unlock()
+	hackerTransport = transport
+}
+
+func currentTransport() HackerTransport {
+	hackerTransportLock.RLock()
+	defer hackerTransportLock.RUnlock()
+	return hackerTransport
+}
+
+func hackerGet(url string) error {
+	transport := currentTransport()
+	if transport == nil {
+		return errNoTransport
+	}
+	return transport.Get(url)
+}
+
+func hackerAcceptPost(url string) (string, error) {
+	transport := currentTransport()
+	if transport == nil {
+		return "", errNoTransport
+	}
+	return transport.AcceptPost(url)
+}
+
+func hackerPost(url, contentType string, body io.Reader, timeout time.Duration) (int, []byte, error) {
+	transport := currentTransport()
+	if transport == nil {
+		return 0, nil, errNoTransport
+	}
+	return transport.Post(url, contentType, body, timeout)
+}
@@ -0,0 +1,249 @@
+/**
+* @hacker_typedtx.go
+* Typed transactions (EIP-2718) for the instrumented execution path.
+* 1 an envelope is a legacy transaction (an RLP list) or a type byte followed by its
+*   payload: 0x01 access list transactions (EIP-2930), 0x02 dynamic fee transactions
+*   (EIP-1559). The hash of a typed transaction is the hash of its envelope.
+* 2 the types of this tree predate typed transactions, a typed transaction is watched
+*   through a legacy carrier with the same nonce, destination, value, gas and data.
+* 3 the accounts and slots of the access list are warm from the start of the
+*   transaction (EIP-2929), the report carries them with the fee parameters ("tx").
+ */
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	HackerLegacyTxType     = 0x00
+	HackerAccessListTxType = 0x01
+	HackerDynamicFeeTxType = 0x02
+)
+
+var (
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+	ErrEmptyTxEnvelope    = errors.New("empty transaction envelope")
+)
+
+// HackerAccessTuple is an entry of an access list (EIP-2930).
+type HackerAccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// HackerAccessList is the access list of a transaction.
+type HackerAccessList []HackerAccessTuple
+
+// HackerTypedTx is a decoded transaction envelope. GasPrice is set for the legacy and
+// access list transactions, GasFeeCap and GasTipCap for the dynamic fee ones.
+type HackerTypedTx struct {
+	Type       uint8
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList HackerAccessList
+	V, R, S    *big.Int
+}
+
+// the RLP payloads of the transaction types
+type hackerLegacyTxData struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       *common.Address `rlp:"nil"`
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+type hackerAccessListTxData struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList HackerAccessList
+	V, R, S    *big.Int
+}
+
+type hackerDynamicFeeTxData struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList HackerAccessList
+	V, R, S    *big.Int
+}
+
+// DecodeHackerTypedTx decodes a transaction envelope.
+func DecodeHackerTypedTx(envelope []byte) (*HackerTypedTx, error) {
+	if len(envelope) == 0 {
+		return nil, ErrEmptyTxEnvelope
+	}
+	// a legacy transaction is an RLP list
+	if envelope[0] >= 0xc0 {
+		var data hackerLegacyTxData
+		if err := rlp.DecodeBytes(envelope, &data); err != nil {
+			return nil, fmt.Errorf("legacy transaction: %v", err)
+		}
+		return &HackerTypedTx{Type: HackerLegacyTxType, Nonce: data.Nonce, GasPrice: data.GasPrice, Gas: data.Gas, To: data.To, Value: data.Value, Data: data.Data, V: data.V, R: data.R, S: data.S}, nil
+	}
+	switch envelope[0] {
+	case HackerAccessListTxType:
+		var data hackerAccessListTxData
+		if err := rlp.DecodeBytes(envelope[1:], &data); err != nil {
+			return nil, fmt.Errorf("access list transaction: %v", err)
+		}
+		return &HackerTypedTx{Type: HackerAccessListTxType, ChainID: data.ChainID, Nonce: data.Nonce, GasPrice: data.GasPrice, Gas: data.Gas, To: data.To, Value: data.Value, Data: data.Data, AccessList: data.AccessList, V: data.V, R: data.R, S: data.S}, nil
+	case HackerDynamicFeeTxType:
+		var data hackerDynamicFeeTxData
+		if err := rlp.DecodeBytes(envelope[1:], &data); err != nil {
+			return nil, fmt.Errorf("dynamic fee transaction: %v", err)
+		}
+		return &HackerTypedTx{Type: HackerDynamicFeeTxType, ChainID: data.ChainID, Nonce: data.Nonce, GasTipCap: data.GasTipCap, GasFeeCap: data.GasFeeCap, Gas: data.Gas, To: data.To, Value: data.Value, Data: data.Data, AccessList: data.AccessList, V: data.V, R: data.R, S: data.S}, nil
+	}
+	return nil, ErrTxTypeNotSupported
+}
+
+// Encode returns the envelope of tx.
+func (tx *HackerTypedTx) Encode() ([]byte, error) {
+	switch tx.Type {
+	case HackerLegacyTxType:
+		return rlp.EncodeToBytes(&hackerLegacyTxData{Nonce: tx.Nonce, GasPrice: tx.GasPrice, Gas: tx.Gas, To: tx.To, Value: tx.Value, Data: tx.Data, V: tx.V, R: tx.R, S: tx.S})
+	case HackerAccessListTxType:
+		payload, err := rlp.EncodeToBytes(&hackerAccessListTxData{ChainID: tx.ChainID, Nonce: tx.Nonce, GasPrice: tx.GasPrice, Gas: tx.Gas, To: tx.To, Value: tx.Value, Data: tx.Data, AccessList: tx.AccessList, V: tx.V, R: tx.R, S: tx.S})
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{HackerAccessListTxType}, payload...), nil
+	case HackerDynamicFeeTxType:
+		payload, err := rlp.EncodeToBytes(&hackerDynamicFeeTxData{ChainID: tx.ChainID, Nonce: tx.Nonce, GasTipCap: tx.GasTipCap, GasFeeCap: tx.GasFeeCap, Gas: tx.Gas, To: tx.To, Value: tx.Value, Data: tx.Data, AccessList: tx.AccessList, V: tx.V, R: tx.R, S: tx.S})
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{HackerDynamicFeeTxType}, payload...), nil
+	}
+	return nil, ErrTxTypeNotSupported
+}
+
+// Hash returns the hash of the envelope of tx.
+func (tx *HackerTypedTx) Hash() common.Hash {
+	envelope, err := tx.Encode()
+	if err != nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(envelope)
+}
+
+// Message returns the message of tx sent by from. A legacy or access list transaction
+// pays its gas price whatever the base fee, as a dynamic fee message capped by it.
+func (tx *HackerTypedTx) Message(from common.Address) *HackerMessage {
+	msg := &HackerMessage{From: from, To: tx.To, Value: tx.Value, Gas: tx.Gas, Data: tx.Data, AccessList: tx.AccessList}
+	if tx.Type == HackerDynamicFeeTxType {
+		msg.GasFeeCap, msg.GasTipCap = tx.GasFeeCap, tx.GasTipCap
+	} else if tx.GasPrice != nil {
+		msg.GasFeeCap, msg.GasTipCap = tx.GasPrice, tx.GasPrice
+	}
+	return msg
+}
+
+// Legacy returns the legacy carrier of tx, the transaction the WatchDog watches.
+func (tx *HackerTypedTx) Legacy() *types.Transaction {
+	var to common.Address
+	if tx.To != nil {
+		to = *tx.To
+	}
+	value := tx.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	price := tx.GasPrice
+	if tx.Type == HackerDynamicFeeTxType {
+		price = tx.GasFeeCap
+	}
+	if price == nil {
+		price = new(big.Int)
+	}
+	if tx.To == nil {
+		return types.NewContractCreation(tx.Nonce, value, new(big.Int).SetUint64(tx.Gas), price, tx.Data)
+	}
+	return types.NewTransaction(tx.Nonce, to, value, new(big.Int).SetUint64(tx.Gas), price, tx.Data)
+}
+
+// WarmAccessList adds the accounts and slots of list to the access list of the
+// transaction, call it before the message call.
+func (evm *EVM) WarmAccessList(list HackerAccessList) {
+	for _, tuple := range list {
+		evm.accessList.addAddress(tuple.Address)
+		for _, slot := range tuple.StorageKeys {
+			evm.accessList.addSlot(tuple.Address, slot)
+		}
+	}
+}
+
+// HackerTx is the "tx" section of the WatchDog report, sent for the transactions
+// watched with WatchTyped.
+type HackerTx struct {
+	Type       uint8            `json:"type"`
+	ChainID    string           `json:"chainId,omitempty"`
+	GasPrice   string           `json:"gasPrice,omitempty"`
+	GasFeeCap  string           `json:"maxFeePerGas,omitempty"`
+	GasTipCap  string           `json:"maxPriorityFeePerGas,omitempty"`
+	AccessList HackerAccessList `json:"accessList"`
+}
+
+// WatchTyped watches the typed transaction tx executed by env.
+func (dog *WatchDog) WatchTyped(env *EVM, tx *HackerTypedTx) {
+	carrier := tx.Legacy()
+	dog.Watch(env, carrier)
+	watcher := dog
+	if fork := dog.forkOf(carrier.Hash()); fork != nil {
+		watcher = fork
+		dog.alias(fork, tx.Hash())
+	}
+	if watcher.watching() && watcher.tx == carrier {
+		watcher.typed = tx
+	}
+}
+
+// typedTx returns the "tx" section of the watched transaction, nil when it was watched
+// as a legacy transaction.
+func (dog *WatchDog) typedTx() *HackerTx {
+	tx := dog.typed
+	if tx == nil {
+		return nil
+	}
+	section := &HackerTx{Type: tx.Type, AccessList: tx.AccessList}
+	if section.AccessList == nil {
+		section.AccessList = HackerAccessList{}
+	}
+	for _, field := range []struct {
+		value *big.Int
+		text  *string
+	}{{tx.ChainID, &section.ChainID}, {tx.GasPrice, &section.GasPrice}, {tx.GasFeeCap, &section.GasFeeCap}, {tx.GasTipCap, &section.GasTipCap}} {
+		if field.value != nil {
+			*field.text = field.value.Text(10)
+		}
+	}
+	return section
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTypedTxEnvelope(t *testing.T) {
+	to := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	txs := []*HackerTypedTx{
+		{Type: HackerLegacyTxType, Nonce: 3, GasPrice: big.NewInt(5), Gas: 21000, To: &to, Value: big.NewInt(1), Data: []byte{0xde, 0xad}, V: big.NewInt(27), R: big.NewInt(1), S: big.NewInt(2)},
+		{Type: HackerAccessListTxType, ChainID: big.NewInt(1), GasPrice: big.NewInt(5), Gas: 21000, To: &to, Value: new(big.Int), AccessList: HackerAccessList{{Address: to, StorageKeys: []common.Hash{common.HexToHash("0x01")}}}, V: new(big.Int), R: big.NewInt(1), S: big.NewInt(2)},
+		{Type: HackerDynamicFeeTxType, ChainID: big.NewInt(1), GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(9), Gas: 21000, Value: new(big.Int), V: big.NewInt(1), R: big.NewInt(1), S: big.NewInt(2)},
+	}
+	for _, tx := range txs {
+		envelope, err := tx.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Type != HackerLegacyTxType && envelope[0] != tx.Type {
+			t.Errorf("type %d envelope starts with %#x", tx.Type, envelope[0])
+		}
+		decoded, err := DecodeHackerTypedTx(envelope)
+		if err != nil {
+			t.Fatalf("type %d: %v", tx.Type, err)
+		}
+		reencoded, _ := decoded.Encode()
+		if !bytes.Equal(envelope, reencoded) || decoded.Hash() != tx.Hash() {
+			t.Errorf("type %d envelope %x decoded as %x", tx.Type, envelope, reencoded)
+		}
+		if (decoded.To == nil) != (tx.To == nil) || len(decoded.AccessList) != len(tx.AccessList) {
+			t.Errorf("type %d decoded %+v, want %+v", tx.Type, decoded, tx)
+		}
+	}
+	if _, err := DecodeHackerTypedTx(nil); err != ErrEmptyTxEnvelope {
+		t.Errorf("error %v, want %v", err, ErrEmptyTxEnvelope)
+	}
+	if _, err := DecodeHackerTypedTx([]byte{0x7f}); err != ErrTxTypeNotSupported {
+		t.Errorf("error %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
@@ -0,0 +1,71 @@
+/**
+* @hacker_upgrade.go
+* Oracle: proxy upgrades.
+* Any write of the watched transaction to a proxy slot (EIP-1967 implementation, beacon
+* and admin slots, EIP-1822 PROXIABLE slot) that is kept at the end is reported as a
+* "proxy_upgrade" finding with the old and new value, the writer (msg.sender of the code
+* that wrote, delegate calls included) and the admin recorded in the EIP-1967 admin slot
+* before the transaction. "authorized" is "false" when the writer is not that admin, the
+* upgrade path is then open to anyone, and "unknown" when the proxy records no admin
+* (EIP-1822 proxies keep the access control in the implementation).
+ */
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// keccak256("eip1967.proxy.admin") - 1
+var eip1967AdminSlot = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+
+var proxySlots = map[common.Hash]string{
+	eip1967ImplementationSlot: "implementation",
+	eip1967BeaconSlot:         "beacon",
+	eip1967AdminSlot:          "admin",
+	eip1822ProxiableSlot:      "proxiable",
+}
+
+// checkUpgrade reports the proxy slots changed by the live frames of the transaction.
+func (taint *HackerTaint) checkUpgrade(statedb StateDB) []*HackerFinding {
+	recorded := taint.erc20
+	if len(recorded.writes) == 0 {
+		return nil
+	}
+	live := taint.journal.live()
+	last := make(map[hackerTaintStorageKey]hackerERC20Write)
+	for _, write := range recorded.writes {
+		if _, ok := proxySlots[write.key.slot]; ok && live[write.frame] {
+			last[write.key] = write
+		}
+	}
+	findings := make([]*HackerFinding, 0)
+	for key, write := range last {
+		old := recorded.old[key]
+		if old == write.value {
+			continue
+		}
+		admin, ok := recorded.old[hackerTaintStorageKey{key.addr, eip1967AdminSlot}]
+		if !ok && statedb != nil {
+			admin = statedb.GetState(key.addr, eip1967AdminSlot)
+		}
+		finding := newHackerFinding("proxy_upgrade", "HackerUpgrade")
+		finding.Detail["contract"] = key.addr.Hex()
+		finding.Detail["slot"] = proxySlots[key.slot]
+		finding.Detail["old"] = common.BytesToAddress(old.Bytes()).Hex()
+		finding.Detail["new"] = common.BytesToAddress(write.value.Bytes()).Hex()
+		writer := taint.journal.frames[write.frame].Caller()
+		finding.Detail["writer"] = writer.Hex()
+		switch {
+		case admin == (common.Hash{}):
+			finding.Detail["authorized"] = "unknown"
+		case common.BytesToAddress(admin.Bytes()) == writer:
+			finding.Detail["admin"] = common.BytesToAddress(admin.Bytes()).Hex()
+			finding.Detail["authorized"] = "true"
+		default:
+			finding.Detail["admin"] = common.BytesToAddress(admin.Bytes()).Hex()
+			finding.Detail["authorized"] = "false"
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
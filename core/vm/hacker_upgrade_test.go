@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestUpgradeOracle(t *testing.T) {
+	var (
+		admin    = common.HexToAddress("0x01")
+		attacker = common.HexToAddress("0x02")
+		proxy    = common.HexToAddress("0x03")
+		newImpl  = common.HexToAddress("0x04")
+	)
+	upgrade := func(writer common.Address) []*HackerFinding {
+		taint := newHackerTaint()
+		call := enterTestFrame(taint, writer, proxy, nil)
+		taint.erc20.old[hackerTaintStorageKey{proxy, eip1967AdminSlot}] = common.BytesToHash(admin.Bytes())
+		taint.erc20.old[hackerTaintStorageKey{proxy, eip1967ImplementationSlot}] = common.Hash{}
+		taint.erc20.writes = append(taint.erc20.writes, hackerERC20Write{
+			frame: call, key: hackerTaintStorageKey{proxy, eip1967ImplementationSlot}, value: common.BytesToHash(newImpl.Bytes()),
+		})
+		return taint.checkUpgrade(poolStateDB{})
+	}
+	findings := upgrade(attacker)
+	if len(findings) != 1 || findings[0].Detail["authorized"] != "false" || findings[0].Detail["new"] != newImpl.Hex() {
+		t.Fatalf("unauthorized upgrade not reported: %v", findings)
+	}
+	if findings = upgrade(admin); len(findings) != 1 || findings[0].Detail["authorized"] != "true" {
+		t.Fatalf("admin upgrade not recognised: %v", findings)
+	}
+}
@@ -0,0 +1,168 @@
+/**
+* @hacker_validation.go
+* Validation phase of account abstraction (ERC-4337 style): a user operation is a
+* validation call, whose success lets its execution call run, and the validation must
+* not depend on state the bundler cannot pin down, or a valid operation may fail once
+* included.
+* 1 ExecuteValidated runs the validation message, then the execution message when the
+*   validation succeeded, on one fork of the state: each one gets its own outcome and,
+*   on an instrumented harness, its own report.
+* 2 the operations of the validation phase are checked against the rules (ERC-7562):
+*   no storage access outside the wallet and the accounts the rules allow, and none of
+*   the banned opcodes reading the environment (TIMESTAMP, NUMBER, BALANCE, ...). A
+*   violation is listed in the outcome and raised as a "validation_violation" finding
+*   of the report of the validation, it does not stop the execution phase.
+* 3 the rules are checked by the instrumentation hooks, like the watchpoints: the
+*   nofuzz build and a node with the hooks off check none.
+ */
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerValidationViolationsCap is the number of violations kept per validation
+const hackerValidationViolationsCap = 32
+
+// hackerValidationBanned are the opcodes banned in the validation phase by default.
+var hackerValidationBanned = []OpCode{GASPRICE, GASLIMIT, DIFFICULTY, TIMESTAMP, BASEFEE, BLOCKHASH, NUMBER, SELFBALANCE, BALANCE, ORIGIN, COINBASE, CREATE, CREATE2, SELFDESTRUCT}
+
+var errValidationTarget = errors.New("validation without target")
+
+// HackerValidationRules are the rules of the validation phase.
+type HackerValidationRules struct {
+	// Wallet is the account whose storage the validation may access, the target of the
+	// validation message when unset
+	Wallet *common.Address `json:"wallet"`
+	// Storage are the other accounts whose storage the validation may access
+	Storage []common.Address `json:"storage"`
+	// Banned are the opcodes the validation may not execute, hackerValidationBanned
+	// when nil
+	Banned []string `json:"banned"`
+}
+
+// HackerValidationViolation is an operation of the validation phase breaking a rule.
+type HackerValidationViolation struct {
+	// Rule is "storage" or "opcode"
+	Rule    string         `json:"rule"`
+	Op      string         `json:"op"`
+	Address common.Address `json:"address"`
+	Pc      uint64         `json:"pc"`
+	Depth   int            `json:"depth"`
+	Slot    *common.Hash   `json:"slot,omitempty"`
+}
+
+// HackerValidatedOutcome is the result of a validated execution.
+type HackerValidatedOutcome struct {
+	Validation *HackerOutcome
+	// Execution is nil when the validation failed
+	Execution  *HackerOutcome
+	Violations []HackerValidationViolation
+}
+
+// hackerValidationGuard checks the operations of a validation phase.
+type hackerValidationGuard struct {
+	storage    map[common.Address]bool
+	banned     map[OpCode]bool
+	violations []HackerValidationViolation
+	seen       map[string]bool
+}
+
+// newHackerValidationGuard returns the guard of rules for a validation of wallet.
+func newHackerValidationGuard(rules *HackerValidationRules, wallet common.Address) (*hackerValidationGuard, error) {
+	guard := &hackerValidationGuard{storage: map[common.Address]bool{wallet: true}, banned: make(map[OpCode]bool), seen: make(map[string]bool)}
+	if rules == nil {
+		rules = new(HackerValidationRules)
+	}
+	if rules.Wallet != nil {
+		guard.storage = map[common.Address]bool{*rules.Wallet: true}
+	}
+	for _, addr := range rules.Storage {
+		guard.storage[addr] = true
+	}
+	if rules.Banned == nil {
+		for _, op := range hackerValidationBanned {
+			guard.banned[op] = true
+		}
+	}
+	for _, name := range rules.Banned {
+		op, ok := stringToOp[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown opcode %q", name)
+		}
+		guard.banned[op] = true
+	}
+	return guard, nil
+}
+
+// check records the violations of op about to execute.
+func (guard *hackerValidationGuard) check(op OpCode, pc uint64, evm *EVM, contract *Contract, stack *Stack) {
+	violation := HackerValidationViolation{Op: op.String(), Address: contract.Address(), Pc: pc, Depth: evm.depth}
+	switch {
+	case guard.banned[op]:
+		violation.Rule = "opcode"
+	case (op == SLOAD || op == SSTORE) && stack.len() >= 1 && !guard.storage[contract.Address()]:
+		slot := common.BigToHash(stack.Back(0))
+		violation.Rule, violation.Slot = "storage", &slot
+	default:
+		return
+	}
+	key := violation.Rule + violation.Op + violation.Address.Hex()
+	if violation.Slot != nil {
+		key += violation.Slot.Hex()
+	}
+	if guard.seen[key] || len(guard.violations) == hackerValidationViolationsCap {
+		return
+	}
+	guard.seen[key] = true
+	guard.violations = append(guard.violations, violation)
+	if dog := hackerDogs(evm)[0]; dog.watches(evm) && dog.TurnOn() {
+		finding := newHackerFinding("validation_violation", "HackerValidation")
+		finding.Detail["rule"] = violation.Rule
+		finding.Detail["op"] = violation.Op
+		finding.Detail["contract"] = violation.Address.Hex()
+		finding.Detail["pc"] = fmt.Sprintf("%d", pc)
+		if violation.Slot != nil {
+			finding.Detail["slot"] = violation.Slot.Hex()
+		}
+		dog.EmitFinding(finding)
+	}
+}
+
+// checkValidation checks op against the rules of the validation phase evm runs, if
+// any.
+func checkValidation(op OpCode, pc uint64, evm *EVM, contract *Contract, stack *Stack) {
+	if evm.validation != nil {
+		evm.validation.check(op, pc, evm, contract, stack)
+	}
+}
+
+// ExecuteValidated runs validation under rules, then execution when the validation
+// succeeded, on one fork of the state with override applied first.
+func (harness *HackerHarness) ExecuteValidated(validation, execution *HackerMessage, rules *HackerValidationRules, override HackerStateOverride) (*HackerValidatedOutcome, error) {
+	if validation.To == nil {
+		return nil, errValidationTarget
+	}
+	guard, err := newHackerValidationGuard(rules, *validation.To)
+	if err != nil {
+		return nil, err
+	}
+	outcome := new(HackerValidatedOutcome)
+	harness.Fork(func() {
+		override.Apply(harness.statedb)
+		harness.validation = guard
+		outcome.Validation = harness.Apply(validation)
+		harness.validation = nil
+		if !outcome.Validation.Failed() && execution != nil {
+			outcome.Execution = harness.Apply(execution)
+		}
+	})
+	outcome.Violations = guard.violations
+	if outcome.Violations == nil {
+		outcome.Violations = []HackerValidationViolation{}
+	}
+	return outcome, nil
+}
@@ -0,0 +1,1109 @@
+/**
+* @hacker_wasmvm.go
+* The WASM runtime of the oracle plugins, registered by default (see hacker_plugin.go).
+* 1 it interprets the integer subset of WebAssembly 1.0: the i32 and i64 instructions,
+*   the control instructions, one linear memory and globals. A module declaring a
+*   table, a start function, floats or an import other than the host API is refused
+*   when it is loaded, so is a module whose function bodies do not validate (operand
+*   types, indexes, branch depths, memory accesses without a memory) or whose data
+*   does not fit in its initial memory.
+* 2 a module exports "check" () -> i32 and "memory"; a non-zero result fires the
+*   oracle. It imports the host API from the module "hacker":
+*     version() -> i32
+*     frames(ptr, cap i32) -> i32     the frames as JSON, written at ptr up to cap bytes,
+*                                     returns the length of the whole JSON
+*     storage(ptr, cap i32) -> i32    the storage journal as JSON, likewise
+*     emit(kind, kindLen, detail, detailLen i32)
+*                                     a finding, detail is a JSON object of strings or empty
+* 3 every check runs a fresh instance of the module: its memory is capped to
+*   hackerWasmMaxPages pages, its call depth to hackerWasmMaxDepth and it is given
+*   hackerWasmFuel instructions. Running out of any of them, or any other trap, fails
+*   the check without firing the oracle.
+ */
+package vm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	// hackerWasmMaxPages caps the linear memory of a plugin, 16MiB
+	hackerWasmMaxPages = 256
+	// hackerWasmMaxDepth caps the call depth of a plugin
+	hackerWasmMaxDepth = 256
+	// hackerWasmFuel is the number of instructions a check may execute
+	hackerWasmFuel = 50000000
+
+	hackerWasmPageSize = 65536
+	hackerWasmI32      = 0x7f
+	hackerWasmI64      = 0x7e
+)
+
+var (
+	errWasmModule = errors.New("wasm: malformed module")
+	errWasmFuel   = errors.New("wasm: out of fuel")
+	errWasmDepth  = errors.New("wasm: call stack exhausted")
+	errWasmMemory = errors.New("wasm: out of bounds memory access")
+)
+
+func init() {
+	RegisterPluginRuntime(hackerWasmRuntime{})
+}
+
+// hackerWasmRuntime is the builtin HackerPluginRuntime.
+type hackerWasmRuntime struct{}
+
+func (hackerWasmRuntime) Name() string { return "wasm" }
+
+func (hackerWasmRuntime) Load(name string, module []byte) (HackerPluginInstance, error) {
+	return parseHackerWasm(module)
+}
+
+type hackerWasmType struct {
+	params, results []byte
+}
+
+// hackerWasmInstr is a decoded instruction. imm is the immediate (index, constant or
+// offset of a memory access), typ the result type of a block, loop or if (0 for
+// none), end and els are the indexes of its end and its else.
+type hackerWasmInstr struct {
+	op    byte
+	imm   uint64
+	typ   byte
+	table []uint32
+	end   int
+	els   int
+}
+
+type hackerWasmFunc struct {
+	typ    hackerWasmType
+	locals []byte
+	code   []hackerWasmInstr
+	// host is set for the imported functions
+	host func(inst *hackerWasmVM, args []uint64) []uint64
+}
+
+type hackerWasmGlobal struct {
+	typ     byte
+	mutable bool
+	init    uint64
+}
+
+type hackerWasmData struct {
+	offset uint32
+	init   []byte
+}
+
+// hackerWasmModule is a compiled plugin module.
+type hackerWasmModule struct {
+	funcs    []hackerWasmFunc
+	globals  []hackerWasmGlobal
+	data     []hackerWasmData
+	memory   bool
+	minPages uint32
+	maxPages uint32
+	check    int
+}
+
+// hackerWasmHostAPI are the functions a plugin may import from the module "hacker".
+var hackerWasmHostAPI = map[string]struct {
+	typ  hackerWasmType
+	call func(inst *hackerWasmVM, args []uint64) []uint64
+}{
+	"version": {hackerWasmType{nil, []byte{hackerWasmI32}}, func(inst *hackerWasmVM, args []uint64) []uint64 {
+		return []uint64{uint64(inst.host.Version())}
+	}},
+	"frames": {hackerWasmType{[]byte{hackerWasmI32, hackerWasmI32}, []byte{hackerWasmI32}}, func(inst *hackerWasmVM, args []uint64) []uint64 {
+		return []uint64{inst.writeJSON(inst.host.Frames(), uint32(args[0]), uint32(args[1]))}
+	}},
+	"storage": {hackerWasmType{[]byte{hackerWasmI32, hackerWasmI32}, []byte{hackerWasmI32}}, func(inst *hackerWasmVM, args []uint64) []uint64 {
+		return []uint64{inst.writeJSON(inst.host.StorageJournal(), uint32(args[0]), uint32(args[1]))}
+	}},
+	"emit": {hackerWasmType{[]byte{hackerWasmI32, hackerWasmI32, hackerWasmI32, hackerWasmI32}, nil}, func(inst *hackerWasmVM, args []uint64) []uint64 {
+		kind := string(inst.read(uint32(args[0]), uint32(args[1])))
+		detail := make(map[string]string)
+		if args[3] != 0 {
+			if err := json.Unmarshal(inst.read(uint32(args[2]), uint32(args[3])), &detail); err != nil {
+				panic(fmt.Errorf("wasm: finding detail: %v", err))
+			}
+		}
+		inst.host.EmitFinding(kind, detail)
+		return nil
+	}},
+}
+
+// hackerWasmReader decodes the binary format.
+type hackerWasmReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *hackerWasmReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		panic(errWasmModule)
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *hackerWasmReader) bytes(n uint32) []byte {
+	if uint64(r.pos)+uint64(n) > uint64(len(r.buf)) {
+		panic(errWasmModule)
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *hackerWasmReader) uleb(size uint) uint64 {
+	var result uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift >= size+7 {
+			panic(errWasmModule)
+		}
+		b := r.byte()
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+}
+
+func (r *hackerWasmReader) sleb(size uint) int64 {
+	var result int64
+	var shift uint
+	for {
+		if shift >= size+7 {
+			panic(errWasmModule)
+		}
+		b := r.byte()
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			return result
+		}
+	}
+}
+
+func (r *hackerWasmReader) u32() uint32 { return uint32(r.uleb(32)) }
+
+func (r *hackerWasmReader) name() string { return string(r.bytes(r.u32())) }
+
+func (r *hackerWasmReader) valtypes() []byte {
+	types := r.bytes(r.u32())
+	for _, t := range types {
+		if t != hackerWasmI32 && t != hackerWasmI64 {
+			panic(fmt.Errorf("wasm: unsupported value type 0x%x", t))
+		}
+	}
+	return types
+}
+
+// constExpr decodes the initializer of a global or the offset of a data segment.
+func (r *hackerWasmReader) constExpr() uint64 {
+	var value uint64
+	switch op := r.byte(); op {
+	case 0x41:
+		value = uint64(uint32(r.sleb(32)))
+	case 0x42:
+		value = uint64(r.sleb(64))
+	default:
+		panic(fmt.Errorf("wasm: unsupported constant expression 0x%x", op))
+	}
+	if r.byte() != 0x0b {
+		panic(errWasmModule)
+	}
+	return value
+}
+
+// parseHackerWasm compiles a module, its errors are the reasons it is refused.
+func parseHackerWasm(module []byte) (parsed *hackerWasmModule, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				parsed, err = nil, e
+			} else {
+				parsed, err = nil, fmt.Errorf("wasm: %v", r)
+			}
+		}
+	}()
+	r := &hackerWasmReader{buf: module}
+	if string(r.bytes(4)) != "\x00asm" || binary.LittleEndian.Uint32(r.bytes(4)) != 1 {
+		return nil, errors.New("wasm: not a WebAssembly 1.0 module")
+	}
+	parsed = &hackerWasmModule{check: -1}
+	var types []hackerWasmType
+	var declared []uint32
+	for r.pos < len(r.buf) {
+		id := r.byte()
+		section := &hackerWasmReader{buf: r.bytes(r.u32())}
+		switch id {
+		case 0:
+			// custom section
+		case 1:
+			for n := section.u32(); n > 0; n-- {
+				if section.byte() != 0x60 {
+					return nil, errWasmModule
+				}
+				types = append(types, hackerWasmType{params: section.valtypes(), results: section.valtypes()})
+			}
+		case 2:
+			for n := section.u32(); n > 0; n-- {
+				module, field := section.name(), section.name()
+				if kind := section.byte(); kind != 0x00 {
+					return nil, fmt.Errorf("wasm: unsupported import %s.%s", module, field)
+				}
+				index := section.u32()
+				api, ok := hackerWasmHostAPI[field]
+				if module != "hacker" || !ok {
+					return nil, fmt.Errorf("wasm: unknown import %s.%s", module, field)
+				}
+				if int(index) >= len(types) || !hackerWasmSameType(types[index], api.typ) {
+					return nil, fmt.Errorf("wasm: import %s.%s has the wrong type", module, field)
+				}
+				parsed.funcs = append(parsed.funcs, hackerWasmFunc{typ: api.typ, host: api.call})
+			}
+		case 3:
+			for n := section.u32(); n > 0; n-- {
+				declared = append(declared, section.u32())
+			}
+		case 5:
+			if section.u32() != 1 {
+				return nil, errors.New("wasm: one memory is supported")
+			}
+			flags := section.byte()
+			parsed.memory, parsed.minPages, parsed.maxPages = true, section.u32(), hackerWasmMaxPages
+			if flags&1 != 0 {
+				if max := section.u32(); max < parsed.maxPages {
+					parsed.maxPages = max
+				}
+			}
+			if parsed.minPages > parsed.maxPages {
+				return nil, fmt.Errorf("wasm: memory of %d pages over the limit of %d", parsed.minPages, parsed.maxPages)
+			}
+		case 6:
+			for n := section.u32(); n > 0; n-- {
+				t := section.byte()
+				if t != hackerWasmI32 && t != hackerWasmI64 {
+					return nil, errWasmModule
+				}
+				mutable := section.byte() == 1
+				parsed.globals = append(parsed.globals, hackerWasmGlobal{typ: t, mutable: mutable, init: section.constExpr()})
+			}
+		case 7:
+			for n := section.u32(); n > 0; n-- {
+				field, kind, index := section.name(), section.byte(), section.u32()
+				if field == "check" && kind == 0x00 {
+					parsed.check = int(index)
+				}
+			}
+		case 10:
+			if int(section.u32()) != len(declared) {
+				return nil, errWasmModule
+			}
+			imported := len(parsed.funcs)
+			for i := range declared {
+				if int(declared[i]) >= len(types) {
+					return nil, errWasmModule
+				}
+				parsed.funcs = append(parsed.funcs, hackerWasmFunc{typ: types[declared[i]]})
+			}
+			for i := range declared {
+				body := &hackerWasmReader{buf: section.bytes(section.u32())}
+				fn := &parsed.funcs[imported+i]
+				for n := body.u32(); n > 0; n-- {
+					count := body.u32()
+					t := body.byte()
+					if t != hackerWasmI32 && t != hackerWasmI64 {
+						return nil, fmt.Errorf("wasm: unsupported value type 0x%x", t)
+					}
+					if uint64(len(fn.locals))+uint64(count) > 50000 {
+						return nil, errors.New("wasm: too many locals")
+					}
+					for ; count > 0; count-- {
+						fn.locals = append(fn.locals, t)
+					}
+				}
+				fn.code = decodeHackerWasm(body)
+			}
+		case 11:
+			for n := section.u32(); n > 0; n-- {
+				if section.u32() != 0 {
+					return nil, errWasmModule
+				}
+				offset := uint32(section.constExpr())
+				parsed.data = append(parsed.data, hackerWasmData{offset: offset, init: section.bytes(section.u32())})
+			}
+		default:
+			return nil, fmt.Errorf("wasm: unsupported section %d", id)
+		}
+	}
+	if parsed.check < 0 || parsed.check >= len(parsed.funcs) {
+		return nil, errors.New("wasm: no check function exported")
+	}
+	if typ := parsed.funcs[parsed.check].typ; len(typ.params) != 0 || len(typ.results) != 1 || typ.results[0] != hackerWasmI32 {
+		return nil, errors.New("wasm: check must be () -> i32")
+	}
+	if len(parsed.data) > 0 && !parsed.memory {
+		return nil, errWasmModule
+	}
+	for _, data := range parsed.data {
+		if uint64(data.offset)+uint64(len(data.init)) > uint64(parsed.minPages)*hackerWasmPageSize {
+			return nil, errors.New("wasm: data segment out of the memory")
+		}
+	}
+	for i := range parsed.funcs {
+		if parsed.funcs[i].host == nil {
+			parsed.validate(i)
+		}
+	}
+	return parsed, nil
+}
+
+func hackerWasmSameType(a, b hackerWasmType) bool {
+	return string(a.params) == string(b.params) && string(a.results) == string(b.results)
+}
+
+// decodeHackerWasm decodes a function body and links its blocks to their else and end.
+func decodeHackerWasm(r *hackerWasmReader) []hackerWasmInstr {
+	var (
+		code   []hackerWasmInstr
+		blocks []int
+	)
+	for r.pos < len(r.buf) {
+		instr := hackerWasmInstr{op: r.byte(), end: -1, els: -1}
+		switch op := instr.op; {
+		case op == 0x02 || op == 0x03 || op == 0x04:
+			switch t := r.byte(); t {
+			case 0x40:
+			case hackerWasmI32, hackerWasmI64:
+				instr.imm, instr.typ = 1, t
+			default:
+				panic(fmt.Errorf("wasm: unsupported block type 0x%x", t))
+			}
+			blocks = append(blocks, len(code))
+		case op == 0x05:
+			if len(blocks) == 0 || code[blocks[len(blocks)-1]].op != 0x04 {
+				panic(errWasmModule)
+			}
+			code[blocks[len(blocks)-1]].els = len(code)
+		case op == 0x0b:
+			if len(blocks) > 0 {
+				block := blocks[len(blocks)-1]
+				blocks = blocks[:len(blocks)-1]
+				code[block].end = len(code)
+				if els := code[block].els; els >= 0 {
+					code[els].end = len(code)
+				}
+			} else if r.pos != len(r.buf) {
+				panic(errWasmModule)
+			}
+		case op == 0x0c || op == 0x0d || op == 0x10 || op >= 0x20 && op <= 0x24:
+			instr.imm = uint64(r.u32())
+		case op == 0x0e:
+			for n := r.u32(); n > 0; n-- {
+				instr.table = append(instr.table, r.u32())
+			}
+			instr.imm = uint64(r.u32())
+		case op >= 0x28 && op <= 0x3e && op != 0x2a && op != 0x2b && op != 0x38 && op != 0x39:
+			r.u32()
+			instr.imm = uint64(r.u32())
+		case op == 0x3f || op == 0x40:
+			if r.byte() != 0 {
+				panic(errWasmModule)
+			}
+		case op == 0x41:
+			instr.imm = uint64(uint32(r.sleb(32)))
+		case op == 0x42:
+			instr.imm = uint64(r.sleb(64))
+		case op <= 0x01 || op == 0x0f || op == 0x1a || op == 0x1b || op >= 0x45 && op <= 0x5a ||
+			op >= 0x67 && op <= 0x8a || op == 0xa7 || op == 0xac || op == 0xad || op >= 0xc0 && op <= 0xc4:
+		default:
+			panic(fmt.Errorf("wasm: unsupported instruction 0x%x", op))
+		}
+		code = append(code, instr)
+	}
+	if len(blocks) != 0 || len(code) == 0 || code[len(code)-1].op != 0x0b {
+		panic(errWasmModule)
+	}
+	return code
+}
+
+// hackerWasmCtrl is a block of a function being validated.
+type hackerWasmCtrl struct {
+	op byte
+	// labels are the types a branch to the block carries, results the ones it ends with
+	labels, results []byte
+	height          int
+	unreachable     bool
+}
+
+// validate type checks the body of the function index, it panics with the reason the
+// module is refused.
+func (module *hackerWasmModule) validate(index int) {
+	fn := &module.funcs[index]
+	locals := append(append([]byte(nil), fn.typ.params...), fn.locals...)
+	var (
+		stack []byte
+		ctrls = []hackerWasmCtrl{{labels: fn.typ.results, results: fn.typ.results}}
+		pc    int
+	)
+	fail := func(reason string) {
+		panic(fmt.Errorf("wasm: function %d, instruction %d: %s", index, pc, reason))
+	}
+	// pop takes an operand of type want off the stack, any type when want is 0; the
+	// operands of an unreachable block are of any type
+	pop := func(want byte) byte {
+		ctrl := &ctrls[len(ctrls)-1]
+		if len(stack) == ctrl.height {
+			if ctrl.unreachable {
+				return want
+			}
+			fail("operand stack underflow")
+		}
+		got := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if want != 0 && got != 0 && got != want {
+			fail("type mismatch")
+		}
+		if got == 0 {
+			return want
+		}
+		return got
+	}
+	pops := func(types []byte) {
+		for i := len(types) - 1; i >= 0; i-- {
+			pop(types[i])
+		}
+	}
+	push := func(types ...byte) { stack = append(stack, types...) }
+	label := func(out uint64) []byte {
+		if out >= uint64(len(ctrls)) {
+			fail("branch out of the function")
+		}
+		return ctrls[len(ctrls)-1-int(out)].labels
+	}
+	unreachable := func() {
+		ctrl := &ctrls[len(ctrls)-1]
+		stack, ctrl.unreachable = stack[:ctrl.height], true
+	}
+	// close checks the stack at the end of the innermost block
+	closeBlock := func() hackerWasmCtrl {
+		ctrl := ctrls[len(ctrls)-1]
+		pops(ctrl.results)
+		if len(stack) != ctrl.height {
+			fail("values left on the stack")
+		}
+		return ctrl
+	}
+	memory := func() {
+		if !module.memory {
+			fail("memory access without a memory")
+		}
+	}
+	local := func(n uint64) byte {
+		if n >= uint64(len(locals)) {
+			fail("unknown local")
+		}
+		return locals[n]
+	}
+	global := func(n uint64) *hackerWasmGlobal {
+		if n >= uint64(len(module.globals)) {
+			fail("unknown global")
+		}
+		return &module.globals[n]
+	}
+	const i32, i64 = hackerWasmI32, hackerWasmI64
+	for ; pc < len(fn.code); pc++ {
+		instr := &fn.code[pc]
+		switch op := instr.op; {
+		case op == 0x00:
+			unreachable()
+		case op == 0x01:
+		case op == 0x02 || op == 0x03 || op == 0x04:
+			if op == 0x04 {
+				pop(i32)
+			}
+			var results []byte
+			if instr.typ != 0 {
+				results = []byte{instr.typ}
+			}
+			ctrl := hackerWasmCtrl{op: op, labels: results, results: results, height: len(stack)}
+			if op == 0x03 {
+				ctrl.labels = nil
+			}
+			ctrls = append(ctrls, ctrl)
+		case op == 0x05:
+			ctrl := closeBlock()
+			if ctrl.op != 0x04 {
+				fail("else out of an if")
+			}
+			ctrls[len(ctrls)-1] = hackerWasmCtrl{op: 0x05, labels: ctrl.labels, results: ctrl.results, height: ctrl.height}
+		case op == 0x0b:
+			ctrl := closeBlock()
+			if ctrl.op == 0x04 && len(ctrl.results) != 0 {
+				fail("if without else has results")
+			}
+			ctrls = ctrls[:len(ctrls)-1]
+			push(ctrl.results...)
+		case op == 0x0c:
+			pops(label(instr.imm))
+			unreachable()
+		case op == 0x0d:
+			pop(i32)
+			types := label(instr.imm)
+			pops(types)
+			push(types...)
+		case op == 0x0e:
+			pop(i32)
+			types := label(instr.imm)
+			for _, out := range instr.table {
+				if string(label(uint64(out))) != string(types) {
+					fail("branch table of mixed types")
+				}
+			}
+			pops(types)
+			unreachable()
+		case op == 0x0f:
+			pops(fn.typ.results)
+			unreachable()
+		case op == 0x10:
+			if instr.imm >= uint64(len(module.funcs)) {
+				fail("unknown function")
+			}
+			callee := module.funcs[instr.imm].typ
+			pops(callee.params)
+			push(callee.results...)
+		case op == 0x1a:
+			pop(0)
+		case op == 0x1b:
+			pop(i32)
+			t := pop(0)
+			push(pop(t))
+		case op == 0x20:
+			push(local(instr.imm))
+		case op == 0x21:
+			pop(local(instr.imm))
+		case op == 0x22:
+			t := local(instr.imm)
+			push(pop(t))
+		case op == 0x23:
+			push(global(instr.imm).typ)
+		case op == 0x24:
+			g := global(instr.imm)
+			if !g.mutable {
+				fail("immutable global")
+			}
+			pop(g.typ)
+		case op >= 0x28 && op <= 0x35:
+			memory()
+			pop(i32)
+			if op == 0x28 || op >= 0x2c && op <= 0x2f {
+				push(i32)
+			} else {
+				push(i64)
+			}
+		case op >= 0x36 && op <= 0x3e:
+			memory()
+			if op == 0x36 || op == 0x3a || op == 0x3b {
+				pop(i32)
+			} else {
+				pop(i64)
+			}
+			pop(i32)
+		case op == 0x3f:
+			memory()
+			push(i32)
+		case op == 0x40:
+			memory()
+			pop(i32)
+			push(i32)
+		case op == 0x41:
+			push(i32)
+		case op == 0x42:
+			push(i64)
+		case op == 0x45 || op >= 0x67 && op <= 0x69 || op == 0xc0 || op == 0xc1:
+			pop(i32)
+			push(i32)
+		case op >= 0x46 && op <= 0x4f || op >= 0x6a && op <= 0x78:
+			pop(i32)
+			pop(i32)
+			push(i32)
+		case op == 0x50 || op == 0xa7:
+			pop(i64)
+			push(i32)
+		case op >= 0x51 && op <= 0x5a:
+			pop(i64)
+			pop(i64)
+			push(i32)
+		case op >= 0x79 && op <= 0x7b || op >= 0xc2 && op <= 0xc4:
+			pop(i64)
+			push(i64)
+		case op >= 0x7c && op <= 0x8a:
+			pop(i64)
+			pop(i64)
+			push(i64)
+		case op == 0xac || op == 0xad:
+			pop(i32)
+			push(i64)
+		default:
+			fail(fmt.Sprintf("unsupported instruction 0x%x", op))
+		}
+	}
+	if len(ctrls) != 0 {
+		fail("unterminated function")
+	}
+}
+
+// Check runs a fresh instance of the module against host.
+func (module *hackerWasmModule) Check(host *HackerPluginHost) (fired bool, err error) {
+	inst := &hackerWasmVM{module: module, host: host, fuel: hackerWasmFuel, globals: make([]uint64, len(module.globals))}
+	for i, global := range module.globals {
+		inst.globals[i] = global.init
+	}
+	if module.memory {
+		inst.memory = make([]byte, int(module.minPages)*hackerWasmPageSize)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("wasm: %v", r)
+			}
+			fired = false
+		}
+	}()
+	for _, data := range module.data {
+		copy(inst.slice(data.offset, uint32(len(data.init))), data.init)
+	}
+	return inst.call(module.check, nil, 0)[0] != 0, nil
+}
+
+// hackerWasmVM is an instance of a module running one check.
+type hackerWasmVM struct {
+	module  *hackerWasmModule
+	host    *HackerPluginHost
+	fuel    uint64
+	memory  []byte
+	globals []uint64
+}
+
+type hackerWasmLabel struct {
+	// block is the index of the block, loop or if instruction
+	block  int
+	height int
+	arity  int
+}
+
+func (inst *hackerWasmVM) slice(addr, size uint32) []byte {
+	if uint64(addr)+uint64(size) > uint64(len(inst.memory)) {
+		panic(errWasmMemory)
+	}
+	return inst.memory[addr : addr+size]
+}
+
+func (inst *hackerWasmVM) read(addr, size uint32) []byte {
+	return append([]byte(nil), inst.slice(addr, size)...)
+}
+
+// writeJSON writes the JSON of value at ptr, up to size bytes, and returns its length.
+func (inst *hackerWasmVM) writeJSON(value interface{}, ptr, size uint32) uint64 {
+	blob, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	if uint64(len(blob)) < uint64(size) {
+		size = uint32(len(blob))
+	}
+	copy(inst.slice(ptr, size), blob)
+	return uint64(len(blob))
+}
+
+// call runs the function index with args and returns its results.
+func (inst *hackerWasmVM) call(index int, args []uint64, depth int) []uint64 {
+	if depth >= hackerWasmMaxDepth {
+		panic(errWasmDepth)
+	}
+	fn := &inst.module.funcs[index]
+	if fn.host != nil {
+		return fn.host(inst, args)
+	}
+	locals := make([]uint64, len(args)+len(fn.locals))
+	copy(locals, args)
+	var (
+		stack  []uint64
+		labels []hackerWasmLabel
+		code   = fn.code
+	)
+	pop := func() uint64 {
+		value := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return value
+	}
+	push := func(value uint64) { stack = append(stack, value) }
+	results := func() []uint64 {
+		return append([]uint64(nil), stack[len(stack)-len(fn.typ.results):]...)
+	}
+	// branch jumps to the label depth out, it returns false for the function itself
+	branch := func(out int, pc *int) bool {
+		if out >= len(labels) {
+			return false
+		}
+		label := labels[len(labels)-1-out]
+		if code[label.block].op == 0x03 {
+			stack = stack[:label.height]
+			labels = labels[:len(labels)-out]
+			*pc = label.block + 1
+			return true
+		}
+		kept := stack[len(stack)-label.arity:]
+		stack = append(stack[:label.height], kept...)
+		labels = labels[:len(labels)-1-out]
+		*pc = code[label.block].end + 1
+		return true
+	}
+	for pc := 0; pc < len(code); {
+		if inst.fuel == 0 {
+			panic(errWasmFuel)
+		}
+		inst.fuel--
+		instr := &code[pc]
+		pc++
+		switch op := instr.op; op {
+		case 0x00:
+			panic(errors.New("wasm: unreachable"))
+		case 0x01:
+		case 0x02, 0x03:
+			labels = append(labels, hackerWasmLabel{block: pc - 1, height: len(stack), arity: int(instr.imm)})
+		case 0x04:
+			if pop() != 0 {
+				labels = append(labels, hackerWasmLabel{block: pc - 1, height: len(stack), arity: int(instr.imm)})
+			} else if instr.els >= 0 {
+				labels = append(labels, hackerWasmLabel{block: pc - 1, height: len(stack), arity: int(instr.imm)})
+				pc = instr.els + 1
+			} else {
+				pc = instr.end + 1
+			}
+		case 0x05:
+			// the then branch is over
+			pc = instr.end
+		case 0x0b:
+			if len(labels) == 0 {
+				return results()
+			}
+			labels = labels[:len(labels)-1]
+		case 0x0c:
+			if !branch(int(instr.imm), &pc) {
+				return results()
+			}
+		case 0x0d:
+			if pop() != 0 && !branch(int(instr.imm), &pc) {
+				return results()
+			}
+		case 0x0e:
+			out, index := instr.imm, pop()
+			if index < uint64(len(instr.table)) {
+				out = uint64(instr.table[index])
+			}
+			if !branch(int(out), &pc) {
+				return results()
+			}
+		case 0x0f:
+			return results()
+		case 0x10:
+			callee := &inst.module.funcs[instr.imm]
+			n := len(callee.typ.params)
+			args := append([]uint64(nil), stack[len(stack)-n:]...)
+			stack = append(stack[:len(stack)-n], inst.call(int(instr.imm), args, depth+1)...)
+		case 0x1a:
+			pop()
+		case 0x1b:
+			cond, b, a := pop(), pop(), pop()
+			if cond != 0 {
+				push(a)
+			} else {
+				push(b)
+			}
+		case 0x20:
+			push(locals[instr.imm])
+		case 0x21:
+			locals[instr.imm] = pop()
+		case 0x22:
+			locals[instr.imm] = stack[len(stack)-1]
+		case 0x23:
+			push(inst.globals[instr.imm])
+		case 0x24:
+			if !inst.module.globals[instr.imm].mutable {
+				panic(errors.New("wasm: immutable global"))
+			}
+			inst.globals[instr.imm] = pop()
+		case 0x3f:
+			push(uint64(len(inst.memory) / hackerWasmPageSize))
+		case 0x40:
+			pages, delta := uint64(len(inst.memory)/hackerWasmPageSize), uint64(uint32(pop()))
+			if !inst.module.memory || pages+delta > uint64(inst.module.maxPages) {
+				push(uint64(uint32(0xffffffff)))
+			} else {
+				inst.memory = append(inst.memory, make([]byte, delta*hackerWasmPageSize)...)
+				push(pages)
+			}
+		case 0x41, 0x42:
+			push(instr.imm)
+		default:
+			switch {
+			case op >= 0x28 && op <= 0x35:
+				push(inst.load(op, uint32(pop()), uint32(instr.imm)))
+			case op >= 0x36 && op <= 0x3e:
+				value := pop()
+				inst.store(op, uint32(pop()), uint32(instr.imm), value)
+			case op == 0x45 || op == 0x50 || op >= 0x67 && op <= 0x69 || op >= 0x79 && op <= 0x7b || op >= 0xa7:
+				push(hackerWasmUnary(op, pop()))
+			default:
+				b := pop()
+				push(hackerWasmBinary(op, pop(), b))
+			}
+		}
+	}
+	return results()
+}
+
+func (inst *hackerWasmVM) load(op byte, addr, offset uint32) uint64 {
+	if uint64(addr)+uint64(offset) > 0xffffffff {
+		panic(errWasmMemory)
+	}
+	addr += offset
+	switch op {
+	case 0x28:
+		return uint64(binary.LittleEndian.Uint32(inst.slice(addr, 4)))
+	case 0x29:
+		return binary.LittleEndian.Uint64(inst.slice(addr, 8))
+	case 0x2c:
+		return uint64(uint32(int32(int8(inst.slice(addr, 1)[0]))))
+	case 0x2d, 0x31:
+		return uint64(inst.slice(addr, 1)[0])
+	case 0x2e:
+		return uint64(uint32(int32(int16(binary.LittleEndian.Uint16(inst.slice(addr, 2))))))
+	case 0x2f, 0x33:
+		return uint64(binary.LittleEndian.Uint16(inst.slice(addr, 2)))
+	case 0x30:
+		return uint64(int64(int8(inst.slice(addr, 1)[0])))
+	case 0x32:
+		return uint64(int64(int16(binary.LittleEndian.Uint16(inst.slice(addr, 2)))))
+	case 0x34:
+		return uint64(int64(int32(binary.LittleEndian.Uint32(inst.slice(addr, 4)))))
+	default:
+		return uint64(binary.LittleEndian.Uint32(inst.slice(addr, 4)))
+	}
+}
+
+func (inst *hackerWasmVM) store(op byte, addr, offset uint32, value uint64) {
+	if uint64(addr)+uint64(offset) > 0xffffffff {
+		panic(errWasmMemory)
+	}
+	addr += offset
+	switch op {
+	case 0x36, 0x3e:
+		binary.LittleEndian.PutUint32(inst.slice(addr, 4), uint32(value))
+	case 0x37:
+		binary.LittleEndian.PutUint64(inst.slice(addr, 8), value)
+	case 0x3a, 0x3c:
+		inst.slice(addr, 1)[0] = byte(value)
+	default:
+		binary.LittleEndian.PutUint16(inst.slice(addr, 2), uint16(value))
+	}
+}
+
+func hackerWasmBool(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func hackerWasmUnary(op byte, a uint64) uint64 {
+	switch op {
+	case 0x45:
+		return hackerWasmBool(uint32(a) == 0)
+	case 0x50:
+		return hackerWasmBool(a == 0)
+	case 0x67:
+		return uint64(bits.LeadingZeros32(uint32(a)))
+	case 0x68:
+		return uint64(bits.TrailingZeros32(uint32(a)))
+	case 0x69:
+		return uint64(bits.OnesCount32(uint32(a)))
+	case 0x79:
+		return uint64(bits.LeadingZeros64(a))
+	case 0x7a:
+		return uint64(bits.TrailingZeros64(a))
+	case 0x7b:
+		return uint64(bits.OnesCount64(a))
+	case 0xa7:
+		return uint64(uint32(a))
+	case 0xac:
+		return uint64(int64(int32(a)))
+	case 0xad:
+		return uint64(uint32(a))
+	case 0xc0:
+		return uint64(uint32(int32(int8(a))))
+	case 0xc1:
+		return uint64(uint32(int32(int16(a))))
+	case 0xc2:
+		return uint64(int64(int8(a)))
+	case 0xc3:
+		return uint64(int64(int16(a)))
+	default:
+		return uint64(int64(int32(a)))
+	}
+}
+
+var errWasmDivide = errors.New("wasm: integer divide by zero or overflow")
+
+func hackerWasmBinary(op byte, a, b uint64) uint64 {
+	if op <= 0x4f || op >= 0x6a && op <= 0x78 {
+		x, y := uint32(a), uint32(b)
+		switch op {
+		case 0x46:
+			return hackerWasmBool(x == y)
+		case 0x47:
+			return hackerWasmBool(x != y)
+		case 0x48:
+			return hackerWasmBool(int32(x) < int32(y))
+		case 0x49:
+			return hackerWasmBool(x < y)
+		case 0x4a:
+			return hackerWasmBool(int32(x) > int32(y))
+		case 0x4b:
+			return hackerWasmBool(x > y)
+		case 0x4c:
+			return hackerWasmBool(int32(x) <= int32(y))
+		case 0x4d:
+			return hackerWasmBool(x <= y)
+		case 0x4e:
+			return hackerWasmBool(int32(x) >= int32(y))
+		case 0x4f:
+			return hackerWasmBool(x >= y)
+		case 0x6a:
+			return uint64(x + y)
+		case 0x6b:
+			return uint64(x - y)
+		case 0x6c:
+			return uint64(x * y)
+		case 0x6d:
+			if y == 0 || int32(x) == -1<<31 && int32(y) == -1 {
+				panic(errWasmDivide)
+			}
+			return uint64(uint32(int32(x) / int32(y)))
+		case 0x6e:
+			if y == 0 {
+				panic(errWasmDivide)
+			}
+			return uint64(x / y)
+		case 0x6f:
+			if y == 0 {
+				panic(errWasmDivide)
+			}
+			if int32(y) == -1 {
+				return 0
+			}
+			return uint64(uint32(int32(x) % int32(y)))
+		case 0x70:
+			if y == 0 {
+				panic(errWasmDivide)
+			}
+			return uint64(x % y)
+		case 0x71:
+			return uint64(x & y)
+		case 0x72:
+			return uint64(x | y)
+		case 0x73:
+			return uint64(x ^ y)
+		case 0x74:
+			return uint64(x << (y & 31))
+		case 0x75:
+			return uint64(uint32(int32(x) >> (y & 31)))
+		case 0x76:
+			return uint64(x >> (y & 31))
+		case 0x77:
+			return uint64(bits.RotateLeft32(x, int(y&31)))
+		default:
+			return uint64(bits.RotateLeft32(x, -int(y&31)))
+		}
+	}
+	switch op {
+	case 0x51:
+		return hackerWasmBool(a == b)
+	case 0x52:
+		return hackerWasmBool(a != b)
+	case 0x53:
+		return hackerWasmBool(int64(a) < int64(b))
+	case 0x54:
+		return hackerWasmBool(a < b)
+	case 0x55:
+		return hackerWasmBool(int64(a) > int64(b))
+	case 0x56:
+		return hackerWasmBool(a > b)
+	case 0x57:
+		return hackerWasmBool(int64(a) <= int64(b))
+	case 0x58:
+		return hackerWasmBool(a <= b)
+	case 0x59:
+		return hackerWasmBool(int64(a) >= int64(b))
+	case 0x5a:
+		return hackerWasmBool(a >= b)
+	case 0x7c:
+		return a + b
+	case 0x7d:
+		return a - b
+	case 0x7e:
+		return a * b
+	case 0x7f:
+		if b == 0 || int64(a) == -1<<63 && int64(b) == -1 {
+			panic(errWasmDivide)
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80:
+		if b == 0 {
+			panic(errWasmDivide)
+		}
+		return a / b
+	case 0x81:
+		if b == 0 {
+			panic(errWasmDivide)
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82:
+		if b == 0 {
+			panic(errWasmDivide)
+		}
+		return a % b
+	case 0x83:
+		return a & b
+	case 0x84:
+		return a | b
+	case 0x85:
+		return a ^ b
+	case 0x86:
+		return a << (b & 63)
+	case 0x87:
+		return uint64(int64(a) >> (b & 63))
+	case 0x88:
+		return a >> (b & 63)
+	case 0x89:
+		return bits.RotateLeft64(a, int(b&63))
+	default:
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+}
@@ -0,0 +1,233 @@
+/**
+* @hacker_watchpoint.go
+* Watchpoints: immediate alerts on the writes of monitored state.
+* 1 a slot watchpoint (fuzz_watchSlot) fires on every SSTORE to the slot of the
+*   account, in the watched transactions and in the others the node executes.
+* 2 a balance watchpoint (fuzz_watchBalance) fires when a message call or creation
+*   changes the balance of the account by more than its threshold, the balances are
+*   compared before and after the execution (top-level frame).
+* 3 an alert is pushed to the alert URL as soon as the watchpoint fires, apart from
+*   the end-of-tx report, with the frame (caller, account, code, depth, selector) and
+*   the transaction (origin, block, and hash when the transaction is watched).
+* 4 the alerts are sent in the background, WaitAlerts blocks until they are delivered.
+ */
+package vm
+
+import (
+	"log"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hackerAlertURL is where the fuzzer listens for watchpoint alerts.
+var hackerAlertURL = "http://localhost:3000/alert"
+
+// SetAlertURL changes where the watchpoint alerts are sent.
+func SetAlertURL(url string) {
+	hackerAlertURL = url
+}
+
+type hackerSlotKey struct {
+	address common.Address
+	slot    common.Hash
+}
+
+var (
+	watchpointsLock sync.RWMutex
+	slotWatchpoints = make(map[hackerSlotKey]struct{})
+	// balanceWatchpoints holds the threshold of the watched accounts
+	balanceWatchpoints = make(map[common.Address]*big.Int)
+	// watchpointCount is the number of watchpoints, read on every operation
+	watchpointCount int32
+	alertsPending   sync.WaitGroup
+)
+
+// WatchSlot adds a watchpoint on the slot of address.
+func WatchSlot(address common.Address, slot common.Hash) {
+	watchpointsLock.Lock()
+	defer watchpointsLock.Unlock()
+	key := hackerSlotKey{address, slot}
+	if _, ok := slotWatchpoints[key]; !ok {
+		slotWatchpoints[key] = struct{}{}
+		atomic.AddInt32(&watchpointCount, 1)
+	}
+}
+
+// UnwatchSlot removes the watchpoint on the slot of address.
+func UnwatchSlot(address common.Address, slot common.Hash) {
+	watchpointsLock.Lock()
+	defer watchpointsLock.Unlock()
+	key := hackerSlotKey{address, slot}
+	if _, ok := slotWatchpoints[key]; ok {
+		delete(slotWatchpoints, key)
+		atomic.AddInt32(&watchpointCount, -1)
+	}
+}
+
+func isWatchedSlot(address common.Address, slot common.Hash) bool {
+	watchpointsLock.RLock()
+	defer watchpointsLock.RUnlock()
+	_, ok := slotWatchpoints[hackerSlotKey{address, slot}]
+	return ok
+}
+
+// WatchBalance adds a watchpoint on the balance of address, firing on the changes
+// larger than threshold wei in either direction.
+func WatchBalance(address common.Address, threshold *big.Int) {
+	watchpointsLock.Lock()
+	defer watchpointsLock.Unlock()
+	if _, ok := balanceWatchpoints[address]; !ok {
+		atomic.AddInt32(&watchpointCount, 1)
+	}
+	balanceWatchpoints[address] = new(big.Int).Set(threshold)
+}
+
+// UnwatchBalance removes the watchpoint on the balance of address.
+func UnwatchBalance(address common.Address) {
+	watchpointsLock.Lock()
+	defer watchpointsLock.Unlock()
+	if _, ok := balanceWatchpoints[address]; ok {
+		delete(balanceWatchpoints, address)
+		atomic.AddInt32(&watchpointCount, -1)
+	}
+}
+
+// watchedBalances returns the balances of the accounts with a balance watchpoint, nil
+// when there is none.
+func watchedBalances(statedb StateDB) map[common.Address]*big.Int {
+	if atomic.LoadInt32(&watchpointCount) == 0 {
+		return nil
+	}
+	watchpointsLock.RLock()
+	defer watchpointsLock.RUnlock()
+	if len(balanceWatchpoints) == 0 {
+		return nil
+	}
+	balances := make(map[common.Address]*big.Int, len(balanceWatchpoints))
+	for address := range balanceWatchpoints {
+		balances[address] = new(big.Int).Set(statedb.GetBalance(address))
+	}
+	return balances
+}
+
+// checkBalanceWatchpoints fires the balance watchpoints of the execution of a message
+// of caller to address with input, given the balances before it.
+func checkBalanceWatchpoints(evm *EVM, caller, address common.Address, input []byte, before map[common.Address]*big.Int) {
+	for watched, old := range before {
+		watchpointsLock.RLock()
+		threshold, ok := balanceWatchpoints[watched]
+		watchpointsLock.RUnlock()
+		balance := evm.StateDB.GetBalance(watched)
+		delta := new(big.Int).Sub(balance, old)
+		if !ok || new(big.Int).Abs(delta).Cmp(threshold) <= 0 {
+			continue
+		}
+		frame := HackerAlertFrame{Caller: caller, Address: address, Selector: hackerSelectorKey(input)}
+		alert := newHackerAlert("balance", evm, frame)
+		if alert.Tx.Hash != nil {
+			root := 0
+			alert.Frame.Index = &root
+		}
+		alert.Address = watched
+		alert.Old, alert.New, alert.Delta = old.Text(10), balance.Text(10), delta.Text(10)
+		sendAlert(alert)
+	}
+}
+
+// HackerAlertFrame is the frame that fired a watchpoint.
+type HackerAlertFrame struct {
+	Caller      common.Address  `json:"caller"`
+	Address     common.Address  `json:"address"`
+	CodeAddress *common.Address `json:"codeAddress,omitempty"`
+	// Depth is the call depth, 0 for the frame of the transaction.
+	Depth    int    `json:"depth"`
+	Selector string `json:"selector"`
+	// Index is the frame of the report, set when the transaction is watched
+	Index *int `json:"index,omitempty"`
+}
+
+// HackerAlertTx is the transaction that fired a watchpoint.
+type HackerAlertTx struct {
+	// Hash is set when the transaction is watched
+	Hash        *common.Hash   `json:"hash,omitempty"`
+	Origin      common.Address `json:"origin"`
+	BlockNumber string         `json:"blockNumber"`
+}
+
+// HackerAlert is pushed to the alert URL when a watchpoint fires.
+type HackerAlert struct {
+	// Type is "slot" or "balance", "saturated" for a target downgraded (hacker_saturation.go)
+	Type    string         `json:"type"`
+	Address common.Address `json:"address"`
+	Slot    *common.Hash   `json:"slot,omitempty"`
+	// Old and New are the values of the slot, or the balances in wei
+	Old string `json:"old"`
+	New string `json:"new"`
+	// Delta is the change of the balance
+	Delta string           `json:"delta,omitempty"`
+	Frame HackerAlertFrame `json:"frame"`
+	Tx    HackerAlertTx    `json:"tx"`
+}
+
+// newHackerAlert returns an alert of type kind fired by frame of evm.
+func newHackerAlert(kind string, evm *EVM, frame HackerAlertFrame) *HackerAlert {
+	alert := &HackerAlert{Type: kind, Frame: frame, Tx: HackerAlertTx{Origin: evm.Origin}}
+	if evm.BlockNumber != nil {
+		alert.Tx.BlockNumber = evm.BlockNumber.Text(10)
+	}
+	if dog := hackerDogs(evm)[0]; dog.watches(evm) && dog.TurnOn() {
+		hash := dog.tx.Hash()
+		alert.Tx.Hash = &hash
+	}
+	return alert
+}
+
+// checkWatchpoints fires the watchpoints written by op, before it executes.
+func checkWatchpoints(op OpCode, evm *EVM, contract *Contract, stack *Stack) {
+	if atomic.LoadInt32(&watchpointCount) == 0 {
+		return
+	}
+	if op == SSTORE && stack.len() >= 2 {
+		slot := common.BigToHash(stack.Back(0))
+		if !isWatchedSlot(contract.Address(), slot) {
+			return
+		}
+		alert := newHackerAlert("slot", evm, HackerAlertFrame{
+			Caller:      contract.Caller(),
+			Address:     contract.Address(),
+			CodeAddress: contract.CodeAddr,
+			Depth:       evm.depth - 1,
+			Selector:    hackerSelectorKey(contract.Input),
+		})
+		if dog := hackerDogs(evm)[0]; alert.Tx.Hash != nil && dog.taint != nil {
+			if top := dog.taint.journal.top(); top >= 0 {
+				alert.Frame.Index = &top
+			}
+		}
+		alert.Address, alert.Slot = contract.Address(), &slot
+		alert.Old = evm.StateDB.GetState(contract.Address(), slot).Hex()
+		alert.New = common.BigToHash(stack.Back(1)).Hex()
+		sendAlert(alert)
+	}
+}
+
+// sendAlert pushes alert to the fuzzer in the background.
+func sendAlert(alert *HackerAlert) {
+	url := hackerAlertURL
+	alertsPending.Add(1)
+	go func() {
+		defer alertsPending.Done()
+		if err := postReport(url, alert); err != nil {
+			log.Printf("Post Error! %v", err)
+		}
+	}()
+}
+
+// WaitAlerts blocks until the alerts fired so far are sent, and the progress reports
+// (hacker_progress.go).
+func WaitAlerts() {
+	alertsPending.Wait()
+}
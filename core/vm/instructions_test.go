@@ -42,6 +42,39 @@ func TestByteOp(t *testing.T) {
 	}
 }
 
+func TestShiftOps(t *testing.T) {
+	var (
+		env   = NewEVM(Context{}, nil, params.TestChainConfig, Config{EnableJit: false, ForceJit: false})
+		stack = newstack()
+	)
+	tests := []struct {
+		op       func(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error)
+		v        string
+		shift    uint64
+		expected string
+	}{
+		{opSHL, "0000000000000000000000000000000000000000000000000000000000000001", 1, "0000000000000000000000000000000000000000000000000000000000000002"},
+		{opSHL, "0000000000000000000000000000000000000000000000000000000000000001", 255, "8000000000000000000000000000000000000000000000000000000000000000"},
+		{opSHL, "0000000000000000000000000000000000000000000000000000000000000001", 256, "0000000000000000000000000000000000000000000000000000000000000000"},
+		{opSHL, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 1, "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe"},
+		{opSHR, "8000000000000000000000000000000000000000000000000000000000000000", 1, "4000000000000000000000000000000000000000000000000000000000000000"},
+		{opSHR, "8000000000000000000000000000000000000000000000000000000000000000", 256, "0000000000000000000000000000000000000000000000000000000000000000"},
+		{opSAR, "8000000000000000000000000000000000000000000000000000000000000000", 1, "c000000000000000000000000000000000000000000000000000000000000000"},
+		{opSAR, "8000000000000000000000000000000000000000000000000000000000000000", 256, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{opSAR, "4000000000000000000000000000000000000000000000000000000000000000", 254, "0000000000000000000000000000000000000000000000000000000000000001"},
+	}
+	pc := uint64(0)
+	for i, test := range tests {
+		stack.push(new(big.Int).SetBytes(common.Hex2Bytes(test.v)))
+		stack.push(new(big.Int).SetUint64(test.shift))
+		test.op(&pc, env, nil, nil, stack)
+		actual := common.BigToHash(stack.pop())
+		if actual != common.HexToHash(test.expected) {
+			t.Errorf("test %d: expected %s, got %x", i, test.expected, actual)
+		}
+	}
+}
+
 func opBenchmark(bench *testing.B, op func(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error), args ...string) {
 	var (
 		env   = NewEVM(Context{}, nil, params.TestChainConfig, Config{EnableJit: false, ForceJit: false})
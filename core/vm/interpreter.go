@@ -17,7 +17,6 @@
 package vm
 
 import (
-	"fmt"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -59,32 +58,72 @@ type Interpreter struct {
 	gasTable params.GasTable
 	intPool  *intPool
 
-	readonly bool
+	readOnly   bool   // Whether to throw on stateful modifications
+	returnData []byte // Last CALL's return data for subsequent reuse
 }
 
 // NewInterpreter returns a new instance of the Interpreter.
 func NewInterpreter(evm *EVM, cfg Config) *Interpreter {
+	in := &Interpreter{
+		evm:     evm,
+		intPool: newIntPool(),
+	}
+	in.reset(cfg)
+	return in
+}
+
+// Reset prepares the interpreter for the next transaction of its EVM, after the
+// context of the EVM changed. The integer pool is kept.
+func (in *Interpreter) Reset() {
+	in.reset(in.evm.vmConfig)
+}
+
+func (in *Interpreter) reset(cfg Config) {
 	// We use the STOP instruction whether to see
 	// the jump table was initialised. If it was not
 	// we'll set the default jump table.
+	// The chain configuration has no fork block after Byzantium, the instructions of
+	// the later forks are those the campaign opted in to (hacker_fork.go).
+	evm := in.evm
 	if !cfg.JumpTable[STOP].valid {
 		switch {
+		case evm.forkRules.IsShanghai:
+			cfg.JumpTable = shanghaiInstructionSet
+		case evm.forkRules.IsLondon:
+			cfg.JumpTable = londonInstructionSet
+		case evm.forkRules.IsBerlin:
+			cfg.JumpTable = berlinInstructionSet
+		case evm.forkRules.IsIstanbul:
+			cfg.JumpTable = istanbulInstructionSet
+		case evm.forkRules.IsConstantinople:
+			cfg.JumpTable = constantinopleInstructionSet
+		case evm.chainRules.IsByzantium:
+			cfg.JumpTable = byzantiumInstructionSet
 		case evm.ChainConfig().IsHomestead(evm.BlockNumber):
 			cfg.JumpTable = homesteadInstructionSet
 		default:
 			cfg.JumpTable = frontierInstructionSet
 		}
 	}
-
-	return &Interpreter{
-		evm:      evm,
-		cfg:      cfg,
-		gasTable: evm.ChainConfig().GasTable(evm.BlockNumber),
-		intPool:  newIntPool(),
-	}
+	in.cfg = cfg
+	in.gasTable = evm.ChainConfig().GasTable(evm.BlockNumber)
+	in.readOnly = false
+	in.returnData = nil
 }
 
 func (in *Interpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack) error {
+	if in.evm.chainRules.IsByzantium {
+		if in.readOnly {
+			// If the interpreter is operating in readonly mode, make sure no
+			// state-modifying operation is performed. The 3rd stack item
+			// for a call operation is the value. Transferring value from one
+			// account to the others means the state is modified and should also
+			// return with an error.
+			if operation.writes || (op == CALL && stack.Back(2).BitLen() > 0) {
+				return ErrWriteProtection
+			}
+		}
+	}
 	return nil
 }
 
@@ -98,10 +137,19 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 	in.evm.depth++
 	defer func() { in.evm.depth-- }()
 
+	// Reset the previous call's return data. It's unimportant to preserve the old buffer
+	// as every returning call will return new data anyway.
+	in.returnData = nil
+
 	// Don't bother with the execution if there's no code.
 	if len(contract.Code) == 0 {
 		return nil, nil
 	}
+	hooks := HooksEnabled()
+	if hooks {
+		gas := contract.Gas
+		defer func() { hacker_frame_end(in.evm, contract, gas, ret, err) }()
+	}
 
 	codehash := contract.CodeHash // codehash is used when doing jump dest caching
 	if codehash == (common.Hash{}) {
@@ -131,18 +179,18 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 	// the execution of one of the operations or until the done flag is set by the
 	// parent context.
 	for atomic.LoadInt32(&in.evm.abort) == 0 {
+		if in.evm.stepLimit != 0 && in.evm.step() {
+			return nil, ErrExecutionTimeout
+		}
 		// Get the memory location of pc
 		op = contract.GetOp(pc)
 
 		// get the operation from the jump table matching the opcode
 		operation := in.cfg.JumpTable[op]
-		if err := in.enforceRestrictions(op, operation, stack); err != nil {
-			return nil, err
-		}
 
 		// if the op is invalid abort the process and return an error
 		if !operation.valid {
-			return nil, fmt.Errorf("invalid opcode 0x%x", int(op))
+			return nil, &ErrInvalidOpCode{opcode: op}
 		}
 
 		// validate the stack and make sure there enough stack items available
@@ -150,6 +198,10 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 		if err := operation.validateStack(stack); err != nil {
 			return nil, err
 		}
+		// If the operation is valid, enforce and write restrictions
+		if err := in.enforceRestrictions(op, operation, stack); err != nil {
+			return nil, err
+		}
 
 		var memorySize uint64
 		// calculate the new memory size and expand the memory to fit
@@ -181,29 +233,36 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 		if in.cfg.Debug {
 			in.cfg.Tracer.CaptureState(in.evm, pc, op, contract.Gas, cost, mem, stack, contract, in.evm.depth, err)
 		}
-		//Hacker_record: wrap evm opcode operation, and record information that we care in our test.
-		res,err := Hacker_record(op,(opFunc)(operation.execute),&pc, in.evm, contract, mem, stack)
-		// execute the operation
-		//res, err := operation.execute(&pc, in.evm, contract, mem, stack)
+		var res []byte
+		if hooks {
+			//Hacker_record: wrap evm opcode operation, and record information that we care in our test.
+			res, err = Hacker_record(op,(opFunc)(operation.execute),&pc, in.evm, contract, mem, stack)
+		} else {
+			// execute the operation
+			res, err = operation.execute(&pc, in.evm, contract, mem, stack)
+		}
 		// verifyPool is a build flag. Pool verification makes sure the integrity
 		// of the integer pool by comparing values to a default value.
 		if verifyPool {
 			verifyIntegerPool(in.intPool)
 		}
 
+		// if the operation clears the return data (e.g. it has returning data)
+		// set the last return to the result of the operation.
+		if operation.returns {
+			in.returnData = res
+		}
+
 		switch {
 		case err != nil:
 			return nil, err
+		case operation.reverts:
+			return res, ErrExecutionReverted
 		case operation.halts:
 			return res, nil
 		case !operation.jumps:
 			pc++
 		}
-		// if the operation returned a value make sure that is also set
-		// the last return data.
-		if res != nil {
-			mem.lastReturn = ret
-		}
 	}
 	return nil, nil
 }
@@ -0,0 +1,193 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// touchAddress adds address to the access list and returns the access cost.
+func touchAddress(evm *EVM, contract *Contract, address common.Address) uint64 {
+	cold := evm.accessList.addAddress(address)
+	if HooksEnabled() {
+		hacker_access(evm, contract, cold)
+	}
+	if cold {
+		return ColdAccountAccessCostEIP2929
+	}
+	return WarmStorageReadCostEIP2929
+}
+
+// touchSlot adds the slot of the executing contract to the access list and returns
+// whether it was cold.
+func touchSlot(evm *EVM, contract *Contract, slot common.Hash) bool {
+	_, cold := evm.accessList.addSlot(contract.Address(), slot)
+	if HooksEnabled() {
+		hacker_access(evm, contract, cold)
+	}
+	return cold
+}
+
+// gasSLoadEIP2929 charges 2100 for a cold slot and 100 for a warm one.
+func gasSLoadEIP2929(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	if touchSlot(evm, contract, common.BigToHash(stack.Back(0))) {
+		return ColdSloadCostEIP2929, nil
+	}
+	return WarmStorageReadCostEIP2929, nil
+}
+
+// makeGasSStoreFunc returns the net gas metering of EIP-2200 with the access costs
+// of EIP-2929, clearingRefund is the refund for clearing a slot. The StateDB has no
+// way to remove refunds, the refunds taken back are added as negative amounts.
+func makeGasSStoreFunc(clearingRefund uint64) gasFunc {
+	return func(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		// If we fail the minimum gas availability invariant, fail (0)
+		if contract.Gas <= SstoreSentryGasEIP2200 {
+			return 0, ErrOutOfGas
+		}
+		var (
+			slot    = common.BigToHash(stack.Back(0))
+			value   = common.BigToHash(stack.Back(1))
+			current = evm.StateDB.GetState(contract.Address(), slot)
+			cost    uint64
+		)
+		if touchSlot(evm, contract, slot) {
+			cost = ColdSloadCostEIP2929
+		}
+		original := evm.accessList.originalState(contract.Address(), slot, current)
+		if current == value { // noop (1)
+			return cost + WarmStorageReadCostEIP2929, nil
+		}
+		if original == current {
+			if original == (common.Hash{}) { // create slot (2.1.1)
+				return cost + params.SstoreSetGas, nil
+			}
+			if value == (common.Hash{}) { // delete slot (2.1.2b)
+				evm.StateDB.AddRefund(new(big.Int).SetUint64(clearingRefund))
+			}
+			return cost + (params.SstoreResetGas - ColdSloadCostEIP2929), nil // write existing slot (2.1.2)
+		}
+		if original != (common.Hash{}) {
+			if current == (common.Hash{}) { // recreate slot (2.2.1.1)
+				evm.StateDB.AddRefund(new(big.Int).Neg(new(big.Int).SetUint64(clearingRefund)))
+			} else if value == (common.Hash{}) { // delete slot (2.2.1.2)
+				evm.StateDB.AddRefund(new(big.Int).SetUint64(clearingRefund))
+			}
+		}
+		if original == value {
+			if original == (common.Hash{}) { // reset to original inexistent slot (2.2.2.1)
+				evm.StateDB.AddRefund(new(big.Int).SetUint64(params.SstoreSetGas - WarmStorageReadCostEIP2929))
+			} else { // reset to original existing slot (2.2.2.2)
+				evm.StateDB.AddRefund(new(big.Int).SetUint64(params.SstoreResetGas - ColdSloadCostEIP2929 - WarmStorageReadCostEIP2929))
+			}
+		}
+		return cost + WarmStorageReadCostEIP2929, nil // dirty update (2.2)
+	}
+}
+
+var (
+	// gasSStoreEIP2929 is the SSTORE gas of Berlin.
+	gasSStoreEIP2929 = makeGasSStoreFunc(SstoreClearsScheduleRefundEIP2200)
+	// gasSStoreEIP3529 is the SSTORE gas of London, clearing a slot refunds less (EIP-3529).
+	gasSStoreEIP3529 = makeGasSStoreFunc(SstoreClearsScheduleRefundEIP3529)
+)
+
+// gasAccountCheckEIP2929 is the gas of BALANCE, EXTCODESIZE and EXTCODEHASH.
+func gasAccountCheckEIP2929(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	return touchAddress(evm, contract, common.BigToAddress(stack.Back(0))), nil
+}
+
+// gasExtCodeCopyEIP2929 replaces the EXTCODECOPY base price with the access cost.
+func gasExtCodeCopyEIP2929(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gt.ExtcodeCopy = 0
+	gas, err := gasExtCodeCopy(gt, evm, contract, stack, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	var overflow bool
+	if gas, overflow = math.SafeAdd(gas, touchAddress(evm, contract, common.BigToAddress(stack.Back(0)))); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
+// makeCallVariantGasCallEIP2929 replaces the call base price with the access cost.
+// The cold surcharge is taken from the available gas before the gas of the call is
+// computed, like the other costs of the caller (EIP-150 63/64 rule).
+func makeCallVariantGasCallEIP2929(oldCalculator gasFunc) gasFunc {
+	return func(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		gt.Calls = touchAddress(evm, contract, common.BigToAddress(stack.Back(1)))
+		coldCost := gt.Calls - WarmStorageReadCostEIP2929
+		if contract.Gas < coldCost {
+			return 0, ErrOutOfGas
+		}
+		gt.Calls = WarmStorageReadCostEIP2929
+		contract.Gas -= coldCost
+		gas, err := oldCalculator(gt, evm, contract, stack, mem, memorySize)
+		contract.Gas += coldCost
+		if err != nil {
+			return 0, err
+		}
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, coldCost); overflow {
+			return 0, errGasUintOverflow
+		}
+		return gas, nil
+	}
+}
+
+var (
+	gasCallEIP2929         = makeCallVariantGasCallEIP2929(gasCall)
+	gasCallCodeEIP2929     = makeCallVariantGasCallEIP2929(gasCallCode)
+	gasDelegateCallEIP2929 = makeCallVariantGasCallEIP2929(gasDelegateCall)
+	gasStaticCallEIP2929   = makeCallVariantGasCallEIP2929(gasStaticCall)
+)
+
+// makeSelfdestructGasFn adds the access cost of a cold beneficiary to the SELFDESTRUCT
+// gas, refundsEnabled is unset from London on (EIP-3529).
+func makeSelfdestructGasFn(refundsEnabled bool) gasFunc {
+	return func(gt params.GasTable, evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		var (
+			gas     = gt.Suicide
+			address = common.BigToAddress(stack.Back(0))
+			cold    = evm.accessList.addAddress(address)
+		)
+		if HooksEnabled() {
+			hacker_access(evm, contract, cold)
+		}
+		if cold {
+			gas += ColdAccountAccessCostEIP2929
+		}
+		// if empty and transfers value
+		if evm.StateDB.Empty(address) && evm.StateDB.GetBalance(contract.Address()).Sign() != 0 {
+			gas += gt.CreateBySuicide
+		}
+		if refundsEnabled && !evm.StateDB.HasSuicided(contract.Address()) {
+			evm.StateDB.AddRefund(new(big.Int).SetUint64(params.SuicideRefundGas))
+		}
+		return gas, nil
+	}
+}
+
+var (
+	gasSelfdestructEIP2929 = makeSelfdestructGasFn(true)
+	gasSelfdestructEIP3529 = makeSelfdestructGasFn(false)
+)
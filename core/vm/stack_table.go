@@ -17,8 +17,6 @@
 package vm
 
 import (
-	"fmt"
-
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -29,7 +27,7 @@ func makeStackFunc(pop, push int) stackValidationFunc {
 		}
 
 		if stack.len()+push-pop > int(params.StackLimit) {
-			return fmt.Errorf("stack limit reached %d (%d)", stack.len(), params.StackLimit)
+			return &ErrStackOverflow{stackLen: stack.len(), limit: params.StackLimit}
 		}
 		return nil
 	}
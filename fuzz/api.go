@@ -0,0 +1,879 @@
+/**
+* @api.go
+* The "fuzz" RPC namespace used by the fuzzer to drive instrumented executions.
+* Every call runs on a fork of the state returned by the backend, nothing it does
+* (including state overrides) reaches the canonical state.
+ */
+package fuzz
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultGas is used when a call does not specify a gas limit.
+const defaultGas = 50000000
+
+var (
+	errFixtureAddress = errors.New("fixture without address")
+	errNegativeFund   = errors.New("negative amount")
+)
+
+// Backend gives the fuzz API access to the chain.
+type Backend interface {
+	// StateAndContext returns a state and block context for blockNr. The state is
+	// only mutated inside snapshots which are reverted.
+	StateAndContext(ctx context.Context, blockNr rpc.BlockNumber) (vm.StateDB, vm.Context, error)
+	ChainConfig() *params.ChainConfig
+}
+
+// APIs returns the RPC services of the fuzz namespace and the debug_fuzz methods.
+func APIs(b Backend) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "fuzz",
+			Version:   "1.0",
+			Service:   NewPublicFuzzAPI(b),
+			Public:    true,
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateDebugAPI(),
+		},
+	}
+}
+
+// PublicFuzzAPI implements the fuzz_ methods.
+type PublicFuzzAPI struct {
+	b Backend
+}
+
+func NewPublicFuzzAPI(b Backend) *PublicFuzzAPI {
+	return &PublicFuzzAPI{b: b}
+}
+
+// CallArgs are the arguments of fuzz_call.
+type CallArgs struct {
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Gas   *hexutil.Uint64 `json:"gas"`
+	Value *hexutil.Big    `json:"value"`
+	Data  hexutil.Bytes   `json:"data"`
+	// MaxFeePerGas and MaxPriorityFeePerGas make a dynamic fee call (EIP-1559)
+	MaxFeePerGas         *hexutil.Big `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big `json:"maxPriorityFeePerGas"`
+	// AccessList is warm from the start of the call (EIP-2930)
+	AccessList vm.HackerAccessList `json:"accessList"`
+	// Instrument watches the call like a transaction of the node, its report is sent
+	// to the sink
+	Instrument bool `json:"instrument"`
+	// Seed makes BLOCKHASH, DIFFICULTY and COINBASE functions of it for the call
+	Seed *common.Hash `json:"seed"`
+}
+
+func (args *CallArgs) message() *vm.HackerMessage {
+	msg := &vm.HackerMessage{From: args.From, To: args.To, Gas: defaultGas, Value: new(big.Int), Data: args.Data}
+	if args.Gas != nil {
+		msg.Gas = uint64(*args.Gas)
+	}
+	if args.Value != nil {
+		msg.Value = args.Value.ToInt()
+	}
+	if args.MaxFeePerGas != nil {
+		msg.GasFeeCap = args.MaxFeePerGas.ToInt()
+	}
+	if args.MaxPriorityFeePerGas != nil {
+		msg.GasTipCap = args.MaxPriorityFeePerGas.ToInt()
+	}
+	msg.AccessList = args.AccessList
+	msg.Seed = args.Seed
+	return msg
+}
+
+// CallResult is the result of fuzz_call.
+type CallResult struct {
+	ReturnData hexutil.Bytes               `json:"returnData"`
+	GasUsed    hexutil.Uint64              `json:"gasUsed"`
+	Failed     bool                        `json:"failed"`
+	Timeout    bool                        `json:"timeout,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+	Storage    map[common.Hash]common.Hash `json:"storage"`
+	// Report is the report of an instrumented call
+	Report *vm.HackerCallReport `json:"report,omitempty"`
+	// Differential compares the call with both versions of the code of a target set by
+	// fuzz_setDifferential
+	Differential *vm.HackerDifferentialReport `json:"differential,omitempty"`
+}
+
+func newCallResult(outcome *vm.HackerOutcome) *CallResult {
+	result := &CallResult{ReturnData: outcome.Ret, GasUsed: hexutil.Uint64(outcome.GasUsed), Failed: outcome.Failed(), Timeout: outcome.Timeout, Storage: outcome.Storage, Report: outcome.Report}
+	if outcome.Err != nil {
+		result.Error = outcome.Err.Error()
+	}
+	return result
+}
+
+func (api *PublicFuzzAPI) harness(ctx context.Context, blockNr rpc.BlockNumber) (*vm.HackerHarness, error) {
+	statedb, vmctx, err := api.b.StateAndContext(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	harness := vm.NewHackerHarness(vmctx, statedb, api.b.ChainConfig(), vm.Config{})
+	harness.SetChainID(vm.GetGlobalCampaign().ChainID())
+	harness.SetLimits(vm.GetGlobalCampaign().Limits())
+	harness.SetFixtures(vm.GetGlobalCampaign().FixtureOverride())
+	harness.SetFunding(vm.GetGlobalCampaign().Credits(), vm.GetGlobalCampaign().Faucet())
+	return harness, nil
+}
+
+// audit records the control operation method with its parameters in the audit log of
+// the campaign and returns its error.
+func audit(method string, err error, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	vm.GetGlobalCampaign().Audit(method, params, err)
+	return err
+}
+
+// auditOverrides records a call with params applying state overrides, the calls
+// without are not control operations.
+func auditOverrides(method string, overrides *StateOverride, err error, params ...interface{}) {
+	if overrides != nil && len(*overrides) > 0 {
+		audit(method, err, params...)
+	}
+}
+
+// Call executes args on top of blockNr with the optional state overrides applied first.
+// Any block whose state the node still has can be given, e.g. an old block of an
+// archive node: an instrumented call is reported with the context of that block.
+func (api *PublicFuzzAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (*CallResult, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	harness.SetInstrumented(args.Instrument)
+	override, err := overrides.toHacker()
+	auditOverrides("fuzz_call", overrides, err, args, blockNr, overrides)
+	if err != nil {
+		return nil, err
+	}
+	msg := args.message()
+	result := newCallResult(harness.ExecuteWithOverride(msg, override))
+	result.Differential = harness.Differential(msg, override)
+	return result, nil
+}
+
+// CallRaw executes the transaction envelope (legacy or EIP-2718 typed) sent by from on
+// top of blockNr, with its access list and fees. The sender is not recovered from the
+// signature, the fuzzer replays transactions under any sender.
+func (api *PublicFuzzAPI) CallRaw(ctx context.Context, envelope hexutil.Bytes, from common.Address, blockNr rpc.BlockNumber, overrides *StateOverride) (*CallResult, error) {
+	tx, err := vm.DecodeHackerTypedTx(envelope)
+	if err != nil {
+		return nil, err
+	}
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	override, err := overrides.toHacker()
+	auditOverrides("fuzz_callRaw", overrides, err, envelope, from, blockNr, overrides)
+	if err != nil {
+		return nil, err
+	}
+	msg := tx.Message(from)
+	result := newCallResult(harness.ExecuteWithOverride(msg, override))
+	result.Differential = harness.Differential(msg, override)
+	return result, nil
+}
+
+// FlashLoan executes args on top of blockNr with the sender lent loan, after the
+// optional state overrides, and repays the loan at the end: the result tells whether
+// the sender could repay it and its profit per asset.
+func (api *PublicFuzzAPI) FlashLoan(ctx context.Context, args CallArgs, loan *FlashLoan, blockNr rpc.BlockNumber, overrides *StateOverride) (*FlashLoanResult, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	harness.SetInstrumented(args.Instrument)
+	override, err := overrides.toHacker()
+	auditOverrides("fuzz_flashLoan", overrides, err, args, loan, blockNr, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return newFlashLoanResult(harness.ExecuteFlashLoan(args.message(), loan.toHacker(), override)), nil
+}
+
+// SimulateBundle executes msgs in order on one fork of the state of blockNr, after the
+// optional state overrides, each message seeing the effects of the previous ones. The
+// bundle is instrumented as a whole: its report, sent to the sink, has a section per
+// message.
+func (api *PublicFuzzAPI) SimulateBundle(ctx context.Context, msgs []CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (*BundleResult, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	harness.SetInstrumented(true)
+	override, err := overrides.toHacker()
+	auditOverrides("fuzz_simulateBundle", overrides, err, msgs, blockNr, overrides)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*vm.HackerMessage, len(msgs))
+	for i := range msgs {
+		messages[i] = msgs[i].message()
+	}
+	return newBundleResult(harness.ExecuteBundle(messages, override)), nil
+}
+
+// CallValidated executes an account abstraction operation on one fork of the state of
+// blockNr, after the optional state overrides: validation first, checked against the
+// rules of the validation phase (storage of the wallet only, no environment opcodes by
+// default), then execution when the validation succeeded. Each call has its own result
+// and, instrumented, its own report.
+func (api *PublicFuzzAPI) CallValidated(ctx context.Context, validation CallArgs, execution CallArgs, rules *vm.HackerValidationRules, blockNr rpc.BlockNumber, overrides *StateOverride) (*ValidatedResult, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	harness.SetInstrumented(validation.Instrument || execution.Instrument)
+	override, err := overrides.toHacker()
+	auditOverrides("fuzz_callValidated", overrides, err, validation, execution, rules, blockNr, overrides)
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := harness.ExecuteValidated(validation.message(), execution.message(), rules, override)
+	if err != nil {
+		return nil, err
+	}
+	return newValidatedResult(outcome), nil
+}
+
+// SetChainID overrides the chain id reported by CHAINID in the calls of the campaign,
+// so that messages signed for another chain can be replayed. A nil id restores the
+// chain id of the node.
+func (api *PublicFuzzAPI) SetChainID(id *hexutil.Big) {
+	audit("fuzz_setChainID", nil, id)
+	if id == nil {
+		vm.GetGlobalCampaign().SetChainID(nil)
+		return
+	}
+	vm.GetGlobalCampaign().SetChainID(id.ToInt())
+}
+
+// SetExecutionLimits bounds every call of the campaign to timeout milliseconds of wall
+// clock and maxSteps operations, zero leaves a bound off. A call cut short fails with
+// timeout set in its result.
+func (api *PublicFuzzAPI) SetExecutionLimits(timeout hexutil.Uint64, maxSteps hexutil.Uint64) {
+	audit("fuzz_setExecutionLimits", nil, timeout, maxSteps)
+	vm.GetGlobalCampaign().SetLimits(time.Duration(timeout)*time.Millisecond, uint64(maxSteps))
+}
+
+// SetStepBudget caps the operations of the transactions watched by the node,
+// independently of their gas, zero removes the cap. The reports carry the budget and
+// the operations counted.
+func (api *PublicFuzzAPI) SetStepBudget(budget hexutil.Uint64) {
+	audit("fuzz_setStepBudget", nil, budget)
+	vm.GetGlobalWatchDog().SetStepBudget(uint64(budget))
+	vm.GetGlobalTracerWatchDog().SetStepBudget(uint64(budget))
+}
+
+// SetProgressThreshold makes the node post a partial report of the transactions it
+// watches every steps operations (frame stack, operations, gas used), zero sends none.
+func (api *PublicFuzzAPI) SetProgressThreshold(steps hexutil.Uint64) {
+	audit("fuzz_setProgressThreshold", nil, steps)
+	vm.GetGlobalWatchDog().SetProgressThreshold(uint64(steps))
+	vm.GetGlobalTracerWatchDog().SetProgressThreshold(uint64(steps))
+}
+
+// Status returns the live status of the campaign: session, coverage growth, targets
+// and the health of the report delivery.
+func (api *PublicFuzzAPI) Status() *vm.HackerStatus {
+	return vm.GetGlobalCampaign().Status()
+}
+
+// Findings returns the last findings raised, at most limit of them (all of them for
+// zero), the most recent first.
+func (api *PublicFuzzAPI) Findings(limit hexutil.Uint64) []vm.HackerRecentFinding {
+	return vm.GetGlobalCampaign().Findings(int(limit))
+}
+
+// Session returns the accounting of the campaign over the blocks of the node.
+func (api *PublicFuzzAPI) Session() vm.HackerSession {
+	return vm.GetGlobalCampaign().Session()
+}
+
+// CallGraph returns the calls between contracts seen in the watched executions of the
+// campaign, with the selectors and how often.
+func (api *PublicFuzzAPI) CallGraph() []vm.HackerCallEdge {
+	return vm.GetGlobalCampaign().CallGraph()
+}
+
+// SelectorStats returns the selectors of target executed in the campaign with their
+// counts and rarity, rarest first.
+func (api *PublicFuzzAPI) SelectorStats(target common.Address) []vm.HackerSelectorStat {
+	return vm.GetGlobalCampaign().SelectorStats(target)
+}
+
+// RegisterTarget adds target to the campaign with the code it has at blockNr, the
+// constants of the code become its dictionary.
+func (api *PublicFuzzAPI) RegisterTarget(ctx context.Context, target common.Address, blockNr rpc.BlockNumber) error {
+	statedb, _, err := api.b.StateAndContext(ctx, blockNr)
+	if err != nil {
+		return audit("fuzz_registerTarget", err, target, blockNr)
+	}
+	audit("fuzz_registerTarget", nil, target, blockNr)
+	vm.GetGlobalCampaign().RegisterTarget(target, statedb.GetCode(target))
+	return nil
+}
+
+// Dictionary returns the selectors, addresses and magic values pushed by the code of
+// target, to be used as mutation dictionary entries.
+func (api *PublicFuzzAPI) Dictionary(target common.Address) []vm.HackerDictionaryEntry {
+	return vm.GetGlobalCampaign().Dictionary(target)
+}
+
+// WatchSlot pushes an alert on every write of the slot of addr, e.g. an owner or admin
+// slot, whether the transaction writing it is watched or not.
+func (api *PublicFuzzAPI) WatchSlot(addr common.Address, slot common.Hash) {
+	audit("fuzz_watchSlot", nil, addr, slot)
+	vm.WatchSlot(addr, slot)
+}
+
+// UnwatchSlot removes the watchpoint added by WatchSlot.
+func (api *PublicFuzzAPI) UnwatchSlot(addr common.Address, slot common.Hash) {
+	audit("fuzz_unwatchSlot", nil, addr, slot)
+	vm.UnwatchSlot(addr, slot)
+}
+
+// WatchBalance pushes an alert whenever an execution, watched or not, changes the
+// balance of addr by more than thresholdDelta wei.
+func (api *PublicFuzzAPI) WatchBalance(addr common.Address, thresholdDelta hexutil.Big) {
+	audit("fuzz_watchBalance", nil, addr, &thresholdDelta)
+	vm.WatchBalance(addr, thresholdDelta.ToInt())
+}
+
+// UnwatchBalance removes the watchpoint added by WatchBalance.
+func (api *PublicFuzzAPI) UnwatchBalance(addr common.Address) {
+	audit("fuzz_unwatchBalance", nil, addr)
+	vm.UnwatchBalance(addr)
+}
+
+// Coverage returns the branch coverage bitmaps of the campaign by code hash, including
+// the coverage resumed from the database of the node.
+func (api *PublicFuzzAPI) Coverage() []vm.HackerCoverageMap {
+	return vm.GetGlobalCampaign().Coverage()
+}
+
+// SetSaturation sets how many watched executions of a target without new coverage
+// downgrade it to calls-only instrumentation, zero never downgrades.
+func (api *PublicFuzzAPI) SetSaturation(executions hexutil.Uint64) {
+	audit("fuzz_setSaturation", nil, executions)
+	vm.GetGlobalCampaign().SetSaturation(uint64(executions))
+}
+
+// SetInstrumentation sets the instrumentation level of target, "full" or "calls".
+func (api *PublicFuzzAPI) SetInstrumentation(target common.Address, level string) error {
+	instrumentation, err := vm.ParseHackerInstrumentation(level)
+	if audit("fuzz_setInstrumentation", err, target, level) != nil {
+		return err
+	}
+	vm.GetGlobalCampaign().SetInstrumentation(target, instrumentation)
+	return nil
+}
+
+// Instrumentation returns the instrumentation level of target.
+func (api *PublicFuzzAPI) Instrumentation(target common.Address) string {
+	return vm.GetGlobalCampaign().Instrumentation(target).String()
+}
+
+// Corpus returns the inputs that covered new edges of target, found by this node or
+// received from the coordinator, all of them for the zero address.
+func (api *PublicFuzzAPI) Corpus(target common.Address) []vm.HackerCorpusEntry {
+	return vm.GetGlobalCampaign().Corpus(target)
+}
+
+// SyncCoordinator synchronises the campaign with the coordinator now instead of at the
+// next block end.
+func (api *PublicFuzzAPI) SyncCoordinator() (*vm.HackerSyncResult, error) {
+	coord := vm.GetCoordinator()
+	if coord == nil {
+		return nil, audit("fuzz_syncCoordinator", errNoCoordinator)
+	}
+	result, err := coord.Sync()
+	return result, audit("fuzz_syncCoordinator", err)
+}
+
+// Block leaves addr out of the trace of the watched transactions, e.g. an exchange or a
+// router; the reports summarise its frames.
+func (api *PublicFuzzAPI) Block(addr common.Address, label string) {
+	audit("fuzz_block", nil, addr, label)
+	vm.GetGlobalCampaign().Block(addr, label)
+}
+
+// Unblock removes addr from the blocklist.
+func (api *PublicFuzzAPI) Unblock(addr common.Address) {
+	audit("fuzz_unblock", nil, addr)
+	vm.GetGlobalCampaign().Unblock(addr)
+}
+
+// Blocklist returns the contracts left out of the trace.
+func (api *PublicFuzzAPI) Blocklist() []vm.HackerBlockedContract {
+	return vm.GetGlobalCampaign().Blocklist()
+}
+
+// SetPolicy compiles the rules of src deciding per transaction, from its sender,
+// target, selector and value, whether it is watched fully, calls-only or skipped. An
+// empty src watches every transaction.
+func (api *PublicFuzzAPI) SetPolicy(src string) error {
+	if src == "" {
+		audit("fuzz_setPolicy", nil, src)
+		vm.GetGlobalCampaign().SetPolicy(nil)
+		return nil
+	}
+	policy, err := vm.CompileHackerPolicy(src)
+	if audit("fuzz_setPolicy", err, src) != nil {
+		return err
+	}
+	vm.GetGlobalCampaign().SetPolicy(policy)
+	return nil
+}
+
+// SetBreakpoint breaks the watched executions before the operation at pc of the code
+// codeHash, of any code for the zero hash: the report of the transaction gets a
+// snapshot of the machine state and, in interactive mode, the execution pauses until
+// Resume.
+func (api *PublicFuzzAPI) SetBreakpoint(codeHash common.Hash, pc hexutil.Uint64) {
+	audit("fuzz_setBreakpoint", nil, codeHash, pc)
+	vm.SetBreakpoint(codeHash, uint64(pc))
+}
+
+// SetInteractive turns the pausing at breakpoints on or off, turning it off resumes
+// the paused executions.
+func (api *PublicFuzzAPI) SetInteractive(on bool) {
+	audit("fuzz_setInteractive", nil, on)
+	vm.SetInteractive(on)
+}
+
+// SetOpBreakpoint breaks the watched executions before every operation op, e.g.
+// "SSTORE".
+func (api *PublicFuzzAPI) SetOpBreakpoint(op string) error {
+	return audit("fuzz_setOpBreakpoint", vm.SetOpBreakpoint(op), op)
+}
+
+// ClearBreakpoints removes the breakpoints, the paused executions stay paused.
+func (api *PublicFuzzAPI) ClearBreakpoints() {
+	audit("fuzz_clearBreakpoints", nil)
+	vm.ClearBreakpoints()
+}
+
+// Paused returns the executions paused at a breakpoint with their stack and memory.
+func (api *PublicFuzzAPI) Paused() []vm.HackerPausedState {
+	return vm.Paused()
+}
+
+// PausedStorage returns the slot of the contract of the execution paused as id.
+func (api *PublicFuzzAPI) PausedStorage(id hexutil.Uint64, slot common.Hash) (common.Hash, error) {
+	return vm.PausedStorage(uint64(id), slot)
+}
+
+// Resume continues the execution paused as id, pausing it again at its next operation
+// when step is set.
+func (api *PublicFuzzAPI) Resume(id hexutil.Uint64, step bool) error {
+	return audit("fuzz_resume", vm.Resume(uint64(id), step), id, step)
+}
+
+// SetProfitOracle reports as a "profit" finding the watched transactions running code
+// of one of targets whose sender made a net profit, gas paid, above threshold wei. No
+// target turns the oracle off.
+func (api *PublicFuzzAPI) SetProfitOracle(targets []common.Address, threshold hexutil.Big) {
+	audit("fuzz_setProfitOracle", nil, targets, &threshold)
+	vm.GetGlobalCampaign().SetProfitOracle(targets, (*big.Int)(&threshold))
+}
+
+// SetProfitTokens registers the ERC-20 tokens whose balances of the sender the profit
+// oracle probes: a "profit_token" finding is a transaction giving its sender tokens
+// without costing it ether or another registered token.
+func (api *PublicFuzzAPI) SetProfitTokens(tokens []common.Address) {
+	audit("fuzz_setProfitTokens", nil, tokens)
+	vm.GetGlobalCampaign().SetProfitTokens(tokens)
+}
+
+// Label names addr in the reports, e.g. "Victim"; an empty label removes its label.
+func (api *PublicFuzzAPI) Label(addr common.Address, label string) {
+	audit("fuzz_label", nil, addr, label)
+	vm.GetGlobalCampaign().SetLabel(addr, label)
+}
+
+// ImportLabels names every address of labels.
+func (api *PublicFuzzAPI) ImportLabels(labels map[common.Address]string) {
+	audit("fuzz_importLabels", nil, labels)
+	vm.GetGlobalCampaign().ImportLabels(labels)
+}
+
+// Labels returns the labels of the campaign.
+func (api *PublicFuzzAPI) Labels() map[common.Address]string {
+	return vm.GetGlobalCampaign().Labels()
+}
+
+// SetRedaction sets the rules redacting the reports before they leave the node: the
+// memory of the snapshots dropped, the calldata of the frames capped, the storage of
+// contracts left out. Nil redacts nothing.
+func (api *PublicFuzzAPI) SetRedaction(rules *vm.HackerRedaction) {
+	audit("fuzz_setRedaction", nil, rules)
+	vm.GetGlobalCampaign().SetRedaction(rules)
+}
+
+// SetReportBudget sets the size budget of the reports: over maxBytes, their sections
+// are dropped in the order of priority (machine state snapshots first, then traces by
+// default) and listed in "elided". The findings and the coverage are never dropped.
+// Nil sends the reports whole.
+func (api *PublicFuzzAPI) SetReportBudget(budget *vm.HackerReportBudget) error {
+	err := vm.GetGlobalCampaign().SetReportBudget(budget)
+	audit("fuzz_setReportBudget", err, budget)
+	return err
+}
+
+// ReportBudget returns the size budget of the reports, nil when there is none.
+func (api *PublicFuzzAPI) ReportBudget() *vm.HackerReportBudget {
+	return vm.GetGlobalCampaign().ReportBudget()
+}
+
+// AuditLog returns the control operations of the campaign in order, with their
+// parameters, to reproduce its setup.
+func (api *PublicFuzzAPI) AuditLog() []vm.HackerAuditEntry {
+	return vm.GetGlobalCampaign().AuditLog()
+}
+
+// SetRevertCapture turns the revert-state inspection on or off: the reports of the
+// transactions which revert get the state diff as of their deepest failed frame,
+// before the rollback.
+func (api *PublicFuzzAPI) SetRevertCapture(on bool) {
+	audit("fuzz_setRevertCapture", nil, on)
+	vm.GetGlobalCampaign().SetRevertCapture(on)
+}
+
+// AddConstraint registers state which must never change, a slot or the return data of
+// a view call (e.g. owner()) of a contract, and returns its id. The watched
+// transactions changing it raise a "constraint_violation" finding.
+func (api *PublicFuzzAPI) AddConstraint(constraint vm.HackerConstraint) (hexutil.Uint64, error) {
+	id, err := vm.GetGlobalCampaign().AddConstraint(constraint)
+	audit("fuzz_addConstraint", err, constraint)
+	return hexutil.Uint64(id), err
+}
+
+// RemoveConstraint drops the constraint id.
+func (api *PublicFuzzAPI) RemoveConstraint(id hexutil.Uint64) {
+	audit("fuzz_removeConstraint", nil, id)
+	vm.GetGlobalCampaign().RemoveConstraint(uint64(id))
+}
+
+// Constraints returns the constraints with the number of transactions violating them.
+func (api *PublicFuzzAPI) Constraints() []vm.HackerConstraint {
+	return vm.GetGlobalCampaign().Constraints()
+}
+
+// SetNegativeMode turns the negative mode on or off: only the reports of the
+// transactions violating a constraint are sent.
+func (api *PublicFuzzAPI) SetNegativeMode(on bool) {
+	audit("fuzz_setNegativeMode", nil, on)
+	vm.GetGlobalCampaign().SetNegativeMode(on)
+}
+
+// RegisterProperties registers the function signatures of the harness contract of an
+// Echidna or Foundry property suite: its echidna_ and invariant_ functions are called
+// after every watched transaction, a false return or a revert is a
+// "property_violation" finding.
+func (api *PublicFuzzAPI) RegisterProperties(harness common.Address, signatures []string) ([]vm.HackerProperty, error) {
+	properties, err := vm.GetGlobalCampaign().RegisterProperties(harness, signatures)
+	audit("fuzz_registerProperties", err, harness, signatures)
+	return properties, err
+}
+
+// UnregisterProperties stops checking the properties of harness.
+func (api *PublicFuzzAPI) UnregisterProperties(harness common.Address) {
+	audit("fuzz_unregisterProperties", nil, harness)
+	vm.GetGlobalCampaign().UnregisterProperties(harness)
+}
+
+// Properties returns the properties checked with their failures.
+func (api *PublicFuzzAPI) Properties() []vm.HackerProperty {
+	return vm.GetGlobalCampaign().Properties()
+}
+
+// DumpContract returns the code, balance, nonce and full storage of addr at blockNr,
+// the loaded fixtures included, to be loaded by fuzz_loadContract on this node or
+// another one.
+func (api *PublicFuzzAPI) DumpContract(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) (*vm.HackerContractFixture, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return harness.DumpContract(addr), nil
+}
+
+// LoadContract installs fixture, replacing the whole account, on the fork of every
+// execution of the fuzz API until it is unloaded.
+func (api *PublicFuzzAPI) LoadContract(fixture vm.HackerContractFixture) error {
+	var err error
+	if fixture.Address == (common.Address{}) {
+		err = errFixtureAddress
+	}
+	audit("fuzz_loadContract", err, fixture)
+	if err != nil {
+		return err
+	}
+	vm.GetGlobalCampaign().LoadFixture(&fixture)
+	return nil
+}
+
+// UnloadContract drops the fixture of addr.
+func (api *PublicFuzzAPI) UnloadContract(addr common.Address) {
+	audit("fuzz_unloadContract", nil, addr)
+	vm.GetGlobalCampaign().UnloadFixture(addr)
+}
+
+// Fixtures returns the fixtures loaded.
+func (api *PublicFuzzAPI) Fixtures() []*vm.HackerContractFixture {
+	return vm.GetGlobalCampaign().Fixtures()
+}
+
+// SetDifferential executes every call and watched transaction to target with both
+// versions of its code, e.g. before and after a patch, and reports their divergences.
+// An empty version stands for the code of target in the state.
+func (api *PublicFuzzAPI) SetDifferential(target common.Address, first, second hexutil.Bytes) {
+	audit("fuzz_setDifferential", nil, target, first, second)
+	vm.GetGlobalCampaign().SetDifferential(target, first, second)
+}
+
+// ClearDifferential stops the differential executions of target.
+func (api *PublicFuzzAPI) ClearDifferential(target common.Address) {
+	audit("fuzz_clearDifferential", nil, target)
+	vm.GetGlobalCampaign().ClearDifferential(target)
+}
+
+// SetFrontRunning replays every watched transaction after a copy of it sent by
+// competitor, the "frontRunning" section of the report tells whether its outcome
+// changed; a nil competitor turns the replay off.
+func (api *PublicFuzzAPI) SetFrontRunning(competitor *common.Address) {
+	audit("fuzz_setFrontRunning", nil, competitor)
+	for _, dog := range []*vm.WatchDog{vm.GetGlobalWatchDog(), vm.GetGlobalTracerWatchDog()} {
+		if competitor == nil {
+			dog.DisableFrontRunning()
+		} else {
+			dog.EnableFrontRunning(*competitor)
+		}
+	}
+}
+
+// SetGriefing turns the gas griefing probe on or off: every watched transaction is
+// replayed once per account its target calls, with the code of that account replaced
+// by a stub burning all its gas, and the callees making it fail or whose failure it
+// ignores are "gas_griefing" findings.
+func (api *PublicFuzzAPI) SetGriefing(on bool) {
+	audit("fuzz_setGriefing", nil, on)
+	vm.GetGlobalCampaign().SetGriefing(on)
+}
+
+// SetRevertBomb turns the revert bomb probe on or off: every watched transaction is
+// replayed once per account its target calls, with the code of that account replaced
+// by a stub always reverting, and the callees making it fail are "revert_bomb"
+// findings.
+func (api *PublicFuzzAPI) SetRevertBomb(on bool) {
+	audit("fuzz_setRevertBomb", nil, on)
+	vm.GetGlobalCampaign().SetRevertBomb(on)
+}
+
+// SetReturnPoisoning turns the return data poisoning probe on or off: every watched
+// transaction is replayed once per account its target calls with the code of that
+// account replaced by stubs returning empty, short, dirty or oversized data, and the
+// replays changing its outcome are "returndata_poisoning" findings.
+func (api *PublicFuzzAPI) SetReturnPoisoning(on bool) {
+	audit("fuzz_setReturnPoisoning", nil, on)
+	vm.GetGlobalCampaign().SetReturnPoisoning(on)
+}
+
+// SetTimeSkew turns the timestamp skew replays on or off: every watched transaction is
+// replayed with the timestamp of its block moved forward by each offset, in seconds
+// (+1s, +15s and +1 day when none is given), and the report tells which offsets
+// change its outcome.
+func (api *PublicFuzzAPI) SetTimeSkew(on bool, offsets []hexutil.Uint64) {
+	audit("fuzz_setTimeSkew", nil, on, offsets)
+	skews := make([]uint64, len(offsets))
+	for i, offset := range offsets {
+		skews[i] = uint64(offset)
+	}
+	vm.GetGlobalCampaign().SetTimeSkew(on, skews)
+}
+
+// TimeSkews returns the timestamp offsets of the replays, empty when they are off.
+func (api *PublicFuzzAPI) TimeSkews() []hexutil.Uint64 {
+	skews := vm.GetGlobalCampaign().TimeSkews()
+	offsets := make([]hexutil.Uint64, len(skews))
+	for i, skew := range skews {
+		offsets[i] = hexutil.Uint64(skew)
+	}
+	return offsets
+}
+
+// SetFeeSkew turns the fee variant replays on or off: every watched transaction is
+// replayed with the gas price and the base fee of each variant (0 and 1000 gwei for
+// each when none is given), and the report tells which variants change its outcome.
+func (api *PublicFuzzAPI) SetFeeSkew(on bool, variants []vm.HackerFeeVariant) {
+	audit("fuzz_setFeeSkew", nil, on, variants)
+	vm.GetGlobalCampaign().SetFeeSkew(on, variants)
+}
+
+// FeeVariants returns the fee variants of the replays, empty when they are off.
+func (api *PublicFuzzAPI) FeeVariants() []vm.HackerFeeVariant {
+	return vm.GetGlobalCampaign().FeeVariants()
+}
+
+// StorageRange returns the non-empty slots of the watched contract addr at blockNr
+// from the key start on, at most limit of them (default 1024): the next page starts at
+// the key returned as next.
+func (api *PublicFuzzAPI) StorageRange(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber, start common.Hash, limit hexutil.Uint64) (*vm.HackerStorageRange, error) {
+	harness, err := api.harness(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return harness.StorageRange(addr, start, int(limit))
+}
+
+// Disassemble returns the disassembly of the code of codeHash annotated per
+// instruction with the branch hits, the operands of the comparisons and the findings
+// of the watched transactions that ran it.
+func (api *PublicFuzzAPI) Disassemble(codeHash common.Hash) (*vm.HackerDisassembly, error) {
+	return vm.GetGlobalCampaign().Disassemble(codeHash)
+}
+
+// SetDetectorBudget sets the latency budget, in milliseconds, of the watched
+// transactions: over it the most expensive detectors are sampled then disabled, under
+// half of it they are restored, and the reports list the detectors active for their
+// transaction. Zero runs all the detectors again.
+func (api *PublicFuzzAPI) SetDetectorBudget(latency hexutil.Uint64) {
+	audit("fuzz_setDetectorBudget", nil, latency)
+	vm.GetGlobalCampaign().SetDetectorBudget(time.Duration(latency) * time.Millisecond)
+}
+
+// Detectors returns the average cost per transaction and the state of the detectors.
+func (api *PublicFuzzAPI) Detectors() []vm.HackerDetectorStat {
+	return vm.GetGlobalCampaign().Detectors()
+}
+
+// SetTraceSampling samples the opcode trace of the watched transactions by class of
+// operation (arithmetic, comparison, bitwise, sha3, environment, stack, memory,
+// storage, flow, log, system): a class mapped to N keeps one operation of N in the
+// trace. The classes not listed, e.g. the calls and the storage accesses, are kept
+// whole; an empty map keeps the whole trace again.
+func (api *PublicFuzzAPI) SetTraceSampling(sampling map[string]hexutil.Uint64) error {
+	every := make(map[string]uint64, len(sampling))
+	for class, n := range sampling {
+		every[class] = uint64(n)
+	}
+	err := vm.GetGlobalCampaign().SetTraceSampling(every)
+	audit("fuzz_setTraceSampling", err, sampling)
+	return err
+}
+
+// TraceSampling returns the sampling of the trace by class of operation.
+func (api *PublicFuzzAPI) TraceSampling() map[string]hexutil.Uint64 {
+	sampling := make(map[string]hexutil.Uint64)
+	for class, n := range vm.GetGlobalCampaign().TraceSampling() {
+		sampling[class] = hexutil.Uint64(n)
+	}
+	return sampling
+}
+
+// Fund credits amount to addr on the fork of every execution of the fuzz API, on top
+// of its previous credits. The canonical state is never touched.
+func (api *PublicFuzzAPI) Fund(addr common.Address, amount hexutil.Big) error {
+	var err error
+	if amount.ToInt().Sign() < 0 {
+		err = errNegativeFund
+	}
+	audit("fuzz_fund", err, addr, amount)
+	if err != nil {
+		return err
+	}
+	vm.GetGlobalCampaign().Fund(addr, amount.ToInt())
+	return nil
+}
+
+// Credits returns the amounts credited by fuzz_fund by account.
+func (api *PublicFuzzAPI) Credits() map[common.Address]*hexutil.Big {
+	credits := make(map[common.Address]*hexutil.Big)
+	for addr, credit := range vm.GetGlobalCampaign().Credits() {
+		credits[addr] = (*hexutil.Big)(credit)
+	}
+	return credits
+}
+
+// SetFaucet tops the sender accounts of the fuzzer up on the forks of the fuzz API: a
+// message of a sender whose balance is below the threshold (the amount when none) is
+// executed with the amount credited first. Null turns the faucet off.
+func (api *PublicFuzzAPI) SetFaucet(faucet *vm.HackerFaucet) error {
+	err := vm.GetGlobalCampaign().SetFaucet(faucet)
+	audit("fuzz_setFaucet", err, faucet)
+	return err
+}
+
+// Faucet returns the faucet of the senders, null when it is off.
+func (api *PublicFuzzAPI) Faucet() *vm.HackerFaucet {
+	return vm.GetGlobalCampaign().Faucet()
+}
+
+// AllocateNonce hands out a nonce of sender to a worker of the fuzzer: the lowest one
+// neither handed out nor mined in the pending state, a nonce released first. The
+// workers sharing a sender get no duplicate and leave no gap.
+func (api *PublicFuzzAPI) AllocateNonce(ctx context.Context, sender common.Address) (hexutil.Uint64, error) {
+	statedb, _, err := api.b.StateAndContext(ctx, rpc.PendingBlockNumber)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(vm.GetGlobalCampaign().AllocateNonce(sender, statedb.GetNonce(sender))), nil
+}
+
+// ReleaseNonce gives back a nonce of sender handed out by fuzz_allocateNonce to a
+// candidate dropped before it was sent, the next allocation reuses it.
+func (api *PublicFuzzAPI) ReleaseNonce(sender common.Address, nonce hexutil.Uint64) error {
+	return vm.GetGlobalCampaign().ReleaseNonce(sender, uint64(nonce))
+}
+
+// ResetNonces drops the nonce allocation of sender, e.g. after its pending
+// transactions were evicted.
+func (api *PublicFuzzAPI) ResetNonces(sender common.Address) {
+	audit("fuzz_resetNonces", nil, sender)
+	vm.GetGlobalCampaign().ResetNonces(sender)
+}
+
+// Nonces returns the nonce allocation of sender, null when none was handed out.
+func (api *PublicFuzzAPI) Nonces(sender common.Address) *vm.HackerNonceState {
+	return vm.GetGlobalCampaign().Nonces(sender)
+}
+
+// SetRefundThreshold reports the watched transactions whose refunds reach percent of
+// the gas they used before the refund as refund_abuse findings, zero reports none.
+func (api *PublicFuzzAPI) SetRefundThreshold(percent hexutil.Uint64) {
+	audit("fuzz_setRefundThreshold", nil, percent)
+	vm.GetGlobalCampaign().SetRefundThreshold(uint64(percent))
+}
+
+// RefundThreshold returns the refund threshold, a percentage of the gas.
+func (api *PublicFuzzAPI) RefundThreshold() hexutil.Uint64 {
+	return hexutil.Uint64(vm.GetGlobalCampaign().RefundThreshold())
+}
+
+// GasRegressions returns the significant gas changes per selector seen across redeployments.
+func (api *PublicFuzzAPI) GasRegressions() []vm.HackerGasRegression {
+	return vm.GetGlobalCampaign().GasRegressions()
+}
@@ -0,0 +1,24 @@
+/**
+* @bundle.go
+* JSON form of the result of fuzz_simulateBundle: the result of every message of the
+* bundle in order, with the report of the bundle sent to the sink.
+ */
+package fuzz
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// BundleResult is the result of fuzz_simulateBundle.
+type BundleResult struct {
+	Steps  []*CallResult        `json:"steps"`
+	Report *vm.HackerCallReport `json:"report"`
+}
+
+func newBundleResult(outcome *vm.HackerBundleOutcome) *BundleResult {
+	result := &BundleResult{Steps: make([]*CallResult, 0, len(outcome.Steps)), Report: outcome.Report}
+	for _, step := range outcome.Steps {
+		result.Steps = append(result.Steps, newCallResult(step))
+	}
+	return result
+}
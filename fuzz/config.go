@@ -0,0 +1,275 @@
+/**
+* @config.go
+* Node configuration of the fuzz campaign. The node registers the flags on its flag set
+* and calls Apply once the flags are parsed:
+*   --fuzz.coordinator        URL of the coordinator of a distributed campaign
+*   --fuzz.coordinator.node   name of the node in the campaign (default: the host name)
+*   --fuzz.coordinator.blocks blocks between two synchronisations
+*   --fuzz.blocklist          contracts left out of the trace, address[=label],...
+*   --fuzz.policy             file of the rules deciding how transactions are watched
+*                             (hacker_policy.go)
+*   --fuzz.labels             JSON file of the labels of addresses, {"0x...": "Victim"}
+*   --fuzz.redact             JSON file of the redaction rules of the reports
+*                             (hacker_redact.go)
+*   --fuzz.dashboard          listen address of the web dashboard, e.g. localhost:8590
+*                             (dashboard.go)
+*   --fuzz.metrics            listen address of the Prometheus endpoint (metrics.go)
+*   --fuzz.constraints        JSON file of the must-hold constraints
+*                             (hacker_constraint.go)
+*   --fuzz.negative           send only the reports of the constraint violations
+*   --fuzz.fixtures           JSON file of the contract fixtures installed on the forks
+*                             of the fuzz API (hacker_fixture.go)
+*   --fuzz.plugins            directory of the *.wasm oracle plugins checked at the end
+*                             of each watched transaction (hacker_plugin.go)
+*   --fuzz.fork               fork after Byzantium whose rules the blocks from
+*                             Byzantium on run (hacker_fork.go), default: none
+*   --fuzz.off                turn the hooks of the instrumentation off, the node runs
+*                             as a normal node (hacker_hooks.go), set by default by the
+*                             GETH_NOFUZZ environment variable
+ */
+package fuzz
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/fuzz/report"
+)
+
+// nofuzzEnv is the environment variable turning the hooks off by default.
+const nofuzzEnv = "GETH_NOFUZZ"
+
+// defaultCoordinatorBlocks is the number of blocks between two synchronisations.
+const defaultCoordinatorBlocks = 16
+
+var errNoCoordinator = errors.New("no coordinator configured")
+
+// Config is the configuration of the campaign of the node.
+type Config struct {
+	Coordinator       string
+	CoordinatorNode   string
+	CoordinatorBlocks uint64
+	// Blocklist holds address[=label] entries separated by commas
+	Blocklist string
+	// Policy is the file of the policy rules
+	Policy string
+	// Labels is the JSON file of the address labels
+	Labels string
+	// Redact is the JSON file of the redaction rules
+	Redact string
+	// Dashboard is the listen address of the dashboard, none when empty
+	Dashboard string
+	// Metrics is the listen address of the Prometheus endpoint, none when empty
+	Metrics string
+	// Constraints is the JSON file of the must-hold constraints, Negative sends only
+	// the reports violating them
+	Constraints string
+	Negative    bool
+	// Fixtures is the JSON file of the contract fixtures, as dumped by fuzz_dumpContract
+	Fixtures string
+	// Plugins is the directory of the WebAssembly oracle plugins, none when empty
+	Plugins string
+	// Fork is the fork after Byzantium opted in to, none when empty
+	Fork string
+	// Off turns the hooks of the instrumentation off, nothing else is applied
+	Off bool
+}
+
+// Flags registers the fuzz flags on set, parsed into config.
+func (config *Config) Flags(set *flag.FlagSet) {
+	set.StringVar(&config.Coordinator, "fuzz.coordinator", config.Coordinator, "URL of the coordinator of a distributed fuzz campaign")
+	set.StringVar(&config.CoordinatorNode, "fuzz.coordinator.node", config.CoordinatorNode, "Name of the node in the distributed fuzz campaign (default: the host name)")
+	set.Uint64Var(&config.CoordinatorBlocks, "fuzz.coordinator.blocks", defaultCoordinatorBlocks, "Blocks between two synchronisations with the coordinator")
+	set.StringVar(&config.Blocklist, "fuzz.blocklist", config.Blocklist, "Contracts left out of the trace, comma separated address[=label]")
+	set.StringVar(&config.Policy, "fuzz.policy", config.Policy, "File of the rules deciding which transactions are watched and how deeply")
+	set.StringVar(&config.Labels, "fuzz.labels", config.Labels, "JSON file of the labels of addresses in the reports")
+	set.StringVar(&config.Redact, "fuzz.redact", config.Redact, "JSON file of the rules redacting the reports before they are sent")
+	set.StringVar(&config.Dashboard, "fuzz.dashboard", config.Dashboard, "Listen address of the web dashboard of the campaign (default: none)")
+	set.StringVar(&config.Metrics, "fuzz.metrics", config.Metrics, "Listen address of the Prometheus endpoint of the campaign (default: none)")
+	set.StringVar(&config.Constraints, "fuzz.constraints", config.Constraints, "JSON file of the state which must never change, [{\"address\": ..., \"slot\": ...}]")
+	set.BoolVar(&config.Negative, "fuzz.negative", config.Negative, "Send only the reports of the transactions violating a constraint")
+	set.StringVar(&config.Fixtures, "fuzz.fixtures", config.Fixtures, "JSON file of the contract fixtures installed on the forks of the fuzz API")
+	set.StringVar(&config.Plugins, "fuzz.plugins", config.Plugins, "Directory of the *.wasm oracle plugins checked at the end of each watched transaction")
+	set.StringVar(&config.Fork, "fuzz.fork", config.Fork, "Fork after Byzantium whose rules the blocks run: constantinople, istanbul, berlin, london or shanghai (default: none)")
+	set.BoolVar(&config.Off, "fuzz.off", config.Off || os.Getenv(nofuzzEnv) != "", "Turn the instrumentation off and run as a normal node (default: set by "+nofuzzEnv+")")
+}
+
+// Apply opts in to the fork, installs the HTTP transport of the reports, blocks the
+// contracts of the blocklist, loads the policy, the labels, the redaction rules, the
+// constraints, the fixtures and the oracle plugins, serves the dashboard and the
+// metrics and starts the coordination of the campaign configured, if any. Off only
+// turns the hooks off.
+func (config *Config) Apply() (*vm.HackerCoordinator, error) {
+	vm.SetHooks(!config.Off)
+	if config.Off {
+		return nil, nil
+	}
+	if err := config.applyFork(); err != nil {
+		return nil, err
+	}
+	vm.SetTransport(report.NewHTTP())
+	if err := config.applyBlocklist(); err != nil {
+		return nil, err
+	}
+	if err := config.applyPolicy(); err != nil {
+		return nil, err
+	}
+	if err := config.applyLabels(); err != nil {
+		return nil, err
+	}
+	if err := config.applyRedaction(); err != nil {
+		return nil, err
+	}
+	if err := config.applyConstraints(); err != nil {
+		return nil, err
+	}
+	if err := config.applyFixtures(); err != nil {
+		return nil, err
+	}
+	if config.Plugins != "" {
+		if err := vm.LoadOraclePlugins(config.Plugins); err != nil {
+			return nil, fmt.Errorf("fuzz.plugins %s: %v", config.Plugins, err)
+		}
+	}
+	if config.Dashboard != "" {
+		if _, err := StartDashboard(config.Dashboard); err != nil {
+			return nil, fmt.Errorf("fuzz.dashboard %s: %v", config.Dashboard, err)
+		}
+	}
+	if config.Metrics != "" {
+		if _, err := StartMetrics(config.Metrics); err != nil {
+			return nil, fmt.Errorf("fuzz.metrics %s: %v", config.Metrics, err)
+		}
+	}
+	node := config.CoordinatorNode
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+	return vm.StartCoordinator(config.Coordinator, node, config.CoordinatorBlocks), nil
+}
+
+func (config *Config) applyFork() error {
+	if config.Fork == "" {
+		return nil
+	}
+	fork, err := vm.ParseFork(config.Fork)
+	if err != nil {
+		return fmt.Errorf("fuzz.fork: %v", err)
+	}
+	vm.GetGlobalCampaign().SetFork(fork)
+	return nil
+}
+
+func (config *Config) applyBlocklist() error {
+	if config.Blocklist == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(config.Blocklist, ",") {
+		addr, label := strings.TrimSpace(entry), ""
+		if i := strings.Index(addr, "="); i >= 0 {
+			addr, label = addr[:i], addr[i+1:]
+		}
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("fuzz.blocklist: invalid address %q", addr)
+		}
+		vm.GetGlobalCampaign().Block(common.HexToAddress(addr), label)
+	}
+	return nil
+}
+
+func (config *Config) applyPolicy() error {
+	if config.Policy == "" {
+		return nil
+	}
+	src, err := ioutil.ReadFile(config.Policy)
+	if err != nil {
+		return err
+	}
+	policy, err := vm.CompileHackerPolicy(string(src))
+	if err != nil {
+		return fmt.Errorf("fuzz.policy %s: %v", config.Policy, err)
+	}
+	vm.GetGlobalCampaign().SetPolicy(policy)
+	return nil
+}
+
+func (config *Config) applyLabels() error {
+	if config.Labels == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(config.Labels)
+	if err != nil {
+		return err
+	}
+	labels := make(map[common.Address]string)
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return fmt.Errorf("fuzz.labels %s: %v", config.Labels, err)
+	}
+	vm.GetGlobalCampaign().ImportLabels(labels)
+	return nil
+}
+
+func (config *Config) applyRedaction() error {
+	if config.Redact == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(config.Redact)
+	if err != nil {
+		return err
+	}
+	rules := new(vm.HackerRedaction)
+	if err := json.Unmarshal(data, rules); err != nil {
+		return fmt.Errorf("fuzz.redact %s: %v", config.Redact, err)
+	}
+	vm.GetGlobalCampaign().SetRedaction(rules)
+	return nil
+}
+
+func (config *Config) applyConstraints() error {
+	vm.GetGlobalCampaign().SetNegativeMode(config.Negative)
+	if config.Constraints == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(config.Constraints)
+	if err != nil {
+		return err
+	}
+	var constraints []vm.HackerConstraint
+	if err := json.Unmarshal(data, &constraints); err != nil {
+		return fmt.Errorf("fuzz.constraints %s: %v", config.Constraints, err)
+	}
+	for i, constraint := range constraints {
+		if _, err := vm.GetGlobalCampaign().AddConstraint(constraint); err != nil {
+			return fmt.Errorf("fuzz.constraints %s: constraint %d: %v", config.Constraints, i, err)
+		}
+	}
+	return nil
+}
+
+func (config *Config) applyFixtures() error {
+	if config.Fixtures == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(config.Fixtures)
+	if err != nil {
+		return err
+	}
+	var fixtures []*vm.HackerContractFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return fmt.Errorf("fuzz.fixtures %s: %v", config.Fixtures, err)
+	}
+	for i, fixture := range fixtures {
+		if fixture == nil || fixture.Address == (common.Address{}) {
+			return fmt.Errorf("fuzz.fixtures %s: fixture %d: %v", config.Fixtures, i, errFixtureAddress)
+		}
+		vm.GetGlobalCampaign().LoadFixture(fixture)
+	}
+	return nil
+}
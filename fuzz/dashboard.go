@@ -0,0 +1,136 @@
+/**
+* @dashboard.go
+* Web dashboard of the campaign, served by the node on its own address
+* (--fuzz.dashboard). The page polls the same data as fuzz_status and fuzz_findings:
+*   /             the page: session, coverage growth, targets, recent findings and the
+*                 health of the report delivery, refreshed every few seconds.
+*   /api/status   fuzz_status as JSON.
+*   /api/findings fuzz_findings as JSON, ?limit=n.
+* It is read-only, nothing of the campaign can be changed from it.
+ */
+package fuzz
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// dashboardFindings is the number of findings shown by the page.
+const dashboardFindings = 50
+
+// NewDashboard returns the handler of the dashboard.
+func NewDashboard() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, vm.GetGlobalCampaign().Status())
+	})
+	mux.HandleFunc("/api/findings", func(w http.ResponseWriter, r *http.Request) {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 0 {
+			limit = dashboardFindings
+		}
+		writeJSON(w, vm.GetGlobalCampaign().Findings(limit))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// StartDashboard serves the dashboard on addr until the node exits.
+func StartDashboard(addr string) (net.Listener, error) {
+	return serve(addr, NewDashboard())
+}
+
+// serve serves handler on addr in the background.
+func serve(addr string, handler http.Handler) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(listener, handler)
+	return listener, nil
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Fuzz campaign</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 1.5em; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 10px; border-bottom: 1px solid #ddd; text-align: left; font-size: 13px; }
+.mono { font-family: monospace; }
+.bad { color: #b00; }
+#growth { border: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>Fuzz campaign</h1>
+<div id="session"></div>
+<h2>Coverage</h2>
+<div id="edges"></div>
+<svg id="growth" width="600" height="120"><polyline id="line" fill="none" stroke="#36c" stroke-width="2"/></svg>
+<h2>Reports</h2>
+<div id="reports"></div>
+<h2>Targets</h2>
+<table id="targets"><tr><th>address</th><th>label</th><th>executions</th><th>selectors</th><th>instrumentation</th><th>corpus</th></tr></table>
+<h2>Recent findings</h2>
+<table id="findings"><tr><th>time</th><th>type</th><th>source</th><th>target</th><th>transaction</th><th>status</th></tr></table>
+<script>
+function text(s) { var d = document.createElement("div"); d.textContent = s; return d.innerHTML; }
+function rows(id, items, cells) {
+  var table = document.getElementById(id);
+  while (table.rows.length > 1) table.deleteRow(1);
+  items.forEach(function (item) {
+    var row = table.insertRow();
+    cells(item).forEach(function (cell) { row.insertCell().innerHTML = cell; });
+  });
+}
+function when(t) { return t ? new Date(t * 1000).toLocaleTimeString() : "-"; }
+function refresh() {
+  fetch("api/status").then(function (r) { return r.json(); }).then(function (s) {
+    var session = s.session;
+    document.getElementById("session").innerHTML = "blocks " + session.blocks + ", last block " + session.lastBlock +
+      ", transactions " + session.transactions + ", watched " + session.watched + ", reported " + session.reported + ", findings " + session.findings;
+    document.getElementById("edges").textContent = s.edges + " edges";
+    var growth = s.growth || [], max = 1;
+    growth.forEach(function (p) { max = Math.max(max, p.edges); });
+    document.getElementById("line").setAttribute("points", growth.map(function (p, i) {
+      return (growth.length > 1 ? i * 600 / (growth.length - 1) : 0) + "," + (115 - p.edges * 110 / max);
+    }).join(" "));
+    var reports = s.reports;
+    document.getElementById("reports").innerHTML = "sent " + reports.sent + ", last " + when(reports.lastSent) +
+      (reports.failed ? ", <span class=bad>failed " + reports.failed + ": " + text(reports.lastError) + "</span>" : ", none failed");
+    rows("targets", s.targets || [], function (t) {
+      return ["<span class=mono>" + t.address + "</span>", text(t.label || ""), t.executions, t.selectors, t.instrumentation, t.corpus];
+    });
+  });
+  fetch("api/findings").then(function (r) { return r.json(); }).then(function (findings) {
+    rows("findings", findings || [], function (f) {
+      return [when(f.time), text(f.type), text(f.source), "<span class=mono>" + f.target + "</span>", "<span class=mono>" + f.hash + "</span>", text(f.status || "")];
+    });
+  });
+}
+refresh();
+setInterval(refresh, 3000);
+</script>
+</body>
+</html>
+`
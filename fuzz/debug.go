@@ -0,0 +1,23 @@
+/**
+* @debug.go
+* The debug_fuzz methods, inspecting the instrumentation of the node. Registered with
+* the fuzz namespace (APIs), not public.
+ */
+package fuzz
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// PrivateDebugAPI implements the debug_fuzz methods.
+type PrivateDebugAPI struct{}
+
+func NewPrivateDebugAPI() *PrivateDebugAPI {
+	return &PrivateDebugAPI{}
+}
+
+// FuzzWatchdog returns the state of the WatchDog of the node: the transaction it
+// watches, its flags and the sizes of its recordings.
+func (api *PrivateDebugAPI) FuzzWatchdog() *vm.HackerWatchDogState {
+	return vm.GetGlobalWatchDog().State()
+}
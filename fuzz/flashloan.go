@@ -0,0 +1,55 @@
+/**
+* @flashloan.go
+* JSON form of the flash loans of fuzz_flashLoan: the ether and the ERC-20 tokens lent
+* to the sender of the call, each token with the slot of its balances mapping.
+ */
+package fuzz
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// TokenLoan is an amount of an ERC-20 token lent.
+type TokenLoan struct {
+	Token  common.Address `json:"token"`
+	Slot   common.Hash    `json:"slot"`
+	Amount hexutil.Big    `json:"amount"`
+}
+
+// FlashLoan is the loan parameter of fuzz_flashLoan.
+type FlashLoan struct {
+	Ether  *hexutil.Big `json:"ether"`
+	Tokens []TokenLoan  `json:"tokens"`
+}
+
+func (loan *FlashLoan) toHacker() *vm.HackerFlashLoan {
+	hacker := new(vm.HackerFlashLoan)
+	if loan == nil {
+		return hacker
+	}
+	if loan.Ether != nil {
+		hacker.Ether = loan.Ether.ToInt()
+	}
+	for _, token := range loan.Tokens {
+		hacker.Tokens = append(hacker.Tokens, vm.HackerTokenLoan{Token: token.Token, Slot: token.Slot, Amount: token.Amount.ToInt()})
+	}
+	return hacker
+}
+
+// FlashLoanResult is the result of fuzz_flashLoan.
+type FlashLoanResult struct {
+	*CallResult
+	Repaid bool `json:"repaid"`
+	// Profits are per token, ether under the zero address
+	Profits map[common.Address]*hexutil.Big `json:"profits"`
+}
+
+func newFlashLoanResult(outcome *vm.HackerOutcome) *FlashLoanResult {
+	result := &FlashLoanResult{CallResult: newCallResult(outcome), Repaid: outcome.Loan.Repaid, Profits: make(map[common.Address]*hexutil.Big)}
+	for asset, profit := range outcome.Loan.Profits {
+		result.Profits[asset] = (*hexutil.Big)(profit)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+/**
+* @metrics.go
+* Prometheus endpoint of the campaign, served on its own address (--fuzz.metrics) at
+* /metrics in the text exposition format, for the alerting on long-running campaigns:
+*   the session      fuzz_blocks_total, fuzz_transactions_total, fuzz_watched_total,
+*                    fuzz_last_block
+*   the coverage     fuzz_coverage_edges, fuzz_targets, fuzz_corpus_entries
+*   the oracles      fuzz_findings_total{type}
+*   the sink         fuzz_reports_sent_total, fuzz_reports_dropped_total and the
+*                    fuzz_report_latency_seconds histogram
+* The rates, e.g. of the reports per second, are left to the queries.
+ */
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// NewMetrics returns the handler of the Prometheus endpoint.
+func NewMetrics() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(formatMetrics(vm.GetGlobalCampaign().Metrics()))
+	})
+	return mux
+}
+
+// StartMetrics serves the Prometheus endpoint on addr until the node exits.
+func StartMetrics(addr string) (net.Listener, error) {
+	return serve(addr, NewMetrics())
+}
+
+// formatMetrics writes metrics in the Prometheus text format.
+func formatMetrics(metrics *vm.HackerMetrics) []byte {
+	buf := new(bytes.Buffer)
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("fuzz_blocks_total", "counter", "Blocks seen by the campaign.", metrics.Session.Blocks)
+	metric("fuzz_transactions_total", "counter", "Transactions of the blocks seen.", metrics.Session.Transactions)
+	metric("fuzz_watched_total", "counter", "Transactions watched.", metrics.Session.Watched)
+	metric("fuzz_last_block", "gauge", "Number of the last block ended.", metrics.Session.LastBlock)
+	metric("fuzz_coverage_edges", "gauge", "Branch coverage edges of the campaign.", metrics.Edges)
+	metric("fuzz_targets", "gauge", "Targets executed.", metrics.Targets)
+	metric("fuzz_corpus_entries", "gauge", "Inputs of the corpus.", metrics.Corpus)
+
+	fmt.Fprintf(buf, "# HELP fuzz_findings_total Findings raised, by type.\n# TYPE fuzz_findings_total counter\n")
+	kinds := make([]string, 0, len(metrics.Findings))
+	for kind := range metrics.Findings {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(buf, "fuzz_findings_total{type=%s} %d\n", strconv.Quote(kind), metrics.Findings[kind])
+	}
+
+	metric("fuzz_reports_sent_total", "counter", "Reports sent to the sink.", metrics.Reports.Sent)
+	metric("fuzz_reports_dropped_total", "counter", "Reports the sink failed to take.", metrics.Reports.Failed)
+	latency := metrics.Latency
+	fmt.Fprintf(buf, "# HELP fuzz_report_latency_seconds Time the sink took per report.\n# TYPE fuzz_report_latency_seconds histogram\n")
+	for i, bound := range latency.Buckets {
+		fmt.Fprintf(buf, "fuzz_report_latency_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), latency.Counts[i])
+	}
+	fmt.Fprintf(buf, "fuzz_report_latency_seconds_bucket{le=\"+Inf\"} %d\n", latency.Count)
+	fmt.Fprintf(buf, "fuzz_report_latency_seconds_sum %s\n", strconv.FormatFloat(latency.Sum, 'g', -1, 64))
+	fmt.Fprintf(buf, "fuzz_report_latency_seconds_count %d\n", latency.Count)
+	return buf.Bytes()
+}
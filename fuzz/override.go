@@ -0,0 +1,80 @@
+/**
+* @override.go
+* JSON form of the state overrides accepted by fuzz_call, same shape as the
+* eth_call overrides: balance, code, nonce and storage (state or stateDiff) per account.
+* An unknown field of an account is refused rather than ignored, a misspelt override
+* would otherwise run the call on the state it was meant to change.
+ */
+package fuzz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+var errStateAndDiff = errors.New("both state and stateDiff overridden")
+
+// OverrideAccount is the override of a single account.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   *hexutil.Big                 `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// UnmarshalJSON decodes the override of an account, refusing the unknown fields.
+func (account *OverrideAccount) UnmarshalJSON(input []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		switch name {
+		case "nonce", "code", "balance", "state", "stateDiff":
+		default:
+			return fmt.Errorf("unknown override field %q", name)
+		}
+	}
+	type override OverrideAccount
+	return json.Unmarshal(input, (*override)(account))
+}
+
+// StateOverride is the stateOverrides parameter of fuzz_call.
+type StateOverride map[common.Address]OverrideAccount
+
+func (diff *StateOverride) toHacker() (vm.HackerStateOverride, error) {
+	override := make(vm.HackerStateOverride)
+	if diff == nil {
+		return override, nil
+	}
+	for addr, account := range *diff {
+		if account.State != nil && account.StateDiff != nil {
+			return nil, fmt.Errorf("account %s: %v", addr.Hex(), errStateAndDiff)
+		}
+		hacker := new(vm.HackerAccountOverride)
+		if account.Nonce != nil {
+			nonce := uint64(*account.Nonce)
+			hacker.Nonce = &nonce
+		}
+		if account.Code != nil {
+			hacker.Code = *account.Code
+		}
+		if account.Balance != nil {
+			hacker.Balance = account.Balance.ToInt()
+		}
+		if account.State != nil {
+			hacker.State = *account.State
+		}
+		if account.StateDiff != nil {
+			hacker.StateDiff = *account.StateDiff
+		}
+		override[addr] = hacker
+	}
+	return override, nil
+}
@@ -0,0 +1,55 @@
+package fuzz
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStateOverrideJSON(t *testing.T) {
+	var (
+		addr  = common.HexToAddress("0xc0de")
+		input = `{"0x000000000000000000000000000000000000c0de": {
+			"nonce": "0x3",
+			"code": "0x602a60005500",
+			"balance": "0x5",
+			"stateDiff": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000007"}
+		}}`
+		overrides StateOverride
+	)
+	if err := json.Unmarshal([]byte(input), &overrides); err != nil {
+		t.Fatal(err)
+	}
+	override, err := overrides.toHacker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := override[addr]
+	switch {
+	case account == nil:
+		t.Fatalf("no override of %s", addr.Hex())
+	case account.Nonce == nil || *account.Nonce != 3:
+		t.Errorf("nonce %v, want 3", account.Nonce)
+	case common.Bytes2Hex(account.Code) != "602a60005500":
+		t.Errorf("code %x", account.Code)
+	case account.Balance == nil || account.Balance.Cmp(big.NewInt(5)) != 0:
+		t.Errorf("balance %v, want 5", account.Balance)
+	case account.State != nil || account.StateDiff[common.BigToHash(big.NewInt(1))] != common.BigToHash(big.NewInt(7)):
+		t.Errorf("storage %v %v, want slot 1 patched to 7", account.State, account.StateDiff)
+	}
+
+	// a misspelt field is refused rather than ignored
+	if err := json.Unmarshal([]byte(`{"0x000000000000000000000000000000000000c0de": {"balanse": "0x5"}}`), &overrides); err == nil {
+		t.Error("unknown field accepted")
+	}
+	both := StateOverride{addr: OverrideAccount{State: &map[common.Hash]common.Hash{}, StateDiff: &map[common.Hash]common.Hash{}}}
+	if _, err := both.toHacker(); err == nil {
+		t.Error("state and stateDiff both accepted")
+	}
+	var none *StateOverride
+	if override, err := none.toHacker(); err != nil || len(override) != 0 {
+		t.Errorf("no overrides: %v, %v", override, err)
+	}
+}
@@ -0,0 +1,76 @@
+/**
+* @http.go
+* HTTP transport of the instrumentation of core/vm (vm.HackerTransport): the WatchDog
+* reports, alerts, progress and summaries, the oracle features and the coordinator
+* synchronisations. The node installs it with vm.SetTransport(report.NewHTTP()).
+* The package depends on the standard library only, core/vm tests use it too.
+ */
+package report
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// MaxIdleConnections bounds the idle connections kept per sink.
+	MaxIdleConnections = 50
+	// RequestTimeout is the timeout of the GET and OPTIONS requests.
+	RequestTimeout = 5 * time.Second
+)
+
+// HTTP sends the requests of the instrumentation over HTTP.
+type HTTP struct {
+	client *http.Client
+}
+
+// NewHTTP returns an HTTP transport.
+func NewHTTP() *HTTP {
+	return &HTTP{client: &http.Client{
+		Transport: &http.Transport{MaxIdleConnsPerHost: MaxIdleConnections},
+		Timeout:   RequestTimeout,
+	}}
+}
+
+// Get sends a GET request to url, its response is discarded.
+func (t *HTTP) Get(url string) error {
+	resp, err := t.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// AcceptPost returns the Accept-Post header of the OPTIONS response of url.
+func (t *HTTP) AcceptPost(url string) (string, error) {
+	req, err := http.NewRequest("OPTIONS", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Accept-Post"), nil
+}
+
+// Post posts body to url and returns the status code and the body of the response,
+// zero timeout waits for it as long as it takes.
+func (t *HTTP) Post(url, contentType string, body io.Reader, timeout time.Duration) (int, []byte, error) {
+	client := &http.Client{Transport: t.client.Transport, Timeout: timeout}
+	resp, err := client.Post(url, contentType, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
@@ -0,0 +1,27 @@
+/**
+* @validation.go
+* JSON form of the result of fuzz_callValidated: the results of the validation and of
+* the execution of an account abstraction operation, with the violations of the rules
+* of the validation phase.
+ */
+package fuzz
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// ValidatedResult is the result of fuzz_callValidated.
+type ValidatedResult struct {
+	Validation *CallResult `json:"validation"`
+	// Execution is null when the validation failed
+	Execution  *CallResult                    `json:"execution"`
+	Violations []vm.HackerValidationViolation `json:"violations"`
+}
+
+func newValidatedResult(outcome *vm.HackerValidatedOutcome) *ValidatedResult {
+	result := &ValidatedResult{Validation: newCallResult(outcome.Validation), Violations: outcome.Violations}
+	if outcome.Execution != nil {
+		result.Execution = newCallResult(outcome.Execution)
+	}
+	return result
+}
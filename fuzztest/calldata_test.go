@@ -0,0 +1,48 @@
+package fuzztest
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestShortCalldata(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, calldataload(36)) stop: reads the amount of transfer(address,uint256)
+	read := "60243560005500"
+	tests := []struct {
+		name  string
+		check string
+		short bool
+	}{
+		{"unchecked", "", true},
+		// pop(lt(calldatasize, 4)): the check of the dispatcher
+		{"selector", "6004361050", true},
+		// pop(lt(calldatasize, 36)): the address only
+		{"address", "6024361050", true},
+		// pop(lt(calldatasize, 68))
+		{"arguments", "6044361050", false},
+		// pop(slt(sub(calldatasize, 4), 64)): the check of the ABI decoder
+		{"decoder", "6040600436031250", false},
+	}
+	for _, test := range tests {
+		token := deploy(t, chain, common.FromHex("0x"+test.check+read))
+		transfer := vm.GetABIRegistry().Register(token, "transfer(address,uint256)")
+		// the amount is missing
+		data := append(transfer.Selector[:], common.LeftPadBytes(deployer.Bytes(), 32)...)
+		receipt := chain.Execute(alice, token, nil, data)
+		if receipt.Err != nil || receipt.Report == nil {
+			t.Fatalf("%s: error %v, report %v", test.name, receipt.Err, receipt.Report)
+		}
+		finding := receipt.Report.Finding("short_calldata")
+		if (finding != nil) != test.short {
+			t.Errorf("%s: finding %v, want one %v", test.name, finding, test.short)
+			continue
+		}
+		if finding != nil && (finding.Detail["calldataSize"] != "36" || finding.Detail["readEnd"] != "68" || finding.Detail["expectedSize"] != "68") {
+			t.Errorf("%s: finding detail %v", test.name, finding.Detail)
+		}
+	}
+}
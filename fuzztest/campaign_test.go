@@ -0,0 +1,623 @@
+package fuzztest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestCallGraph(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// mstore(0, shl(224, 0xa9059cbb)) pop(call(gas, counter, 0, 0, 4, 0, 0)) stop
+	caller := deploy(t, chain, common.FromHex("0x63a9059cbb60e01b6000526000600060046000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	chain.Execute(alice, caller, nil, nil)
+	chain.Execute(alice, caller, nil, nil)
+
+	var edges []vm.HackerCallEdge
+	for _, edge := range vm.GetGlobalCampaign().CallGraph() {
+		if edge.From == caller || edge.From == alice {
+			edges = append(edges, edge)
+		}
+	}
+	want := vm.HackerCallEdge{From: caller, To: counter, Selector: "0xa9059cbb", Kind: "CALL", Count: 2}
+	if len(edges) != 1 || edges[0] != want {
+		t.Errorf("edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestSelectorStats(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	var receipt *Receipt
+	for i := 0; i < 3; i++ {
+		receipt = chain.Execute(alice, counter, nil, common.FromHex("0x11111111"))
+	}
+	if receipt.Report == nil || string(receipt.Report.Raw["selector"]) != `{"selector":"0x11111111","count":3,"rarity":0.3333333333333333}` {
+		t.Fatalf("report selector %s", receipt.Report.Raw["selector"])
+	}
+	chain.Execute(alice, counter, nil, common.FromHex("0x22222222"))
+	want := []vm.HackerSelectorStat{{Selector: "0x22222222", Count: 1, Rarity: 1}, {Selector: "0x11111111", Count: 3, Rarity: 1.0 / 3}}
+	if stats := vm.GetGlobalCampaign().SelectorStats(counter); len(stats) != 2 || stats[0] != want[0] || stats[1] != want[1] {
+		t.Errorf("stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	chain.Execute(alice, counter, nil, common.FromHex("0x11111111"))
+	chain.Execute(alice, counter, nil, common.FromHex("0x22222222"))
+	campaign := vm.GetGlobalCampaign()
+	stats, dictionary := campaign.SelectorStats(counter), campaign.Dictionary(counter)
+	campaign.Audit("fuzz_setSaturation", []interface{}{"0x10"}, nil)
+	campaign.Audit("fuzz_setInstrumentation", []interface{}{counter, "none"}, errors.New("unknown instrumentation none"))
+
+	dir, err := ioutil.TempDir("", "campaign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "campaign.json")
+	summary, err := vm.Shutdown(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Session.Watched != 2 || summary.Targets != 1 || summary.Selectors != 2 || !summary.AlertsFlushed || summary.Saved != path {
+		t.Errorf("summary %+v", summary)
+	}
+	if audit := summary.Audit; len(audit) != 2 || audit[0].Seq != 0 || audit[0].Method != "fuzz_setSaturation" || string(audit[0].Params) != `["0x10"]` || audit[0].Error != "" {
+		t.Errorf("audit log %+v, want the operations in order", audit)
+	} else if audit[1].Seq != 1 || audit[1].Error != "unknown instrumentation none" || audit[1].Time == 0 {
+		t.Errorf("audit entry %+v, want the failed operation", audit[1])
+	}
+	if sent := chain.Summary(); sent == nil || !reflect.DeepEqual(sent, summary) {
+		t.Errorf("summary sent %+v, want %+v", sent, summary)
+	}
+
+	// the next process resumes the campaign
+	campaign.Reset()
+	if err := campaign.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := campaign.Session(); got != summary.Session {
+		t.Errorf("session %+v, want %+v", got, summary.Session)
+	}
+	restored := campaign.SelectorStats(counter)
+	if len(restored) != len(stats) || restored[0] != stats[0] || restored[1] != stats[1] {
+		t.Errorf("selectors %+v, want %+v", restored, stats)
+	}
+	if got := campaign.Dictionary(counter); len(got) != len(dictionary) {
+		t.Errorf("dictionary %+v, want %+v", got, dictionary)
+	}
+	if err := campaign.Load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Errorf("missing campaign: %v", err)
+	}
+}
+
+// memoryDatabase is a database of the node kept in memory.
+type memoryDatabase map[string][]byte
+
+func (db memoryDatabase) Put(key []byte, value []byte) error {
+	db[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (db memoryDatabase) Get(key []byte) ([]byte, error) {
+	if value, ok := db[string(key)]; ok {
+		return value, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (db memoryDatabase) Has(key []byte) (bool, error) {
+	_, ok := db[string(key)]
+	return ok, nil
+}
+
+func TestCoverageResume(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// jumpi(7, calldataload(0)) stop jumpdest stop
+	branch := deploy(t, chain, common.FromHex("0x600035600757005b00"))
+	one := common.LeftPadBytes([]byte{1}, 32)
+	coverage := func(input []byte) vm.HackerCoverageStat {
+		receipt := chain.Execute(alice, branch, nil, input)
+		if receipt.Report == nil || receipt.Report.Coverage == nil {
+			t.Fatalf("report %+v, want the coverage section", receipt.Report)
+		}
+		return *receipt.Report.Coverage
+	}
+	for i, test := range []struct {
+		input []byte
+		want  vm.HackerCoverageStat
+	}{{nil, vm.HackerCoverageStat{Edges: 1, New: 1}}, {nil, vm.HackerCoverageStat{Edges: 1, New: 0}}, {one, vm.HackerCoverageStat{Edges: 1, New: 1}}} {
+		if got := coverage(test.input); got != test.want {
+			t.Errorf("execution %d: coverage %+v, want %+v", i, got, test.want)
+		}
+	}
+
+	db := make(memoryDatabase)
+	campaign := vm.GetGlobalCampaign()
+	if err := campaign.SaveTo(db); err != nil {
+		t.Fatal(err)
+	}
+	// a restarted node resumes the campaign, the edges are not new again
+	campaign.Reset()
+	if err := campaign.LoadFrom(db); err != nil {
+		t.Fatal(err)
+	}
+	if maps := campaign.Coverage(); len(maps) != 1 || maps[0].Edges != 2 {
+		t.Errorf("coverage %+v, want the 2 edges of the branch", maps)
+	}
+	if got := coverage(one); got.New != 0 {
+		t.Errorf("coverage %+v after the restart, want no new edge", got)
+	}
+	// without it they are
+	campaign.Reset()
+	if err := campaign.LoadFrom(make(memoryDatabase)); err != nil {
+		t.Fatal(err)
+	}
+	if got := coverage(nil); got.New != 1 {
+		t.Errorf("coverage %+v of a new campaign, want a new edge", got)
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetSaturation(2)
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// call(gas, counter, 0, 0, 0, 0, 0) after a branch
+	caller := deploy(t, chain, common.FromHex("0x600035600757005b6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	one := common.LeftPadBytes([]byte{1}, 32)
+	trace := func() []string {
+		receipt := chain.Execute(alice, caller, nil, one)
+		if receipt.Report == nil {
+			t.Fatal("no report")
+		}
+		return receipt.Report.Trace
+	}
+	full := trace()
+	// the first execution found new edges, the next two did not
+	trace()
+	if alerts := chain.Alerts(); len(alerts) != 0 {
+		t.Fatalf("alerts %+v before the target is saturated", alerts)
+	}
+	trace()
+	alerts := chain.Alerts()
+	if len(alerts) != 1 || alerts[0].Type != "saturated" || alerts[0].Address != caller || alerts[0].Old != "full" || alerts[0].New != "calls" {
+		t.Fatalf("alerts %+v, want the caller saturated", alerts)
+	}
+	if level := campaign.Instrumentation(caller); level != vm.HackerInstrumentCalls {
+		t.Errorf("instrumentation %v, want calls", level)
+	}
+	// the caller only records its call, the counter is still instrumented
+	reduced := trace()
+	if len(reduced) >= len(full) || len(reduced) == 0 {
+		t.Errorf("trace %v calls-only, want fewer operations than %v", reduced, full)
+	}
+	campaign.SetInstrumentation(caller, vm.HackerInstrumentFull)
+	if restored := trace(); len(restored) != len(full) {
+		t.Errorf("trace %v restored, want %v", restored, full)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// jumpi(7, calldataload(0)) stop jumpdest stop
+	branch := deploy(t, chain, common.FromHex("0x600035600757005b00"))
+	one := common.LeftPadBytes([]byte{1}, 32)
+	other := common.HexToHash("0x01")
+	var requests []*vm.HackerCoordinatorSync
+	var update *vm.HackerCoordinatorUpdate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := new(vm.HackerCoordinatorSync)
+		if r.URL.Path != "/sync" || json.NewDecoder(r.Body).Decode(request) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, request)
+		json.NewEncoder(w).Encode(update)
+	}))
+	defer server.Close()
+	// synchronised by the test only
+	coord := vm.StartCoordinator(server.URL, "a", 1000)
+	defer vm.StartCoordinator("", "", 0)
+
+	chain.Execute(alice, branch, nil, nil)
+	chain.Execute(alice, branch, nil, nil)
+	campaign := vm.GetGlobalCampaign()
+	if corpus := campaign.Corpus(branch); len(corpus) != 1 || len(corpus[0].Input) != 0 || corpus[0].Edges != 1 {
+		t.Fatalf("corpus %+v, want the input that covered the edge", corpus)
+	}
+	code := campaign.Coverage()[0].CodeHash
+	// node b covered the jump, the coordinator merged the bitmaps
+	update = &vm.HackerCoordinatorUpdate{
+		Coverage: []vm.HackerCoverageMap{{CodeHash: code, Bitmap: []byte{0x03}}, {CodeHash: other, Bitmap: []byte{0x01}}},
+		Corpus:   []vm.HackerCorpusEntry{{Target: branch, Edges: 1, Node: "a"}, {Target: branch, Input: one, Edges: 1, Node: "b"}},
+	}
+	result, err := coord.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (vm.HackerSyncResult{SentMaps: 1, SentCorpus: 1, Edges: 2, Corpus: 1}); *result != want {
+		t.Errorf("sync %+v, want %+v", *result, want)
+	}
+	if request := requests[0]; request.Node != "a" || len(request.Digests) != 1 || len(request.Corpus) != 1 || request.Corpus[0].Node != "a" {
+		t.Errorf("request %+v, want the bitmap and the input of node a", request)
+	}
+	if corpus := campaign.Corpus(branch); len(corpus) != 2 || corpus[1].Node != "b" {
+		t.Errorf("corpus %+v, want the input of node b", corpus)
+	}
+	// the jump is not new any more
+	if receipt := chain.Execute(alice, branch, nil, one); receipt.Report.Coverage.New != 0 {
+		t.Errorf("coverage %+v, want the edge covered by node b", receipt.Report.Coverage)
+	}
+
+	// nothing changed since
+	update = &vm.HackerCoordinatorUpdate{}
+	if result, err = coord.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (vm.HackerSyncResult{}); *result != want {
+		t.Errorf("sync %+v, want nothing exchanged", *result)
+	}
+	if request := requests[1]; len(request.Digests) != 2 || len(request.Coverage) != 0 || len(request.Corpus) != 0 {
+		t.Errorf("request %+v, want the digests only", request)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	full := chain.Execute(alice, caller, nil, nil).Report
+	if full.Blocked != nil {
+		t.Fatalf("blocked %+v without a blocklist", full.Blocked)
+	}
+
+	vm.GetGlobalCampaign().Block(counter, "router")
+	report := chain.Execute(alice, caller, nil, nil).Report
+	// the 7 operations of the counter are left out of the trace
+	if len(report.Trace) != len(full.Trace)-7 || len(report.StorageWrites) != 0 {
+		t.Errorf("trace %v, want the %d operations of the caller", report.Trace, len(full.Trace)-7)
+	}
+	want := []vm.HackerBlockedContract{{Address: counter, Label: "router", Frames: 1, Steps: 7}}
+	if len(report.Blocked) != 1 || report.Blocked[0] != want[0] {
+		t.Errorf("blocked %+v, want %+v", report.Blocked, want)
+	}
+	// the frame of the counter is still reported
+	if len(report.Frames) != len(full.Frames) {
+		t.Errorf("frames %+v, want %+v", report.Frames, full.Frames)
+	}
+
+	vm.GetGlobalCampaign().Unblock(counter)
+	if report := chain.Execute(alice, caller, nil, nil).Report; len(report.Trace) != len(full.Trace) || report.Blocked != nil {
+		t.Errorf("trace %v after Unblock, want %v", report.Trace, full.Trace)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	policy, err := vm.CompileHackerPolicy("to == " + counter.Hex() + " -> skip; to == " + caller.Hex() + " && selector == 0x11111111 -> calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.GetGlobalCampaign().SetPolicy(policy)
+
+	if receipt := chain.Execute(alice, counter, nil, nil); receipt.Report != nil {
+		t.Errorf("report %+v of a skipped transaction", receipt.Report)
+	}
+	// calls-only in the callee too
+	report := chain.Execute(alice, caller, nil, common.FromHex("0x11111111")).Report
+	if report == nil || len(report.Trace) != 1 || len(report.Frames) != 2 {
+		t.Fatalf("report %+v, want the call only", report)
+	}
+	if report := chain.Execute(alice, caller, nil, nil).Report; report == nil || len(report.Trace) <= 1 {
+		t.Errorf("report %+v, want the full trace", report)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	vm.GetGlobalWatchDog().SetProgressThreshold(5)
+	defer vm.GetGlobalWatchDog().SetProgressThreshold(0)
+
+	// 8 operations of the caller up to the call, 7 of the counter, 2 after the call
+	receipt := chain.Execute(alice, caller, nil, nil)
+	progress := chain.Progress()
+	if len(progress) != 3 {
+		t.Fatalf("%d progress reports, want 3", len(progress))
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Steps < progress[j].Steps })
+	for i, want := range []struct {
+		steps  uint64
+		pc     uint64
+		frames int
+	}{{5, 8, 1}, {10, 1, 2}, {15, 8, 2}} {
+		got := progress[i]
+		if got.Hash != receipt.Hash || got.Steps != want.steps || got.Pc != want.pc || len(got.Frames) != want.frames {
+			t.Errorf("progress %d %+v, want %d steps at pc %d in %d frames", i, got, want.steps, want.pc, want.frames)
+		}
+		if i > 0 && got.GasUsed <= progress[i-1].GasUsed {
+			t.Errorf("progress %d used %d gas, want more than %d", i, got.GasUsed, progress[i-1].GasUsed)
+		}
+	}
+	if frames := progress[1].Frames; frames[0].Address != caller || frames[1].Address != counter || frames[1].Kind != "CALL" || frames[1].Depth != 2 {
+		t.Errorf("frames %+v, want the counter called by the caller", frames)
+	}
+	if used := progress[2].GasUsed; used >= receipt.GasUsed {
+		t.Errorf("progress used %d gas, want less than the %d of the receipt", used, receipt.GasUsed)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	if report := chain.Execute(alice, caller, nil, nil).Report; report.Labels != nil {
+		t.Errorf("labels %v without any label", report.Labels)
+	}
+
+	vm.GetGlobalCampaign().SetLabel(counter, "Counter")
+	vm.GetGlobalCampaign().ImportLabels(map[common.Address]string{alice: "Alice", deployer: "Deployer"})
+	report := chain.Execute(alice, caller, nil, nil).Report
+	// the deployer does not appear in the report
+	want := map[string]string{counter.Hex(): "Counter", alice.Hex(): "Alice"}
+	if len(report.Labels) != len(want) || report.Labels[counter.Hex()] != "Counter" || report.Labels[alice.Hex()] != "Alice" {
+		t.Errorf("labels %v, want %v", report.Labels, want)
+	}
+	vm.GetGlobalCampaign().SetLabel(counter, "")
+	if labels := vm.GetGlobalCampaign().Labels(); len(labels) != 2 || labels[counter] != "" {
+		t.Errorf("labels %v after removing the counter", labels)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// jumpi(7, calldataload(0)) stop jumpdest stop
+	branch := deploy(t, chain, common.FromHex("0x600035600757005b00"))
+	// call(gas, calldataload(0), 1, 0, 0, 0, 0) sstore(0, 1) stop
+	late := deploy(t, chain, common.FromHex("0x600060006000600060016000355af150600160005500"))
+	chain.Fund(late, ether)
+	vm.GetGlobalCampaign().SetLabel(late, "Late")
+	start := vm.GetGlobalCampaign().Status()
+
+	chain.Execute(alice, branch, nil, nil)
+	chain.Execute(alice, branch, nil, common.LeftPadBytes([]byte{1}, 32))
+	receipt := chain.Execute(alice, late, nil, common.LeftPadBytes(alice.Bytes(), 32))
+	status := vm.GetGlobalCampaign().Status()
+	growth := status.Growth[len(start.Growth):]
+	if len(growth) != 3 || growth[0].Edges != start.Edges+1 || growth[1].Edges != start.Edges+2 || growth[2].Edges != status.Edges {
+		t.Errorf("growth %v from %d edges, want an edge per branch", growth, start.Edges)
+	}
+	targets := make(map[common.Address]vm.HackerTargetStatus)
+	for _, target := range status.Targets {
+		targets[target.Address] = target
+	}
+	if len(targets) != 2 {
+		t.Fatalf("targets %+v, want the two contracts", status.Targets)
+	}
+	if target := targets[late]; target.Label != "Late" || target.Executions != 1 || target.Selectors != 1 || target.Instrumentation != "full" {
+		t.Errorf("target %+v, want an execution of a selector", target)
+	}
+	if target := targets[branch]; target.Executions != 2 || target.Corpus != 2 || target.Label != "" {
+		t.Errorf("target %+v, want 2 executions of the branch in the corpus", target)
+	}
+	if status.Reports.Sent != start.Reports.Sent+3 || status.Reports.Failed != 0 || status.Reports.LastSent == 0 {
+		t.Errorf("reports %+v, want 3 more sent", status.Reports)
+	}
+	findings := vm.GetGlobalCampaign().Findings(1)
+	if len(findings) != 1 || findings[0].Hash != receipt.Hash || findings[0].Target != late || findings[0].Time == 0 {
+		t.Fatalf("findings %+v, want the last one of the contract", findings)
+	}
+	if all := vm.GetGlobalCampaign().Findings(0); len(all) != status.Findings || status.Findings < 2 {
+		t.Errorf("%d findings out of %d, want them all", len(all), status.Findings)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// call(gas, calldataload(0), 1, 0, 0, 0, 0) sstore(0, 1) stop
+	late := deploy(t, chain, common.FromHex("0x600060006000600060016000355af150600160005500"))
+	chain.Fund(late, ether)
+	start := vm.GetGlobalCampaign().Metrics()
+
+	for i := 0; i < 2; i++ {
+		chain.Execute(alice, late, nil, common.LeftPadBytes(alice.Bytes(), 32))
+	}
+	metrics := vm.GetGlobalCampaign().Metrics()
+	if found := metrics.Findings["cei_violation"] - start.Findings["cei_violation"]; found != 2 {
+		t.Errorf("%d CEI findings, want 2", found)
+	}
+	if metrics.Session.Watched != start.Session.Watched+2 || metrics.Targets != start.Targets+1 {
+		t.Errorf("metrics %+v, want 2 more transactions watched of a target", metrics)
+	}
+	latency := metrics.Latency
+	if latency.Count != metrics.Reports.Sent+metrics.Reports.Failed || latency.Count != start.Latency.Count+2 || latency.Sum <= start.Latency.Sum {
+		t.Errorf("latency of %d reports in %vs, want one per report", latency.Count, latency.Sum)
+	}
+	if last := latency.Counts[len(latency.Counts)-1]; last != latency.Count || len(latency.Counts) != len(latency.Buckets) {
+		t.Errorf("histogram %v, want all the reports under %vs", latency.Counts, latency.Buckets[len(latency.Buckets)-1])
+	}
+	for i := 1; i < len(latency.Counts); i++ {
+		if latency.Counts[i] < latency.Counts[i-1] {
+			t.Errorf("histogram %v not cumulative", latency.Counts)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// jumpi(ok, eq(42, calldataload(0))) stop ok: sstore(0, 1) stop
+	code := common.FromHex("0x600035602a14600a57005b600160005500")
+	guard := deploy(t, chain, code)
+	if _, err := vm.GetGlobalCampaign().Disassemble(crypto.Keccak256Hash(code)); err == nil {
+		t.Fatal("disassembly of a code never watched")
+	}
+	chain.Execute(alice, guard, nil, common.BigToHash(big.NewInt(42)).Bytes())
+	chain.Execute(alice, guard, nil, common.Hash{}.Bytes())
+	disassembly, err := vm.GetGlobalCampaign().Disassemble(crypto.Keccak256Hash(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disassembly.Instructions) != 12 {
+		t.Fatalf("%d instructions, want 12", len(disassembly.Instructions))
+	}
+	eq, jumpi := disassembly.Instructions[3], disassembly.Instructions[5]
+	if want := []common.Hash{common.BigToHash(big.NewInt(42)), {}}; eq.Op != "EQ" || !reflect.DeepEqual(eq.Operands, want) {
+		t.Errorf("instruction %+v, want the operands %v", eq, want)
+	}
+	if jumpi.Op != "JUMPI" || jumpi.Pc != 8 || jumpi.FallThrough != 1 || jumpi.Taken != 1 {
+		t.Errorf("instruction %+v, want a fall through and a jump", jumpi)
+	}
+	if push := disassembly.Instructions[2]; push.Op != "PUSH1" || len(push.Arg) != 1 || push.Arg[0] != 0x2a {
+		t.Errorf("instruction %+v, want push 42", push)
+	}
+}
+
+func TestDetectorSchedule(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	campaign := vm.GetGlobalCampaign()
+	if report := chain.Execute(alice, counter, nil, nil).Report; report.Raw["detectors"] != nil {
+		t.Fatalf("detectors %v reported without a budget", report.Detectors)
+	}
+	// every transaction is over budget
+	campaign.SetDetectorBudget(time.Nanosecond)
+	defer campaign.SetDetectorBudget(0)
+	for i := 0; i < 8; i++ {
+		if report := chain.Execute(alice, counter, nil, nil).Report; len(report.Detectors) != 6 {
+			t.Fatalf("detectors %v, want all of them before the schedule adapts", report.Detectors)
+		}
+	}
+	sampled := ""
+	for _, stat := range campaign.Detectors() {
+		if stat.State != vm.DetectorActive {
+			if sampled != "" || stat.State != vm.DetectorSampled {
+				t.Fatalf("detectors %+v, want one sampled", campaign.Detectors())
+			}
+			sampled = stat.Name
+		}
+	}
+	if sampled == "" {
+		t.Fatalf("detectors %+v, want one sampled", campaign.Detectors())
+	}
+	// the sampled detector runs with one transaction of four
+	report := chain.Execute(alice, counter, nil, nil).Report
+	if len(report.Detectors) != 6 {
+		t.Errorf("detectors %v, want the sampled one run", report.Detectors)
+	}
+	report = chain.Execute(alice, counter, nil, nil).Report
+	if len(report.Detectors) != 5 {
+		t.Fatalf("detectors %v, want the sampled one left out", report.Detectors)
+	}
+	for _, name := range report.Detectors {
+		if name == sampled {
+			t.Errorf("detectors %v, want %s left out", report.Detectors, sampled)
+		}
+	}
+	campaign.SetDetectorBudget(0)
+	for _, stat := range campaign.Detectors() {
+		if stat.State != vm.DetectorActive || stat.Runs == 0 {
+			t.Errorf("detector %+v, want it active again", stat)
+		}
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// stores a counter from 50 down to 1 into slot 0
+	loop := deploy(t, chain, common.FromHex("0x60325b8060005560019003806002570000"))
+	campaign := vm.GetGlobalCampaign()
+	if err := campaign.SetTraceSampling(map[string]uint64{"loops": 2}); err == nil {
+		t.Fatal("unknown class accepted")
+	}
+	count := func(trace []string, op string) (n int) {
+		for _, entry := range trace {
+			if strings.TrimLeft(entry, "0123456789") == op {
+				n++
+			}
+		}
+		return n
+	}
+	whole := chain.Execute(alice, loop, nil, nil).Report
+	if whole.Raw["traceSkipped"] != nil || count(whole.Trace, "SUB") != 50 {
+		t.Fatalf("trace sampled without a sampling, skipped %v", whole.TraceSkipped)
+	}
+	if err := campaign.SetTraceSampling(map[string]uint64{"arithmetic": 10, "stack": 4}); err != nil {
+		t.Fatal(err)
+	}
+	defer campaign.SetTraceSampling(nil)
+	sampled := chain.Execute(alice, loop, nil, nil).Report
+	if n := count(sampled.Trace, "SUB"); n != 5 {
+		t.Errorf("%d SUB traced, want one of ten", n)
+	}
+	if n := count(sampled.Trace, "SSTORE"); n != 50 {
+		t.Errorf("%d SSTORE traced, want all of them", n)
+	}
+	if n := count(sampled.Trace, "JUMPI"); n != 50 {
+		t.Errorf("%d JUMPI traced, want all of them", n)
+	}
+	if sampled.TraceSkipped["arithmetic"] != 45 || sampled.TraceSkipped["storage"] != 0 {
+		t.Errorf("skipped %v, want 45 arithmetic operations", sampled.TraceSkipped)
+	}
+	skipped := 0
+	for _, n := range sampled.TraceSkipped {
+		skipped += int(n)
+	}
+	if len(sampled.Trace)+skipped != len(whole.Trace) || len(sampled.TraceFrames) != len(sampled.Trace) {
+		t.Errorf("%d traced and %d skipped, want %d operations", len(sampled.Trace), skipped, len(whole.Trace))
+	}
+	if sampled.Env.Instrumentation == whole.Env.Instrumentation {
+		t.Error("sampling left out of the instrumentation of the env")
+	}
+}
+
+func TestDictionaryOnWatch(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	caller := deploy(t, chain, common.FromHex("0x63a9059cbb60e01b6000526000600060046000600073"+common.Bytes2Hex(counter[:])+"5af15000"))
+	if entries := vm.GetGlobalCampaign().Dictionary(caller); entries != nil {
+		t.Fatalf("dictionary %v before the target is watched", entries)
+	}
+	chain.Execute(alice, caller, nil, nil)
+	entries := vm.GetGlobalCampaign().Dictionary(caller)
+	if len(entries) != 2 || entries[0].Kind != "selector" || entries[1].Kind != "address" || common.BytesToAddress(entries[1].Value) != counter {
+		t.Errorf("dictionary %v, want the selector and the address of the counter", entries)
+	}
+}
@@ -0,0 +1,407 @@
+/**
+* @chain.go
+* Instrumented chain for end-to-end tests of the WatchDog and its oracles.
+* 1 a Chain is a MemoryState, a block context and a report sink: an HTTP server the
+*   WatchDog reports are sent to instead of the fuzzer.
+* 2 Deploy runs the creation code of a fixture without watching it, Execute sends a
+*   transaction the way the node does (OnBlockStart, Start, Watch, message call, End,
+*   OnBlockEnd: every transaction is mined in a block of its own) and returns
+*   the report the WatchDog sent for it. ExecuteTyped sends a transaction envelope,
+*   typed transactions (EIP-2718) included.
+* 3 the WatchDog and the report URL are process wide, tests using a Chain must not run
+*   in parallel.
+* 4 the watchpoint alerts and the progress reports are sent to the sink too, Alerts and
+*   Progress return them once delivered.
+* 5 NewChainFromGenesis starts the chain on a genesis (genesis.go) instead of the test
+*   chain config and an empty state.
+ */
+package fuzztest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/fuzz/report"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultGas is the gas of the transactions sent without a gas limit.
+const DefaultGas = 3000000
+
+// Report is a WatchDog report as received by the sink.
+type Report struct {
+	Hash  string   `json:"hash"`
+	Trace []string `json:"trace"`
+	// TraceFrames is the frame of every entry of Trace
+	TraceFrames     []int                        `json:"traceFrames"`
+	StorageWrites   []vm.HackerStorageWrite      `json:"storageWrites"`
+	Logs            []vm.HackerLogRecord         `json:"logs"`
+	HasThrow        bool                         `json:"hasThrow"`
+	BalanceOld      string                       `json:"balance_old"`
+	BalanceNew      string                       `json:"balance_new"`
+	Findings        []vm.HackerFinding           `json:"findings"`
+	Frames          []vm.HackerReportFrame       `json:"frames"`
+	Forwarding      []vm.HackerForwardChain      `json:"forwarding"`
+	Gas             *vm.HackerGasBreakdown       `json:"gas"`
+	Refunds         *vm.HackerRefundReport       `json:"refunds"`
+	Accounts        *vm.HackerAccountDiff        `json:"accounts"`
+	Coverage        *vm.HackerCoverageStat       `json:"coverage"`
+	Blocked         []vm.HackerBlockedContract   `json:"blocked"`
+	Breakpoints     []vm.HackerMachineState      `json:"breakpoints"`
+	Labels          map[string]string            `json:"labels"`
+	Proxy           *vm.HackerProxy              `json:"proxy"`
+	Env             *vm.HackerReportEnv          `json:"env"`
+	Bundle          []vm.HackerBundleStep        `json:"bundle"`
+	RevertState     *vm.HackerRevertState        `json:"revertState"`
+	Console         []vm.HackerConsoleLog        `json:"console"`
+	FrontRunning    *vm.HackerFrontRunReport     `json:"frontRunning"`
+	Differential    *vm.HackerDifferentialReport `json:"differential"`
+	Griefing        *vm.HackerGriefingReport     `json:"griefing"`
+	RevertBomb      *vm.HackerGriefingReport     `json:"revertBomb"`
+	ReturnPoisoning *vm.HackerGriefingReport     `json:"returnPoisoning"`
+	TimeSkew        *vm.HackerTimeSkewReport     `json:"timeSkew"`
+	FeeSkew         *vm.HackerFeeSkewReport      `json:"feeSkew"`
+	Elided          []string                     `json:"elided"`
+	Detectors       []string                     `json:"detectors"`
+	TraceSkipped    map[string]uint64            `json:"traceSkipped"`
+	// Raw is the report as sent, for the sections not decoded above
+	Raw map[string]json.RawMessage `json:"-"`
+}
+
+// Finding returns the first finding of type kind, nil if there is none.
+func (report *Report) Finding(kind string) *vm.HackerFinding {
+	for i := range report.Findings {
+		if report.Findings[i].Type == kind {
+			return &report.Findings[i]
+		}
+	}
+	return nil
+}
+
+// HasFinding tells whether the report carries a finding of type kind.
+func (report *Report) HasFinding(kind string) bool {
+	return report.Finding(kind) != nil
+}
+
+// Receipt is the outcome of a transaction sent with Execute.
+type Receipt struct {
+	Hash    common.Hash
+	Ret     []byte
+	GasUsed uint64
+	// GasPrice is the price per gas paid, the effective price of a dynamic fee
+	// transaction
+	GasPrice *big.Int
+	Err      error
+	Logs     []*types.Log
+	// Report is nil when the WatchDog sent nothing (no code ran)
+	Report *Report
+}
+
+// Chain is an in-memory instrumented chain.
+type Chain struct {
+	State  *MemoryState
+	Config *params.ChainConfig
+	// Coinbase, Number, Time, Difficulty, GasLimit and BaseFee are used for the next
+	// transactions, a nil BaseFee is a block before London
+	Coinbase   common.Address
+	Number     *big.Int
+	Time       *big.Int
+	Difficulty *big.Int
+	GasLimit   *big.Int
+	BaseFee    *big.Int
+
+	sink     *httptest.Server
+	lock     sync.Mutex
+	reports  map[string]*Report
+	alerts   []*vm.HackerAlert
+	progress []*vm.HackerProgress
+	summary  *vm.HackerShutdownSummary
+	labels   map[common.Address]string
+}
+
+// NewChain returns an empty chain whose WatchDog reports are sent to its own sink.
+// Close it to stop the sink. The transactions watched before are forgotten, the same
+// transactions sent to a new chain are watched again, and the campaign starts over
+// opted in to Shanghai.
+func NewChain() *Chain {
+	chain := &Chain{
+		State:      NewMemoryState(),
+		Config:     params.TestChainConfig,
+		Number:     big.NewInt(1),
+		Time:       big.NewInt(1500000000),
+		Difficulty: big.NewInt(131072),
+		GasLimit:   big.NewInt(DefaultGas * 10),
+		reports:    make(map[string]*Report),
+	}
+	vm.ForgetTransactions()
+	vm.GetGlobalCampaign().Reset()
+	vm.GetGlobalCampaign().SetFork(vm.ForkShanghai)
+	chain.sink = httptest.NewServer(http.HandlerFunc(chain.receive))
+	vm.SetTransport(report.NewHTTP())
+	vm.SetReportURL(chain.sink.URL)
+	// the sink only speaks JSON
+	vm.SetReportFormat(chain.sink.URL, vm.ReportJSON)
+	vm.SetAlertURL(chain.sink.URL + "/alert")
+	vm.SetReportFormat(chain.sink.URL+"/alert", vm.ReportJSON)
+	vm.SetSummaryURL(chain.sink.URL + "/summary")
+	vm.SetReportFormat(chain.sink.URL+"/summary", vm.ReportJSON)
+	vm.SetProgressURL(chain.sink.URL + "/progress")
+	vm.SetReportFormat(chain.sink.URL+"/progress", vm.ReportJSON)
+	return chain
+}
+
+// Close stops the report sink.
+func (chain *Chain) Close() {
+	chain.sink.Close()
+}
+
+func (chain *Chain) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		return
+	}
+	if r.URL.Path == "/alert" {
+		alert := new(vm.HackerAlert)
+		if err := json.NewDecoder(r.Body).Decode(alert); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chain.lock.Lock()
+		chain.alerts = append(chain.alerts, alert)
+		chain.lock.Unlock()
+		return
+	}
+	if r.URL.Path == "/progress" {
+		progress := new(vm.HackerProgress)
+		if err := json.NewDecoder(r.Body).Decode(progress); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chain.lock.Lock()
+		chain.progress = append(chain.progress, progress)
+		chain.lock.Unlock()
+		return
+	}
+	if r.URL.Path == "/summary" {
+		summary := new(vm.HackerShutdownSummary)
+		if err := json.NewDecoder(r.Body).Decode(summary); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chain.lock.Lock()
+		chain.summary = summary
+		chain.lock.Unlock()
+		return
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	encoded, _ := json.Marshal(raw)
+	report := &Report{Raw: raw}
+	if err := json.Unmarshal(encoded, report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chain.lock.Lock()
+	chain.reports[report.Hash] = report
+	chain.lock.Unlock()
+}
+
+// Alerts returns the watchpoint alerts received so far, once the pending ones are
+// delivered.
+func (chain *Chain) Alerts() []*vm.HackerAlert {
+	vm.WaitAlerts()
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	return append([]*vm.HackerAlert{}, chain.alerts...)
+}
+
+// Progress returns the progress reports received so far, once the pending ones are
+// delivered.
+func (chain *Chain) Progress() []*vm.HackerProgress {
+	vm.WaitAlerts()
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	return append([]*vm.HackerProgress{}, chain.progress...)
+}
+
+// Summary returns the session summary received, nil before vm.Shutdown.
+func (chain *Chain) Summary() *vm.HackerShutdownSummary {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	return chain.summary
+}
+
+// Report returns the report received for the transaction hash, nil if there is none.
+func (chain *Chain) Report(hash common.Hash) *Report {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	return chain.reports[hash.String()]
+}
+
+// Fund adds amount wei to addr.
+func (chain *Chain) Fund(addr common.Address, amount *big.Int) {
+	chain.State.AddBalance(addr, amount)
+	chain.finalise()
+}
+
+func (chain *Chain) context(origin common.Address, gasPrice *big.Int) vm.Context {
+	return vm.Context{
+		CanTransfer: func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
+			db.SubBalance(sender, amount)
+			db.AddBalance(recipient, amount)
+		},
+		GetHash: func(n uint64) common.Hash {
+			return crypto.Keccak256Hash(new(big.Int).SetUint64(n).Bytes())
+		},
+		Origin:      origin,
+		GasPrice:    gasPrice,
+		Coinbase:    chain.Coinbase,
+		GasLimit:    new(big.Int).Set(chain.GasLimit),
+		BlockNumber: new(big.Int).Set(chain.Number),
+		Time:        new(big.Int).Set(chain.Time),
+		Difficulty:  new(big.Int).Set(chain.Difficulty),
+		BaseFee:     chain.BaseFee,
+	}
+}
+
+// finalise ends the transaction on the state, deleting the touched empty accounts
+// after EIP-158.
+func (chain *Chain) finalise() {
+	chain.State.Finalise(chain.Config.IsEIP158(chain.Number))
+}
+
+// header returns the header of the block of the next transaction.
+func (chain *Chain) header() *types.Header {
+	return &types.Header{
+		Coinbase:   chain.Coinbase,
+		Difficulty: new(big.Int).Set(chain.Difficulty),
+		Number:     new(big.Int).Set(chain.Number),
+		GasLimit:   new(big.Int).Set(chain.GasLimit),
+		Time:       new(big.Int).Set(chain.Time),
+	}
+}
+
+// evm returns an EVM for a transaction of from paying gasPrice, the nonce of from is
+// bumped.
+func (chain *Chain) evm(from common.Address, gasPrice *big.Int) (*vm.EVM, uint64) {
+	nonce := chain.State.GetNonce(from)
+	chain.State.SetNonce(from, nonce+1)
+	return vm.NewEVM(chain.context(from, gasPrice), chain.State, chain.Config, vm.Config{}), nonce
+}
+
+// Deploy runs the creation code of a contract sent by from and returns its address.
+// The deployment is not watched.
+func (chain *Chain) Deploy(from common.Address, creation []byte) (common.Address, error) {
+	evm, _ := chain.evm(from, new(big.Int))
+	_, addr, _, err := evm.Create(vm.AccountRef(from), creation, DefaultGas, new(big.Int))
+	chain.State.Logs()
+	chain.finalise()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("deploying: %v", err)
+	}
+	return addr, nil
+}
+
+// DeployRuntime deploys a contract whose code is runtime.
+func (chain *Chain) DeployRuntime(from common.Address, runtime []byte) (common.Address, error) {
+	return chain.Deploy(from, Creation(runtime))
+}
+
+// Execute sends a watched transaction of from to the contract to with the default gas
+// and returns its receipt, with the WatchDog report when one was sent.
+func (chain *Chain) Execute(from, to common.Address, value *big.Int, data []byte) *Receipt {
+	return chain.execute(from, to, value, data, new(big.Int))
+}
+
+// ExecuteDynamicFee sends a watched dynamic fee transaction (EIP-1559) with the fee
+// and tip caps, it pays the effective gas price under the base fee of the chain. The
+// transaction is refused, with no report, when its fees are invalid.
+func (chain *Chain) ExecuteDynamicFee(from, to common.Address, value *big.Int, data []byte, feeCap, tipCap *big.Int) *Receipt {
+	price, err := vm.EffectiveGasPrice(chain.BaseFee, feeCap, tipCap)
+	if err != nil {
+		return &Receipt{Err: err}
+	}
+	return chain.execute(from, to, value, data, price)
+}
+
+func (chain *Chain) execute(from, to common.Address, value *big.Int, data []byte, gasPrice *big.Int) *Receipt {
+	if value == nil {
+		value = new(big.Int)
+	}
+	evm, nonce := chain.evm(from, gasPrice)
+	// the types of this tree predate typed transactions, the legacy transaction only
+	// gives the watched transaction its hash
+	tx := types.NewTransaction(nonce, to, value, big.NewInt(DefaultGas), gasPrice, data)
+
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	dog.Start()
+	dog.Watch(evm, tx)
+	return chain.run(evm, dog, tx.Hash(), from, to, value, data, DefaultGas, gasPrice)
+}
+
+// ExecuteTyped sends the watched transaction envelope (legacy or EIP-2718 typed) of
+// from, with its own gas, access list and fees. The nonce of the envelope is not
+// checked. The transaction is refused, with no report, when it cannot be decoded or
+// its fees are invalid.
+func (chain *Chain) ExecuteTyped(from common.Address, envelope []byte) *Receipt {
+	tx, err := vm.DecodeHackerTypedTx(envelope)
+	if err != nil {
+		return &Receipt{Err: err}
+	}
+	if tx.To == nil {
+		return &Receipt{Err: fmt.Errorf("contract creations are not watched")}
+	}
+	msg := tx.Message(from)
+	price, err := msg.EffectiveGasPrice(chain.BaseFee, new(big.Int))
+	if err != nil {
+		return &Receipt{Err: err}
+	}
+	value := msg.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	evm, _ := chain.evm(from, price)
+	evm.WarmAccessList(msg.AccessList)
+
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	dog.Start()
+	dog.WatchTyped(evm, tx)
+	return chain.run(evm, dog, tx.Hash(), from, *tx.To, value, msg.Data, msg.Gas, price)
+}
+
+// run executes the message call of the watched transaction hash and ends it.
+func (chain *Chain) run(evm *vm.EVM, dog *vm.WatchDog, hash common.Hash, from, to common.Address, value *big.Int, data []byte, gas uint64, gasPrice *big.Int) *Receipt {
+	ret, gasLeft, err := evm.Call(vm.AccountRef(from), to, data, gas, value)
+	receipt := &Receipt{Hash: hash, Ret: ret, GasUsed: gas - gasLeft, GasPrice: gasPrice, Err: err, Logs: chain.State.Logs()}
+	txReceipt := &types.Receipt{
+		TxHash:            hash,
+		GasUsed:           new(big.Int).SetUint64(receipt.GasUsed),
+		CumulativeGasUsed: new(big.Int).SetUint64(receipt.GasUsed),
+		Logs:              receipt.Logs,
+	}
+	dog.End(txReceipt)
+	dog.OnBlockEnd(chain.header(), types.Receipts{txReceipt})
+	chain.finalise()
+	chain.Number.Add(chain.Number, common.Big1)
+	chain.Time.Add(chain.Time, big.NewInt(15))
+
+	chain.lock.Lock()
+	receipt.Report = chain.reports[hash.String()]
+	chain.lock.Unlock()
+	return receipt
+}
@@ -0,0 +1,554 @@
+package fuzztest
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRevertAndStaticCall(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// mstore(0, 42) revert(0, 32)
+	reverter := deploy(t, chain, common.FromHex("0x602a60005260206000fd"))
+	receipt := chain.Execute(alice, reverter, nil, nil)
+	if receipt.Err != vm.ErrExecutionReverted {
+		t.Fatalf("error %v, want %v", receipt.Err, vm.ErrExecutionReverted)
+	}
+	if common.BytesToHash(receipt.Ret) != common.BigToHash(big.NewInt(42)) || receipt.GasUsed >= DefaultGas {
+		t.Errorf("revert returned %x using %d gas", receipt.Ret, receipt.GasUsed)
+	}
+
+	// sstore(0, 1) stop
+	writer := deploy(t, chain, common.FromHex("0x600160005500"))
+	// sstore(0, staticcall(gas, writer, 0, 0, 0, 0)) stop
+	code := append(common.FromHex("0x600060006000600073"), writer.Bytes()...)
+	caller := deploy(t, chain, append(code, common.FromHex("0x5afa60005500")...))
+	chain.State.SetState(caller, common.Hash{}, common.HexToHash("0x02"))
+	receipt = chain.Execute(alice, caller, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetState(caller, common.Hash{}); got != (common.Hash{}) {
+		t.Errorf("staticcall pushed %x, want 0", got)
+	}
+	if got := chain.State.GetState(writer, common.Hash{}); got != (common.Hash{}) {
+		t.Errorf("write in a static context kept: %x", got)
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	for _, frame := range receipt.Report.Frames {
+		if common.HexToAddress(frame.Address) == writer && (frame.Kind != "STATICCALL" || !frame.ReadOnly || !frame.Failed) {
+			t.Errorf("writer frame %+v, want a failed read only STATICCALL", frame)
+		}
+	}
+}
+
+func TestFrameRevertReason(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// codecopy(0, 12, 100) revert(0, 100) followed by Error("no")
+	reverter := deploy(t, chain, common.FromHex("0x6064600c60003960646000fd"+
+		"08c379a0"+
+		"0000000000000000000000000000000000000000000000000000000000000020"+
+		"0000000000000000000000000000000000000000000000000000000000000002"+
+		"6e6f000000000000000000000000000000000000000000000000000000000000"))
+	// pop(call(gas, reverter, 0, 0, 0, 0, 0)) stop
+	code := append(common.FromHex("0x6000600060006000600073"), reverter.Bytes()...)
+	caller := deploy(t, chain, append(code, common.FromHex("0x5af15000")...))
+	receipt := chain.Execute(alice, caller, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if receipt.Report == nil || len(receipt.Report.Frames) != 2 {
+		t.Fatalf("report %+v, want 2 frames", receipt.Report)
+	}
+	if frame := receipt.Report.Frames[0]; frame.Failed || frame.Error != "" || frame.ReturnDataSize != 0 {
+		t.Errorf("caller frame %+v, want a successful frame without return data", frame)
+	}
+	frame := receipt.Report.Frames[1]
+	if !frame.Failed || frame.Error != "revert" || frame.RevertReason != "no" || frame.ReturnDataSize != 100 || len(frame.ReturnData) != 200 {
+		t.Errorf("reverter frame %+v, want a revert with reason \"no\"", frame)
+	}
+}
+
+func TestFrameIdentifiers(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// mstore(0, 0xab) log1(0, 32, 1) stop
+	emitter := deploy(t, chain, common.FromHex("0x60ab600052600160206000a100"))
+	// sstore(0, 1) pop(call(gas, emitter, 0, 0, 0, 0, 0)) stop
+	code := append(common.FromHex("0x60016000556000600060006000600073"), emitter.Bytes()...)
+	caller := deploy(t, chain, append(code, common.FromHex("0x5af15000")...))
+	receipt := chain.Execute(alice, caller, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	report := receipt.Report
+	if report == nil || len(report.Frames) != 2 || common.HexToAddress(report.Frames[1].Address) != emitter {
+		t.Fatalf("report %+v, want the frames of the caller and the emitter", report)
+	}
+	if len(report.TraceFrames) != len(report.Trace) {
+		t.Fatalf("%d trace frames for %d entries", len(report.TraceFrames), len(report.Trace))
+	}
+	frames := make(map[string]int)
+	for i, entry := range report.Trace {
+		frames[entry] = report.TraceFrames[i]
+	}
+	if sstore, ok := frames["4SSTORE"]; !ok || sstore != 0 || frames["11LOG1"] != 1 || frames["37CALL"] != 0 || frames["38POP"] != 0 {
+		t.Errorf("trace frames %v, want SSTORE and CALL in frame 0, LOG1 in frame 1", frames)
+	}
+	if last := report.TraceFrames[len(report.TraceFrames)-1]; last != 0 {
+		t.Errorf("last trace entry in frame %d, want the caller resumed", last)
+	}
+	if len(report.StorageWrites) != 1 || report.StorageWrites[0].Frame != 0 {
+		t.Errorf("storage writes %+v, want one in frame 0", report.StorageWrites)
+	}
+	if len(report.Logs) != 1 {
+		t.Fatalf("logs %+v, want one", report.Logs)
+	}
+	if log := report.Logs[0]; log.Frame != 1 || log.Address != emitter || len(log.Topics) != 1 || log.Topics[0] != common.HexToHash("0x01") || log.Data != common.Bytes2Hex(common.LeftPadBytes([]byte{0xab}, 32)) {
+		t.Errorf("log %+v, want the event of the emitter in frame 1", log)
+	}
+}
+
+func TestAccountDiff(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// pop(create(0, 0, 0)) selfdestruct(caller)
+	factory := deploy(t, chain, common.FromHex("0x600060006000f05033ff"))
+	nonce := chain.State.GetNonce(alice)
+	receipt := chain.Execute(alice, factory, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if receipt.Report == nil || receipt.Report.Accounts == nil {
+		t.Fatalf("report %+v, want the accounts section", receipt.Report)
+	}
+	diff := receipt.Report.Accounts
+	child := crypto.CreateAddress(factory, 1)
+	want := []vm.HackerNonceChange{{Address: alice, Old: nonce, New: nonce + 1}, {Address: factory, Old: 1, New: 2}, {Address: child, Old: 0, New: 1}}
+	if len(diff.Nonces) != len(want) {
+		t.Fatalf("nonces %+v, want %+v", diff.Nonces, want)
+	}
+	for i := range want {
+		if diff.Nonces[i] != want[i] {
+			t.Errorf("nonce %d: %+v, want %+v", i, diff.Nonces[i], want[i])
+		}
+	}
+	if len(diff.Created) != 1 || diff.Created[0] != child {
+		t.Errorf("created %v, want %x", diff.Created, child)
+	}
+	if len(diff.Deleted) != 1 || diff.Deleted[0] != factory {
+		t.Errorf("deleted %v, want %x", diff.Deleted, factory)
+	}
+}
+
+func TestConcurrentWatches(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, callvalue) stop
+	first := deploy(t, chain, common.FromHex("0x3460005500"))
+	second := deploy(t, chain, common.FromHex("0x3460005500"))
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+
+	// both transactions are watched before either runs
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	txs := make([]*pending, 2)
+	for i, to := range []common.Address{first, second} {
+		txs[i] = watch(chain, dog, alice, to, new(big.Int).Mul(ether, big.NewInt(int64(i+1))), nil)
+	}
+	if watching := dog.Watching(); watching != 2 {
+		t.Fatalf("watching %d transactions, want 2", watching)
+	}
+	// the second one ends first
+	for _, i := range []int{1, 0} {
+		receipt, err := txs[i].call()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dog.End(receipt)
+	}
+	dog.OnBlockEnd(chain.header(), nil)
+	if watching := dog.Watching(); watching != 0 {
+		t.Errorf("watching %d transactions after the block, want 0", watching)
+	}
+	for i, to := range []common.Address{first, second} {
+		report := chain.Report(txs[i].tx.Hash())
+		if report == nil {
+			t.Fatalf("no report for transaction %d", i)
+		}
+		value := txs[i].tx.Value().Text(10)
+		if report.BalanceOld != "0" || report.BalanceNew != value {
+			t.Errorf("transaction %d: balance %s -> %s, want 0 -> %s", i, report.BalanceOld, report.BalanceNew, value)
+		}
+		if len(report.Frames) != 1 || common.HexToAddress(report.Frames[0].Address) != to || len(report.Trace) != 4 {
+			t.Errorf("transaction %d: frames %+v trace %v, want the execution of %s alone", i, report.Frames, report.Trace, to.Hex())
+		}
+	}
+}
+
+func TestUnwatchedEVM(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, callvalue) stop
+	watched := deploy(t, chain, common.FromHex("0x3460005500"))
+	// sstore(1, 1) sstore(2, 2) stop
+	other := deploy(t, chain, common.FromHex("0x6001600155600260025500"))
+
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	tx := watch(chain, dog, alice, watched, new(big.Int), nil)
+	// an EVM the WatchDog does not watch runs while the transaction is watched
+	unwatched, _ := chain.evm(deployer, new(big.Int))
+	if _, _, err := unwatched.Call(vm.AccountRef(deployer), other, nil, DefaultGas, new(big.Int)); err != nil {
+		t.Fatal(err)
+	}
+	receipt := tx.end(dog)
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	if len(receipt.Report.Frames) != 1 || len(receipt.Report.Trace) != 4 || len(receipt.Report.StorageWrites) != 1 {
+		t.Errorf("frames %+v trace %v writes %v, want the execution of the watched transaction alone", receipt.Report.Frames, receipt.Report.Trace, receipt.Report.StorageWrites)
+	}
+}
+
+// TestUnwatchedEVMRace runs an EVM nothing watches on another goroutine while the
+// transactions of the chain are watched, run it with -race.
+func TestUnwatchedEVMRace(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// both record a call tree: pop(staticcall(gas, 4, 0, 0, 0, 0))
+	// sstore(0, add(sload(0), 1)) stop
+	counter := common.FromHex("0x600060006000600060045afa50" + "60016000540160005500")
+	watched := deploy(t, chain, counter)
+
+	state := NewMemoryState()
+	other := common.HexToAddress("0x0b")
+	state.SetCode(other, counter)
+	evm := vm.NewEVM(chain.context(deployer, new(big.Int)), state, chain.Config, vm.Config{})
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	stop := func() {
+		close(done)
+		wg.Wait()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, _, err := evm.Call(vm.AccountRef(deployer), other, nil, DefaultGas, new(big.Int)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		receipt := chain.Execute(alice, watched, nil, nil)
+		if receipt.Report == nil {
+			stop()
+			t.Fatalf("transaction %d: no report", i)
+		}
+		if len(receipt.Report.Frames) != 1 || len(receipt.Report.Trace) != 15 || len(receipt.Report.StorageWrites) != 1 {
+			t.Errorf("transaction %d: frames %+v trace %v writes %v, want the watched transaction alone", i, receipt.Report.Frames, receipt.Report.Trace, receipt.Report.StorageWrites)
+		}
+	}
+	stop()
+	if state.GetState(other, common.Hash{}) == (common.Hash{}) {
+		t.Error("the unwatched EVM did not run")
+	}
+}
+
+func TestBalanceOldAtExecution(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, callvalue) stop
+	target := deploy(t, chain, common.FromHex("0x3460005500"))
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+
+	dog := vm.GetGlobalWatchDog()
+	tx := watch(chain, dog, alice, target, ether, nil)
+	// a transaction before it in the block pays the target
+	chain.State.AddBalance(target, new(big.Int).Mul(ether, big.NewInt(3)))
+	receipt, err := tx.call()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dog.End(receipt)
+	report := chain.Report(tx.tx.Hash())
+	if report == nil {
+		t.Fatal("no report")
+	}
+	if report.BalanceOld != "3000000000000000000" || report.BalanceNew != "4000000000000000000" {
+		t.Errorf("balance %s -> %s, want 3 ether -> 4 ether", report.BalanceOld, report.BalanceNew)
+	}
+}
+
+func TestEVMReset(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, add(sload(0), number)) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	evm := vm.NewEVM(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	_, first, err := evm.Call(vm.AccountRef(alice), counter, nil, DefaultGas, new(big.Int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Number.SetUint64(5)
+	evm.Reset(chain.context(alice, new(big.Int)), chain.State)
+	_, second, err := evm.Call(vm.AccountRef(alice), counter, nil, DefaultGas, new(big.Int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chain.State.GetState(counter, common.Hash{}); got != common.BigToHash(big.NewInt(6)) {
+		t.Errorf("counter %x, want the numbers 1 and 5 of both blocks", got)
+	}
+	// the slot is cold again in the next transaction, and no longer created
+	if DefaultGas-second != DefaultGas-first-(params.SstoreSetGas-(params.SstoreResetGas-vm.ColdSloadCostEIP2929)) {
+		t.Errorf("gas used %d then %d", DefaultGas-first, DefaultGas-second)
+	}
+}
+
+// benchmarkEVM calls a counter contract in a loop, with a new EVM per call unless
+// reuse is set.
+func benchmarkEVM(b *testing.B, reuse bool) {
+	chain := NewChain()
+	defer chain.Close()
+	addr, err := chain.DeployRuntime(deployer, common.FromHex("0x436000540160005500"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := chain.context(alice, new(big.Int))
+	evm := vm.NewEVM(ctx, chain.State, chain.Config, vm.Config{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if reuse {
+			evm.Reset(ctx, chain.State)
+		} else {
+			evm = vm.NewEVM(ctx, chain.State, chain.Config, vm.Config{})
+		}
+		if _, _, err := evm.Call(vm.AccountRef(alice), addr, nil, DefaultGas, new(big.Int)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEVMNew(b *testing.B)   { benchmarkEVM(b, false) }
+func BenchmarkEVMReset(b *testing.B) { benchmarkEVM(b, true) }
+
+// blockRecorder records the block boundaries it is told about.
+type blockRecorder struct {
+	started, ended []uint64
+	receipts       int
+}
+
+func (recorder *blockRecorder) OnBlockStart(header *types.Header) {
+	recorder.started = append(recorder.started, header.Number.Uint64())
+}
+
+func (recorder *blockRecorder) OnBlockEnd(header *types.Header, receipts types.Receipts) {
+	recorder.ended = append(recorder.ended, header.Number.Uint64())
+	recorder.receipts += len(receipts)
+}
+
+func TestBlockHooks(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	recorder := new(blockRecorder)
+	vm.RegisterBlockListener(recorder)
+	defer vm.UnregisterBlockListener(recorder)
+	before := vm.GetGlobalCampaign().Session()
+
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	chain.Execute(alice, counter, nil, nil)
+	chain.Execute(alice, counter, nil, nil)
+	if len(recorder.started) != 2 || recorder.started[0] != 1 || recorder.ended[1] != 2 || recorder.receipts != 2 {
+		t.Errorf("blocks started %v, ended %v with %d receipts", recorder.started, recorder.ended, recorder.receipts)
+	}
+	session := vm.GetGlobalCampaign().Session()
+	if session.Blocks-before.Blocks != 2 || session.Watched-before.Watched != 2 || session.Reported-before.Reported != 2 || session.LastBlock != 2 {
+		t.Errorf("session %+v after %+v, want two more watched and reported blocks", session, before)
+	}
+}
+
+func TestWatchDogState(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	reverter := deploy(t, chain, common.FromHex("0x60006000fd"))
+	dog := vm.GetGlobalWatchDog()
+
+	// armed on a transaction not executed yet
+	dog.OnBlockStart(chain.header())
+	tx := watch(chain, dog, alice, counter, new(big.Int), nil)
+	state := dog.State()
+	if !state.TurnOn || state.Started || state.Tx == nil || *state.Tx != tx.tx.Hash() || *state.To != counter || state.TraceLength != 0 {
+		t.Fatalf("armed state %+v", state)
+	}
+	tx.end(dog)
+	state = dog.State()
+	if state.TurnOn || state.HasThrow || *state.Tx != tx.tx.Hash() || state.TraceLength != 7 || state.StorageWrites != 0 {
+		t.Errorf("state %+v after the transaction", state)
+	}
+	receipt := chain.Execute(alice, reverter, nil, nil)
+	if state = dog.State(); !state.HasThrow || *state.Tx != receipt.Hash || state.TraceLength != 3 {
+		t.Errorf("state %+v after the failed transaction", state)
+	}
+}
+
+func TestHooksOff(t *testing.T) {
+	run := func(hooks bool) (*Receipt, common.Hash) {
+		vm.SetHooks(hooks)
+		defer vm.SetHooks(true)
+		chain := NewChain()
+		defer chain.Close()
+		counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+		reverter := deploy(t, chain, common.FromHex("0x60006000fd"))
+		caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af1506000600060006000600073"+common.Bytes2Hex(reverter[:])+"5af15000"))
+		return chain.Execute(alice, caller, nil, nil), chain.State.Digest()
+	}
+	on, onDigest := run(true)
+	off, offDigest := run(false)
+	if on.Report == nil || off.Report != nil {
+		t.Fatalf("reports %v with the hooks on, %v off", on.Report, off.Report)
+	}
+	if off.Err != on.Err || off.GasUsed != on.GasUsed || !bytes.Equal(off.Ret, on.Ret) || offDigest != onDigest {
+		t.Errorf("hooks off: %v, gas %d, state %s, want %v, gas %d, state %s", off.Err, off.GasUsed, offDigest.Hex(), on.Err, on.GasUsed, onDigest.Hex())
+	}
+	if !vm.HooksEnabled() {
+		t.Error("hooks still off")
+	}
+}
+
+func TestEndTracer(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, callvalue) stop
+	target := deploy(t, chain, common.FromHex("0x3460005500"))
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+
+	dog := vm.GetGlobalWatchDog()
+	send := func() *Report {
+		tx := watch(chain, dog, alice, target, new(big.Int), nil)
+		receipt, err := tx.call()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dog.EndTracer(receipt, map[string]int{"calls": 1})
+		chain.finalise()
+		chain.Number.Add(chain.Number, common.Big1)
+		return chain.Report(tx.tx.Hash())
+	}
+	report := send()
+	if report == nil || string(report.Raw["tracer"]) != `{"calls":1}` {
+		t.Fatalf("report %+v without the tracer result", report)
+	}
+	if state := dog.State(); state.TurnOn || state.Started || state.CallsOnly {
+		t.Errorf("state %+v of the transaction not reset", state)
+	}
+	for i := 1; i < 5; i++ {
+		send()
+	}
+	// sstore(0, callvalue) push1 1 push1 1 add pop stop costs 11 gas more
+	chain.State.SetCode(target, common.FromHex("0x3460005560016001015000"))
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	if regressions := vm.GetGlobalCampaign().GasRegressions(); len(regressions) != 1 || regressions[0].NewMean-regressions[0].OldMean != 11 {
+		t.Errorf("gas regressions %+v, want one of 11 gas", regressions)
+	}
+}
+
+// TestParallelExecutions runs two watched transactions at the same time, each on its
+// goroutine and watched by its WatchDog, while the state of the WatchDog is read as the
+// debug API does; run it with -race.
+func TestParallelExecutions(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// for i := 0; i < 120; i++ { sstore(i, i) }
+	loop := common.FromHex("0x60005b8060781115601457808055600101600256" + "5b00")
+	targets := []common.Address{deploy(t, chain, loop), deploy(t, chain, loop)}
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+
+	dog := vm.GetGlobalWatchDog()
+	// a round may run before the state is read again, a missing lock shows in one of them
+	for round := 0; round < 5; round++ {
+		dog.OnBlockStart(chain.header())
+		txs := make([]*pending, 2)
+		for i, to := range targets {
+			txs[i] = prepare(chain, alice, to, new(big.Int), nil)
+			// like the transactions of two blocks, each runs on its own state
+			txs[i].evm.StateDB = chain.State.Copy()
+			txs[i].watch(dog)
+		}
+		var (
+			wg    sync.WaitGroup
+			ready = make(chan struct{})
+			done  = make(chan struct{})
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for reads := 0; ; reads++ {
+				select {
+				case <-done:
+					return
+				default:
+					dog.State()
+					dog.Findings()
+					dog.Watching()
+					dog.SetProgressThreshold(0)
+				}
+				if reads == 0 {
+					close(ready)
+				}
+			}
+		}()
+		// the transactions run while the state is being read
+		<-ready
+		var running sync.WaitGroup
+		errs := make([]error, 2)
+		receipts := make([]*types.Receipt, 2)
+		for i := range txs {
+			running.Add(1)
+			go func(i int) {
+				defer running.Done()
+				receipts[i], errs[i] = txs[i].call()
+			}(i)
+		}
+		running.Wait()
+		for i := range txs {
+			if errs[i] != nil {
+				t.Fatalf("round %d, transaction %d: %v", round, i, errs[i])
+			}
+			dog.End(receipts[i])
+		}
+		close(done)
+		wg.Wait()
+		dog.OnBlockEnd(chain.header(), nil)
+		for i := range txs {
+			report := chain.Report(txs[i].tx.Hash())
+			if report == nil {
+				t.Fatalf("round %d: no report for transaction %d", round, i)
+			}
+			if len(report.StorageWrites) != 120 || len(report.Frames) != 1 || common.HexToAddress(report.Frames[0].Address) != targets[i] {
+				t.Errorf("round %d, transaction %d: %d storage writes, frames %+v, want the 120 writes of %s", round, i, len(report.StorageWrites), report.Frames, targets[i].Hex())
+			}
+		}
+	}
+}
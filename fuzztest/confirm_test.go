@@ -0,0 +1,100 @@
+package fuzztest
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// agent is the address the attacker templates are deployed at by the confirmation.
+var agent = common.HexToAddress("0xe930e50b62af818dbc955f345f9a3a3108f7a70d")
+
+func confirm(chain *Chain, kind string, to *common.Address, data []byte) (string, string) {
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	return harness.Confirm(&vm.HackerFinding{Type: kind, Source: "test"}, &vm.HackerMessage{From: alice, To: to, Data: data, Gas: DefaultGas})
+}
+
+func TestConfirm(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	victim := deploy(t, chain, ReentrancyVictim)
+	// the agent deposited one ether, two more belong to others
+	chain.State.SetState(victim, common.BytesToHash(agent.Bytes()), common.BigToHash(ether))
+	chain.Fund(victim, new(big.Int).Mul(ether, big.NewInt(3)))
+	// sstore(0, call(gas, caller, 0, 0, 0, 0, 0)) stop
+	checked := deploy(t, chain, common.FromHex("0x60006000600060006000335af160005500"))
+	// sstore(0, balance(address)) stop
+	balance := deploy(t, chain, common.FromHex("0x303160005500"))
+
+	tests := []struct {
+		kind     string
+		to       *common.Address
+		data     []byte
+		status   string
+		evidence string
+	}{
+		{"HackerReentrancy", &victim, []byte{1}, vm.FindingConfirmed, "reentrancy:" + new(big.Int).Mul(ether, big.NewInt(2)).String()},
+		{"HackerExceptionDisorder", &checked, nil, vm.FindingConfirmed, "revert_always:storage:"},
+		{"HackerBalanceGtZero", &balance, nil, vm.FindingConfirmed, "selfdestruct_funder:"},
+		// the callee does not call back into the agent
+		{"HackerReentrancy", &balance, nil, vm.FindingHeuristicOnly, ""},
+		// no template for the finding, no target
+		{"timestamp_dependence", &victim, []byte{1}, vm.FindingHeuristicOnly, ""},
+		{"HackerReentrancy", nil, []byte{1}, vm.FindingHeuristicOnly, ""},
+	}
+	for i, test := range tests {
+		status, evidence := confirm(chain, test.kind, test.to, test.data)
+		if status != test.status || !strings.HasPrefix(evidence, test.evidence) || (test.evidence == "" && evidence != "") {
+			t.Errorf("test %d (%s): status %q evidence %q, want %q %q", i, test.kind, status, evidence, test.status, test.evidence)
+		}
+	}
+	// the confirmations run on forks
+	if got := chain.State.GetBalance(victim); got.Cmp(new(big.Int).Mul(ether, big.NewInt(3))) != 0 {
+		t.Errorf("victim balance %v after the confirmations, want 3 ether", got)
+	}
+	if got := chain.State.GetBalance(balance); got.Sign() != 0 {
+		t.Errorf("ether forced into the contract: %v", got)
+	}
+}
+
+func TestConfirmBeforeTransaction(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	victim := deploy(t, chain, ReentrancyVictim)
+	// the agent deposited one ether, alice three
+	chain.State.SetState(victim, common.BytesToHash(agent.Bytes()), common.BigToHash(ether))
+	chain.State.SetState(victim, common.BytesToHash(alice.Bytes()), common.BigToHash(new(big.Int).Mul(ether, big.NewInt(3))))
+	chain.Fund(victim, new(big.Int).Mul(ether, big.NewInt(4)))
+
+	// alice withdraws her deposit, leaving the ether of the agent alone: replayed after
+	// the transaction, the reentrancy gains nothing
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	tx := watch(chain, dog, alice, victim, new(big.Int), []byte{1})
+	dog.EmitFinding(&vm.HackerFinding{Type: "HackerReentrancy", Source: "test"})
+	receipt := tx.end(dog)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetBalance(victim); got.Cmp(ether) != 0 {
+		t.Fatalf("victim balance %v, want 1 ether", got)
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	var finding *vm.HackerFinding
+	for i := range receipt.Report.Findings {
+		if receipt.Report.Findings[i].Source == "test" {
+			finding = &receipt.Report.Findings[i]
+		}
+	}
+	if finding == nil {
+		t.Fatal("finding not reported")
+	}
+	if finding.Status != vm.FindingConfirmed || !strings.HasPrefix(finding.Detail["confirmation"], "reentrancy:") {
+		t.Errorf("finding %s %v, want confirmed on the state before the transaction", finding.Status, finding.Detail)
+	}
+}
@@ -0,0 +1,151 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestDebugger(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	code := common.FromHex("0x436000540160005500")
+	counter := deploy(t, chain, code)
+	vm.SetBreakpoint(crypto.Keccak256Hash(code), 7)
+	defer vm.ClearBreakpoints()
+	vm.SetInteractive(true)
+	defer vm.SetInteractive(false)
+
+	done := make(chan *Receipt)
+	go func() { done <- chain.Execute(alice, counter, nil, nil) }()
+	pause := func() vm.HackerPausedState {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if states := vm.Paused(); len(states) != 0 {
+				return states[0]
+			}
+		}
+		t.Fatal("the execution did not pause")
+		return vm.HackerPausedState{}
+	}
+	state := pause()
+	if state.Address != counter || state.Pc != 7 || state.Op != "SSTORE" || len(state.Stack) != 2 || state.Stack[1].ToInt().Sign() != 0 || state.Stack[0].ToInt().Cmp(chain.Number) != 0 {
+		t.Errorf("paused %+v, want SSTORE of the block number in slot 0", state)
+	}
+	if value, err := vm.PausedStorage(state.ID, common.Hash{}); err != nil || value != (common.Hash{}) {
+		t.Errorf("slot 0 %x before SSTORE (%v), want 0", value, err)
+	}
+	if err := vm.Resume(state.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	// stepped to the next operation
+	state = pause()
+	if state.Pc != 8 || state.Op != "STOP" {
+		t.Errorf("paused %+v, want STOP", state)
+	}
+	if value, _ := vm.PausedStorage(state.ID, common.Hash{}); value.Big().Cmp(chain.Number) != 0 {
+		t.Errorf("slot 0 %x after SSTORE, want the block number", value)
+	}
+	if err := vm.Resume(state.ID+1, false); err == nil {
+		t.Error("resumed an execution not paused")
+	}
+	vm.Resume(state.ID, false)
+	select {
+	case receipt := <-done:
+		if receipt.Err != nil || receipt.Report == nil {
+			t.Errorf("receipt %+v, want the execution to complete", receipt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the execution did not complete")
+	}
+}
+
+func TestBreakpointSnapshot(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	code := common.FromHex("0x436000540160005500")
+	counter := deploy(t, chain, code)
+	chain.Execute(alice, counter, nil, nil)
+	vm.SetBreakpoint(crypto.Keccak256Hash(code), 7)
+	defer vm.ClearBreakpoints()
+
+	// not interactive, the execution goes on
+	report := chain.Execute(alice, counter, nil, nil).Report
+	if len(report.Breakpoints) != 1 {
+		t.Fatalf("breakpoints %+v, want the SSTORE", report.Breakpoints)
+	}
+	hit := report.Breakpoints[0]
+	if hit.CodeHash != crypto.Keccak256Hash(code) || hit.Address != counter || hit.Op != "SSTORE" || len(hit.Stack) != 2 {
+		t.Errorf("snapshot %+v, want the SSTORE of the counter", hit)
+	}
+	// the slot stored by the first execution
+	if value := hit.Storage[common.Hash{}]; value.Big().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("storage %v, want slot 0 stored by the first execution", hit.Storage)
+	}
+	if vm.Paused() == nil || len(vm.Paused()) != 0 {
+		t.Errorf("paused %+v without interactive mode", vm.Paused())
+	}
+	vm.ClearBreakpoints()
+	if report := chain.Execute(alice, counter, nil, nil).Report; report.Breakpoints != nil {
+		t.Errorf("breakpoints %+v once cleared", report.Breakpoints)
+	}
+}
+
+func TestSlotWatchpoint(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	vm.WatchSlot(counter, common.Hash{})
+	defer vm.UnwatchSlot(counter, common.Hash{})
+
+	receipt := chain.Execute(alice, counter, nil, common.FromHex("0x11111111"))
+	// an unwatched execution fires the watchpoint too
+	evm := vm.NewEVM(chain.context(deployer, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	if _, _, err := evm.Call(vm.AccountRef(deployer), counter, nil, DefaultGas, new(big.Int)); err != nil {
+		t.Fatal(err)
+	}
+	alerts := chain.Alerts()
+	if len(alerts) != 2 {
+		t.Fatalf("%d alerts, want 2", len(alerts))
+	}
+	first, second := alerts[0], alerts[1]
+	if first.Tx.Hash == nil {
+		first, second = second, first
+	}
+	if first.Type != "slot" || first.Address != counter || *first.Slot != (common.Hash{}) || first.New != common.BigToHash(big.NewInt(1)).Hex() {
+		t.Errorf("alert %+v, want the write of 1 to slot 0", first)
+	}
+	if first.Tx.Hash == nil || *first.Tx.Hash != receipt.Hash || first.Frame.Index == nil || *first.Frame.Index != 0 || first.Frame.Selector != "0x11111111" || first.Frame.Caller != alice {
+		t.Errorf("alert of the watched transaction %+v %+v", first.Tx, first.Frame)
+	}
+	if second.Tx.Hash != nil || second.Tx.Origin != deployer || second.Tx.BlockNumber != "2" || second.Old != common.BigToHash(big.NewInt(1)).Hex() {
+		t.Errorf("alert of the unwatched execution %+v", second)
+	}
+}
+
+func TestBalanceWatchpoint(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+	vm.WatchBalance(alice, ether)
+	defer vm.UnwatchBalance(alice)
+
+	// below the threshold
+	chain.Execute(alice, counter, new(big.Int).Div(ether, big.NewInt(2)), nil)
+	receipt := chain.Execute(alice, counter, new(big.Int).Mul(ether, big.NewInt(2)), common.FromHex("0x11111111"))
+	alerts := chain.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("%d alerts, want 1", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.Type != "balance" || alert.Address != alice || alert.Delta != "-2000000000000000000" || alert.Old != "9500000000000000000" {
+		t.Errorf("alert %+v, want alice sending 2 ether", alert)
+	}
+	if alert.Tx.Hash == nil || *alert.Tx.Hash != receipt.Hash || alert.Frame.Address != counter || alert.Frame.Selector != "0x11111111" {
+		t.Errorf("alert transaction %+v frame %+v", alert.Tx, alert.Frame)
+	}
+}
@@ -0,0 +1,50 @@
+/**
+* @fixtures.go
+* Fixture contracts, as runtime bytecode to deploy with Chain.DeployRuntime.
+* They are written by hand so the tests do not depend on a compiler; every fixture
+* ignores the function selector and has a single behaviour, storage is keyed by the
+* account address (no mapping hashes) unless stated otherwise.
+ */
+package fuzztest
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ReentrancyVictim keeps a balance per depositor in the slot of its address.
+	// Empty calldata deposits msg.value. Any calldata withdraws the whole balance of the
+	// caller, which is sent with all the gas before the slot is cleared:
+	//   calldatasize jumpi(withdraw)
+	//   sstore(caller, sload(caller) + callvalue) stop
+	//   withdraw: pop(call(gas, caller, sload(caller), 0, 0, 0, 0)) sstore(caller, 0) stop
+	ReentrancyVictim = common.FromHex("0x36600b5734335401335500" + "5b60006000600060003354335af150" + "6000335500")
+
+	// OverflowToken is transfer(address to, uint256 amount) without checks and without a
+	// Transfer event: the balance of the sender wraps around when it is too low.
+	//   amount := calldataload(0x24)
+	//   sstore(caller, sub(sload(caller), amount))
+	//   to := calldataload(4)
+	//   sstore(to, add(sload(to), amount)) stop
+	OverflowToken = common.FromHex("0x60243580335403335560043580548201905500")
+
+	// TimestampLottery pays its balance to the caller on odd block timestamps:
+	//   pop(call(gas, caller, mul(balance(address), mod(timestamp, 2)), 0, 0, 0, 0)) stop
+	TimestampLottery = common.FromHex("0x60006000600060006002420630310233" + "5af15000")
+)
+
+// Creation returns creation code deploying runtime: it copies the code following it
+// into memory and returns it.
+func Creation(runtime []byte) []byte {
+	size := len(runtime)
+	code := []byte{
+		0x61, byte(size >> 8), byte(size), // PUSH2 size
+		0x80,             // DUP1
+		0x61, 0x00, 0x0d, // PUSH2 13, the length of this prefix
+		0x60, 0x00, // PUSH1 0
+		0x39,       // CODECOPY
+		0x60, 0x00, // PUSH1 0
+		0xf3, // RETURN
+	}
+	return append(code, runtime...)
+}
@@ -0,0 +1,90 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestReentrancyVictim(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	victim := deploy(t, chain, ReentrancyVictim)
+	attacker, err := vm.NewHackerAttacker(vm.AttackerReentrancy, vm.HackerAttackerParams{Target: victim, Payload: []byte{1}, Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thief := deploy(t, chain, attacker.Code)
+	// the attacker deposited one ether, two more belong to others
+	chain.State.SetState(victim, common.BytesToHash(thief.Bytes()), common.BigToHash(ether))
+	chain.Fund(victim, new(big.Int).Mul(ether, big.NewInt(3)))
+
+	receipt := chain.Execute(alice, thief, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetBalance(thief); got.Cmp(new(big.Int).Mul(ether, big.NewInt(2))) != 0 {
+		t.Errorf("attacker balance %v, want 2 ether", got)
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	depths := make([]int, 0)
+	for _, frame := range receipt.Report.Frames {
+		if common.HexToAddress(frame.Address) == victim {
+			depths = append(depths, frame.Depth)
+		}
+	}
+	if len(depths) != 2 || depths[0] != 1 || depths[1] != 3 {
+		t.Errorf("victim entered at depths %v, want [1 3]", depths)
+	}
+}
+
+func TestOverflowToken(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	token := deploy(t, chain, OverflowToken)
+	transfer := vm.GetABIRegistry().Register(token, "transfer(address,uint256)")
+
+	data := append(transfer.Selector[:], common.LeftPadBytes(deployer.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes([]byte{5}, 32)...)
+	receipt := chain.Execute(alice, token, nil, data)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	wrapped := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), big.NewInt(5))
+	if got := chain.State.GetState(token, common.BytesToHash(alice.Bytes())); got != common.BigToHash(wrapped) {
+		t.Errorf("sender balance %x, want 2^256-5", got)
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	finding := receipt.Report.Finding("erc20_missing_transfer_event")
+	if finding == nil {
+		t.Fatalf("findings %v, want erc20_missing_transfer_event", receipt.Report.Findings)
+	}
+	if finding.Detail["value"] != "5" || common.HexToAddress(finding.Detail["to"]) != deployer {
+		t.Errorf("finding detail %v", finding.Detail)
+	}
+}
+
+func TestTimestampLottery(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	lottery := deploy(t, chain, TimestampLottery)
+	chain.Fund(lottery, ether)
+	chain.Time.SetUint64(1500000001)
+
+	receipt := chain.Execute(alice, lottery, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetBalance(alice); got.Cmp(ether) != 0 {
+		t.Errorf("winner balance %v, want 1 ether", got)
+	}
+	if receipt.Report == nil || !receipt.Report.HasFinding("weak_randomness") {
+		t.Fatalf("report %+v, want a weak_randomness finding", receipt.Report)
+	}
+}
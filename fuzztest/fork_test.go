@@ -0,0 +1,226 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestColdAccessGriefing(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// for i := 0; i < 40; i++ { pop(sload(i)) }
+	reader := deploy(t, chain, common.FromHex("0x60005b8054506001018060281160025700"))
+	receipt := chain.Execute(alice, reader, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if receipt.GasUsed < 40*vm.ColdSloadCostEIP2929 {
+		t.Errorf("gas used %d, want at least 40 cold loads", receipt.GasUsed)
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	finding := receipt.Report.Finding("cold_access_griefing")
+	if finding == nil || finding.Detail["cold"] != "40" || common.HexToAddress(finding.Detail["contract"]) != reader {
+		t.Fatalf("findings %v, want cold_access_griefing with 40 cold accesses", receipt.Report.Findings)
+	}
+}
+
+func TestDynamicFee(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	chain.BaseFee = big.NewInt(7)
+	// sstore(0, basefee) sstore(1, gasprice) stop
+	reader := deploy(t, chain, common.FromHex("0x486000553a60015500"))
+
+	receipt := chain.ExecuteDynamicFee(alice, reader, nil, nil, big.NewInt(10), big.NewInt(2))
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetState(reader, common.Hash{}); got != common.BigToHash(big.NewInt(7)) {
+		t.Errorf("basefee %x, want 7", got)
+	}
+	if got := chain.State.GetState(reader, common.HexToHash("0x01")); got != common.BigToHash(big.NewInt(9)) || receipt.GasPrice.Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("gas price %x paid %v, want base fee plus tip 9", got, receipt.GasPrice)
+	}
+	if receipt.Report == nil || string(receipt.Report.Raw["fees"]) != `{"baseFee":"7","gasPrice":"9","tip":"2"}` {
+		t.Fatalf("report fees %s", receipt.Report.Raw["fees"])
+	}
+	// the fee cap bounds the tip
+	if receipt = chain.ExecuteDynamicFee(alice, reader, nil, nil, big.NewInt(8), big.NewInt(2)); receipt.GasPrice.Cmp(big.NewInt(8)) != 0 {
+		t.Errorf("gas price %v, want the fee cap 8", receipt.GasPrice)
+	}
+	if receipt = chain.ExecuteDynamicFee(alice, reader, nil, nil, big.NewInt(6), big.NewInt(0)); receipt.Err != vm.ErrFeeCapTooLow {
+		t.Errorf("error %v, want %v", receipt.Err, vm.ErrFeeCapTooLow)
+	}
+}
+
+func TestRecentOpcodes(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(push0, shl(255, 1)) sstore(1, extcodehash(address)) stop
+	contract := deploy(t, chain, common.FromHex("0x600160ff1b5f55303f60015500"))
+	receipt := chain.Execute(alice, contract, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if got := chain.State.GetState(contract, common.Hash{}); got != common.BigToHash(new(big.Int).Lsh(common.Big1, 255)) {
+		t.Errorf("slot 0 %x, want 1 << 255", got)
+	}
+	if got := chain.State.GetState(contract, common.HexToHash("0x01")); got != chain.State.GetCodeHash(contract) {
+		t.Errorf("slot 1 %x, want the code hash", got)
+	}
+}
+
+func TestForkOptIn(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	defer vm.GetGlobalCampaign().SetFork(vm.ForkShanghai)
+	// shl(1, 1), chainid, basefee, push0 are introduced by Constantinople, Istanbul,
+	// London and Shanghai
+	opcodes := []struct {
+		contract common.Address
+		fork     vm.HackerFork
+	}{
+		{deploy(t, chain, common.FromHex("0x600160011b00")), vm.ForkConstantinople},
+		{deploy(t, chain, common.FromHex("0x4600")), vm.ForkIstanbul},
+		{deploy(t, chain, common.FromHex("0x4800")), vm.ForkLondon},
+		{deploy(t, chain, common.FromHex("0x5f00")), vm.ForkShanghai},
+	}
+	for _, name := range []string{"byzantium", "Constantinople", "istanbul", "berlin", "london", "shanghai"} {
+		fork, err := vm.ParseFork(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm.GetGlobalCampaign().SetFork(fork)
+		for _, opcode := range opcodes {
+			if receipt := chain.Execute(alice, opcode.contract, nil, nil); (receipt.Err == nil) != (fork >= opcode.fork) {
+				t.Errorf("%v: error %v running the opcode of %v", fork, receipt.Err, opcode.fork)
+			}
+		}
+	}
+	if _, err := vm.ParseFork("istanbul2"); err == nil {
+		t.Error("parsed an unknown fork")
+	}
+	// the campaign starts without a fork opted in, Byzantium runs as in the chain config
+	vm.GetGlobalCampaign().Reset()
+	if receipt := chain.Execute(alice, opcodes[0].contract, nil, nil); receipt.Err == nil {
+		t.Error("SHL ran in Byzantium")
+	}
+}
+
+func TestIstanbulSstore(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	defer vm.GetGlobalCampaign().SetFork(vm.ForkShanghai)
+	// sstore(0, 1) sstore(0, 2) sstore(0, 0) sstore(1, 0) stop
+	contract := deploy(t, chain, common.FromHex("0x600160005560026000556000600055600060015500"))
+	for _, test := range []struct {
+		fork   vm.HackerFork
+		gas    uint64
+		refund uint64
+	}{
+		// create, then a slot cleared and one left at zero at the reset price
+		{vm.ForkConstantinople, 4*6 + params.SstoreSetGas + 3*params.SstoreResetGas, params.SstoreRefundGas},
+		// create, then three writes of a slot already written or unchanged at the
+		// price of a read, the slot set back to its original zero refunds its creation
+		{vm.ForkIstanbul, 4*6 + params.SstoreSetGas + 3*vm.SloadGasEIP2200, params.SstoreSetGas - vm.SloadGasEIP2200},
+	} {
+		vm.GetGlobalCampaign().SetFork(test.fork)
+		state := chain.State.Copy()
+		evm := vm.NewEVM(chain.context(alice, new(big.Int)), state, chain.Config, vm.Config{})
+		_, left, err := evm.Call(vm.AccountRef(alice), contract, nil, DefaultGas, new(big.Int))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used, refund := DefaultGas-left, state.GetRefund(); used != test.gas || refund.Uint64() != test.refund {
+			t.Errorf("%v: gas %d refund %v, want %d and %d", test.fork, used, refund, test.gas, test.refund)
+		}
+	}
+}
+
+func TestChainIDOverride(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, chainid) sstore(1, selfbalance) stop
+	reader := deploy(t, chain, common.FromHex("0x466000554760015500"))
+	chain.Fund(reader, ether)
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	msg := &vm.HackerMessage{From: alice, To: &reader, Gas: DefaultGas}
+
+	var slots [2]common.Hash
+	harness.Fork(func() {
+		harness.Apply(msg)
+		slots[0], slots[1] = chain.State.GetState(reader, common.Hash{}), chain.State.GetState(reader, common.HexToHash("0x01"))
+	})
+	if slots[0] != common.BigToHash(chain.Config.ChainId) || slots[1] != common.BigToHash(ether) {
+		t.Errorf("chain id %x, self balance %x", slots[0], slots[1])
+	}
+	harness.SetChainID(big.NewInt(137))
+	harness.Fork(func() {
+		harness.Apply(msg)
+		slots[0] = chain.State.GetState(reader, common.Hash{})
+	})
+	if slots[0] != common.BigToHash(big.NewInt(137)) {
+		t.Errorf("chain id %x, want the override 137", slots[0])
+	}
+}
+
+func TestTypedTransaction(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	chain.BaseFee = big.NewInt(7)
+	// pop(sload(0)) stop
+	reader := deploy(t, chain, common.FromHex("0x6000545000"))
+	tx := &vm.HackerTypedTx{
+		Type:       vm.HackerDynamicFeeTxType,
+		ChainID:    big.NewInt(1),
+		GasFeeCap:  big.NewInt(10),
+		GasTipCap:  big.NewInt(2),
+		Gas:        DefaultGas,
+		To:         &reader,
+		AccessList: vm.HackerAccessList{{Address: reader, StorageKeys: []common.Hash{{}}}},
+	}
+	envelope, err := tx.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := chain.ExecuteTyped(alice, envelope)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if receipt.Hash != tx.Hash() || receipt.GasPrice.Cmp(big.NewInt(9)) != 0 {
+		t.Errorf("hash %x price %v, want %x and 9", receipt.Hash, receipt.GasPrice, tx.Hash())
+	}
+	if receipt.Report == nil {
+		t.Fatal("no report")
+	}
+	want := `{"type":2,"chainId":"1","maxFeePerGas":"10","maxPriorityFeePerGas":"2","accessList":[{"address":"` + hexutil.Encode(reader[:]) + `","storageKeys":["0x0000000000000000000000000000000000000000000000000000000000000000"]}]}`
+	if got := string(receipt.Report.Raw["tx"]); got != want {
+		t.Errorf("report tx %s, want %s", got, want)
+	}
+	// the slot of the access list is warm
+	if frame := receipt.Report.Frames[0]; frame.ColdAccesses != 0 || frame.WarmAccesses != 1 {
+		t.Errorf("frame accesses cold %d warm %d, want one warm", frame.ColdAccesses, frame.WarmAccesses)
+	}
+
+	// a legacy envelope is reported with its gas price
+	tx = &vm.HackerTypedTx{Type: vm.HackerLegacyTxType, Nonce: 1, GasPrice: big.NewInt(8), Gas: DefaultGas, To: &reader}
+	if envelope, err = tx.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	if receipt = chain.ExecuteTyped(alice, envelope); receipt.Report == nil || string(receipt.Report.Raw["tx"]) != `{"type":0,"gasPrice":"8","accessList":[]}` {
+		t.Fatalf("legacy report %v", receipt.Report)
+	}
+	if frame := receipt.Report.Frames[0]; frame.ColdAccesses != 1 {
+		t.Errorf("frame cold accesses %d, want 1", frame.ColdAccesses)
+	}
+	if receipt = chain.ExecuteTyped(alice, []byte{0x03, 0xc0}); receipt.Err != vm.ErrTxTypeNotSupported {
+		t.Errorf("error %v, want %v", receipt.Err, vm.ErrTxTypeNotSupported)
+	}
+}
@@ -0,0 +1,52 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestFrontRun(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// the first caller wins: if iszero(sload(0)) { sstore(0, caller) } stop
+	first := deploy(t, chain, common.FromHex("0x600054600a57336000555b00"))
+	// sstore(1, caller) stop
+	last := deploy(t, chain, common.FromHex("0x3360015500"))
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+
+	report := harness.FrontRun(&vm.HackerMessage{From: alice, To: &first, Gas: DefaultGas}, deployer)
+	if !report.Changed || report.Competitor != deployer || len(report.Reasons) != 1 || report.Reasons[0] != "storage:"+(common.Hash{}).Hex() {
+		t.Errorf("report %+v, want the slot of the winner changed", report)
+	}
+	if report.OriginalFailed || report.FrontRunFailed || report.CompetitorFailed {
+		t.Errorf("report %+v, want no failure", report)
+	}
+	// the transaction overwrites what the competitor wrote
+	if report := harness.FrontRun(&vm.HackerMessage{From: alice, To: &last, Gas: DefaultGas}, deployer); report.Changed {
+		t.Errorf("report %+v, want the outcome unchanged", report)
+	}
+	if slot := chain.State.GetState(first, common.Hash{}); slot != (common.Hash{}) {
+		t.Errorf("slot %x after the replays, want none applied", slot)
+	}
+
+	// the replay of the watched transactions
+	if receipt := chain.Execute(alice, last, nil, nil); receipt.Report.FrontRunning != nil {
+		t.Fatalf("report %+v without the replay", receipt.Report.FrontRunning)
+	}
+	dog := vm.GetGlobalWatchDog()
+	dog.EnableFrontRunning(deployer)
+	defer dog.DisableFrontRunning()
+	receipt := chain.Execute(alice, first, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if report := receipt.Report.FrontRunning; report == nil || !report.Changed || report.Competitor != deployer {
+		t.Fatalf("report %+v, want the watched transaction front run", report)
+	}
+	if winner := common.BytesToAddress(chain.State.GetState(first, common.Hash{}).Bytes()); winner != alice {
+		t.Errorf("winner %s, want the watched transaction applied alone", winner.Hex())
+	}
+}
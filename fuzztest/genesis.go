@@ -0,0 +1,109 @@
+/**
+* @genesis.go
+* Chains started on a genesis.json, so that private chains and L2-like configurations
+* can be campaign subjects.
+* 1 a Genesis is read in the layout of the genesis.json of geth init: the chain config,
+*   the coinbase, timestamp, number, difficulty, gas limit and base fee of the genesis
+*   block, and the alloc of the prefunded accounts and predeployed contracts. The
+*   integers are hex or decimal, the fields of other clients (nonce, mixHash, ...) are
+*   ignored.
+* 2 NewChainFromGenesis installs the alloc, unwatched, and mines the first transaction
+*   in the block after the genesis block (number + 1, timestamp + 15). A genesis without
+*   config runs on the test chain config.
+* 3 every chain is a session of its own: two chains started on different genesis files
+*   share nothing but the WatchDog, which starts over with each of them.
+ */
+package fuzztest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// GenesisAccount is an account of the alloc of a genesis.
+type GenesisAccount struct {
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+	Balance *math.HexOrDecimal256       `json:"balance"`
+	Nonce   math.HexOrDecimal64         `json:"nonce"`
+}
+
+// Genesis is the genesis block and state of a chain.
+type Genesis struct {
+	Config     *params.ChainConfig   `json:"config"`
+	Coinbase   common.Address        `json:"coinbase"`
+	Timestamp  math.HexOrDecimal64   `json:"timestamp"`
+	Number     math.HexOrDecimal64   `json:"number"`
+	Difficulty *math.HexOrDecimal256 `json:"difficulty"`
+	GasLimit   math.HexOrDecimal64   `json:"gasLimit"`
+	BaseFee    *math.HexOrDecimal256 `json:"baseFeePerGas"`
+	// Alloc is keyed by address, with or without 0x
+	Alloc map[string]GenesisAccount `json:"alloc"`
+}
+
+// LoadGenesis reads the genesis of the file at path.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return genesis, nil
+}
+
+// NewChainFromGenesis returns a chain, as NewChain, whose state and next block follow
+// genesis.
+func NewChainFromGenesis(genesis *Genesis) (*Chain, error) {
+	for addr := range genesis.Alloc {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("alloc: invalid address %q", addr)
+		}
+	}
+	chain := NewChain()
+	if genesis.Config != nil {
+		chain.Config = genesis.Config
+	}
+	chain.Coinbase = genesis.Coinbase
+	chain.Number = new(big.Int).SetUint64(uint64(genesis.Number) + 1)
+	chain.Time = new(big.Int).SetUint64(uint64(genesis.Timestamp) + 15)
+	if genesis.Difficulty != nil {
+		chain.Difficulty = new(big.Int).Set((*big.Int)(genesis.Difficulty))
+	}
+	if genesis.GasLimit != 0 {
+		chain.GasLimit = new(big.Int).SetUint64(uint64(genesis.GasLimit))
+	}
+	if genesis.BaseFee != nil {
+		chain.BaseFee = new(big.Int).Set((*big.Int)(genesis.BaseFee))
+	}
+	for addr, acc := range genesis.Alloc {
+		balance := new(big.Int)
+		if acc.Balance != nil {
+			balance = (*big.Int)(acc.Balance)
+		}
+		chain.alloc(common.HexToAddress(addr), balance, uint64(acc.Nonce), acc.Code, acc.Storage)
+	}
+	// the genesis state is not touched by the transactions
+	chain.State.Finalise(false)
+	return chain, nil
+}
+
+// alloc installs an account on the state of chain.
+func (chain *Chain) alloc(addr common.Address, balance *big.Int, nonce uint64, code []byte, storage map[common.Hash]common.Hash) {
+	chain.State.CreateAccount(addr)
+	chain.State.AddBalance(addr, balance)
+	chain.State.SetNonce(addr, nonce)
+	chain.State.SetCode(addr, code)
+	for key, value := range storage {
+		chain.State.SetState(addr, key, value)
+	}
+}
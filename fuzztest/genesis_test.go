@@ -0,0 +1,45 @@
+package fuzztest
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestChainFromGenesis(t *testing.T) {
+	genesis, err := LoadGenesis(filepath.Join("testdata", "genesis", "private.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := NewChainFromGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	counter := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	if balance := chain.State.GetBalance(alice); balance.Cmp(ether) < 0 || chain.State.GetNonce(counter) != 1 {
+		t.Fatalf("alloc not installed: balance %v, nonce %d", balance, chain.State.GetNonce(counter))
+	}
+
+	receipt := chain.Execute(alice, counter, nil, nil)
+	if receipt.Err != nil || receipt.Report == nil {
+		t.Fatalf("transaction failed: %v, report %v", receipt.Err, receipt.Report)
+	}
+	// the predeployed counter adds the number of the block after the genesis (42) to 100
+	if value := chain.State.GetState(counter, common.Hash{}); value != common.BigToHash(big.NewInt(142)) {
+		t.Errorf("counter %s, want 142", value.Hex())
+	}
+	env := receipt.Report.Env
+	if env == nil || env.ChainID != "1337" || env.Number != "42" || env.Time != "1509949455" || env.Difficulty != "1" || env.GasLimit != "30000000" || env.Coinbase != common.HexToAddress("0xc0") {
+		t.Errorf("env %+v, want the block after the genesis of chain 1337", env)
+	}
+}
+
+func TestGenesisInvalidAlloc(t *testing.T) {
+	genesis := &Genesis{Alloc: map[string]GenesisAccount{"0xnothex": {}}}
+	if _, err := NewChainFromGenesis(genesis); err == nil {
+		t.Error("chain started on an invalid alloc address")
+	}
+}
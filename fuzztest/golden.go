@@ -0,0 +1,89 @@
+/**
+* @golden.go
+* Golden reports: the wire format of the WatchDog reports, pinned by files under testdata.
+* A report is made comparable across runs and builds before it is written or compared:
+* 1 the labelled accounts (Chain.Label) are replaced with "<label>" wherever they appear,
+*   as an address or left padded to a word (storage slots keyed by an account).
+* 2 the transaction hash is replaced with "<tx>", it depends on the signer.
+* 3 the receipt is dropped, its encoding belongs to core/types and not to the fuzzer
+*   protocol.
+* The remaining sections are written as indented JSON with sorted keys. After a deliberate
+* change of the format, rewrite the files with: go test ./fuzztest -run Golden -update
+ */
+package fuzztest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// goldenDropped are the report sections left out of golden files.
+var goldenDropped = []string{"receipt"}
+
+// Label names addr in the golden reports of the chain.
+func (chain *Chain) Label(addr common.Address, name string) {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	if chain.labels == nil {
+		chain.labels = make(map[common.Address]string)
+	}
+	chain.labels[addr] = name
+}
+
+// Golden returns the normalized encoding of report.
+func (chain *Chain) Golden(report *Report) ([]byte, error) {
+	raw := make(map[string]json.RawMessage, len(report.Raw))
+	for section, value := range report.Raw {
+		raw[section] = value
+	}
+	for _, section := range goldenDropped {
+		delete(raw, section)
+	}
+	if _, ok := raw["hash"]; ok {
+		raw["hash"] = json.RawMessage(`"<tx>"`)
+	}
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(raw); err != nil {
+		return nil, err
+	}
+	encoded := buf.Bytes()
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+	for addr, name := range chain.labels {
+		account := regexp.MustCompile(`(?i)0x(000000000000000000000000)?` + strings.TrimPrefix(strings.ToLower(addr.Hex()), "0x"))
+		encoded = account.ReplaceAll(encoded, []byte("<"+name+">"))
+	}
+	return encoded, nil
+}
+
+// CompareGolden compares the normalized report with the golden file at path, or writes
+// the file when update is set.
+func (chain *Chain) CompareGolden(report *Report, path string, update bool) error {
+	if report == nil {
+		return fmt.Errorf("%s: no report", path)
+	}
+	got, err := chain.Golden(report)
+	if err != nil {
+		return err
+	}
+	if update {
+		return ioutil.WriteFile(path, got, 0644)
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%s: report changed, got\n%s", path, got)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package fuzztest
+
+import (
+	"flag"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden reports under testdata")
+
+// goldenCases set up a chain, send one transaction and return its receipt.
+var goldenCases = map[string]func(t *testing.T, chain *Chain) *Receipt{
+	"reentrancy": func(t *testing.T, chain *Chain) *Receipt {
+		victim := deploy(t, chain, ReentrancyVictim)
+		attacker, err := vm.NewHackerAttacker(vm.AttackerReentrancy, vm.HackerAttackerParams{Target: victim, Payload: []byte{1}, Depth: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		thief := deploy(t, chain, attacker.Code)
+		chain.Label(victim, "victim")
+		chain.Label(thief, "attacker")
+		chain.State.SetState(victim, common.BytesToHash(thief.Bytes()), common.BigToHash(ether))
+		chain.Fund(victim, new(big.Int).Mul(ether, big.NewInt(3)))
+		return chain.Execute(alice, thief, nil, nil)
+	},
+	"overflow_token": func(t *testing.T, chain *Chain) *Receipt {
+		token := deploy(t, chain, OverflowToken)
+		chain.Label(token, "token")
+		transfer := vm.GetABIRegistry().Register(token, "transfer(address,uint256)")
+		data := append(transfer.Selector[:], common.LeftPadBytes(deployer.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes([]byte{5}, 32)...)
+		return chain.Execute(alice, token, nil, data)
+	},
+	"timestamp_lottery": func(t *testing.T, chain *Chain) *Receipt {
+		lottery := deploy(t, chain, TimestampLottery)
+		chain.Label(lottery, "lottery")
+		chain.Fund(lottery, ether)
+		chain.Time.SetUint64(1500000001)
+		return chain.Execute(alice, lottery, nil, nil)
+	},
+}
+
+func TestGoldenReports(t *testing.T) {
+	for name, run := range goldenCases {
+		chain := NewChain()
+		chain.Label(deployer, "deployer")
+		chain.Label(alice, "alice")
+		receipt := run(t, chain)
+		if err := chain.CompareGolden(receipt.Report, filepath.Join("testdata", name+".golden.json"), *update); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		chain.Close()
+	}
+}
@@ -0,0 +1,456 @@
+package fuzztest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestFlashLoan(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// if balance(caller) >= 100 ether { call(gas, caller, 1 ether, 0, 0, 0, 0) } stop
+	whale := deploy(t, chain, common.FromHex("0x68056bc75e2d631000003331106025576000600060006000670de0b6b3a7640000335af1505b00"))
+	chain.Fund(whale, ether)
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	msg := &vm.HackerMessage{From: alice, To: &whale, Gas: DefaultGas}
+
+	loan := &vm.HackerFlashLoan{Ether: new(big.Int).Mul(ether, big.NewInt(100))}
+	outcome := harness.ExecuteFlashLoan(msg, loan, nil)
+	if outcome.Failed() || !outcome.Loan.Repaid || outcome.Loan.Profits[common.Address{}].Cmp(ether) != 0 {
+		t.Errorf("loan %+v, want 1 ether of profit", outcome.Loan)
+	}
+	if outcome := harness.ExecuteFlashLoan(msg, &vm.HackerFlashLoan{}, nil); outcome.Loan.Profits[common.Address{}].Sign() != 0 {
+		t.Errorf("profit %v without capital", outcome.Loan.Profits)
+	}
+	if balance := chain.State.GetBalance(alice); balance.Cmp(new(big.Int).Mul(ether, big.NewInt(10))) != 0 {
+		t.Errorf("balance %v after the loans, want 10 ether", balance)
+	}
+
+	// sstore(keccak256(caller, 0), 0) stop: the tokens lent are burned
+	burner := deploy(t, chain, common.FromHex("0x33600052600060205260406000206000905500"))
+	loan = &vm.HackerFlashLoan{Tokens: []vm.HackerTokenLoan{{Token: burner, Amount: big.NewInt(500)}}}
+	outcome = harness.ExecuteFlashLoan(&vm.HackerMessage{From: alice, To: &burner, Gas: DefaultGas}, loan, nil)
+	if outcome.Loan.Repaid || outcome.Loan.Profits[burner].Cmp(big.NewInt(-500)) != 0 {
+		t.Errorf("loan %+v, want 500 tokens not repaid", outcome.Loan)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// for {}
+	loop := deploy(t, chain, common.FromHex("0x5b600056"))
+	msg := &vm.HackerMessage{From: alice, To: &loop, Gas: DefaultGas}
+
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	harness.SetLimits(0, 1000)
+	if outcome := harness.Execute(msg); !outcome.Timeout || outcome.Err != vm.ErrExecutionTimeout {
+		t.Errorf("outcome %+v, want a timeout after 1000 steps", outcome)
+	}
+	// without gas metering only the wall clock stops the loop
+	harness = vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{DisableGasMetering: true})
+	harness.SetLimits(20*time.Millisecond, 0)
+	if outcome := harness.Execute(msg); !outcome.Timeout {
+		t.Errorf("outcome %+v, want a timeout", outcome)
+	}
+	// the reused EVM runs again after a timeout
+	harness.SetLimits(0, 0)
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	if outcome := harness.Execute(&vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas}); outcome.Failed() || outcome.Timeout {
+		t.Errorf("outcome %+v after a timeout", outcome)
+	}
+}
+
+func TestStepBudget(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	dog := vm.GetGlobalWatchDog()
+	dog.SetStepBudget(100)
+	defer dog.SetStepBudget(0)
+
+	// for {}
+	loop := deploy(t, chain, common.FromHex("0x5b600056"))
+	receipt := chain.Execute(alice, loop, nil, nil)
+	if receipt.Err != vm.ErrExecutionTimeout {
+		t.Errorf("error %v, want %v", receipt.Err, vm.ErrExecutionTimeout)
+	}
+	if receipt.Report == nil || string(receipt.Report.Raw["steps"]) != `{"budget":100,"count":100,"exceeded":true}` {
+		t.Fatalf("report steps %s", receipt.Report.Raw["steps"])
+	}
+	// sstore(0, add(sload(0), number)) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	if receipt = chain.Execute(alice, counter, nil, nil); receipt.Err != nil || string(receipt.Report.Raw["steps"]) != `{"budget":100,"count":7,"exceeded":false}` {
+		t.Fatalf("error %v, report steps %s", receipt.Err, receipt.Report.Raw["steps"])
+	}
+}
+
+func TestInstrumentedCall(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// call(gas, calldataload(0), 1, 0, 0, 0, 0) sstore(0, 1) stop
+	late := deploy(t, chain, common.FromHex("0x600060006000600060016000355af150600160005500"))
+	chain.Fund(late, ether)
+	// the state and context of an old block
+	header := chain.header()
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	msg := &vm.HackerMessage{From: alice, To: &late, Gas: DefaultGas, Data: common.LeftPadBytes(alice.Bytes(), 32)}
+	chain.Execute(alice, late, nil, nil)
+	session := vm.GetGlobalCampaign().Session()
+
+	if outcome := harness.Execute(msg); outcome.Report != nil {
+		t.Errorf("report %+v of a harness not instrumented", outcome.Report)
+	}
+	harness.SetInstrumented(true)
+	outcome := harness.Execute(msg)
+	if outcome.Failed() || outcome.Report == nil || !outcome.Report.Sent {
+		t.Fatalf("outcome %+v, want a report", outcome)
+	}
+	if len(outcome.Report.Findings) != 1 || outcome.Report.Findings[0].Type != "cei_violation" {
+		t.Errorf("findings %+v, want the CEI violation", outcome.Report.Findings)
+	}
+	report := chain.Report(outcome.Report.Hash)
+	if report == nil {
+		t.Fatal("no report sent")
+	}
+	if env := report.Env; !env.Call || env.Number != header.Number.String() || env.ForkBlock != header.Number.Uint64() {
+		t.Errorf("env %+v, want the call on the state of block %v", env, header.Number)
+	}
+	if again := harness.Execute(msg); again.Report == nil || again.Report.Hash == outcome.Report.Hash {
+		t.Errorf("report %+v, want another report for the same call", again.Report)
+	}
+	if got := vm.GetGlobalCampaign().Session(); got != session {
+		t.Errorf("session %+v, want %+v without the calls", got, session)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// call(gas, calldataload(0), 1, 0, 0, 0, 0) sstore(0, 1) stop
+	late := deploy(t, chain, common.FromHex("0x600060006000600060016000355af150600160005500"))
+	chain.Fund(late, ether)
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	bump := &vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas}
+	msgs := []*vm.HackerMessage{bump, {From: deployer, To: &late, Gas: DefaultGas, Data: common.LeftPadBytes(alice.Bytes(), 32)}, bump}
+
+	bundle := harness.ExecuteBundle(msgs, nil)
+	if bundle.Report != nil || len(bundle.Steps) != 3 {
+		t.Fatalf("bundle %+v, want 3 steps without a report", bundle)
+	}
+	// the second bump sees the first one
+	number := common.BigToHash(new(big.Int).Mul(chain.Number, big.NewInt(2)))
+	if slot := bundle.Steps[2].Storage[common.Hash{}]; slot != number {
+		t.Errorf("counter %x after 2 bumps, want %x", slot, number)
+	}
+	if slot := chain.State.GetState(counter, common.Hash{}); slot != (common.Hash{}) {
+		t.Errorf("counter %x once the bundle ran, want it reverted", slot)
+	}
+
+	harness.SetInstrumented(true)
+	bundle = harness.ExecuteBundle(msgs, nil)
+	if bundle.Report == nil || !bundle.Report.Sent {
+		t.Fatalf("bundle %+v, want a report", bundle)
+	}
+	for i, step := range bundle.Steps {
+		if step.Report != nil || step.Failed() {
+			t.Errorf("step %d %+v, want it in the report of the bundle", i, step)
+		}
+	}
+	report := chain.Report(bundle.Report.Hash)
+	if report == nil || len(report.Bundle) != 3 {
+		t.Fatalf("report %+v, want a section per step", report)
+	}
+	steps := report.Bundle
+	if steps[0].Trace[0] != 0 || steps[0].Trace[1] != steps[1].Trace[0] || steps[1].Trace[1] != steps[2].Trace[0] || steps[2].Trace[1] != uint64(len(report.Trace)) {
+		t.Errorf("steps %+v, want them to split the trace of %d operations", steps, len(report.Trace))
+	}
+	if steps[1].From != deployer || steps[1].To != late || steps[1].Findings != [2]int{0, 1} || steps[2].Findings != [2]int{1, 1} {
+		t.Errorf("step %+v, want the CEI violation of the second step", steps[1])
+	}
+	if report.Findings[0].Type != "cei_violation" || len(bundle.Report.Findings) != len(report.Findings) {
+		t.Errorf("findings %+v, want the ones of the report", bundle.Report.Findings)
+	}
+	if writes := report.StorageWrites; len(writes) == 0 || steps[0].GasUsed != bundle.Steps[0].GasUsed {
+		t.Errorf("report %+v, want the writes and the gas of the steps", report)
+	}
+}
+
+func TestRevertState(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(1, 0x22) revert(0, 0)
+	child := deploy(t, chain, common.FromHex("0x602260015560006000fd"))
+	// sstore(0, 0x11) call(gas, calldataload(0), 0, 0, 0, 0, 0) revert(0, 0)
+	parent := deploy(t, chain, common.FromHex("0x6011600055600060006000600060006000355af15060006000fd"))
+	// sstore(0, 0x11) call(gas, calldataload(0), 0, 0, 0, 0, 0) stop
+	survivor := deploy(t, chain, common.FromHex("0x6011600055600060006000600060006000355af15000"))
+	data := common.LeftPadBytes(child.Bytes(), 32)
+
+	if report := chain.Execute(alice, parent, nil, data).Report; report.RevertState != nil {
+		t.Errorf("revert state %+v, want none without the capture", report.RevertState)
+	}
+	vm.GetGlobalCampaign().SetRevertCapture(true)
+	receipt := chain.Execute(alice, parent, nil, data)
+	state := receipt.Report.RevertState
+	if receipt.Err == nil || state == nil {
+		t.Fatalf("receipt %+v, want the revert state of a failed transaction", receipt)
+	}
+	if state.Frame != 1 || state.Address != child || state.Error != vm.FrameRevert.String() {
+		t.Errorf("revert state %+v, want the failed call of the child", state)
+	}
+	want := []vm.HackerRevertSlot{
+		{Address: parent, Slot: common.Hash{}, New: common.BigToHash(big.NewInt(0x11))},
+		{Address: child, Slot: common.BigToHash(big.NewInt(1)), New: common.BigToHash(big.NewInt(0x22))},
+	}
+	if !reflect.DeepEqual(state.Storage, want) || len(state.Balances) != 0 {
+		t.Errorf("revert state diff %+v %+v, want %+v", state.Storage, state.Balances, want)
+	}
+	if slot := chain.State.GetState(parent, common.Hash{}); slot != (common.Hash{}) {
+		t.Errorf("slot %x, want the write rolled back", slot)
+	}
+	// a failed call does not revert the transaction
+	if report := chain.Execute(alice, survivor, nil, data).Report; report.RevertState != nil {
+		t.Errorf("revert state %+v of a successful transaction", report.RevertState)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	chain.Execute(alice, counter, nil, nil)
+	counted := chain.State.GetState(counter, common.Hash{})
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+
+	fixture := harness.DumpContract(counter)
+	if !bytes.Equal(fixture.Code, chain.State.GetCode(counter)) || len(fixture.Storage) != 1 || fixture.Storage[common.Hash{}] != counted {
+		t.Fatalf("fixture %+v, want the code and the storage of the counter", fixture)
+	}
+	dumped, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(vm.HackerContractFixture)
+	if err := json.Unmarshal(dumped, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := json.Marshal(loaded); !bytes.Equal(again, dumped) {
+		t.Errorf("fixture %s loaded as %s", dumped, again)
+	}
+
+	// the counter installed on another address of the forks
+	copied := common.HexToAddress("0xc0")
+	loaded.Address = copied
+	campaign := vm.GetGlobalCampaign()
+	campaign.LoadFixture(loaded)
+	harness.SetFixtures(campaign.FixtureOverride())
+	outcome := harness.Execute(&vm.HackerMessage{From: alice, To: &copied, Gas: DefaultGas})
+	if want := common.BigToHash(new(big.Int).Add(counted.Big(), chain.Number)); outcome.Failed() || outcome.Storage[common.Hash{}] != want {
+		t.Errorf("outcome %+v, want the counter %x", outcome, want)
+	}
+	if code := chain.State.GetCode(copied); len(code) != 0 {
+		t.Errorf("code %x installed on the canonical state", code)
+	}
+	if dump := harness.DumpContract(copied); !bytes.Equal(dump.Code, fixture.Code) {
+		t.Errorf("dump %+v, want the fixture installed", dump)
+	}
+	// a fixture replaces the whole storage
+	campaign.LoadFixture(&vm.HackerContractFixture{Address: counter, Code: fixture.Code})
+	harness.SetFixtures(campaign.FixtureOverride())
+	outcome = harness.Execute(&vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas})
+	if want := common.BigToHash(chain.Number); outcome.Storage[common.Hash{}] != want {
+		t.Errorf("counter %x, want %x counted from an empty storage", outcome.Storage[common.Hash{}], want)
+	}
+	if len(campaign.Fixtures()) != 2 {
+		t.Errorf("fixtures %+v, want 2", campaign.Fixtures())
+	}
+}
+
+func TestStorageRange(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	for i := int64(1); i <= 5; i++ {
+		chain.State.SetState(counter, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i*i)))
+	}
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	if _, err := harness.StorageRange(counter, common.Hash{}, 0); err == nil {
+		t.Fatal("storage of a contract never watched iterated")
+	}
+	chain.Execute(alice, counter, nil, nil)
+
+	var (
+		slots []vm.HackerStorageSlot
+		start common.Hash
+		pages int
+	)
+	for {
+		page, err := harness.StorageRange(counter, start, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slots, pages = append(slots, page.Storage...), pages+1
+		if page.Next == nil {
+			break
+		}
+		start = *page.Next
+	}
+	if len(slots) != 6 || pages != 2 {
+		t.Fatalf("%d slots in %d pages, want 6 in 2", len(slots), pages)
+	}
+	for i, slot := range slots {
+		if slot.Key != common.BigToHash(big.NewInt(int64(i))) || chain.State.GetState(counter, slot.Key) != slot.Value {
+			t.Errorf("slot %d %+v, want the slots in order", i, slot)
+		}
+	}
+}
+
+func TestDifferential(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// sstore(0, sload(0) + number + 1) stop
+	patched := common.FromHex("0x436000540160010160005500")
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	msg := &vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas}
+	if report := harness.Differential(msg, nil); report != nil {
+		t.Fatalf("report %+v without a differential", report)
+	}
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetDifferential(counter, nil, chain.State.GetCode(counter))
+	if report := harness.Differential(msg, nil); report == nil || report.Diverged {
+		t.Fatalf("report %+v, want the same code to agree", report)
+	}
+
+	campaign.SetDifferential(counter, nil, patched)
+	report := harness.Differential(msg, nil)
+	if want := []string{"storage:" + (common.Hash{}).Hex(), "write:0"}; report == nil || !report.Diverged || !reflect.DeepEqual(report.Reasons, want) || report.Failed != [2]bool{} {
+		t.Fatalf("report %+v, want the divergence %v", report, want)
+	}
+	receipt := chain.Execute(alice, counter, nil, nil)
+	if watched := receipt.Report.Differential; watched == nil || !watched.Diverged || watched.Target != counter {
+		t.Errorf("report %+v, want the divergence of the watched transaction", watched)
+	}
+	if slot := chain.State.GetState(counter, common.Hash{}); slot != common.BigToHash(new(big.Int).Sub(chain.Number, common.Big1)) {
+		t.Errorf("counter %x, want it counted once", slot)
+	}
+	campaign.ClearDifferential(counter)
+	if receipt := chain.Execute(alice, counter, nil, nil); receipt.Report.Differential != nil {
+		t.Errorf("report %+v of a cleared differential", receipt.Report.Differential)
+	}
+}
+
+func TestFaucet(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	bob, carol := common.HexToAddress("0xb0"), common.HexToAddress("0xca")
+	campaign := vm.GetGlobalCampaign()
+	campaign.Fund(bob, ether)
+	campaign.Fund(bob, ether)
+	if err := campaign.SetFaucet(&vm.HackerFaucet{Senders: []common.Address{carol}}); err == nil {
+		t.Fatal("faucet without amount")
+	}
+	campaign.SetFaucet(&vm.HackerFaucet{Senders: []common.Address{carol}, Threshold: (*hexutil.Big)(ether), Amount: (*hexutil.Big)(new(big.Int).Mul(ether, big.NewInt(2)))})
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	harness.SetFunding(campaign.Credits(), campaign.Faucet())
+
+	// bob spends one of the two ether credited
+	outcome := harness.Execute(&vm.HackerMessage{From: bob, To: &counter, Value: ether, Gas: DefaultGas})
+	if outcome.Failed() || outcome.Balances[bob].Cmp(ether) != 0 {
+		t.Fatalf("outcome %+v, want bob left with one ether", outcome)
+	}
+	// carol is topped up with 2 ether whenever she holds less than 1
+	msg := &vm.HackerMessage{From: carol, To: &counter, Value: ether, Gas: DefaultGas}
+	bundle := harness.ExecuteBundle([]*vm.HackerMessage{msg, msg, msg}, nil)
+	for i, want := range []int64{1, 0, 1} {
+		step := bundle.Steps[i]
+		if step.Failed() || step.Balances[carol].Cmp(new(big.Int).Mul(ether, big.NewInt(want))) != 0 {
+			t.Errorf("step %d: %+v, want carol left with %d ether", i, step, want)
+		}
+	}
+	for _, addr := range []common.Address{bob, carol} {
+		if balance := chain.State.GetBalance(addr); balance.Sign() != 0 {
+			t.Errorf("balance %v of %s on the canonical state", balance, addr.Hex())
+		}
+	}
+}
+
+func TestValidatedExecution(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// pop(sload(0)) stop
+	oracle := deploy(t, chain, common.FromHex("0x6000545000"))
+	// pop(sload(0)) pop(timestamp) call(gas, oracle, 0, 0, 0, 0, 0) pop stop
+	wallet := deploy(t, chain, common.FromHex("0x6000545042506000600060006000600073"+common.Bytes2Hex(oracle[:])+"5af15000"))
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	validation := &vm.HackerMessage{From: alice, To: &wallet, Gas: DefaultGas}
+	execution := &vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas}
+
+	outcome, err := harness.ExecuteValidated(validation, execution, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.Validation.Failed() || outcome.Execution == nil || outcome.Execution.Storage[common.Hash{}] != common.BigToHash(chain.Number) {
+		t.Fatalf("outcome %+v, want the execution run after the validation", outcome)
+	}
+	if slot := chain.State.GetState(counter, common.Hash{}); slot != (common.Hash{}) {
+		t.Errorf("counter %x once the operation ran, want it reverted", slot)
+	}
+	violations := outcome.Violations
+	if len(violations) != 2 || violations[0].Rule != "opcode" || violations[0].Op != "TIMESTAMP" || violations[0].Address != wallet || violations[0].Pc != 4 {
+		t.Fatalf("violations %+v, want TIMESTAMP then the storage of the oracle", violations)
+	}
+	if v := violations[1]; v.Rule != "storage" || v.Op != "SLOAD" || v.Address != oracle || v.Depth != 2 || v.Slot == nil || *v.Slot != (common.Hash{}) {
+		t.Errorf("violation %+v, want the load of the storage of the oracle", v)
+	}
+
+	// the oracle allowed and no opcode banned
+	rules := &vm.HackerValidationRules{Storage: []common.Address{oracle}, Banned: []string{}}
+	if outcome, err = harness.ExecuteValidated(validation, execution, rules, nil); err != nil || len(outcome.Violations) != 0 {
+		t.Errorf("violations %+v (%v), want none under the rules", outcome.Violations, err)
+	}
+	if _, err = harness.ExecuteValidated(validation, execution, &vm.HackerValidationRules{Banned: []string{"NOPE"}}, nil); err == nil {
+		t.Error("rules with an unknown opcode accepted")
+	}
+
+	// each phase is reported on its own, the violations in the report of the validation
+	harness.SetInstrumented(true)
+	if outcome, err = harness.ExecuteValidated(validation, execution, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if outcome.Validation.Report == nil || outcome.Execution.Report == nil || outcome.Validation.Report.Hash == outcome.Execution.Report.Hash {
+		t.Fatalf("outcome %+v, want a report per phase", outcome)
+	}
+	report := chain.Report(outcome.Validation.Report.Hash)
+	if report == nil || report.Finding("validation_violation") == nil || report.Finding("validation_violation").Detail["op"] != "TIMESTAMP" {
+		t.Errorf("report %+v, want the violations in the findings", report)
+	}
+	if report := chain.Report(outcome.Execution.Report.Hash); report == nil || report.Finding("validation_violation") != nil {
+		t.Errorf("report %+v of the execution, want no violation", report)
+	}
+
+	// a failed validation runs no execution: jump(0)
+	thrower := deploy(t, chain, common.FromHex("0x600056"))
+	validation.To = &thrower
+	if outcome, err = harness.ExecuteValidated(validation, execution, nil, nil); err != nil || !outcome.Validation.Failed() || outcome.Execution != nil {
+		t.Errorf("outcome %+v (%v), want the execution skipped", outcome, err)
+	}
+}
@@ -0,0 +1,79 @@
+package fuzztest
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+var (
+	deployer = common.HexToAddress("0x00000000000000000000000000000000000000d0")
+	alice    = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	ether    = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+)
+
+// TestMain runs nothing when the hooks of the instrumentation are compiled out
+// (nofuzz): the chain watches nothing then.
+func TestMain(m *testing.M) {
+	if !vm.HooksEnabled() {
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func deploy(t *testing.T, chain *Chain, runtime []byte) common.Address {
+	addr, err := chain.DeployRuntime(deployer, runtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := chain.State.GetCode(addr); string(code) != string(runtime) {
+		t.Fatalf("deployed code %x, want %x", code, runtime)
+	}
+	return addr
+}
+
+// pending is a transaction watched by hand, for the tests interleaving other work with
+// its steps; Chain.Execute sends a transaction from start to end.
+type pending struct {
+	chain *Chain
+	evm   *vm.EVM
+	tx    *types.Transaction
+	from  common.Address
+}
+
+// prepare returns the transaction of from to the contract to with the default gas, the
+// nonce of from is bumped. It is not watched yet.
+func prepare(chain *Chain, from, to common.Address, value *big.Int, data []byte) *pending {
+	evm, nonce := chain.evm(from, new(big.Int))
+	tx := types.NewTransaction(nonce, to, value, big.NewInt(DefaultGas), new(big.Int), data)
+	return &pending{chain: chain, evm: evm, tx: tx, from: from}
+}
+
+// watch prepares the transaction of from to the contract to and starts watching it on
+// dog.
+func watch(chain *Chain, dog *vm.WatchDog, from, to common.Address, value *big.Int, data []byte) *pending {
+	p := prepare(chain, from, to, value, data)
+	p.watch(dog)
+	return p
+}
+
+func (p *pending) watch(dog *vm.WatchDog) {
+	dog.Start()
+	dog.Watch(p.evm, p.tx)
+}
+
+// call runs the message call of the transaction and returns the receipt to end it with.
+func (p *pending) call() (*types.Receipt, error) {
+	_, gasLeft, err := p.evm.Call(vm.AccountRef(p.from), *p.tx.To(), p.tx.Data(), DefaultGas, p.tx.Value())
+	return &types.Receipt{TxHash: p.tx.Hash(), GasUsed: new(big.Int).SetUint64(DefaultGas - gasLeft)}, err
+}
+
+// end runs the transaction, ends it and its block on dog and returns its receipt, as
+// Chain.Execute does.
+func (p *pending) end(dog *vm.WatchDog) *Receipt {
+	return p.chain.run(p.evm, dog, p.tx.Hash(), p.from, *p.tx.To(), p.tx.Value(), p.tx.Data(), DefaultGas, new(big.Int))
+}
@@ -0,0 +1,280 @@
+package fuzztest
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestProfitOracle(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// call(gas, caller, 1 ether, 0, 0, 0, 0) stop
+	faucet := deploy(t, chain, common.FromHex("0x6000600060006000670de0b6b3a7640000335af15000"))
+	chain.Fund(faucet, new(big.Int).Mul(ether, big.NewInt(10)))
+
+	if report := chain.Execute(alice, faucet, nil, nil).Report; report.HasFinding("profit") {
+		t.Errorf("profit finding without a target")
+	}
+	vm.GetGlobalCampaign().SetProfitOracle([]common.Address{faucet}, nil)
+	defer vm.GetGlobalCampaign().SetProfitOracle(nil, nil)
+	finding := chain.Execute(alice, faucet, nil, nil).Report.Finding("profit")
+	if finding == nil {
+		t.Fatal("no profit finding")
+	}
+	if finding.Detail["profit"] != "1000000000000000000" || finding.Detail["target"] != faucet.Hex() || finding.Detail["sender"] != alice.Hex() {
+		t.Errorf("finding %+v, want alice making 1 ether", finding.Detail)
+	}
+	// the gas paid exceeds the ether received
+	price := big.NewInt(1000000000000000)
+	if report := chain.ExecuteDynamicFee(alice, faucet, nil, nil, price, price).Report; report.HasFinding("profit") {
+		t.Errorf("profit finding %+v at a loss", report.Finding("profit").Detail)
+	}
+	vm.GetGlobalCampaign().SetProfitOracle([]common.Address{faucet}, ether)
+	if report := chain.Execute(alice, faucet, nil, nil).Report; report.HasFinding("profit") {
+		t.Errorf("profit finding at the threshold")
+	}
+}
+
+func TestTokenProfitOracle(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// balanceOf(holder) returns sload(holder), any other call credits the caller with 100
+	token := deploy(t, chain, common.FromHex("0x36602414600f5760643354013355005b6004355460005260206000f3"))
+	chain.Fund(alice, new(big.Int).Mul(ether, big.NewInt(10)))
+	vm.GetGlobalCampaign().SetProfitOracle([]common.Address{token}, nil)
+	defer vm.GetGlobalCampaign().SetProfitOracle(nil, nil)
+
+	if report := chain.Execute(alice, token, nil, nil).Report; report.HasFinding("profit_token") {
+		t.Errorf("token profit finding without a registered token")
+	}
+	vm.GetGlobalCampaign().SetProfitTokens([]common.Address{token})
+	defer vm.GetGlobalCampaign().SetProfitTokens(nil)
+	report := chain.Execute(alice, token, nil, nil).Report
+	finding := report.Finding("profit_token")
+	if finding == nil {
+		t.Fatal("no token profit finding")
+	}
+	if finding.Detail["token:"+token.Hex()] != "100" || finding.Detail["sender"] != alice.Hex() {
+		t.Errorf("finding %+v, want alice getting 100 tokens", finding.Detail)
+	}
+	// the probes are left out of the trace
+	if len(report.Trace) != 12 {
+		t.Errorf("trace %v, want the 12 operations of the transaction", report.Trace)
+	}
+	// bought with ether
+	if report := chain.Execute(alice, token, ether, nil).Report; report.HasFinding("profit_token") {
+		t.Errorf("token profit finding %+v for a purchase", report.Finding("profit_token").Detail)
+	}
+}
+
+func TestCEIViolation(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	recipient := common.LeftPadBytes(alice.Bytes(), 32)
+	// call(gas, calldataload(0), 1, 0, 0, 0, 0) sstore(0, 1) stop
+	late := deploy(t, chain, common.FromHex("0x600060006000600060016000355af150600160005500"))
+	// sstore(0, 1) call(gas, calldataload(0), 1, 0, 0, 0, 0) stop
+	early := deploy(t, chain, common.FromHex("0x6001600055600060006000600060016000355af15000"))
+	// call(gas, 0xa1, 1, 0, 0, 0, 0) sstore(0, 1) stop
+	fixed := deploy(t, chain, common.FromHex("0x6000600060006000600173"+common.Bytes2Hex(alice[:])+"5af150600160005500"))
+	for _, addr := range []common.Address{late, early, fixed} {
+		chain.Fund(addr, ether)
+	}
+
+	finding := chain.Execute(alice, late, nil, recipient).Report.Finding("cei_violation")
+	if finding == nil {
+		t.Fatal("no finding for a write after the call")
+	}
+	if finding.Detail["rank"] != "high" || finding.Detail["callee"] != alice.Hex() || finding.Detail["callPc"] != "14" || finding.Detail["storePc"] != "20" {
+		t.Errorf("finding %+v, want a high rank write at 20 after the call at 14", finding.Detail)
+	}
+	if report := chain.Execute(alice, early, nil, recipient).Report; report.HasFinding("cei_violation") {
+		t.Errorf("finding %+v for a write before the call", report.Finding("cei_violation").Detail)
+	}
+	if finding := chain.Execute(alice, fixed, nil, nil).Report.Finding("cei_violation"); finding == nil || finding.Detail["rank"] != "low" {
+		t.Errorf("finding %+v, want a low rank for a constant callee", finding)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// calldatasize ? return(sload(0)) : sstore(0, caller)
+	owned := deploy(t, chain, common.FromHex("0x361560105760005460005260206000f35b3360005500"))
+	slot := common.Hash{}
+	campaign := vm.GetGlobalCampaign()
+	if _, err := campaign.AddConstraint(vm.HackerConstraint{Address: owned}); err == nil {
+		t.Error("constraint without a slot or a call accepted")
+	}
+	if _, err := campaign.AddConstraint(vm.HackerConstraint{Address: owned, Slot: &slot}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := campaign.AddConstraint(vm.HackerConstraint{Name: "owner", Address: owned, Call: common.FromHex("0x8da5cb5b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := chain.Execute(alice, owned, nil, nil).Report
+	if len(report.Findings) != 2 || report.Findings[0].Type != "constraint_violation" || report.Findings[1].Detail["name"] != "owner" {
+		t.Fatalf("findings %+v, want the violations of both constraints", report.Findings)
+	}
+	if owner := common.BytesToHash(alice.Bytes()); report.Findings[0].Detail["new"] != owner.Hex() || report.Findings[1].Detail["new"] != owner.Hex() {
+		t.Errorf("findings %+v, want the new owner %x", report.Findings, owner)
+	}
+	if again := chain.Execute(alice, owned, nil, nil).Report; again == nil || len(again.Findings) != 0 {
+		t.Errorf("report %+v, want it without violation", again)
+	}
+
+	campaign.SetNegativeMode(true)
+	if receipt := chain.Execute(alice, owned, nil, nil); receipt.Report != nil {
+		t.Errorf("report %+v sent in negative mode without violation", receipt.Report)
+	}
+	chain.State.SetState(owned, slot, common.BytesToHash(deployer.Bytes()))
+	if receipt := chain.Execute(alice, owned, nil, nil); receipt.Report == nil || len(receipt.Report.Findings) != 2 {
+		t.Errorf("receipt %+v, want the report of the violations", receipt)
+	}
+	for _, constraint := range campaign.Constraints() {
+		if constraint.Violations != 2 {
+			t.Errorf("constraint %+v, want 2 violations", constraint)
+		}
+	}
+}
+
+func TestProperties(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// calldatasize ? return(iszero(sload(0))) : sstore(0, caller)
+	harness := deploy(t, chain, common.FromHex("0x36156011576000541560005260206000f35b3360005500"))
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	campaign := vm.GetGlobalCampaign()
+	if _, err := campaign.RegisterProperties(counter, []string{"increment()"}); err == nil {
+		t.Error("harness without property accepted")
+	}
+	properties, err := campaign.RegisterProperties(harness, []string{"echidna_unowned()", "invariant_args(uint256)", "setOwner()"})
+	if err != nil || len(properties) != 1 || properties[0].Signature != "echidna_unowned()" {
+		t.Fatalf("properties %+v (%v), want echidna_unowned()", properties, err)
+	}
+
+	if report := chain.Execute(alice, counter, nil, nil).Report; len(report.Findings) != 0 {
+		t.Errorf("findings %+v while the property holds", report.Findings)
+	}
+	receipt := chain.Execute(alice, harness, nil, nil)
+	findings := receipt.Report.Findings
+	if len(findings) != 1 || findings[0].Type != "property_violation" || findings[0].Detail["property"] != "echidna_unowned()" || findings[0].Detail["reason"] != "false" {
+		t.Errorf("findings %+v, want the violation of echidna_unowned()", findings)
+	}
+	if steps := len(receipt.Report.Trace); steps != 9 {
+		t.Errorf("%d steps traced, want 9 without the property call", steps)
+	}
+	property := campaign.Properties()[0]
+	if property.Failures != 1 || property.FirstFailure == nil || *property.FirstFailure != receipt.Hash {
+		t.Errorf("property %+v, want the failure after %x", property, receipt.Hash)
+	}
+	campaign.UnregisterProperties(harness)
+	if report := chain.Execute(alice, harness, nil, nil).Report; len(report.Findings) != 0 {
+		t.Errorf("findings %+v of a harness unregistered", report.Findings)
+	}
+}
+
+func TestConsoleLog(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// staticcall(gas, console, log(string) of "hi") stop
+	logger := deploy(t, chain, common.FromHex("0x7f41304fac00000000000000000000000000000000000000000000000000000000600052602060045260026024527f6869000000000000000000000000000000000000000000000000000000000000604452600060006064600073000000000000000000636f6e736f6c652e6c6f675afa5000"))
+
+	receipt := chain.Execute(alice, logger, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	console := receipt.Report.Console
+	if len(console) != 1 || console[0].Message != "hi" || console[0].Address != logger || console[0].Frame != 0 {
+		t.Fatalf("console %+v, want the message of the logger", console)
+	}
+	if step := receipt.Report.Trace[console[0].Step]; !strings.HasSuffix(step, "STATICCALL") {
+		t.Errorf("step %s of the log, want the STATICCALL", step)
+	}
+}
+
+func TestRefundAbuse(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// for i := 0; i < 10; i++ { sstore(i, 0) }
+	token := deploy(t, chain, common.FromHex("0x60005b6000815560010180600a1160025700"))
+	for i := int64(0); i < 10; i++ {
+		chain.State.SetState(token, common.BigToHash(big.NewInt(i)), common.BigToHash(common.Big1))
+	}
+	receipt := chain.Execute(alice, token, nil, nil)
+	if receipt.Err != nil || receipt.Report == nil || receipt.Report.Refunds == nil {
+		t.Fatalf("no refunds: %v", receipt.Err)
+	}
+	refunds := receipt.Report.Refunds
+	if refunds.Counter != 10*4800 || len(refunds.Frames) != 1 || refunds.Frames[0].Index != 0 || refunds.Frames[0].Refund != 10*4800 {
+		t.Errorf("refunds %+v, want 4800 per cleared slot in the frame of the transaction", refunds)
+	}
+	if len(refunds.Cleared) != 10 || refunds.Cleared[9].Slot != common.BigToHash(big.NewInt(9)) || refunds.Cleared[9].Address != token || refunds.Cleared[9].Refund != 4800 {
+		t.Fatalf("cleared %+v, want the 10 slots", refunds.Cleared)
+	}
+	finding := receipt.Report.Finding("refund_abuse")
+	if finding == nil || finding.Detail["cleared"] != "10" || !strings.Contains(finding.Detail["slots"], token.Hex()+":"+common.BigToHash(big.NewInt(9)).Hex()) {
+		t.Fatalf("findings %v, want refund_abuse with the 10 cleared slots", receipt.Report.Findings)
+	}
+
+	// a single clear stays under the threshold
+	counter := deploy(t, chain, common.FromHex("0x600060005500"))
+	chain.State.SetState(counter, common.Hash{}, common.BigToHash(common.Big1))
+	receipt = chain.Execute(alice, counter, nil, nil)
+	if receipt.Report.Refunds == nil || len(receipt.Report.Refunds.Cleared) != 1 || receipt.Report.Finding("refund_abuse") != nil {
+		t.Errorf("refunds %+v, findings %v, want the clear unreported", receipt.Report.Refunds, receipt.Report.Findings)
+	}
+	vm.GetGlobalCampaign().SetRefundThreshold(1)
+	defer vm.GetGlobalCampaign().SetRefundThreshold(20)
+	chain.State.SetState(counter, common.Hash{}, common.BigToHash(common.Big1))
+	if receipt = chain.Execute(alice, counter, nil, nil); receipt.Report.Finding("refund_abuse") == nil {
+		t.Errorf("findings %v, want refund_abuse under a threshold of 1%%", receipt.Report.Findings)
+	}
+}
+
+func TestCalldataForwarding(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, calldataload(0)) stop
+	implementation := deploy(t, chain, common.FromHex("0x60003560005500"))
+	// calldatacopy(0, 0, calldatasize) delegatecall(gas, implementation, 0, calldatasize, 0, 0) pop stop
+	proxy := deploy(t, chain, common.FromHex("0x3660006000376000600036600073"+common.Bytes2Hex(implementation[:])+"5af45000"))
+	// calldatacopy(0, 0, calldatasize) call(gas, proxy, 0, 0, calldatasize, 0, 0) pop stop
+	router := deploy(t, chain, common.FromHex("0x36600060003760006000366000600073"+common.Bytes2Hex(proxy[:])+"5af15000"))
+
+	data := common.FromHex("0xa9059cbb0000000000000000000000000000000000000000000000000000000000000001")
+	receipt := chain.Execute(alice, router, nil, data)
+	if receipt.Err != nil || receipt.Report == nil || len(receipt.Report.Frames) != 3 {
+		t.Fatalf("no report of the 3 frames: %v", receipt.Err)
+	}
+	frames := receipt.Report.Frames
+	if frames[0].ForwardedFrom != nil || frames[1].ForwardedFrom == nil || *frames[1].ForwardedFrom != 0 || frames[2].ForwardedFrom == nil || *frames[2].ForwardedFrom != 1 {
+		t.Errorf("frames %+v, want the router forwarding to the proxy forwarding to the implementation", frames)
+	}
+	forwarding := receipt.Report.Forwarding
+	if len(forwarding) != 1 || forwarding[0].Selector != "0xa9059cbb" || !reflect.DeepEqual(forwarding[0].Frames, []int{0, 1, 2}) || forwarding[0].Implementation != implementation {
+		t.Fatalf("forwarding %+v, want the chain down to the implementation", forwarding)
+	}
+	var selector vm.HackerSelectorStat
+	if err := json.Unmarshal(receipt.Report.Raw["selector"], &selector); err != nil || selector.Implementation == nil || *selector.Implementation != implementation || selector.Selector != "0xa9059cbb" {
+		t.Errorf("selector %+v (%v), want it attributed to the implementation", selector, err)
+	}
+	if stats := vm.GetGlobalCampaign().SelectorStats(implementation); len(stats) != 1 || stats[0].Selector != "0xa9059cbb" || stats[0].Count != 1 {
+		t.Errorf("selectors %+v of the implementation, want the forwarded one", stats)
+	}
+
+	// a call with its own input forwards nothing: call(gas, proxy, 0, 0, 0, 0, 0) pop stop
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(proxy[:])+"5af15000"))
+	receipt = chain.Execute(alice, caller, nil, data)
+	if len(receipt.Report.Forwarding) != 0 || receipt.Report.Frames[1].ForwardedFrom != nil {
+		t.Errorf("forwarding %+v, want none", receipt.Report.Forwarding)
+	}
+}
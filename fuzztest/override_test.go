@@ -0,0 +1,68 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestStateOverride(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, balance(caller)) stop
+	balance := deploy(t, chain, common.FromHex("0x333160005500"))
+	// sstore(0, sload(1)) stop
+	copier := deploy(t, chain, common.FromHex("0x600154600055"))
+	chain.State.SetState(copier, common.BigToHash(big.NewInt(2)), common.BigToHash(big.NewInt(9)))
+	// no code yet
+	empty := common.HexToAddress("0xc0de")
+
+	slot := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+	tests := []struct {
+		name     string
+		to       common.Address
+		override vm.HackerStateOverride
+		storage  map[common.Hash]common.Hash
+	}{
+		{"balance", balance, vm.HackerStateOverride{alice: {Balance: big.NewInt(5)}}, map[common.Hash]common.Hash{slot(0): slot(5)}},
+		// sstore(0, 42) stop
+		{"code", empty, vm.HackerStateOverride{empty: {Code: common.FromHex("0x602a60005500")}}, map[common.Hash]common.Hash{slot(0): slot(42)}},
+		// the state replaces the storage, the diff patches it
+		{"state", copier, vm.HackerStateOverride{copier: {State: map[common.Hash]common.Hash{slot(1): slot(7)}}}, map[common.Hash]common.Hash{slot(0): slot(7), slot(1): slot(7)}},
+		{"stateDiff", copier, vm.HackerStateOverride{copier: {StateDiff: map[common.Hash]common.Hash{slot(1): slot(7)}}}, map[common.Hash]common.Hash{slot(0): slot(7), slot(1): slot(7), slot(2): slot(9)}},
+	}
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	for _, test := range tests {
+		to := test.to
+		outcome := harness.ExecuteWithOverride(&vm.HackerMessage{From: alice, To: &to, Gas: DefaultGas}, test.override)
+		if outcome.Err != nil {
+			t.Errorf("%s: %v", test.name, outcome.Err)
+			continue
+		}
+		for key, value := range outcome.Storage {
+			if value != test.storage[key] {
+				t.Errorf("%s: slot %s = %s, want %s", test.name, key.Hex(), value.Hex(), test.storage[key].Hex())
+			}
+		}
+		for key, value := range test.storage {
+			if outcome.Storage[key] != value {
+				t.Errorf("%s: slot %s = %s, want %s", test.name, key.Hex(), outcome.Storage[key].Hex(), value.Hex())
+			}
+		}
+	}
+	// the overrides are applied on a fork
+	if got := chain.State.GetBalance(alice); got.Cmp(big.NewInt(5)) == 0 {
+		t.Error("balance override left on the state")
+	}
+	if code := chain.State.GetCode(empty); len(code) != 0 {
+		t.Errorf("code override left on the state: %x", code)
+	}
+	if got := chain.State.GetState(copier, slot(1)); got != (common.Hash{}) {
+		t.Errorf("storage override left on the state: %s", got.Hex())
+	}
+	if got := chain.State.GetState(copier, slot(2)); got != slot(9) {
+		t.Errorf("storage cleared by the override: %s", got.Hex())
+	}
+}
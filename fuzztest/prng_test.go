@@ -0,0 +1,57 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestSeed(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, coinbase) sstore(1, difficulty) sstore(2, blockhash(sub(number, 1))) stop
+	env := deploy(t, chain, common.FromHex("0x4160005544600155600143034060025500"))
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	execute := func(seed *common.Hash) map[common.Hash]common.Hash {
+		outcome := harness.Execute(&vm.HackerMessage{From: alice, To: &env, Gas: DefaultGas, Seed: seed})
+		if outcome.Failed() {
+			t.Fatal(outcome.Err)
+		}
+		return outcome.Storage
+	}
+	coinbase := common.BytesToHash(chain.Coinbase.Bytes())
+
+	lucky, unlucky := common.HexToHash("0x1"), common.HexToHash("0x2")
+	first, again, other := execute(&lucky), execute(&lucky), execute(&unlucky)
+	for i := int64(0); i < 3; i++ {
+		slot := common.BigToHash(big.NewInt(i))
+		if first[slot] != again[slot] || first[slot] == other[slot] {
+			t.Errorf("slot %d: %x, %x again, %x with another seed", i, first[slot], again[slot], other[slot])
+		}
+	}
+	if first[common.Hash{}] == coinbase {
+		t.Error("the seeded execution read the coinbase of the chain")
+	}
+	// the executions without a seed read the chain
+	if storage := execute(nil); storage[common.Hash{}] != coinbase || storage[common.BigToHash(common.Big1)] != common.BigToHash(chain.Difficulty) {
+		t.Errorf("storage %v, want the coinbase and difficulty of the chain", storage)
+	}
+	// so do the executions with the hooks off
+	vm.SetHooks(false)
+	storage := execute(&lucky)
+	vm.SetHooks(true)
+	if storage[common.Hash{}] != coinbase {
+		t.Errorf("coinbase %x with the hooks off, want the one of the chain", storage[common.Hash{}])
+	}
+
+	// a seeded execution leaves the transactions of the node alone
+	receipt := chain.Execute(alice, env, nil, nil)
+	if receipt.Err != nil || receipt.Report == nil {
+		t.Fatalf("error %v, report %v", receipt.Err, receipt.Report)
+	}
+	if slot := chain.State.GetState(env, common.Hash{}); slot != coinbase || receipt.Report.Env.Seed != nil {
+		t.Errorf("coinbase %x, seed %v: the transaction of the node was seeded", slot, receipt.Report.Env.Seed)
+	}
+}
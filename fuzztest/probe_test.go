@@ -0,0 +1,186 @@
+package fuzztest
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestGriefing(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// call(gas, counter, 0, 0, 4, 0, 0) with the selector of transfer in memory
+	call := "0x63a9059cbb60e01b6000526000600060046000600073" + common.Bytes2Hex(counter[:]) + "5af1"
+	// pop sstore(1, 1) stop
+	unchecked := deploy(t, chain, common.FromHex(call+"50600160015500"))
+	// jumpi(ok, success) revert(0, 0) ok: sstore(1, 1) stop
+	checked := deploy(t, chain, common.FromHex(call+"603357600080fd5b600160015500"))
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+
+	for target, want := range map[common.Address]string{unchecked: vm.GriefingIgnored, checked: vm.GriefingReverted} {
+		target := target
+		report := harness.Griefing(&vm.HackerMessage{From: alice, To: &target, Gas: DefaultGas})
+		if report == nil || report.OriginalFailed || len(report.Calls) != 1 {
+			t.Fatalf("report %+v, want one callee", report)
+		}
+		if call := report.Calls[0]; call.Callee != counter || call.Op != "CALL" || call.Outcome != want {
+			t.Errorf("call %+v, want the counter %s", call, want)
+		}
+	}
+	if receipt := chain.Execute(alice, checked, nil, nil); receipt.Report.Griefing != nil {
+		t.Fatalf("report %+v without the probe", receipt.Report.Griefing)
+	}
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetGriefing(true)
+	defer campaign.SetGriefing(false)
+	receipt := chain.Execute(alice, checked, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	if report := receipt.Report.Griefing; report == nil || report.Target != checked || len(report.Calls) != 1 {
+		t.Fatalf("report %+v, want the probe of the watched transaction", report)
+	}
+	finding := receipt.Report.Finding("gas_griefing")
+	if finding == nil || finding.Detail["callee"] != counter.Hex() || finding.Detail["outcome"] != vm.GriefingReverted {
+		t.Errorf("finding %+v, want the counter reverting the transaction", finding)
+	}
+	if slot := chain.State.GetState(checked, common.BigToHash(common.Big1)); slot != common.BigToHash(common.Big1) {
+		t.Errorf("slot %x, want the watched transaction applied", slot)
+	}
+}
+
+func TestRevertBomb(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// call(gas, counter, 0, 0, 4, 0, 0) with the selector of transfer in memory
+	call := "0x63a9059cbb60e01b6000526000600060046000600073" + common.Bytes2Hex(counter[:]) + "5af1"
+	// pop sstore(1, 1) stop
+	unchecked := deploy(t, chain, common.FromHex(call+"50600160015500"))
+	// jumpi(ok, success) revert(0, 0) ok: sstore(1, 1) stop
+	checked := deploy(t, chain, common.FromHex(call+"603357600080fd5b600160015500"))
+
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetRevertBomb(true)
+	defer campaign.SetRevertBomb(false)
+	receipt := chain.Execute(alice, checked, nil, nil)
+	report := receipt.Report.RevertBomb
+	if report == nil || len(report.Calls) != 1 || report.Calls[0].Callee != counter || report.Calls[0].Outcome != vm.GriefingReverted {
+		t.Fatalf("report %+v, want the counter blocking the transaction", report)
+	}
+	if finding := receipt.Report.Finding("revert_bomb"); finding == nil || finding.Detail["callee"] != counter.Hex() {
+		t.Errorf("finding %+v, want the blocking counter", finding)
+	}
+	receipt = chain.Execute(alice, unchecked, nil, nil)
+	if report := receipt.Report.RevertBomb; report == nil || len(report.Calls) != 1 || report.Calls[0].Outcome != vm.GriefingIgnored {
+		t.Fatalf("report %+v, want the revert of the counter ignored", report)
+	}
+	if finding := receipt.Report.Finding("revert_bomb"); finding != nil {
+		t.Errorf("finding %+v of a target not blocked", finding)
+	}
+}
+
+func TestReturnPoisoning(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// mstore(0, 42) return(0, 32)
+	oracle := deploy(t, chain, common.FromHex("0x602a60005260206000f3"))
+	// call(gas, oracle, 0, 0, 0, 0, 0) pop returndatacopy(0, 0, 32) sstore(1, mload(0)) stop
+	target := deploy(t, chain, common.FromHex("0x600060006000600060006000"+"73"+common.Bytes2Hex(oracle[:])+"5af150602060006000"+"3e60005160015500"))
+
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetReturnPoisoning(true)
+	defer campaign.SetReturnPoisoning(false)
+	receipt := chain.Execute(alice, target, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	report := receipt.Report.ReturnPoisoning
+	if report == nil || len(report.Calls) != 4 {
+		t.Fatalf("report %+v, want a replay per stub", report)
+	}
+	want := map[string]string{"empty": vm.GriefingReverted, "short": vm.GriefingReverted, "dirty": vm.GriefingHandled, "oversized": vm.GriefingHandled}
+	for _, call := range report.Calls {
+		if call.Callee != oracle || call.Outcome != want[call.Stub] {
+			t.Errorf("call %+v, want the oracle %s", call, want[call.Stub])
+		}
+	}
+	if finding := receipt.Report.Finding("returndata_poisoning"); finding == nil || finding.Detail["callee"] != oracle.Hex() || finding.Detail["stub"] != "empty" {
+		t.Errorf("finding %+v, want the empty return data of the oracle", finding)
+	}
+	if slot := chain.State.GetState(target, common.BigToHash(common.Big1)); slot != common.BigToHash(big.NewInt(42)) {
+		t.Errorf("slot %x, want the answer of the oracle", slot)
+	}
+}
+
+func TestTimeSkew(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// jumpi(late, gt(timestamp, sload(0))) sstore(1, 1) stop late: revert(0, 0)
+	auction := deploy(t, chain, common.FromHex("0x6000544211600e576001600155005b600080fd"))
+	campaign := vm.GetGlobalCampaign()
+	if skews := campaign.TimeSkews(); skews != nil {
+		t.Fatalf("offsets %v, want the replays off", skews)
+	}
+	campaign.SetTimeSkew(true, nil)
+	defer campaign.SetTimeSkew(false, nil)
+	if skews := campaign.TimeSkews(); !reflect.DeepEqual(skews, []uint64{1, 15, 86400}) {
+		t.Fatalf("offsets %v, want the default ones", skews)
+	}
+	// the deadline is 10 seconds after the block of the watched transaction
+	chain.State.SetState(auction, common.Hash{}, common.BigToHash(new(big.Int).Add(chain.Time, big.NewInt(10))))
+	receipt := chain.Execute(alice, auction, nil, nil)
+	if receipt.Err != nil {
+		t.Fatal(receipt.Err)
+	}
+	report := receipt.Report.TimeSkew
+	if report == nil || report.OriginalFailed || len(report.Skews) != 3 {
+		t.Fatalf("report %+v, want a replay per offset", report)
+	}
+	for i, want := range []bool{false, true, true} {
+		if skew := report.Skews[i]; skew.Changed != want || skew.Failed != want {
+			t.Errorf("skew %+v, want changed %v", skew, want)
+		}
+	}
+	if report.Sensitivity < 0.66 || report.Sensitivity > 0.67 {
+		t.Errorf("sensitivity %v, want 2/3", report.Sensitivity)
+	}
+}
+
+func TestFeeSkew(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(1, gasprice) stop
+	refunder := deploy(t, chain, common.FromHex("0x3a60015500"))
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetFeeSkew(true, nil)
+	if variants := campaign.FeeVariants(); len(variants) != 4 {
+		t.Fatalf("variants %v, want the default ones", variants)
+	}
+	price := (*hexutil.Big)(big.NewInt(7))
+	campaign.SetFeeSkew(true, []vm.HackerFeeVariant{{GasPrice: price}, {BaseFee: price}})
+	defer campaign.SetFeeSkew(false, nil)
+	receipt := chain.Execute(alice, refunder, nil, nil)
+	report := receipt.Report.FeeSkew
+	if report == nil || report.OriginalFailed || len(report.Variants) != 2 {
+		t.Fatalf("report %+v, want a replay per variant", report)
+	}
+	if skew := report.Variants[0]; !skew.Changed || skew.GasPrice == nil || skew.GasPrice.ToInt().Int64() != 7 {
+		t.Errorf("variant %+v, want the gas price to change the outcome", skew)
+	}
+	if skew := report.Variants[1]; skew.Changed {
+		t.Errorf("variant %+v, want the base fee ignored", skew)
+	}
+	if report.Sensitivity != 0.5 {
+		t.Errorf("sensitivity %v, want 1/2", report.Sensitivity)
+	}
+	campaign.SetFeeSkew(false, nil)
+	if variants := campaign.FeeVariants(); variants != nil {
+		t.Errorf("variants %v, want the replays off", variants)
+	}
+}
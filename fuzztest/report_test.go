@@ -0,0 +1,158 @@
+package fuzztest
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRedaction(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// mstore(0, number) sstore(0, sload(0) + number) stop
+	code := common.FromHex("0x43600052436000540160005500")
+	counter := deploy(t, chain, code)
+	vm.SetBreakpoint(crypto.Keccak256Hash(code), 11)
+	defer vm.ClearBreakpoints()
+	input := common.FromHex("0x1122334455667788")
+
+	report := chain.Execute(alice, counter, nil, input).Report
+	if len(report.StorageWrites) != 1 || len(report.Breakpoints) != 1 || len(report.Breakpoints[0].Memory) != 32 || report.Frames[0].Input != "1122334455667788" {
+		t.Fatalf("report %+v, want the storage, memory and input in full", report)
+	}
+	vm.GetGlobalCampaign().SetRedaction(&vm.HackerRedaction{DropMemory: true, MaxInput: 4, ExcludeStorage: []common.Address{counter}})
+	report = chain.Execute(alice, counter, nil, input).Report
+	if len(report.StorageWrites) != 0 || string(report.Raw["storage_old"]) != "{}" || string(report.Raw["storage_new"]) != "{}" {
+		t.Errorf("storage writes %v, storage %s -> %s, want none", report.StorageWrites, report.Raw["storage_old"], report.Raw["storage_new"])
+	}
+	if hit := report.Breakpoints[0]; len(hit.Memory) != 0 || hit.Storage != nil || hit.Op != "SSTORE" {
+		t.Errorf("snapshot %+v, want neither memory nor storage", hit)
+	}
+	if frame := report.Frames[0]; frame.Input != "11223344" || frame.InputSize != 8 {
+		t.Errorf("frame input %s of %d bytes, want 4 bytes of 8", frame.Input, frame.InputSize)
+	}
+}
+
+func TestReportBudget(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// mstore(0, number) sstore(0, sload(0) + number) stop
+	code := common.FromHex("0x43600052436000540160005500")
+	counter := deploy(t, chain, code)
+	vm.SetBreakpoint(crypto.Keccak256Hash(code), 11)
+	defer vm.ClearBreakpoints()
+	campaign := vm.GetGlobalCampaign()
+	if err := campaign.SetReportBudget(&vm.HackerReportBudget{MaxBytes: 1, Priority: []string{"trace", "findings"}}); err == nil {
+		t.Fatal("budget eliding the findings accepted")
+	}
+
+	campaign.SetReportBudget(&vm.HackerReportBudget{MaxBytes: 1 << 20})
+	defer campaign.SetReportBudget(nil)
+	if report := chain.Execute(alice, counter, nil, nil).Report; report.Raw["elided"] != nil || len(report.Breakpoints) != 1 {
+		t.Fatalf("sections %v elided, want the report whole", report.Elided)
+	}
+	campaign.SetReportBudget(&vm.HackerReportBudget{MaxBytes: 1})
+	report := chain.Execute(alice, counter, nil, nil).Report
+	if len(report.Elided) < 2 || report.Elided[0] != "breakpoints" || report.Elided[1] != "trace" {
+		t.Fatalf("sections %v elided, want the snapshots then the trace first", report.Elided)
+	}
+	for _, section := range report.Elided {
+		if report.Raw[section] != nil {
+			t.Errorf("section %s elided and sent", section)
+		}
+	}
+	for _, section := range []string{"findings", "coverage", "hash", "receipt", "hasThrow"} {
+		if report.Raw[section] == nil {
+			t.Errorf("section %s dropped", section)
+		}
+	}
+	campaign.SetReportBudget(&vm.HackerReportBudget{MaxBytes: 1, Priority: []string{"logs", "trace"}})
+	if report := chain.Execute(alice, counter, nil, nil).Report; !reflect.DeepEqual(report.Elided, []string{"logs", "trace"}) || report.Raw["breakpoints"] == nil {
+		t.Errorf("sections %v elided, want the ones of the priority", report.Elided)
+	}
+}
+
+func TestReportEnv(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, coinbase) stop
+	counter := deploy(t, chain, common.FromHex("0x4160005500"))
+
+	header := chain.header()
+	env := chain.Execute(alice, counter, nil, nil).Report.Env
+	if env == nil {
+		t.Fatal("no env section")
+	}
+	if env.Number != header.Number.String() || env.ForkBlock != header.Number.Uint64()-1 || env.Time != header.Time.String() || env.Node != params.Version || env.Seed != nil || env.ChainID != chain.Config.ChainId.String() {
+		t.Errorf("env %+v, want the block %v on its parent", env, header.Number)
+	}
+	if again := chain.Execute(alice, counter, nil, nil).Report.Env; again.Instrumentation != env.Instrumentation {
+		t.Errorf("instrumentation %x, want %x unchanged", again.Instrumentation, env.Instrumentation)
+	}
+	if err := vm.SetOpBreakpoint("SSTORE"); err != nil {
+		t.Fatal(err)
+	}
+	if breaking := chain.Execute(alice, counter, nil, nil).Report.Env; breaking.Instrumentation == env.Instrumentation {
+		t.Errorf("instrumentation %x unchanged by a breakpoint", breaking.Instrumentation)
+	}
+	vm.ClearBreakpoints()
+
+	seed := common.HexToHash("0x5eed")
+	harness := vm.NewHackerHarness(chain.context(alice, new(big.Int)), chain.State, chain.Config, vm.Config{})
+	harness.SetInstrumented(true)
+	outcome := harness.Execute(&vm.HackerMessage{From: alice, To: &counter, Gas: DefaultGas, Seed: &seed})
+	if outcome.Report == nil || chain.Report(outcome.Report.Hash) == nil {
+		t.Fatal("no report of the seeded call")
+	}
+	seeded := chain.Report(outcome.Report.Hash).Env
+	if seeded.Seed == nil || *seeded.Seed != seed || seeded.Instrumentation != env.Instrumentation {
+		t.Fatalf("env %+v, want the seed with the instrumentation unchanged", seeded)
+	}
+	// the coinbase reported is the one the execution read
+	if stored := outcome.Storage[common.Hash{}]; seeded.Coinbase == env.Coinbase || common.BytesToAddress(stored.Bytes()) != seeded.Coinbase {
+		t.Errorf("coinbase %x, stored %x", seeded.Coinbase, stored)
+	}
+}
+
+func TestGasBreakdown(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// sstore(0, sload(0) + number) stop
+	counter := deploy(t, chain, common.FromHex("0x436000540160005500"))
+	// call(gas, counter, 0, 0, 0, 0, 0) pop, sstore(1, 0) stop
+	caller := deploy(t, chain, common.FromHex("0x6000600060006000600073"+common.Bytes2Hex(counter[:])+"5af15060006001550000"))
+	chain.State.SetState(caller, common.BigToHash(common.Big1), common.BigToHash(common.Big1))
+
+	data := []byte{0, 1}
+	receipt := chain.Execute(alice, caller, nil, data)
+	if receipt.Err != nil || receipt.Report == nil || receipt.Report.Gas == nil {
+		t.Fatalf("no gas breakdown: %v", receipt.Err)
+	}
+	gas := receipt.Report.Gas
+	if gas.Limit != DefaultGas || gas.Intrinsic != 21000+4+16 || gas.Execution != receipt.GasUsed {
+		t.Errorf("breakdown %+v, want the intrinsic gas of 2 bytes and the execution gas %d", gas, receipt.GasUsed)
+	}
+	// clearing slot 1 refunds 4800, under the cap of a fifth
+	if gas.RefundCounter != 4800 || gas.Refund != 4800 || gas.Used != gas.Intrinsic+gas.Execution-4800 {
+		t.Errorf("breakdown %+v, want the refund of the cleared slot applied", gas)
+	}
+	if len(gas.Frames) != 1 || gas.Frames[0].Address != counter.Hex() || gas.Frames[0].Kind != "CALL" || gas.Frames[0].GasUsed == 0 || gas.Own+gas.Frames[0].GasUsed != gas.Execution {
+		t.Fatalf("frames %+v, own %d, want the call of the counter", gas.Frames, gas.Own)
+	}
+	frame := receipt.Report.Frames[gas.Frames[0].Index]
+	if frame.GasUsed != gas.Frames[0].GasUsed || frame.Gas != gas.Frames[0].Gas || receipt.Report.Frames[0].GasUsed != gas.Execution {
+		t.Errorf("frames %+v, want the gas of the breakdown", receipt.Report.Frames)
+	}
+
+	// a frame failing with an error uses all of its gas: jump(0)
+	thrower := deploy(t, chain, common.FromHex("0x600056"))
+	receipt = chain.Execute(alice, thrower, nil, nil)
+	if gas := receipt.Report.Gas; gas.Execution != DefaultGas || gas.Used != DefaultGas+21000 {
+		t.Errorf("breakdown %+v of the failed transaction, want all the gas used", gas)
+	}
+}
@@ -0,0 +1,323 @@
+/**
+* @state.go
+* In-memory state for the integration tests.
+* 1 accounts live in a map, nothing is persisted and no trie is built.
+* 2 every mutation appends an undo entry to a journal, a snapshot is the journal length
+*   when it was taken and reverting replays the entries back to it. This is what the
+*   harness forks and the interpreter's reverts rely on.
+* 3 a mutated account is touched; after EIP-158 the touched accounts left empty are
+*   deleted at the end of the transaction (EIP-161), as by the trie backed state.
+ */
+package fuzztest
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type account struct {
+	balance  *big.Int
+	nonce    uint64
+	code     []byte
+	storage  map[common.Hash]common.Hash
+	suicided bool
+	touched  bool
+}
+
+// MemoryState is a vm.StateDB kept in memory.
+type MemoryState struct {
+	accounts  map[common.Address]*account
+	refund    *big.Int
+	logs      []*types.Log
+	journal   []func()
+	revisions []int
+}
+
+var (
+	_ vm.StateDB             = (*MemoryState)(nil)
+	_ vm.HackerStateCopier   = (*MemoryState)(nil)
+	_ vm.HackerStorageSeeker = (*MemoryState)(nil)
+)
+
+func NewMemoryState() *MemoryState {
+	return &MemoryState{accounts: make(map[common.Address]*account), refund: new(big.Int)}
+}
+
+// Copy returns a copy of the state with an empty journal, the WatchDog runs its probes
+// on a copy of the state of the block.
+func (state *MemoryState) Copy() vm.StateDB {
+	copied := &MemoryState{accounts: make(map[common.Address]*account, len(state.accounts)), refund: new(big.Int).Set(state.refund)}
+	for addr, acc := range state.accounts {
+		storage := make(map[common.Hash]common.Hash, len(acc.storage))
+		for key, value := range acc.storage {
+			storage[key] = value
+		}
+		copied.accounts[addr] = &account{balance: new(big.Int).Set(acc.balance), nonce: acc.nonce, code: acc.code, storage: storage, suicided: acc.suicided, touched: acc.touched}
+	}
+	copied.logs = append(copied.logs, state.logs...)
+	return copied
+}
+
+// get returns the account at addr, creating it when create is set. The account is
+// about to be mutated when create is set, it is touched.
+func (state *MemoryState) get(addr common.Address, create bool) *account {
+	acc := state.accounts[addr]
+	if acc == nil && create {
+		acc = &account{balance: new(big.Int), storage: make(map[common.Hash]common.Hash)}
+		state.accounts[addr] = acc
+		state.journal = append(state.journal, func() { delete(state.accounts, addr) })
+	}
+	if acc != nil && create && !acc.touched {
+		acc.touched = true
+		state.journal = append(state.journal, func() { acc.touched = false })
+	}
+	return acc
+}
+
+func (state *MemoryState) CreateAccount(addr common.Address) {
+	prev := state.accounts[addr]
+	acc := &account{balance: new(big.Int), storage: make(map[common.Hash]common.Hash), touched: true}
+	if prev != nil {
+		// the balance survives, as in the trie backed state
+		acc.balance.Set(prev.balance)
+	}
+	state.accounts[addr] = acc
+	state.journal = append(state.journal, func() {
+		if prev == nil {
+			delete(state.accounts, addr)
+		} else {
+			state.accounts[addr] = prev
+		}
+	})
+}
+
+func (state *MemoryState) SubBalance(addr common.Address, amount *big.Int) {
+	state.AddBalance(addr, new(big.Int).Neg(amount))
+}
+
+func (state *MemoryState) AddBalance(addr common.Address, amount *big.Int) {
+	acc := state.get(addr, true)
+	prev := acc.balance
+	acc.balance = new(big.Int).Add(prev, amount)
+	state.journal = append(state.journal, func() { acc.balance = prev })
+}
+
+func (state *MemoryState) GetBalance(addr common.Address) *big.Int {
+	if acc := state.get(addr, false); acc != nil {
+		return acc.balance
+	}
+	return new(big.Int)
+}
+
+func (state *MemoryState) GetNonce(addr common.Address) uint64 {
+	if acc := state.get(addr, false); acc != nil {
+		return acc.nonce
+	}
+	return 0
+}
+
+func (state *MemoryState) SetNonce(addr common.Address, nonce uint64) {
+	acc := state.get(addr, true)
+	prev := acc.nonce
+	acc.nonce = nonce
+	state.journal = append(state.journal, func() { acc.nonce = prev })
+}
+
+func (state *MemoryState) GetCodeHash(addr common.Address) common.Hash {
+	acc := state.get(addr, false)
+	if acc == nil {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(acc.code)
+}
+
+func (state *MemoryState) GetCode(addr common.Address) []byte {
+	if acc := state.get(addr, false); acc != nil {
+		return acc.code
+	}
+	return nil
+}
+
+func (state *MemoryState) SetCode(addr common.Address, code []byte) {
+	acc := state.get(addr, true)
+	prev := acc.code
+	acc.code = code
+	state.journal = append(state.journal, func() { acc.code = prev })
+}
+
+func (state *MemoryState) GetCodeSize(addr common.Address) int {
+	return len(state.GetCode(addr))
+}
+
+func (state *MemoryState) AddRefund(gas *big.Int) {
+	prev := state.refund
+	state.refund = new(big.Int).Add(prev, gas)
+	state.journal = append(state.journal, func() { state.refund = prev })
+}
+
+func (state *MemoryState) GetRefund() *big.Int {
+	return state.refund
+}
+
+func (state *MemoryState) GetState(addr common.Address, key common.Hash) common.Hash {
+	if acc := state.get(addr, false); acc != nil {
+		return acc.storage[key]
+	}
+	return common.Hash{}
+}
+
+func (state *MemoryState) SetState(addr common.Address, key, value common.Hash) {
+	acc := state.get(addr, true)
+	prev, ok := acc.storage[key]
+	acc.storage[key] = value
+	state.journal = append(state.journal, func() {
+		if ok {
+			acc.storage[key] = prev
+		} else {
+			delete(acc.storage, key)
+		}
+	})
+}
+
+func (state *MemoryState) Suicide(addr common.Address) bool {
+	acc := state.get(addr, false)
+	if acc == nil {
+		return false
+	}
+	suicided, balance := acc.suicided, acc.balance
+	acc.suicided, acc.balance = true, new(big.Int)
+	state.journal = append(state.journal, func() { acc.suicided, acc.balance = suicided, balance })
+	return true
+}
+
+func (state *MemoryState) HasSuicided(addr common.Address) bool {
+	acc := state.get(addr, false)
+	return acc != nil && acc.suicided
+}
+
+func (state *MemoryState) Exist(addr common.Address) bool {
+	return state.get(addr, false) != nil
+}
+
+func (state *MemoryState) Empty(addr common.Address) bool {
+	acc := state.get(addr, false)
+	return acc == nil || acc.balance.Sign() == 0 && acc.nonce == 0 && len(acc.code) == 0
+}
+
+func (state *MemoryState) Snapshot() int {
+	state.revisions = append(state.revisions, len(state.journal))
+	return len(state.revisions) - 1
+}
+
+func (state *MemoryState) RevertToSnapshot(id int) {
+	if id < 0 || id >= len(state.revisions) {
+		return
+	}
+	mark := state.revisions[id]
+	for i := len(state.journal) - 1; i >= mark; i-- {
+		state.journal[i]()
+	}
+	state.journal, state.revisions = state.journal[:mark], state.revisions[:id]
+}
+
+func (state *MemoryState) GetNextRevisionId() int {
+	return len(state.revisions)
+}
+
+func (state *MemoryState) AddLog(log *types.Log) {
+	state.logs = append(state.logs, log)
+	n := len(state.logs) - 1
+	state.journal = append(state.journal, func() { state.logs = state.logs[:n] })
+}
+
+// Logs returns the logs added since the last call and forgets them.
+func (state *MemoryState) Logs() []*types.Log {
+	logs := state.logs
+	state.logs = nil
+	return logs
+}
+
+func (state *MemoryState) AddPreimage(common.Hash, []byte) {}
+
+func (state *MemoryState) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
+	acc := state.get(addr, false)
+	if acc == nil {
+		return
+	}
+	for key, value := range acc.storage {
+		if !cb(key, value) {
+			return
+		}
+	}
+}
+
+// ForEachStorageFrom iterates the storage of addr in the order of the keys from start
+// on, the storage ranges page through it.
+func (state *MemoryState) ForEachStorageFrom(addr common.Address, start common.Hash, cb func(key, value common.Hash) bool) {
+	acc := state.get(addr, false)
+	if acc == nil {
+		return
+	}
+	keys := make([]common.Hash, 0, len(acc.storage))
+	for key := range acc.storage {
+		if bytes.Compare(key[:], start[:]) >= 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	for _, key := range keys {
+		if !cb(key, acc.storage[key]) {
+			return
+		}
+	}
+}
+
+// Digest stands for the state root, the tree has no trie: the hash of the accounts,
+// ordered by address, with their balance, nonce, code hash and non-empty slots.
+func (state *MemoryState) Digest() common.Hash {
+	addrs := make([]common.Address, 0, len(state.accounts))
+	for addr := range state.accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	var encoded []byte
+	for _, addr := range addrs {
+		acc := state.accounts[addr]
+		encoded = append(encoded, addr[:]...)
+		encoded = append(encoded, common.BigToHash(acc.balance).Bytes()...)
+		encoded = append(encoded, common.BigToHash(new(big.Int).SetUint64(acc.nonce)).Bytes()...)
+		encoded = append(encoded, crypto.Keccak256(acc.code)...)
+		keys := make([]common.Hash, 0, len(acc.storage))
+		for key, value := range acc.storage {
+			if value != (common.Hash{}) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+		for _, key := range keys {
+			value := acc.storage[key]
+			encoded = append(encoded, key[:]...)
+			encoded = append(encoded, value[:]...)
+		}
+	}
+	return crypto.Keccak256Hash(encoded)
+}
+
+// Finalise drops suicided accounts, the touched empty ones when deleteEmpty is set
+// (EIP-161), and the journal: the state can no longer be reverted past this point.
+func (state *MemoryState) Finalise(deleteEmpty bool) {
+	for addr, acc := range state.accounts {
+		if acc.suicided || deleteEmpty && acc.touched && state.Empty(addr) {
+			delete(state.accounts, addr)
+		}
+		acc.touched = false
+	}
+	state.refund = new(big.Int)
+	state.journal, state.revisions = nil, nil
+}
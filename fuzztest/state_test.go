@@ -0,0 +1,26 @@
+package fuzztest
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMemoryStateRevert(t *testing.T) {
+	state := NewMemoryState()
+	key := common.HexToHash("0x01")
+	state.SetState(alice, key, common.HexToHash("0x02"))
+	snapshot := state.Snapshot()
+	state.SetState(alice, key, common.HexToHash("0x03"))
+	state.AddBalance(deployer, ether)
+	if state.GetNextRevisionId() != snapshot+1 {
+		t.Fatalf("next revision %d, want %d", state.GetNextRevisionId(), snapshot+1)
+	}
+	state.RevertToSnapshot(snapshot)
+	if got := state.GetState(alice, key); got != common.HexToHash("0x02") {
+		t.Errorf("slot after revert %x", got)
+	}
+	if state.Exist(deployer) {
+		t.Errorf("account created after the snapshot survived the revert")
+	}
+}
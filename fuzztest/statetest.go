@@ -0,0 +1,543 @@
+/**
+* @statetest.go
+* State tests replayed through the instrumented EVM, proving the instrumentation
+* (recover blocks, early returns, the forks of the replay probes) leaves the consensus
+* results alone.
+* 1 a StateTest is in the layout of the fillers of the standard state tests: a
+*   pre-state, a transaction whose data, gas limit and value have variants, and the
+*   expected results per variant and network, account by account. The tree has no trie
+*   to check the roots of the filled tests against: the results are checked instead,
+*   an account marked shouldnotexist must not exist, a listed storage is the whole
+*   storage of the account.
+* 2 RunStateTest applies a variant of the transaction as a block would (nonce, gas
+*   bought, intrinsic gas, refund, fee paid to the coinbase) under the Byzantium rules
+*   of the chain config, watched with the replay probes on (griefing, revert bomb,
+*   return poisoning, time and fee skew) or unwatched, checks the results of the
+*   network StateTestFork and returns the digest of the post-state
+*   (MemoryState.Digest): both runs must end on the same one. Creations are not
+*   watched.
+* 3 the fixtures of the tree live under testdata/statetests, eip158.json covers the
+*   empty account semantics of EIP-158/161. The upstream fillers of stEIP158Specific
+*   are replayed as well when the tests repository is checked out under
+*   tests/testdata; the tests whose code or data is not hex (LLL, ABI, Yul) or which
+*   select their variants by label are StateUnsupported and skipped.
+ */
+package fuzztest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// StateTestFork is the network whose results the state tests check, the last fork of
+// the chain config of the tree.
+const StateTestFork = "Byzantium"
+
+// stateForks are the networks of the fillers in order.
+var stateForks = []string{"Frontier", "Homestead", "EIP150", "EIP158", "Byzantium", "Constantinople", "ConstantinopleFix", "Istanbul", "Berlin", "London", "Merge", "Shanghai"}
+
+// StateUnsupported is returned for the tests written in a language the tree does not
+// compile or selecting their variants by label.
+type StateUnsupported string
+
+func (err StateUnsupported) Error() string { return "unsupported: " + string(err) }
+
+// StateEnv is the block of a state test.
+type StateEnv struct {
+	Coinbase   string `json:"currentCoinbase"`
+	Difficulty string `json:"currentDifficulty"`
+	GasLimit   string `json:"currentGasLimit"`
+	Number     string `json:"currentNumber"`
+	Timestamp  string `json:"currentTimestamp"`
+}
+
+// StateAccount is an account of the pre-state of a state test.
+type StateAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   string            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// StateTransaction is the transaction of a state test, Data, GasLimit and Value hold
+// its variants and To is empty for a creation.
+type StateTransaction struct {
+	Data      []string `json:"data"`
+	GasLimit  []string `json:"gasLimit"`
+	GasPrice  string   `json:"gasPrice"`
+	Nonce     string   `json:"nonce"`
+	SecretKey string   `json:"secretKey"`
+	To        string   `json:"to"`
+	Value     []string `json:"value"`
+}
+
+// StateResult is the expected post-state of an account, the fields left out are not
+// checked.
+type StateResult struct {
+	Balance        *string           `json:"balance"`
+	Nonce          *string           `json:"nonce"`
+	Code           *string           `json:"code"`
+	Storage        map[string]string `json:"storage"`
+	ShouldNotExist json.RawMessage   `json:"shouldnotexist"`
+}
+
+// StateIndexes selects the variants an expectation applies to, -1 is any.
+type StateIndexes struct {
+	Data  stateIndex `json:"data"`
+	Gas   stateIndex `json:"gas"`
+	Value stateIndex `json:"value"`
+}
+
+// StateExpect is the expected post-state of the variants of Indexes on Network.
+type StateExpect struct {
+	Indexes StateIndexes            `json:"indexes"`
+	Network []string                `json:"network"`
+	Result  map[string]*StateResult `json:"result"`
+}
+
+// StateTest is a state test.
+type StateTest struct {
+	Env         StateEnv                 `json:"env"`
+	Pre         map[string]*StateAccount `json:"pre"`
+	Transaction StateTransaction         `json:"transaction"`
+	Expect      []StateExpect            `json:"expect"`
+}
+
+// StateVariant is a variant of the transaction of a state test, by index of its data,
+// gas limit and value.
+type StateVariant struct {
+	Data, Gas, Value int
+}
+
+func (variant StateVariant) String() string {
+	return fmt.Sprintf("d%d g%d v%d", variant.Data, variant.Gas, variant.Value)
+}
+
+// stateIndex is an index of an expectation: a number, or a list of numbers and ranges
+// "a-b". A label is kept to be refused when the expectation is checked.
+type stateIndex struct {
+	values []int
+	label  string
+}
+
+func (index *stateIndex) UnmarshalJSON(input []byte) error {
+	var one int
+	if err := json.Unmarshal(input, &one); err == nil {
+		index.values = []int{one}
+		return nil
+	}
+	var items []interface{}
+	if err := json.Unmarshal(input, &items); err != nil {
+		items = []interface{}{string(bytes.Trim(input, `"`))}
+	}
+	for _, item := range items {
+		switch item := item.(type) {
+		case float64:
+			index.values = append(index.values, int(item))
+		case string:
+			bounds := strings.SplitN(item, "-", 2)
+			from, err := strconv.Atoi(bounds[0])
+			to := from
+			if err == nil && len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+			}
+			if err != nil {
+				index.label = item
+				continue
+			}
+			for i := from; i <= to; i++ {
+				index.values = append(index.values, i)
+			}
+		default:
+			return fmt.Errorf("invalid index %s", input)
+		}
+	}
+	return nil
+}
+
+func (index stateIndex) has(i int) bool {
+	for _, want := range index.values {
+		if want == -1 || want == i {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadStateTests reads the state tests of the file at path by name.
+func LoadStateTests(path string) (map[string]*StateTest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tests := make(map[string]*StateTest)
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return tests, nil
+}
+
+// Variants returns the variants of the transaction of the test.
+func (test *StateTest) Variants() []StateVariant {
+	var variants []StateVariant
+	for data := range test.Transaction.Data {
+		for gas := range test.Transaction.GasLimit {
+			for value := range test.Transaction.Value {
+				variants = append(variants, StateVariant{data, gas, value})
+			}
+		}
+	}
+	return variants
+}
+
+// stateNumber parses a number of a test, decimal or hex, empty is zero.
+func stateNumber(s string) (*big.Int, error) {
+	s = strings.TrimPrefix(s, ":bigint ")
+	if s == "" {
+		return new(big.Int), nil
+	}
+	n, base := s, 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, base = s[2:], 16
+	}
+	if n == "" {
+		return new(big.Int), nil
+	}
+	value, ok := new(big.Int).SetString(n, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return value, nil
+}
+
+// stateBytes parses the code or data of a test, only hex is supported.
+func stateBytes(s string) ([]byte, error) {
+	s = strings.TrimPrefix(s, ":raw ")
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, StateUnsupported(fmt.Sprintf("%.20q is not hex", s))
+	}
+	return hexutil.Decode(s)
+}
+
+// stateStorage parses a storage of a test.
+func stateStorage(storage map[string]string) (map[common.Hash]common.Hash, error) {
+	parsed := make(map[common.Hash]common.Hash, len(storage))
+	for key, value := range storage {
+		k, err := stateNumber(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := stateNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		if v.Sign() != 0 {
+			parsed[common.BigToHash(k)] = common.BigToHash(v)
+		}
+	}
+	return parsed, nil
+}
+
+// stateNetwork tells whether the networks of an expectation cover fork: the networks
+// are named, ALL, or a fork after a comparison.
+func stateNetwork(networks []string, fork string) bool {
+	position := func(name string) int {
+		for i, known := range stateForks {
+			if strings.EqualFold(name, known) {
+				return i
+			}
+		}
+		return -1
+	}
+	at := position(fork)
+	for _, network := range networks {
+		if network == "ALL" {
+			return true
+		}
+		var cmp string
+		for _, prefix := range []string{">=", "<=", ">", "<"} {
+			if strings.HasPrefix(network, prefix) {
+				cmp, network = prefix, network[len(prefix):]
+				break
+			}
+		}
+		other := position(network)
+		if other < 0 {
+			continue
+		}
+		switch {
+		case cmp == "" && at == other, cmp == ">=" && at >= other, cmp == "<=" && at <= other,
+			cmp == ">" && at > other, cmp == "<" && at < other:
+			return true
+		}
+	}
+	return false
+}
+
+// stateMessage is a variant of the transaction of a state test.
+type stateMessage struct {
+	from  common.Address
+	to    *common.Address
+	nonce uint64
+	gas   uint64
+	price *big.Int
+	value *big.Int
+	data  []byte
+}
+
+func (test *StateTest) message(variant StateVariant) (*stateMessage, error) {
+	tx := test.Transaction
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(tx.SecretKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("secret key: %v", err)
+	}
+	msg := &stateMessage{from: crypto.PubkeyToAddress(key.PublicKey)}
+	if tx.To != "" {
+		to := common.HexToAddress(tx.To)
+		msg.to = &to
+	}
+	if msg.data, err = stateBytes(tx.Data[variant.Data]); err != nil {
+		return nil, err
+	}
+	gas, err := stateNumber(tx.GasLimit[variant.Gas])
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := stateNumber(tx.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	msg.gas, msg.nonce = gas.Uint64(), nonce.Uint64()
+	if msg.price, err = stateNumber(tx.GasPrice); err != nil {
+		return nil, err
+	}
+	if msg.value, err = stateNumber(tx.Value[variant.Value]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// install sets the block and the pre-state of the test on chain.
+func (test *StateTest) install(chain *Chain) error {
+	env := test.Env
+	chain.Coinbase = common.HexToAddress(env.Coinbase)
+	for _, field := range []struct {
+		value string
+		dst   **big.Int
+	}{{env.Difficulty, &chain.Difficulty}, {env.GasLimit, &chain.GasLimit}, {env.Number, &chain.Number}, {env.Timestamp, &chain.Time}} {
+		if field.value == "" {
+			continue
+		}
+		n, err := stateNumber(field.value)
+		if err != nil {
+			return err
+		}
+		*field.dst = n
+	}
+	for addr, acc := range test.Pre {
+		balance, err := stateNumber(acc.Balance)
+		if err != nil {
+			return err
+		}
+		nonce, err := stateNumber(acc.Nonce)
+		if err != nil {
+			return err
+		}
+		code, err := stateBytes(acc.Code)
+		if err != nil {
+			return err
+		}
+		storage, err := stateStorage(acc.Storage)
+		if err != nil {
+			return err
+		}
+		chain.alloc(common.HexToAddress(addr), balance, nonce.Uint64(), code, storage)
+	}
+	// the pre-state is not touched by the transaction
+	chain.State.Finalise(false)
+	return nil
+}
+
+// RunStateTest installs the pre-state of test on a new chain, applies the variant of its
+// transaction, watched or not, checks the post-state against the results expected on
+// StateTestFork and returns its digest.
+func RunStateTest(test *StateTest, variant StateVariant, watched bool) (common.Hash, error) {
+	msg, err := test.message(variant)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	chain := NewChain()
+	defer chain.Close()
+	campaign := vm.GetGlobalCampaign()
+	campaign.SetFork(vm.ForkByzantium)
+	if err := test.install(chain); err != nil {
+		return common.Hash{}, err
+	}
+	if watched {
+		campaign.SetGriefing(true)
+		campaign.SetRevertBomb(true)
+		campaign.SetReturnPoisoning(true)
+		campaign.SetTimeSkew(true, nil)
+		campaign.SetFeeSkew(true, nil)
+	}
+	chain.apply(msg, watched)
+	return chain.State.Digest(), test.check(chain.State, variant)
+}
+
+// apply applies msg as the transaction of a block, watched when it is a message call.
+// An invalid transaction leaves the state alone.
+func (chain *Chain) apply(msg *stateMessage, watched bool) {
+	state := chain.State
+	intrinsic := params.TxGas
+	if msg.to == nil {
+		intrinsic = params.TxGasContractCreation
+	}
+	for _, b := range msg.data {
+		if b == 0 {
+			intrinsic += params.TxDataZeroGas
+		} else {
+			intrinsic += params.TxDataNonZeroGas
+		}
+	}
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(msg.gas), msg.price)
+	if state.GetNonce(msg.from) != msg.nonce || state.GetBalance(msg.from).Cmp(cost) < 0 || msg.gas < intrinsic {
+		return
+	}
+	state.SubBalance(msg.from, cost)
+
+	var (
+		dog  *vm.WatchDog
+		tx   *types.Transaction
+		left uint64
+	)
+	if msg.to == nil {
+		// the creation bumps the nonce of the sender itself
+		evm := vm.NewEVM(chain.context(msg.from, msg.price), state, chain.Config, vm.Config{})
+		_, _, left, _ = evm.Create(vm.AccountRef(msg.from), msg.data, msg.gas-intrinsic, msg.value)
+	} else {
+		evm, _ := chain.evm(msg.from, msg.price)
+		if watched {
+			tx = types.NewTransaction(msg.nonce, *msg.to, msg.value, new(big.Int).SetUint64(msg.gas), msg.price, msg.data)
+			dog = vm.GetGlobalWatchDog()
+			dog.OnBlockStart(chain.header())
+			dog.Start()
+			dog.Watch(evm, tx)
+		}
+		_, left, _ = evm.Call(vm.AccountRef(msg.from), *msg.to, msg.data, msg.gas-intrinsic, msg.value)
+	}
+	logs := state.Logs()
+	refund := state.GetRefund().Uint64()
+	if used := msg.gas - left; refund > used/2 {
+		refund = used / 2
+	}
+	left += refund
+	state.AddBalance(msg.from, new(big.Int).Mul(new(big.Int).SetUint64(left), msg.price))
+	state.AddBalance(chain.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(msg.gas-left), msg.price))
+	if dog != nil {
+		used := new(big.Int).SetUint64(msg.gas - left)
+		receipt := &types.Receipt{TxHash: tx.Hash(), GasUsed: used, CumulativeGasUsed: used, Logs: logs}
+		dog.End(receipt)
+		dog.OnBlockEnd(chain.header(), types.Receipts{receipt})
+	}
+	chain.finalise()
+}
+
+// check compares state with the results expected for variant on StateTestFork.
+func (test *StateTest) check(state *MemoryState, variant StateVariant) error {
+	for _, expect := range test.Expect {
+		for _, index := range []stateIndex{expect.Indexes.Data, expect.Indexes.Gas, expect.Indexes.Value} {
+			if index.label != "" {
+				return StateUnsupported("index " + index.label)
+			}
+		}
+		if !stateNetwork(expect.Network, StateTestFork) || !expect.Indexes.Data.has(variant.Data) ||
+			!expect.Indexes.Gas.has(variant.Gas) || !expect.Indexes.Value.has(variant.Value) {
+			continue
+		}
+		addrs := make([]string, 0, len(expect.Result))
+		for addr := range expect.Result {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			if err := checkStateResult(state, common.HexToAddress(addr), expect.Result[addr]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkStateResult compares the account addr of state with its expected result.
+func checkStateResult(state *MemoryState, addr common.Address, want *StateResult) error {
+	acc := state.accounts[addr]
+	switch {
+	case len(want.ShouldNotExist) > 0:
+		if acc != nil {
+			return fmt.Errorf("account %s: exists, want none", addr.Hex())
+		}
+		return nil
+	case acc == nil:
+		return fmt.Errorf("account %s: missing", addr.Hex())
+	}
+	if want.Balance != nil {
+		balance, err := stateNumber(*want.Balance)
+		if err != nil {
+			return err
+		}
+		if acc.balance.Cmp(balance) != 0 {
+			return fmt.Errorf("account %s: balance %v, want %v", addr.Hex(), acc.balance, balance)
+		}
+	}
+	if want.Nonce != nil {
+		nonce, err := stateNumber(*want.Nonce)
+		if err != nil {
+			return err
+		}
+		if acc.nonce != nonce.Uint64() {
+			return fmt.Errorf("account %s: nonce %d, want %v", addr.Hex(), acc.nonce, nonce)
+		}
+	}
+	if want.Code != nil {
+		code, err := stateBytes(*want.Code)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(acc.code, code) {
+			return fmt.Errorf("account %s: code %x, want %x", addr.Hex(), acc.code, code)
+		}
+	}
+	if want.Storage != nil {
+		storage, err := stateStorage(want.Storage)
+		if err != nil {
+			return err
+		}
+		for key, value := range acc.storage {
+			if value != storage[key] {
+				return fmt.Errorf("account %s: slot %s = %s, want %s", addr.Hex(), key.Hex(), value.Hex(), storage[key].Hex())
+			}
+		}
+		for key, value := range storage {
+			if acc.storage[key] != value {
+				return fmt.Errorf("account %s: slot %s = %s, want %s", addr.Hex(), key.Hex(), acc.storage[key].Hex(), value.Hex())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package fuzztest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// upstreamStateTests are the fillers of the tests repository, checked out under
+// tests/testdata.
+var upstreamStateTests = filepath.Join("..", "tests", "testdata", "src", "GeneralStateTestsFiller", "stEIP158Specific")
+
+func runStateTests(t *testing.T, path string) {
+	tests, err := LoadStateTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, variant := range tests[name].Variants() {
+			plain, err := RunStateTest(tests[name], variant, false)
+			if _, ok := err.(StateUnsupported); ok {
+				t.Logf("%s %v: skipped, %v", name, variant, err)
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %v unwatched: %v", name, variant, err)
+				continue
+			}
+			instrumented, err := RunStateTest(tests[name], variant, true)
+			if err != nil {
+				t.Errorf("%s %v watched: %v", name, variant, err)
+				continue
+			}
+			if instrumented != plain {
+				t.Errorf("%s %v: watched post-state %x, unwatched %x", name, variant, instrumented, plain)
+			}
+		}
+	}
+}
+
+func TestEIP158StateTests(t *testing.T) {
+	path := filepath.Join("testdata", "statetests", "eip158.json")
+	runStateTests(t, path)
+
+	// the post-state is checked against the expectations, not only between the runs
+	tests, err := LoadStateTests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := tests["callValueToMissing"]
+	balance := "0x2"
+	test.Expect[0].Result["0x000000000000000000000000000000000000dead"].Balance = &balance
+	for _, watched := range []bool{false, true} {
+		if _, err := RunStateTest(test, StateVariant{}, watched); err == nil {
+			t.Errorf("watched %v: wrong balance expected and no error", watched)
+		}
+	}
+}
+
+func TestUpstreamEIP158StateTests(t *testing.T) {
+	if _, err := os.Stat(upstreamStateTests); err != nil {
+		t.Skip("tests repository not checked out under tests/testdata")
+	}
+	files, err := filepath.Glob(filepath.Join(upstreamStateTests, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		runStateTests(t, file)
+	}
+}
+
+func TestStateNetwork(t *testing.T) {
+	tests := []struct {
+		networks []string
+		want     bool
+	}{
+		{[]string{"ALL"}, true},
+		{[]string{"Byzantium"}, true},
+		{[]string{"EIP158"}, false},
+		{[]string{">=EIP158"}, true},
+		{[]string{">Byzantium"}, false},
+		{[]string{"<=Byzantium"}, true},
+		{[]string{"<Byzantium", ">=Constantinople"}, false},
+		{[]string{"Frontier", "Homestead", "Byzantium"}, true},
+		{[]string{"Unknown"}, false},
+	}
+	for _, test := range tests {
+		if got := stateNetwork(test.networks, StateTestFork); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.networks, got, test.want)
+		}
+	}
+}
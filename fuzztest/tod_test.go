@@ -0,0 +1,69 @@
+package fuzztest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// block runs the watched transactions of senders to the contract to in one block and
+// returns the TOD findings the block raised.
+func block(t *testing.T, chain *Chain, to common.Address, senders ...common.Address) []vm.HackerRecentFinding {
+	dog := vm.GetGlobalWatchDog()
+	dog.OnBlockStart(chain.header())
+	hashes := make(map[common.Hash]bool)
+	for _, from := range senders {
+		tx := watch(chain, dog, from, to, new(big.Int), nil)
+		hashes[tx.tx.Hash()] = true
+		receipt, err := tx.call()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dog.End(receipt)
+	}
+	dog.OnBlockEnd(chain.header(), nil)
+	chain.finalise()
+	chain.Number.Add(chain.Number, common.Big1)
+
+	findings := make([]vm.HackerRecentFinding, 0)
+	for _, finding := range vm.GetGlobalCampaign().Findings(0) {
+		if finding.Type == "tod" && hashes[finding.Hash] {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestTOD(t *testing.T) {
+	chain := NewChain()
+	defer chain.Close()
+	// the first caller wins: if iszero(sload(0)) { sstore(0, caller) } stop
+	first := deploy(t, chain, common.FromHex("0x600054600a57336000555b00"))
+	// sstore(0, add(sload(0), 1)) stop
+	counter := deploy(t, chain, common.FromHex("0x60016000540160005500"))
+
+	findings := block(t, chain, first, alice, deployer)
+	if len(findings) != 1 {
+		t.Fatalf("%d TOD findings, want 1", len(findings))
+	}
+	slot := first.Hex() + ":" + common.Hash{}.Hex()
+	if finding := findings[0]; finding.Target != first || finding.Detail["slots"] != slot || finding.Detail["orders"] != "[[1 0]]" {
+		t.Errorf("finding on %s %v, want slot %s diverging in the order [1 0]", finding.Target.Hex(), finding.Detail, slot)
+	}
+	if got := common.BytesToAddress(chain.State.GetState(first, common.Hash{}).Bytes()); got != alice {
+		t.Errorf("winner %s after the replays, want alice", got.Hex())
+	}
+	// the increments commute
+	if findings := block(t, chain, counter, alice, deployer, alice); len(findings) != 0 {
+		t.Errorf("TOD findings %v on commuting transactions", findings)
+	}
+	// a transaction alone has no order
+	if findings := block(t, chain, first, alice); len(findings) != 0 {
+		t.Errorf("TOD findings %v on a single transaction", findings)
+	}
+	if got := chain.State.GetState(counter, common.Hash{}); got != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("counter %s after the replays, want 3", got.Hex())
+	}
+}